@@ -7,6 +7,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -14,20 +15,26 @@ import (
 	pkgErrors "github.com/pkg/errors"
 
 	"github.com/manifoldco/promptui"
+	"go.opentelemetry.io/otel/attribute"
 
 	"github.com/fpt/klein-cli/internal/claude"
 	"github.com/fpt/klein-cli/internal/config"
 	"github.com/fpt/klein-cli/internal/infra"
+	"github.com/fpt/klein-cli/internal/lsp"
 	"github.com/fpt/klein-cli/internal/permission"
 	pluginpkg "github.com/fpt/klein-cli/internal/plugin"
 	"github.com/fpt/klein-cli/internal/repository"
+	"github.com/fpt/klein-cli/internal/session"
 	"github.com/fpt/klein-cli/internal/skill"
+	"github.com/fpt/klein-cli/internal/telemetry"
 	"github.com/fpt/klein-cli/internal/tool"
 	"github.com/fpt/klein-cli/internal/tool/memorydb"
+	"github.com/fpt/klein-cli/internal/webarchive"
 	"github.com/fpt/klein-cli/pkg/agent/domain"
 	"github.com/fpt/klein-cli/pkg/agent/events"
 	"github.com/fpt/klein-cli/pkg/agent/react"
 	"github.com/fpt/klein-cli/pkg/agent/state"
+	"github.com/fpt/klein-cli/pkg/agent/tracing"
 	"github.com/fpt/klein-cli/pkg/client"
 	pkgLogger "github.com/fpt/klein-cli/pkg/logger"
 	"github.com/fpt/klein-cli/pkg/message"
@@ -57,10 +64,12 @@ type Agent struct {
 	out                  io.Writer
 	router               *SkillsRouter
 	thinkingStarted      bool
+	tail                 liveTail            // live output of the running tool
 	sessionRules         *permission.RuleSet // in-memory allow/deny rules created during this session
 	permRules            *permission.RuleSet // persistent allow/deny rules from JSON files
 	allowedToolsOverride []string            // CLI override for skill's allowed-tools (guarded by sandboxMu)
-	sandboxMu            sync.RWMutex        // guards allowedToolsOverride: background runs read it off-turn
+	commandModelPin      config.ModelPin     // running plugin command's model pin (guarded by sandboxMu)
+	sandboxMu            sync.RWMutex        // guards allowedToolsOverride and commandModelPin: background runs read them off-turn
 	sanitizeToolResults  bool                // neutralize chat-template control tokens in tool results
 	tokenBudget          int                 // cumulative token cap per Invoke run (0 = unlimited)
 	externalEventHandler events.EventHandler // optional: forward events to external consumers (e.g., Connect server)
@@ -68,6 +77,24 @@ type Agent struct {
 	memoryDir            string              // $HOME/.klein/projects/<hash>/memory/ (interactive mode only)
 	toolResultsDir       string              // $HOME/.klein/projects/<hash>/tool_results/ (interactive mode only)
 	memoryManager        *memorydb.Manager   // sqlite long-term memory, when wired in (serve/claw); nil otherwise
+	webArchive           *webarchive.Archive // fetched pages kept for citation; nil when [web] disables it
+	toolApprover         ToolApprover        // remote approval (Connect clients); nil uses the terminal dialog
+	turnToolsMu          sync.Mutex
+	turnTools            domain.ToolManager // tools the latest turn offered the model, for /context (guarded by turnToolsMu)
+
+	// Long-term memory extraction (see memory_pipeline.go). memoryExtractMu
+	// serializes passes; memoryExtractWG tracks the background ones.
+	memoryExtractMu   sync.Mutex
+	memoryExtractWG   sync.WaitGroup
+	memorySessionOnce sync.Once
+	memorySessionID   string
+
+	// Clients for definitions that pin their own backend/model/effort, keyed by
+	// the pin (see clientFor). newLLMClient overrides
+	// client.NewLLMClient in tests.
+	pinnedMu      sync.Mutex
+	pinnedClients map[config.ModelPin]domain.LLM
+	newLLMClient  func(config.LLMSettings) (domain.LLM, error)
 
 	// codexBackend, when set (llm.backend == "codex"), routes Invoke to a codex
 	// app-server thread instead of the ReAct loop. codexThreadID caches this
@@ -93,6 +120,9 @@ func (a *Agent) WorkingDir() string { return a.workingDir }
 // FilesystemRepository returns the shared filesystem repository instance.
 func (a *Agent) FilesystemRepository() repository.FilesystemRepository { return a.fsRepo }
 
+// TodoManager returns the session's todo list, shared with the TodoWrite tool.
+func (a *Agent) TodoManager() *tool.TodoToolManager { return a.todoToolManager }
+
 // SetAllowedToolsOverride sets a CLI-level override for the skill's allowed-tools.
 // When non-empty, this list is used instead of the skill's own allowed-tools field.
 // It is a hard sandbox: subagents dispatched from this agent are bounded by it
@@ -210,7 +240,7 @@ type subagentOptions struct {
 
 func (a *Agent) runSubagent(
 	ctx context.Context, def *skill.Definition, task string, opts subagentOptions,
-) (string, error) {
+) (result string, err error) {
 	toolsOverride, maxIterations := opts.ToolsOverride, opts.MaxIterations
 	if def == nil {
 		return "", errors.New("subagent: nil definition")
@@ -224,7 +254,7 @@ func (a *Agent) runSubagent(
 	if err != nil {
 		return "", err
 	}
-	subToolManager := buildSubAgentToolManager(a.allToolManagers, allowed)
+	subToolManager := buildSubAgentToolManager(a.allToolManagers, allowed, def.DisallowedTools)
 
 	if maxIterations <= 0 {
 		maxIterations = DefaultAgentMaxIterations
@@ -234,6 +264,10 @@ func (a *Agent) runSubagent(
 	if def.PluginName != "" {
 		label = def.PluginName + ":" + def.Name
 	}
+	ctx, span := tracing.Start(ctx, tracing.SpanSubagent,
+		tracing.AttrAgent.String(label), tracing.AttrBackground.Bool(def.Background || opts.SkipApproval))
+	defer func() { tracing.End(span, err) }()
+
 	writer := opts.Writer
 	if writer == nil {
 		writer = a.OutWriter()
@@ -246,7 +280,11 @@ func (a *Agent) runSubagent(
 		subState.AddMessage(message.NewSystemMessage(prompt))
 	}
 
-	llmWithTools, err := client.NewClientWithToolManager(a.llmClient, subToolManager)
+	llm, err := a.clientFor(definitionPin(def))
+	if err != nil {
+		return "", fmt.Errorf("agent %s: %w", label, err)
+	}
+	llmWithTools, err := client.NewClientWithToolManager(llm, subToolManager)
 	if err != nil {
 		return "", fmt.Errorf("agent %s: failed to create LLM client: %w", label, err)
 	}
@@ -257,6 +295,8 @@ func (a *Agent) runSubagent(
 	if a.settings != nil {
 		reactClient.SetBashWhitelist(a.settings.Bash.WhitelistedCommands)
 	}
+	reactClient.SetApprovalCheck(a.requiresRuleApproval)
+	reactClient.SetToolGate(a.enforceRules)
 	// Auto-approve every tool call when the definition declares itself a
 	// background agent, or when this run is detached and nobody is at the
 	// prompt to answer. The frontmatter tool list is the surface area the user
@@ -287,13 +327,13 @@ func (a *Agent) runSubagent(
 		}
 	})
 
-	result, err := reactClient.Run(ctx, task)
+	resp, err := reactClient.Run(ctx, task)
 	if err != nil {
 		fmt.Fprintf(writer, "  [agent:%s] Failed: %v\n", label, err)
 		return "", err
 	}
 	fmt.Fprintf(writer, "  [agent:%s] Done\n", label)
-	return result.Content(), nil
+	return resp.Content(), nil
 }
 
 // DispatchTask resolves a name for the Task tool and runs it as a subagent.
@@ -315,7 +355,7 @@ func (a *Agent) DispatchTask(ctx context.Context, req tool.TaskRequest) (string,
 	// A definition marked `background: true` detaches by default; the caller
 	// can also ask for it per dispatch.
 	if req.Background || def.Background {
-		info, err := a.StartBackgroundAgent(def, task)
+		info, err := a.StartBackgroundAgent(ctx, def, task)
 		if err != nil {
 			return "", err
 		}
@@ -396,9 +436,12 @@ func intersectTools(defTools, bound []string) []string {
 
 // buildSubAgentToolManager constructs a filtered tool manager for sub-agents:
 // always strips Task to prevent recursion; respects the optional allowedTools
-// whitelist.
-func buildSubAgentToolManager(all *tool.CompositeToolManager, allowedTools []string) domain.ToolManager {
+// whitelist, and strips the definition's deniedTools after it.
+func buildSubAgentToolManager(all *tool.CompositeToolManager, allowedTools, deniedTools []string) domain.ToolManager {
 	excluded := map[string]bool{"Task": true}
+	for _, name := range deniedTools {
+		excluded[name] = true
+	}
 	if len(allowedTools) > 0 {
 		filtered := make([]string, 0, len(allowedTools))
 		for _, name := range allowedTools {
@@ -460,6 +503,16 @@ type AgentOptions struct {
 	// starting a fresh one (`klein --continue`). Interactive mode only; a fresh
 	// session is the default so a plain `klein` never inherits stale context.
 	ContinueSession bool
+
+	// TerminalDispatcher, when set, lets Bash run commands in an attached
+	// editor's terminal (Connect clients with the terminal capability).
+	TerminalDispatcher tool.TerminalDispatcher
+
+	// ResumeSession is the path of a specific session file to resume
+	// (`klein --resume <id>`, `klein sessions resume <id>`). It wins over
+	// ContinueSession, and unlike it a session that will not load is an error:
+	// the user named it.
+	ResumeSession string
 }
 
 // resolveLLMClient returns opts.LLMClient when set, otherwise builds one from the
@@ -514,6 +567,23 @@ func computeToolResultsDir(isInteractiveMode bool, workingDir string) string {
 	return dir
 }
 
+// computeCodeIndexPath returns where the CodeSearch index is persisted, or ""
+// in one-shot/test mode, where it is rebuilt in memory on first use.
+func computeCodeIndexPath(isInteractiveMode bool, workingDir string) string {
+	if !isInteractiveMode {
+		return ""
+	}
+	userConfig, err := config.DefaultUserConfig()
+	if err != nil {
+		return ""
+	}
+	path, err := userConfig.GetProjectCodeIndexFile(workingDir)
+	if err != nil {
+		return ""
+	}
+	return path
+}
+
 // newSharedSessionState creates the shared message state and its session file
 // path. Interactive mode gets a *fresh* session file per run; it resumes the
 // project's most recently used session only when continueSession is set
@@ -573,8 +643,8 @@ func resumeLatestSession(
 		return nil, ""
 	}
 
-	sharedState := state.NewMessageStateWithRepository(infra.NewMessageHistoryRepository(latest))
-	if err := sharedState.LoadFromFile(); err != nil {
+	sharedState, err := loadSessionFile(latest)
+	if err != nil {
 		logger.Warn("Could not load previous session; starting fresh",
 			"session_file", latest, "error", err)
 		return nil, ""
@@ -584,6 +654,15 @@ func resumeLatestSession(
 	return sharedState, latest
 }
 
+// loadSessionFile restores the file-backed message state stored at path.
+func loadSessionFile(path string) (domain.State, error) {
+	sharedState := state.NewMessageStateWithRepository(infra.NewMessageHistoryRepository(path))
+	if err := sharedState.LoadFromFile(); err != nil {
+		return nil, fmt.Errorf("failed to load session %s: %w", path, err)
+	}
+	return sharedState, nil
+}
+
 // agentTools bundles the tool managers an Agent keeps references to after
 // construction, plus the composite/deferred views the ReAct loop binds per skill.
 type agentTools struct {
@@ -596,6 +675,8 @@ type agentTools struct {
 	agentRuns   *tool.AgentRunToolManager
 	all         *tool.CompositeToolManager
 	deferred    *tool.DeferredToolManager
+	lsp         *lsp.Manager // nil when [lsp] is disabled
+	lspTools    *tool.LSPToolManager
 }
 
 // buildAgentTools constructs every tool manager (universal + specialized + MCP)
@@ -636,25 +717,56 @@ func buildAgentTools(opts AgentOptions, skills skill.DefinitionMap, memoryDir, t
 	}
 	filesystemManager := tool.NewFileSystemToolManager(opts.FsRepo, fsConfig, workingDir)
 
+	// Language servers start lazily, on the first query or edit of a file they
+	// handle, so configuring them costs nothing in a session that never needs
+	// one. Edits keep the servers in sync and report what the edit broke. The
+	// LSP tools are offered only when at least one server is installed.
+	var lspManager *lsp.Manager
+	var lspToolManager *tool.LSPToolManager
+	if servers := lspServers(opts.Settings.LSP); len(servers) > 0 {
+		lspManager = lsp.NewManager(workingDir, servers)
+		if len(lspManager.Available()) == 0 {
+			lspManager = nil
+		}
+	}
+	if lspManager != nil {
+		filesystemManager.SetPostEditHook(lspManager.AfterEdit)
+		// Rename rewrites files the language server picks, so each one must
+		// pass the checks Edit would apply to it.
+		lspToolManager = tool.NewLSPToolManager(lspManager)
+		lspToolManager.AddEditGuard(func(_, target string) error {
+			return filesystemManager.CheckWritable(target)
+		})
+	}
+
 	bashToolManager := tool.NewBashToolManager(tool.BashConfig{
 		WorkingDir:          workingDir,
 		MaxDuration:         2 * time.Minute,
 		WhitelistedCommands: opts.Settings.Bash.WhitelistedCommands,
 	})
+	if opts.TerminalDispatcher != nil {
+		bashToolManager.SetTerminalDispatcher(opts.TerminalDispatcher)
+	}
 
 	askQuestionManager := tool.NewAskUserQuestionToolManager()
 	planModeState := new(tool.PlanModeState) // starts as PlanModeOff
 	planToolManager := tool.NewPlanToolManager(planModeState)
 	taskAgentManager := tool.NewTaskAgentToolManager()
 	agentRunManager := tool.NewAgentRunToolManager()
+	marketManager := tool.NewMarketToolManager()
 
 	// Combine ALL tool managers into one composite.
 	managers := []domain.ToolManager{
 		todoToolManager, taskToolManager, filesystemManager, bashToolManager,
-		tool.NewSearchToolManager(tool.SearchConfig{WorkingDir: workingDir}),
-		tool.NewWebToolManager(), tool.NewPDFToolManager(workingDir), tool.NewMarketToolManager(),
+		tool.NewSearchToolManager(tool.SearchConfig{WorkingDir: workingDir, FileSystem: fsConfig}),
+		tool.NewCodeSearchToolManager(workingDir, computeCodeIndexPath(opts.IsInteractiveMode, workingDir), fsConfig),
+		tool.NewWebToolManager(), tool.NewPDFToolManager(workingDir), marketManager,
 		tool.NewSkillToolManager(skills, workingDir), askQuestionManager, planToolManager,
-		taskAgentManager, agentRunManager, tool.NewResearcherToolManager(),
+		taskAgentManager, agentRunManager, tool.NewResearcherToolManager(marketManager),
+		tool.NewReportToolManager(opts.Settings.ReportsDir(), marketManager),
+	}
+	if lspToolManager != nil {
+		managers = append(managers, lspToolManager)
 	}
 	for _, mcpManager := range opts.MCPToolManagers {
 		managers = append(managers, mcpManager)
@@ -671,6 +783,8 @@ func buildAgentTools(opts AgentOptions, skills skill.DefinitionMap, memoryDir, t
 		agentRuns:   agentRunManager,
 		all:         allToolManagers,
 		deferred:    tool.NewDeferredToolManager(allToolManagers),
+		lsp:         lspManager,
+		lspTools:    lspToolManager,
 	}
 }
 
@@ -746,15 +860,26 @@ func NewAgentWithOptions(ctx context.Context, opts AgentOptions) (*Agent, func()
 	// silently ignored, never fatal.
 	permRules := permission.LoadForProject(workingDir)
 
+	// Create or restore shared message state with session persistence. This
+	// runs before any tool manager exists so a named session that will not
+	// load fails the run before anything needs cleaning up.
+	var sharedState domain.State
+	var sessionFilePath string
+	if opts.ResumeSession != "" {
+		if sharedState, err = loadSessionFile(opts.ResumeSession); err != nil {
+			return nil, cleanup, err
+		}
+		sessionFilePath = opts.ResumeSession
+	} else {
+		sharedState, sessionFilePath = newSharedSessionState(
+			isInteractiveMode, skipSessionRestore, opts.ContinueSession, workingDir, logger,
+		)
+	}
+
 	// Build every tool manager (universal + specialized + MCP) and the
 	// composite/deferred views the ReAct loop binds per skill.
 	tools := buildAgentTools(opts, skills, memoryDir, toolResultsDir)
 
-	// Create or restore shared message state with session persistence
-	sharedState, sessionFilePath := newSharedSessionState(
-		isInteractiveMode, skipSessionRestore, opts.ContinueSession, workingDir, logger,
-	)
-
 	a := &Agent{
 		llmClient:          llmClient,
 		allToolManagers:    tools.all,
@@ -781,9 +906,27 @@ func NewAgentWithOptions(ctx context.Context, opts AgentOptions) (*Agent, func()
 		memoryDir:          memoryDir,
 		toolResultsDir:     toolResultsDir,
 		memoryManager:      findMemoryManager(opts.MCPToolManagers),
+		webArchive:         newWebArchive(settings),
 	}
 
+	if tools.lspTools != nil {
+		tools.lspTools.AddEditGuard(a.checkRenameTarget)
+	}
 	cleanup, err = a.wireToolsAndBackend(ctx, tools, opts.AgentBackend)
+	if tools.lsp != nil {
+		backendCleanup := cleanup
+		cleanup = func() {
+			tools.lsp.Close()
+			backendCleanup()
+		}
+	}
+	// Ending the session distils what is left of it into long-term memory, so
+	// it runs before the backend (whose client may serve the call) shuts down.
+	sessionCleanup := cleanup
+	cleanup = func() {
+		a.FlushMemory(context.Background())
+		sessionCleanup()
+	}
 	if err != nil {
 		return nil, cleanup, err
 	}
@@ -841,6 +984,22 @@ func (a *Agent) wireToolsAndBackend(
 // that get attached to the user message for vision-capable models.
 func (a *Agent) Invoke(ctx context.Context, userInput string, skillName string, images ...string) (message.Message, error) {
 	skillName = strings.ToLower(skillName)
+	// Every fetch the role's tools make this turn runs under its web policy.
+	ctx = a.webContext(ctx, skillName)
+	attrs := []attribute.KeyValue{tracing.AttrSkill.String(skillName)}
+	if a.sessionFilePath != "" {
+		// Each session's turns go to the trace file beside it, so one serve
+		// process with many sessions still keeps their traces apart.
+		ctx = telemetry.WithTraceFile(ctx, telemetry.TraceFile(a.sessionFilePath))
+		attrs = append(attrs, tracing.AttrSession.String(strings.TrimSuffix(filepath.Base(a.sessionFilePath), ".json")))
+	}
+	ctx, span := tracing.Start(ctx, tracing.SpanInvoke, attrs...)
+	resp, err := a.invoke(ctx, userInput, skillName, images...)
+	tracing.End(span, err)
+	return resp, err
+}
+
+func (a *Agent) invoke(ctx context.Context, userInput string, skillName string, images ...string) (message.Message, error) {
 	activeSkill, exists := a.lookupInvocable(skillName)
 	if !exists {
 		return nil, fmt.Errorf("skill '%s' not found", skillName)
@@ -892,9 +1051,21 @@ func (a *Agent) Invoke(ctx context.Context, userInput string, skillName string,
 	if a.sanitizeToolResults {
 		toolManager = tool.NewControlTokenSanitizer(toolManager)
 	}
+	a.turnToolsMu.Lock()
+	a.turnTools = toolManager
+	a.turnToolsMu.Unlock()
 
-	// Create LLM client with filtered tools
-	llmWithTools, err := client.NewClientWithToolManager(a.llmClient, toolManager)
+	// Create LLM client with filtered tools. A plugin command's model pin wins
+	// over the definition's for the command's turn.
+	pin := definitionPin(activeSkill)
+	if cmdPin := a.commandPin(); !cmdPin.IsZero() {
+		pin = cmdPin
+	}
+	llm, err := a.clientFor(pin)
+	if err != nil {
+		return nil, fmt.Errorf("skill '%s': %w", skillName, err)
+	}
+	llmWithTools, err := client.NewClientWithToolManager(llm, toolManager)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create LLM client with tools: %w", err)
 	}
@@ -920,6 +1091,12 @@ func (a *Agent) Invoke(ctx context.Context, userInput string, skillName string,
 	if a.settings != nil {
 		reactClient.SetBashWhitelist(a.settings.Bash.WhitelistedCommands)
 	}
+	reactClient.SetApprovalCheck(a.requiresRuleApproval)
+	reactClient.SetToolGate(a.enforceRules)
+	reactClient.SetCompactionPolicy(a.compactionPolicy(skillName))
+	if a.memoryExtractionEnabled() {
+		reactClient.SetCompactionObserver(a.onCompaction)
+	}
 
 	// Tool result budgeting: offload large tool results to disk so they don't
 	// permanently consume context window space. Only active in interactive/persistent
@@ -1034,17 +1211,27 @@ func (a *Agent) Invoke(ctx context.Context, userInput string, skillName string,
 		userPrompt = strings.Join(out, "\n")
 	}
 
+	// Prefix the message with the long-term memories relevant to it. Recall runs
+	// against what the user typed, not the todo/include expansion above.
+	if block := a.memoryContext(ctx, userInput); block != "" {
+		userPrompt = block + userPrompt
+	}
+
 	// Token-based compaction: compact the conversation history if context usage
 	// approaches the model's context window limit. Skipped for backends that handle
 	// context overflow server-side (e.g. OpenAI Responses API with auto-truncation).
 	if ssc, ok := a.llmClient.(domain.ServerSideCompactionLLM); !ok || !ssc.SupportsServerSideCompaction() {
 		if cwp, ok := a.llmClient.(domain.ContextWindowProvider); ok {
 			if maxCtx := cwp.MaxContextTokens(); maxCtx > 0 {
-				compacted, compactErr := a.sharedState.CompactIfNeeded(ctx, a.llmClient, maxCtx, 0)
+				before := slices.Clone(a.sharedState.GetMessages())
+				threshold, opts := a.compactionPolicy(skillName)
+				res, compactErr := a.sharedState.CompactIfNeeded(ctx, a.llmClient, maxCtx, threshold, opts)
 				if compactErr != nil {
 					a.logger.Warn("Context compaction failed, continuing without compaction", "error", compactErr)
 				}
-				if compacted {
+				if res.Compacted {
+					a.emitCompaction(res, false)
+					a.onCompaction(before)
 					a.postCompactRestore(ctx)
 				}
 			}
@@ -1080,6 +1267,7 @@ func (a *Agent) Invoke(ctx context.Context, userInput string, skillName string,
 			a.logger.Warn("Failed to save session state",
 				"session_file", a.sessionFilePath, "error", saveErr)
 		}
+		a.recordSessionRole(skillName)
 	}
 
 	return result, nil
@@ -1089,47 +1277,68 @@ func (a *Agent) Invoke(ctx context.Context, userInput string, skillName string,
 func (a *Agent) handleApprovalWorkflow(ctx context.Context, reactClient domain.ReAct) (message.Message, error) {
 	writer := a.OutWriter()
 
-	var toolName, arg string
+	var call permission.Call
 	if pending, ok := reactClient.GetPendingToolCall().(*message.ToolCallMessage); ok {
-		toolName = string(pending.ToolName())
-		arg = extractPermissionArg(toolName, pending.ToolArguments())
+		call = a.permissionCall(pending)
 	}
+	toolName := call.Tool
+	arg := strings.Join(call.Primary, ", ")
 
-	// 1. Session rules (in-memory, highest priority).
-	if behavior, matched := a.sessionRules.Check(toolName, arg); matched {
-		switch behavior {
+	// 1. Persistent rules from JSON files. They are consulted before the
+	// session rules so that a deny or ask rule still holds when the session
+	// pre-approves everything (non-interactive mode).
+	ask := false
+	if rule, matched := a.permRules.Match(call); matched {
+		switch rule.Behavior {
 		case permission.RuleAllow:
-			fmt.Fprintf(writer, "Proceeding (session rule matched)...\n\n")
+			fmt.Fprintf(writer, "Proceeding (allow rule matched)...\n\n")
 			return reactClient.Resume(ctx)
 		case permission.RuleDeny:
-			fmt.Fprintf(writer, "Cancelled (session deny rule matched).\n")
+			fmt.Fprintf(writer, "Cancelled (deny rule matched).\n")
 			reactClient.CancelPendingToolCall()
 			return reactClient.Resume(ctx)
+		case permission.RuleAsk:
+			ask = true
 		}
 	}
 
-	// 2. Persistent rules from JSON files.
-	if behavior, matched := a.permRules.Check(toolName, arg); matched {
-		switch behavior {
-		case permission.RuleAllow:
-			fmt.Fprintf(writer, "Proceeding (allow rule matched)...\n\n")
-			return reactClient.Resume(ctx)
-		case permission.RuleDeny:
-			fmt.Fprintf(writer, "Cancelled (deny rule matched).\n")
-			reactClient.CancelPendingToolCall()
-			return reactClient.Resume(ctx)
+	// 2. Session rules (in-memory). An ask rule skips them: it exists to put
+	// the call in front of a human.
+	if !ask {
+		if rule, matched := a.sessionRules.Match(call); matched {
+			switch rule.Behavior {
+			case permission.RuleAllow:
+				fmt.Fprintf(writer, "Proceeding (session rule matched)...\n\n")
+				return reactClient.Resume(ctx)
+			case permission.RuleDeny:
+				fmt.Fprintf(writer, "Cancelled (session deny rule matched).\n")
+				reactClient.CancelPendingToolCall()
+				return reactClient.Resume(ctx)
+			}
 		}
 	}
 
-	// 3. Non-interactive stdin (pipe / script): auto-approve.
+	// 3. A remote approver (Connect client such as the Discord gateway)
+	// decides instead of this process's terminal; silence denies.
+	if a.toolApprover != nil {
+		return a.askToolApprover(ctx, reactClient, call, describePendingToolCall(toolName, arg))
+	}
+
+	// 4. Non-interactive stdin (pipe / script): auto-approve, unless an ask
+	// rule wanted a human — with nobody to ask, the call does not run.
 	stat, err := os.Stdin.Stat()
 	if err != nil || (stat.Mode()&os.ModeCharDevice) == 0 {
 		fmt.Fprintf(writer, "\n%s\n", describePendingToolCall(toolName, arg))
+		if ask {
+			fmt.Fprintf(writer, "Cancelled (ask rule matched, but there is no terminal to ask).\n")
+			reactClient.CancelPendingToolCall()
+			return reactClient.Resume(ctx)
+		}
 		fmt.Fprintf(writer, "Proceeding (non-interactive mode)...\n\n")
 		return reactClient.Resume(ctx)
 	}
 
-	// 4. Interactive dialog.
+	// 5. Interactive dialog.
 	fmt.Fprintf(writer, "\n%s\n\n", describePendingToolCall(toolName, arg))
 
 	items := []string{"Yes", "Always (save to project)", "No"}
@@ -1157,12 +1366,16 @@ func (a *Agent) handleApprovalWorkflow(ctx context.Context, reactClient domain.R
 		fmt.Fprintf(writer, "Proceeding...\n\n")
 		return reactClient.Resume(ctx)
 	case "Always (save to project)":
-		pattern := inferPattern(toolName, arg)
-		rule := permission.PermissionRule{Tool: toolName, Pattern: pattern, Behavior: permission.RuleAllow}
+		var first string
+		if len(call.Primary) > 0 {
+			first = call.Primary[0]
+		}
+		pattern := inferPattern(toolName, first)
+		rule := permission.PermissionRule{Tool: call.QualifiedName(), Pattern: pattern, Behavior: permission.RuleAllow}
 		if saveErr := permission.AppendToProjectFile(a.workingDir, rule); saveErr != nil {
 			fmt.Fprintf(writer, "Warning: could not save rule: %v\n", saveErr)
 		} else {
-			fmt.Fprintf(writer, "Rule saved to .klein/permissions.json (%s %s).\n", toolName, pattern)
+			fmt.Fprintf(writer, "Rule saved to .klein/permissions.json (%s %s).\n", rule.Tool, pattern)
 		}
 		// Also add to in-memory permRules so subsequent calls in this session are covered.
 		a.permRules.Rules = append([]permission.PermissionRule{rule}, a.permRules.Rules...)
@@ -1184,6 +1397,7 @@ const (
 	toolWrite     = "Write"
 	toolEdit      = "Edit"
 	toolMultiEdit = "MultiEdit"
+	toolRename    = "Rename"
 	toolBash      = "Bash"
 )
 
@@ -1199,6 +1413,8 @@ func describePendingToolCall(toolName, arg string) string {
 		action = "About to write file:"
 	case toolEdit, toolMultiEdit:
 		action = "About to edit file:"
+	case toolRename:
+		action = "About to rename a symbol across the project, starting from:"
 	case toolBash:
 		action = "About to run command:"
 	case "":
@@ -1214,32 +1430,80 @@ func describePendingToolCall(toolName, arg string) string {
 	return fmt.Sprintf("%s\n   ↳ %s", action, truncateForDisplay(arg, 200))
 }
 
-// extractPermissionArg returns the primary argument used for rule pattern matching.
-// For file tools this is the path; for bash this is the command string.
-// MultiEdit carries multiple paths — we return the first one; the caller may
-// want to call Check per-path, but for the initial implementation one suffices.
-func extractPermissionArg(toolName string, args message.ToolArgumentValues) string {
-	switch toolName {
-	case toolWrite, toolEdit:
-		// Filesystem tools register the parameter as "file_path".
-		if path, ok := args["file_path"].(string); ok {
-			return path
-		}
-	case toolMultiEdit:
-		// edits is []interface{} where each element has "file_path"
-		if edits, ok := args["edits"].([]interface{}); ok && len(edits) > 0 {
-			if edit, ok := edits[0].(map[string]interface{}); ok {
-				if fp, ok := edit["file_path"].(string); ok {
-					return fp
-				}
+// permissionCall describes a tool call for permission rule matching. MCP tools
+// carry their server, so rules can name them as "mcp:<server>/<tool>".
+func (a *Agent) permissionCall(tc *message.ToolCallMessage) permission.Call {
+	name := string(tc.ToolName())
+	var server string
+	if a.allToolManagers != nil {
+		if t, ok := a.allToolManagers.GetTool(tc.ToolName()); ok {
+			if st, ok := t.(interface{ ServerName() string }); ok {
+				server = st.ServerName()
 			}
 		}
-	case toolBash:
-		if cmd, ok := args["command"].(string); ok {
-			return cmd
+	}
+	return permission.NewCall(name, server, tc.ToolArguments())
+}
+
+// requiresRuleApproval reports whether a persistent rule wants a say in a call
+// the approval gate would otherwise wave through — a deny or ask rule on an MCP
+// tool or a whitelisted command. Allow rules need no gate: the call runs anyway.
+func (a *Agent) requiresRuleApproval(tc *message.ToolCallMessage) bool {
+	rule, ok := a.permRules.Match(a.permissionCall(tc))
+	return ok && rule.Behavior != permission.RuleAllow
+}
+
+// enforceRules applies the persistent rules to a call about to run. The
+// approval workflow has already handled a lone call that paused for it; this
+// covers the calls of a batch and agents that skip approval, where nobody is
+// asked: a deny rule refuses the call, and so does an ask rule.
+func (a *Agent) enforceRules(tc *message.ToolCallMessage) error {
+	rule, ok := a.permRules.Match(a.permissionCall(tc))
+	if !ok {
+		return nil
+	}
+	switch rule.Behavior {
+	case permission.RuleDeny:
+		return fmt.Errorf("%s refused: denied by permission rule (%s)", tc.ToolName(), ruleSummary(rule))
+	case permission.RuleAsk:
+		return fmt.Errorf("%s refused: permission rule (%s) requires approval, which a batched or background call cannot get; call the tool on its own in the foreground", tc.ToolName(), ruleSummary(rule))
+	}
+	return nil
+}
+
+// checkRenameTarget applies the persistent rules to a file a Rename would
+// rewrite, as if the file were edited directly. The approval workflow only saw
+// the file the rename started from, so an ask rule on any other file refuses
+// the rename; a deny rule refuses it for every file.
+func (a *Agent) checkRenameTarget(origin, target string) error {
+	path := target
+	if rel, err := filepath.Rel(a.workingDir, target); err == nil && !strings.HasPrefix(rel, "..") {
+		path = filepath.ToSlash(rel)
+	}
+	for _, name := range []string{toolRename, toolEdit, toolWrite} {
+		rule, ok := a.permRules.Match(permission.NewCall(name, "", map[string]any{"file_path": path}))
+		if !ok {
+			continue
+		}
+		switch {
+		case rule.Behavior == permission.RuleDeny:
+			return fmt.Errorf("denied by permission rule (%s)", ruleSummary(rule))
+		case rule.Behavior == permission.RuleAsk && filepath.Clean(target) != filepath.Clean(origin):
+			return fmt.Errorf("permission rule (%s) requires approval to edit it", ruleSummary(rule))
 		}
 	}
-	return ""
+	return nil
+}
+
+func ruleSummary(r permission.PermissionRule) string {
+	s := r.Tool
+	if r.Pattern != "" {
+		s += " " + r.Pattern
+	}
+	if r.Source != "" {
+		s += " from " + r.Source
+	}
+	return s
 }
 
 // newSessionRules returns the initial session rule set.
@@ -1249,7 +1513,7 @@ func newSessionRules(isInteractive bool) *permission.RuleSet {
 	if isInteractive {
 		return &permission.RuleSet{}
 	}
-	tools := []string{toolWrite, toolEdit, toolMultiEdit, toolBash}
+	tools := []string{toolWrite, toolEdit, toolMultiEdit, toolRename, toolBash}
 	rules := make([]permission.PermissionRule, len(tools))
 	for i, t := range tools {
 		rules[i] = permission.PermissionRule{Tool: t, Pattern: "", Behavior: permission.RuleAllow}
@@ -1307,8 +1571,41 @@ func (a *Agent) EnablePersistence(filePath string) error {
 	return nil
 }
 
+// SessionFile returns the file backing this session, or "" when the session is
+// in-memory only.
+func (a *Agent) SessionFile() string {
+	return a.sessionFilePath
+}
+
+// SwitchSession replaces the conversation with the session stored at path, as
+// `/resume` does. Every turn already saves its session, so the one being left
+// needs no final write. Must not be called during a turn.
+func (a *Agent) SwitchSession(path string) error {
+	newState, err := loadSessionFile(path)
+	if err != nil {
+		return err
+	}
+	a.extractMemoriesAsync(slices.Clone(a.sharedState.GetMessages()), "session switch")
+	a.sharedState = newState
+	a.sessionFilePath = path
+	// The codex thread belongs to the session; the next turn reloads the new
+	// session's sidecar (or starts a thread if it has none).
+	a.codexThreadID = ""
+	return nil
+}
+
+// recordSessionRole notes in the session index which role ran the latest
+// turn. The index is a convenience for `klein sessions`, so a failure is only
+// logged.
+func (a *Agent) recordSessionRole(role string) {
+	if err := session.RecordRole(a.sessionFilePath, role); err != nil {
+		a.logger.Debug("Failed to update session index", "error", err)
+	}
+}
+
 // ClearHistory clears the conversation history.
 func (a *Agent) ClearHistory() {
+	a.extractMemoriesAsync(slices.Clone(a.sharedState.GetMessages()), "clear")
 	a.sharedState.Clear()
 }
 
@@ -1339,7 +1636,14 @@ func (a *Agent) InvokeWithOptions(ctx context.Context, prompt string) (message.M
 	if a.settings != nil {
 		reactClient.SetBashWhitelist(a.settings.Bash.WhitelistedCommands)
 	}
+	reactClient.SetApprovalCheck(a.requiresRuleApproval)
+	reactClient.SetToolGate(a.enforceRules)
+	reactClient.SetCompactionPolicy(a.compactionPolicy(""))
+	if a.memoryExtractionEnabled() {
+		reactClient.SetCompactionObserver(a.onCompaction)
+	}
 
+	ctx = a.webContext(ctx, "")
 	result, err := reactClient.Run(ctx, prompt)
 
 	var approvalErrors []error
@@ -1380,6 +1684,10 @@ func (a *Agent) GetConversationPreview(maxMessages int) string {
 
 	isFirstMessage := true
 	for _, msg := range recentMessages {
+		if msg.Type() == message.MessageTypeUser && strings.Contains(msg.Content(), memorydb.ContextStart) {
+			// Show the prompt as the user typed it, not the recalled memories.
+			msg = message.NewChatMessage(message.MessageTypeUser, memorydb.StripContext(msg.Content()))
+		}
 		truncated := msg.TruncatedString()
 		if truncated == "" {
 			continue
@@ -1509,6 +1817,7 @@ func (a *Agent) invokeCodex(
 		if err := a.sharedState.SaveToFile(); err != nil {
 			a.logger.Warn("Failed to persist session after codex turn", "error", err)
 		}
+		a.recordSessionRole(activeSkill.Name)
 	}
 
 	// EventTypeResponse resets any in-progress thinking style and forwards the
@@ -1553,55 +1862,73 @@ func (a *Agent) saveCodexThreadID(id string) {
 }
 
 func (a *Agent) setupEventHandlers(emitter events.EventEmitter) {
-	emitter.AddHandler(func(event events.AgentEvent) {
-		writer := a.OutWriter()
-		if writer == nil {
-			return
-		}
+	emitter.AddHandler(a.handleEvent)
+}
 
-		switch event.Type {
-		case events.EventTypeToolCallStart:
-			if data, ok := event.Data.(events.ToolCallStartData); ok {
-				fmt.Fprintf(writer, "%sRunning tool%s %s%s%s %v\n",
-					ansiDim, ansiReset, ansiCyan, data.ToolName, ansiReset, data.Arguments)
-				if data.ToolName == "Read" {
-					if path, ok := data.Arguments["file_path"].(string); ok && path != "" {
-						a.recordRecentlyRead(path)
-					}
+// handleEvent renders an agent event to the output writer and forwards it to
+// the external handler. Events the agent raises itself, outside a ReAct run,
+// come here directly.
+func (a *Agent) handleEvent(event events.AgentEvent) {
+	writer := a.OutWriter()
+	if writer == nil {
+		return
+	}
+
+	switch event.Type {
+	case events.EventTypeToolCallStart:
+		a.tail.clear(writer)
+		if data, ok := event.Data.(events.ToolCallStartData); ok {
+			fmt.Fprintf(writer, "%sRunning tool%s %s%s%s %v\n",
+				ansiDim, ansiReset, ansiCyan, data.ToolName, ansiReset, data.Arguments)
+			if data.ToolName == "Read" {
+				if path, ok := data.Arguments["file_path"].(string); ok && path != "" {
+					a.recordRecentlyRead(path)
 				}
 			}
+		}
 
-		case events.EventTypeToolResult:
-			if data, ok := event.Data.(events.ToolResultData); ok {
-				writeToolResult(writer, data)
-			}
+	case events.EventTypeToolOutputDelta:
+		if data, ok := event.Data.(events.ToolOutputDeltaData); ok {
+			a.tail.add(writer, data.Text)
+		}
 
-		case events.EventTypeThinkingChunk:
-			if data, ok := event.Data.(events.ThinkingChunkData); ok {
-				if !a.thinkingStarted {
-					fmt.Fprint(writer, "\x1b[90m💭 ")
-					a.thinkingStarted = true
-				}
-				fmt.Fprintf(writer, "\x1b[90m%s", data.Content)
-			}
+	case events.EventTypeToolResult:
+		a.tail.clear(writer)
+		if data, ok := event.Data.(events.ToolResultData); ok {
+			writeToolResult(writer, data)
+		}
 
-		case events.EventTypeResponse:
-			if a.thinkingStarted {
-				fmt.Fprint(writer, "\x1b[0m\n")
-				a.thinkingStarted = false
+	case events.EventTypeThinkingChunk:
+		if data, ok := event.Data.(events.ThinkingChunkData); ok {
+			if !a.thinkingStarted {
+				fmt.Fprint(writer, "\x1b[90m💭 ")
+				a.thinkingStarted = true
 			}
+			fmt.Fprintf(writer, "\x1b[90m%s", data.Content)
+		}
 
-		case events.EventTypeError:
-			if data, ok := event.Data.(events.ErrorData); ok {
-				fmt.Fprintf(writer, "Error: %v\n", data.Error)
-			}
+	case events.EventTypeResponse:
+		if a.thinkingStarted {
+			fmt.Fprint(writer, "\x1b[0m\n")
+			a.thinkingStarted = false
 		}
 
-		// Forward to external handler if set (e.g., Connect server)
-		if a.externalEventHandler != nil {
-			a.externalEventHandler(event)
+	case events.EventTypeError:
+		if data, ok := event.Data.(events.ErrorData); ok {
+			fmt.Fprintf(writer, "Error: %v\n", data.Error)
 		}
-	})
+
+	case events.EventTypeCompaction:
+		if data, ok := event.Data.(events.CompactionData); ok {
+			fmt.Fprintf(writer, "%s🗜  Compacted context (%s): %s → %s tokens%s\n",
+				ansiDim, data.Strategy, formatTokens(data.BeforeTokens), formatTokens(data.AfterTokens), ansiReset)
+		}
+	}
+
+	// Forward to external handler if set (e.g., Connect server)
+	if a.externalEventHandler != nil {
+		a.externalEventHandler(event)
+	}
 }
 
 // recordRecentlyRead records a file path as recently read, keeping only the 5
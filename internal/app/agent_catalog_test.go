@@ -235,7 +235,7 @@ func TestBuiltInAgentsReachTheTaskToolDescription(t *testing.T) {
 		}
 	}
 	// explore's read-only tool set must survive into the listing.
-	if !strings.Contains(desc, "(Tools: Read, LS, Glob, Grep, ToolSearch)") {
+	if !strings.Contains(desc, "(Tools: Read, LS, Glob, Grep, CodeSearch, ToolSearch)") {
 		t.Errorf("explore's tool restriction not shown in the listing:\n%s", desc)
 	}
 }
@@ -242,12 +242,13 @@ func (a *agentRun) getStatus() RunStatus {
 // StartBackgroundAgent launches def as a detached subagent and returns its run
 // id immediately.
 //
-// The run does NOT inherit the caller's context. That context belongs to the
-// turn that started it and is canceled the moment the turn ends (or the user
-// hits Ctrl+C), which would kill the background agent instantly — the opposite
-// of backgrounding. It gets its own cancellable root instead, held in the
-// registry so shutdown and AgentStop can reach it.
-func (a *Agent) StartBackgroundAgent(def *skill.Definition, task string) (RunInfo, error) {
+// The run does NOT inherit the caller's cancellation. The caller's context
+// belongs to the turn that started it and is canceled the moment the turn ends
+// (or the user hits Ctrl+C), which would kill the background agent instantly —
+// the opposite of backgrounding. It gets its own cancellable root instead, held
+// in the registry so shutdown and AgentStop can reach it. Only parent's values
+// carry over, so the run's spans still nest under the turn that launched it.
+func (a *Agent) StartBackgroundAgent(parent context.Context, def *skill.Definition, task string) (RunInfo, error) {
 	if def == nil {
 		return RunInfo{}, errors.New("background agent: nil definition")
 	}
@@ -278,7 +279,7 @@ func (a *Agent) StartBackgroundAgent(def *skill.Definition, task string) (RunInf
 	transcript := &syncBuffer{}
 	outputPath := a.openRunTranscript(id, transcript)
 
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(context.WithoutCancel(parent))
 	run := &agentRun{
 		id: id, label: label, task: task,
 		started: time.Now(), status: RunRunning,
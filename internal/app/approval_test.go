@@ -1,11 +1,13 @@
 package app
 
 import (
+	"path/filepath"
 	"strings"
 	"testing"
 	"unicode/utf8"
 
 	"github.com/fpt/klein-cli/internal/permission"
+	"github.com/fpt/klein-cli/pkg/message"
 )
 
 // ---- inferPattern ----
@@ -189,3 +191,62 @@ func TestSessionRules_ToolScoped(t *testing.T) {
 		t.Error("bash should still require approval when only Write was blanket-approved")
 	}
 }
+
+// enforceRules refuses deny and ask matches at execution time; the react
+// gate applies it to every call of a batch.
+func TestEnforceRules(t *testing.T) {
+	a := &Agent{permRules: &permission.RuleSet{Rules: []permission.PermissionRule{
+		{Tool: "Write", Args: []permission.ArgMatcher{{Name: "file_path", Glob: ".env*"}}, Behavior: permission.RuleDeny},
+		{Tool: "Bash", Pattern: "git push *", Behavior: permission.RuleAsk},
+		{Tool: "Bash", Pattern: "go *", Behavior: permission.RuleAllow},
+	}}}
+	cases := []struct {
+		call   *message.ToolCallMessage
+		refuse string
+	}{
+		{message.NewToolCallMessage("Write", message.ToolArgumentValues{"file_path": ".env.local", "content": "x"}), "denied"},
+		{message.NewToolCallMessage("Bash", message.ToolArgumentValues{"command": "git push origin main"}), "requires approval"},
+		{message.NewToolCallMessage("Bash", message.ToolArgumentValues{"command": "go test ./..."}), ""},
+		{message.NewToolCallMessage("Write", message.ToolArgumentValues{"file_path": "main.go", "content": "x"}), ""},
+	}
+	for _, c := range cases {
+		err := a.enforceRules(c.call)
+		if c.refuse == "" && err != nil {
+			t.Errorf("%s %v refused: %v", c.call.ToolName(), c.call.ToolArguments(), err)
+		}
+		if c.refuse != "" && (err == nil || !strings.Contains(err.Error(), c.refuse)) {
+			t.Errorf("%s %v: err = %v, want %q", c.call.ToolName(), c.call.ToolArguments(), err, c.refuse)
+		}
+	}
+}
+
+func TestCheckRenameTarget(t *testing.T) {
+	root := t.TempDir()
+	a := &Agent{workingDir: root, permRules: &permission.RuleSet{Rules: []permission.PermissionRule{
+		{Tool: "Edit", Pattern: "vendor/**", Behavior: permission.RuleDeny},
+		{Tool: "Write", Pattern: "api/*.go", Behavior: permission.RuleAsk},
+	}}}
+	origin := filepath.Join(root, "main.go")
+	cases := []struct {
+		target string
+		refuse string
+	}{
+		{origin, ""},
+		{filepath.Join(root, "util.go"), ""},
+		{filepath.Join(root, "vendor", "x", "x.go"), "denied"},
+		{filepath.Join(root, "api", "handler.go"), "requires approval"},
+	}
+	for _, c := range cases {
+		err := a.checkRenameTarget(origin, c.target)
+		if c.refuse == "" && err != nil {
+			t.Errorf("%s refused: %v", c.target, err)
+		}
+		if c.refuse != "" && (err == nil || !strings.Contains(err.Error(), c.refuse)) {
+			t.Errorf("%s: err = %v, want %q", c.target, err, c.refuse)
+		}
+	}
+	// An ask rule on the file the approved call named does not refuse it.
+	if err := a.checkRenameTarget(filepath.Join(root, "api", "handler.go"), filepath.Join(root, "api", "handler.go")); err != nil {
+		t.Errorf("origin refused: %v", err)
+	}
+}
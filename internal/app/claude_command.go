@@ -0,0 +1,157 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fpt/klein-cli/internal/claude"
+	"github.com/fpt/klein-cli/internal/session"
+	"github.com/manifoldco/promptui"
+)
+
+// cmdClaude is the /claude command name (REPL palette + dispatch).
+const cmdClaude = "claude"
+
+// claudePickerSize caps how many Claude Code sessions a picker shows at once.
+const claudePickerSize = 10
+
+// handleClaudeCommand implements /claude: move a conversation between klein
+// and Claude Code in either direction.
+//
+//	/claude list           Claude Code sessions of this project
+//	/claude import [id]    append one to this session (picker without an id)
+//	/claude export         write this session out for `claude --resume`
+func handleClaudeCommand(a *Agent, args string) {
+	sub, rest, _ := strings.Cut(strings.TrimSpace(args), " ")
+	rest = strings.TrimSpace(rest)
+	switch sub {
+	case "", "list":
+		listClaudeSessions(a)
+	case "import":
+		importClaudeSession(a, rest)
+	case "export":
+		exportClaudeSession(a)
+	default:
+		fmt.Printf("❌ Unknown /claude subcommand %q (list, import [id], export)\n", sub)
+	}
+}
+
+func listClaudeSessions(a *Agent) {
+	sessions, err := claude.ListSessions(a.WorkingDir())
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return
+	}
+	if len(sessions) == 0 {
+		fmt.Println("📜 No Claude Code sessions for this project.")
+		return
+	}
+	now := time.Now()
+	for _, s := range sessions {
+		fmt.Println("  " + claudeSessionLine(now, s))
+	}
+	fmt.Println("💡 Use /claude import <id> to bring one into this session.")
+}
+
+func importClaudeSession(a *Agent, ref string) {
+	var target claude.Session
+	if ref != "" {
+		s, err := claude.ResolveSession(a.WorkingDir(), ref)
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			return
+		}
+		target = s
+	} else {
+		sessions, err := claude.ListSessions(a.WorkingDir())
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			return
+		}
+		if len(sessions) == 0 {
+			fmt.Println("📜 No Claude Code sessions for this project.")
+			return
+		}
+		if !stdinIsInteractive() {
+			target = sessions[0]
+		} else if s, ok := pickClaudeSession("Import which Claude Code session?", sessions, false); ok {
+			target = s
+		} else {
+			return
+		}
+	}
+
+	count, err := a.ImportClaudeHistory(target.Path)
+	if err != nil {
+		fmt.Printf("❌ Failed to import Claude Code session: %v\n", err)
+		return
+	}
+	fmt.Printf("✅ Imported %d messages from Claude Code session %s.\n", count, target.ID)
+}
+
+func exportClaudeSession(a *Agent) {
+	path := a.SessionFile()
+	if path == "" {
+		fmt.Println("📜 This session is not saved to disk, so there is nothing to export.")
+		return
+	}
+	if err := a.GetMessageState().SaveToFile(); err != nil {
+		fmt.Printf("❌ Failed to save session: %v\n", err)
+		return
+	}
+	st, err := session.Load(path)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return
+	}
+	s, err := claude.ExportToProject(a.WorkingDir(), st.Messages, "")
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return
+	}
+	fmt.Printf("✅ Exported to Claude Code session %s\n", s.ID)
+	fmt.Printf("💡 Continue it with: claude --resume %s\n", s.ID)
+}
+
+// claudeSessionLine renders one Claude Code session for lists and pickers.
+func claudeSessionLine(now time.Time, s claude.Session) string {
+	title := s.Title
+	if title == "" {
+		title = "(untitled)"
+	}
+	return fmt.Sprintf("%.8s  %8s  %s", s.ID, session.Age(now, s.Modified), title)
+}
+
+// pickClaudeSession lets the user choose among sessions, newest first. With
+// allowNone the list ends in a "Don't import" entry; choosing it, or
+// cancelling, returns false.
+func pickClaudeSession(label string, sessions []claude.Session, allowNone bool) (claude.Session, bool) {
+	now := time.Now()
+	lines := make([]string, 0, len(sessions)+1)
+	for _, s := range sessions {
+		lines = append(lines, claudeSessionLine(now, s))
+	}
+	if allowNone {
+		lines = append(lines, "Don't import")
+	}
+	prompt := promptui.Select{
+		Label: label,
+		Items: lines,
+		Size:  min(len(lines), claudePickerSize),
+		Searcher: func(input string, index int) bool {
+			return strings.Contains(strings.ToLower(lines[index]), strings.ToLower(input))
+		},
+	}
+	i, _, err := prompt.Run()
+	if err != nil {
+		if err != promptui.ErrInterrupt {
+			fmt.Printf("Session selection failed: %v\n", err)
+		}
+		return claude.Session{}, false
+	}
+	if i >= len(sessions) {
+		return claude.Session{}, false
+	}
+	return sessions[i], true
+}
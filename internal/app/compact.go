@@ -0,0 +1,107 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fpt/klein-cli/pkg/agent/domain"
+	"github.com/fpt/klein-cli/pkg/agent/events"
+)
+
+// cmdCompact compacts the conversation on demand. It calls the model, so it is
+// dispatched by handleDrivingCommand with the execution context.
+const cmdCompact = "compact"
+
+// compactionPolicy returns the threshold and strategy automatic compaction
+// uses on role's turns, as [compaction] configures them.
+func (a *Agent) compactionPolicy(role string) (float64, domain.CompactionOptions) {
+	if a.settings == nil {
+		return 0, domain.CompactionOptions{}
+	}
+	p := a.settings.Compaction.ForRole(role)
+	return p.ThresholdPercent, domain.CompactionOptions{Strategy: domain.CompactionStrategy(p.Strategy)}
+}
+
+// emitCompaction reports a compaction the agent ran itself rather than inside
+// a ReAct run, through the same path as the run's events.
+func (a *Agent) emitCompaction(res domain.CompactionResult, manual bool) {
+	a.handleEvent(events.AgentEvent{
+		Type:      events.EventTypeCompaction,
+		Timestamp: time.Now(),
+		Data: events.CompactionData{
+			Strategy:     string(res.Strategy),
+			BeforeTokens: res.BeforeTokens,
+			AfterTokens:  res.AfterTokens,
+			Manual:       manual,
+		},
+	})
+}
+
+// Compact compacts the conversation now; call it between turns. An empty
+// strategy selects the one configured for role. As with automatic compaction,
+// the turns summarised away are first distilled into long-term memory, and the
+// session is saved so the smaller history is what a resume loads.
+func (a *Agent) Compact(ctx context.Context, role string, opts domain.CompactionOptions) (domain.CompactionResult, error) {
+	if a.codexBackend != nil {
+		return domain.CompactionResult{}, errors.New("the app-server backend manages its own context")
+	}
+	if opts.Strategy == "" {
+		_, configured := a.compactionPolicy(role)
+		opts.Strategy = configured.Strategy
+	}
+	before := slices.Clone(a.sharedState.GetMessages())
+	res, err := a.sharedState.Compact(ctx, a.llmClient, opts)
+	if err != nil || !res.Compacted {
+		return res, err
+	}
+	a.emitCompaction(res, true)
+	a.onCompaction(before)
+	if a.sessionFilePath != "" {
+		if saveErr := a.sharedState.SaveToFile(); saveErr != nil {
+			a.logger.Warn("Failed to save session state",
+				"session_file", a.sessionFilePath, "error", saveErr)
+		}
+	}
+	return res, nil
+}
+
+// parseCompactArgs reads "/compact [--summary|--micro|--structured] [focus]".
+func parseCompactArgs(args string) (domain.CompactionOptions, error) {
+	var opts domain.CompactionOptions
+	first, rest, _ := strings.Cut(strings.TrimSpace(args), " ")
+	if name, ok := strings.CutPrefix(first, "--"); ok {
+		opts.Strategy = domain.CompactionStrategy(name)
+		if !slices.Contains(domain.CompactionStrategies, opts.Strategy) {
+			return opts, fmt.Errorf("unknown strategy --%s (use --summary, --micro or --structured)", name)
+		}
+		args = rest
+	}
+	opts.Focus = strings.TrimSpace(args)
+	return opts, nil
+}
+
+// handleCompactCommand runs /compact for the REPL; Ctrl+C abandons it and
+// leaves the conversation as it was.
+func handleCompactCommand(ctx context.Context, a *Agent, skillName, args string) {
+	w := a.OutWriter()
+	opts, err := parseCompactArgs(args)
+	if err != nil {
+		fmt.Fprintf(w, "❌ %v\n", err)
+		return
+	}
+	ctx, stop := interruptible(ctx)
+	defer stop()
+	res, err := a.Compact(ctx, skillName, opts)
+	switch {
+	case ctx.Err() == context.Canceled:
+		fmt.Fprintln(w, "🔄 Compaction cancelled.")
+	case err != nil:
+		fmt.Fprintf(w, "❌ Compaction failed: %v\n", err)
+	case !res.Compacted:
+		fmt.Fprintln(w, "Nothing to compact.")
+	}
+}
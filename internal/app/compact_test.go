@@ -0,0 +1,69 @@
+package app
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/fpt/klein-cli/internal/config"
+	"github.com/fpt/klein-cli/pkg/agent/domain"
+	"github.com/fpt/klein-cli/pkg/agent/events"
+	"github.com/fpt/klein-cli/pkg/agent/state"
+	"github.com/fpt/klein-cli/pkg/message"
+)
+
+func TestParseCompactArgs(t *testing.T) {
+	for args, want := range map[string]domain.CompactionOptions{
+		"":                          {},
+		"keep the API design":       {Focus: "keep the API design"},
+		"--micro":                   {Strategy: domain.CompactionMicro},
+		"--structured  the parser ": {Strategy: domain.CompactionStructured, Focus: "the parser"},
+	} {
+		got, err := parseCompactArgs(args)
+		if err != nil || got != want {
+			t.Errorf("parseCompactArgs(%q) = %+v, %v; want %+v", args, got, err, want)
+		}
+	}
+	if _, err := parseCompactArgs("--everything"); err == nil {
+		t.Error("an unknown strategy flag was accepted")
+	}
+}
+
+func TestAgentCompact(t *testing.T) {
+	st := state.NewMessageState()
+	for i := range 3 {
+		st.AddMessage(message.NewChatMessage(message.MessageTypeUser, "question"))
+		st.AddMessage(message.NewToolCallMessage("Read", message.ToolArgumentValues{"file_path": "main.go"}))
+		st.AddMessage(message.NewToolResultMessage("", strings.Repeat("package main\n", 40+i), ""))
+		st.AddMessage(message.NewChatMessage(message.MessageTypeAssistant, "answer"))
+	}
+	settings := &config.Settings{Compaction: config.CompactionSettings{
+		Roles: map[string]config.CompactionPolicy{"claw": {Strategy: "micro"}},
+	}}
+	a := &Agent{llmClient: &stubLLM{}, sharedState: st, settings: settings, out: io.Discard}
+	var got []events.CompactionData
+	a.SetEventHandler(func(ev events.AgentEvent) {
+		if data, ok := ev.Data.(events.CompactionData); ok {
+			got = append(got, data)
+		}
+	})
+
+	// The claw role compacts with micro, which calls no model (stubLLM fails
+	// any call).
+	res, err := a.Compact(context.Background(), "claw", domain.CompactionOptions{})
+	if err != nil || !res.Compacted || res.Strategy != domain.CompactionMicro {
+		t.Fatalf("Compact = %+v, %v", res, err)
+	}
+	if len(got) != 1 || !got[0].Manual || got[0].AfterTokens >= got[0].BeforeTokens || got[0].Strategy != "micro" {
+		t.Errorf("events = %+v", got)
+	}
+
+	// Nothing is left to clear the second time.
+	if res, err := a.Compact(context.Background(), "claw", domain.CompactionOptions{}); err != nil || res.Compacted {
+		t.Errorf("second Compact = %+v, %v", res, err)
+	}
+	if len(got) != 1 {
+		t.Errorf("a no-op compaction emitted an event: %+v", got)
+	}
+}
@@ -0,0 +1,135 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/fpt/klein-cli/pkg/agent/domain"
+	"github.com/fpt/klein-cli/pkg/agent/state"
+	"github.com/fpt/klein-cli/pkg/message"
+	"github.com/fpt/klein-cli/pkg/tokenizer"
+)
+
+// ContextCategory is one slice of the context window as /context shows it.
+type ContextCategory struct {
+	Name   string
+	Tokens int
+}
+
+// The categories, in display order.
+const (
+	contextSystem      = "System prompt"
+	contextTools       = "Tools"
+	contextSkills      = "Skills"
+	contextMemory      = "Memory"
+	contextMessages    = "Messages"
+	contextToolResults = "Tool results"
+)
+
+// ContextBreakdown splits the context the next turn would send into
+// categories, counted in the model's tokenizer, and returns them with the
+// model's window (0 when unknown). Tools are the ones the latest turn
+// offered; before the first turn, none.
+func (a *Agent) ContextBreakdown() ([]ContextCategory, int) {
+	counter := state.TokenCounterFor(a.llmClient)
+	totals := map[string]int{}
+	for _, msg := range a.sharedState.GetMessages() {
+		totals[contextCategoryOf(msg)] += state.MessageTokens(counter, msg)
+	}
+	a.turnToolsMu.Lock()
+	tools := a.turnTools
+	a.turnToolsMu.Unlock()
+	if tools != nil {
+		totals[contextTools] = toolSchemaTokens(counter, tools.GetTools())
+	}
+
+	var cats []ContextCategory
+	for _, name := range []string{contextSystem, contextTools, contextSkills, contextMemory, contextMessages, contextToolResults} {
+		cats = append(cats, ContextCategory{Name: name, Tokens: totals[name]})
+	}
+	maxTokens := 0
+	if cwp, ok := a.llmClient.(domain.ContextWindowProvider); ok {
+		maxTokens = cwp.MaxContextTokens()
+	}
+	return cats, maxTokens
+}
+
+// contextCategoryOf files a message under a category, telling the system
+// messages Invoke injects apart by their markers.
+func contextCategoryOf(msg message.Message) string {
+	switch msg.Type() {
+	case message.MessageTypeToolResult:
+		return contextToolResults
+	case message.MessageTypeSystem:
+		content := msg.Content()
+		switch {
+		case strings.HasPrefix(content, "[[SKILL_CATALOG]]"):
+			return contextSkills
+		case strings.HasPrefix(content, "[[MEMORY_SYSTEM]]"):
+			return contextMemory
+		case msg.Source() == message.MessageSourceCompactBoundary:
+			return contextMessages // the summary of earlier turns
+		}
+		return contextSystem
+	}
+	return contextMessages
+}
+
+// toolSchemaTokens counts tool definitions as the backends send them: name,
+// description and argument schema.
+func toolSchemaTokens(counter domain.TokenCounter, tools map[message.ToolName]message.Tool) int {
+	total := 0
+	for name, t := range tools {
+		schema, _ := json.Marshal(t.Arguments())
+		total += counter.CountTokens(string(name)) + counter.CountTokens(string(t.Description())) + counter.CountTokens(string(schema))
+	}
+	return total
+}
+
+// showContextBreakdown prints /context: each category's share of the window
+// as a bar, largest first, then the total.
+func showContextBreakdown(w io.Writer, a *Agent) {
+	cats, maxTokens := a.ContextBreakdown()
+	total := 0
+	for _, c := range cats {
+		total += c.Tokens
+	}
+	sort.SliceStable(cats, func(i, j int) bool { return cats[i].Tokens > cats[j].Tokens })
+
+	const barWidth = 30
+	scale := maxTokens
+	if scale <= 0 {
+		scale = max(total, 1)
+	}
+	fmt.Fprintln(w, "\n🧮 Context usage:")
+	for _, c := range cats {
+		filled := min(barWidth, (c.Tokens*barWidth+scale-1)/scale)
+		fmt.Fprintf(w, "  %-13s %s%s %7s  %5.1f%%\n", c.Name,
+			strings.Repeat("█", filled), strings.Repeat("░", barWidth-filled),
+			formatTokens(c.Tokens), float64(c.Tokens)*100/float64(scale))
+	}
+	if maxTokens > 0 {
+		fmt.Fprintf(w, "  %-13s %s / %s (%.1f%%), %s free\n", "Total", formatTokens(total), formatTokens(maxTokens),
+			float64(total)*100/float64(maxTokens), formatTokens(max(maxTokens-total, 0)))
+	} else {
+		fmt.Fprintf(w, "  %-13s %s (context window unknown)\n", "Total", formatTokens(total))
+	}
+	counter := tokenizer.ForModel(a.llmClient.ModelID())
+	if !counter.Exact() {
+		fmt.Fprintf(w, "  Counts are estimates (%s-based) for %s.\n", counter.Name(), a.llmClient.ModelID())
+	}
+}
+
+// formatTokens renders a count as "850", "12.3k" or "1.2M".
+func formatTokens(n int) string {
+	switch {
+	case n >= 1_000_000:
+		return fmt.Sprintf("%.1fM", float64(n)/1_000_000)
+	case n >= 1_000:
+		return fmt.Sprintf("%.1fk", float64(n)/1_000)
+	}
+	return fmt.Sprint(n)
+}
@@ -0,0 +1,58 @@
+package app
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fpt/klein-cli/pkg/agent/state"
+	"github.com/fpt/klein-cli/pkg/message"
+)
+
+func TestContextBreakdown(t *testing.T) {
+	st := state.NewMessageState()
+	for _, m := range []message.Message{
+		message.NewSystemMessage("[[SKILL_PROMPT:code]]\nYou are a coding agent."),
+		message.NewSystemMessage("[[SKILL_CATALOG]]\n- review: reviews diffs"),
+		message.NewSystemMessage("[[MEMORY_SYSTEM]]\n" + strings.Repeat("remember this ", 50)),
+		message.NewChatMessage(message.MessageTypeUser, "read main.go"),
+		message.NewToolCallMessage("Read", message.ToolArgumentValues{"path": "main.go"}),
+		message.NewToolResultMessage("call-1", strings.Repeat("package main\n", 100), ""),
+		message.NewCompactBoundaryMessage("earlier we fixed the build"),
+	} {
+		st.AddMessage(m)
+	}
+	a := &Agent{llmClient: &stubLLM{}, sharedState: st}
+
+	cats, maxTokens := a.ContextBreakdown()
+	if maxTokens != 0 {
+		t.Errorf("window = %d for a client that reports none", maxTokens)
+	}
+	got := map[string]int{}
+	total := 0
+	for _, c := range cats {
+		got[c.Name] = c.Tokens
+		total += c.Tokens
+	}
+	if len(cats) != 6 || got[contextTools] != 0 {
+		t.Errorf("categories = %+v", cats)
+	}
+	for _, name := range []string{contextSystem, contextSkills, contextMemory, contextMessages, contextToolResults} {
+		if got[name] == 0 {
+			t.Errorf("%s counted nothing: %+v", name, cats)
+		}
+	}
+	if got[contextMemory] < 100 || got[contextToolResults] < got[contextSystem] {
+		t.Errorf("implausible counts: %+v", cats)
+	}
+	if want := state.CountMessageTokens(state.TokenCounterFor(a.llmClient), st.GetMessages()); total != want {
+		t.Errorf("categories sum to %d, the messages count %d", total, want)
+	}
+
+	var out strings.Builder
+	showContextBreakdown(&out, a)
+	for _, want := range []string{"Tool results", "Total", "context window unknown", "estimates"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("/context output lacks %q:\n%s", want, out.String())
+		}
+	}
+}
@@ -7,7 +7,7 @@ import (
 	"strings"
 
 	"github.com/fpt/klein-cli/pkg/agent/domain"
-	"github.com/fpt/klein-cli/pkg/message"
+	"github.com/fpt/klein-cli/pkg/agent/state"
 	"golang.org/x/term"
 )
 
@@ -26,22 +26,7 @@ func (cd *ContextDisplay) CalculateUsageDetails(messageState domain.State, llmCl
 		return 0, 0, 0
 	}
 
-	estimateTokensFor := func(msg message.Message) int {
-		content := msg.Content()
-		if t := msg.Thinking(); t != "" {
-			content += "\n" + t
-		}
-		// ~4 chars per token + small per-message overhead
-		approx := int(math.Ceil(float64(len(content))/4.0)) + 8
-		if approx < 0 {
-			approx = 0
-		}
-		return approx
-	}
-
-	for _, msg := range messages {
-		currentTokens += estimateTokensFor(msg)
-	}
+	currentTokens = state.CountMessageTokens(state.TokenCounterFor(llmClient), messages)
 
 	// Prefer the interface-based value; fall back to hardcoded estimates.
 	if cwp, ok := llmClient.(domain.ContextWindowProvider); ok {
@@ -0,0 +1,64 @@
+package app
+
+import (
+	"sort"
+
+	"github.com/fpt/klein-cli/internal/config"
+	"github.com/fpt/klein-cli/internal/lsp"
+)
+
+// lspServers merges the [lsp] settings over the built-in server list. A
+// settings entry named like a built-in overrides only the fields it sets; any
+// other name adds a server, which then needs both a command and extensions.
+// Disabled servers are dropped. Built-ins keep their order and come first, so
+// an added server cannot take over a built-in's extensions unless the
+// built-in is disabled.
+func lspServers(s config.LSPSettings) []lsp.ServerConfig {
+	if s.Disabled {
+		return nil
+	}
+	var out []lsp.ServerConfig
+	seen := make(map[string]bool)
+	for _, d := range lsp.DefaultServers() {
+		seen[d.Name] = true
+		o, ok := s.Servers[d.Name]
+		if !ok {
+			out = append(out, d)
+			continue
+		}
+		if o.Disabled {
+			continue
+		}
+		out = append(out, overlayLSPServer(d, o))
+	}
+
+	names := make([]string, 0, len(s.Servers))
+	for name := range s.Servers {
+		if !seen[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		o := s.Servers[name]
+		if o.Disabled || o.Command == "" || len(o.Extensions) == 0 {
+			continue
+		}
+		out = append(out, overlayLSPServer(lsp.ServerConfig{Name: name}, o))
+	}
+	return out
+}
+
+func overlayLSPServer(base lsp.ServerConfig, o config.LSPServerSettings) lsp.ServerConfig {
+	if o.Command != "" {
+		base.Command = o.Command
+		base.Args = o.Args // a new command does not inherit the old one's flags
+	} else if o.Args != nil {
+		base.Args = o.Args
+	}
+	if len(o.Extensions) > 0 {
+		base.Extensions = o.Extensions
+	}
+	base.Env = o.EnvSlice()
+	return base
+}
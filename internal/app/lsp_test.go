@@ -0,0 +1,73 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/fpt/klein-cli/internal/config"
+	"github.com/fpt/klein-cli/internal/infra"
+	"github.com/fpt/klein-cli/internal/skill"
+)
+
+func TestLSPServers(t *testing.T) {
+	if got := lspServers(config.LSPSettings{Disabled: true}); got != nil {
+		t.Errorf("disabled subsystem = %+v", got)
+	}
+
+	defaults := lspServers(config.LSPSettings{})
+	var names []string
+	for _, s := range defaults {
+		names = append(names, s.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"gopls", "pyright", "tsserver"}) {
+		t.Errorf("defaults = %v", names)
+	}
+
+	got := lspServers(config.LSPSettings{Servers: map[string]config.LSPServerSettings{
+		"gopls":         {Args: []string{"-remote=auto"}, Env: map[string]string{"GOFLAGS": "-tags=integration"}},
+		"pyright":       {Disabled: true},
+		"tsserver":      {Command: "/opt/tsls"},
+		"rust-analyzer": {Command: "rust-analyzer", Extensions: []string{".rs"}},
+		"incomplete":    {Command: "x"},
+	}})
+	if len(got) != 3 {
+		t.Fatalf("merged = %+v", got)
+	}
+	if g := got[0]; g.Command != "gopls" || !reflect.DeepEqual(g.Args, []string{"-remote=auto"}) ||
+		!reflect.DeepEqual(g.Env, []string{"GOFLAGS=-tags=integration"}) || g.Extensions[0] != ".go" {
+		t.Errorf("gopls override = %+v", g)
+	}
+	if ts := got[1]; ts.Command != "/opt/tsls" || ts.Args != nil || len(ts.Extensions) == 0 {
+		t.Errorf("tsserver override = %+v", ts)
+	}
+	if ra := got[2]; ra.Name != "rust-analyzer" || ra.Extensions[0] != ".rs" {
+		t.Errorf("added server = %+v", ra)
+	}
+}
+
+func TestBuildAgentToolsRegistersLSPOnlyWhenInstalled(t *testing.T) {
+	hasRename := func() bool {
+		opts := AgentOptions{
+			Settings:   config.GetDefaultSettings(),
+			FsRepo:     infra.NewOSFilesystemRepository(),
+			WorkingDir: t.TempDir(),
+		}
+		_, ok := buildAgentTools(opts, skill.DefinitionMap{}, "", "").all.GetTool("Rename")
+		return ok
+	}
+
+	bin := t.TempDir()
+	t.Setenv("PATH", bin)
+	if hasRename() {
+		t.Error("LSP tools registered with no language server on PATH")
+	}
+	if err := os.WriteFile(filepath.Join(bin, "gopls"), []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" && !hasRename() {
+		t.Error("LSP tools missing with gopls on PATH")
+	}
+}
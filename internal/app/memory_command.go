@@ -101,7 +101,12 @@ func memoryShow(ctx context.Context, store *memorydb.Store, arg string) {
 	mem, _ := store.Get(ctx, id) // for entities
 
 	state := "active"
-	if !st.Active {
+	switch {
+	case st.MergedInto != 0:
+		state = fmt.Sprintf("merged into #%d", st.MergedInto)
+	case !st.ArchivedAt.IsZero():
+		state = "archived (klein memory restore brings it back)"
+	case !st.Active:
 		state = "inactive (superseded or forgotten)"
 	}
 	fmt.Printf("🧠 Memory #%d  [%s]  v%d  %s\n", st.ID, st.Kind, st.Version, state)
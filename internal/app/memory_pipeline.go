@@ -0,0 +1,146 @@
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fpt/klein-cli/internal/tool/memorydb"
+	"github.com/fpt/klein-cli/pkg/message"
+)
+
+// memoryExtractTimeout bounds one extraction pass, including its model call.
+const memoryExtractTimeout = 2 * time.Minute
+
+// The long-term memory pipeline (memorydb), active when a memorydb manager is
+// among the tool managers:
+//
+//   - recall: each user message is prefixed with a budgeted block of the
+//     memories relevant to it (memoryContext);
+//   - extraction: the turns a compaction is about to summarize away, and the
+//     rest of the conversation when the session ends or is cleared, are
+//     distilled into atomic facts in the background (extractMemoriesAsync,
+//     FlushMemory).
+
+// memoryContext returns the recalled-memory block to prepend to userInput, or
+// "" when memory is off, the budget disables it, or nothing is relevant.
+func (a *Agent) memoryContext(ctx context.Context, userInput string) string {
+	if a.memoryManager == nil {
+		return ""
+	}
+	budget := 0
+	if a.settings != nil {
+		budget = a.settings.Memory.RecallBudgetTokens
+	}
+	if budget < 0 {
+		return ""
+	}
+	block, err := a.memoryManager.Store().RecallContext(ctx, recallQuery(userInput), budget)
+	if err != nil {
+		a.logger.Warn("Memory recall failed, continuing without it", "error", err)
+		return ""
+	}
+	return block
+}
+
+// recallQuery drops the preambles the claw gateway puts before a message (the
+// channel and session-log tags, the scheduled-run notice) so recall matches
+// what was actually asked rather than the boilerplate around it.
+func recallQuery(userInput string) string {
+	text := userInput
+	for {
+		switch {
+		case strings.HasPrefix(text, "[SCHEDULED RUN "):
+			// A tag line followed by a fixed paragraph, ended by a blank line.
+			_, rest, ok := strings.Cut(text, "\n\n")
+			if !ok {
+				return ""
+			}
+			text = rest
+		case strings.HasPrefix(text, "[SCHEDULING CONTEXT]"), strings.HasPrefix(text, "[SESSION LOG:"):
+			_, rest, _ := strings.Cut(text, "\n")
+			text = rest
+		default:
+			return text
+		}
+	}
+}
+
+// memoryExtractionEnabled reports whether conversations are distilled into
+// memory. A whole-agent backend keeps its own transcript, and its stub client
+// cannot serve the extraction call.
+func (a *Agent) memoryExtractionEnabled() bool {
+	if a.memoryManager == nil || a.codexBackend != nil {
+		return false
+	}
+	return a.settings == nil || !a.settings.Memory.DisableExtraction
+}
+
+// memorySource names this conversation for the extractor's watermark: the
+// session file when persisted (so a restarted process resumes where the last
+// pass stopped), otherwise an id fixed for the agent's lifetime.
+func (a *Agent) memorySource() string {
+	if a.sessionFilePath != "" {
+		return a.sessionFilePath
+	}
+	a.memorySessionOnce.Do(func() {
+		var b [8]byte
+		_, _ = rand.Read(b[:])
+		a.memorySessionID = "session-" + hex.EncodeToString(b[:])
+	})
+	return a.memorySessionID
+}
+
+// extractMemoriesAsync distils msgs into memory in the background. msgs must
+// not be mutated afterwards; callers pass a snapshot.
+func (a *Agent) extractMemoriesAsync(msgs []message.Message, trigger string) {
+	if !a.memoryExtractionEnabled() {
+		return
+	}
+	source := a.memorySource()
+	a.memoryExtractWG.Add(1)
+	go func() {
+		defer a.memoryExtractWG.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), memoryExtractTimeout)
+		defer cancel()
+		a.extractMemories(ctx, source, msgs, trigger)
+	}()
+}
+
+// extractMemories runs one extraction pass. Passes are serialized so two of
+// them never read the same watermark and store the same facts twice.
+func (a *Agent) extractMemories(ctx context.Context, source string, msgs []message.Message, trigger string) {
+	a.memoryExtractMu.Lock()
+	defer a.memoryExtractMu.Unlock()
+	res, err := memorydb.NewExtractor(a.memoryManager.Store(), a.llmClient).Extract(ctx, source, msgs)
+	if err != nil {
+		a.logger.Warn("Memory extraction failed", "trigger", trigger, "error", err)
+		return
+	}
+	if res.Remembered+res.Revised > 0 {
+		a.logger.Info("Extracted long-term memories", "trigger", trigger,
+			"remembered", res.Remembered, "revised", res.Revised, "skipped", res.Skipped)
+	}
+}
+
+// onCompaction is the ReAct compaction observer: the turns about to be
+// summarized are distilled before their detail is gone.
+func (a *Agent) onCompaction(before []message.Message) {
+	a.extractMemoriesAsync(before, "compaction")
+}
+
+// FlushMemory distils whatever of the conversation has not been extracted yet
+// and waits for background passes to finish. Call it when the session ends;
+// it is a no-op when memory extraction is off.
+func (a *Agent) FlushMemory(ctx context.Context) {
+	if !a.memoryExtractionEnabled() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, memoryExtractTimeout)
+	defer cancel()
+	a.extractMemories(ctx, a.memorySource(), slices.Clone(a.sharedState.GetMessages()), "session end")
+	a.memoryExtractWG.Wait()
+}
@@ -0,0 +1,131 @@
+package app
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/fpt/klein-cli/internal/tool/memorydb"
+	"github.com/fpt/klein-cli/pkg/agent/domain"
+	pkgLogger "github.com/fpt/klein-cli/pkg/logger"
+	"github.com/fpt/klein-cli/pkg/message"
+)
+
+// memoryPipelineLLM answers extraction prompts with facts and ordinary turns
+// with a fixed reply, recording the last user message of each turn.
+type memoryPipelineLLM struct {
+	mockAgentToolCallingLLM
+	facts string
+
+	mu          sync.Mutex
+	turns       []string
+	extractions int
+}
+
+func (m *memoryPipelineLLM) reply(messages []message.Message) message.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	last := messages[len(messages)-1].Content()
+	if strings.HasPrefix(last, "You maintain an assistant's long-term memory.") {
+		m.extractions++
+		return message.NewChatMessage(message.MessageTypeAssistant, m.facts)
+	}
+	m.turns = append(m.turns, last)
+	return message.NewChatMessage(message.MessageTypeAssistant, "noted")
+}
+
+func (m *memoryPipelineLLM) Chat(_ context.Context, messages []message.Message, _ bool, _ chan<- string) (message.Message, error) {
+	return m.reply(messages), nil
+}
+
+func (m *memoryPipelineLLM) ChatWithToolChoice(_ context.Context, messages []message.Message, _ domain.ToolChoice, _ bool, _ chan<- string) (message.Message, error) {
+	return m.reply(messages), nil
+}
+
+func newMemoryPipelineAgent(t *testing.T, llm *memoryPipelineLLM) (*Agent, *memorydb.Store) {
+	t.Helper()
+	mgr, err := memorydb.NewManager(filepath.Join(t.TempDir(), "mem.sqlite"))
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	t.Cleanup(func() { mgr.Close() })
+	a := newTestAgent(t)
+	a.llmClient = llm
+	a.logger = pkgLogger.NewLogger(pkgLogger.LogLevelError)
+	a.memoryManager = mgr
+	return a, mgr.Store()
+}
+
+func TestInvokePrependsRecalledMemories(t *testing.T) {
+	llm := &memoryPipelineLLM{facts: `{"facts":[]}`}
+	a, store := newMemoryPipelineAgent(t, llm)
+	ctx := context.Background()
+	if _, err := store.Remember(ctx, "Deploys go through the staging cluster first", "fact", 0.7, nil, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Remember(ctx, "The user prefers tabs over spaces", "preference", 0.5, nil, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := a.Invoke(ctx, "[SCHEDULING CONTEXT] channel_type=discord channel_id=1\nhow do deploys work?", "code"); err != nil {
+		t.Fatal(err)
+	}
+	sent := llm.turns[0]
+	if !strings.HasPrefix(sent, memorydb.ContextStart) || !strings.Contains(sent, "staging cluster") {
+		t.Fatalf("turn was not prefixed with the deploy memory:\n%s", sent)
+	}
+	if strings.Contains(sent, "tabs") {
+		t.Fatalf("unrelated memory injected:\n%s", sent)
+	}
+	if preview := a.GetConversationPreview(10); strings.Contains(preview, memorydb.ContextStart) {
+		t.Fatalf("conversation preview shows the memory block:\n%s", preview)
+	}
+
+	if _, err := a.Invoke(ctx, "what about the weather?", "code"); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(llm.turns[1], memorydb.ContextStart) {
+		t.Fatalf("nothing relevant, yet a block was injected:\n%s", llm.turns[1])
+	}
+}
+
+func TestFlushMemoryExtractsConversation(t *testing.T) {
+	llm := &memoryPipelineLLM{facts: `{"facts":[{"content":"The user's staging cluster runs on ARM","entities":["staging"]}]}`}
+	a, store := newMemoryPipelineAgent(t, llm)
+	ctx := context.Background()
+
+	if _, err := a.Invoke(ctx, "our staging cluster runs on ARM now", "code"); err != nil {
+		t.Fatal(err)
+	}
+	a.FlushMemory(ctx)
+	hits, err := store.Recall(ctx, "staging ARM", nil, 5)
+	if err != nil || len(hits) != 1 || hits[0].Content != "The user's staging cluster runs on ARM" {
+		t.Fatalf("extracted memories = %+v, %v", hits, err)
+	}
+
+	// Already extracted: a second flush does not ask the model again.
+	a.FlushMemory(ctx)
+	if llm.extractions != 1 {
+		t.Fatalf("extraction ran %d times, want 1", llm.extractions)
+	}
+}
+
+func TestRecallQueryDropsGatewayPreambles(t *testing.T) {
+	t.Parallel()
+	in := scheduledRunPreambleForTest + "[SESSION LOG: /tmp/s.json]\nsummarize the market"
+	if got := recallQuery(in); got != "summarize the market" {
+		t.Fatalf("recallQuery = %q", got)
+	}
+	if got := recallQuery("[note] keep me"); got != "[note] keep me" {
+		t.Fatalf("recallQuery changed an ordinary message: %q", got)
+	}
+}
+
+// scheduledRunPreambleForTest has the shape of the claw gateway's
+// scheduled-run notice: a tag line and a paragraph, then a blank line.
+const scheduledRunPreambleForTest = `[SCHEDULED RUN name="morning" channel_type=discord channel_id=1]
+This is an automated, recurring scheduled task firing now.
+
+`
@@ -210,6 +210,62 @@ func writeToolResult(w io.Writer, data events.ToolResultData) {
 	}
 }
 
+// liveTailLines is how many of a running command's most recent output lines
+// the console keeps on screen.
+const liveTailLines = 5
+
+// liveTail renders the output a running tool streams (ToolOutputDelta
+// events) as a small dimmed window that is redrawn in place, so a long build
+// shows progress without scrolling the whole log past. clear erases it once
+// the tool finishes and its result is printed instead.
+type liveTail struct {
+	lines   []string // most recent lines, oldest first
+	partial bool     // the last line has no newline yet
+	drawn   int      // lines currently on screen
+}
+
+func (t *liveTail) add(w io.Writer, text string) {
+	for i, piece := range strings.Split(text, "\n") {
+		if i == 0 && t.partial && len(t.lines) > 0 {
+			t.lines[len(t.lines)-1] += piece
+		} else {
+			t.lines = append(t.lines, piece)
+		}
+	}
+	// A trailing newline leaves an empty last piece: the next chunk starts a
+	// fresh line rather than continuing it.
+	t.partial = !strings.HasSuffix(text, "\n")
+	if !t.partial {
+		t.lines = t.lines[:len(t.lines)-1]
+	}
+	if len(t.lines) > liveTailLines {
+		t.lines = append(t.lines[:0], t.lines[len(t.lines)-liveTailLines:]...)
+	}
+
+	t.erase(w)
+	for _, line := range t.lines {
+		// A carriage return redraws the line in a terminal (progress bars);
+		// show only what is left after the last one.
+		if i := strings.LastIndexByte(line, '\r'); i >= 0 {
+			line = line[i+1:]
+		}
+		fmt.Fprintf(w, "%s  │ %s%s\n", ansiDim, truncate(line, 76), ansiReset)
+	}
+	t.drawn = len(t.lines)
+}
+
+// clear erases the window and forgets its lines.
+func (t *liveTail) clear(w io.Writer) {
+	t.erase(w)
+	t.lines, t.partial, t.drawn = nil, false, 0
+}
+
+func (t *liveTail) erase(w io.Writer) {
+	if t.drawn > 0 {
+		fmt.Fprintf(w, "\x1b[%dA\x1b[J", t.drawn)
+	}
+}
+
 // WriteResponseHeader writes a standardized response header to w.
 // When colored is true, prints in bright cyan; otherwise plain text.
 func WriteResponseHeader(w io.Writer, model string, colored bool) {
@@ -0,0 +1,42 @@
+package app
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestLiveTailKeepsRecentLines(t *testing.T) {
+	var buf bytes.Buffer
+	var tail liveTail
+	tail.add(&buf, "1\n2\n3\n")
+	tail.add(&buf, "4\n5\n6\npart")
+	tail.add(&buf, "ial\r50%\r100%\n")
+	if got := strings.Join(tail.lines, ","); got != "3,4,5,6,partial\r50%\r100%" {
+		t.Fatalf("lines = %q", got)
+	}
+	if tail.partial || tail.drawn != liveTailLines {
+		t.Fatalf("partial = %v, drawn = %d", tail.partial, tail.drawn)
+	}
+
+	// Each redraw first moves up over the lines it drew last time.
+	out := buf.String()
+	if !strings.Contains(out, "\x1b[3A\x1b[J") || !strings.Contains(out, "\x1b[5A\x1b[J") {
+		t.Fatalf("redraws do not erase the previous window: %q", out)
+	}
+	last := out[strings.LastIndex(out, "\x1b[J"):]
+	if !strings.Contains(last, "│ 100%") || strings.Contains(last, "50%") || strings.Contains(last, "│ 2") {
+		t.Fatalf("last frame = %q", last)
+	}
+
+	buf.Reset()
+	tail.clear(&buf)
+	if buf.String() != "\x1b[5A\x1b[J" || tail.drawn != 0 || len(tail.lines) != 0 {
+		t.Fatalf("clear wrote %q, left %+v", buf.String(), tail)
+	}
+	buf.Reset()
+	tail.clear(&buf)
+	if buf.Len() != 0 {
+		t.Fatalf("clearing an empty tail wrote %q", buf.String())
+	}
+}
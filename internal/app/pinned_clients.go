@@ -0,0 +1,80 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/fpt/klein-cli/internal/config"
+	"github.com/fpt/klein-cli/internal/skill"
+	"github.com/fpt/klein-cli/pkg/agent/domain"
+	"github.com/fpt/klein-cli/pkg/client"
+)
+
+// definitionPin returns the backend/model/effort a definition's frontmatter
+// pins; zero when it inherits the session's.
+func definitionPin(def *skill.Definition) config.ModelPin {
+	return config.ModelPin{Backend: def.Backend, Model: def.Model, Effort: def.Effort}
+}
+
+// clientFor returns the LLM client to run under pin: the session's own client
+// when the pin changes nothing, otherwise one built through client.NewLLMClient
+// for the pinned settings.
+//
+// Pinned clients are cached for the agent's life, keyed by the pin — the
+// settings it resolves against only change through UpdateSettings, which drops
+// the cache — so an explore agent dispatched twenty times in a session opens
+// one haiku client rather than twenty. Each caller still wraps the result with its
+// own tool manager (client.NewClientWithToolManager clones from the core), so
+// sharing the cached client across concurrent subagents is safe.
+func (a *Agent) clientFor(pin config.ModelPin) (domain.LLM, error) {
+	if pin.IsZero() || a.settings == nil {
+		return a.llmClient, nil
+	}
+	settings, err := a.settings.LLM.Pin(pin)
+	if err != nil {
+		return nil, err
+	}
+	if settings.Equal(a.settings.LLM) {
+		return a.llmClient, nil
+	}
+
+	a.pinnedMu.Lock()
+	defer a.pinnedMu.Unlock()
+	if c, ok := a.pinnedClients[pin]; ok {
+		return c, nil
+	}
+	newClient := a.newLLMClient
+	if newClient == nil {
+		newClient = client.NewLLMClient
+	}
+	c, err := newClient(settings)
+	if err != nil {
+		return nil, fmt.Errorf("create %s client for %s: %w", settings.Backend, settings.Model, err)
+	}
+	if a.pinnedClients == nil {
+		a.pinnedClients = make(map[config.ModelPin]domain.LLM)
+	}
+	a.pinnedClients[pin] = c
+	if a.logger != nil {
+		a.logger.Debug("Created pinned LLM client", "backend", settings.Backend, "model", settings.Model,
+			"effort", settings.Effort)
+	}
+	return c, nil
+}
+
+// commandPin returns the model pin of the plugin command running this turn,
+// if any. Like the tool sandbox it is turn-scoped state that InvokeCommand
+// installs and restores.
+func (a *Agent) commandPin() config.ModelPin {
+	a.sandboxMu.RLock()
+	defer a.sandboxMu.RUnlock()
+	return a.commandModelPin
+}
+
+// setCommandPin installs a turn-scoped model pin and returns the previous one.
+func (a *Agent) setCommandPin(pin config.ModelPin) config.ModelPin {
+	a.sandboxMu.Lock()
+	defer a.sandboxMu.Unlock()
+	prev := a.commandModelPin
+	a.commandModelPin = pin
+	return prev
+}
@@ -0,0 +1,124 @@
+package app
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/fpt/klein-cli/internal/config"
+	pluginpkg "github.com/fpt/klein-cli/internal/plugin"
+	"github.com/fpt/klein-cli/internal/skill"
+	"github.com/fpt/klein-cli/internal/tool"
+	"github.com/fpt/klein-cli/pkg/agent/domain"
+)
+
+func TestClientFor_InheritsAndCaches(t *testing.T) {
+	t.Parallel()
+	base := &mockAgentToolCallingLLM{}
+	var built []config.LLMSettings
+	settings := config.GetDefaultSettings()
+	settings.LLM = config.GetDefaultLLMSettingsForBackend("anthropic")
+	a := &Agent{
+		llmClient: base,
+		settings:  settings,
+		newLLMClient: func(s config.LLMSettings) (domain.LLM, error) {
+			built = append(built, s)
+			return &mockAgentToolCallingLLM{}, nil
+		},
+	}
+
+	for _, pin := range []config.ModelPin{{}, {Model: "inherit"}, {Model: a.settings.LLM.Model}} {
+		if c, err := a.clientFor(pin); err != nil || c != base {
+			t.Errorf("clientFor(%+v) = %v, %v; want the session client", pin, c, err)
+		}
+	}
+
+	haiku, err := a.clientFor(config.ModelPin{Model: "haiku"})
+	if err != nil {
+		t.Fatalf("clientFor(haiku): %v", err)
+	}
+	if haiku == base {
+		t.Fatal("a haiku pin must not reuse the session client")
+	}
+	again, _ := a.clientFor(config.ModelPin{Model: "haiku"})
+	if again != haiku {
+		t.Error("the same pin should reuse the cached client")
+	}
+	if _, err := a.clientFor(config.ModelPin{Model: "opus"}); err != nil {
+		t.Fatalf("clientFor(opus): %v", err)
+	}
+	if len(built) != 2 || built[0].Model != "claude-haiku-4-5" || built[1].Model != "claude-opus-4-7" {
+		t.Errorf("built clients: %+v", built)
+	}
+
+	if _, err := a.clientFor(config.ModelPin{Backend: "codex"}); err == nil {
+		t.Error("pinning a whole-agent backend should fail")
+	}
+}
+
+func TestSelectToolManager_DisallowedTools(t *testing.T) {
+	a := newTestAgent(t)
+
+	capped := &skill.Definition{
+		Name:            "capped",
+		Tools:           []string{catToolRead, toolBash, toolWrite},
+		DisallowedTools: []string{toolBash},
+	}
+	tm, _ := a.selectToolManager(capped)
+	if _, ok := tm.GetTools()[toolBash]; ok {
+		t.Error("capped: Bash is disallowed and must not be exposed")
+	}
+	if _, ok := tm.GetTools()[toolWrite]; !ok {
+		t.Error("capped: Write should remain")
+	}
+
+	a.deferredTools = tool.NewDeferredToolManager(a.allToolManagers)
+	denied := &skill.Definition{Name: "denied", DisallowedTools: []string{toolBash}}
+	tm, usingDeferred := a.selectToolManager(denied)
+	if !usingDeferred {
+		t.Fatal("an uncapped definition should get the deferred view")
+	}
+	if _, ok := tm.GetTools()[toolBash]; ok {
+		t.Error("deferred: Bash is disallowed and must not be exposed")
+	}
+
+	// The deferred view is shared; the next definition must not inherit the
+	// previous one's denylist.
+	tm, _ = a.selectToolManager(&skill.Definition{Name: "plain"})
+	if _, ok := tm.GetTools()[toolBash]; !ok {
+		t.Error("deferred: Bash should be back for a definition without a denylist")
+	}
+}
+
+func TestCommandSandbox(t *testing.T) {
+	a := newTestAgent(t)
+
+	if got := a.commandSandbox(&pluginpkg.Command{}); got != nil {
+		t.Errorf("no tool policy: got %v, want nil", got)
+	}
+	allowed := []string{catToolRead, toolBash}
+	if got := a.commandSandbox(&pluginpkg.Command{AllowedTools: allowed}); !equalStringSlices(got, allowed) {
+		t.Errorf("allowed only: got %v, want %v", got, allowed)
+	}
+	got := a.commandSandbox(&pluginpkg.Command{AllowedTools: allowed, DisallowedTools: []string{toolBash}})
+	if !equalStringSlices(got, []string{catToolRead}) {
+		t.Errorf("allowed minus disallowed: got %v", got)
+	}
+
+	// A denylist alone becomes every registered tool but the denied ones.
+	got = a.commandSandbox(&pluginpkg.Command{DisallowedTools: []string{toolBash}})
+	if slices.Contains(got, toolBash) || !slices.Contains(got, catToolRead) || !slices.Contains(got, toolWrite) {
+		t.Errorf("disallowed only: got %v", got)
+	}
+}
+
+func TestBuildSubAgentToolManager_StripsDisallowed(t *testing.T) {
+	a := newTestAgent(t)
+	tm := buildSubAgentToolManager(a.allToolManagers, nil, []string{toolBash})
+	tools := tm.GetTools()
+	if _, ok := tools[toolBash]; ok {
+		t.Error("Bash is disallowed and must be stripped")
+	}
+	if _, ok := tools[catToolRead]; !ok {
+		t.Error("Read should remain")
+	}
+}
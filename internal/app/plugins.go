@@ -7,6 +7,7 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/fpt/klein-cli/internal/config"
 	"github.com/fpt/klein-cli/internal/permission"
 	pluginpkg "github.com/fpt/klein-cli/internal/plugin"
 	"github.com/fpt/klein-cli/internal/skill"
@@ -165,14 +166,18 @@ func mergeDefinitions(
 // excluded. Otherwise `preload:` only decides what is visible up front, and
 // anything else stays reachable through ToolSearch, which is what lets the cad
 // role discover app MCP tools.
+//
+// `disallowedTools:` is subtracted in every case, after whichever list applies,
+// and in the deferred view also from what ToolSearch can load.
 func (a *Agent) selectToolManager(def *skill.Definition) (domain.ToolManager, bool) {
 	switch sandbox := a.toolSandbox(); {
 	case len(sandbox) > 0:
-		return skill.NewFilteredToolManager(a.allToolManagers, sandbox), false
+		return skill.NewFilteredToolManager(a.allToolManagers, skill.WithoutTools(sandbox, def.DisallowedTools)), false
 	case len(def.Tools) > 0:
-		return skill.NewFilteredToolManager(a.allToolManagers, def.Tools), false
+		return skill.NewFilteredToolManager(a.allToolManagers, skill.WithoutTools(def.Tools, def.DisallowedTools)), false
 	case a.deferredTools != nil:
 		a.deferredTools.SetCore(def.Preload) // empty → default core
+		a.deferredTools.SetDenied(def.DisallowedTools)
 		return a.deferredTools, true
 	default:
 		// Fallback for agents built without the constructor (tests).
@@ -278,7 +283,8 @@ func (a *Agent) ListPluginCommands() []string {
 
 // InvokeCommand renders a plugin command's body and runs it through the
 // agent's normal Invoke path, with the command's `allowed-tools` taking
-// precedence over any active skill restriction for this turn only.
+// precedence over any active skill restriction for this turn only. Its
+// `disallowed-tools` and model pin are likewise scoped to the turn.
 func (a *Agent) InvokeCommand(ctx context.Context, cmd *pluginpkg.Command, args string, skillName string) (message.Message, error) {
 	if cmd == nil {
 		return nil, fmt.Errorf("nil command")
@@ -289,10 +295,14 @@ func (a *Agent) InvokeCommand(ctx context.Context, cmd *pluginpkg.Command, args
 	// existing override so the command doesn't leak state into subsequent
 	// turns. Anything dispatched during the window must capture the override
 	// synchronously — see resolveSubagentTools.
-	if len(cmd.AllowedTools) > 0 {
-		prevOverride := a.setToolSandbox(cmd.AllowedTools)
+	if sandbox := a.commandSandbox(cmd); len(sandbox) > 0 {
+		prevOverride := a.setToolSandbox(sandbox)
 		defer a.setToolSandbox(prevOverride)
 	}
+	if pin := (config.ModelPin{Backend: cmd.Backend, Model: cmd.Model, Effort: cmd.Effort}); !pin.IsZero() {
+		prevPin := a.setCommandPin(pin)
+		defer a.setCommandPin(prevPin)
+	}
 
 	// Plugin commands frequently shell out via Bash; the official Claude Code
 	// behaviour is to auto-approve based on the command's `allowed-tools`.
@@ -315,6 +325,30 @@ func (a *Agent) InvokeCommand(ctx context.Context, cmd *pluginpkg.Command, args
 	return a.Invoke(ctx, prompt, skillName)
 }
 
+// commandSandbox returns the hard tool list a plugin command runs under, or
+// nil when it declares no tool policy.
+//
+// A denylist is expressed as a sandbox too — the command's allowed-tools, else
+// the sandbox already in force, else every registered tool, minus
+// disallowed-tools. Riding on the sandbox is what makes the denial reach
+// subagents the command dispatches, which a per-definition denylist would not.
+func (a *Agent) commandSandbox(cmd *pluginpkg.Command) []string {
+	if len(cmd.DisallowedTools) == 0 {
+		return cmd.AllowedTools
+	}
+	base := cmd.AllowedTools
+	if len(base) == 0 {
+		base = a.toolSandbox()
+	}
+	if len(base) == 0 {
+		for name := range a.allToolManagers.GetTools() {
+			base = append(base, string(name))
+		}
+		sort.Strings(base)
+	}
+	return skill.WithoutTools(base, cmd.DisallowedTools)
+}
+
 // PluginMCPServers returns the merged MCP server configurations contributed
 // by all registered plugins. Used by main.go to fold plugin MCP servers into
 // the global MCP integration on startup.
@@ -0,0 +1,74 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fpt/klein-cli/internal/permission"
+	"github.com/fpt/klein-cli/pkg/agent/domain"
+	"github.com/fpt/klein-cli/pkg/message"
+)
+
+// ApprovalDecision is a remote approver's answer for one pending tool call.
+// The zero value denies, so an approver that gives up (timeout, cancelled
+// context, unknown answer) never lets a call through by accident.
+type ApprovalDecision int
+
+const (
+	ApprovalDeny ApprovalDecision = iota
+	ApprovalAllowOnce
+	ApprovalAllowSession // allow this call and similar ones for the rest of the session
+)
+
+// ToolApprovalRequest describes a tool call waiting for a remote decision.
+type ToolApprovalRequest struct {
+	ToolName    string // qualified name, e.g. "Bash" or "mcp:github/create_issue"
+	Arguments   map[string]any
+	Description string // the same text the terminal dialog shows
+}
+
+// ToolApprover puts a pending tool call in front of a human who is not at this
+// process's terminal (e.g. a Discord user behind the Connect server) and
+// blocks until they answer. Implementations own their timeout.
+type ToolApprover func(ctx context.Context, req ToolApprovalRequest) ApprovalDecision
+
+// SetToolApprover routes approval-gated tool calls to fn instead of the
+// terminal dialog. Non-interactive agents pre-approve everything through their
+// session rules; those are dropped here, since a remote approver exists
+// precisely so that a human decides. Persistent rules still apply first.
+func (a *Agent) SetToolApprover(fn ToolApprover) {
+	a.toolApprover = fn
+	if fn != nil {
+		a.sessionRules = &permission.RuleSet{}
+	}
+}
+
+// askToolApprover resolves the pending call through the remote approver.
+func (a *Agent) askToolApprover(ctx context.Context, reactClient domain.ReAct, call permission.Call, description string) (message.Message, error) {
+	writer := a.OutWriter()
+	fmt.Fprintf(writer, "\n%s\nWaiting for remote approval...\n", description)
+
+	decision := a.toolApprover(ctx, ToolApprovalRequest{
+		ToolName:    call.QualifiedName(),
+		Arguments:   call.Args,
+		Description: description,
+	})
+	switch decision {
+	case ApprovalAllowSession:
+		var first string
+		if len(call.Primary) > 0 {
+			first = call.Primary[0]
+		}
+		rule := permission.PermissionRule{Tool: call.QualifiedName(), Pattern: inferPattern(call.Tool, first), Behavior: permission.RuleAllow}
+		a.sessionRules.Rules = append(a.sessionRules.Rules, rule)
+		fmt.Fprintf(writer, "Approved for this session (%s %s). Proceeding...\n\n", rule.Tool, rule.Pattern)
+		return reactClient.Resume(ctx)
+	case ApprovalAllowOnce:
+		fmt.Fprintf(writer, "Approved. Proceeding...\n\n")
+		return reactClient.Resume(ctx)
+	default:
+		fmt.Fprintf(writer, "Cancelled (denied or no answer).\n")
+		reactClient.CancelPendingToolCall()
+		return reactClient.Resume(ctx)
+	}
+}
@@ -0,0 +1,108 @@
+package app
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/fpt/klein-cli/internal/permission"
+	"github.com/fpt/klein-cli/pkg/agent/domain"
+	"github.com/fpt/klein-cli/pkg/message"
+)
+
+// pendingReAct is a ReAct paused on one tool call; it records whether the
+// approval workflow resumed or cancelled it.
+type pendingReAct struct {
+	domain.ReAct
+	pending   message.Message
+	cancelled bool
+	resumed   bool
+}
+
+func (r *pendingReAct) GetPendingToolCall() message.Message { return r.pending }
+func (r *pendingReAct) CancelPendingToolCall()              { r.cancelled = true }
+func (r *pendingReAct) Resume(context.Context) (message.Message, error) {
+	r.resumed = true
+	return nil, nil
+}
+
+func bashCall(command string) *pendingReAct {
+	return &pendingReAct{pending: message.NewToolCallMessage("Bash", message.ToolArgumentValues{"command": command})}
+}
+
+func TestRemoteApproval_Decisions(t *testing.T) {
+	cases := []struct {
+		decision   ApprovalDecision
+		wantCancel bool
+	}{
+		{ApprovalAllowOnce, false},
+		{ApprovalAllowSession, false},
+		{ApprovalDeny, true},
+	}
+	for _, c := range cases {
+		a := &Agent{permRules: &permission.RuleSet{}, sessionRules: newSessionRules(false), out: io.Discard}
+		var got ToolApprovalRequest
+		a.SetToolApprover(func(_ context.Context, req ToolApprovalRequest) ApprovalDecision {
+			got = req
+			return c.decision
+		})
+
+		r := bashCall("rm -rf build")
+		if _, err := a.handleApprovalWorkflow(context.Background(), r); err != nil {
+			t.Fatal(err)
+		}
+		if r.cancelled != c.wantCancel || !r.resumed {
+			t.Errorf("decision %d: cancelled=%v resumed=%v", c.decision, r.cancelled, r.resumed)
+		}
+		if got.ToolName != "Bash" || got.Arguments["command"] != "rm -rf build" || got.Description == "" {
+			t.Errorf("approver saw %+v", got)
+		}
+	}
+}
+
+// Setting a remote approver drops the blanket pre-approval non-interactive
+// agents start with; otherwise the approver would never be asked.
+func TestRemoteApproval_ReplacesPreApproval(t *testing.T) {
+	a := &Agent{permRules: &permission.RuleSet{}, sessionRules: newSessionRules(false), out: io.Discard}
+	asked := 0
+	a.SetToolApprover(func(context.Context, ToolApprovalRequest) ApprovalDecision {
+		asked++
+		return ApprovalAllowSession
+	})
+
+	for _, cmd := range []string{"go test ./...", "go test ./pkg/..."} {
+		if _, err := a.handleApprovalWorkflow(context.Background(), bashCall(cmd)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if asked != 1 {
+		t.Errorf("approver asked %d times, want 1 (the session rule covers the second call)", asked)
+	}
+	if _, err := a.handleApprovalWorkflow(context.Background(), bashCall("rm -rf /")); err != nil {
+		t.Fatal(err)
+	}
+	if asked != 2 {
+		t.Error("the session rule must not cover an unrelated command")
+	}
+}
+
+// Persistent rules are consulted before the remote approver.
+func TestRemoteApproval_PersistentRulesFirst(t *testing.T) {
+	a := &Agent{out: io.Discard, permRules: &permission.RuleSet{Rules: []permission.PermissionRule{
+		{Tool: "Bash", Pattern: "rm *", Behavior: permission.RuleDeny},
+		{Tool: "Bash", Pattern: "make *", Behavior: permission.RuleAllow},
+	}}}
+	a.SetToolApprover(func(context.Context, ToolApprovalRequest) ApprovalDecision {
+		t.Error("approver should not be asked when a persistent rule matches")
+		return ApprovalAllowOnce
+	})
+
+	denied := bashCall("rm -rf build")
+	if _, err := a.handleApprovalWorkflow(context.Background(), denied); err != nil || !denied.cancelled {
+		t.Errorf("deny rule: cancelled=%v err=%v", denied.cancelled, err)
+	}
+	allowed := bashCall("make lint")
+	if _, err := a.handleApprovalWorkflow(context.Background(), allowed); err != nil || allowed.cancelled {
+		t.Errorf("allow rule: cancelled=%v err=%v", allowed.cancelled, err)
+	}
+}
@@ -62,6 +62,14 @@ func getSlashCommands() []SlashCommand {
 				return false
 			},
 		},
+		{
+			Name:        "context",
+			Description: "Show how the context window is used, by category",
+			Handler: func(a *Agent) bool {
+				showContextBreakdown(os.Stdout, a)
+				return false
+			},
+		},
 		{
 			Name:        "tasks",
 			Description: "Show all tasks for this project session",
@@ -83,6 +91,22 @@ func getSlashCommands() []SlashCommand {
 				return false
 			},
 		},
+		{
+			Name:        cmdResume,
+			Description: "Switch to another saved session of this project (/resume [id])",
+			Handler: func(a *Agent) bool {
+				handleResumeCommand(a, "")
+				return false
+			},
+		},
+		{
+			Name:        cmdClaude,
+			Description: "Move conversations to/from Claude Code (/claude list|import [id]|export)",
+			Handler: func(a *Agent) bool {
+				handleClaudeCommand(a, "")
+				return false
+			},
+		},
 		{
 			Name:        "quit",
 			Description: "Exit the interactive session",
@@ -117,12 +141,22 @@ func handleSlashCommand(input string, a *Agent) bool {
 
 	commandName := strings.TrimPrefix(parts[0], "/")
 
-	// /memory takes subcommands/args, which the generic argument-less dispatch
-	// below would drop — handle it here with the full argument string.
-	if commandName == cmdMemory {
+	// /memory, /resume and /claude take subcommands/args, which the generic
+	// argument-less dispatch below would drop — handle them here with the full
+	// argument string.
+	switch commandName {
+	case cmdMemory:
 		_, args := SplitSlashCommand(input)
 		handleMemoryCommand(a, args)
 		return false
+	case cmdResume:
+		_, args := SplitSlashCommand(input)
+		handleResumeCommand(a, args)
+		return false
+	case cmdClaude:
+		_, args := SplitSlashCommand(input)
+		handleClaudeCommand(a, args)
+		return false
 	}
 
 	commands := getSlashCommands()
@@ -219,7 +253,7 @@ func isBuiltinSlashCommand(name string) bool {
 			return true
 		}
 	}
-	return name == cmdGoal || name == cmdLoop
+	return name == cmdGoal || name == cmdLoop || name == cmdCompact
 }
 
 // agentCommandDescription labels a definition in the REPL command palette.
@@ -412,9 +446,9 @@ func StartInteractiveMode(ctx context.Context, a *Agent, skillName string) {
 		// in VisiblePrompt() does not interfere with detection.
 		if pb.IsSlashCommand() {
 			cmd := pb.SlashInput()
-			// /goal and /loop are multi-turn drivers that need ctx and the
-			// active skill, so they are dispatched here rather than via the
-			// argument-less handleSlashCommand handlers.
+			// /goal, /loop and /compact need ctx and the active skill, so
+			// they are dispatched here rather than via the argument-less
+			// handleSlashCommand handlers.
 			if handleDrivingCommand(ctx, a, skillName, cmd) {
 				pb.Clear()
 				rl.Clean()
@@ -478,7 +512,7 @@ func StartInteractiveMode(ctx context.Context, a *Agent, skillName string) {
 }
 
 // slashCandidates returns the invocable /commands for display: built-ins, the
-// multi-turn drivers (/goal, /loop), any loaded plugin commands, and every
+// context-taking ones (/goal, /loop, /compact), any loaded plugin commands, and every
 // definition that can drive a turn.
 func slashCandidates(a *Agent) []SlashCommand {
 	cmds := getSlashCommands()
@@ -486,6 +520,7 @@ func slashCandidates(a *Agent) []SlashCommand {
 		cmds,
 		SlashCommand{Name: cmdGoal, Description: "Set and track a goal across turns"},
 		SlashCommand{Name: cmdLoop, Description: "Repeat a prompt/command on an interval"},
+		SlashCommand{Name: cmdCompact, Description: "Compact the conversation now (/compact [--micro|--structured] [focus])"},
 	)
 	for _, name := range a.StartupNames() {
 		if isBuiltinSlashCommand(name) {
@@ -518,7 +553,7 @@ func createAutoCompleter(a *Agent) *readline.PrefixCompleter {
 		pcItems = append(pcItems, readline.PcItem("/"+cmd.Name))
 	}
 	// Multi-turn driving commands handled outside getSlashCommands.
-	pcItems = append(pcItems, readline.PcItem("/goal"), readline.PcItem("/loop"))
+	pcItems = append(pcItems, readline.PcItem("/goal"), readline.PcItem("/loop"), readline.PcItem("/"+cmdCompact))
 	for _, name := range a.StartupNames() {
 		pcItems = append(pcItems, readline.PcItem("/"+name))
 	}
@@ -669,9 +704,10 @@ func stdinIsInteractive() bool {
 	return (stat.Mode() & os.ModeCharDevice) != 0
 }
 
-// offerClaudeHistoryImport checks whether a Claude Code session file exists for
-// the agent's working directory. When one is found the user is prompted to import
-// it (y/n). Errors are printed as informational warnings, never fatal.
+// offerClaudeHistoryImport checks whether Claude Code has sessions for the
+// agent's working directory and offers to import one: a yes/no prompt for a
+// single session, a picker (newest first) when there are several. Errors are
+// printed as informational warnings, never fatal.
 func offerClaudeHistoryImport(a *Agent) {
 	// The import prompt is an interactive selector; skip it entirely when stdin
 	// is piped (non-interactive), otherwise it would swallow the piped input.
@@ -679,32 +715,42 @@ func offerClaudeHistoryImport(a *Agent) {
 		return
 	}
 
-	jsonlPath, err := claude.FindLatestSession(a.WorkingDir())
+	sessions, err := claude.ListSessions(a.WorkingDir())
 	if err != nil {
 		fmt.Printf("⚠️  Could not check for Claude history: %v\n", err)
 		return
 	}
-	if jsonlPath == "" {
+	if len(sessions) == 0 {
 		return // no history available
 	}
 
-	prompt := promptui.Select{
-		Label: "Claude Code history found. Import it into this session?",
-		Items: []string{"Yes", "No"},
-		Templates: &promptui.SelectTemplates{
-			Label:    "{{ . }}",
-			Active:   "> {{ . | cyan }}",
-			Inactive: "  {{ . }}",
-			Selected: "{{ . | bold }}",
-		},
-		Size: 2,
-	}
-	_, result, err := prompt.Run()
-	if err != nil || result != "Yes" {
-		return
+	var target claude.Session
+	if len(sessions) == 1 {
+		prompt := promptui.Select{
+			Label: "Claude Code history found. Import it into this session?",
+			Items: []string{"Yes", "No"},
+			Templates: &promptui.SelectTemplates{
+				Label:    "{{ . }}",
+				Active:   "> {{ . | cyan }}",
+				Inactive: "  {{ . }}",
+				Selected: "{{ . | bold }}",
+			},
+			Size: 2,
+		}
+		_, result, err := prompt.Run()
+		if err != nil || result != "Yes" {
+			return
+		}
+		target = sessions[0]
+	} else {
+		s, ok := pickClaudeSession("Claude Code history found. Import a session?", sessions, true)
+		if !ok {
+			return
+		}
+		target = s
 	}
 
-	count, err := a.ImportClaudeHistory(jsonlPath)
+	count, err := a.ImportClaudeHistory(target.Path)
 	if err != nil {
 		fmt.Printf("⚠️  Failed to import Claude history: %v\n", err)
 		return
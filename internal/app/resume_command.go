@@ -0,0 +1,105 @@
+package app
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fpt/klein-cli/internal/session"
+	"github.com/manifoldco/promptui"
+)
+
+// cmdResume is the /resume command name (REPL palette + dispatch).
+const cmdResume = "resume"
+
+// handleResumeCommand implements /resume [id]: switch this REPL to another of
+// the project's saved sessions. With no id it offers a picker, most recently
+// used first; `klein sessions list` shows the same sessions outside the REPL.
+func handleResumeCommand(a *Agent, args string) {
+	current := a.SessionFile()
+	if current == "" {
+		fmt.Println("📜 This session is not saved to disk, so there is nothing to resume from.")
+		return
+	}
+	store := session.NewStore(filepath.Dir(current))
+
+	var target session.Info
+	if ref := strings.TrimSpace(args); ref != "" {
+		info, err := store.Resolve(ref)
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			return
+		}
+		target = info
+	} else {
+		info, ok := pickSession(store, current)
+		if !ok {
+			return
+		}
+		target = info
+	}
+
+	if target.Path == current {
+		fmt.Println("📜 That is the current session.")
+		return
+	}
+	if err := a.SwitchSession(target.Path); err != nil {
+		fmt.Printf("❌ Failed to resume session: %v\n", err)
+		return
+	}
+	fmt.Printf("📜 Resumed %s — %s (%d messages)\n", target.ID, target.DisplayTitle(), target.Messages)
+	if preview := a.GetConversationPreview(4); preview != "" {
+		fmt.Print(preview)
+	}
+}
+
+// pickSession lists the project's other sessions and lets the user choose one.
+// Without a terminal it prints them instead, for `/resume <id>`.
+func pickSession(store *session.Store, current string) (session.Info, bool) {
+	all, err := store.List()
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return session.Info{}, false
+	}
+	others := make([]session.Info, 0, len(all))
+	for _, info := range all {
+		if info.Path != current {
+			others = append(others, info)
+		}
+	}
+	if len(others) == 0 {
+		fmt.Println("📜 No other sessions in this project.")
+		return session.Info{}, false
+	}
+
+	now := time.Now()
+	lines := make([]string, len(others))
+	for i, info := range others {
+		lines[i] = info.Summary(now)
+	}
+	if !stdinIsInteractive() {
+		for _, l := range lines {
+			fmt.Println("  " + l)
+		}
+		fmt.Println("💡 Use /resume <id> to switch.")
+		return session.Info{}, false
+	}
+
+	prompt := promptui.Select{
+		Label: "Resume which session?",
+		Items: lines,
+		Size:  10,
+		Searcher: func(input string, index int) bool {
+			return strings.Contains(strings.ToLower(lines[index]), strings.ToLower(input))
+		},
+	}
+	i, _, err := prompt.Run()
+	if err != nil {
+		if err != promptui.ErrInterrupt {
+			fmt.Printf("Session selection failed: %v\n", err)
+		}
+		return session.Info{}, false
+	}
+	return others[i], true
+}
@@ -1,9 +1,13 @@
 package app
 
 import (
+	"context"
 	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/fpt/klein-cli/internal/infra"
+	"github.com/fpt/klein-cli/internal/session"
 	"github.com/fpt/klein-cli/pkg/agent/domain"
 	pkgLogger "github.com/fpt/klein-cli/pkg/logger"
 	"github.com/fpt/klein-cli/pkg/message"
@@ -108,3 +112,49 @@ func TestSessionLifecycle_SkipRestoreBeatsContinue(t *testing.T) {
 		t.Errorf("skipSessionRestore must not reuse the previous session file %q", firstPath)
 	}
 }
+
+// A turn in a file-backed session records its role in the session index, and
+// /resume's SwitchSession swaps the conversation and the file it saves to.
+func TestSessionIndex_RoleRecordedAndSwitch(t *testing.T) {
+	dir := t.TempDir()
+	older := filepath.Join(dir, "older.json")
+	if err := infra.NewMessageHistoryRepository(older).Save([]message.Message{
+		message.NewChatMessage(message.MessageTypeUser, "an older thread"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	a := newTestAgent(t)
+	a.logger = pkgLogger.NewLogger(pkgLogger.LogLevelError)
+	current := filepath.Join(dir, "current.json")
+	if err := a.EnablePersistence(current); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Invoke(context.Background(), "hello", "code"); err != nil {
+		t.Fatal(err)
+	}
+	info, err := session.NewStore(dir).Resolve("current")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Role != "code" || info.FirstPrompt != "hello" {
+		t.Errorf("index entry = %+v", info)
+	}
+
+	if err := a.SwitchSession(older); err != nil {
+		t.Fatal(err)
+	}
+	if a.SessionFile() != older {
+		t.Errorf("SessionFile = %q", a.SessionFile())
+	}
+	msgs := a.GetMessageState().GetMessages()
+	if len(msgs) != 1 || msgs[0].Content() != "an older thread" {
+		t.Errorf("switched history = %+v", msgs)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "broken.json"), []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.SwitchSession(filepath.Join(dir, "broken.json")); err == nil || a.SessionFile() != older {
+		t.Errorf("a session that will not load must leave the current one in place (err=%v, file=%q)", err, a.SessionFile())
+	}
+}
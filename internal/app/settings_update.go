@@ -0,0 +1,54 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/fpt/klein-cli/pkg/client"
+)
+
+// SettingsUpdate is a mid-session change to an agent's settings, e.g. an
+// editor switching models. Zero values leave a setting alone.
+type SettingsUpdate struct {
+	Model         string
+	MaxIterations int
+	Thinking      *bool
+}
+
+// UpdateSettings applies u from the next turn on. Changing the model or
+// thinking rebuilds the session's LLM client, and drops the pinned clients,
+// which were resolved against the old settings. It must not run during a turn.
+func (a *Agent) UpdateSettings(u SettingsUpdate) error {
+	if a.settings == nil {
+		return errors.New("agent has no settings to update")
+	}
+	llm := a.settings.LLM
+	if u.Model != "" {
+		llm.Model = u.Model
+	}
+	if u.Thinking != nil {
+		llm.Thinking = *u.Thinking
+	}
+	if !llm.Equal(a.settings.LLM) {
+		if a.codexBackend != nil {
+			return fmt.Errorf("the %s backend cannot change model or thinking mid-session", llm.Backend)
+		}
+		newClient := a.newLLMClient
+		if newClient == nil {
+			newClient = client.NewLLMClient
+		}
+		c, err := newClient(llm)
+		if err != nil {
+			return fmt.Errorf("create %s client for %s: %w", llm.Backend, llm.Model, err)
+		}
+		a.llmClient = c
+		a.settings.LLM = llm
+		a.pinnedMu.Lock()
+		a.pinnedClients = nil
+		a.pinnedMu.Unlock()
+	}
+	if u.MaxIterations > 0 {
+		a.settings.Agent.MaxIterations = u.MaxIterations
+	}
+	return nil
+}
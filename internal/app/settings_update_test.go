@@ -0,0 +1,48 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/fpt/klein-cli/internal/config"
+	"github.com/fpt/klein-cli/pkg/agent/domain"
+)
+
+func TestUpdateSettings(t *testing.T) {
+	base := &mockAgentToolCallingLLM{}
+	var built []config.LLMSettings
+	settings := config.GetDefaultSettings()
+	settings.LLM = config.GetDefaultLLMSettingsForBackend("anthropic")
+	a := &Agent{
+		llmClient: base,
+		settings:  settings,
+		newLLMClient: func(s config.LLMSettings) (domain.LLM, error) {
+			built = append(built, s)
+			return &mockAgentToolCallingLLM{}, nil
+		},
+	}
+	if _, err := a.clientFor(config.ModelPin{Model: "haiku"}); err != nil {
+		t.Fatalf("clientFor: %v", err)
+	}
+
+	// Only the iteration cap: the client stays.
+	if err := a.UpdateSettings(SettingsUpdate{MaxIterations: 7}); err != nil {
+		t.Fatalf("UpdateSettings: %v", err)
+	}
+	if a.llmClient != base || a.settings.Agent.MaxIterations != 7 {
+		t.Errorf("client swapped=%v, max_iterations=%d", a.llmClient != base, a.settings.Agent.MaxIterations)
+	}
+
+	thinking := true
+	if err := a.UpdateSettings(SettingsUpdate{Model: "claude-opus-4-7", Thinking: &thinking}); err != nil {
+		t.Fatalf("UpdateSettings: %v", err)
+	}
+	if a.llmClient == base {
+		t.Error("a model change should rebuild the client")
+	}
+	if last := built[len(built)-1]; last.Model != "claude-opus-4-7" || !last.Thinking {
+		t.Errorf("rebuilt with %+v", last)
+	}
+	if len(a.pinnedClients) != 0 {
+		t.Error("pinned clients resolved against the old settings should be dropped")
+	}
+}
@@ -86,7 +86,7 @@ func TestStartBackgroundAgent_ResolvesSandboxAtDispatch(t *testing.T) {
 		Kind:  skill.KindAgent,
 		Tools: []string{toolBash, toolWrite},
 	}
-	if _, err := a.StartBackgroundAgent(def, "do something"); err == nil {
+	if _, err := a.StartBackgroundAgent(context.Background(), def, "do something"); err == nil {
 		t.Fatal("expected a synchronous refusal at dispatch")
 	} else if !strings.Contains(err.Error(), "sandbox") {
 		t.Errorf("error %q should mention the sandbox", err)
@@ -19,10 +19,11 @@ const (
 	cmdLoop = "loop"
 )
 
-// handleDrivingCommand dispatches the multi-turn driving slash commands
-// (/goal and /loop), which need the execution context and active skill. It
-// returns true if the input was one of these commands (whether or not it did
-// useful work), so the REPL can skip the argument-less command dispatch.
+// handleDrivingCommand dispatches the slash commands that need the execution
+// context and active skill: the multi-turn drivers /goal and /loop, and
+// /compact. It returns true if the input was one of these commands (whether or
+// not it did useful work), so the REPL can skip the argument-less command
+// dispatch.
 func handleDrivingCommand(ctx context.Context, a *Agent, skillName, input string) bool {
 	trimmed := strings.TrimSpace(input)
 	name, args, _ := strings.Cut(strings.TrimPrefix(trimmed, "/"), " ")
@@ -33,6 +34,9 @@ func handleDrivingCommand(ctx context.Context, a *Agent, skillName, input string
 	case cmdLoop:
 		runLoop(ctx, a, skillName, args)
 		return true
+	case cmdCompact:
+		handleCompactCommand(ctx, a, skillName, args)
+		return true
 	}
 	return false
 }
@@ -45,25 +49,10 @@ func handleDrivingCommand(ctx context.Context, a *Agent, skillName, input string
 // This is the shared execution path for normal REPL turns as well as the
 // auto-continuing /goal and repeating /loop drivers.
 func executeTurn(ctx context.Context, a *Agent, userInput, skillName string) (message.Message, bool, error) {
-	execCtx, cancel := context.WithCancel(ctx)
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT)
-
-	go func() {
-		select {
-		case <-sigChan:
-			fmt.Println() // move to a new line after ^C
-			cancel()
-		case <-execCtx.Done():
-		}
-	}()
-
+	execCtx, stop := interruptible(ctx)
 	response, invokeErr := a.Invoke(execCtx, userInput, skillName)
 	canceled := execCtx.Err() == context.Canceled
-
-	signal.Stop(sigChan)
-	close(sigChan)
-	cancel()
+	stop()
 
 	if invokeErr != nil {
 		if canceled {
@@ -79,6 +68,28 @@ func executeTurn(ctx context.Context, a *Agent, userInput, skillName string) (me
 	return response, canceled, nil
 }
 
+// interruptible returns a context that Ctrl+C cancels, and the func that
+// stops listening for it and releases the context.
+func interruptible(ctx context.Context) (context.Context, func()) {
+	execCtx, cancel := context.WithCancel(ctx)
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT)
+
+	go func() {
+		select {
+		case <-sigChan:
+			fmt.Println() // move to a new line after ^C
+			cancel()
+		case <-execCtx.Done():
+		}
+	}()
+
+	return execCtx, func() {
+		signal.Stop(sigChan)
+		cancel()
+	}
+}
+
 // interruptibleSleep blocks for d, returning true if it was interrupted early by
 // Ctrl+C or context cancellation, false if the full duration elapsed.
 func interruptibleSleep(ctx context.Context, d time.Duration) bool {
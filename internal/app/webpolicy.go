@@ -0,0 +1,46 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"github.com/fpt/klein-cli/internal/config"
+	"github.com/fpt/klein-cli/internal/webarchive"
+	"github.com/fpt/klein-cli/internal/webfetch"
+)
+
+// webContext returns ctx carrying what role's fetches run under: its policy
+// and the web archive.
+func (a *Agent) webContext(ctx context.Context, role string) context.Context {
+	ctx = webfetch.WithPolicy(ctx, a.webPolicy(role))
+	if a.webArchive != nil {
+		ctx = webarchive.WithArchive(ctx, a.webArchive)
+	}
+	return ctx
+}
+
+// webPolicy returns the policy role's fetches run under, as [web] configures
+// it. Without settings it is webfetch's guarded default.
+func (a *Agent) webPolicy(role string) webfetch.Policy {
+	if a.settings == nil {
+		return webfetch.Policy{}
+	}
+	p := a.settings.Web.ForRole(role)
+	return webfetch.Policy{
+		AllowDomains:         p.AllowDomains,
+		DenyDomains:          p.DenyDomains,
+		AllowPrivateNetworks: p.AllowPrivateNetworks != nil && *p.AllowPrivateNetworks,
+		RespectRobots:        p.RespectRobots != nil && *p.RespectRobots,
+		MaxBytes:             p.MaxBytes,
+		Timeout:              time.Duration(p.TimeoutSeconds) * time.Second,
+	}
+}
+
+// newWebArchive returns the archive fetched pages are kept in, or nil when
+// there are no settings or [web] disables it.
+func newWebArchive(settings *config.Settings) *webarchive.Archive {
+	if settings == nil || settings.Web.DisableArchive {
+		return nil
+	}
+	return webarchive.New(settings.WebArchiveDir())
+}
@@ -0,0 +1,277 @@
+package claude
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/fpt/klein-cli/internal/repository"
+	"github.com/fpt/klein-cli/pkg/message"
+)
+
+// ExportOptions describes the Claude Code session an export becomes.
+type ExportOptions struct {
+	SessionID string // "" generates one
+	Cwd       string // the project directory Claude Code will resume it in
+	Model     string // recorded on assistant replies; "" means "klein"
+}
+
+// outRecord is one line written to a Claude Code session file.
+type outRecord struct {
+	ParentUUID       *string   `json:"parentUuid"`
+	IsSidechain      bool      `json:"isSidechain"`
+	UserType         string    `json:"userType"`
+	Cwd              string    `json:"cwd,omitempty"`
+	SessionID        string    `json:"sessionId"`
+	Type             string    `json:"type"`
+	Message          any       `json:"message"`
+	IsCompactSummary bool      `json:"isCompactSummary,omitempty"`
+	UUID             string    `json:"uuid"`
+	Timestamp        time.Time `json:"timestamp"`
+}
+
+type userMessage struct {
+	Role    string `json:"role"`
+	Content any    `json:"content"` // string or []block
+}
+
+type assistantMessage struct {
+	ID         string  `json:"id"`
+	Type       string  `json:"type"`
+	Role       string  `json:"role"`
+	Model      string  `json:"model"`
+	Content    []block `json:"content"`
+	StopReason *string `json:"stop_reason"`
+	Usage      usage   `json:"usage"`
+}
+
+type usage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// Export writes a klein session as a Claude Code session file, one record per
+// content block the way Claude Code writes them, chained by parentUuid.
+//
+// Prompts, replies, tool calls and tool results (errors and images included)
+// carry over; tool call ids are kept, made safe for the Anthropic API where
+// another provider's ids are not. Thinking is left out: klein does not keep
+// thinking signatures in session files, and the API rejects unsigned thinking
+// in a replayed history. Situation and project-context system messages are
+// dropped, since Claude Code loads its own; a compaction summary becomes
+// Claude Code's compact summary. Unanswered tool calls are dropped so the
+// session resumes cleanly. Export returns the number of records written.
+func Export(w io.Writer, msgs []repository.MessageHistory, opts ExportOptions) (int, error) {
+	if opts.SessionID == "" {
+		opts.SessionID = NewSessionID()
+	}
+	if opts.Model == "" {
+		opts.Model = "klein"
+	}
+	msgs = pairHistory(msgs)
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	var parent *string
+	replyID := "" // API message id shared by consecutive assistant-side blocks
+	n := 0
+	emit := func(rec outRecord) error {
+		rec.ParentUUID, rec.UUID = parent, newUUID()
+		rec.SessionID, rec.Cwd, rec.UserType = opts.SessionID, opts.Cwd, "external"
+		if err := enc.Encode(rec); err != nil {
+			return fmt.Errorf("write Claude Code session: %w", err)
+		}
+		id := rec.UUID
+		parent = &id
+		n++
+		return nil
+	}
+	reply := func(m repository.MessageHistory, b block) error {
+		if replyID == "" {
+			replyID = "msg_" + strings.ReplaceAll(newUUID(), "-", "")
+		}
+		return emit(outRecord{Type: "assistant", Timestamp: m.Timestamp, Message: assistantMessage{
+			ID: replyID, Type: "message", Role: "assistant", Model: opts.Model, Content: []block{b},
+			Usage: usage{InputTokens: m.InputTokens, OutputTokens: m.OutputTokens},
+		}})
+	}
+
+	for _, m := range msgs {
+		var err error
+		switch m.Type {
+		case message.MessageTypeUser:
+			replyID = ""
+			if content := userContent(m.Content, m.Images); content != nil {
+				err = emit(outRecord{Type: "user", Timestamp: m.Timestamp, Message: userMessage{Role: "user", Content: content}})
+			}
+		case message.MessageTypeAssistant:
+			if strings.TrimSpace(m.Content) != "" {
+				err = reply(m, block{Type: "text", Text: m.Content})
+			}
+		case message.MessageTypeToolCall:
+			input := m.Args
+			if input == nil {
+				input = map[string]any{}
+			}
+			err = reply(m, block{Type: "tool_use", ID: toolUseID(m.ID), Name: m.ToolName, Input: input})
+		case message.MessageTypeToolResult:
+			replyID = ""
+			err = emit(outRecord{Type: "user", Timestamp: m.Timestamp, Message: userMessage{
+				Role: "user", Content: []block{toolResultBlock(m)},
+			}})
+		case message.MessageTypeSystem:
+			if m.Source == message.MessageSourceSummary || m.Source == message.MessageSourceCompactBoundary {
+				replyID = ""
+				err = emit(outRecord{Type: "user", Timestamp: m.Timestamp, IsCompactSummary: true,
+					Message: userMessage{Role: "user", Content: m.Content}})
+			}
+		}
+		if err != nil {
+			return n, err
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return n, fmt.Errorf("write Claude Code session: %w", err)
+	}
+	return n, nil
+}
+
+// ExportToProject writes msgs as a new session in workingDir's Claude Code
+// project directory, creating it if needed, so `claude --resume <id>` picks
+// it up. It returns the new session.
+func ExportToProject(workingDir string, msgs []repository.MessageHistory, model string) (Session, error) {
+	dir, err := projectPath(workingDir)
+	if err != nil {
+		return Session{}, err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return Session{}, fmt.Errorf("cannot create Claude project directory: %w", err)
+	}
+	abs, err := filepath.Abs(workingDir)
+	if err != nil {
+		return Session{}, fmt.Errorf("cannot resolve working directory: %w", err)
+	}
+	id := NewSessionID()
+	path := filepath.Join(dir, id+".jsonl")
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return Session{}, fmt.Errorf("cannot create Claude Code session: %w", err)
+	}
+	_, err = Export(f, msgs, ExportOptions{SessionID: id, Cwd: abs, Model: model})
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(path)
+		return Session{}, err
+	}
+	return ResolveSession(workingDir, path)
+}
+
+// userContent renders a prompt as a plain string, or as image blocks followed
+// by the text when images are attached. It returns nil for an empty prompt.
+func userContent(text string, images []string) any {
+	if len(images) == 0 {
+		if strings.TrimSpace(text) == "" {
+			return nil
+		}
+		return text
+	}
+	blocks := imageBlocks(images)
+	if strings.TrimSpace(text) != "" {
+		blocks = append(blocks, block{Type: "text", Text: text})
+	}
+	return blocks
+}
+
+func toolResultBlock(m repository.MessageHistory) block {
+	text, isErr := m.Result, false
+	if m.Error != "" {
+		text, isErr = m.Error, true
+	} else if text == "" {
+		text = m.Content
+	}
+	b := block{Type: "tool_result", ToolUseID: toolUseID(m.ID), IsError: isErr}
+	var content any = text
+	if len(m.Images) > 0 {
+		content = append(imageBlocks(m.Images), block{Type: "text", Text: text})
+	}
+	b.Content, _ = json.Marshal(content)
+	return b
+}
+
+func imageBlocks(images []string) []block {
+	blocks := make([]block, 0, len(images))
+	for _, img := range images {
+		blocks = append(blocks, block{Type: "image", Source: &imageSource{
+			Type: "base64", MediaType: imageMediaType(img), Data: img,
+		}})
+	}
+	return blocks
+}
+
+// imageMediaType sniffs a base64 image's type from its first bytes, the same
+// guess the Anthropic client makes; JPEG is the fallback.
+func imageMediaType(data string) string {
+	switch {
+	case strings.HasPrefix(data, "iVBORw0KGgo"):
+		return "image/png"
+	case strings.HasPrefix(data, "R0lGOD"):
+		return "image/gif"
+	case strings.HasPrefix(data, "UklGR"):
+		return "image/webp"
+	}
+	return "image/jpeg"
+}
+
+var unsafeToolIDChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// toolUseID makes a tool call id acceptable to the Anthropic API, which
+// allows only letters, digits, '_' and '-'. Calls and results map alike.
+func toolUseID(id string) string {
+	if id == "" {
+		return "toolu_unknown"
+	}
+	return unsafeToolIDChars.ReplaceAllString(id, "_")
+}
+
+// pairHistory is pairToolMessages for serialized messages.
+func pairHistory(msgs []repository.MessageHistory) []repository.MessageHistory {
+	calls, results := map[string]bool{}, map[string]bool{}
+	for _, m := range msgs {
+		switch m.Type {
+		case message.MessageTypeToolCall:
+			calls[m.ID] = true
+		case message.MessageTypeToolResult:
+			results[m.ID] = true
+		}
+	}
+	out := make([]repository.MessageHistory, 0, len(msgs))
+	for _, m := range msgs {
+		if (m.Type == message.MessageTypeToolCall && !results[m.ID]) ||
+			(m.Type == message.MessageTypeToolResult && !calls[m.ID]) {
+			continue
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// NewSessionID returns a random session id in Claude Code's format, a UUID.
+func NewSessionID() string { return newUUID() }
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,122 @@
+package claude
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fpt/klein-cli/internal/repository"
+	"github.com/fpt/klein-cli/pkg/message"
+)
+
+func TestExportRoundTrip(t *testing.T) {
+	ts := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	history := []repository.MessageHistory{
+		{ID: "s1", Type: message.MessageTypeSystem, Content: "# Project Context\n...", Timestamp: ts},
+		{ID: "u1", Type: message.MessageTypeUser, Content: "Why does this fail?", Images: []string{"iVBORw0KGgoAAA"}, Timestamp: ts},
+		{ID: "a1", Type: message.MessageTypeAssistant, Content: "Let me check.", Thinking: "unsigned", Timestamp: ts},
+		{ID: "call_abc.1", Type: message.MessageTypeToolCall, ToolName: "Read", Args: map[string]any{"file_path": "p.go"}, Timestamp: ts},
+		{ID: "call_2", Type: message.MessageTypeToolCall, ToolName: "TodoWrite", Timestamp: ts},
+		{ID: "call_abc.1", Type: message.MessageTypeToolResult, Result: "package p", Timestamp: ts},
+		{ID: "call_2", Type: message.MessageTypeToolResult, Error: "bad todo", Timestamp: ts},
+		{ID: "call_3", Type: message.MessageTypeToolCall, ToolName: "Bash", Args: map[string]any{"command": "make"}, Timestamp: ts},
+		{ID: "a2", Type: message.MessageTypeAssistant, Content: "Fixed.", Timestamp: ts},
+	}
+
+	var buf bytes.Buffer
+	n, err := Export(&buf, history, ExportOptions{SessionID: "sess", Cwd: "/work"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// user, text, 2 tool_use, 2 tool_result, text — no system, no dangling call_3
+	if n != 7 {
+		t.Fatalf("records = %d\n%s", n, buf.String())
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	var prev string
+	replyIDs := map[string]bool{}
+	for i, line := range lines {
+		var rec struct {
+			ParentUUID *string `json:"parentUuid"`
+			UUID       string  `json:"uuid"`
+			SessionID  string  `json:"sessionId"`
+			Cwd        string  `json:"cwd"`
+			Type       string  `json:"type"`
+			Message    struct {
+				ID string `json:"id"`
+			} `json:"message"`
+		}
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatal(err)
+		}
+		if rec.SessionID != "sess" || rec.Cwd != "/work" || rec.UUID == "" {
+			t.Fatalf("record %d = %s", i, line)
+		}
+		if (i == 0) != (rec.ParentUUID == nil) || (i > 0 && *rec.ParentUUID != prev) {
+			t.Fatalf("record %d is not chained to the one before: %s", i, line)
+		}
+		prev = rec.UUID
+		if rec.Type == "assistant" && i < 4 {
+			replyIDs[rec.Message.ID] = true
+		}
+	}
+	if len(replyIDs) != 1 {
+		t.Errorf("text and tool calls of one reply should share a message id, got %v", replyIDs)
+	}
+	if strings.Contains(buf.String(), "unsigned") || strings.Contains(buf.String(), "Project Context") {
+		t.Errorf("thinking and system messages must not be exported:\n%s", buf.String())
+	}
+	if !strings.Contains(buf.String(), `"id":"call_abc_1"`) || !strings.Contains(buf.String(), `"tool_use_id":"call_abc_1"`) {
+		t.Errorf("tool ids should be sanitized consistently:\n%s", buf.String())
+	}
+	if !strings.Contains(buf.String(), `"input":{}`) {
+		t.Errorf("a tool call without arguments still needs an input object:\n%s", buf.String())
+	}
+
+	// Claude Code → klein brings the same conversation back.
+	path := writeSession(t, t.TempDir(), "sess", lines...)
+	msgs, err := ImportMessages(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, m := range msgs {
+		got = append(got, m.Type().String()+":"+m.Content())
+	}
+	want := []string{
+		"user:Why does this fail?", "assistant:Let me check.",
+		"tool_call:Calling tool: Read with args: map[file_path:p.go]",
+		"tool_call:Calling tool: TodoWrite with args: map[]",
+		"tool_result:package p", "tool_result:Error: bad todo", "assistant:Fixed.",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("round trip:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if len(msgs[0].Images()) != 1 {
+		t.Errorf("image lost in the round trip")
+	}
+}
+
+func TestExportToProject(t *testing.T) {
+	home, work := t.TempDir(), t.TempDir()
+	t.Setenv("HOME", home)
+	s, err := ExportToProject(work, []repository.MessageHistory{
+		{ID: "u1", Type: message.MessageTypeUser, Content: "hello"},
+	}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Title != "hello" || len(s.ID) != 36 {
+		t.Fatalf("session = %+v", s)
+	}
+	if _, err := os.Stat(s.Path); err != nil {
+		t.Fatal(err)
+	}
+	if sessions, _ := ListSessions(work); len(sessions) != 1 || sessions[0].ID != s.ID {
+		t.Fatalf("exported session not listed: %+v", sessions)
+	}
+}
@@ -3,26 +3,35 @@ package claude
 import (
 	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
-
-	"github.com/fpt/klein-cli/pkg/message"
+	"time"
+	"unicode/utf8"
 )
 
 // EncodePath converts an absolute path to the Claude Code project directory
-// encoding: leading slash dropped and all slashes replaced with hyphens.
-// e.g. /Users/foo/bar → -Users-foo-bar
+// encoding: every character other than an ASCII letter or digit becomes a
+// hyphen, so the leading slash turns into the leading hyphen.
+// e.g. /Users/foo/my.app → -Users-foo-my-app
 func EncodePath(absPath string) string {
-	return strings.ReplaceAll(absPath, "/", "-")
+	var b strings.Builder
+	for _, r := range absPath {
+		if r < utf8.RuneSelf && (r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('-')
+		}
+	}
+	return b.String()
 }
 
-// ClaudeProjectDir returns ~/.claude/projects/<encoded-path> for the given
-// working directory. Returns an error if the home directory cannot be resolved
-// or the resulting directory does not exist.
-func ClaudeProjectDir(workingDir string) (string, error) {
+// projectPath returns ~/.claude/projects/<encoded-path> for workingDir,
+// whether or not it exists.
+func projectPath(workingDir string) (string, error) {
 	home, err := os.UserHomeDir()
 	if err != nil {
 		return "", fmt.Errorf("cannot determine home directory: %w", err)
@@ -31,32 +40,48 @@ func ClaudeProjectDir(workingDir string) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("cannot resolve working directory: %w", err)
 	}
-	dir := filepath.Join(home, ".claude", "projects", EncodePath(abs))
+	return filepath.Join(home, ".claude", "projects", EncodePath(abs)), nil
+}
+
+// ClaudeProjectDir returns ~/.claude/projects/<encoded-path> for the given
+// working directory. Returns an error if the home directory cannot be resolved
+// or the resulting directory does not exist.
+func ClaudeProjectDir(workingDir string) (string, error) {
+	dir, err := projectPath(workingDir)
+	if err != nil {
+		return "", err
+	}
 	if _, err := os.Stat(dir); err != nil {
 		return "", fmt.Errorf("Claude project directory not found: %s", dir)
 	}
 	return dir, nil
 }
 
-// FindLatestSession returns the path to the most-recently modified *.jsonl file
-// inside the Claude project directory for workingDir, or an empty string when
-// none exist. A non-nil error is returned only for unexpected I/O failures.
-func FindLatestSession(workingDir string) (string, error) {
+// Session is one Claude Code session file of a project.
+type Session struct {
+	ID       string // file name without .jsonl; Claude Code's session id
+	Path     string
+	Modified time.Time
+	Size     int64
+	Title    string // Claude Code's summary of the session, else its first prompt
+}
+
+// titleRunes caps a title taken from the first prompt.
+const titleRunes = 60
+
+// ListSessions returns the Claude Code sessions of workingDir, most recently
+// modified first. A project Claude Code has never run in has none.
+func ListSessions(workingDir string) ([]Session, error) {
 	projectDir, err := ClaudeProjectDir(workingDir)
 	if err != nil {
-		return "", nil // directory absent → no history to offer
+		return nil, nil // directory absent → no history to offer
 	}
-
 	entries, err := os.ReadDir(projectDir)
 	if err != nil {
-		return "", fmt.Errorf("cannot read Claude project directory: %w", err)
+		return nil, fmt.Errorf("cannot read Claude project directory: %w", err)
 	}
 
-	type candidate struct {
-		path    string
-		modTime int64
-	}
-	var candidates []candidate
+	var sessions []Session
 	for _, e := range entries {
 		if e.IsDir() || !strings.HasSuffix(e.Name(), ".jsonl") {
 			continue
@@ -65,114 +90,113 @@ func FindLatestSession(workingDir string) (string, error) {
 		if err != nil {
 			continue
 		}
-		candidates = append(candidates, candidate{
-			path:    filepath.Join(projectDir, e.Name()),
-			modTime: info.ModTime().Unix(),
+		path := filepath.Join(projectDir, e.Name())
+		sessions = append(sessions, Session{
+			ID:       strings.TrimSuffix(e.Name(), ".jsonl"),
+			Path:     path,
+			Modified: info.ModTime(),
+			Size:     info.Size(),
+			Title:    sessionTitle(path),
 		})
 	}
-	if len(candidates) == 0 {
-		return "", nil
-	}
-	sort.Slice(candidates, func(i, j int) bool {
-		return candidates[i].modTime > candidates[j].modTime
+	sort.Slice(sessions, func(i, j int) bool {
+		if !sessions[i].Modified.Equal(sessions[j].Modified) {
+			return sessions[i].Modified.After(sessions[j].Modified)
+		}
+		return sessions[i].ID > sessions[j].ID
 	})
-	return candidates[0].path, nil
+	return sessions, nil
 }
 
-// jsonlRecord is the minimal shape we need to parse from each JSONL line.
-type jsonlRecord struct {
-	Type        string `json:"type"`
-	IsSidechain bool   `json:"isSidechain"`
-	IsMeta      bool   `json:"isMeta"`
-	Message     struct {
-		Role    string          `json:"role"`
-		Content json.RawMessage `json:"content"`
-	} `json:"message"`
+// FindLatestSession returns the path to the most-recently modified *.jsonl file
+// inside the Claude project directory for workingDir, or an empty string when
+// none exist. A non-nil error is returned only for unexpected I/O failures.
+func FindLatestSession(workingDir string) (string, error) {
+	sessions, err := ListSessions(workingDir)
+	if err != nil || len(sessions) == 0 {
+		return "", err
+	}
+	return sessions[0].Path, nil
 }
 
-// contentBlock represents one element of message.content when it is an array.
-type contentBlock struct {
-	Type string `json:"type"`
-	Text string `json:"text"`
-}
+// ErrSessionNotFound is returned when a session reference matches nothing.
+var ErrSessionNotFound = errors.New("no such Claude Code session")
 
-// extractText returns the plain-text content from a message.content field
-// which may be either a plain string or an array of content blocks.
-// Only "text" blocks are included; tool_use, tool_result, thinking, image
-// blocks are discarded.
-func extractText(raw json.RawMessage) string {
-	if len(raw) == 0 {
-		return ""
-	}
-	// Try plain string first.
-	var s string
-	if err := json.Unmarshal(raw, &s); err == nil {
-		return strings.TrimSpace(s)
+// ResolveSession finds a session by path to a .jsonl file, by id, or by a
+// unique id prefix among workingDir's sessions.
+func ResolveSession(workingDir, ref string) (Session, error) {
+	ref = strings.TrimSpace(ref)
+	if strings.HasSuffix(ref, ".jsonl") {
+		if info, err := os.Stat(ref); err == nil && !info.IsDir() {
+			return Session{
+				ID:       strings.TrimSuffix(filepath.Base(ref), ".jsonl"),
+				Path:     ref,
+				Modified: info.ModTime(),
+				Size:     info.Size(),
+				Title:    sessionTitle(ref),
+			}, nil
+		}
 	}
-	// Try array of content blocks.
-	var blocks []contentBlock
-	if err := json.Unmarshal(raw, &blocks); err != nil {
-		return ""
+	sessions, err := ListSessions(workingDir)
+	if err != nil {
+		return Session{}, err
 	}
-	var parts []string
-	for _, b := range blocks {
-		if b.Type == "text" && b.Text != "" {
-			parts = append(parts, strings.TrimSpace(b.Text))
+	var matches []Session
+	for _, s := range sessions {
+		if s.ID == ref {
+			return s, nil
+		}
+		if ref != "" && strings.HasPrefix(s.ID, ref) {
+			matches = append(matches, s)
 		}
 	}
-	return strings.Join(parts, "\n")
+	switch len(matches) {
+	case 0:
+		return Session{}, fmt.Errorf("%w: %q", ErrSessionNotFound, ref)
+	case 1:
+		return matches[0], nil
+	default:
+		return Session{}, fmt.Errorf("%q matches %d Claude Code sessions; use more of the id", ref, len(matches))
+	}
 }
 
-// ImportMessages reads a Claude Code *.jsonl session file and returns the
-// conversation as klein message.Message values. Only user and assistant turns
-// with extractable text are included; sidechain, meta, and non-text records
-// are skipped. The messages arrive in file order (oldest first).
-func ImportMessages(jsonlPath string) ([]message.Message, error) {
-	f, err := os.Open(jsonlPath)
+// sessionTitle reads just enough of a session file to name it: a "summary"
+// record if one comes first, else the first real prompt.
+func sessionTitle(path string) string {
+	f, err := os.Open(path)
 	if err != nil {
-		return nil, fmt.Errorf("cannot open session file: %w", err)
+		return ""
 	}
 	defer f.Close()
-
-	var msgs []message.Message
 	scanner := bufio.NewScanner(f)
-	scanner.Buffer(make([]byte, 4*1024*1024), 4*1024*1024) // 4 MB line buffer
+	scanner.Buffer(make([]byte, 64*1024), maxLine)
 	for scanner.Scan() {
-		line := scanner.Bytes()
-		if len(line) == 0 {
-			continue
-		}
-		var rec jsonlRecord
-		if err := json.Unmarshal(line, &rec); err != nil {
-			continue // skip malformed lines
-		}
-		if rec.IsSidechain || rec.IsMeta {
+		var rec record
+		if json.Unmarshal(scanner.Bytes(), &rec) != nil {
 			continue
 		}
-		if rec.Type != "user" && rec.Type != "assistant" {
-			continue
+		if rec.Type == "summary" && rec.Summary != "" {
+			return clip(rec.Summary)
 		}
-
-		text := extractText(rec.Message.Content)
-		if text == "" {
+		if rec.Type != "user" || rec.IsSidechain || rec.IsMeta || rec.IsCompactSummary {
 			continue
 		}
-
-		var msgType message.MessageType
-		switch rec.Type {
-		case "user":
-			msgType = message.MessageTypeUser
-		case "assistant":
-			msgType = message.MessageTypeAssistant
-		default:
-			continue
+		text, _, results := parseUserContent(rec.Message.Content)
+		// Slash-command echoes and caveats are wrapped in tags; they make
+		// poor titles.
+		if len(results) == 0 && text != "" && !strings.HasPrefix(text, "<") {
+			return clip(text)
 		}
-		msgs = append(msgs, message.NewChatMessage(msgType, text))
 	}
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error reading session file: %w", err)
+	return ""
+}
+
+func clip(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if utf8.RuneCountInString(s) <= titleRunes {
+		return s
 	}
-	return msgs, nil
+	return string([]rune(s)[:titleRunes-1]) + "…"
 }
 
 // FindContextFile looks for AGENTS.md then CLAUDE.md in workingDir and returns
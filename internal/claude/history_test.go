@@ -0,0 +1,65 @@
+package claude
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEncodePath(t *testing.T) {
+	if got := EncodePath("/Users/foo/github.com/my_app"); got != "-Users-foo-github-com-my-app" {
+		t.Fatalf("EncodePath = %q", got)
+	}
+}
+
+func TestListAndResolveSessions(t *testing.T) {
+	home, work := t.TempDir(), t.TempDir()
+	t.Setenv("HOME", home)
+	if sessions, err := ListSessions(work); err != nil || len(sessions) != 0 {
+		t.Fatalf("a project Claude Code never ran in: %v, %v", sessions, err)
+	}
+
+	dir, err := projectPath(work)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	older := writeSession(t, dir, "aaaa1111-0000-4000-8000-000000000000", sampleSession...)
+	newer := writeSession(t, dir, "aaaa2222-0000-4000-8000-000000000000",
+		`{"type":"user","message":{"role":"user","content":"<command-name>/clear</command-name>"}}`,
+		`{"type":"user","message":{"role":"user","content":"Add   a retry\nto the fetcher"}}`)
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(older, past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	sessions, err := ListSessions(work)
+	if err != nil || len(sessions) != 2 {
+		t.Fatalf("ListSessions = %v, %v", sessions, err)
+	}
+	if sessions[0].Path != newer || sessions[0].Title != "Add a retry to the fetcher" ||
+		sessions[1].Title != "Fix the flaky parser test" {
+		t.Fatalf("sessions = %+v", sessions)
+	}
+	if latest, _ := FindLatestSession(work); latest != newer {
+		t.Fatalf("latest = %s", latest)
+	}
+
+	if s, err := ResolveSession(work, "aaaa1"); err != nil || s.Path != older {
+		t.Fatalf("resolve by prefix = %+v, %v", s, err)
+	}
+	if _, err := ResolveSession(work, "aaaa"); err == nil {
+		t.Fatal("an ambiguous prefix should not resolve")
+	}
+	if _, err := ResolveSession(work, "bbbb"); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("unknown id: %v", err)
+	}
+	elsewhere := writeSession(t, t.TempDir(), "other", sampleSession...)
+	if s, err := ResolveSession(work, elsewhere); err != nil || s.ID != "other" || filepath.Clean(s.Path) != elsewhere {
+		t.Fatalf("resolve by path = %+v, %v", s, err)
+	}
+}
@@ -0,0 +1,307 @@
+package claude
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fpt/klein-cli/pkg/message"
+)
+
+// maxLine bounds one JSONL record; tool results with images run to megabytes.
+const maxLine = 64 * 1024 * 1024
+
+// thinkingSignatureKey is the metadata key the Anthropic client reads a
+// thinking block's signature from when it replays history.
+const thinkingSignatureKey = "anthropic_thinking_signature"
+
+// record is one line of a Claude Code session file.
+type record struct {
+	Type             string    `json:"type"`
+	UUID             string    `json:"uuid"`
+	Timestamp        time.Time `json:"timestamp"`
+	IsSidechain      bool      `json:"isSidechain"`
+	IsMeta           bool      `json:"isMeta"`
+	IsCompactSummary bool      `json:"isCompactSummary"`
+	Summary          string    `json:"summary"` // type "summary"
+	Message          struct {
+		ID      string          `json:"id"` // assistant API message id, shared by its blocks
+		Role    string          `json:"role"`
+		Content json.RawMessage `json:"content"`
+	} `json:"message"`
+}
+
+// block is one element of message.content when it is an array.
+type block struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	Thinking  string          `json:"thinking,omitempty"`
+	Signature string          `json:"signature,omitempty"`
+	ID        string          `json:"id,omitempty"`          // tool_use
+	Name      string          `json:"name,omitempty"`        // tool_use
+	Input     map[string]any  `json:"input,omitzero"`        // tool_use; omitzero still writes an empty {}
+	ToolUseID string          `json:"tool_use_id,omitempty"` // tool_result
+	Content   json.RawMessage `json:"content,omitempty"`     // tool_result: string or blocks
+	IsError   bool            `json:"is_error,omitempty"`
+	Source    *imageSource    `json:"source,omitempty"` // image
+}
+
+type imageSource struct {
+	Type      string `json:"type"` // base64; url sources are not imported
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+}
+
+// toolResult is a tool_result block of a user record.
+type toolResult struct {
+	callID string
+	text   string
+	images []string
+	isErr  bool
+}
+
+// NormalizeToolName maps a Claude Code tool name onto klein's. Most built-in
+// tools share their names; MCP tools lose Claude Code's mcp__<server>__
+// prefix, since klein registers them under the server's own tool name.
+func NormalizeToolName(name string) message.ToolName {
+	if rest, ok := strings.CutPrefix(name, "mcp__"); ok {
+		if _, tool, ok := strings.Cut(rest, "__"); ok && tool != "" {
+			return message.ToolName(tool)
+		}
+	}
+	switch name {
+	case "Agent":
+		return "Task" // Claude Code's later name for its subagent tool
+	}
+	return message.ToolName(name)
+}
+
+// ImportMessages reads a Claude Code *.jsonl session file and returns the
+// conversation as klein messages, oldest first:
+//
+//   - user prompts, with any attached images
+//   - assistant text, with the thinking that preceded it (and its signature)
+//   - tool_use blocks as tool calls keeping their ids, tool names normalized
+//   - tool_result blocks as tool results, errors and images included
+//
+// Sidechain (subagent) and meta records are skipped. A compaction summary
+// replaces everything before it, as it did in Claude Code's own context.
+// Tool calls without a result, as left by an interrupted turn, are dropped
+// together with stray results, so every backend accepts the history.
+func ImportMessages(jsonlPath string) ([]message.Message, error) {
+	f, err := os.Open(jsonlPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open session file: %w", err)
+	}
+	defer f.Close()
+
+	var imp importer
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1024*1024), maxLine)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var rec record
+		if err := json.Unmarshal(line, &rec); err != nil {
+			continue // skip malformed lines
+		}
+		if rec.IsSidechain || rec.IsMeta {
+			continue
+		}
+		switch rec.Type {
+		case "user":
+			imp.user(rec)
+		case "assistant":
+			imp.assistant(rec)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading session file: %w", err)
+	}
+	return pairToolMessages(imp.msgs), nil
+}
+
+// importer accumulates messages. Claude Code writes each content block of an
+// assistant reply as its own record, so thinking arrives a record before the
+// text or tool call it belongs to.
+type importer struct {
+	msgs        []message.Message
+	assistantID string // API message id of the reply being read
+	thinking    []string
+	signature   string
+}
+
+func (imp *importer) user(rec record) {
+	imp.assistantID, imp.thinking, imp.signature = "", nil, ""
+	text, images, results := parseUserContent(rec.Message.Content)
+	if rec.IsCompactSummary {
+		imp.msgs = []message.Message{message.NewCompactBoundaryMessage(text)}
+		return
+	}
+	for _, r := range results {
+		if r.isErr {
+			imp.msgs = append(imp.msgs, message.NewToolResultMessageWithImages(r.callID, "", r.images, r.text))
+		} else {
+			imp.msgs = append(imp.msgs, message.NewToolResultMessageWithImages(r.callID, r.text, r.images, ""))
+		}
+	}
+	switch {
+	case len(images) > 0:
+		imp.msgs = append(imp.msgs, message.NewChatMessageWithImages(message.MessageTypeUser, text, images))
+	case text != "":
+		imp.msgs = append(imp.msgs, message.NewChatMessage(message.MessageTypeUser, text))
+	}
+}
+
+func (imp *importer) assistant(rec record) {
+	if rec.Message.ID == "" || rec.Message.ID != imp.assistantID {
+		imp.assistantID, imp.thinking, imp.signature = rec.Message.ID, nil, ""
+	}
+	blocks, ok := parseBlocks(rec.Message.Content)
+	if !ok {
+		return
+	}
+	for _, b := range blocks {
+		switch b.Type {
+		case "thinking":
+			if b.Thinking != "" {
+				imp.thinking = append(imp.thinking, b.Thinking)
+				imp.signature = b.Signature
+			}
+		case "text":
+			if text := strings.TrimSpace(b.Text); text != "" {
+				msg := message.NewChatMessage(message.MessageTypeAssistant, text)
+				imp.attachThinking(msg)
+				imp.msgs = append(imp.msgs, msg)
+			}
+		case "tool_use":
+			if b.ID == "" || b.Name == "" {
+				continue
+			}
+			args := message.ToolArgumentValues(b.Input)
+			if args == nil {
+				args = message.ToolArgumentValues{}
+			}
+			call := message.NewToolCallMessageWithID(b.ID, NormalizeToolName(b.Name), args, rec.Timestamp)
+			imp.attachThinking(&call.ChatMessage)
+			imp.msgs = append(imp.msgs, call)
+		}
+	}
+}
+
+// attachThinking gives msg the thinking read since the last text or tool call
+// of this reply.
+func (imp *importer) attachThinking(msg *message.ChatMessage) {
+	if len(imp.thinking) == 0 {
+		return
+	}
+	msg.SetThinking(strings.Join(imp.thinking, "\n\n"))
+	if imp.signature != "" {
+		msg.SetMetadata(thinkingSignatureKey, imp.signature)
+	}
+	imp.thinking, imp.signature = nil, ""
+}
+
+// parseBlocks decodes message.content as an array of blocks; a plain string
+// becomes one text block.
+func parseBlocks(raw json.RawMessage) ([]block, bool) {
+	if len(raw) == 0 {
+		return nil, false
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return []block{{Type: "text", Text: s}}, true
+	}
+	var blocks []block
+	if err := json.Unmarshal(raw, &blocks); err != nil {
+		return nil, false
+	}
+	return blocks, true
+}
+
+// parseUserContent splits a user record's content into its prompt text,
+// attached images and tool results.
+func parseUserContent(raw json.RawMessage) (string, []string, []toolResult) {
+	blocks, ok := parseBlocks(raw)
+	if !ok {
+		return "", nil, nil
+	}
+	var texts, images []string
+	var results []toolResult
+	for _, b := range blocks {
+		switch b.Type {
+		case "text":
+			if t := strings.TrimSpace(b.Text); t != "" {
+				texts = append(texts, t)
+			}
+		case "image":
+			if img := b.base64Image(); img != "" {
+				images = append(images, img)
+			}
+		case "tool_result":
+			if b.ToolUseID == "" {
+				continue
+			}
+			r := toolResult{callID: b.ToolUseID, isErr: b.IsError}
+			if inner, ok := parseBlocks(b.Content); ok {
+				var parts []string
+				for _, ib := range inner {
+					switch ib.Type {
+					case "text":
+						parts = append(parts, ib.Text)
+					case "image":
+						if img := ib.base64Image(); img != "" {
+							r.images = append(r.images, img)
+						}
+					}
+				}
+				r.text = strings.Join(parts, "\n")
+			}
+			results = append(results, r)
+		}
+	}
+	return strings.Join(texts, "\n"), images, results
+}
+
+// base64Image returns the image's base64 data, the form klein messages keep
+// images in, or "" for an image given by URL.
+func (b block) base64Image() string {
+	if b.Source == nil || b.Source.Type != "base64" {
+		return ""
+	}
+	return b.Source.Data
+}
+
+// pairToolMessages drops tool calls that never got a result and results
+// whose call is missing. Providers reject either in a replayed history.
+func pairToolMessages(msgs []message.Message) []message.Message {
+	calls, results := map[string]bool{}, map[string]bool{}
+	for _, m := range msgs {
+		switch m.Type() {
+		case message.MessageTypeToolCall:
+			calls[m.ID()] = true
+		case message.MessageTypeToolResult:
+			results[m.ID()] = true
+		}
+	}
+	out := msgs[:0]
+	for _, m := range msgs {
+		switch m.Type() {
+		case message.MessageTypeToolCall:
+			if !results[m.ID()] {
+				continue
+			}
+		case message.MessageTypeToolResult:
+			if !calls[m.ID()] {
+				continue
+			}
+		}
+		out = append(out, m)
+	}
+	return out
+}
@@ -0,0 +1,112 @@
+package claude
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fpt/klein-cli/pkg/message"
+)
+
+// writeSession writes lines as a session file in dir and returns its path.
+func writeSession(t *testing.T, dir, id string, lines ...string) string {
+	t.Helper()
+	path := filepath.Join(dir, id+".jsonl")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// A session as Claude Code writes it: one record per assistant content block,
+// sharing the API message id.
+var sampleSession = []string{
+	`{"type":"summary","summary":"Fix the flaky parser test","leafUuid":"x"}`,
+	`{"type":"user","isMeta":true,"message":{"role":"user","content":"<local-command-caveat>ignore</local-command-caveat>"},"uuid":"u0","timestamp":"2026-10-01T09:00:00.000Z"}`,
+	`{"type":"user","message":{"role":"user","content":[{"type":"image","source":{"type":"base64","media_type":"image/png","data":"iVBORw0KGgoAAA"}},{"type":"text","text":"Why does this fail?"}]},"uuid":"u1","timestamp":"2026-10-01T09:00:01.000Z"}`,
+	`{"type":"assistant","message":{"id":"msg_1","role":"assistant","content":[{"type":"thinking","thinking":"Look at the test first.","signature":"sig-1"}]},"uuid":"a1","timestamp":"2026-10-01T09:00:02.000Z"}`,
+	`{"type":"assistant","message":{"id":"msg_1","role":"assistant","content":[{"type":"text","text":"Let me check."}]},"uuid":"a2","timestamp":"2026-10-01T09:00:03.000Z"}`,
+	`{"type":"assistant","message":{"id":"msg_1","role":"assistant","content":[{"type":"tool_use","id":"toolu_1","name":"Read","input":{"file_path":"parser_test.go"}}]},"uuid":"a3","timestamp":"2026-10-01T09:00:04.000Z"}`,
+	`{"type":"assistant","message":{"id":"msg_1","role":"assistant","content":[{"type":"tool_use","id":"toolu_2","name":"mcp__godev__search","input":{"q":"Parse"}}]},"uuid":"a4","timestamp":"2026-10-01T09:00:05.000Z"}`,
+	`{"type":"user","message":{"role":"user","content":[{"tool_use_id":"toolu_1","type":"tool_result","content":"package parser"}]},"uuid":"u2","timestamp":"2026-10-01T09:00:06.000Z"}`,
+	`{"type":"user","message":{"role":"user","content":[{"tool_use_id":"toolu_2","type":"tool_result","content":[{"type":"text","text":"no such tool"}],"is_error":true}]},"uuid":"u3","timestamp":"2026-10-01T09:00:07.000Z"}`,
+	`{"type":"assistant","isSidechain":true,"message":{"id":"msg_side","role":"assistant","content":[{"type":"text","text":"subagent chatter"}]},"uuid":"s1"}`,
+	`not json`,
+	`{"type":"assistant","message":{"id":"msg_2","role":"assistant","content":[{"type":"tool_use","id":"toolu_3","name":"Agent","input":{}}]},"uuid":"a5","timestamp":"2026-10-01T09:00:08.000Z"}`,
+	`{"type":"user","message":{"role":"user","content":"[Request interrupted by user]"},"uuid":"u4","timestamp":"2026-10-01T09:00:09.000Z"}`,
+}
+
+func TestImportMessages(t *testing.T) {
+	path := writeSession(t, t.TempDir(), "s1", sampleSession...)
+	msgs, err := ImportMessages(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kinds []string
+	for _, m := range msgs {
+		kinds = append(kinds, m.Type().String())
+	}
+	// The unanswered Agent call (toolu_3) is dropped.
+	want := "user assistant tool_call tool_call tool_result tool_result user"
+	if got := strings.Join(kinds, " "); got != want {
+		t.Fatalf("message types = %q, want %q", got, want)
+	}
+
+	if msgs[0].Content() != "Why does this fail?" || len(msgs[0].Images()) != 1 {
+		t.Errorf("prompt = %q with %d images", msgs[0].Content(), len(msgs[0].Images()))
+	}
+	reply := msgs[1]
+	if reply.Content() != "Let me check." || reply.Thinking() != "Look at the test first." ||
+		reply.Metadata()[thinkingSignatureKey] != "sig-1" {
+		t.Errorf("reply = %q, thinking %q, metadata %v", reply.Content(), reply.Thinking(), reply.Metadata())
+	}
+	read, ok := msgs[2].(*message.ToolCallMessage)
+	if !ok || read.ID() != "toolu_1" || read.ToolName() != "Read" || read.ToolArguments()["file_path"] != "parser_test.go" {
+		t.Errorf("first call = %v", msgs[2])
+	}
+	if read.Thinking() != "" {
+		t.Errorf("thinking attached twice: %q", read.Thinking())
+	}
+	if search := msgs[3].(*message.ToolCallMessage); search.ToolName() != "search" {
+		t.Errorf("MCP tool name = %q, want the server's own name", search.ToolName())
+	}
+	if res := msgs[4].(*message.ToolResultMessage); res.ID() != "toolu_1" || res.Result != "package parser" {
+		t.Errorf("first result = %+v", res)
+	}
+	if res := msgs[5].(*message.ToolResultMessage); res.ID() != "toolu_2" || res.Error != "no such tool" {
+		t.Errorf("error result = %+v", res)
+	}
+}
+
+func TestImportMessagesStartsAtCompactSummary(t *testing.T) {
+	path := writeSession(t, t.TempDir(), "s2",
+		`{"type":"user","message":{"role":"user","content":"old prompt"}}`,
+		`{"type":"assistant","message":{"id":"m1","role":"assistant","content":[{"type":"text","text":"old answer"}]}}`,
+		`{"type":"user","isCompactSummary":true,"message":{"role":"user","content":"We fixed the parser."}}`,
+		`{"type":"user","message":{"role":"user","content":"now the lexer"}}`,
+	)
+	msgs, err := ImportMessages(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 2 || msgs[0].Source() != message.MessageSourceCompactBoundary ||
+		!strings.Contains(msgs[0].Content(), "We fixed the parser.") || msgs[1].Content() != "now the lexer" {
+		t.Fatalf("messages = %v", msgs)
+	}
+}
+
+func TestNormalizeToolName(t *testing.T) {
+	for in, want := range map[string]message.ToolName{
+		"Bash":                "Bash",
+		"Agent":               "Task",
+		"mcp__github__get_pr": "get_pr",
+		"mcp__broken":         "mcp__broken",
+		"WebFetch":            "WebFetch",
+		"mcp__srv__a__b_tool": "a__b_tool",
+	} {
+		if got := NormalizeToolName(in); got != want {
+			t.Errorf("NormalizeToolName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,330 @@
+package codeindex
+
+import (
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// languages maps file extensions to the language names the index reports.
+var languages = map[string]string{
+	".go":    langGo,
+	".py":    "python",
+	".js":    "javascript",
+	".jsx":   "javascript",
+	".mjs":   "javascript",
+	".cjs":   "javascript",
+	".ts":    "typescript",
+	".tsx":   "typescript",
+	".rs":    "rust",
+	".java":  "java",
+	".kt":    "kotlin",
+	".c":     "c",
+	".h":     "c",
+	".cc":    "cpp",
+	".cpp":   "cpp",
+	".hpp":   "cpp",
+	".rb":    "ruby",
+	".swift": "swift",
+}
+
+// languageOf returns the language of a file name, or "" when it is not source
+// the index understands.
+func languageOf(name string) string {
+	return languages[strings.ToLower(filepath.Ext(name))]
+}
+
+// parseFile indexes one file's source.
+func parseFile(rel, lang string, src []byte) *File {
+	f := &File{Path: rel, Lang: lang}
+	if lang == langGo {
+		parseGo(f, src)
+		return f
+	}
+	parseTags(f, src)
+	return f
+}
+
+// tagRule is one ctags-style definition pattern: the first submatch is the
+// name.
+type tagRule struct {
+	re   *regexp.Regexp
+	kind Kind
+	// scope marks a definition that opens a container (a class, impl, module):
+	// later definitions nested inside it get it as their Container.
+	scope bool
+}
+
+func rule(kind Kind, scope bool, pattern string) tagRule {
+	return tagRule{re: regexp.MustCompile(pattern), kind: kind, scope: scope}
+}
+
+// Per-language definition rules, tried in order. A KindMethod rule only
+// matches inside a container; a KindFunc match inside one is reported as a
+// method (Python's def, Ruby's def).
+var (
+	pythonRules = []tagRule{
+		rule(KindClass, true, `^\s*class\s+([A-Za-z_]\w*)`),
+		rule(KindFunc, false, `^\s*(?:async\s+)?def\s+([A-Za-z_]\w*)`),
+	}
+	jsRules = []tagRule{
+		rule(KindClass, true, `^\s*(?:export\s+)?(?:default\s+)?(?:abstract\s+)?class\s+([A-Za-z_$][\w$]*)`),
+		rule(KindInterface, false, `^\s*(?:export\s+)?interface\s+([A-Za-z_$][\w$]*)`),
+		rule(KindType, false, `^\s*(?:export\s+)?(?:type|enum)\s+([A-Za-z_$][\w$]*)`),
+		rule(KindFunc, false, `^\s*(?:export\s+)?(?:default\s+)?(?:async\s+)?function\s*\*?\s*([A-Za-z_$][\w$]*)`),
+		rule(KindFunc, false, `^\s*(?:export\s+)?(?:const|let|var)\s+([A-Za-z_$][\w$]*)\s*(?::[^=]+)?=\s*(?:async\s+)?(?:\([^)]*\)|[A-Za-z_$][\w$]*)\s*(?::[^=]+)?=>`),
+		rule(KindMethod, false, `^\s+(?:(?:public|private|protected|static|async|readonly|override)\s+)*([A-Za-z_$][\w$]*)\s*\([^)]*\)\s*(?::[^{]+)?\{`),
+	}
+	rustRules = []tagRule{
+		rule(KindType, true, `^\s*impl(?:<[^>]*>)?\s+(?:[\w:]+(?:<[^>]*>)?\s+for\s+)?([A-Za-z_]\w*)`),
+		rule(KindInterface, true, `^\s*(?:pub(?:\([^)]*\))?\s+)?trait\s+([A-Za-z_]\w*)`),
+		rule(KindType, false, `^\s*(?:pub(?:\([^)]*\))?\s+)?(?:struct|enum|union|type)\s+([A-Za-z_]\w*)`),
+		rule(KindModule, false, `^\s*(?:pub(?:\([^)]*\))?\s+)?mod\s+([A-Za-z_]\w*)`),
+		rule(KindConst, false, `^\s*(?:pub(?:\([^)]*\))?\s+)?(?:const|static)\s+([A-Z_][A-Z0-9_]*)`),
+		rule(KindFunc, false, `^\s*(?:pub(?:\([^)]*\))?\s+)?(?:(?:async|const|unsafe|extern\s+"[^"]*")\s+)*fn\s+([A-Za-z_]\w*)`),
+	}
+	javaRules = []tagRule{
+		rule(KindClass, true, `^\s*(?:(?:public|private|protected|abstract|final|static|sealed|open|data|inner)\s+)*(?:class|object|record|enum)\s+([A-Za-z_]\w*)`),
+		rule(KindInterface, true, `^\s*(?:(?:public|private|protected|sealed|fun)\s+)*interface\s+([A-Za-z_]\w*)`),
+		rule(KindFunc, false, `^\s*(?:(?:public|private|protected|internal|override|suspend|inline|open)\s+)*fun\s+(?:<[^>]*>\s*)?(?:[\w.]+\.)?([A-Za-z_]\w*)\s*\(`),
+		rule(KindMethod, false, `^\s+(?:(?:public|private|protected|static|final|abstract|synchronized|native|default)\s+)*(?:<[^>]*>\s+)?[\w.<>\[\], ?]+\s+([A-Za-z_]\w*)\s*\([^;]*$`),
+	}
+	cRules = []tagRule{
+		rule(KindClass, true, `^\s*(?:template\s*<[^>]*>\s*)?(?:class|struct)\s+([A-Za-z_]\w*)[^;]*$`),
+		rule(KindModule, true, `^\s*namespace\s+([A-Za-z_]\w*)`),
+		rule(KindType, false, `^\s*(?:typedef\s+)?(?:enum|union)\s+(?:class\s+)?([A-Za-z_]\w*)`),
+		rule(KindConst, false, `^\s*#\s*define\s+([A-Za-z_]\w*)`),
+		rule(KindFunc, false, `^[A-Za-z_][\w\s\*&:<>,]*?[\s\*&]([A-Za-z_][\w:]*)\s*\([^;]*$`),
+	}
+	rubyRules = []tagRule{
+		rule(KindClass, true, `^\s*class\s+([A-Z]\w*)`),
+		rule(KindModule, true, `^\s*module\s+([A-Z]\w*)`),
+		rule(KindFunc, false, `^\s*def\s+(?:self\.)?([A-Za-z_]\w*[?!=]?)`),
+	}
+	swiftRules = []tagRule{
+		rule(KindClass, true, `^\s*(?:(?:public|private|internal|open|final)\s+)*(?:class|struct|enum|extension|actor)\s+([A-Za-z_]\w*)`),
+		rule(KindInterface, true, `^\s*(?:(?:public|private|internal)\s+)*protocol\s+([A-Za-z_]\w*)`),
+		rule(KindFunc, false, `^\s*(?:(?:public|private|internal|open|static|override|mutating|final)\s+)*func\s+([A-Za-z_]\w*)`),
+	}
+)
+
+var tagRules = map[string][]tagRule{
+	"python":     pythonRules,
+	"javascript": jsRules,
+	"typescript": jsRules,
+	"rust":       rustRules,
+	"java":       javaRules,
+	"kotlin":     javaRules,
+	"c":          cRules,
+	"cpp":        cRules,
+	"ruby":       rubyRules,
+	"swift":      swiftRules,
+}
+
+// statementWords start lines that are statements, never definitions. The
+// looser method patterns would otherwise read "return foo(x)" or "if (x) {" as
+// a definition of foo or if.
+var statementWords = map[string]bool{
+	"if": true, "for": true, "while": true, "switch": true, "catch": true, "return": true,
+	"else": true, "do": true, "try": true, "new": true, "delete": true, "throw": true,
+	"case": true, "yield": true, "await": true, "sizeof": true, "using": true, "goto": true,
+}
+
+var (
+	identRe     = regexp.MustCompile(`[A-Za-z_$][\w$]*`)
+	rubyOpenRe  = regexp.MustCompile(`^\s*(?:class|module|def|if|unless|while|until|case|begin)\b|\bdo\s*(?:\|[^|]*\|)?\s*$`)
+	rubyCloseRe = regexp.MustCompile(`^\s*end\b`)
+)
+
+// scope is an open container while scanning: its name and the depth its
+// members live at (brace or block depth, or indentation for Python).
+type scope struct {
+	name  string
+	depth int
+}
+
+// parseTags runs the language's rules over each line. Containers are tracked
+// by brace depth, by block keywords for Ruby, and by indentation for Python,
+// which is as much structure as an outline needs without a real parser.
+func parseTags(f *File, src []byte) {
+	rules := tagRules[f.Lang]
+	idents := map[string]bool{}
+
+	var stack []scope
+	depth := 0
+	for i, raw := range strings.Split(string(src), "\n") {
+		line := stripLineComment(f.Lang, raw)
+		for _, id := range identRe.FindAllString(line, -1) {
+			idents[id] = true
+		}
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+
+		level := depth
+		if f.Lang == "python" {
+			level = indentOf(raw)
+		}
+		for len(stack) > 0 && level < stack[len(stack)-1].depth {
+			stack = stack[:len(stack)-1]
+		}
+
+		if first := identRe.FindString(trimmed); !statementWords[first] {
+			if sym, opens, ok := matchRules(rules, line, stack); ok {
+				sym.Line = i + 1
+				sym.Signature = truncateSignature(trimmed)
+				f.Symbols = append(f.Symbols, sym)
+				if opens {
+					member := depth + 1
+					if f.Lang == "python" {
+						member = level + 1
+					}
+					stack = append(stack, scope{name: sym.Name, depth: member})
+				}
+			}
+		}
+
+		switch f.Lang {
+		case "python":
+		case "ruby":
+			if rubyOpenRe.MatchString(line) {
+				depth++
+			}
+			if rubyCloseRe.MatchString(line) && depth > 0 {
+				depth--
+			}
+		default:
+			depth += strings.Count(line, "{") - strings.Count(line, "}")
+			if depth < 0 {
+				depth = 0
+			}
+		}
+	}
+
+	f.Idents = make([]string, 0, len(idents))
+	for id := range idents {
+		f.Idents = append(f.Idents, id)
+	}
+	sort.Strings(f.Idents)
+}
+
+// matchRules returns the first definition line matches, and whether it opens
+// a container. A method-shaped match outside any container is not a
+// definition; a function inside one is a method.
+func matchRules(rules []tagRule, line string, stack []scope) (Symbol, bool, bool) {
+	container := ""
+	if len(stack) > 0 {
+		container = stack[len(stack)-1].name
+	}
+	for _, r := range rules {
+		m := r.re.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		sym := Symbol{Name: m[1], Kind: r.kind, Container: container}
+		switch {
+		case sym.Kind == KindMethod && container == "":
+			continue
+		case sym.Kind == KindFunc && container != "":
+			sym.Kind = KindMethod
+		}
+		if i := strings.LastIndex(sym.Name, "::"); i >= 0 {
+			// C++ out-of-line definition: Widget::draw.
+			sym.Container, sym.Name, sym.Kind = sym.Name[:i], sym.Name[i+2:], KindMethod
+		}
+		return sym, r.scope, true
+	}
+	return Symbol{}, false, false
+}
+
+func indentOf(s string) int {
+	n := 0
+	for _, r := range s {
+		switch r {
+		case ' ':
+			n++
+		case '\t':
+			n += 4
+		default:
+			return n
+		}
+	}
+	return n
+}
+
+// stripLineComment blanks a line that is only a comment, so commented-out code
+// does not produce definitions. Trailing comments are left alone.
+func stripLineComment(lang, line string) string {
+	trimmed := strings.TrimSpace(line)
+	switch lang {
+	case "python", "ruby":
+		if strings.HasPrefix(trimmed, "#") {
+			return ""
+		}
+	default:
+		if strings.HasPrefix(trimmed, "//") || strings.HasPrefix(trimmed, "/*") || strings.HasPrefix(trimmed, "* ") || trimmed == "*" {
+			return ""
+		}
+	}
+	return line
+}
+
+func truncateSignature(s string) string {
+	s = strings.TrimSuffix(strings.TrimSpace(s), "{")
+	s = strings.TrimSpace(s)
+	if r := []rune(s); len(r) > 160 {
+		return string(r[:160]) + "…"
+	}
+	return s
+}
+
+// scanReferences finds the uses of name in a non-Go file: every whole-word
+// occurrence that is not one of the file's own definitions of it. The caller
+// is the nearest function or method defined above the line.
+func scanReferences(f *File, lines []string, name string, callsOnly bool) []RefHit {
+	defined := map[int]bool{}
+	var funcs []Symbol
+	for _, s := range f.Symbols {
+		if s.Name == name {
+			defined[s.Line] = true
+		}
+		if s.Kind == KindFunc || s.Kind == KindMethod {
+			funcs = append(funcs, s)
+		}
+	}
+	re := regexp.MustCompile(`(?:^|[^\w$])` + regexp.QuoteMeta(name) + `(?:[^\w$]|$)`)
+
+	var out []RefHit
+	for i, raw := range lines {
+		n := i + 1
+		if defined[n] {
+			continue
+		}
+		line := stripLineComment(f.Lang, raw)
+		loc := re.FindStringIndex(line)
+		if loc == nil {
+			continue
+		}
+		rest := strings.TrimLeft(line[loc[0]:], " \t")
+		rest = strings.TrimLeft(rest[strings.Index(rest, name)+len(name):], " \t")
+		call := strings.HasPrefix(rest, "(")
+		if callsOnly && !call {
+			continue
+		}
+		caller := ""
+		for _, s := range funcs {
+			if s.Line > n {
+				break
+			}
+			caller = s.QualifiedName()
+		}
+		out = append(out, RefHit{
+			Path: f.Path,
+			Text: strings.TrimSpace(raw),
+			Ref:  Ref{Name: name, Caller: caller, Line: n, Call: call},
+		})
+	}
+	return out
+}
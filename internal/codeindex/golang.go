@@ -0,0 +1,266 @@
+package codeindex
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"strings"
+)
+
+const langGo = "go"
+
+// predeclared are Go's universe-scope names. They are never recorded as
+// references: "who uses len" is not a question the index is for, and string,
+// error, nil and friends would otherwise dominate it.
+var predeclared = map[string]bool{
+	"any": true, "bool": true, "byte": true, "comparable": true, "complex64": true,
+	"complex128": true, "error": true, "float32": true, "float64": true, "int": true,
+	"int8": true, "int16": true, "int32": true, "int64": true, "rune": true,
+	"string": true, "uint": true, "uint8": true, "uint16": true, "uint32": true,
+	"uint64": true, "uintptr": true, "true": true, "false": true, "iota": true,
+	"nil": true, "append": true, "cap": true, "clear": true, "close": true,
+	"complex": true, "copy": true, "delete": true, "imag": true, "len": true,
+	"make": true, "max": true, "min": true, "new": true, "panic": true,
+	"print": true, "println": true, "real": true, "recover": true, "_": true,
+}
+
+// parseGo indexes a Go file. A file that does not parse still contributes what
+// the parser recovered, so a half-edited file keeps most of its outline.
+func parseGo(f *File, src []byte) {
+	fset := token.NewFileSet()
+	af, _ := parser.ParseFile(fset, f.Path, src, parser.SkipObjectResolution)
+	if af == nil {
+		return
+	}
+	f.Package = af.Name.Name
+
+	line := func(p token.Pos) int { return fset.Position(p).Line }
+	for _, decl := range af.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			s := Symbol{Name: d.Name.Name, Kind: KindFunc, Line: line(d.Name.Pos()), Signature: goFuncSignature(fset, d)}
+			if recv := receiverType(d); recv != "" {
+				s.Kind, s.Container = KindMethod, recv
+			}
+			f.Symbols = append(f.Symbols, s)
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch sp := spec.(type) {
+				case *ast.TypeSpec:
+					kind, shape := KindType, goTypeShape(sp.Type)
+					if _, ok := sp.Type.(*ast.InterfaceType); ok {
+						kind = KindInterface
+					}
+					f.Symbols = append(f.Symbols, Symbol{
+						Name: sp.Name.Name, Kind: kind, Line: line(sp.Name.Pos()),
+						Signature: "type " + sp.Name.Name + " " + shape,
+					})
+					if it, ok := sp.Type.(*ast.InterfaceType); ok {
+						for _, m := range it.Methods.List {
+							for _, n := range m.Names {
+								f.Symbols = append(f.Symbols, Symbol{
+									Name: n.Name, Kind: KindMethod, Container: sp.Name.Name,
+									Line: line(n.Pos()), Signature: n.Name + strings.TrimPrefix(render(fset, m.Type), "func"),
+								})
+							}
+						}
+					}
+				case *ast.ValueSpec:
+					kind := KindVar
+					if d.Tok == token.CONST {
+						kind = KindConst
+					}
+					for _, n := range sp.Names {
+						if n.Name == "_" {
+							continue
+						}
+						sig := d.Tok.String() + " " + n.Name
+						if sp.Type != nil {
+							sig += " " + render(fset, sp.Type)
+						}
+						f.Symbols = append(f.Symbols, Symbol{Name: n.Name, Kind: kind, Line: line(n.Pos()), Signature: sig})
+					}
+				}
+			}
+		}
+	}
+
+	f.Refs = goRefs(fset, af)
+}
+
+// receiverType returns the bare receiver type name of a method ("Agent" for
+// both `(a Agent)` and `(a *Agent[T])`), or "" for a function.
+func receiverType(d *ast.FuncDecl) string {
+	if d.Recv == nil || len(d.Recv.List) == 0 {
+		return ""
+	}
+	t := d.Recv.List[0].Type
+	for {
+		switch x := t.(type) {
+		case *ast.StarExpr:
+			t = x.X
+		case *ast.IndexExpr:
+			t = x.X
+		case *ast.IndexListExpr:
+			t = x.X
+		case *ast.Ident:
+			return x.Name
+		default:
+			return ""
+		}
+	}
+}
+
+// goFuncSignature renders a function declaration without its body or doc.
+func goFuncSignature(fset *token.FileSet, d *ast.FuncDecl) string {
+	stripped := *d
+	stripped.Body, stripped.Doc = nil, nil
+	return render(fset, &stripped)
+}
+
+// goTypeShape summarizes a type expression: "struct", "interface", or the
+// rendered expression for anything else ("map[string]int", "func(int) error").
+func goTypeShape(t ast.Expr) string {
+	switch t.(type) {
+	case *ast.StructType:
+		return "struct"
+	case *ast.InterfaceType:
+		return "interface"
+	}
+	return render(token.NewFileSet(), t)
+}
+
+// render prints a node on one line.
+func render(fset *token.FileSet, n any) string {
+	var b bytes.Buffer
+	if err := printer.Fprint(&b, fset, n); err != nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// goRefs records the uses of names in a file: every identifier that is not a
+// declaration, a predeclared name, an import qualifier, or a local of the
+// function it appears in. Locals are dropped by name, not by scope — a local
+// that shadows a package-level function hides that function's uses in the
+// same body, which is rare and cheaper than resolving scopes.
+func goRefs(fset *token.FileSet, af *ast.File) []Ref {
+	imports := map[string]bool{}
+	for _, imp := range af.Imports {
+		name := strings.Trim(imp.Path.Value, `"`)
+		if i := strings.LastIndex(name, "/"); i >= 0 {
+			name = name[i+1:]
+		}
+		if imp.Name != nil {
+			name = imp.Name.Name
+		}
+		imports[name] = true
+	}
+
+	var refs []Ref
+	for _, decl := range af.Decls {
+		caller := ""
+		locals := map[string]bool{}
+		declared := map[*ast.Ident]bool{}
+		if fd, ok := decl.(*ast.FuncDecl); ok {
+			caller = fd.Name.Name
+			if recv := receiverType(fd); recv != "" {
+				caller = recv + "." + caller
+			}
+		}
+
+		// First pass: what this declaration declares, and which idents are
+		// call targets or package qualifiers.
+		calls := map[*ast.Ident]bool{}
+		qualifiers := map[*ast.Ident]bool{}
+		ast.Inspect(decl, func(n ast.Node) bool {
+			switch x := n.(type) {
+			case *ast.FuncDecl:
+				declared[x.Name] = true
+			case *ast.TypeSpec:
+				declared[x.Name] = true
+			case *ast.ValueSpec:
+				for _, id := range x.Names {
+					declared[id] = true
+					if caller != "" {
+						locals[id.Name] = true
+					}
+				}
+			case *ast.Field:
+				for _, id := range x.Names {
+					declared[id] = true
+					locals[id.Name] = true
+				}
+			case *ast.AssignStmt:
+				if x.Tok == token.DEFINE {
+					for _, lhs := range x.Lhs {
+						if id, ok := lhs.(*ast.Ident); ok {
+							declared[id] = true
+							locals[id.Name] = true
+						}
+					}
+				}
+			case *ast.RangeStmt:
+				if x.Tok == token.DEFINE {
+					for _, e := range []ast.Expr{x.Key, x.Value} {
+						if id, ok := e.(*ast.Ident); ok {
+							declared[id] = true
+							locals[id.Name] = true
+						}
+					}
+				}
+			case *ast.LabeledStmt:
+				declared[x.Label] = true
+			case *ast.BranchStmt:
+				if x.Label != nil {
+					declared[x.Label] = true
+				}
+			case *ast.SelectorExpr:
+				if id, ok := x.X.(*ast.Ident); ok && imports[id.Name] {
+					qualifiers[id] = true
+				}
+			case *ast.CallExpr:
+				switch fn := x.Fun.(type) {
+				case *ast.Ident:
+					calls[fn] = true
+				case *ast.SelectorExpr:
+					calls[fn.Sel] = true
+				case *ast.IndexExpr: // generic instantiation: F[T](...)
+					if id, ok := fn.X.(*ast.Ident); ok {
+						calls[id] = true
+					}
+				}
+			}
+			return true
+		})
+
+		// Second pass: the uses. A selector's Sel is always a use (x.Field,
+		// pkg.Func); a bare ident is one unless it is local to this function.
+		sels := map[*ast.Ident]bool{}
+		ast.Inspect(decl, func(n ast.Node) bool {
+			if se, ok := n.(*ast.SelectorExpr); ok {
+				sels[se.Sel] = true
+			}
+			return true
+		})
+		ast.Inspect(decl, func(n ast.Node) bool {
+			id, ok := n.(*ast.Ident)
+			if !ok || declared[id] || qualifiers[id] || predeclared[id.Name] {
+				return true
+			}
+			if !sels[id] && locals[id.Name] {
+				return true
+			}
+			refs = append(refs, Ref{
+				Name:   id.Name,
+				Caller: caller,
+				Line:   fset.Position(id.Pos()).Line,
+				Call:   calls[id],
+			})
+			return true
+		})
+	}
+	return refs
+}
@@ -0,0 +1,442 @@
+// Package codeindex keeps a per-project symbol index: definitions, references
+// and file outlines, refreshed incrementally by file mtime.
+//
+// Go files are parsed with go/parser, which gives exact declarations and call
+// sites. Other languages get a ctags-style line scanner: definitions come from
+// per-language patterns, and references are found at query time by scanning
+// only the files whose identifier set contains the name. That split keeps the
+// index small while still answering "who calls Y" across a mixed repository.
+package codeindex
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/fpt/klein-cli/internal/tool/filesearch"
+)
+
+// indexVersion is bumped whenever the on-disk shape or the parsers change in a
+// way that makes old entries wrong; a mismatched file is discarded and rebuilt.
+const indexVersion = 1
+
+// Limits on what gets indexed. Generated bundles and vendored trees are where
+// a repository's biggest files live, and they are never what the model is
+// looking for.
+const (
+	maxFileSize = 1 << 20 // bytes; larger files are skipped
+	maxFiles    = 50_000  // stop walking past this many indexed files
+)
+
+// skipDirs are directory names never descended into, in addition to any
+// hidden directory and anything .gitignore or .ignore excludes.
+var skipDirs = map[string]bool{
+	"node_modules": true, "vendor": true, "dist": true, "target": true,
+	"__pycache__": true, "testdata": true,
+}
+
+// Kind classifies a symbol.
+type Kind string
+
+// Symbol kinds. Languages map their own constructs onto these: a Rust trait
+// and a Java interface are both KindInterface, a Python class is KindClass.
+const (
+	KindFunc      Kind = "func"
+	KindMethod    Kind = "method"
+	KindType      Kind = "type"
+	KindInterface Kind = "interface"
+	KindClass     Kind = "class"
+	KindVar       Kind = "var"
+	KindConst     Kind = "const"
+	KindModule    Kind = "module"
+)
+
+// Symbol is one definition.
+type Symbol struct {
+	Name      string
+	Kind      Kind
+	Container string // receiver type or enclosing class; "" at top level
+	Signature string // the declaration on one line
+	Line      int
+}
+
+// QualifiedName returns "Container.Name", or Name at top level.
+func (s Symbol) QualifiedName() string {
+	if s.Container == "" {
+		return s.Name
+	}
+	return s.Container + "." + s.Name
+}
+
+// Ref is one use of a name, recorded for Go files.
+type Ref struct {
+	Name   string
+	Caller string // enclosing function ("Type.Method" for methods); "" at file scope
+	Line   int
+	Call   bool // the use is the callee of a call expression
+}
+
+// File is the indexed state of one source file.
+type File struct {
+	Path    string // slash-separated, relative to the index root
+	Lang    string
+	Package string // Go package name; "" for other languages
+	Symbols []Symbol
+	Refs    []Ref    // Go only
+	Idents  []string // other languages: distinct identifiers, to pick files to scan
+	ModTime int64    // UnixNano
+	Size    int64
+}
+
+// Index is a symbol index over one directory tree. It is safe for concurrent
+// use; queries refresh it first, so callers never see a stale file.
+type Index struct {
+	root string
+	path string                // gob file; "" keeps the index in memory only
+	skip func(abs string) bool // files kept out of the index; nil skips none
+
+	mu     sync.Mutex
+	files  map[string]*File // by relative path
+	loaded bool
+}
+
+// Open returns an index over root persisted at path. Nothing is read until the
+// first Refresh; an unreadable or outdated index file is rebuilt from scratch.
+// A file skip reports true for, by absolute path, is never read or indexed,
+// so the index sees no more of the tree than Grep does.
+func Open(root, path string, skip func(abs string) bool) *Index {
+	return &Index{root: root, path: path, skip: skip, files: make(map[string]*File)}
+}
+
+// Root returns the directory the index covers.
+func (ix *Index) Root() string { return ix.root }
+
+// diskIndex is the gob payload.
+type diskIndex struct {
+	Version int
+	Root    string
+	Files   map[string]*File
+}
+
+// RefreshStats reports what a Refresh changed.
+type RefreshStats struct {
+	Files   int // files in the index afterwards
+	Parsed  int // new or modified files re-parsed
+	Removed int // files dropped because they are gone
+}
+
+// Refresh brings the index up to date with the tree: files whose mtime or size
+// changed are re-parsed, vanished files are dropped, and the result is saved
+// when anything changed.
+func (ix *Index) Refresh(ctx context.Context) (RefreshStats, error) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	return ix.refreshLocked(ctx)
+}
+
+func (ix *Index) refreshLocked(ctx context.Context) (RefreshStats, error) {
+	var stats RefreshStats
+	if !ix.loaded {
+		ix.load()
+		ix.loaded = true
+	}
+
+	// An unreadable root would otherwise look like an empty tree and drop
+	// every entry.
+	if _, err := os.Stat(ix.root); err != nil {
+		return stats, err
+	}
+	seen := make(map[string]bool, len(ix.files))
+	opts := filesearch.WalkOptions{SkipDir: func(rel string) bool { return skipDirs[path.Base(rel)] }}
+	err := filesearch.Walk(ctx, ix.root, opts, func(rel string) error {
+		lang := languageOf(path.Base(rel))
+		if lang == "" {
+			return nil
+		}
+		if len(seen) >= maxFiles {
+			return filepath.SkipAll
+		}
+		p := filepath.Join(ix.root, filepath.FromSlash(rel))
+		if ix.skip != nil && ix.skip(p) {
+			return nil
+		}
+		info, err := os.Stat(p)
+		if err != nil || info.Size() > maxFileSize {
+			return nil
+		}
+		seen[rel] = true
+
+		mod := info.ModTime().UnixNano()
+		if f, ok := ix.files[rel]; ok && f.ModTime == mod && f.Size == info.Size() {
+			return nil
+		}
+		src, err := os.ReadFile(p) //nolint:gosec // walking the project tree is the point
+		if err != nil {
+			return nil
+		}
+		f := parseFile(rel, lang, src)
+		f.ModTime, f.Size = mod, info.Size()
+		ix.files[rel] = f
+		stats.Parsed++
+		return nil
+	})
+	if err != nil {
+		return stats, err
+	}
+
+	for rel := range ix.files {
+		if !seen[rel] {
+			delete(ix.files, rel)
+			stats.Removed++
+		}
+	}
+	stats.Files = len(ix.files)
+	if stats.Parsed > 0 || stats.Removed > 0 {
+		if err := ix.save(); err != nil {
+			return stats, fmt.Errorf("saving code index: %w", err)
+		}
+	}
+	return stats, nil
+}
+
+// load reads the persisted index, leaving it empty when there is none or it
+// was written by another version or for another root.
+func (ix *Index) load() {
+	if ix.path == "" {
+		return
+	}
+	f, err := os.Open(ix.path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	var d diskIndex
+	if err := gob.NewDecoder(f).Decode(&d); err != nil || d.Version != indexVersion || d.Root != ix.root {
+		return
+	}
+	if d.Files != nil {
+		ix.files = d.Files
+	}
+}
+
+// save writes the index atomically: a crash mid-write must not leave a
+// truncated file that the next load silently discards along with every entry.
+func (ix *Index) save() error {
+	if ix.path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(ix.path), 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(ix.path), ".codeindex-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	enc := gob.NewEncoder(tmp)
+	if err := enc.Encode(diskIndex{Version: indexVersion, Root: ix.root, Files: ix.files}); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), ix.path)
+}
+
+// Location is a symbol together with the file that defines it.
+type Location struct {
+	Path string
+	Lang string
+	Symbol
+}
+
+// splitQuery accepts "Name" or "Container.Name".
+func splitQuery(q string) (container, name string) {
+	q = strings.TrimSpace(q)
+	if i := strings.LastIndex(q, "."); i > 0 && i < len(q)-1 {
+		return strings.TrimPrefix(q[:i], "*"), q[i+1:]
+	}
+	return "", q
+}
+
+// Definitions returns where name is defined. name may be qualified
+// ("Agent.Invoke"); kind, when non-empty, filters by symbol kind. An exact
+// match wins; failing that, a case-insensitive one is returned, which covers
+// the model guessing the casing of an identifier it has only seen in prose.
+func (ix *Index) Definitions(ctx context.Context, name string, kind Kind) ([]Location, error) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if _, err := ix.refreshLocked(ctx); err != nil {
+		return nil, err
+	}
+	container, bare := splitQuery(name)
+	match := func(fold bool) []Location {
+		eq := func(a, b string) bool { return a == b }
+		if fold {
+			eq = strings.EqualFold
+		}
+		var out []Location
+		for _, f := range ix.files {
+			for _, s := range f.Symbols {
+				if !eq(s.Name, bare) || (kind != "" && s.Kind != kind) {
+					continue
+				}
+				if container != "" && !eq(s.Container, container) && !eq(f.Package, container) {
+					continue
+				}
+				out = append(out, Location{Path: f.Path, Lang: f.Lang, Symbol: s})
+			}
+		}
+		return out
+	}
+	out := match(false)
+	if len(out) == 0 {
+		out = match(true)
+	}
+	sortLocations(out)
+	return out, nil
+}
+
+// RefHit is one reference found by References.
+type RefHit struct {
+	Path string
+	Text string // the source line, trimmed
+	Ref
+}
+
+// References returns the uses of name across the tree, excluding its
+// definitions. callsOnly restricts the result to call sites, which is the
+// "who calls Y" question. A qualified name ("Agent.Invoke") matches on the
+// final element: without type information a method call cannot be tied to its
+// receiver, so the qualifier only narrows which definitions are excluded.
+func (ix *Index) References(ctx context.Context, name string, callsOnly bool) ([]RefHit, error) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if _, err := ix.refreshLocked(ctx); err != nil {
+		return nil, err
+	}
+	_, bare := splitQuery(name)
+	if bare == "" {
+		return nil, errors.New("empty name")
+	}
+
+	var out []RefHit
+	for _, f := range ix.files {
+		if f.Lang == langGo {
+			var lines []string
+			for _, r := range f.Refs {
+				if r.Name != bare || (callsOnly && !r.Call) {
+					continue
+				}
+				if lines == nil {
+					lines = ix.readLines(f.Path)
+				}
+				out = append(out, RefHit{Path: f.Path, Text: lineAt(lines, r.Line), Ref: r})
+			}
+			continue
+		}
+		if !containsSorted(f.Idents, bare) {
+			continue
+		}
+		out = append(out, scanReferences(f, ix.readLines(f.Path), bare, callsOnly)...)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Path != out[j].Path {
+			return out[i].Path < out[j].Path
+		}
+		return out[i].Line < out[j].Line
+	})
+	return out, nil
+}
+
+// Outline returns the indexed files under path: the file itself, or every
+// file directly inside a directory (a Go package, in a Go tree). path is
+// relative to the root, or absolute inside it.
+func (ix *Index) Outline(ctx context.Context, path string) ([]*File, error) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if _, err := ix.refreshLocked(ctx); err != nil {
+		return nil, err
+	}
+	rel, err := ix.relative(path)
+	if err != nil {
+		return nil, err
+	}
+	var out []*File
+	if f, ok := ix.files[rel]; ok {
+		return []*File{f}, nil
+	}
+	for p, f := range ix.files {
+		dir := pathDir(p)
+		if dir == rel {
+			out = append(out, f)
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no indexed source files at %q", path)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Path < out[j].Path })
+	return out, nil
+}
+
+// relative maps a user-supplied path onto the index's relative form.
+func (ix *Index) relative(path string) (string, error) {
+	path = strings.TrimSpace(path)
+	if path == "" || path == "." {
+		return ".", nil
+	}
+	if filepath.IsAbs(path) {
+		rel, err := filepath.Rel(ix.root, path)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return "", fmt.Errorf("%q is outside the indexed tree %s", path, ix.root)
+		}
+		path = rel
+	}
+	return strings.TrimSuffix(filepath.ToSlash(filepath.Clean(path)), "/"), nil
+}
+
+// pathDir is path.Dir for the index's slash-separated paths, with "." for the
+// root.
+func pathDir(p string) string {
+	if i := strings.LastIndex(p, "/"); i >= 0 {
+		return p[:i]
+	}
+	return "."
+}
+
+func (ix *Index) readLines(rel string) []string {
+	data, err := os.ReadFile(filepath.Join(ix.root, filepath.FromSlash(rel)))
+	if err != nil {
+		return nil
+	}
+	return strings.Split(string(data), "\n")
+}
+
+func lineAt(lines []string, n int) string {
+	if n < 1 || n > len(lines) {
+		return ""
+	}
+	return strings.TrimSpace(lines[n-1])
+}
+
+func sortLocations(locs []Location) {
+	sort.Slice(locs, func(i, j int) bool {
+		if locs[i].Path != locs[j].Path {
+			return locs[i].Path < locs[j].Path
+		}
+		return locs[i].Line < locs[j].Line
+	})
+}
+
+func containsSorted(sorted []string, s string) bool {
+	i := sort.SearchStrings(sorted, s)
+	return i < len(sorted) && sorted[i] == s
+}
@@ -0,0 +1,350 @@
+package codeindex
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const goAgent = `package app
+
+import "fmt"
+
+// Agent runs turns.
+type Agent struct {
+	name string
+}
+
+type Runner interface {
+	Run(task string) error
+}
+
+const DefaultName = "klein"
+
+func NewAgent(name string) *Agent {
+	return &Agent{name: name}
+}
+
+func (a *Agent) Run(task string) error {
+	fmt.Println(a.name, task)
+	return helper(task)
+}
+
+func helper(task string) error {
+	if task == "" {
+		return nil
+	}
+	return nil
+}
+`
+
+const goMain = `package app
+
+func start() {
+	a := NewAgent(DefaultName)
+	_ = a.Run("go")
+	helper := 1
+	_ = helper
+}
+`
+
+const pyWidget = `import os
+
+class Widget:
+    def __init__(self, name):
+        self.name = name
+
+    def draw(self, canvas):
+        return render(canvas, self.name)
+
+def render(canvas, name):
+    return canvas.text(name)
+
+# def commented_out(): pass
+`
+
+const tsStore = `export interface Store {
+  get(key: string): string;
+}
+
+export class MemoryStore {
+  private data = new Map<string, string>();
+
+  get(key: string): string {
+    return this.data.get(key) ?? "";
+  }
+}
+
+export const makeStore = (seed: string) => {
+  return new MemoryStore();
+};
+
+function unused() {
+  return makeStore("x");
+}
+`
+
+func writeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for rel, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func newTestIndex(t *testing.T) (*Index, string) {
+	t.Helper()
+	root := writeTree(t, map[string]string{
+		"app/agent.go":         goAgent,
+		"app/main.go":          goMain,
+		"py/widget.py":         pyWidget,
+		"web/store.ts":         tsStore,
+		"node_modules/x/a.js":  "function vendored() {}",
+		".git/hooks/pre.py":    "def hidden(): pass",
+		"docs/readme.md":       "# not source",
+		"app/testdata/fake.go": "package fake\nfunc Fixture() {}",
+	})
+	return Open(root, filepath.Join(t.TempDir(), "index.gob"), nil), root
+}
+
+func TestDefinitionsGo(t *testing.T) {
+	ix, _ := newTestIndex(t)
+	ctx := context.Background()
+
+	locs, err := ix.Definitions(ctx, "Run", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, l := range locs {
+		got = append(got, l.Path+":"+l.QualifiedName()+":"+string(l.Kind))
+	}
+	want := "app/agent.go:Runner.Run:method app/agent.go:Agent.Run:method"
+	if strings.Join(got, " ") != want {
+		t.Errorf("Definitions(Run) = %v, want %s", got, want)
+	}
+
+	locs, _ = ix.Definitions(ctx, "Agent.Run", "")
+	if len(locs) != 1 || locs[0].Signature != "func (a *Agent) Run(task string) error" {
+		t.Errorf("qualified lookup: %+v", locs)
+	}
+	locs, _ = ix.Definitions(ctx, "newagent", "")
+	if len(locs) != 1 || locs[0].Name != "NewAgent" {
+		t.Errorf("case-insensitive fallback: %+v", locs)
+	}
+	locs, _ = ix.Definitions(ctx, "DefaultName", KindConst)
+	if len(locs) != 1 {
+		t.Errorf("const lookup: %+v", locs)
+	}
+	if locs, _ = ix.Definitions(ctx, "Fixture", ""); len(locs) != 0 {
+		t.Errorf("testdata should not be indexed: %+v", locs)
+	}
+	if locs, _ = ix.Definitions(ctx, "vendored", ""); len(locs) != 0 {
+		t.Errorf("node_modules should not be indexed: %+v", locs)
+	}
+}
+
+func TestReferencesGo(t *testing.T) {
+	ix, _ := newTestIndex(t)
+	ctx := context.Background()
+
+	hits, err := ix.References(ctx, "helper", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The local variable named helper in start() is not a call to it.
+	if len(hits) != 1 || hits[0].Caller != "Agent.Run" || hits[0].Path != "app/agent.go" {
+		t.Fatalf("callers of helper: %+v", hits)
+	}
+	if hits[0].Text != "return helper(task)" {
+		t.Errorf("hit text: %q", hits[0].Text)
+	}
+
+	hits, _ = ix.References(ctx, "NewAgent", false)
+	if len(hits) != 1 || hits[0].Caller != "start" || !hits[0].Call {
+		t.Errorf("references to NewAgent: %+v", hits)
+	}
+	hits, _ = ix.References(ctx, "Agent", false)
+	if len(hits) < 2 {
+		t.Errorf("type references to Agent: %+v", hits)
+	}
+	// Parameters are locals, not references to anything.
+	if hits, _ = ix.References(ctx, "task", false); len(hits) != 0 {
+		t.Errorf("locals leaked into references: %+v", hits)
+	}
+}
+
+func TestPythonAndTypeScript(t *testing.T) {
+	ix, _ := newTestIndex(t)
+	ctx := context.Background()
+
+	locs, _ := ix.Definitions(ctx, "draw", "")
+	if len(locs) != 1 || locs[0].Container != "Widget" || locs[0].Kind != KindMethod {
+		t.Errorf("python method: %+v", locs)
+	}
+	locs, _ = ix.Definitions(ctx, "render", "")
+	if len(locs) != 1 || locs[0].Container != "" || locs[0].Kind != KindFunc {
+		t.Errorf("python top-level func after a class: %+v", locs)
+	}
+	if locs, _ = ix.Definitions(ctx, "commented_out", ""); len(locs) != 0 {
+		t.Errorf("commented-out def indexed: %+v", locs)
+	}
+	hits, _ := ix.References(ctx, "render", true)
+	if len(hits) != 1 || hits[0].Caller != "Widget.draw" || hits[0].Line != 8 {
+		t.Errorf("python callers of render: %+v", hits)
+	}
+
+	locs, _ = ix.Definitions(ctx, "get", "")
+	if len(locs) != 1 || locs[0].Container != "MemoryStore" {
+		t.Errorf("ts method: %+v", locs)
+	}
+	locs, _ = ix.Definitions(ctx, "makeStore", "")
+	if len(locs) != 1 || locs[0].Kind != KindFunc {
+		t.Errorf("ts arrow function: %+v", locs)
+	}
+	hits, _ = ix.References(ctx, "makeStore", true)
+	if len(hits) != 1 || hits[0].Caller != "unused" {
+		t.Errorf("ts callers of makeStore: %+v", hits)
+	}
+}
+
+func TestOutline(t *testing.T) {
+	ix, root := newTestIndex(t)
+	ctx := context.Background()
+
+	files, err := ix.Outline(ctx, "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || files[0].Path != "app/agent.go" || files[0].Package != "app" {
+		t.Fatalf("outline of app: %+v", files)
+	}
+	var names []string
+	for _, s := range files[0].Symbols {
+		names = append(names, s.QualifiedName())
+	}
+	if want := "Agent Runner Runner.Run DefaultName NewAgent Agent.Run helper"; strings.Join(names, " ") != want {
+		t.Errorf("agent.go outline = %q, want %q", strings.Join(names, " "), want)
+	}
+
+	if files, err = ix.Outline(ctx, filepath.Join(root, "py", "widget.py")); err != nil || len(files) != 1 {
+		t.Errorf("outline of an absolute file path: %v %+v", err, files)
+	}
+	if _, err = ix.Outline(ctx, "nope"); err == nil {
+		t.Error("outline of a missing path should fail")
+	}
+}
+
+func TestRefreshIsIncrementalAndPersistent(t *testing.T) {
+	ix, root := newTestIndex(t)
+	ctx := context.Background()
+
+	stats, err := ix.Refresh(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Files != 4 || stats.Parsed != 4 {
+		t.Fatalf("first refresh: %+v", stats)
+	}
+	if stats, _ = ix.Refresh(ctx); stats.Parsed != 0 {
+		t.Errorf("unchanged tree re-parsed: %+v", stats)
+	}
+
+	// A reopened index starts from disk and parses nothing.
+	reopened := Open(root, ix.path, nil)
+	if stats, _ = reopened.Refresh(ctx); stats.Parsed != 0 || stats.Files != 4 {
+		t.Errorf("reopened index: %+v", stats)
+	}
+
+	// Edit one file, delete another.
+	agent := filepath.Join(root, "app", "agent.go")
+	edited := strings.Replace(goAgent, "func helper(", "func assist(", 1)
+	if err := os.WriteFile(agent, []byte(edited), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(agent, future, future); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(root, "web", "store.ts")); err != nil {
+		t.Fatal(err)
+	}
+	if stats, _ = reopened.Refresh(ctx); stats.Parsed != 1 || stats.Removed != 1 || stats.Files != 3 {
+		t.Errorf("after edit+delete: %+v", stats)
+	}
+	if locs, _ := reopened.Definitions(ctx, "assist", ""); len(locs) != 1 {
+		t.Errorf("edited definition not picked up: %+v", locs)
+	}
+}
+
+func TestRefreshHonoursIgnoreFilesAndSkip(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		".gitignore":       "gen/\n",
+		"app/main.go":      "package main\n\nfunc Kept() {}\n",
+		"gen/out.go":       "package gen\n\nfunc Generated() {}\n",
+		"secret/creds.go":  "package secret\n\nfunc Token() {}\n",
+		"app/.hidden/x.py": "def hidden(): pass\n",
+	})
+	skip := func(abs string) bool { return strings.Contains(filepath.ToSlash(abs), "/secret/") }
+	ix := Open(root, "", skip)
+	ctx := context.Background()
+
+	stats, err := ix.Refresh(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Files != 1 {
+		t.Errorf("indexed %d files, want only app/main.go", stats.Files)
+	}
+	for _, name := range []string{"Generated", "Token", "hidden"} {
+		if locs, _ := ix.Definitions(ctx, name, ""); len(locs) != 0 {
+			t.Errorf("%s should not be indexed: %+v", name, locs)
+		}
+	}
+	if locs, _ := ix.Definitions(ctx, "Kept", ""); len(locs) != 1 {
+		t.Errorf("Kept: %+v", locs)
+	}
+}
+
+func TestBrokenGoFileKeepsWhatParsed(t *testing.T) {
+	f := parseFile("x.go", langGo, []byte("package x\n\nfunc Good() {}\n\nfunc Bad( {\n"))
+	if len(f.Symbols) == 0 || f.Symbols[0].Name != "Good" {
+		t.Errorf("symbols from a broken file: %+v", f.Symbols)
+	}
+}
+
+func TestCTagsOtherLanguages(t *testing.T) {
+	tests := []struct {
+		file, src string
+		want      []string // QualifiedName:kind
+	}{
+		{"lib.rs", "pub struct Pool {}\nimpl Pool {\n    pub fn get(&self) -> u8 { 0 }\n}\nfn main() {}\n",
+			[]string{"Pool:type", "Pool:type", "Pool.get:method", "main:func"}},
+		{"Svc.java", "public class Svc {\n    public String name(int x) {\n        return helper(x);\n    }\n}\n",
+			[]string{"Svc:class", "Svc.name:method"}},
+		{"w.cpp", "class Widget {\n};\nvoid Widget::draw(int x) {\n}\nint main(int argc) {\n}\n",
+			[]string{"Widget:class", "Widget.draw:method", "main:func"}},
+		{"m.rb", "module Util\n  class Box\n    def open?\n    end\n  end\n  def self.make\n  end\nend\n",
+			[]string{"Util:module", "Util.Box:class", "Box.open?:method", "Util.make:method"}},
+	}
+	for _, tt := range tests {
+		f := parseFile(tt.file, languageOf(tt.file), []byte(tt.src))
+		var got []string
+		for _, s := range f.Symbols {
+			got = append(got, s.QualifiedName()+":"+string(s.Kind))
+		}
+		if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("%s: got %v, want %v", tt.file, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadFallbackChain(t *testing.T) {
+	t.Setenv("ANTHROPIC_API_KEY", "k")
+	t.Setenv("OPENAI_API_KEY", "k")
+	path := filepath.Join(t.TempDir(), "settings.toml")
+	content := `
+[llm]
+backend = "anthropic"
+model = "claude-sonnet-4-6"
+
+[llm.retry]
+max_attempts = 4
+backoff = "500ms"
+
+[[llm.fallback]]
+backend = "openai"
+model = "gpt-5.6-luna"
+effort = "medium"
+
+[[llm.fallback]]
+backend = "openai"
+model = "qwen3-coder"
+base_url = "http://localhost:11434/v1"
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	settings, err := LoadSettings(path)
+	if err != nil {
+		t.Fatalf("LoadSettings: %v", err)
+	}
+	llm := settings.LLM
+	if !llm.UsesFallbackChain() || len(llm.Fallback) != 2 {
+		t.Fatalf("fallback = %+v", llm.Fallback)
+	}
+	if fb := llm.Fallback[1]; fb.Model != "qwen3-coder" || fb.BaseURL != "http://localhost:11434/v1" {
+		t.Errorf("local fallback = %+v", fb)
+	}
+	attempts, backoff, maxBackoff, err := llm.Retry.Policy()
+	if err != nil || attempts != 4 || backoff != 500*time.Millisecond || maxBackoff != DefaultRetryMaxBackoff {
+		t.Errorf("policy = %d %v %v %v", attempts, backoff, maxBackoff, err)
+	}
+}
+
+func TestRetrySettingsDefaults(t *testing.T) {
+	if (LLMSettings{Backend: "openai", Model: "m"}).UsesFallbackChain() {
+		t.Error("plain settings should keep the SDK's own retries")
+	}
+	attempts, backoff, maxBackoff, err := RetrySettings{}.Policy()
+	if err != nil || attempts != DefaultRetryAttempts || backoff != DefaultRetryBackoff || maxBackoff != DefaultRetryMaxBackoff {
+		t.Errorf("defaults = %d %v %v %v", attempts, backoff, maxBackoff, err)
+	}
+}
+
+func TestValidateFallbackChain(t *testing.T) {
+	t.Setenv("ANTHROPIC_API_KEY", "k")
+	t.Setenv("OPENAI_API_KEY", "k")
+	t.Setenv("GEMINI_API_KEY", "")
+	for _, tc := range []struct {
+		name    string
+		edit    func(*LLMSettings)
+		wantErr string
+	}{
+		{"ok", func(l *LLMSettings) { l.Fallback = []LLMSettings{{Backend: "openai", Model: "gpt-5.6-luna"}} }, ""},
+		{"bad backoff", func(l *LLMSettings) { l.Retry.Backoff = "soon" }, "llm.retry.backoff"},
+		{"negative attempts", func(l *LLMSettings) { l.Retry.MaxAttempts = -1 }, "max_attempts"},
+		{"agent backend", func(l *LLMSettings) {
+			l.Fallback = []LLMSettings{{Backend: BackendCodex, Model: "x"}}
+		}, "cannot be a fallback"},
+		{"no model", func(l *LLMSettings) { l.Fallback = []LLMSettings{{Backend: "openai"}} }, "model is required"},
+		{"nested", func(l *LLMSettings) {
+			l.Fallback = []LLMSettings{{Backend: "openai", Model: "m", Retry: RetrySettings{MaxAttempts: 2}}}
+		}, "set once"},
+		{"missing key", func(l *LLMSettings) { l.Fallback = []LLMSettings{{Backend: "gemini", Model: "flash"}} }, "GEMINI_API_KEY"},
+		{"primary is an agent backend", func(l *LLMSettings) {
+			l.Backend, l.Model = BackendCodex, ""
+			l.Retry.MaxAttempts = 2
+		}, "do not apply"},
+	} {
+		s := GetDefaultSettings()
+		s.LLM = LLMSettings{Backend: "anthropic", Model: "claude-sonnet-4-6"}
+		tc.edit(&s.LLM)
+		err := ValidateSettings(s)
+		switch {
+		case tc.wantErr == "" && err != nil:
+			t.Errorf("%s: %v", tc.name, err)
+		case tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)):
+			t.Errorf("%s: err = %v, want it to mention %q", tc.name, err, tc.wantErr)
+		}
+	}
+}
+
+func TestLLMSettingsEqual(t *testing.T) {
+	a := LLMSettings{Backend: "anthropic", Model: "m", Fallback: []LLMSettings{{Backend: "openai", Model: "x"}}}
+	b := a
+	b.Fallback = []LLMSettings{{Backend: "openai", Model: "x"}}
+	if !a.Equal(b) {
+		t.Error("equal chains compare unequal")
+	}
+	b.Fallback[0].Model = "y"
+	if a.Equal(b) {
+		t.Error("different fallbacks compare equal")
+	}
+	if !(LLMSettings{Model: "m"}).Equal(LLMSettings{Model: "m", Fallback: []LLMSettings{}}) {
+		t.Error("nil and empty fallback lists should be equal")
+	}
+}
+
+func TestPinKeepsFallbackChain(t *testing.T) {
+	base := LLMSettings{Backend: "openai", Model: "gpt-5.6-luna", Retry: RetrySettings{MaxAttempts: 2},
+		Fallback: []LLMSettings{{Backend: "gemini", Model: "flash"}}}
+	got, err := base.Pin(ModelPin{Model: "anthropic:claude-haiku-4-5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Backend != "anthropic" || got.Retry != base.Retry || len(got.Fallback) != 1 {
+		t.Errorf("pinned = %+v", got)
+	}
+}
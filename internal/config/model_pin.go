@@ -0,0 +1,111 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// modelAliases are the shorthands a definition's `model:` may use instead of a
+// full model id. They are the names Claude Code agent files use, so an agent
+// written for it ("model: haiku") runs here unchanged.
+//
+// An alias is a tier hint for the anthropic backend, not a request to switch
+// to it: under another backend it inherits the session's model, so a built-in
+// agent pinned to haiku still runs for a user who only has an OpenAI key.
+// `backend: anthropic` next to the alias forces the switch.
+var modelAliases = map[string]string{
+	"haiku":  "claude-haiku-4-5",
+	"sonnet": "claude-sonnet-4-6",
+	"opus":   "claude-opus-4-7",
+}
+
+// modelInherit is the explicit spelling of "use the session's model".
+const modelInherit = "inherit"
+
+// ModelPin is a per-definition choice of backend, model, and reasoning effort,
+// taken from a skill, role, agent, or plugin command's frontmatter. Empty
+// fields inherit from the session's LLMSettings.
+type ModelPin struct {
+	Backend string // "anthropic", "openai", "gemini"; empty = inferred from Model, else inherited
+	Model   string // full id, an alias (haiku/sonnet/opus), "backend:model", or "inherit"
+	Effort  string // one of ValidEfforts; empty = inherited
+}
+
+// IsZero reports whether the pin changes nothing.
+func (p ModelPin) IsZero() bool {
+	return p.Backend == "" && p.Effort == "" && (p.Model == "" || p.Model == modelInherit)
+}
+
+// Pin returns s with p applied.
+//
+// Switching backend starts from that backend's defaults rather than carrying
+// over fields that only made sense for the old one: an OpenAI base URL or
+// effort means nothing to Anthropic. MaxTokens is kept, since it is a budget
+// rather than a provider setting, and so are the retry policy and fallback
+// chain, which are about staying available rather than about one provider. The whole-agent backends (codex, appserver)
+// cannot be pinned — they own the entire turn, so there is no client to swap.
+func (s LLMSettings) Pin(p ModelPin) (LLMSettings, error) {
+	if p.IsZero() {
+		return s, nil
+	}
+	backend := strings.ToLower(strings.TrimSpace(p.Backend))
+	model := strings.TrimSpace(p.Model)
+	if model == modelInherit {
+		model = ""
+	}
+
+	if full, ok := modelAliases[strings.ToLower(model)]; ok {
+		switch {
+		case backend == "" && normalizeBackend(s.Backend) != "anthropic":
+			model = "" // tier hint for another backend: inherit
+		case backend != "" && normalizeBackend(backend) != "anthropic":
+			return s, fmt.Errorf("model %q is an anthropic model, but backend %q is pinned", model, backend)
+		default:
+			model = full
+		}
+	} else if prefix, rest, ok := strings.Cut(model, ":"); ok && isChatBackend(prefix) {
+		if backend != "" && normalizeBackend(backend) != normalizeBackend(prefix) {
+			return s, fmt.Errorf("model %q names backend %q, but backend %q is pinned", model, prefix, backend)
+		}
+		backend, model = prefix, rest
+	}
+
+	out := s
+	if backend != "" {
+		if !isChatBackend(backend) {
+			return s, fmt.Errorf("backend %q cannot be pinned per definition (want anthropic, openai, or gemini)", backend)
+		}
+		if normalizeBackend(backend) != normalizeBackend(s.Backend) {
+			out = GetDefaultLLMSettingsForBackend(backend)
+			out.MaxTokens, out.Retry, out.Fallback = s.MaxTokens, s.Retry, s.Fallback
+		}
+	}
+	if model != "" {
+		out.Model = model
+	}
+	if p.Effort != "" {
+		if !IsValidEffort(p.Effort) {
+			return s, fmt.Errorf("invalid effort %q (must be one of %v)", p.Effort, ValidEfforts)
+		}
+		out.Effort = p.Effort
+	}
+	return out, nil
+}
+
+// isChatBackend reports whether backend is one klein drives through its own
+// ReAct loop, and so can serve a single definition.
+func isChatBackend(backend string) bool {
+	switch normalizeBackend(backend) {
+	case "anthropic", "openai", "gemini":
+		return true
+	}
+	return false
+}
+
+// normalizeBackend folds the "claude" alias into "anthropic".
+func normalizeBackend(backend string) string {
+	if backend == "claude" {
+		return "anthropic"
+	}
+	return backend
+}
@@ -0,0 +1,75 @@
+package config
+
+import "testing"
+
+func TestModelPin(t *testing.T) {
+	openai := GetDefaultLLMSettingsForBackend("openai")
+	openai.MaxTokens = 4096
+	anthropic := GetDefaultLLMSettingsForBackend("anthropic")
+
+	tests := []struct {
+		name string
+		base LLMSettings
+		pin  ModelPin
+		want LLMSettings
+	}{
+		{"zero pin", openai, ModelPin{}, openai},
+		{"inherit", openai, ModelPin{Model: "inherit"}, openai},
+		{"alias under another backend inherits", openai, ModelPin{Model: "haiku"}, openai},
+		{
+			"alias with an explicit backend switches and keeps max tokens",
+			openai, ModelPin{Backend: "anthropic", Model: "haiku"},
+			LLMSettings{Backend: "anthropic", Model: "claude-haiku-4-5", Thinking: true, MaxTokens: 4096},
+		},
+		{
+			"alias on the same backend only swaps the model",
+			anthropic, ModelPin{Model: "opus"},
+			LLMSettings{Backend: "anthropic", Model: "claude-opus-4-7", Thinking: true},
+		},
+		{
+			"backend-qualified model",
+			anthropic, ModelPin{Model: "gemini:gemini-2.5-pro"},
+			LLMSettings{Backend: "gemini", Model: "gemini-2.5-pro"},
+		},
+		{
+			"bare model keeps the backend",
+			openai, ModelPin{Model: "gpt-5.6"},
+			LLMSettings{Backend: "openai", Model: "gpt-5.6", Thinking: true, MaxTokens: 4096, Effort: "low"},
+		},
+		{
+			"effort only",
+			openai, ModelPin{Effort: "high"},
+			LLMSettings{Backend: "openai", Model: openai.Model, Thinking: true, MaxTokens: 4096, Effort: "high"},
+		},
+		{
+			"backend only takes that backend's default model",
+			openai, ModelPin{Backend: "gemini"},
+			LLMSettings{Backend: "gemini", Model: "gemini-2.5-flash-lite", MaxTokens: 4096},
+		},
+	}
+	for _, tt := range tests {
+		got, err := tt.base.Pin(tt.pin)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("%s:\n got %+v\nwant %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestModelPinRejects(t *testing.T) {
+	base := GetDefaultLLMSettingsForBackend("openai")
+	for _, pin := range []ModelPin{
+		{Backend: "codex"},
+		{Backend: "ollama"},
+		{Effort: "turbo"},
+		{Backend: "openai", Model: "haiku"},
+		{Backend: "openai", Model: "gemini:gemini-2.5-pro"},
+	} {
+		if got, err := base.Pin(pin); err == nil {
+			t.Errorf("Pin(%+v) = %+v, want an error", pin, got)
+		}
+	}
+}
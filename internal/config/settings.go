@@ -6,9 +6,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
 	"slices"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
 
@@ -27,6 +29,26 @@ type Settings struct {
 	MCP   MCPSettings   `toml:"mcp"`
 	Agent AgentSettings `toml:"agent"`
 	Bash  BashSettings  `toml:"bash,omitempty"`
+	LSP   LSPSettings   `toml:"lsp,omitempty"`
+
+	// Memory tunes the long-term memory pipeline (memorydb) where it is wired
+	// in: the interactive REPL, `klein --serve` and `klein claw`.
+	Memory MemorySettings `toml:"memory,omitempty"`
+
+	// Compaction tunes when the conversation is compacted and how, with
+	// overrides per role.
+	Compaction CompactionSettings `toml:"compaction,omitempty"`
+
+	// Web is the policy WebFetch, PDF URLs, the market tools and the
+	// researcher crawlers fetch under, with overrides per role.
+	Web WebSettings `toml:"web,omitempty"`
+
+	// Trace exports OpenTelemetry spans of agent turns, LLM calls and tool
+	// calls. Off unless an exporter is named.
+	Trace TraceSettings `toml:"trace,omitempty"`
+
+	// Tokenizer controls the BPE vocabularies used for exact token counts.
+	Tokenizer TokenizerSettings `toml:"tokenizer,omitempty"`
 
 	// BaseDir is the root for shared per-user state (sessions, memory, the
 	// schedule store). Empty resolves to ~/.klein. It is env-expanded on load.
@@ -70,8 +92,16 @@ type Settings struct {
 	// substitution. See agentserver.WithAutoApprove.
 	AutoApproveCommands []string `toml:"auto_approve_commands,omitempty"`
 
+	// Plugins are the plugins and marketplaces `klein plugin add` installed,
+	// keyed by name. They load on every run alongside --plugin and
+	// --plugin-marketplace; git sources load at the commit pinned in the
+	// plugins.lock beside this file.
+	Plugins map[string]PluginSpec `toml:"plugins,omitempty"`
+
 	// Repository for persistence (nil for in-memory only)
 	settingsRepository repository.SettingsRepository `toml:"-"`
+	// path is the file these settings were loaded from ("" when none was).
+	path string
 }
 
 // ResolvedBaseDir returns the env-expanded base directory, defaulting to
@@ -107,6 +137,74 @@ func (s *Settings) MemoryDBFile() string {
 	return filepath.Join(s.MemoryDir(), "memory.sqlite")
 }
 
+// PluginsDir is <base>/plugins — checkouts of git-sourced plugins, one
+// directory per plugin and commit.
+func (s *Settings) PluginsDir() string {
+	return filepath.Join(s.ResolvedBaseDir(), "plugins")
+}
+
+// TracesDir is <base>/traces — JSONL traces of runs that have no session
+// file to sit beside (one-shot prompts).
+func (s *Settings) TracesDir() string {
+	return filepath.Join(s.ResolvedBaseDir(), "traces")
+}
+
+// TokenizersDir is <base>/tokenizers — BPE vocabularies for exact token
+// counts, placed by hand or fetched when [tokenizer] fetch is on.
+func (s *Settings) TokenizersDir() string {
+	return filepath.Join(s.ResolvedBaseDir(), "tokenizers")
+}
+
+// WebArchiveDir is <base>/webarchive — pages and PDFs the web and research
+// tools fetched, kept for citation and revalidation.
+func (s *Settings) WebArchiveDir() string {
+	return filepath.Join(s.ResolvedBaseDir(), "webarchive")
+}
+
+// ReportsDir is <base>/reports — HTML and PDF reports written by
+// RenderReport.
+func (s *Settings) ReportsDir() string {
+	return filepath.Join(s.ResolvedBaseDir(), "reports")
+}
+
+// FilePath is the settings file these settings were loaded from, or "" for
+// defaults that came from no file.
+func (s *Settings) FilePath() string {
+	return s.path
+}
+
+// PluginsLockFile is the lockfile that pins the plugins of the settings file
+// at settingsPath: plugins.lock in the same directory, so a project's
+// .agents/settings.toml and its lock are committed together.
+func PluginsLockFile(settingsPath string) string {
+	return filepath.Join(filepath.Dir(settingsPath), "plugins.lock")
+}
+
+// PluginSpec is one [plugins.<name>] table.
+type PluginSpec struct {
+	Source  string `toml:"source"`            // git URL or local directory (plugin or marketplace)
+	Ref     string `toml:"ref,omitempty"`     // branch, tag or commit to follow; empty = the remote's default branch
+	Enabled *bool  `toml:"enabled,omitempty"` // omitted = enabled
+}
+
+// IsEnabled reports whether the plugin loads (enabled unless set to false).
+func (p PluginSpec) IsEnabled() bool {
+	return p.Enabled == nil || *p.Enabled
+}
+
+// PluginTOML renders a plugin as the body of its [plugins.<name>] table,
+// ready to hand to tomledit.SetTable. enabled is written only when false.
+func PluginTOML(spec PluginSpec) ([]byte, error) {
+	if spec.IsEnabled() {
+		spec.Enabled = nil
+	}
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(spec); err != nil {
+		return nil, fmt.Errorf("rendering plugin: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
 // LLMSettings contains LLM client configuration.
 type LLMSettings struct {
 	Backend   string `toml:"backend"`              // "openai", "anthropic", "gemini", "codex", or "appserver"
@@ -114,12 +212,81 @@ type LLMSettings struct {
 	BaseURL   string `toml:"base_url,omitempty"`   // optional provider base URL (OpenAI/Azure-compatible)
 	Thinking  bool   `toml:"thinking,omitempty"`   // enable thinking mode
 	MaxTokens int    `toml:"max_tokens,omitempty"` // maximum tokens for model responses (0 = use model default)
-	// Effort sets the reasoning effort for reasoning-capable models (primarily
-	// OpenAI GPT-5). Empty = backend default. The full vocabulary is in
+	// Effort sets the reasoning effort for reasoning-capable models: OpenAI
+	// GPT-5 reasoning effort, Gemini thinking budgets (2.5) and thinking levels
+	// (3). Empty = backend default. The full vocabulary is in
 	// ValidEfforts, but actual support is model-dependent — e.g. gpt-5.6-luna accepts
 	// none/low/medium/high/xhigh but not minimal. Ignored by models without
 	// reasoning effort.
 	Effort string `toml:"effort,omitempty"`
+
+	// Retry is the policy for transient provider failures (429, 5xx, dropped
+	// connections). Setting it, or Fallback, routes calls through a composite
+	// client that owns retries; otherwise each SDK keeps its own.
+	Retry RetrySettings `toml:"retry,omitempty"`
+	// Fallback lists the backends to try, in order, once this one has used up
+	// its retries: [[llm.fallback]] tables with the same keys as [llm]
+	// (backend, model, base_url, max_tokens, effort). Each is retried under
+	// the same policy; a fallback's own retry/fallback keys are not allowed.
+	Fallback []LLMSettings `toml:"fallback,omitempty"`
+}
+
+// RetrySettings is the [llm.retry] table.
+type RetrySettings struct {
+	MaxAttempts int    `toml:"max_attempts,omitempty"` // Calls per backend, the first included (default 3; 1 = no retries)
+	Backoff     string `toml:"backoff,omitempty"`      // Wait before the first retry, doubled for each next one (Go duration, default "1s")
+	MaxBackoff  string `toml:"max_backoff,omitempty"`  // Longest single wait; a longer Retry-After moves on to the next backend (default "30s")
+}
+
+// Retry policy defaults, applied where [llm.retry] leaves a key unset.
+const (
+	DefaultRetryAttempts   = 3
+	DefaultRetryBackoff    = time.Second
+	DefaultRetryMaxBackoff = 30 * time.Second
+)
+
+// UsesFallbackChain reports whether calls go through the composite client:
+// a fallback list or a retry policy is configured.
+func (s LLMSettings) UsesFallbackChain() bool {
+	return len(s.Fallback) > 0 || s.Retry != RetrySettings{}
+}
+
+// Policy parses the retry settings, applying the defaults.
+func (r RetrySettings) Policy() (attempts int, backoff, maxBackoff time.Duration, err error) {
+	attempts, backoff, maxBackoff = r.MaxAttempts, DefaultRetryBackoff, DefaultRetryMaxBackoff
+	if attempts < 0 {
+		return 0, 0, 0, fmt.Errorf("llm.retry.max_attempts must not be negative, got %d", attempts)
+	}
+	if attempts == 0 {
+		attempts = DefaultRetryAttempts
+	}
+	if r.Backoff != "" {
+		if backoff, err = time.ParseDuration(r.Backoff); err != nil || backoff < 0 {
+			return 0, 0, 0, fmt.Errorf("bad llm.retry.backoff %q", r.Backoff)
+		}
+	}
+	if r.MaxBackoff != "" {
+		if maxBackoff, err = time.ParseDuration(r.MaxBackoff); err != nil || maxBackoff < 0 {
+			return 0, 0, 0, fmt.Errorf("bad llm.retry.max_backoff %q", r.MaxBackoff)
+		}
+	}
+	return attempts, backoff, maxBackoff, nil
+}
+
+// Equal reports whether s and o configure the same client. LLMSettings is not
+// comparable with == because of the fallback list.
+func (s LLMSettings) Equal(o LLMSettings) bool {
+	if len(s.Fallback) != len(o.Fallback) {
+		return false
+	}
+	for i := range s.Fallback {
+		if !s.Fallback[i].Equal(o.Fallback[i]) {
+			return false
+		}
+	}
+	a, b := s, o
+	a.Fallback, b.Fallback = nil, nil
+	return reflect.DeepEqual(a, b)
 }
 
 // ValidEfforts lists every reasoning-effort value accepted by the OpenAI API
@@ -463,6 +630,207 @@ type BashSettings struct {
 	WhitelistedCommands []string `toml:"whitelisted_commands,omitempty"` // Commands that don't require approval
 }
 
+// LSPSettings configures the language servers behind the Diagnostics,
+// GoToDefinition, FindReferences, Hover and Rename tools and the diagnostics
+// appended to Write/Edit/MultiEdit results. Built-in servers (gopls, pyright,
+// tsserver) are used whenever their command is on PATH; a [lsp.servers.<name>]
+// table overrides a built-in of the same name or adds a new one:
+//
+//	[lsp.servers.rust-analyzer]
+//	command    = "rust-analyzer"
+//	extensions = [".rs"]
+type LSPSettings struct {
+	Disabled bool                         `toml:"disabled,omitempty"` // turn the whole subsystem off
+	Servers  map[string]LSPServerSettings `toml:"servers,omitempty"`
+}
+
+// MemorySettings tunes the long-term memory pipeline. Each user message is
+// prefixed with the memories a budgeted recall finds relevant to it, and a
+// background pass distils new facts from the conversation whenever it is
+// compacted and when the session ends.
+type MemorySettings struct {
+	// DisableExtraction turns the background fact extraction off; the model can
+	// still store facts itself with the Remember tool.
+	DisableExtraction bool `toml:"disable_extraction,omitempty"`
+	// RecallBudgetTokens caps the recalled-memory block prepended to each user
+	// message. 0 selects memorydb.DefaultContextBudgetTokens; negative turns the
+	// injection off.
+	RecallBudgetTokens int `toml:"recall_budget_tokens,omitempty"`
+	// Embeddings adds semantic similarity to recall; off unless a model is set.
+	Embeddings EmbeddingSettings `toml:"embeddings,omitempty"`
+}
+
+// CompactionSettings is the [compaction] table. Automatic compaction runs when
+// the context reaches ThresholdPercent of the model's window; /compact runs it
+// on demand.
+type CompactionSettings struct {
+	CompactionPolicy
+	// Roles overrides the policy per role, e.g. [compaction.roles.claw]. A
+	// field left unset there inherits the table's.
+	Roles map[string]CompactionPolicy `toml:"roles,omitempty"`
+}
+
+// CompactionPolicy is a compaction trigger and strategy.
+type CompactionPolicy struct {
+	// ThresholdPercent is the share of the context window, in percent, that
+	// triggers compaction. 0 selects the default, 70.
+	ThresholdPercent float64 `toml:"threshold_percent,omitempty"`
+	// Strategy is "summary" (the default), "micro" or "structured"; see
+	// domain.CompactionStrategy.
+	Strategy string `toml:"strategy,omitempty"`
+}
+
+// ForRole returns the policy role runs under: its override's fields where
+// set, the table's otherwise.
+func (c CompactionSettings) ForRole(role string) CompactionPolicy {
+	p := c.CompactionPolicy
+	if r, ok := c.Roles[role]; ok {
+		if r.ThresholdPercent != 0 {
+			p.ThresholdPercent = r.ThresholdPercent
+		}
+		if r.Strategy != "" {
+			p.Strategy = r.Strategy
+		}
+	}
+	return p
+}
+
+func (p CompactionPolicy) validate() error {
+	if p.ThresholdPercent < 0 || p.ThresholdPercent > 100 {
+		return fmt.Errorf("threshold_percent %v must be between 0 (default) and 100", p.ThresholdPercent)
+	}
+	if p.Strategy != "" && !slices.Contains(domain.CompactionStrategies, domain.CompactionStrategy(p.Strategy)) {
+		return fmt.Errorf("invalid strategy %q (must be empty or one of %v)", p.Strategy, domain.CompactionStrategies)
+	}
+	return nil
+}
+
+// WebSettings is the [web] table. Whatever it says, fetches never reach
+// private, loopback or link-local addresses unless allow_private_networks is
+// set.
+type WebSettings struct {
+	WebPolicy
+	// Roles overrides the policy per role, e.g. [web.roles.claw]. A field left
+	// unset there inherits the table's, and deny lists add up.
+	Roles map[string]WebPolicy `toml:"roles,omitempty"`
+	// DisableArchive stops keeping fetched pages under WebArchiveDir. The
+	// archive is shared by every role, so this is table-wide only.
+	DisableArchive bool `toml:"disable_archive,omitempty"`
+}
+
+// WebPolicy is what the web tools may fetch.
+type WebPolicy struct {
+	// AllowDomains, when set, is the only domains (and their subdomains) the
+	// tools may fetch from.
+	AllowDomains []string `toml:"allow_domains,omitempty"`
+	// DenyDomains are never fetched, with their subdomains.
+	DenyDomains []string `toml:"deny_domains,omitempty"`
+	// AllowPrivateNetworks lets fetches reach localhost and the LAN.
+	AllowPrivateNetworks *bool `toml:"allow_private_networks,omitempty"`
+	// RespectRobots refuses paths a site's robots.txt disallows.
+	RespectRobots *bool `toml:"respect_robots,omitempty"`
+	// MaxBytes caps each response body; 0 selects the default, 50 MiB. A tool
+	// with a lower cap of its own keeps it.
+	MaxBytes int64 `toml:"max_bytes,omitempty"`
+	// TimeoutSeconds caps each request; 0 selects the default, 60.
+	TimeoutSeconds int `toml:"timeout_seconds,omitempty"`
+}
+
+// ForRole returns the policy role fetches under: its override's fields where
+// set, the table's otherwise, with both deny lists applied.
+func (w WebSettings) ForRole(role string) WebPolicy {
+	p := w.WebPolicy
+	r, ok := w.Roles[role]
+	if !ok {
+		return p
+	}
+	if r.AllowDomains != nil {
+		p.AllowDomains = r.AllowDomains
+	}
+	p.DenyDomains = append(slices.Clone(p.DenyDomains), r.DenyDomains...)
+	if r.AllowPrivateNetworks != nil {
+		p.AllowPrivateNetworks = r.AllowPrivateNetworks
+	}
+	if r.RespectRobots != nil {
+		p.RespectRobots = r.RespectRobots
+	}
+	if r.MaxBytes != 0 {
+		p.MaxBytes = r.MaxBytes
+	}
+	if r.TimeoutSeconds != 0 {
+		p.TimeoutSeconds = r.TimeoutSeconds
+	}
+	return p
+}
+
+func (p WebPolicy) validate() error {
+	if p.MaxBytes < 0 {
+		return errors.New("max_bytes must be zero (default) or positive")
+	}
+	if p.TimeoutSeconds < 0 {
+		return errors.New("timeout_seconds must be zero (default) or positive")
+	}
+	for _, d := range slices.Concat(p.AllowDomains, p.DenyDomains) {
+		if d = strings.TrimSpace(d); d == "" || strings.ContainsAny(d, ":/ ") {
+			return fmt.Errorf("invalid domain %q (want a bare host name such as \"example.com\")", d)
+		}
+	}
+	return nil
+}
+
+// Trace exporters.
+const (
+	TraceExporterJSONL = "jsonl"
+	TraceExporterOTLP  = "otlp"
+)
+
+// TraceSettings is the [trace] table.
+type TraceSettings struct {
+	// Exporter is "" (tracing off), "jsonl" (a trace file beside each session,
+	// read back with `klein trace show`) or "otlp" (OTLP over HTTP).
+	Exporter string `toml:"exporter,omitempty"`
+	// Endpoint is the OTLP/HTTP traces URL, path included, e.g.
+	// "http://localhost:4318/v1/traces".
+	// Empty defers to the OTEL_EXPORTER_OTLP_* environment variables.
+	Endpoint string `toml:"endpoint,omitempty"`
+}
+
+// TokenizerSettings is the [tokenizer] table.
+type TokenizerSettings struct {
+	// Fetch lets klein download a missing vocabulary (a few MB from
+	// openaipublic.blob.core.windows.net) into TokenizersDir the first time a
+	// count needs it. Off by default: counts are then estimates unless the
+	// vocabulary was placed there by hand.
+	Fetch bool `toml:"fetch,omitempty"`
+}
+
+// EmbeddingSettings is the [memory.embeddings] table: an OpenAI-compatible
+// /embeddings endpoint used to embed memories and recall queries.
+type EmbeddingSettings struct {
+	Model string `toml:"model,omitempty"` // e.g. "text-embedding-3-small"; empty disables embeddings
+	// BaseURL is the API root; empty selects https://api.openai.com/v1.
+	BaseURL string `toml:"base_url,omitempty"`
+	// APIKeyEnv names the environment variable holding the API key; empty
+	// selects OPENAI_API_KEY. A local server may need none.
+	APIKeyEnv  string `toml:"api_key_env,omitempty"`
+	Dimensions int    `toml:"dimensions,omitempty"` // shortened vectors, where the model supports it
+}
+
+// LSPServerSettings is one [lsp.servers.<name>] table. Empty fields keep the
+// built-in value when <name> is a built-in.
+type LSPServerSettings struct {
+	Disabled   bool              `toml:"disabled,omitempty"`
+	Command    string            `toml:"command,omitempty"`
+	Args       []string          `toml:"args,omitempty"`
+	Extensions []string          `toml:"extensions,omitempty"` // with the dot: [".go"]
+	Env        map[string]string `toml:"env,omitempty"`
+}
+
+// EnvSlice renders the env table as sorted "KEY=VAL" entries.
+func (s LSPServerSettings) EnvSlice() []string {
+	return envMapToSlice(s.Env)
+}
+
 // NewSettings creates new settings with in-memory repository
 func NewSettings() *Settings {
 	return NewSettingsWithRepository(infra.NewInMemorySettingsRepository())
@@ -531,6 +899,7 @@ func LoadSettings(configPath string) (*Settings, error) {
 	if err := settings.Load(); err != nil {
 		return nil, fmt.Errorf("invalid settings file %s: %w", path, err)
 	}
+	settings.path = path
 
 	return settings, nil
 }
@@ -671,6 +1040,60 @@ func applyDefaults(settings *Settings) {
 	}
 }
 
+// requireAPIKey checks that the API key a chat backend reads from the
+// environment is set.
+func requireAPIKey(backend string) error {
+	switch normalizeBackend(backend) {
+	case "anthropic":
+		if os.Getenv("ANTHROPIC_API_KEY") == "" {
+			return fmt.Errorf("Anthropic API key is required (set ANTHROPIC_API_KEY environment variable)")
+		}
+	case "openai":
+		if os.Getenv("OPENAI_API_KEY") == "" {
+			return fmt.Errorf("OpenAI API key is required (set OPENAI_API_KEY environment variable)")
+		}
+	case "gemini":
+		if os.Getenv("GEMINI_API_KEY") == "" {
+			return fmt.Errorf("Gemini API key is required (set GEMINI_API_KEY environment variable)")
+		}
+	}
+	return nil
+}
+
+// validateRetryChain checks [llm.retry] and each [[llm.fallback]] entry. Only
+// chat backends can take part: a whole-agent backend owns the entire turn, so
+// there is no single call to retry or hand over.
+func validateRetryChain(llm LLMSettings) error {
+	if !llm.UsesFallbackChain() {
+		return nil
+	}
+	if IsAgentServerBackend(llm.Backend) {
+		return fmt.Errorf("llm.retry and llm.fallback do not apply to the %s backend", llm.Backend)
+	}
+	if _, _, _, err := llm.Retry.Policy(); err != nil {
+		return err
+	}
+	for i, fb := range llm.Fallback {
+		where := fmt.Sprintf("llm.fallback[%d]", i)
+		if !isChatBackend(fb.Backend) {
+			return fmt.Errorf("%s: backend %q cannot be a fallback (want anthropic, openai, or gemini)", where, fb.Backend)
+		}
+		if fb.Model == "" {
+			return fmt.Errorf("%s: model is required", where)
+		}
+		if !IsValidEffort(fb.Effort) {
+			return fmt.Errorf("%s: invalid effort %q (must be empty or one of %v)", where, fb.Effort, ValidEfforts)
+		}
+		if fb.UsesFallbackChain() {
+			return fmt.Errorf("%s: retry and fallback are set once, on [llm]", where)
+		}
+		if err := requireAPIKey(fb.Backend); err != nil {
+			return fmt.Errorf("%s: %w", where, err)
+		}
+	}
+	return nil
+}
+
 // ValidateSettings validates the settings configuration
 func ValidateSettings(settings *Settings) error {
 	// Validate LLM settings
@@ -701,25 +1124,11 @@ func ValidateSettings(settings *Settings) error {
 			settings.LLM.Effort, ValidEfforts)
 	}
 
-	if settings.LLM.Backend == "anthropic" {
-		// Check environment variable for API key
-		if os.Getenv("ANTHROPIC_API_KEY") == "" {
-			return fmt.Errorf("Anthropic API key is required (set ANTHROPIC_API_KEY environment variable)")
-		}
-	}
-
-	if settings.LLM.Backend == "openai" {
-		// Check environment variable for API key
-		if os.Getenv("OPENAI_API_KEY") == "" {
-			return fmt.Errorf("OpenAI API key is required (set OPENAI_API_KEY environment variable)")
-		}
+	if err := requireAPIKey(settings.LLM.Backend); err != nil {
+		return err
 	}
-
-	if settings.LLM.Backend == "gemini" {
-		// Check environment variable for API key
-		if os.Getenv("GEMINI_API_KEY") == "" {
-			return fmt.Errorf("Gemini API key is required (set GEMINI_API_KEY environment variable)")
-		}
+	if err := validateRetryChain(settings.LLM); err != nil {
+		return err
 	}
 
 	// Validate Agent settings
@@ -732,6 +1141,31 @@ func ValidateSettings(settings *Settings) error {
 		return errors.New("max_tool_result_runes must be zero (default) or positive")
 	}
 
+	if err := settings.Compaction.validate(); err != nil {
+		return fmt.Errorf("compaction: %w", err)
+	}
+	for role, p := range settings.Compaction.Roles {
+		if err := p.validate(); err != nil {
+			return fmt.Errorf("compaction.roles.%s: %w", role, err)
+		}
+	}
+
+	if err := settings.Web.validate(); err != nil {
+		return fmt.Errorf("web: %w", err)
+	}
+	for role, p := range settings.Web.Roles {
+		if err := p.validate(); err != nil {
+			return fmt.Errorf("web.roles.%s: %w", role, err)
+		}
+	}
+
+	switch settings.Trace.Exporter {
+	case "", TraceExporterJSONL, TraceExporterOTLP:
+	default:
+		return fmt.Errorf("invalid trace.exporter %q (must be empty, %q or %q)",
+			settings.Trace.Exporter, TraceExporterJSONL, TraceExporterOTLP)
+	}
+
 	// Validate MCP server configurations
 	for _, serverConfig := range settings.MCP.Servers {
 		if err := ValidateMCPServerConfig(serverConfig); err != nil {
@@ -839,7 +1273,8 @@ func createDefaultSettingsFile() (*Settings, error) {
 // can explain itself. An encoder would emit every zero value with no hint what
 // any of them mean.
 const defaultSettingsTemplate = `# klein settings — see doc/CONFIGS.md for every field.
-# Edit freely; klein only rewrites the [mcp.*] tables, and only via ` + "`klein mcp`" + `.
+# Edit freely; klein only rewrites the [mcp.*] and [plugins.*] tables, and only
+# via ` + "`klein mcp`" + ` and ` + "`klein plugin`" + `.
 
 [llm]
 backend = "%s"
@@ -873,6 +1308,8 @@ func createSettingsFileAtPath(settingsPath string) (*Settings, error) {
 		return GetDefaultSettings(), nil
 	}
 
+	settings.path = settingsPath
+
 	// Log success message
 	pkgLogger.NewComponentLogger("settings").InfoWithIntention(pkgLogger.IntentionConfig, "Created default settings file", "path", settingsPath)
 	pkgLogger.NewComponentLogger("settings").InfoWithIntention(pkgLogger.IntentionStatus, "You can edit this file to customize your configuration")
@@ -3,8 +3,11 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+
+	"github.com/BurntSushi/toml"
 )
 
 // testBackend is a sample backend value used across the load tests.
@@ -184,3 +187,116 @@ func TestDefaultAndUnsetBackendAreOpenAI(t *testing.T) {
 		t.Errorf("unset backend = %q, want %q", b, DefaultBackend)
 	}
 }
+
+func TestCompactionForRole(t *testing.T) {
+	t.Parallel()
+	var s Settings
+	if _, err := toml.Decode(`
+[compaction]
+threshold_percent = 60
+strategy = "structured"
+
+[compaction.roles.claw]
+strategy = "micro"
+
+[compaction.roles.review]
+threshold_percent = 85
+`, &s); err != nil {
+		t.Fatal(err)
+	}
+	for role, want := range map[string]CompactionPolicy{
+		"code":   {ThresholdPercent: 60, Strategy: "structured"},
+		"claw":   {ThresholdPercent: 60, Strategy: "micro"},
+		"review": {ThresholdPercent: 85, Strategy: "structured"},
+	} {
+		if got := s.Compaction.ForRole(role); got != want {
+			t.Errorf("ForRole(%q) = %+v, want %+v", role, got, want)
+		}
+	}
+
+	for _, bad := range []CompactionSettings{
+		{CompactionPolicy: CompactionPolicy{Strategy: "drop-everything"}},
+		{CompactionPolicy: CompactionPolicy{ThresholdPercent: 120}},
+		{Roles: map[string]CompactionPolicy{"claw": {ThresholdPercent: -1}}},
+	} {
+		v := GetDefaultSettings()
+		v.LLM.Backend = testBackend
+		v.Compaction = bad
+		if err := ValidateSettings(v); err == nil {
+			t.Errorf("%+v validated", bad)
+		}
+	}
+}
+
+func TestWebForRole(t *testing.T) {
+	t.Parallel()
+	var s Settings
+	if _, err := toml.Decode(`
+[web]
+deny_domains = ["ads.example"]
+allow_private_networks = true
+timeout_seconds = 20
+
+[web.roles.claw]
+allow_domains = ["example.com"]
+deny_domains = ["internal.example.com"]
+allow_private_networks = false
+respect_robots = true
+`, &s); err != nil {
+		t.Fatal(err)
+	}
+	code := s.Web.ForRole("code")
+	if !*code.AllowPrivateNetworks || code.AllowDomains != nil || code.TimeoutSeconds != 20 {
+		t.Errorf("ForRole(code) = %+v", code)
+	}
+	claw := s.Web.ForRole("claw")
+	if *claw.AllowPrivateNetworks || !*claw.RespectRobots || claw.TimeoutSeconds != 20 ||
+		!reflect.DeepEqual(claw.AllowDomains, []string{"example.com"}) ||
+		!reflect.DeepEqual(claw.DenyDomains, []string{"ads.example", "internal.example.com"}) {
+		t.Errorf("ForRole(claw) = %+v", claw)
+	}
+
+	for _, bad := range []WebSettings{
+		{WebPolicy: WebPolicy{AllowDomains: []string{"https://example.com"}}},
+		{WebPolicy: WebPolicy{MaxBytes: -1}},
+		{Roles: map[string]WebPolicy{"claw": {DenyDomains: []string{""}}}},
+	} {
+		v := GetDefaultSettings()
+		v.LLM.Backend = testBackend
+		v.Web = bad
+		if err := ValidateSettings(v); err == nil {
+			t.Errorf("%+v validated", bad)
+		}
+	}
+}
+
+func TestValidateTraceExporter(t *testing.T) {
+	t.Parallel()
+	for exporter, ok := range map[string]bool{"": true, "jsonl": true, "otlp": true, "zipkin": false} {
+		s := GetDefaultSettings()
+		s.LLM.Backend = testBackend
+		s.Trace.Exporter = exporter
+		if err := ValidateSettings(s); (err == nil) != ok {
+			t.Errorf("exporter %q: ValidateSettings = %v", exporter, err)
+		}
+	}
+}
+
+func TestTokenizerFetch(t *testing.T) {
+	t.Parallel()
+	if GetDefaultSettings().Tokenizer.Fetch {
+		t.Error("vocabulary fetch on by default")
+	}
+	path := filepath.Join(t.TempDir(), "settings.toml")
+	content := []byte("[llm]\nbackend = \"" + testBackend + "\"\n\n[tokenizer]\nfetch = true\n")
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	settings, err := LoadSettings(path)
+	if err != nil {
+		t.Fatalf("LoadSettings: %v", err)
+	}
+	if !settings.Tokenizer.Fetch {
+		t.Error("[tokenizer] fetch = true left fetching off")
+	}
+}
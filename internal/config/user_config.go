@@ -106,6 +106,16 @@ func (c *UserConfig) GetProjectToolResultsDir(projectPath string) (string, error
 	return toolResultsDir, nil
 }
 
+// GetProjectCodeIndexFile returns the path of the project's persisted symbol
+// index (see internal/codeindex). The file is created on the first CodeSearch.
+func (c *UserConfig) GetProjectCodeIndexFile(projectPath string) (string, error) {
+	projectDir, err := c.GetProjectDataDir(projectPath)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(projectDir, "codeindex.gob"), nil
+}
+
 // GetProjectTodoFile returns the todo file path for a specific project
 func (c *UserConfig) GetProjectTodoFile(projectPath string) (string, error) {
 	projectDir, err := c.GetProjectDataDir(projectPath)
@@ -0,0 +1,52 @@
+package connectrpc
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/fpt/klein-cli/internal/app"
+	agentv1 "github.com/fpt/klein-cli/internal/gen/agentv1"
+)
+
+// remoteApprover forwards one session's approval-gated tool calls to the
+// client as ApprovalRequest events and waits for the matching
+// ApprovalResponse. Anything but an explicit allow — a timeout, a cancelled
+// turn, no stream to ask on — denies.
+type remoteApprover struct {
+	client  *clientChannel
+	timeout time.Duration
+}
+
+// approve implements app.ToolApprover.
+func (r *remoteApprover) approve(ctx context.Context, req app.ToolApprovalRequest) app.ApprovalDecision {
+	argsJSON, _ := json.Marshal(req.Arguments)
+	ev, err := r.client.request(ctx, "approval", r.timeout, func(id string) *agentv1.InvokeEvent {
+		return &agentv1.InvokeEvent{
+			Event: &agentv1.InvokeEvent_ApprovalRequest{
+				ApprovalRequest: &agentv1.ApprovalRequest{
+					RequestId:      id,
+					ToolName:       req.ToolName,
+					ArgumentsJson:  string(argsJSON),
+					Description:    req.Description,
+					TimeoutSeconds: int32(r.timeout / time.Second),
+				},
+			},
+		}
+	})
+	if err != nil {
+		return app.ApprovalDeny
+	}
+	return toAppDecision(ev.GetApprovalResponse().GetDecision())
+}
+
+func toAppDecision(d agentv1.ApprovalDecision) app.ApprovalDecision {
+	switch d {
+	case agentv1.ApprovalDecision_APPROVAL_ALLOW_ONCE:
+		return app.ApprovalAllowOnce
+	case agentv1.ApprovalDecision_APPROVAL_ALLOW_SESSION:
+		return app.ApprovalAllowSession
+	default:
+		return app.ApprovalDeny
+	}
+}
@@ -0,0 +1,81 @@
+package connectrpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fpt/klein-cli/internal/app"
+	agentv1 "github.com/fpt/klein-cli/internal/gen/agentv1"
+)
+
+// answerWith attaches a fake Invoke stream that answers every ApprovalRequest
+// with d, and returns the requests it saw.
+func answerWith(c *clientChannel, d agentv1.ApprovalDecision) *[]*agentv1.ApprovalRequest {
+	var seen []*agentv1.ApprovalRequest
+	c.attach(func(ev *agentv1.InvokeEvent) {
+		req := ev.GetApprovalRequest()
+		seen = append(seen, req)
+		go c.resolve(req.RequestId, &agentv1.ClientEvent{
+			Event: &agentv1.ClientEvent_ApprovalResponse{
+				ApprovalResponse: &agentv1.ApprovalResponse{RequestId: req.RequestId, Decision: d},
+			},
+		})
+	})
+	return &seen
+}
+
+func TestRemoteApprover_ForwardsDecision(t *testing.T) {
+	cases := map[agentv1.ApprovalDecision]app.ApprovalDecision{
+		agentv1.ApprovalDecision_APPROVAL_ALLOW_ONCE:           app.ApprovalAllowOnce,
+		agentv1.ApprovalDecision_APPROVAL_ALLOW_SESSION:        app.ApprovalAllowSession,
+		agentv1.ApprovalDecision_APPROVAL_DENY:                 app.ApprovalDeny,
+		agentv1.ApprovalDecision_APPROVAL_DECISION_UNSPECIFIED: app.ApprovalDeny,
+	}
+	for in, want := range cases {
+		c := newClientChannel()
+		r := &remoteApprover{client: c, timeout: time.Minute}
+		seen := answerWith(c, in)
+		got := r.approve(context.Background(), app.ToolApprovalRequest{
+			ToolName:  "Bash",
+			Arguments: map[string]any{"command": "make"},
+		})
+		if got != want {
+			t.Errorf("%v: got %v, want %v", in, got, want)
+		}
+		if len(*seen) != 1 || (*seen)[0].ToolName != "Bash" || (*seen)[0].ArgumentsJson != `{"command":"make"}` ||
+			(*seen)[0].TimeoutSeconds != 60 {
+			t.Errorf("request = %+v", *seen)
+		}
+	}
+}
+
+func TestRemoteApprover_DeniesByDefault(t *testing.T) {
+	// No Invoke stream to ask on.
+	c := newClientChannel()
+	r := &remoteApprover{client: c, timeout: time.Minute}
+	if got := r.approve(context.Background(), app.ToolApprovalRequest{ToolName: "Write"}); got != app.ApprovalDeny {
+		t.Errorf("detached: got %v", got)
+	}
+
+	// Nobody answers.
+	r.timeout = 20 * time.Millisecond
+	detach := c.attach(func(*agentv1.InvokeEvent) {})
+	if got := r.approve(context.Background(), app.ToolApprovalRequest{ToolName: "Write"}); got != app.ApprovalDeny {
+		t.Errorf("timeout: got %v", got)
+	}
+
+	// The turn is cancelled while waiting.
+	r.timeout = time.Minute
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if got := r.approve(ctx, app.ToolApprovalRequest{ToolName: "Write"}); got != app.ApprovalDeny {
+		t.Errorf("cancelled: got %v", got)
+	}
+	detach()
+
+	// A late or unknown answer is reported, not applied.
+	if c.resolve("approval-1", &agentv1.ClientEvent{}) {
+		t.Error("resolve should report that nothing was waiting")
+	}
+}
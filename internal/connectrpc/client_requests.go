@@ -0,0 +1,108 @@
+package connectrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	agentv1 "github.com/fpt/klein-cli/internal/gen/agentv1"
+)
+
+var (
+	errNoClientStream = errors.New("no client is attached (no Invoke stream is open)")
+	errClientTimeout  = errors.New("the client did not answer in time")
+)
+
+// clientChannel carries one session's server → client requests. A request
+// goes out as an InvokeEvent on the open Invoke stream; the client answers
+// with a ClientEvent through the unary SubmitClientEvent, which is matched
+// back to the waiting request by its request_id. Between turns there is no
+// stream, so nothing can be asked.
+type clientChannel struct {
+	mu      sync.Mutex
+	send    func(*agentv1.InvokeEvent) // the active Invoke stream; nil between turns
+	pending map[string]chan *agentv1.ClientEvent
+	nextID  int
+}
+
+func newClientChannel() *clientChannel {
+	return &clientChannel{pending: make(map[string]chan *agentv1.ClientEvent)}
+}
+
+// attach points the channel at an Invoke stream and returns the detach func.
+func (c *clientChannel) attach(send func(*agentv1.InvokeEvent)) func() {
+	c.mu.Lock()
+	c.send = send
+	c.mu.Unlock()
+	return func() {
+		c.mu.Lock()
+		c.send = nil
+		c.mu.Unlock()
+	}
+}
+
+// request sends the event build returns for a fresh request id and waits for
+// the client's answer, the timeout, or ctx.
+func (c *clientChannel) request(ctx context.Context, kind string, timeout time.Duration, build func(id string) *agentv1.InvokeEvent) (*agentv1.ClientEvent, error) {
+	c.mu.Lock()
+	send := c.send
+	if send == nil {
+		c.mu.Unlock()
+		return nil, errNoClientStream
+	}
+	c.nextID++
+	id := fmt.Sprintf("%s-%d", kind, c.nextID)
+	ch := make(chan *agentv1.ClientEvent, 1)
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	send(build(id))
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case ev := <-ch:
+		return ev, nil
+	case <-timer.C:
+		return nil, errClientTimeout
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// resolve delivers a client's answer. It reports false when nothing is
+// waiting on requestID (already answered, timed out, or never issued).
+func (c *clientChannel) resolve(requestID string, ev *agentv1.ClientEvent) bool {
+	c.mu.Lock()
+	ch, ok := c.pending[requestID]
+	if ok {
+		delete(c.pending, requestID)
+	}
+	c.mu.Unlock()
+	if ok {
+		ch <- ev
+	}
+	return ok
+}
+
+// clientEventRequestID returns the request id a client event answers.
+func clientEventRequestID(ev *agentv1.ClientEvent) (string, bool) {
+	switch e := ev.Event.(type) {
+	case *agentv1.ClientEvent_ApprovalResponse:
+		return e.ApprovalResponse.GetRequestId(), true
+	case *agentv1.ClientEvent_FileReadResponse:
+		return e.FileReadResponse.GetRequestId(), true
+	case *agentv1.ClientEvent_CommandDispatchResponse:
+		return e.CommandDispatchResponse.GetRequestId(), true
+	default:
+		return "", false
+	}
+}
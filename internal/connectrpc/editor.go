@@ -0,0 +1,158 @@
+package connectrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	agentv1 "github.com/fpt/klein-cli/internal/gen/agentv1"
+	"github.com/fpt/klein-cli/internal/repository"
+	"github.com/fpt/klein-cli/internal/tool"
+)
+
+const (
+	// bufferReadTimeout bounds a RequestFileRead round trip. Reads fall back to
+	// the disk, so a slow editor only costs latency, never a failed read.
+	bufferReadTimeout = 5 * time.Second
+	// terminalDispatchTimeout bounds an ExecuteCommandRequest acknowledgement
+	// (the client confirms it handed the command to a terminal, not that it
+	// finished).
+	terminalDispatchTimeout = 30 * time.Second
+	// editorTerminalName is the terminal the client is asked to use.
+	editorTerminalName = "klein"
+)
+
+// editorFilesystem reads through an attached editor so the agent sees unsaved
+// buffer contents. Every other operation, and any read the editor cannot
+// answer (no open Invoke stream, an error, a timeout), goes to the disk.
+type editorFilesystem struct {
+	repository.FilesystemRepository
+	client *clientChannel
+}
+
+func (f *editorFilesystem) ReadFile(ctx context.Context, path string) ([]byte, error) {
+	ev, err := f.client.request(ctx, "read", bufferReadTimeout, func(id string) *agentv1.InvokeEvent {
+		return &agentv1.InvokeEvent{
+			Event: &agentv1.InvokeEvent_RequestFileRead{
+				RequestFileRead: &agentv1.RequestFileRead{RequestId: id, Path: path, Reason: "agent read"},
+			},
+		}
+	})
+	if err == nil {
+		if resp := ev.GetFileReadResponse(); resp != nil && resp.Error == "" {
+			return []byte(resp.Content), nil
+		}
+	}
+	return f.FilesystemRepository.ReadFile(ctx, path)
+}
+
+// editorTerminal returns a tool.TerminalDispatcher that asks the client to run
+// commands in its integrated terminal.
+func editorTerminal(client *clientChannel, workingDir string) tool.TerminalDispatcher {
+	return func(ctx context.Context, command, _ string) (string, error) {
+		ev, err := client.request(ctx, "term", terminalDispatchTimeout, func(id string) *agentv1.InvokeEvent {
+			return &agentv1.InvokeEvent{
+				Event: &agentv1.InvokeEvent_ExecuteCommandRequest{
+					ExecuteCommandRequest: &agentv1.ExecuteCommandRequest{
+						RequestId:    id,
+						Command:      command,
+						Cwd:          workingDir,
+						TerminalName: editorTerminalName,
+						Reveal:       true,
+					},
+				},
+			}
+		})
+		if err != nil {
+			return "", err
+		}
+		resp := ev.GetCommandDispatchResponse()
+		if resp == nil {
+			return "", errors.New("the editor answered with the wrong event type")
+		}
+		if !strings.EqualFold(resp.Status, "SENT") {
+			if resp.Error != "" {
+				return "", errors.New(resp.Error)
+			}
+			return "", fmt.Errorf("the editor reported status %q", resp.Status)
+		}
+		if resp.TerminalId != "" {
+			return resp.TerminalId, nil
+		}
+		return editorTerminalName, nil
+	}
+}
+
+var (
+	todoStatusToProto = map[string]agentv1.TodoStatus{
+		"pending":     agentv1.TodoStatus_TODO_PENDING,
+		"in_progress": agentv1.TodoStatus_TODO_IN_PROGRESS,
+		"completed":   agentv1.TodoStatus_TODO_COMPLETED,
+		"done":        agentv1.TodoStatus_TODO_COMPLETED,
+	}
+	todoPriorityToProto = map[string]agentv1.TodoPriority{
+		"low":    agentv1.TodoPriority_TODO_LOW,
+		"medium": agentv1.TodoPriority_TODO_MEDIUM,
+		"high":   agentv1.TodoPriority_TODO_HIGH,
+	}
+)
+
+func todosToProto(items []tool.TodoItem) []*agentv1.TodoItem {
+	out := make([]*agentv1.TodoItem, len(items))
+	for i, it := range items {
+		out[i] = &agentv1.TodoItem{
+			Id:       it.ID,
+			Content:  it.Content,
+			Status:   todoStatusToProto[it.Status],
+			Priority: todoPriorityToProto[it.Priority],
+			Created:  it.Created,
+			Updated:  it.Updated,
+		}
+	}
+	return out
+}
+
+// todosFromProto converts an editor's list. Unspecified enums become "" and
+// are rejected by TodoToolManager's validation, like any other bad value.
+func todosFromProto(items []*agentv1.TodoItem) []tool.TodoItem {
+	out := make([]tool.TodoItem, len(items))
+	for i, it := range items {
+		out[i] = tool.TodoItem{
+			ID:       it.Id,
+			Content:  it.Content,
+			Status:   todoStatusFromProto(it.Status),
+			Priority: todoPriorityFromProto(it.Priority),
+			Created:  it.Created,
+			Updated:  it.Updated,
+		}
+	}
+	return out
+}
+
+func todoStatusFromProto(s agentv1.TodoStatus) string {
+	switch s {
+	case agentv1.TodoStatus_TODO_PENDING:
+		return "pending"
+	case agentv1.TodoStatus_TODO_IN_PROGRESS:
+		return "in_progress"
+	case agentv1.TodoStatus_TODO_COMPLETED:
+		return "completed"
+	default:
+		return ""
+	}
+}
+
+func todoPriorityFromProto(p agentv1.TodoPriority) string {
+	switch p {
+	case agentv1.TodoPriority_TODO_LOW:
+		return "low"
+	case agentv1.TodoPriority_TODO_MEDIUM:
+		return "medium"
+	case agentv1.TodoPriority_TODO_HIGH:
+		return "high"
+	default:
+		return ""
+	}
+}
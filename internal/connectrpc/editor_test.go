@@ -0,0 +1,212 @@
+package connectrpc
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+
+	"github.com/fpt/klein-cli/internal/config"
+	agentv1 "github.com/fpt/klein-cli/internal/gen/agentv1"
+	"github.com/fpt/klein-cli/internal/infra"
+	pkgLogger "github.com/fpt/klein-cli/pkg/logger"
+)
+
+// answerEach attaches a fake Invoke stream that answers every request with
+// the ClientEvent reply builds for it.
+func answerEach(c *clientChannel, reply func(*agentv1.InvokeEvent) *agentv1.ClientEvent) {
+	c.attach(func(ev *agentv1.InvokeEvent) {
+		answer := reply(ev)
+		id, _ := clientEventRequestID(answer)
+		go c.resolve(id, answer)
+	})
+}
+
+func TestEditorFilesystem_ReadsBuffers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "main.go")
+	if err := os.WriteFile(path, []byte("on disk"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c := newClientChannel()
+	fs := &editorFilesystem{FilesystemRepository: infra.NewOSFilesystemRepository(), client: c}
+	ctx := context.Background()
+
+	// No Invoke stream: straight to the disk.
+	if got, err := fs.ReadFile(ctx, path); err != nil || string(got) != "on disk" {
+		t.Errorf("detached: %q, %v", got, err)
+	}
+
+	answerEach(c, func(ev *agentv1.InvokeEvent) *agentv1.ClientEvent {
+		req := ev.GetRequestFileRead()
+		return &agentv1.ClientEvent{Event: &agentv1.ClientEvent_FileReadResponse{
+			FileReadResponse: &agentv1.FileReadResponse{RequestId: req.RequestId, Content: "unsaved " + filepath.Base(req.Path)},
+		}}
+	})
+	if got, err := fs.ReadFile(ctx, path); err != nil || string(got) != "unsaved main.go" {
+		t.Errorf("buffer: %q, %v", got, err)
+	}
+
+	// The editor cannot read it: the disk answers.
+	answerEach(c, func(ev *agentv1.InvokeEvent) *agentv1.ClientEvent {
+		return &agentv1.ClientEvent{Event: &agentv1.ClientEvent_FileReadResponse{
+			FileReadResponse: &agentv1.FileReadResponse{RequestId: ev.GetRequestFileRead().RequestId, Error: "not open"},
+		}}
+	})
+	if got, err := fs.ReadFile(ctx, path); err != nil || string(got) != "on disk" {
+		t.Errorf("editor error: %q, %v", got, err)
+	}
+}
+
+func TestEditorTerminal(t *testing.T) {
+	c := newClientChannel()
+	dispatch := editorTerminal(c, "/work")
+	if _, err := dispatch(context.Background(), "make", ""); !errors.Is(err, errNoClientStream) {
+		t.Errorf("detached: %v", err)
+	}
+
+	var seen *agentv1.ExecuteCommandRequest
+	status := "SENT"
+	answerEach(c, func(ev *agentv1.InvokeEvent) *agentv1.ClientEvent {
+		seen = ev.GetExecuteCommandRequest()
+		return &agentv1.ClientEvent{Event: &agentv1.ClientEvent_CommandDispatchResponse{
+			CommandDispatchResponse: &agentv1.CommandDispatchResponse{RequestId: seen.RequestId, Status: status, TerminalId: "t1"},
+		}}
+	})
+	name, err := dispatch(context.Background(), "npm run dev", "dev server")
+	if err != nil || name != "t1" {
+		t.Fatalf("dispatch: %q, %v", name, err)
+	}
+	if seen.Command != "npm run dev" || seen.Cwd != "/work" || seen.TerminalName != editorTerminalName || !seen.Reveal {
+		t.Errorf("request = %+v", seen)
+	}
+
+	status = "FAILED"
+	if _, err := dispatch(context.Background(), "make", ""); err == nil {
+		t.Error("a failed dispatch should be an error")
+	}
+}
+
+func startTestSession(t *testing.T, srv *AgentServer) string {
+	t.Helper()
+	resp, err := srv.StartSession(context.Background(), connect.NewRequest(&agentv1.StartSessionRequest{
+		Settings: &agentv1.Settings{WorkingDir: t.TempDir()},
+	}))
+	if err != nil {
+		t.Fatalf("StartSession: %v", err)
+	}
+	return resp.Msg.SessionId
+}
+
+func newTestServer(t *testing.T) *AgentServer {
+	t.Helper()
+	t.Setenv("OPENAI_API_KEY", "test") // the ollama backend speaks the OpenAI API; nothing is called
+	settings := config.GetDefaultSettings()
+	settings.LLM = config.GetDefaultLLMSettingsForBackend("ollama")
+	return NewAgentServer(settings, nil, pkgLogger.NewLogger(pkgLogger.LogLevelError), t.TempDir(), nil)
+}
+
+func TestServerTodos(t *testing.T) {
+	srv := newTestServer(t)
+	id := startTestSession(t, srv)
+	ctx := context.Background()
+
+	written, err := srv.WriteTodos(ctx, connect.NewRequest(&agentv1.WriteTodosRequest{
+		SessionId: id,
+		Items: []*agentv1.TodoItem{
+			{Id: "1", Content: "review diff", Status: agentv1.TodoStatus_TODO_IN_PROGRESS, Priority: agentv1.TodoPriority_TODO_HIGH},
+		},
+	}))
+	if err != nil {
+		t.Fatalf("WriteTodos: %v", err)
+	}
+	if it := written.Msg.Items[0]; it.Created == "" || it.Status != agentv1.TodoStatus_TODO_IN_PROGRESS {
+		t.Errorf("written = %+v", it)
+	}
+
+	got, err := srv.GetTodos(ctx, connect.NewRequest(&agentv1.GetTodosRequest{SessionId: id}))
+	if err != nil || len(got.Msg.Items) != 1 || got.Msg.Items[0].Content != "review diff" {
+		t.Fatalf("GetTodos: %+v, %v", got, err)
+	}
+
+	_, err = srv.WriteTodos(ctx, connect.NewRequest(&agentv1.WriteTodosRequest{
+		SessionId: id,
+		Items:     []*agentv1.TodoItem{{Id: "1", Content: "no status", Priority: agentv1.TodoPriority_TODO_LOW}},
+	}))
+	if connect.CodeOf(err) != connect.CodeInvalidArgument {
+		t.Errorf("invalid list: %v", err)
+	}
+}
+
+func TestServerSetSettings(t *testing.T) {
+	srv := newTestServer(t)
+	id := startTestSession(t, srv)
+	ctx := context.Background()
+	thinking := true
+
+	_, err := srv.SetSettings(ctx, connect.NewRequest(&agentv1.SetSettingsRequest{
+		SessionId: id,
+		Settings:  &agentv1.Settings{Model: "qwen3:8b", MaxIterations: 5, Thinking: &thinking},
+	}))
+	if err != nil {
+		t.Fatalf("SetSettings: %v", err)
+	}
+
+	// Settings cannot change under a running turn.
+	session, _ := srv.getSession(id)
+	session.turnMu.Lock()
+	_, err = srv.SetSettings(ctx, connect.NewRequest(&agentv1.SetSettingsRequest{
+		SessionId: id,
+		Settings:  &agentv1.Settings{MaxIterations: 9},
+	}))
+	session.turnMu.Unlock()
+	if connect.CodeOf(err) != connect.CodeFailedPrecondition {
+		t.Errorf("busy session: %v", err)
+	}
+}
+
+func TestServerSubmitClientEvent(t *testing.T) {
+	srv := newTestServer(t)
+	id := startTestSession(t, srv)
+	session, _ := srv.getSession(id)
+	ctx := context.Background()
+
+	// Unanswerable: nothing is pending.
+	resp, err := srv.SubmitClientEvent(ctx, connect.NewRequest(&agentv1.ClientEvent{
+		SessionId: id,
+		Event: &agentv1.ClientEvent_FileReadResponse{
+			FileReadResponse: &agentv1.FileReadResponse{RequestId: "read-1"},
+		},
+	}))
+	if err != nil || resp.Msg.Status != "ERROR" {
+		t.Errorf("stale answer: %+v, %v", resp, err)
+	}
+
+	// A pending buffer read is answered through the RPC.
+	requests := make(chan string, 1)
+	detach := session.client.attach(func(ev *agentv1.InvokeEvent) { requests <- ev.GetRequestFileRead().RequestId })
+	defer detach()
+	result := make(chan string, 1)
+	go func() {
+		ev, _ := session.client.request(ctx, "read", time.Minute, func(rid string) *agentv1.InvokeEvent {
+			return &agentv1.InvokeEvent{Event: &agentv1.InvokeEvent_RequestFileRead{RequestFileRead: &agentv1.RequestFileRead{RequestId: rid}}}
+		})
+		result <- ev.GetFileReadResponse().GetContent()
+	}()
+	rid := <-requests
+	resp, err = srv.SubmitClientEvent(ctx, connect.NewRequest(&agentv1.ClientEvent{
+		SessionId: id,
+		Event: &agentv1.ClientEvent_FileReadResponse{
+			FileReadResponse: &agentv1.FileReadResponse{RequestId: rid, Content: "buffer"},
+		},
+	}))
+	if err != nil || resp.Msg.Status != "OK" {
+		t.Fatalf("answer: %+v, %v", resp, err)
+	}
+	if got := <-result; got != "buffer" {
+		t.Errorf("request got %q", got)
+	}
+}
@@ -4,12 +4,17 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"connectrpc.com/connect"
 
@@ -18,7 +23,9 @@ import (
 	agentv1 "github.com/fpt/klein-cli/internal/gen/agentv1"
 	"github.com/fpt/klein-cli/internal/gen/agentv1/agentv1connect"
 	"github.com/fpt/klein-cli/internal/infra"
+	"github.com/fpt/klein-cli/internal/report"
 	"github.com/fpt/klein-cli/internal/skill"
+	"github.com/fpt/klein-cli/internal/tool"
 	"github.com/fpt/klein-cli/pkg/agent/domain"
 	"github.com/fpt/klein-cli/pkg/agent/events"
 	pkgLogger "github.com/fpt/klein-cli/pkg/logger"
@@ -47,6 +54,12 @@ type AgentServer struct {
 type sessionState struct {
 	agent          *app.Agent
 	persistenceKey string
+	client         *clientChannel // server → client requests (approvals, buffer reads, terminal)
+	remoteApproval bool
+
+	// turnMu is held for the length of an Invoke. The agent is not safe for
+	// concurrent turns, and SetSettings may only swap its client between them.
+	turnMu sync.Mutex
 }
 
 // NewAgentServer creates a Connect AgentService handler.
@@ -83,6 +96,9 @@ func (s *AgentServer) StartSession(ctx context.Context, req *connect.Request[age
 		if msg.Settings.MaxIterations > 0 {
 			settings.Agent.MaxIterations = int(msg.Settings.MaxIterations)
 		}
+		if msg.Settings.Thinking != nil {
+			settings.LLM.Thinking = *msg.Settings.Thinking
+		}
 	}
 
 	workingDir := "."
@@ -90,12 +106,24 @@ func (s *AgentServer) StartSession(ctx context.Context, req *connect.Request[age
 		workingDir = msg.Settings.WorkingDir
 	}
 
-	// In Connect/gRPC mode: auto-approve all tool calls, each session gets isolated in-memory state.
+	// In Connect/gRPC mode each session gets isolated in-memory state. Tool
+	// calls are auto-approved unless the client asks to approve them itself
+	// (approval_timeout_seconds > 0); persistent permission rules apply either way.
+	// An editor client may also serve file reads from its buffers and run
+	// commands in its terminal (msg.editor).
 	// Persistence is enabled per-session via X-Persistence-Key header. The factory
 	// builds the LLM client from settings and attaches the shared agent backend
 	// (e.g. codex app-server) when configured; sessions share one backend process,
 	// so no per-session cleanup is needed here.
+	client := newClientChannel()
 	fsRepo := infra.NewOSFilesystemRepository()
+	if msg.GetEditor().GetBufferReads() {
+		fsRepo = &editorFilesystem{FilesystemRepository: fsRepo, client: client}
+	}
+	var terminal tool.TerminalDispatcher
+	if msg.GetEditor().GetTerminal() {
+		terminal = editorTerminal(client, workingDir)
+	}
 	out := io.Discard
 	agent, _, err := app.NewAgentWithOptions(ctx, app.AgentOptions{
 		Settings:           settings,
@@ -107,11 +135,18 @@ func (s *AgentServer) StartSession(ctx context.Context, req *connect.Request[age
 		SkipSessionRestore: true,
 		IsInteractiveMode:  false,
 		AgentBackend:       s.agentBackend,
+		TerminalDispatcher: terminal,
 	})
 	if err != nil {
 		return nil, connect.NewError(connect.CodeInternal, err)
 	}
 
+	remoteApproval := msg.ApprovalTimeoutSeconds > 0
+	if remoteApproval {
+		approver := &remoteApprover{client: client, timeout: time.Duration(msg.ApprovalTimeoutSeconds) * time.Second}
+		agent.SetToolApprover(approver.approve)
+	}
+
 	// Enable file-backed persistence if a persistence key is provided
 	persistenceKey := req.Header().Get("X-Persistence-Key")
 	if persistenceKey != "" && s.sessionsDir != "" {
@@ -133,13 +168,14 @@ func (s *AgentServer) StartSession(ctx context.Context, req *connect.Request[age
 	}
 	s.nextID++
 	sessionID := fmt.Sprintf("session-%d", s.nextID)
-	s.sessions[sessionID] = &sessionState{agent: agent, persistenceKey: persistenceKey}
+	s.sessions[sessionID] = &sessionState{agent: agent, persistenceKey: persistenceKey, client: client, remoteApproval: remoteApproval}
 	if persistenceKey != "" {
 		s.keyToSession[persistenceKey] = sessionID
 	}
 	s.mu.Unlock()
 
-	s.logger.Info("Session started", "session_id", sessionID, "working_dir", workingDir, "persistence_key", persistenceKey)
+	s.logger.Info("Session started", "session_id", sessionID, "working_dir", workingDir, "persistence_key", persistenceKey,
+		"remote_approval", remoteApproval, "editor", msg.GetEditor() != nil)
 
 	return connect.NewResponse(&agentv1.StartSessionResponse{
 		SessionId: sessionID,
@@ -179,6 +215,9 @@ func (s *AgentServer) Invoke(ctx context.Context, req *connect.Request[agentv1.I
 		skillName = "code"
 	}
 
+	session.turnMu.Lock()
+	defer session.turnMu.Unlock()
+
 	// The agent emits events from its own goroutine (thinking drainer) as well as
 	// the main Invoke goroutine; connect.ServerStream.Send is not safe for
 	// concurrent use, so serialize all sends behind this mutex.
@@ -202,8 +241,12 @@ func (s *AgentServer) Invoke(ctx context.Context, req *connect.Request[agentv1.I
 		if protoEvent != nil {
 			send(protoEvent)
 		}
+		if todos := todosChangedEvent(session.agent, event); todos != nil {
+			send(todos)
+		}
 	})
 	defer session.agent.SetEventHandler(nil)
+	defer session.client.attach(send)()
 
 	// Convert raw image bytes to base64 strings for the agent
 	var images []string
@@ -211,7 +254,8 @@ func (s *AgentServer) Invoke(ctx context.Context, req *connect.Request[agentv1.I
 		images = append(images, base64.StdEncoding.EncodeToString(imgBytes))
 	}
 
-	// Invoke the agent
+	// Invoke the agent, collecting the report files it writes
+	ctx, outputs := report.WithOutputs(ctx)
 	result, invokeErr := session.agent.Invoke(ctx, req.Msg.UserInput, skillName, images...)
 
 	// Send final message or error
@@ -223,9 +267,15 @@ func (s *AgentServer) Invoke(ctx context.Context, req *connect.Request[agentv1.I
 	}
 
 	if result != nil {
+		files, skipped := s.attachments(outputs.Paths())
+		text := result.Content()
+		if len(skipped) > 0 {
+			text += "\n\nNot attached: " + strings.Join(skipped, ", ") + "."
+		}
 		final := &agentv1.FinalMessage{
-			Text:     result.Content(),
-			Thinking: result.Thinking(),
+			Text:        text,
+			Thinking:    result.Thinking(),
+			Attachments: files,
 		}
 		if usage := result.TotalTokens(); usage > 0 {
 			final.Usage = &agentv1.TokenUsage{
@@ -248,6 +298,46 @@ func (s *AgentServer) Invoke(ctx context.Context, req *connect.Request[agentv1.I
 	return nil
 }
 
+// The files of a final message are capped at what one Discord message
+// accepts, the tightest of the gateway's channels: 10 files, 10 MiB in all.
+const (
+	maxAttachments     = 10
+	maxAttachmentBytes = 10 << 20
+)
+
+// attachments reads the files a turn produced, up to maxAttachments of them
+// and maxAttachmentBytes in all. skipped names each file left out, and why,
+// for the reply to mention.
+func (s *AgentServer) attachments(paths []string) (out []*agentv1.Attachment, skipped []string) {
+	total := 0
+	for _, path := range paths {
+		name := filepath.Base(path)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			s.logger.Warn("Failed to read attachment", "path", path, "error", err)
+			skipped = append(skipped, name+" (unreadable)")
+			continue
+		}
+		if len(out) == maxAttachments {
+			s.logger.Warn("Attachment skipped: over the file count cap", "path", path)
+			skipped = append(skipped, fmt.Sprintf("%s (more than %d files)", name, maxAttachments))
+			continue
+		}
+		if total+len(data) > maxAttachmentBytes {
+			s.logger.Warn("Attachment skipped: over the size cap", "path", path, "bytes", len(data))
+			skipped = append(skipped, fmt.Sprintf("%s (over %d MiB in all)", name, maxAttachmentBytes>>20))
+			continue
+		}
+		total += len(data)
+		out = append(out, &agentv1.Attachment{
+			Name:        name,
+			ContentType: report.ContentType(path),
+			Data:        data,
+		})
+	}
+	return out, skipped
+}
+
 func (s *AgentServer) GetConversationPreview(ctx context.Context, req *connect.Request[agentv1.GetConversationPreviewRequest]) (*connect.Response[agentv1.GetConversationPreviewResponse], error) {
 	session, err := s.getSession(req.Msg.SessionId)
 	if err != nil {
@@ -261,6 +351,39 @@ func (s *AgentServer) GetConversationPreview(ctx context.Context, req *connect.R
 	return connect.NewResponse(&agentv1.GetConversationPreviewResponse{Preview: preview}), nil
 }
 
+// Compact compacts the session's conversation now. It waits for a running
+// turn to finish, since compacting inside one would summarise what the turn
+// is still working on.
+func (s *AgentServer) Compact(ctx context.Context, req *connect.Request[agentv1.CompactRequest]) (*connect.Response[agentv1.CompactResponse], error) {
+	session, err := s.getSession(req.Msg.SessionId)
+	if err != nil {
+		return nil, err
+	}
+	role := req.Msg.Scenario
+	if role == "" {
+		role = "code"
+	}
+	strategy := domain.CompactionStrategy(req.Msg.Strategy)
+	if strategy != "" && !slices.Contains(domain.CompactionStrategies, strategy) {
+		return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("unknown compaction strategy %q", req.Msg.Strategy))
+	}
+
+	session.turnMu.Lock()
+	defer session.turnMu.Unlock()
+	res, err := session.agent.Compact(ctx, role, domain.CompactionOptions{Strategy: strategy, Focus: req.Msg.Focus})
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	s.logger.Info("Session compacted", "session_id", req.Msg.SessionId, "compacted", res.Compacted,
+		"strategy", res.Strategy, "before_tokens", res.BeforeTokens, "after_tokens", res.AfterTokens)
+	return connect.NewResponse(&agentv1.CompactResponse{
+		Compacted:    res.Compacted,
+		Strategy:     string(res.Strategy),
+		BeforeTokens: int32(res.BeforeTokens),
+		AfterTokens:  int32(res.AfterTokens),
+	}), nil
+}
+
 func (s *AgentServer) ListScenarios(ctx context.Context, req *connect.Request[agentv1.ListScenariosRequest]) (*connect.Response[agentv1.ListScenariosResponse], error) {
 	// Enumerate the actually-loaded skills rather than a hardcoded list, so
 	// renamed/removed skills (e.g. the deleted "respond") never appear.
@@ -291,7 +414,114 @@ func (s *AgentServer) ListScenarios(ctx context.Context, req *connect.Request[ag
 	return connect.NewResponse(&agentv1.ListScenariosResponse{Scenarios: scenarios}), nil
 }
 
-// SubmitClientEvent, GetTodos, WriteTodos, SetSettings use the unimplemented defaults for now.
+// SubmitClientEvent delivers a client's answer to a request the server made on
+// the Invoke stream: a tool approval, a buffer read, or a terminal dispatch.
+func (s *AgentServer) SubmitClientEvent(ctx context.Context, req *connect.Request[agentv1.ClientEvent]) (*connect.Response[agentv1.SubmitClientEventResponse], error) {
+	session, err := s.getSession(req.Msg.SessionId)
+	if err != nil {
+		return nil, err
+	}
+	id, ok := clientEventRequestID(req.Msg)
+	if !ok {
+		return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("client event %T is not supported", req.Msg.Event))
+	}
+	resp := &agentv1.SubmitClientEventResponse{RequestId: id, Status: "OK"}
+	if !session.client.resolve(id, req.Msg) {
+		resp.Status = "ERROR"
+		resp.Error = "no pending request with that id (already answered or timed out)"
+		return connect.NewResponse(resp), nil
+	}
+	if ar := req.Msg.GetApprovalResponse(); ar != nil {
+		s.logger.Info("Tool approval answered", "session_id", req.Msg.SessionId,
+			"request_id", id, "decision", ar.Decision.String(), "responder", ar.Responder)
+	}
+	return connect.NewResponse(resp), nil
+}
+
+// GetTodos returns the session's todo list, the one TodoWrite maintains.
+func (s *AgentServer) GetTodos(ctx context.Context, req *connect.Request[agentv1.GetTodosRequest]) (*connect.Response[agentv1.GetTodosResponse], error) {
+	session, err := s.getSession(req.Msg.SessionId)
+	if err != nil {
+		return nil, err
+	}
+	todos := session.agent.TodoManager()
+	if todos == nil {
+		return connect.NewResponse(&agentv1.GetTodosResponse{}), nil
+	}
+	return connect.NewResponse(&agentv1.GetTodosResponse{Items: todosToProto(todos.Items())}), nil
+}
+
+// WriteTodos replaces the session's todo list (an edit in the editor's todo
+// panel). The agent sees the new list in its next prompt.
+func (s *AgentServer) WriteTodos(ctx context.Context, req *connect.Request[agentv1.WriteTodosRequest]) (*connect.Response[agentv1.WriteTodosResponse], error) {
+	session, err := s.getSession(req.Msg.SessionId)
+	if err != nil {
+		return nil, err
+	}
+	todos := session.agent.TodoManager()
+	if todos == nil {
+		return nil, connect.NewError(connect.CodeFailedPrecondition, errors.New("this session has no todo list"))
+	}
+	items, err := todos.ReplaceItems(todosFromProto(req.Msg.Items))
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+	return connect.NewResponse(&agentv1.WriteTodosResponse{Items: todosToProto(items)}), nil
+}
+
+// SetSettings changes the model, iteration cap or thinking of a session
+// between turns; the next Invoke uses them.
+func (s *AgentServer) SetSettings(ctx context.Context, req *connect.Request[agentv1.SetSettingsRequest]) (*connect.Response[agentv1.SetSettingsResponse], error) {
+	session, err := s.getSession(req.Msg.SessionId)
+	if err != nil {
+		return nil, err
+	}
+	st := req.Msg.Settings
+	if st == nil {
+		return connect.NewResponse(&agentv1.SetSettingsResponse{}), nil
+	}
+	if !session.turnMu.TryLock() {
+		return nil, connect.NewError(connect.CodeFailedPrecondition, errors.New("a turn is running; change settings after it completes"))
+	}
+	defer session.turnMu.Unlock()
+	if err := session.agent.UpdateSettings(app.SettingsUpdate{
+		Model:         st.Model,
+		MaxIterations: int(st.MaxIterations),
+		Thinking:      st.Thinking,
+	}); err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+	s.logger.Info("Session settings updated", "session_id", req.Msg.SessionId,
+		"model", st.Model, "max_iterations", st.MaxIterations, "thinking", st.Thinking)
+	return connect.NewResponse(&agentv1.SetSettingsResponse{}), nil
+}
+
+// FlushMemories distils each live session's not-yet-extracted turns into
+// long-term memory. It is called once serving has stopped; sessions are
+// flushed concurrently and the call returns when they are done or ctx ends.
+func (s *AgentServer) FlushMemories(ctx context.Context) {
+	s.mu.RLock()
+	agents := make([]*app.Agent, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		agents = append(agents, session.agent)
+	}
+	s.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, agent := range agents {
+		wg.Go(func() { agent.FlushMemory(ctx) })
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.logger.Warn("Gave up flushing session memories", "error", ctx.Err())
+	}
+}
 
 func (s *AgentServer) getSession(sessionID string) (*sessionState, error) {
 	s.mu.RLock()
@@ -314,6 +544,22 @@ func sanitizeFilename(key string) string {
 	return safe
 }
 
+// todosChangedEvent reports the todo list after a successful TodoWrite, so an
+// editor's todo panel follows the agent without polling GetTodos.
+func todosChangedEvent(agent *app.Agent, event events.AgentEvent) *agentv1.InvokeEvent {
+	data, ok := event.Data.(events.ToolResultData)
+	if event.Type != events.EventTypeToolResult || !ok || data.IsError || data.ToolName != "TodoWrite" {
+		return nil
+	}
+	todos := agent.TodoManager()
+	if todos == nil {
+		return nil
+	}
+	return &agentv1.InvokeEvent{
+		Event: &agentv1.InvokeEvent_Todos{Todos: &agentv1.TodoList{Items: todosToProto(todos.Items())}},
+	}
+}
+
 // translateEvent converts an events.AgentEvent to a proto InvokeEvent.
 func translateEvent(event events.AgentEvent) *agentv1.InvokeEvent {
 	switch event.Type {
@@ -358,6 +604,33 @@ func translateEvent(event events.AgentEvent) *agentv1.InvokeEvent {
 			}
 		}
 
+	case events.EventTypeToolOutputDelta:
+		if data, ok := event.Data.(events.ToolOutputDeltaData); ok && data.Text != "" {
+			return &agentv1.InvokeEvent{
+				Event: &agentv1.InvokeEvent_ToolOutput{
+					ToolOutput: &agentv1.ToolOutputDelta{
+						Id:     data.CallID,
+						Name:   data.ToolName,
+						Stream: data.Stream,
+						Text:   data.Text,
+					},
+				},
+			}
+		}
+
+	case events.EventTypeCompaction:
+		if data, ok := event.Data.(events.CompactionData); ok {
+			return &agentv1.InvokeEvent{
+				Event: &agentv1.InvokeEvent_Compaction{
+					Compaction: &agentv1.CompactionEvent{
+						Strategy:     data.Strategy,
+						BeforeTokens: int32(data.BeforeTokens),
+						AfterTokens:  int32(data.AfterTokens),
+					},
+				},
+			}
+		}
+
 	case events.EventTypeError:
 		if data, ok := event.Data.(events.ErrorData); ok {
 			return &agentv1.InvokeEvent{
@@ -0,0 +1,71 @@
+package connectrpc
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fpt/klein-cli/pkg/agent/events"
+	pkgLogger "github.com/fpt/klein-cli/pkg/logger"
+)
+
+func TestTranslateToolOutputDelta(t *testing.T) {
+	ev := translateEvent(events.AgentEvent{
+		Type: events.EventTypeToolOutputDelta,
+		Data: events.ToolOutputDeltaData{ToolName: "Bash", CallID: "call_1", Stream: "stderr", Text: "warning: x\n"},
+	})
+	out := ev.GetToolOutput()
+	if out == nil {
+		t.Fatalf("event = %v, want a tool_output variant", ev)
+	}
+	if out.GetId() != "call_1" || out.GetName() != "Bash" || out.GetStream() != "stderr" || out.GetText() != "warning: x\n" {
+		t.Fatalf("tool output = %v", out)
+	}
+
+	if ev := translateEvent(events.AgentEvent{Type: events.EventTypeToolOutputDelta, Data: events.ToolOutputDeltaData{}}); ev != nil {
+		t.Fatalf("an empty delta should be dropped, got %v", ev)
+	}
+}
+
+func TestTranslateCompaction(t *testing.T) {
+	ev := translateEvent(events.AgentEvent{
+		Type: events.EventTypeCompaction,
+		Data: events.CompactionData{Strategy: "structured", BeforeTokens: 150000, AfterTokens: 42000},
+	})
+	c := ev.GetCompaction()
+	if c == nil || c.GetStrategy() != "structured" || c.GetBeforeTokens() != 150000 || c.GetAfterTokens() != 42000 {
+		t.Fatalf("event = %v, want the compaction variant", ev)
+	}
+}
+
+func TestAttachmentsCapToOneDiscordMessage(t *testing.T) {
+	s := &AgentServer{logger: pkgLogger.NewLogger(pkgLogger.LogLevelError)}
+	dir := t.TempDir()
+	write := func(name string, size int) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, make([]byte, size), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	var paths []string
+	for i := range 11 {
+		paths = append(paths, write(fmt.Sprintf("chart%02d.png", i), 1024))
+	}
+	files, skipped := s.attachments(paths)
+	if len(files) != maxAttachments || len(skipped) != 1 || !strings.HasPrefix(skipped[0], "chart10.png (more than") {
+		t.Errorf("11 files: attached %d, skipped %q", len(files), skipped)
+	}
+
+	big := write("report.pdf", maxAttachmentBytes-1024)
+	files, skipped = s.attachments([]string{paths[0], big, paths[1], filepath.Join(dir, "missing.png")})
+	if len(files) != 2 || files[1].Name != "report.pdf" {
+		t.Errorf("attached %d files", len(files))
+	}
+	if want := []string{"chart01.png (over 10 MiB in all)", "missing.png (unreadable)"}; strings.Join(skipped, "|") != strings.Join(want, "|") {
+		t.Errorf("skipped = %q, want %q", skipped, want)
+	}
+}
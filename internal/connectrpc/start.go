@@ -16,13 +16,17 @@ import (
 	pkgLogger "github.com/fpt/klein-cli/pkg/logger"
 )
 
+// memoryFlushTimeout bounds the memory extraction run for live sessions once
+// the server has stopped, so a slow model cannot hold up process exit.
+const memoryFlushTimeout = 90 * time.Second
+
 // buildServer constructs the h2c HTTP server for the agent service. Addr is left
 // unset so callers can either ListenAndServe (StartServer) or bind a listener
 // themselves (StartServerListener).
 func buildServer(
 	settings *config.Settings, mcpToolManagers map[string]domain.ToolManager, logger *pkgLogger.Logger,
 	sessionsDir string, agentBackend domain.AgentBackend,
-) *http.Server {
+) (*http.Server, *AgentServer) {
 	server := NewAgentServer(settings, mcpToolManagers, logger, sessionsDir, agentBackend)
 
 	path, handler := agentv1connect.NewAgentServiceHandler(server)
@@ -31,26 +35,34 @@ func buildServer(
 
 	return &http.Server{
 		Handler: h2c.NewHandler(mux, &http2.Server{}),
-	}
+	}, server
 }
 
-// shutdownOnCancel gracefully shuts srv down when ctx is cancelled.
-func shutdownOnCancel(ctx context.Context, srv *http.Server) {
+// shutdownOnCancel gracefully shuts srv down when ctx is cancelled, then
+// flushes the live sessions' memories and closes stopped.
+func shutdownOnCancel(ctx context.Context, srv *http.Server, server *AgentServer, stopped chan<- struct{}) {
+	defer close(stopped)
 	<-ctx.Done()
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	_ = srv.Shutdown(shutdownCtx)
+
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), memoryFlushTimeout)
+	defer cancelFlush()
+	server.FlushMemories(flushCtx)
 }
 
-// StartServer starts the Connect-gRPC HTTP/2 server and blocks until ctx is cancelled.
+// StartServer starts the Connect-gRPC HTTP/2 server and blocks until ctx is
+// cancelled and the shutdown, including the session memory flush, is done.
 func StartServer(
 	ctx context.Context, addr string, settings *config.Settings, mcpToolManagers map[string]domain.ToolManager,
 	logger *pkgLogger.Logger, sessionsDir string, agentBackend domain.AgentBackend,
 ) error {
-	srv := buildServer(settings, mcpToolManagers, logger, sessionsDir, agentBackend)
+	srv, server := buildServer(settings, mcpToolManagers, logger, sessionsDir, agentBackend)
 	srv.Addr = addr
 
-	go shutdownOnCancel(ctx, srv)
+	stopped := make(chan struct{})
+	go shutdownOnCancel(ctx, srv, server, stopped)
 
 	logger.Info("Connect-gRPC server listening", "addr", addr)
 	fmt.Printf("klein agent server listening on %s\n", addr)
@@ -58,25 +70,29 @@ func StartServer(
 	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		return fmt.Errorf("server error: %w", err)
 	}
+	<-stopped
 	return nil
 }
 
 // StartServerListener binds addr, serves the agent in a background goroutine, and
 // returns the actual listen address. Pass "127.0.0.1:0" for an ephemeral local
 // port — right for an in-process embedded server (e.g. `klein claw`) where the
-// caller dials the returned address. Serving stops when ctx is cancelled.
+// caller dials the returned address. Serving stops when ctx is cancelled; the
+// returned channel is closed once the shutdown, including the session memory
+// flush, is done, so the caller can wait before closing the tools it passed in.
 func StartServerListener(
 	ctx context.Context, addr string, settings *config.Settings, mcpToolManagers map[string]domain.ToolManager,
 	logger *pkgLogger.Logger, sessionsDir string, agentBackend domain.AgentBackend,
-) (string, error) {
-	srv := buildServer(settings, mcpToolManagers, logger, sessionsDir, agentBackend)
+) (string, <-chan struct{}, error) {
+	srv, server := buildServer(settings, mcpToolManagers, logger, sessionsDir, agentBackend)
 
 	ln, err := net.Listen("tcp", addr)
 	if err != nil {
-		return "", fmt.Errorf("failed to listen on %s: %w", addr, err)
+		return "", nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
 	}
 
-	go shutdownOnCancel(ctx, srv)
+	stopped := make(chan struct{})
+	go shutdownOnCancel(ctx, srv, server, stopped)
 	go func() {
 		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
 			logger.Error("Embedded agent server error", "error", err)
@@ -85,5 +101,5 @@ func StartServerListener(
 
 	bound := ln.Addr().String()
 	logger.Info("Connect-gRPC server listening (embedded)", "addr", bound)
-	return bound, nil
+	return bound, stopped, nil
 }
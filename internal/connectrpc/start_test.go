@@ -18,7 +18,7 @@ func TestStartServerListener(t *testing.T) {
 	logger := pkgLogger.NewLogger(pkgLogger.LogLevelError)
 	settings := config.GetDefaultSettings()
 
-	addr, err := StartServerListener(ctx, "127.0.0.1:0", settings, nil, logger, t.TempDir(), nil)
+	addr, stopped, err := StartServerListener(ctx, "127.0.0.1:0", settings, nil, logger, t.TempDir(), nil)
 	if err != nil {
 		t.Fatalf("StartServerListener: %v", err)
 	}
@@ -38,6 +38,11 @@ func TestStartServerListener(t *testing.T) {
 
 	// Cancelling stops the server; the port should free up shortly after.
 	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("stopped channel not closed after cancel")
+	}
 	deadline := time.Now().Add(2 * time.Second)
 	for time.Now().Before(deadline) {
 		c, err := net.DialTimeout("tcp", addr, 200*time.Millisecond)
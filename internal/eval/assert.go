@@ -0,0 +1,189 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Assertions is a testcase's assert.json: checks on the workspace, on what
+// klein printed and on how the run went, evaluated in Go so a case needs no
+// shell script.
+//
+//	{
+//	  "files": [{"path": "add.go", "contains": ["package main"], "matches": ["func add\\("]}],
+//	  "absent": ["go.mod"],
+//	  "output": {"contains": ["add"]},
+//	  "commands": [{"run": "go build add.go"}, {"run": "go run add.go", "contains": ["5"]}],
+//	  "tools_used": ["Write"],
+//	  "max_iterations": 6
+//	}
+type Assertions struct {
+	Files    []FileAssertion    `json:"files,omitempty"`
+	Absent   []string           `json:"absent,omitempty"`
+	Output   *TextAssertion     `json:"output,omitempty"`
+	Commands []CommandAssertion `json:"commands,omitempty"`
+	// ToolsUsed must each be called at least once; ToolsNotUsed never.
+	ToolsUsed    []string `json:"tools_used,omitempty"`
+	ToolsNotUsed []string `json:"tools_not_used,omitempty"`
+	// MaxIterations and MaxToolCalls bound the run; zero is no bound. These
+	// and the tool checks need the run's trace, so a whole-agent backend,
+	// which records none of its own loop, is not held to them.
+	MaxIterations int `json:"max_iterations,omitempty"`
+	MaxToolCalls  int `json:"max_tool_calls,omitempty"`
+}
+
+// TextAssertion checks a text: every Contains substring and Matches regexp
+// must occur in it, and no NotContains substring may.
+type TextAssertion struct {
+	Contains    []string `json:"contains,omitempty"`
+	NotContains []string `json:"not_contains,omitempty"`
+	Matches     []string `json:"matches,omitempty"`
+}
+
+// FileAssertion requires a workspace file to exist and, optionally, checks
+// its content.
+type FileAssertion struct {
+	Path string `json:"path"`
+	TextAssertion
+}
+
+// CommandAssertion runs a shell command in the workspace after klein is done.
+// It must exit zero and its combined output must pass the text checks.
+type CommandAssertion struct {
+	Run string `json:"run"`
+	TextAssertion
+}
+
+// Check evaluates the assertions against a finished run and returns one line
+// per failed check; none means the run passed. wholeAgent skips the
+// trace-based checks; any other run without a trace fails them.
+func (a *Assertions) Check(ctx context.Context, workDir, output string, m Metrics, wholeAgent bool) []string {
+	var failures []string
+	fail := func(format string, args ...any) { failures = append(failures, fmt.Sprintf(format, args...)) }
+
+	for _, f := range a.Files {
+		raw, err := os.ReadFile(filepath.Join(workDir, filepath.FromSlash(f.Path)))
+		if err != nil {
+			fail("file %s: %v", f.Path, err)
+			continue
+		}
+		for _, msg := range f.check(string(raw)) {
+			fail("file %s: %s", f.Path, msg)
+		}
+	}
+	for _, p := range a.Absent {
+		if _, err := os.Stat(filepath.Join(workDir, filepath.FromSlash(p))); err == nil {
+			fail("%s exists but should not", p)
+		}
+	}
+	if a.Output != nil {
+		for _, msg := range a.Output.check(output) {
+			fail("output: %s", msg)
+		}
+	}
+	for _, c := range a.Commands {
+		cmd := exec.CommandContext(ctx, "sh", "-c", c.Run) //nolint:gosec // the testcase's own command
+		cmd.Dir = workDir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			fail("`%s`: %v\n%s", c.Run, err, tail(string(out), 20))
+			continue
+		}
+		for _, msg := range c.check(string(out)) {
+			fail("`%s`: %s", c.Run, msg)
+		}
+	}
+
+	if wholeAgent || !a.needsTrace() {
+		return failures
+	}
+	if !m.Traced {
+		fail("no trace recorded")
+		return failures
+	}
+	for _, t := range a.ToolsUsed {
+		if m.ToolCalls[t] == 0 {
+			fail("tool %s was never called", t)
+		}
+	}
+	for _, t := range a.ToolsNotUsed {
+		if n := m.ToolCalls[t]; n > 0 {
+			fail("tool %s was called %d time(s)", t, n)
+		}
+	}
+	if a.MaxIterations > 0 && m.Iterations > a.MaxIterations {
+		fail("%d iterations, at most %d allowed", m.Iterations, a.MaxIterations)
+	}
+	if a.MaxToolCalls > 0 && m.TotalToolCalls() > a.MaxToolCalls {
+		fail("%d tool calls, at most %d allowed", m.TotalToolCalls(), a.MaxToolCalls)
+	}
+	return failures
+}
+
+// needsTrace reports whether any check reads the run's trace.
+func (a *Assertions) needsTrace() bool {
+	return len(a.ToolsUsed) > 0 || len(a.ToolsNotUsed) > 0 || a.MaxIterations > 0 || a.MaxToolCalls > 0
+}
+
+// validate compiles every pattern up front, so a bad one fails the suite at
+// load time rather than every run that reaches it.
+func (a *Assertions) validate() error {
+	texts := make([]TextAssertion, 0, len(a.Files)+len(a.Commands)+1)
+	for _, f := range a.Files {
+		if f.Path == "" {
+			return fmt.Errorf("a files entry has no path")
+		}
+		texts = append(texts, f.TextAssertion)
+	}
+	for _, c := range a.Commands {
+		if strings.TrimSpace(c.Run) == "" {
+			return fmt.Errorf("a commands entry has no run")
+		}
+		texts = append(texts, c.TextAssertion)
+	}
+	if a.Output != nil {
+		texts = append(texts, *a.Output)
+	}
+	for _, t := range texts {
+		for _, expr := range t.Matches {
+			if _, err := regexp.Compile(expr); err != nil {
+				return fmt.Errorf("pattern %q: %w", expr, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (t TextAssertion) check(text string) []string {
+	var failures []string
+	for _, s := range t.Contains {
+		if !strings.Contains(text, s) {
+			failures = append(failures, fmt.Sprintf("does not contain %q", s))
+		}
+	}
+	for _, s := range t.NotContains {
+		if strings.Contains(text, s) {
+			failures = append(failures, fmt.Sprintf("contains %q", s))
+		}
+	}
+	for _, expr := range t.Matches {
+		if !regexp.MustCompile(expr).MatchString(text) { // compiled by validate
+			failures = append(failures, fmt.Sprintf("does not match %q", expr))
+		}
+	}
+	return failures
+}
+
+// tail is the last n lines of s, for failure messages that quote output.
+func tail(s string, n int) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
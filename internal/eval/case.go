@@ -0,0 +1,138 @@
+// Package eval runs klein against a suite of testcases across a matrix of
+// backends and models, measures each run from its trace, and reports the
+// results as JSON, JUnit XML and Markdown, optionally diffed against a
+// baseline. `klein eval` is its command line.
+package eval
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Files a testcase directory may hold. prompt.txt is required, and at least
+// one of check.sh and assert.json says what passing means.
+const (
+	PromptFile = "prompt.txt"
+	CheckFile  = "check.sh"
+	AssertFile = "assert.json"
+	ConfigFile = "config.json"
+)
+
+// Case is one testcase: a directory copied into a fresh workspace per run.
+type Case struct {
+	Name string
+	Dir  string
+	// Check is the path of check.sh, "" when the case has none.
+	Check string
+	// Assert is the parsed assert.json, nil when the case has none.
+	Assert *Assertions
+	Config CaseConfig
+}
+
+// CaseConfig is a testcase's config.json.
+type CaseConfig struct {
+	// Role is the role the run opens with (--role); empty is klein's default.
+	Role string `json:"role,omitempty"`
+	// AllowedTools restricts the run to these tools (--allowed-tools).
+	AllowedTools []string `json:"allowed_tools,omitempty"`
+	// Timeout overrides the runner's per-run timeout, e.g. "15m".
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// LoadCases reads every testcase under dir, sorted by name. Names, when given,
+// select cases by exact name; an unknown name is an error rather than an
+// empty run.
+func LoadCases(dir string, names []string) ([]Case, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var cases []Case
+	for _, e := range entries {
+		if !e.IsDir() || (len(names) > 0 && !slices.Contains(names, e.Name())) {
+			continue
+		}
+		c, err := LoadCase(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		cases = append(cases, c)
+	}
+	for _, n := range names {
+		if !slices.ContainsFunc(cases, func(c Case) bool { return c.Name == n }) {
+			return nil, fmt.Errorf("no testcase %q in %s", n, dir)
+		}
+	}
+	sort.Slice(cases, func(i, j int) bool { return cases[i].Name < cases[j].Name })
+	return cases, nil
+}
+
+// LoadCase reads the testcase in dir.
+func LoadCase(dir string) (Case, error) {
+	c := Case{Name: filepath.Base(dir), Dir: dir}
+	if _, err := os.Stat(filepath.Join(dir, PromptFile)); err != nil {
+		return c, fmt.Errorf("testcase %s: %w", c.Name, err)
+	}
+	if fi, err := os.Stat(filepath.Join(dir, CheckFile)); err == nil {
+		if fi.Mode()&0o111 == 0 {
+			return c, fmt.Errorf("testcase %s: %s is not executable", c.Name, CheckFile)
+		}
+		c.Check = filepath.Join(dir, CheckFile)
+	}
+	if raw, err := os.ReadFile(filepath.Join(dir, AssertFile)); err == nil {
+		var a Assertions
+		err := decodeStrict(raw, &a)
+		if err == nil {
+			err = a.validate()
+		}
+		if err != nil {
+			return c, fmt.Errorf("testcase %s: %s: %w", c.Name, AssertFile, err)
+		}
+		c.Assert = &a
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return c, fmt.Errorf("testcase %s: %w", c.Name, err)
+	}
+	if c.Check == "" && c.Assert == nil {
+		return c, fmt.Errorf("testcase %s has neither %s nor %s", c.Name, CheckFile, AssertFile)
+	}
+	if raw, err := os.ReadFile(filepath.Join(dir, ConfigFile)); err == nil {
+		if err := decodeStrict(raw, &c.Config); err != nil {
+			return c, fmt.Errorf("testcase %s: %s: %w", c.Name, ConfigFile, err)
+		}
+		if c.Config.Timeout != "" {
+			if _, err := time.ParseDuration(c.Config.Timeout); err != nil {
+				return c, fmt.Errorf("testcase %s: %s: timeout: %w", c.Name, ConfigFile, err)
+			}
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return c, fmt.Errorf("testcase %s: %w", c.Name, err)
+	}
+	return c, nil
+}
+
+// requiresKleinLoop reports whether the case needs a tool that exists only in
+// klein's own ReAct loop, which a whole-agent backend never hands over.
+func (c Case) requiresKleinLoop() bool {
+	return slices.ContainsFunc(c.Config.AllowedTools, func(t string) bool {
+		return slices.Contains(kleinLoopOnlyTools, t)
+	})
+}
+
+// kleinLoopOnlyTools are plan mode and subagent spawning. Web and PDF tools are
+// deliberately absent: a whole-agent backend can pass those cases with its own.
+var kleinLoopOnlyTools = []string{"EnterPlanMode", "ExitPlanMode", "Task"}
+
+// decodeStrict decodes JSON, rejecting unknown keys so a misspelled
+// assertion fails loudly instead of never being checked.
+func decodeStrict(raw []byte, v any) error {
+	dec := json.NewDecoder(strings.NewReader(string(raw)))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
@@ -0,0 +1,158 @@
+package eval
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// DefaultTolerance is how far a metric may grow over its baseline before the
+// growth counts as a regression.
+const DefaultTolerance = 0.2
+
+// Change is one difference between a run and its baseline.
+type Change struct {
+	Case   string `json:"case"`
+	Target string `json:"target"`
+	// What changed: "status", or the metric's name.
+	What       string `json:"what"`
+	Was        string `json:"was"`
+	Now        string `json:"now"`
+	Regression bool   `json:"regression"`
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s × %s: %s %s → %s", c.Case, c.Target, c.What, c.Was, c.Now)
+}
+
+// Diff is a report compared with a baseline report.
+type Diff struct {
+	Tolerance float64
+	// Changes are the runs whose status flipped or whose metrics moved past
+	// the tolerance, in either direction.
+	Changes []Change
+	// New and Missing are runs in only one of the two reports.
+	New, Missing []string
+}
+
+// Regressions are the changes for the worse.
+func (d *Diff) Regressions() []Change {
+	var out []Change
+	for _, c := range d.Changes {
+		if c.Regression {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// Compare diffs current against baseline, pairing runs by case and target.
+// A pass turning into a fail is a regression; so is tokens, cost, iterations
+// or tool calls growing by more than tolerance (a fraction: 0.2 is 20%).
+// Metrics are compared only between passing traced runs, since a failed run
+// stops at an arbitrary point; wall time is never compared, since it follows
+// the provider's load more than klein's behaviour. Skipped runs are ignored.
+func Compare(baseline, current *Report, tolerance float64) *Diff {
+	d := &Diff{Tolerance: tolerance}
+	key := func(r Result) string { return r.Case + " × " + r.Target.Label() }
+	base := map[string]Result{}
+	for _, r := range baseline.Results {
+		if r.Status != StatusSkip {
+			base[key(r)] = r
+		}
+	}
+	seen := map[string]bool{}
+	for _, now := range current.Results {
+		if now.Status == StatusSkip {
+			continue
+		}
+		k := key(now)
+		seen[k] = true
+		was, ok := base[k]
+		if !ok {
+			d.New = append(d.New, k)
+			continue
+		}
+		change := func(what, w, n string, regression bool) {
+			d.Changes = append(d.Changes, Change{
+				Case: now.Case, Target: now.Target.Label(), What: what, Was: w, Now: n, Regression: regression,
+			})
+		}
+		if was.Status != now.Status {
+			change("status", string(was.Status), string(now.Status), now.Status == StatusFail)
+			continue
+		}
+		if now.Status != StatusPass || !was.Metrics.Traced || !now.Metrics.Traced {
+			continue
+		}
+		wm, nm := was.Metrics, now.Metrics
+		for _, m := range []struct {
+			name     string
+			was, now float64
+			format   string
+		}{
+			{"tokens", float64(wm.TotalTokens()), float64(nm.TotalTokens()), "%.0f"},
+			{"iterations", float64(wm.Iterations), float64(nm.Iterations), "%.0f"},
+			{"tool calls", float64(wm.TotalToolCalls()), float64(nm.TotalToolCalls()), "%.0f"},
+			{"cost", wm.CostUSD, nm.CostUSD, "$%.4f"},
+		} {
+			if grew, shrank := moved(m.was, m.now, tolerance); grew || shrank {
+				change(m.name, fmt.Sprintf(m.format, m.was), fmt.Sprintf(m.format, m.now), grew)
+			}
+		}
+	}
+	for _, r := range baseline.Results {
+		if k := key(r); r.Status != StatusSkip && !seen[k] {
+			d.Missing = append(d.Missing, k)
+		}
+	}
+	return d
+}
+
+// moved reports whether now is beyond was by more than the tolerance, up or
+// down. Growth from zero always counts.
+func moved(was, now, tolerance float64) (grew, shrank bool) {
+	if was == 0 {
+		return now > 0, false
+	}
+	rel := (now - was) / was
+	return rel > tolerance, rel < -tolerance
+}
+
+// WriteMarkdown writes the diff as Markdown.
+func (d *Diff) WriteMarkdown(w io.Writer) {
+	if len(d.Changes) == 0 && len(d.New) == 0 && len(d.Missing) == 0 {
+		fmt.Fprintf(w, "No changes beyond ±%.0f%%.\n", d.Tolerance*100)
+		return
+	}
+	if len(d.Changes) > 0 {
+		fmt.Fprintf(w, "| case | target | what | baseline | now | change |\n|---|---|---|---:|---:|---|\n")
+		for _, c := range d.Changes {
+			verdict := "🟢 better"
+			if c.Regression {
+				verdict = "🔴 regression"
+			}
+			fmt.Fprintf(w, "| %s | %s | %s | %s | %s | %s%s |\n", c.Case, c.Target, c.What, c.Was, c.Now, verdict, percent(c))
+		}
+	}
+	if len(d.New) > 0 {
+		fmt.Fprintf(w, "\nNot in the baseline: %s\n", strings.Join(d.New, ", "))
+	}
+	if len(d.Missing) > 0 {
+		fmt.Fprintf(w, "\nIn the baseline but not run: %s\n", strings.Join(d.Missing, ", "))
+	}
+}
+
+// percent is a metric change's relative size, " (+35%)", or "" for status
+// changes and growth from zero.
+func percent(c Change) string {
+	var was, now float64
+	if _, err := fmt.Sscanf(strings.TrimPrefix(c.Was, "$"), "%g", &was); err != nil || was == 0 {
+		return ""
+	}
+	if _, err := fmt.Sscanf(strings.TrimPrefix(c.Now, "$"), "%g", &now); err != nil {
+		return ""
+	}
+	return fmt.Sprintf(" (%+.0f%%)", math.Round((now-was)/was*100))
+}
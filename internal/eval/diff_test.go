@@ -0,0 +1,82 @@
+package eval
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	target := Target{Backend: "openai"}
+	run := func(c string, s Status, tokens int64, iterations int, cost float64) Result {
+		return Result{Case: c, Target: target, Status: s, Metrics: Metrics{
+			Traced: true, InputTokens: tokens, Iterations: iterations, CostUSD: cost,
+		}}
+	}
+	baseline := &Report{Results: []Result{
+		run("steady", StatusPass, 1000, 4, 0.01),
+		run("bloated", StatusPass, 1000, 4, 0.01),
+		run("leaner", StatusPass, 1000, 4, 0.01),
+		run("broken", StatusPass, 1000, 4, 0.01),
+		run("fixed", StatusFail, 1000, 4, 0.01),
+		run("dropped", StatusPass, 1000, 4, 0.01),
+	}}
+	current := &Report{Results: []Result{
+		run("steady", StatusPass, 1150, 4, 0.0115),
+		run("bloated", StatusPass, 1500, 6, 0.01),
+		run("leaner", StatusPass, 500, 4, 0.01),
+		run("broken", StatusFail, 1000, 4, 0.01),
+		run("fixed", StatusPass, 9000, 9, 0.09),
+		run("added", StatusPass, 1000, 4, 0.01),
+	}}
+
+	d := Compare(baseline, current, 0.2)
+	var got []string
+	for _, c := range d.Changes {
+		got = append(got, c.String())
+	}
+	want := []string{
+		"bloated × openai: tokens 1000 → 1500",
+		"bloated × openai: iterations 4 → 6",
+		"leaner × openai: tokens 1000 → 500",
+		"broken × openai: status pass → fail",
+		"fixed × openai: status fail → pass",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("changes:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	var regressions []string
+	for _, c := range d.Regressions() {
+		regressions = append(regressions, c.Case+" "+c.What)
+	}
+	if strings.Join(regressions, ",") != "bloated tokens,bloated iterations,broken status" {
+		t.Errorf("regressions = %v", regressions)
+	}
+	if len(d.New) != 1 || d.New[0] != "added × openai" || len(d.Missing) != 1 || d.Missing[0] != "dropped × openai" {
+		t.Errorf("new = %v, missing = %v", d.New, d.Missing)
+	}
+
+	var md strings.Builder
+	d.WriteMarkdown(&md)
+	if !strings.Contains(md.String(), "| bloated | openai | tokens | 1000 | 1500 | 🔴 regression (+50%) |") {
+		t.Errorf("markdown:\n%s", md.String())
+	}
+}
+
+func TestPriceLookup(t *testing.T) {
+	p, ok := DefaultPrices.Lookup("gpt-5-mini-2025-08-07")
+	if !ok || p != DefaultPrices["gpt-5-mini"] {
+		t.Errorf("gpt-5-mini-2025-08-07 priced as %+v, want the gpt-5-mini price", p)
+	}
+	if _, ok := DefaultPrices.Lookup("llama3"); ok {
+		t.Error("an unknown model was priced")
+	}
+	// Cache reads come out of input for OpenAI, on top of it for Anthropic,
+	// whose cache writes cost 1.25 times input.
+	gpt := Price{Input: 1, CachedInput: 0.1, Output: 10}
+	if got := gpt.cost("gpt-5", usage{input: 1_000_000, cached: 500_000}); got != 0.55 {
+		t.Errorf("openai cost = %v, want 0.55", got)
+	}
+	if got := gpt.cost("claude-sonnet-4", usage{input: 1_000_000, cached: 500_000, cacheCreation: 400_000}); got != 1.55 {
+		t.Errorf("anthropic cost = %v, want 1.55", got)
+	}
+}
@@ -0,0 +1,138 @@
+package eval
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/fpt/klein-cli/internal/config"
+)
+
+// Target is one column of the matrix: a backend file, optionally with its
+// model overridden.
+type Target struct {
+	// Backend names the settings file backends/<Backend>.toml.
+	Backend string `json:"backend"`
+	// Model overrides the file's llm.model; "" keeps it.
+	Model string `json:"model,omitempty"`
+
+	settingsFile string
+	settings     *config.Settings
+}
+
+// Label is how reports name the target: "backend" or "backend:model".
+func (t Target) Label() string {
+	if t.Model == "" {
+		return t.Backend
+	}
+	return t.Backend + ":" + t.Model
+}
+
+// wholeAgent reports whether the target's backend runs whole turns itself
+// (codex, appserver) rather than through klein's ReAct loop.
+func (t Target) wholeAgent() bool {
+	return t.settings != nil && config.IsAgentServerBackend(t.settings.LLM.Backend)
+}
+
+// ResolveTargets turns specs of the form "backend" or "backend:model" into
+// targets backed by backendsDir/<backend>.toml. No specs means every backend
+// file in the directory.
+func ResolveTargets(backendsDir string, specs []string) ([]Target, error) {
+	if len(specs) == 0 {
+		files, err := filepath.Glob(filepath.Join(backendsDir, "*.toml"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(files)
+		for _, f := range files {
+			specs = append(specs, strings.TrimSuffix(filepath.Base(f), ".toml"))
+		}
+	}
+	var targets []Target
+	for _, spec := range specs {
+		backend, model, _ := strings.Cut(strings.TrimSpace(spec), ":")
+		if backend == "" {
+			return nil, fmt.Errorf("empty backend in %q", spec)
+		}
+		path := filepath.Join(backendsDir, backend+".toml")
+		var s config.Settings
+		if _, err := toml.DecodeFile(path, &s); err != nil {
+			return nil, fmt.Errorf("backend %s: %w", backend, err)
+		}
+		if model != "" {
+			s.LLM.Model = model
+		}
+		targets = append(targets, Target{Backend: backend, Model: model, settingsFile: path, settings: &s})
+	}
+	return targets, nil
+}
+
+// Unavailable says why the target cannot run here — a missing API key or
+// app-server binary — or "" when it can.
+func (t Target) Unavailable() string {
+	s := t.settings
+	if s == nil {
+		return "no settings"
+	}
+	switch s.LLM.Backend {
+	case config.BackendCodex:
+		bin := s.Codex.CodexPath
+		if bin == "" {
+			bin = "codex"
+		}
+		if _, err := exec.LookPath(bin); err != nil {
+			return fmt.Sprintf("codex binary %q not found", bin)
+		}
+	case config.BackendAppServer:
+		if s.AppServer.Command == "" {
+			return "no appserver.command in the backend file"
+		}
+		if _, err := exec.LookPath(s.AppServer.Command); err != nil {
+			return fmt.Sprintf("app-server binary %q not found", s.AppServer.Command)
+		}
+	default:
+		if key := apiKeyEnv(s.LLM.Backend); key != "" && os.Getenv(key) == "" {
+			return key + " not set"
+		}
+	}
+	return ""
+}
+
+// apiKeyEnv is the variable a chat backend reads its key from.
+func apiKeyEnv(backend string) string {
+	switch backend {
+	case "anthropic":
+		return "ANTHROPIC_API_KEY"
+	case "openai":
+		return "OPENAI_API_KEY"
+	case "gemini":
+		return "GEMINI_API_KEY"
+	}
+	return ""
+}
+
+// runSettings is the backend file with base_dir pointed at the run's private
+// directory and tracing to JSONL switched on: the run shares no sessions or
+// memory with the user's, and leaves the trace its metrics are read from.
+func (t Target) runSettings(baseDir string) ([]byte, error) {
+	raw, err := os.ReadFile(t.settingsFile)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]any
+	if err := toml.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("backend %s: %w", t.Backend, err)
+	}
+	doc["base_dir"] = baseDir
+	doc["trace"] = map[string]any{"exporter": config.TraceExporterJSONL}
+	var b strings.Builder
+	if err := toml.NewEncoder(&b).Encode(doc); err != nil {
+		return nil, err
+	}
+	return []byte(b.String()), nil
+}
@@ -0,0 +1,102 @@
+package eval
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fpt/klein-cli/internal/telemetry"
+	"github.com/fpt/klein-cli/pkg/agent/tracing"
+)
+
+// Metrics is what a run cost, read back from the trace klein wrote for it.
+type Metrics struct {
+	// Traced is false when the run left no spans: klein failed before its
+	// first turn, or a whole-agent backend ran the turn outside the ReAct loop.
+	// The counts are then zero because nothing was measured, not because
+	// nothing happened.
+	Traced bool `json:"traced"`
+
+	Iterations          int     `json:"iterations"`
+	LLMCalls            int     `json:"llm_calls"`
+	InputTokens         int64   `json:"input_tokens"`
+	OutputTokens        int64   `json:"output_tokens"`
+	CachedTokens        int64   `json:"cached_tokens,omitempty"`
+	CacheCreationTokens int64   `json:"cache_creation_tokens,omitempty"`
+	CostUSD             float64 `json:"cost_usd"`
+	// UnpricedCalls counts LLM calls to a model with no known price; CostUSD
+	// leaves them out.
+	UnpricedCalls int `json:"unpriced_calls,omitempty"`
+
+	ToolCalls      map[string]int `json:"tool_calls,omitempty"`
+	ToolErrors     int            `json:"tool_errors,omitempty"`
+	OffloadedTools int            `json:"offloaded_tools,omitempty"`
+}
+
+// TotalToolCalls is the number of tool calls of every name.
+func (m Metrics) TotalToolCalls() int {
+	n := 0
+	for _, c := range m.ToolCalls {
+		n += c
+	}
+	return n
+}
+
+// TotalTokens is input plus output tokens.
+func (m Metrics) TotalTokens() int64 { return m.InputTokens + m.OutputTokens }
+
+// readMetrics collects the spans of every trace file under baseDir, the
+// run's private base_dir: the fallback traces/ directory and any session
+// sidecars.
+func readMetrics(baseDir string, prices PriceTable) Metrics {
+	var spans []telemetry.Span
+	_ = filepath.WalkDir(baseDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if strings.HasSuffix(path, ".trace") || (strings.HasSuffix(path, ".jsonl") && filepath.Base(filepath.Dir(path)) == "traces") {
+			if s, err := telemetry.ReadTrace(path); err == nil {
+				spans = append(spans, s...)
+			}
+		}
+		return nil
+	})
+	return metricsFromSpans(spans, prices)
+}
+
+func metricsFromSpans(spans []telemetry.Span, prices PriceTable) Metrics {
+	m := Metrics{Traced: len(spans) > 0, ToolCalls: map[string]int{}}
+	for _, s := range spans {
+		switch s.Name {
+		case tracing.SpanIteration:
+			m.Iterations++
+		case tracing.SpanLLM:
+			m.LLMCalls++
+			u := usage{
+				input:         s.IntAttr(tracing.AttrInputTokens),
+				output:        s.IntAttr(tracing.AttrOutputTokens),
+				cached:        s.IntAttr(tracing.AttrCachedTokens),
+				cacheCreation: s.IntAttr(tracing.AttrCacheCreationTokens),
+			}
+			m.InputTokens += u.input
+			m.OutputTokens += u.output
+			m.CachedTokens += u.cached
+			m.CacheCreationTokens += u.cacheCreation
+			model := s.StringAttr(tracing.AttrModel)
+			if p, ok := prices.Lookup(model); ok {
+				m.CostUSD += p.cost(model, u)
+			} else {
+				m.UnpricedCalls++
+			}
+		case tracing.SpanTool:
+			m.ToolCalls[s.StringAttr(tracing.AttrToolName)]++
+			if s.Error != "" {
+				m.ToolErrors++
+			}
+			if s.BoolAttr(tracing.AttrOffloaded) {
+				m.OffloadedTools++
+			}
+		}
+	}
+	return m
+}
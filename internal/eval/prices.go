@@ -0,0 +1,82 @@
+package eval
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Price is a model's list price in USD per million tokens.
+type Price struct {
+	Input       float64 `json:"input"`
+	CachedInput float64 `json:"cached_input"`
+	Output      float64 `json:"output"`
+}
+
+// PriceTable maps a model name, or a prefix of one, to its price. The longest
+// matching prefix wins, so "gpt-5-mini" is not priced as "gpt-5".
+type PriceTable map[string]Price
+
+// DefaultPrices are list prices at the time of writing. They drift; pass
+// `klein eval --prices` a JSON file of the same shape to correct or extend
+// them. Costs compare runs against each other more than they bill anyone.
+var DefaultPrices = PriceTable{
+	"claude-opus-4":         {Input: 15, CachedInput: 1.5, Output: 75},
+	"claude-opus-4-5":       {Input: 5, CachedInput: 0.5, Output: 25},
+	"claude-opus-4-6":       {Input: 5, CachedInput: 0.5, Output: 25},
+	"claude-sonnet-4":       {Input: 3, CachedInput: 0.3, Output: 15},
+	"claude-haiku-4-5":      {Input: 1, CachedInput: 0.1, Output: 5},
+	"gpt-5":                 {Input: 1.25, CachedInput: 0.125, Output: 10},
+	"gpt-5-mini":            {Input: 0.25, CachedInput: 0.025, Output: 2},
+	"gpt-5-nano":            {Input: 0.05, CachedInput: 0.005, Output: 0.4},
+	"gemini-2.5-pro":        {Input: 1.25, CachedInput: 0.125, Output: 10},
+	"gemini-2.5-flash":      {Input: 0.3, CachedInput: 0.03, Output: 2.5},
+	"gemini-2.5-flash-lite": {Input: 0.1, CachedInput: 0.01, Output: 0.4},
+}
+
+// LoadPrices reads a JSON price file and lays it over DefaultPrices.
+func LoadPrices(path string) (PriceTable, error) {
+	raw, err := os.ReadFile(path) //nolint:gosec // a price file the user named
+	if err != nil {
+		return nil, err
+	}
+	var extra PriceTable
+	if err := decodeStrict(raw, &extra); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	table := PriceTable{}
+	for k, v := range DefaultPrices {
+		table[k] = v
+	}
+	for k, v := range extra {
+		table[k] = v
+	}
+	return table, nil
+}
+
+// Lookup finds the price of model by longest prefix.
+func (t PriceTable) Lookup(model string) (Price, bool) {
+	best, found := "", false
+	for prefix := range t {
+		if strings.HasPrefix(model, prefix) && len(prefix) >= len(best) {
+			best, found = prefix, true
+		}
+	}
+	return t[best], found
+}
+
+// usage is one LLM call's token counts as its span recorded them.
+type usage struct {
+	input, output, cached, cacheCreation int64
+}
+
+// cost prices one call. Most clients count cache reads inside input tokens;
+// Anthropic's reports them, and cache writes, separately, writes billed at
+// 1.25 times the input price.
+func (p Price) cost(model string, u usage) float64 {
+	uncached := float64(max(u.input-u.cached, 0))
+	if strings.HasPrefix(model, "claude") {
+		uncached = float64(u.input) + 1.25*float64(u.cacheCreation)
+	}
+	return (uncached*p.Input + float64(u.cached)*p.CachedInput + float64(u.output)*p.Output) / 1e6
+}
@@ -0,0 +1,272 @@
+package eval
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Report is one `klein eval` run: what ran, with what, and how it went. Its
+// JSON form is also the baseline a later run is diffed against.
+type Report struct {
+	Started time.Time `json:"started"`
+	// Version identifies the klein under test, e.g. the git revision.
+	Version string   `json:"version,omitempty"`
+	Results []Result `json:"results"`
+}
+
+// Summary counts results by status.
+type Summary struct {
+	Pass, Fail, Skip int
+	CostUSD          float64
+	WallTime         time.Duration
+}
+
+// Summary totals the report's results.
+func (r *Report) Summary() Summary {
+	var s Summary
+	for _, res := range r.Results {
+		switch res.Status {
+		case StatusPass:
+			s.Pass++
+		case StatusFail:
+			s.Fail++
+		case StatusSkip:
+			s.Skip++
+		}
+		s.CostUSD += res.Metrics.CostUSD
+		s.WallTime += res.WallTime
+	}
+	return s
+}
+
+// ReadReport reads a report written by WriteJSON.
+func ReadReport(path string) (*Report, error) {
+	raw, err := os.ReadFile(path) //nolint:gosec // a report the user named
+	if err != nil {
+		return nil, err
+	}
+	var r Report
+	if err := json.Unmarshal(raw, &r); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &r, nil
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// JUnit XML, in the subset CI systems agree on: a suite per target, a test
+// case per testcase.
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Skipped  int         `xml:"skipped,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Body    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the report as JUnit XML. A case's metrics go in its
+// system-out, where CI shows them next to the result.
+func (r *Report) WriteJUnit(w io.Writer) error {
+	var suites junitSuites
+	index := map[string]int{}
+	for _, res := range r.Results {
+		label := res.Target.Label()
+		i, ok := index[label]
+		if !ok {
+			i = len(suites.Suites)
+			index[label] = i
+			suites.Suites = append(suites.Suites, junitSuite{Name: label})
+		}
+		s := &suites.Suites[i]
+		jc := junitCase{Name: res.Case, Classname: "klein.eval." + label, Time: seconds(res.WallTime)}
+		switch res.Status {
+		case StatusFail:
+			s.Failures++
+			jc.Failure = &junitMessage{Message: res.Reason, Body: res.Output}
+		case StatusSkip:
+			s.Skipped++
+			jc.Skipped = &junitMessage{Message: res.Reason}
+		}
+		if res.Status != StatusSkip {
+			jc.SystemOut = metricsLine(res.Metrics)
+		}
+		s.Tests++
+		s.Cases = append(s.Cases, jc)
+	}
+	for i := range suites.Suites {
+		var total time.Duration
+		for _, res := range r.Results {
+			if res.Target.Label() == suites.Suites[i].Name {
+				total += res.WallTime
+			}
+		}
+		suites.Suites[i].Time = seconds(total)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// WriteMarkdown writes the report as Markdown: a pass/fail matrix, the
+// metrics of every run, the reasons for failures and, when given, the diff
+// against a baseline.
+func (r *Report) WriteMarkdown(w io.Writer, diff *Diff) {
+	sum := r.Summary()
+	fmt.Fprintf(w, "# klein eval — %s\n\n", r.Started.Local().Format("2006-01-02 15:04"))
+	if r.Version != "" {
+		fmt.Fprintf(w, "Version: `%s`\n\n", r.Version)
+	}
+	fmt.Fprintf(w, "**%d passed, %d failed, %d skipped** · $%.4f · %s of agent time\n\n",
+		sum.Pass, sum.Fail, sum.Skip, sum.CostUSD, sum.WallTime.Round(time.Second))
+
+	cases, targets := r.axes()
+	fmt.Fprintf(w, "| case | %s |\n|---|%s\n", strings.Join(targets, " | "), strings.Repeat("---|", len(targets)))
+	for _, c := range cases {
+		row := []string{c}
+		for _, t := range targets {
+			row = append(row, statusMark(r.find(c, t)))
+		}
+		fmt.Fprintf(w, "| %s |\n", strings.Join(row, " | "))
+	}
+
+	fmt.Fprintf(w, "\n## Runs\n\n| case | target | status | iterations | LLM calls | tokens in / out (cached) | tool calls | cost | time |\n|---|---|---|---:|---:|---|---:|---:|---:|\n")
+	for _, res := range r.Results {
+		if res.Status == StatusSkip {
+			continue
+		}
+		m := res.Metrics
+		fmt.Fprintf(w, "| %s | %s | %s | %d | %d | %d / %d (%d) | %d | $%.4f | %s |\n",
+			res.Case, res.Target.Label(), statusMark(&res), m.Iterations, m.LLMCalls,
+			m.InputTokens, m.OutputTokens, m.CachedTokens, m.TotalToolCalls(), m.CostUSD, res.WallTime.Round(time.Second))
+	}
+
+	var failed []Result
+	for _, res := range r.Results {
+		if res.Status == StatusFail {
+			failed = append(failed, res)
+		}
+	}
+	if len(failed) > 0 {
+		fmt.Fprintf(w, "\n## Failures\n")
+		for _, res := range failed {
+			fmt.Fprintf(w, "\n### %s × %s\n\n%s\n", res.Case, res.Target.Label(), res.Reason)
+			if res.WorkDir != "" {
+				fmt.Fprintf(w, "\nKept in `%s`.\n", res.WorkDir)
+			}
+			if res.Output != "" {
+				fmt.Fprintf(w, "\n```\n%s\n```\n", strings.TrimRight(res.Output, "\n"))
+			}
+		}
+	}
+	if diff != nil {
+		fmt.Fprintf(w, "\n## Against the baseline\n\n")
+		diff.WriteMarkdown(w)
+	}
+}
+
+// axes are the report's case names and target labels in first-seen order.
+func (r *Report) axes() (cases, targets []string) {
+	for _, res := range r.Results {
+		if !slices.Contains(cases, res.Case) {
+			cases = append(cases, res.Case)
+		}
+		if l := res.Target.Label(); !slices.Contains(targets, l) {
+			targets = append(targets, l)
+		}
+	}
+	sort.Strings(cases)
+	return cases, targets
+}
+
+// find is the result of case c on the target labelled t, or nil.
+func (r *Report) find(c, t string) *Result {
+	for i := range r.Results {
+		if r.Results[i].Case == c && r.Results[i].Target.Label() == t {
+			return &r.Results[i]
+		}
+	}
+	return nil
+}
+
+func statusMark(res *Result) string {
+	if res == nil {
+		return ""
+	}
+	switch res.Status {
+	case StatusPass:
+		return "✅"
+	case StatusFail:
+		return "❌"
+	default:
+		return "—"
+	}
+}
+
+// Line is the result in one line, for console progress.
+func (res Result) Line() string {
+	line := fmt.Sprintf("%s %s × %s", statusMark(&res), res.Case, res.Target.Label())
+	switch res.Status {
+	case StatusSkip:
+		return line + ": " + res.Reason
+	case StatusFail:
+		line += ": " + res.Reason
+	}
+	return fmt.Sprintf("%s [%s; %s]", line, res.WallTime.Round(100*time.Millisecond), metricsLine(res.Metrics))
+}
+
+// metricsLine is one run's metrics in a line, for the console and JUnit.
+func metricsLine(m Metrics) string {
+	if !m.Traced {
+		return "no trace (whole-agent backend, or klein failed early)"
+	}
+	line := fmt.Sprintf("%d iterations, %d LLM calls, %d in / %d out tokens", m.Iterations, m.LLMCalls, m.InputTokens, m.OutputTokens)
+	if m.CachedTokens > 0 {
+		line += fmt.Sprintf(" (%d cached)", m.CachedTokens)
+	}
+	line += fmt.Sprintf(", %d tool calls, $%.4f", m.TotalToolCalls(), m.CostUSD)
+	if m.UnpricedCalls > 0 {
+		line += fmt.Sprintf(" (+%d unpriced calls)", m.UnpricedCalls)
+	}
+	return line
+}
+
+func seconds(d time.Duration) string { return fmt.Sprintf("%.3f", d.Seconds()) }
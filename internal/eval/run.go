@@ -0,0 +1,298 @@
+package eval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Status is how one case × target run ended.
+type Status string
+
+const (
+	StatusPass Status = "pass"
+	StatusFail Status = "fail"
+	// StatusSkip is a run that never started: its backend is unavailable
+	// here, or the case needs a tool the backend cannot have.
+	StatusSkip Status = "skip"
+)
+
+// Result is one cell of the matrix.
+type Result struct {
+	Case   string `json:"case"`
+	Target Target `json:"target"`
+	Status Status `json:"status"`
+	// Reason says why a run failed or was skipped.
+	Reason string `json:"reason,omitempty"`
+	// Output is the tail of klein's output and the checks' output, kept for
+	// failures only.
+	Output   string        `json:"output,omitempty"`
+	ExitCode int           `json:"exit_code"`
+	WallTime time.Duration `json:"wall_time_ns"`
+	Metrics  Metrics       `json:"metrics"`
+	// WorkDir is the run's directory, kept on failure for a look at what the
+	// agent left behind; "" once removed.
+	WorkDir string `json:"work_dir,omitempty"`
+}
+
+// Runner runs cases against targets by starting the klein binary once per
+// pair, each in its own copy of the testcase.
+type Runner struct {
+	// Binary is the klein executable to run.
+	Binary string
+	// SuiteDir is the suite root. Its extract_response.sh, when present, is
+	// copied beside each case as the shell runner did, for check scripts that
+	// call it.
+	SuiteDir string
+	// Concurrency is how many runs go at once; below 1 means 1.
+	Concurrency int
+	// Timeout bounds one run, agent and checks together, unless the case's
+	// config.json sets its own.
+	Timeout time.Duration
+	Prices  PriceTable
+	// KeepAll keeps every run's directory, not only failed runs'.
+	KeepAll bool
+	// Progress, when set, is called as each run finishes, from the run's
+	// goroutine.
+	Progress func(Result)
+}
+
+// job is one cell of the matrix waiting to run.
+type job struct {
+	index  int
+	c      Case
+	target Target
+}
+
+// Run runs every case against every target and returns the results in
+// matrix order, targets outermost. Runs are independent; one failing never
+// stops the others. Cancelling ctx kills the runs in flight and fails the
+// ones not yet started.
+func (r *Runner) Run(ctx context.Context, cases []Case, targets []Target) []Result {
+	results := make([]Result, len(cases)*len(targets))
+	jobs := make(chan job)
+	workers := max(r.Concurrency, 1)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				res := r.runOne(ctx, j.c, j.target)
+				results[j.index] = res
+				if r.Progress != nil {
+					r.Progress(res)
+				}
+			}
+		}()
+	}
+	i := 0
+	for _, t := range targets {
+		for _, c := range cases {
+			jobs <- job{index: i, c: c, target: t}
+			i++
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+// runOne runs a single case against a single target.
+func (r *Runner) runOne(ctx context.Context, c Case, t Target) (res Result) {
+	res = Result{Case: c.Name, Target: t}
+	if why := t.Unavailable(); why != "" {
+		res.Status, res.Reason = StatusSkip, why
+		return res
+	}
+	if t.wholeAgent() && c.requiresKleinLoop() {
+		res.Status, res.Reason = StatusSkip, "needs a klein-loop-only tool; N/A for a whole-agent backend"
+		return res
+	}
+	if err := ctx.Err(); err != nil {
+		res.Status, res.Reason = StatusFail, err.Error()
+		return res
+	}
+
+	timeout := r.Timeout
+	if c.Config.Timeout != "" {
+		timeout, _ = time.ParseDuration(c.Config.Timeout) // validated by LoadCase
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	dir, err := os.MkdirTemp("", "klein-eval-"+c.Name+"-")
+	if err != nil {
+		res.Status, res.Reason = StatusFail, err.Error()
+		return res
+	}
+	res.WorkDir = dir
+	defer func() {
+		if res.Status == StatusPass && !r.KeepAll {
+			_ = os.RemoveAll(dir)
+			res.WorkDir = ""
+		}
+	}()
+
+	failed, outputs := r.execute(ctx, c, t, dir, &res)
+	switch {
+	case failed != "":
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		failed = "timed out after " + timeout.String()
+	case ctx.Err() != nil:
+		failed = ctx.Err().Error()
+	}
+	if failed != "" {
+		res.Status, res.Reason = StatusFail, failed
+		res.Output = tail(outputs, 60)
+	} else {
+		res.Status = StatusPass
+	}
+	return res
+}
+
+// execute prepares dir, runs klein in it and checks the outcome. It returns
+// why the run failed, "" if it passed, and the output worth showing if not.
+func (r *Runner) execute(ctx context.Context, c Case, t Target, dir string, res *Result) (failed, output string) {
+	work, base := filepath.Join(dir, "work"), filepath.Join(dir, "base")
+	if err := copyDir(c.Dir, work); err != nil {
+		return err.Error(), ""
+	}
+	if r.SuiteDir != "" {
+		if err := copyFile(filepath.Join(r.SuiteDir, "extract_response.sh"), filepath.Join(work, "extract_response.sh")); err != nil &&
+			!errors.Is(err, fs.ErrNotExist) {
+			return err.Error(), ""
+		}
+	}
+	settings, err := t.runSettings(base)
+	if err != nil {
+		return err.Error(), ""
+	}
+	settingsPath := filepath.Join(dir, "settings.toml")
+	if err := os.WriteFile(settingsPath, settings, 0o600); err != nil {
+		return err.Error(), ""
+	}
+
+	args := []string{"--workdir", work, "--settings", settingsPath, "-f", filepath.Join(work, PromptFile)}
+	if t.Model != "" {
+		args = append(args, "-m", t.Model)
+	}
+	if c.Config.Role != "" {
+		args = append(args, "--role", c.Config.Role)
+	}
+	if len(c.Config.AllowedTools) > 0 {
+		args = append(args, "--allowed-tools", strings.Join(c.Config.AllowedTools, ","))
+	}
+	stdoutPath, stderrPath := filepath.Join(dir, "stdout.txt"), filepath.Join(dir, "stderr.txt")
+	stdout, err := os.Create(stdoutPath) //nolint:gosec // inside the run's own temp dir
+	if err != nil {
+		return err.Error(), ""
+	}
+	defer stdout.Close()
+	stderr, err := os.Create(stderrPath) //nolint:gosec // inside the run's own temp dir
+	if err != nil {
+		return err.Error(), ""
+	}
+	defer stderr.Close()
+
+	cmd := exec.CommandContext(ctx, r.Binary, args...) //nolint:gosec // the klein binary under test
+	cmd.Dir = work
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	start := time.Now()
+	runErr := cmd.Run()
+	res.WallTime = time.Since(start)
+	res.Metrics = readMetrics(base, r.Prices)
+	kleinOutput := readTail(stdoutPath) + readTail(stderrPath)
+
+	if runErr != nil {
+		var exitErr *exec.ExitError
+		if errors.As(runErr, &exitErr) {
+			res.ExitCode = exitErr.ExitCode()
+		}
+		if ctx.Err() != nil {
+			return "", kleinOutput
+		}
+		return fmt.Sprintf("klein failed: %v", runErr), kleinOutput
+	}
+
+	var failures []string
+	var checkOutput string
+	if c.Check != "" {
+		check := exec.CommandContext(ctx, filepath.Join(work, CheckFile), stdoutPath, stderrPath) //nolint:gosec // the case's own check
+		check.Dir = work
+		check.Env = append(os.Environ(), "TESTSUITE_DIR="+r.SuiteDir)
+		out, err := check.CombinedOutput()
+		checkOutput = string(out)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", CheckFile, err))
+		}
+	}
+	if c.Assert != nil {
+		raw, _ := os.ReadFile(stdoutPath) //nolint:gosec // inside the run's own temp dir
+		failures = append(failures, c.Assert.Check(ctx, work, string(raw), res.Metrics, t.wholeAgent())...)
+	}
+	if len(failures) > 0 {
+		return strings.Join(failures, "; "), kleinOutput + checkOutput
+	}
+	return "", ""
+}
+
+// readTail is the last lines of a file, or "" if it cannot be read.
+func readTail(path string) string {
+	raw, err := os.ReadFile(path) //nolint:gosec // inside the run's own temp dir
+	if err != nil || len(raw) == 0 {
+		return ""
+	}
+	return tail(string(raw), 60) + "\n"
+}
+
+// copyDir copies the testcase tree src to dst, keeping file modes so check
+// scripts stay executable.
+func copyDir(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0o755)
+		}
+		return copyFile(path, target)
+	})
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src) //nolint:gosec // a testcase file
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	fi, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, fi.Mode().Perm()) //nolint:gosec // inside the run's own temp dir
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
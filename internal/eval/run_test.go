@@ -0,0 +1,261 @@
+package eval
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fpt/klein-cli/internal/telemetry"
+	"github.com/fpt/klein-cli/pkg/agent/tracing"
+)
+
+// fakeKlein is a stand-in for the binary under test: it writes add.go into
+// its workdir, drops a canned trace into the base_dir its settings name, and
+// prints an answer.
+const fakeKlein = `#!/bin/sh
+while [ $# -gt 0 ]; do
+  case "$1" in
+    --workdir) work="$2"; shift ;;
+    --settings) settings="$2"; shift ;;
+  esac
+  shift
+done
+base=$(sed -n 's/^base_dir = "\(.*\)"$/\1/p' "$settings")
+mkdir -p "$base/traces" && cp "$KLEIN_FAKE_TRACE" "$base/traces/run.jsonl"
+printf 'package main\n\nfunc add(a, b int) int { return a + b }\n' > "$work/add.go"
+echo "the sum is 5"
+`
+
+// writeSuite lays out a suite: a passing assert.json case, a failing one, a
+// check.sh case, and two backends, one of which cannot run here.
+func writeSuite(t *testing.T) string {
+	t.Helper()
+	suite := t.TempDir()
+	write := func(rel, content string, mode os.FileMode) {
+		path := filepath.Join(suite, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("testcases/adds/prompt.txt", "write add.go", 0o644)
+	write("testcases/adds/assert.json", `{
+		"files": [{"path": "add.go", "matches": ["func add\\("]}],
+		"output": {"contains": ["sum is 5"]},
+		"tools_used": ["Write"],
+		"max_iterations": 3
+	}`, 0o644)
+	write("testcases/strict/prompt.txt", "write nothing", 0o644)
+	write("testcases/strict/assert.json", `{"absent": ["add.go"], "max_tool_calls": 1}`, 0o644)
+	write("testcases/checked/prompt.txt", "say the sum", 0o644)
+	write("testcases/checked/check.sh", "#!/bin/sh\ngrep -q 'sum is 5' \"$1\" && test -x extract_response.sh && test -d \"$TESTSUITE_DIR\"\n", 0o755)
+	write("extract_response.sh", "#!/bin/sh\n", 0o755)
+	write("backends/anthropic.toml", "name = \"anthropic\"\n\n[llm]\nbackend = \"anthropic\"\nmodel = \"claude-sonnet-4-6\"\n", 0o644)
+	write("backends/codex.toml", "[llm]\nbackend = \"codex\"\n\n[codex]\ncodex_path = \"/nonexistent/codex\"\n", 0o644)
+	return suite
+}
+
+// writeTrace writes the canned trace the fake binary hands every run: two
+// iterations, two LLM calls, a Write and a Read.
+func writeTrace(t *testing.T) string {
+	t.Helper()
+	llm := map[string]any{
+		string(tracing.AttrModel):        "claude-sonnet-4-6",
+		string(tracing.AttrInputTokens):  1000,
+		string(tracing.AttrOutputTokens): 200,
+	}
+	spans := []telemetry.Span{
+		{Name: tracing.SpanInvoke},
+		{Name: tracing.SpanIteration},
+		{Name: tracing.SpanIteration},
+		{Name: tracing.SpanLLM, Attributes: llm},
+		{Name: tracing.SpanLLM, Attributes: llm},
+		{Name: tracing.SpanTool, Attributes: map[string]any{string(tracing.AttrToolName): "Write"}},
+		{Name: tracing.SpanTool, Attributes: map[string]any{string(tracing.AttrToolName): "Read"}, Error: "no such file"},
+	}
+	var b bytes.Buffer
+	for _, s := range spans {
+		if err := json.NewEncoder(&b).Encode(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := filepath.Join(t.TempDir(), "trace.jsonl")
+	if err := os.WriteFile(path, b.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRunner(t *testing.T) {
+	suite := writeSuite(t)
+	t.Setenv("KLEIN_FAKE_TRACE", writeTrace(t))
+	t.Setenv("ANTHROPIC_API_KEY", "test")
+	t.Setenv("TMPDIR", t.TempDir()) // where the failed run's directory is kept
+	binary := filepath.Join(t.TempDir(), "klein")
+	if err := os.WriteFile(binary, []byte(fakeKlein), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	cases, err := LoadCases(filepath.Join(suite, "testcases"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	targets, err := ResolveTargets(filepath.Join(suite, "backends"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var progressed atomic.Int32
+	r := &Runner{Binary: binary, SuiteDir: suite, Concurrency: 3, Timeout: time.Minute, Prices: DefaultPrices,
+		Progress: func(Result) { progressed.Add(1) }}
+	results := r.Run(context.Background(), cases, targets)
+
+	if len(results) != 6 || progressed.Load() != 6 {
+		t.Fatalf("got %d results, %d progress calls; want 6 of each", len(results), progressed.Load())
+	}
+	got := map[string]Result{}
+	for _, res := range results {
+		got[res.Case+"/"+res.Target.Label()] = res
+	}
+	for _, c := range []string{"adds", "checked", "strict"} {
+		if res := got[c+"/codex"]; res.Status != StatusSkip || !strings.Contains(res.Reason, "not found") {
+			t.Errorf("%s on codex: %s (%s), want a skip for the missing binary", c, res.Status, res.Reason)
+		}
+	}
+	for _, c := range []string{"adds", "checked"} {
+		res := got[c+"/anthropic"]
+		if res.Status != StatusPass {
+			t.Errorf("%s: %s (%s)\n%s", c, res.Status, res.Reason, res.Output)
+		}
+		if res.WorkDir != "" {
+			t.Errorf("%s: passed run's directory kept at %s", c, res.WorkDir)
+		}
+	}
+	strict := got["strict/anthropic"]
+	if strict.Status != StatusFail ||
+		!strings.Contains(strict.Reason, "add.go exists but should not") ||
+		!strings.Contains(strict.Reason, "2 tool calls, at most 1 allowed") {
+		t.Errorf("strict: %s (%s)", strict.Status, strict.Reason)
+	}
+	if strict.WorkDir == "" {
+		t.Error("failed run's directory was not kept")
+	}
+
+	m := got["adds/anthropic"].Metrics
+	if !m.Traced || m.Iterations != 2 || m.LLMCalls != 2 || m.InputTokens != 2000 || m.OutputTokens != 400 ||
+		m.ToolCalls["Write"] != 1 || m.ToolErrors != 1 {
+		t.Errorf("metrics = %+v", m)
+	}
+	// 2000 input at $3/M plus 400 output at $15/M.
+	if want := 0.012; m.CostUSD < want-1e-9 || m.CostUSD > want+1e-9 {
+		t.Errorf("cost = %v, want %v", m.CostUSD, want)
+	}
+
+	report := &Report{Started: time.Now(), Results: results}
+	if s := report.Summary(); s.Pass != 2 || s.Fail != 1 || s.Skip != 3 {
+		t.Errorf("summary = %+v", s)
+	}
+	var junit bytes.Buffer
+	if err := report.WriteJUnit(&junit); err != nil {
+		t.Fatal(err)
+	}
+	var parsed junitSuites
+	if err := xml.Unmarshal(junit.Bytes(), &parsed); err != nil {
+		t.Fatalf("junit does not parse: %v\n%s", err, junit.String())
+	}
+	if len(parsed.Suites) != 2 || parsed.Suites[0].Name != "anthropic" || parsed.Suites[0].Failures != 1 || parsed.Suites[1].Skipped != 3 {
+		t.Errorf("junit suites = %+v", parsed.Suites)
+	}
+	var md strings.Builder
+	report.WriteMarkdown(&md, nil)
+	for _, want := range []string{"**2 passed, 1 failed, 3 skipped**", "| case | anthropic | codex |", "| adds | ✅ | — |", "### strict × anthropic"} {
+		if !strings.Contains(md.String(), want) {
+			t.Errorf("markdown lacks %q:\n%s", want, md.String())
+		}
+	}
+}
+
+// TestRunner_Untraced checks that a klein-loop run without a trace fails its
+// trace-based assertions instead of passing them unchecked, while a
+// whole-agent run is not held to them.
+func TestRunner_Untraced(t *testing.T) {
+	suite := writeSuite(t)
+	empty := filepath.Join(t.TempDir(), "trace.jsonl")
+	if err := os.WriteFile(empty, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("KLEIN_FAKE_TRACE", empty)
+	t.Setenv("ANTHROPIC_API_KEY", "test")
+	t.Setenv("TMPDIR", t.TempDir())
+	binary := filepath.Join(t.TempDir(), "klein")
+	if err := os.WriteFile(binary, []byte(fakeKlein), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	cases, err := LoadCases(filepath.Join(suite, "testcases"), []string{"adds", "checked"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	targets, err := ResolveTargets(filepath.Join(suite, "backends"), []string{"anthropic"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &Runner{Binary: binary, SuiteDir: suite, Concurrency: 2, Timeout: time.Minute, Prices: DefaultPrices}
+	got := map[string]Result{}
+	for _, res := range r.Run(context.Background(), cases, targets) {
+		got[res.Case] = res
+	}
+	if res := got["adds"]; res.Status != StatusFail || !strings.Contains(res.Reason, "no trace recorded") {
+		t.Errorf("adds: %s (%s), want a failure for the missing trace", res.Status, res.Reason)
+	}
+	if res := got["checked"]; res.Status != StatusPass {
+		t.Errorf("checked: %s (%s), has no trace-based assertion and should pass", res.Status, res.Reason)
+	}
+
+	a := &Assertions{ToolsUsed: []string{"Write"}, MaxIterations: 3}
+	if failures := a.Check(context.Background(), t.TempDir(), "", Metrics{}, true); len(failures) != 0 {
+		t.Errorf("whole-agent run: %v, want no trace-based failures", failures)
+	}
+}
+
+func TestLoadCase_Errors(t *testing.T) {
+	for name, files := range map[string]map[string]string{
+		"no prompt":        {AssertFile: `{}`},
+		"no check":         {PromptFile: "x"},
+		"unknown key":      {PromptFile: "x", AssertFile: `{"file": []}`},
+		"bad pattern":      {PromptFile: "x", AssertFile: `{"output": {"matches": ["("]}}`},
+		"bad timeout":      {PromptFile: "x", AssertFile: `{}`, ConfigFile: `{"timeout": "soon"}`},
+		"stale config key": {PromptFile: "x", AssertFile: `{}`, ConfigFile: `{"skill": "code"}`},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			for f, content := range files {
+				if err := os.WriteFile(filepath.Join(dir, f), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if _, err := LoadCase(dir); err == nil {
+				t.Error("loaded without error")
+			}
+		})
+	}
+}
+
+func TestLoadCases_UnknownName(t *testing.T) {
+	suite := writeSuite(t)
+	if _, err := LoadCases(filepath.Join(suite, "testcases"), []string{"adds", "nope"}); err == nil {
+		t.Error("an unknown testcase name was ignored")
+	}
+	cases, err := LoadCases(filepath.Join(suite, "testcases"), []string{"strict"})
+	if err != nil || len(cases) != 1 || cases[0].Name != "strict" {
+		t.Errorf("cases = %v, err = %v", cases, err)
+	}
+}
@@ -1,6 +1,10 @@
 package gateway
 
-import "context"
+import (
+	"context"
+
+	agentv1 "github.com/fpt/klein-cli/internal/gen/agentv1"
+)
 
 // Adapter is the interface all channel adapters implement.
 type Adapter interface {
@@ -13,3 +17,12 @@ type Adapter interface {
 	// SendTyping shows a typing indicator in the channel.
 	SendTyping(ctx context.Context, channelID string) error
 }
+
+// ApprovalAdapter is implemented by adapters that can put a tool call awaiting
+// approval in front of the people allowed to decide it.
+type ApprovalAdapter interface {
+	// RequestApproval posts the prompt and blocks until an authorized user
+	// answers or ctx ends. It returns the decision and who made it; anything
+	// other than an answer (no approvers, send failure, ctx end) is a deny.
+	RequestApproval(ctx context.Context, req ApprovalPrompt) (agentv1.ApprovalDecision, string)
+}
@@ -20,6 +20,11 @@ type InboundMessage struct {
 	Images      [][]byte
 	Silent      bool   // when true, skip posting the final response back to the channel
 	Skill       string // when non-empty, overrides the session's skill for THIS turn only
+
+	// Set by the scheduler, which waits on Result for the run's outcome.
+	Result       chan<- RunResult // when non-nil, receives exactly one RunResult (buffered by the sender)
+	Timeout      time.Duration    // when > 0, the agent turn is cancelled after this long
+	QuietFailure bool             // a retry follows a failure: record it, don't post the error to the channel
 }
 
 // OutboundMessage represents a message to send back to a channel.
@@ -28,6 +33,25 @@ type OutboundMessage struct {
 	ChannelID   string
 	Text        string
 	ReplyToID   string // optional: reply to specific message
+	Files       []OutboundFile
+}
+
+// OutboundFile is a file sent with an outbound message, such as a report the
+// agent rendered.
+type OutboundFile struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// ApprovalPrompt asks a channel to approve one pending tool call.
+type ApprovalPrompt struct {
+	ChannelType string
+	ChannelID   string
+	ReplyToID   string
+	RequesterID string // peer whose message started the turn ("scheduler:<name>" for jobs)
+	ToolName    string
+	Description string
 }
 
 // MessageBus decouples channel adapters from the agent routing.
@@ -14,11 +14,16 @@ import (
 // is NOT configured here — it is derived from the shared base dir so the CLI and
 // the gateway agree on locations. See ParseClawConfig.
 type GatewayConfig struct {
-	AgentAddr      string        `toml:"agent_addr"`      // Connect server address; empty = start an embedded in-process server
-	WorkingDir     string        `toml:"working_dir"`     // Agent working directory
-	SessionTimeout string        `toml:"session_timeout"` // Inactivity timeout for sessions (Go duration, default: "30m")
-	Discord        DiscordConfig `toml:"discord"`
-	Memory         MemoryConfig  `toml:"memory"` // Only MaxNotes is read from the file; BaseDir is derived from the shared base dir.
+	AgentAddr      string `toml:"agent_addr"`      // Connect server address; empty = start an embedded in-process server
+	WorkingDir     string `toml:"working_dir"`     // Agent working directory
+	SessionTimeout string `toml:"session_timeout"` // Inactivity timeout for sessions (Go duration, default: "30m")
+	// ApprovalTimeout is how long a tool call that needs approval (Write, Edit,
+	// non-whitelisted Bash, ask rules) waits for an allowed user to answer the
+	// channel prompt before it is denied (Go duration, default: "5m").
+	// Persistent allow rules (`klein permissions`) skip the prompt.
+	ApprovalTimeout string        `toml:"approval_timeout"`
+	Discord         DiscordConfig `toml:"discord"`
+	Memory          MemoryConfig  `toml:"memory"` // Only MaxNotes is read from the file; BaseDir is derived from the shared base dir.
 
 	// Schedules is the multi-job scheduler. Each entry runs on its own
 	// goroutine, fires on a cron expression evaluated in its timezone, and can
@@ -30,11 +35,12 @@ type GatewayConfig struct {
 	Schedules []ScheduleConfig `toml:"schedules,omitempty"`
 
 	// Derived from the shared base dir (set by ParseClawConfig, not from the
-	// claw block). SessionsDir and SchedulesFile are used directly; the
-	// memory directory is written into Memory.BaseDir.
-	BaseDir       string `toml:"-"`
-	SessionsDir   string `toml:"-"`
-	SchedulesFile string `toml:"-"`
+	// claw block). SessionsDir, SchedulesFile and ScheduleLedgerFile are used
+	// directly; the memory directory is written into Memory.BaseDir.
+	BaseDir            string `toml:"-"`
+	SessionsDir        string `toml:"-"`
+	SchedulesFile      string `toml:"-"`
+	ScheduleLedgerFile string `toml:"-"` // scheduler run history, pause state and queued runs (SQLite)
 }
 
 // DiscordConfig holds Discord bot configuration.
@@ -42,13 +48,14 @@ type DiscordConfig struct {
 	Token             string   `toml:"token"`
 	AllowedGuildIDs   []string `toml:"allowed_guild_ids"`
 	AllowedChannelIDs []string `toml:"allowed_channel_ids"`
-	AllowedUserIDs    []string `toml:"allowed_user_ids"`
-	MentionOnly       bool     `toml:"mention_only"` // In guilds, only respond when @mentioned
+	AllowedUserIDs    []string `toml:"allowed_user_ids"` // also the only users who may answer tool-approval prompts
+	MentionOnly       bool     `toml:"mention_only"`     // In guilds, only respond when @mentioned
 }
 
 // ParseClawConfig decodes the "claw" section of settings.toml (block may be nil
 // or empty for an all-defaults gateway) and derives all path-shaped state from
-// the shared base dir: <base>/sessions, <base>/schedules.json, <base>/memory. This
+// the shared base dir: <base>/sessions, <base>/schedules.json,
+// <base>/schedule_runs.sqlite, <base>/memory. This
 // keeps the agent's Schedule* tools and the scheduler pointed at the same file,
 // and the [SESSION LOG] path the gateway injects at the same directory the agent
 // server persists to.
@@ -70,6 +77,7 @@ func (cfg *GatewayConfig) applyBaseDir(baseDir string) {
 	cfg.BaseDir = baseDir
 	cfg.SessionsDir = filepath.Join(baseDir, "sessions")
 	cfg.SchedulesFile = filepath.Join(baseDir, "schedules.json")
+	cfg.ScheduleLedgerFile = filepath.Join(baseDir, "schedule_runs.sqlite")
 	cfg.Memory.BaseDir = filepath.Join(baseDir, "memory")
 	if cfg.Memory.MaxNotes <= 0 {
 		cfg.Memory.MaxNotes = 30
@@ -80,8 +88,9 @@ func (cfg *GatewayConfig) applyBaseDir(baseDir string) {
 // which selects the embedded in-process agent server.
 func DefaultGatewayConfig() *GatewayConfig {
 	return &GatewayConfig{
-		SessionTimeout: "30m",
-		Memory:         MemoryConfig{MaxNotes: 30},
+		SessionTimeout:  "30m",
+		ApprovalTimeout: "5m",
+		Memory:          MemoryConfig{MaxNotes: 30},
 	}
 }
 
@@ -1,6 +1,7 @@
 package gateway
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
@@ -23,6 +24,7 @@ type DiscordAdapter struct {
 	allowGuilds map[string]bool
 	allowChans  map[string]bool
 	allowUsers  map[string]bool
+	approvals   *approvalRegistry
 }
 
 // NewDiscordAdapter creates a Discord adapter.
@@ -42,10 +44,12 @@ func NewDiscordAdapter(bus *MessageBus, cfg DiscordConfig, logger *pkgLogger.Log
 		allowGuilds: toSet(cfg.AllowedGuildIDs),
 		allowChans:  toSet(cfg.AllowedChannelIDs),
 		allowUsers:  toSet(cfg.AllowedUserIDs),
+		approvals:   newApprovalRegistry(),
 	}
 
 	dg.AddHandler(a.handleMessage)
 	dg.AddHandler(a.handleReady)
+	dg.AddHandler(a.handleInteraction)
 
 	return a, nil
 }
@@ -155,26 +159,87 @@ func (a *DiscordAdapter) Stop() error {
 	return a.session.Close()
 }
 
+// Discord's limits on the files of one message.
+const (
+	discordMaxFiles     = 10
+	discordMaxFileBytes = 10 << 20
+)
+
 // Send sends a message to a Discord channel, splitting if over 2000 chars.
-// The first chunk is sent as a reply to the original message (if ReplyToID is set);
-// subsequent chunks are sent as plain follow-up messages.
+// Files follow the text in messages of their own, so a rejected upload never
+// costs the reply; the files that could not be attached are named in a last
+// message instead.
 func (a *DiscordAdapter) Send(ctx context.Context, msg OutboundMessage) error {
-	chunks := splitMessage(msg.Text, 2000)
-	for i, chunk := range chunks {
-		var err error
-		if i == 0 && msg.ReplyToID != "" {
-			ref := &discordgo.MessageReference{MessageID: msg.ReplyToID, ChannelID: msg.ChannelID}
-			_, err = a.session.ChannelMessageSendReply(msg.ChannelID, chunk, ref)
-		} else {
-			_, err = a.session.ChannelMessageSend(msg.ChannelID, chunk)
+	reference := func() *discordgo.MessageReference {
+		if msg.ReplyToID == "" {
+			return nil
 		}
-		if err != nil {
+		return &discordgo.MessageReference{MessageID: msg.ReplyToID, ChannelID: msg.ChannelID}
+	}
+	replied := false
+	if msg.Text != "" {
+		for i, chunk := range splitMessage(msg.Text, 2000) {
+			send := &discordgo.MessageSend{Content: chunk}
+			if i == 0 {
+				send.Reference = reference()
+			}
+			if _, err := a.session.ChannelMessageSendComplex(msg.ChannelID, send); err != nil {
+				return fmt.Errorf("failed to send discord message: %w", err)
+			}
+		}
+		replied = true
+	}
+
+	batches, failed := discordFileBatches(msg.Files)
+	for _, batch := range batches {
+		send := &discordgo.MessageSend{}
+		if !replied {
+			send.Reference = reference()
+		}
+		for _, f := range batch {
+			send.Files = append(send.Files, &discordgo.File{Name: f.Name, ContentType: f.ContentType, Reader: bytes.NewReader(f.Data)})
+		}
+		if _, err := a.session.ChannelMessageSendComplex(msg.ChannelID, send); err != nil {
+			a.logger.Warn("Failed to upload discord attachments", "channel", msg.ChannelID, "error", err)
+			for _, f := range batch {
+				failed = append(failed, f.Name+" (upload failed)")
+			}
+			continue
+		}
+		replied = true
+	}
+	if len(failed) > 0 {
+		note := "Could not attach: " + strings.Join(failed, ", ") + "."
+		if _, err := a.session.ChannelMessageSend(msg.ChannelID, note); err != nil {
 			return fmt.Errorf("failed to send discord message: %w", err)
 		}
 	}
 	return nil
 }
 
+// discordFileBatches packs files, in order, into messages within Discord's
+// limits. failed names the files too large to send at all.
+func discordFileBatches(files []OutboundFile) (batches [][]OutboundFile, failed []string) {
+	var batch []OutboundFile
+	size := 0
+	for _, f := range files {
+		if len(f.Data) > discordMaxFileBytes {
+			failed = append(failed, fmt.Sprintf("%s (over %d MiB)", f.Name, discordMaxFileBytes>>20))
+			continue
+		}
+		if len(batch) == discordMaxFiles || size+len(f.Data) > discordMaxFileBytes {
+			batches = append(batches, batch)
+			batch, size = nil, 0
+		}
+		batch = append(batch, f)
+		size += len(f.Data)
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	return batches, failed
+}
+
 // SendTyping shows a typing indicator.
 func (a *DiscordAdapter) SendTyping(ctx context.Context, channelID string) error {
 	return a.session.ChannelTyping(channelID)
@@ -0,0 +1,228 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	agentv1 "github.com/fpt/klein-cli/internal/gen/agentv1"
+)
+
+// approvalCustomIDPrefix marks the buttons of a tool-approval prompt. A button's
+// custom ID is "<prefix>:<prompt id>:<decision>", so the interaction handler
+// can route a click without any state on the message itself.
+const approvalCustomIDPrefix = "klein-approval"
+
+var (
+	errApprovalGone          = errors.New("this request was already answered or has expired")
+	errApprovalNotAuthorized = errors.New("you are not allowed to answer this request")
+)
+
+type approvalAnswer struct {
+	decision  agentv1.ApprovalDecision
+	responder string
+}
+
+type pendingApproval struct {
+	approvers map[string]bool
+	answer    chan approvalAnswer
+}
+
+// approvalRegistry tracks the approval prompts currently waiting for a click.
+type approvalRegistry struct {
+	mu      sync.Mutex
+	nextID  int
+	pending map[string]*pendingApproval
+}
+
+func newApprovalRegistry() *approvalRegistry {
+	return &approvalRegistry{pending: make(map[string]*pendingApproval)}
+}
+
+// add registers a prompt that only approvers may answer.
+func (r *approvalRegistry) add(approvers map[string]bool) (string, <-chan approvalAnswer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	id := strconv.Itoa(r.nextID)
+	p := &pendingApproval{approvers: approvers, answer: make(chan approvalAnswer, 1)}
+	r.pending[id] = p
+	return id, p.answer
+}
+
+func (r *approvalRegistry) remove(id string) {
+	r.mu.Lock()
+	delete(r.pending, id)
+	r.mu.Unlock()
+}
+
+// answer records userID's decision. The first authorized answer wins; clicks
+// by anyone else leave the prompt open.
+func (r *approvalRegistry) answer(id, userID, userName string, d agentv1.ApprovalDecision) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.pending[id]
+	if !ok {
+		return errApprovalGone
+	}
+	if !p.approvers[userID] {
+		return errApprovalNotAuthorized
+	}
+	delete(r.pending, id)
+	p.answer <- approvalAnswer{decision: d, responder: userName}
+	return nil
+}
+
+func approvalCustomID(id string, d agentv1.ApprovalDecision) string {
+	return fmt.Sprintf("%s:%s:%d", approvalCustomIDPrefix, id, d)
+}
+
+// parseApprovalCustomID is the inverse of approvalCustomID.
+func parseApprovalCustomID(customID string) (string, agentv1.ApprovalDecision, bool) {
+	parts := strings.Split(customID, ":")
+	if len(parts) != 3 || parts[0] != approvalCustomIDPrefix || parts[1] == "" {
+		return "", 0, false
+	}
+	n, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return "", 0, false
+	}
+	if _, known := agentv1.ApprovalDecision_name[int32(n)]; !known {
+		return "", 0, false
+	}
+	return parts[1], agentv1.ApprovalDecision(n), true
+}
+
+func approvalButtons(id string) []discordgo.MessageComponent {
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+			discordgo.Button{Label: "Allow once", Style: discordgo.SuccessButton,
+				CustomID: approvalCustomID(id, agentv1.ApprovalDecision_APPROVAL_ALLOW_ONCE)},
+			discordgo.Button{Label: "Allow for session", Style: discordgo.PrimaryButton,
+				CustomID: approvalCustomID(id, agentv1.ApprovalDecision_APPROVAL_ALLOW_SESSION)},
+			discordgo.Button{Label: "Deny", Style: discordgo.DangerButton,
+				CustomID: approvalCustomID(id, agentv1.ApprovalDecision_APPROVAL_DENY)},
+		}},
+	}
+}
+
+// approvalText renders the prompt body.
+func approvalText(p ApprovalPrompt, timeout time.Duration) string {
+	return fmt.Sprintf("🔐 **Approval needed** (`%s`)\n%s\nNo answer within %s denies the call.",
+		p.ToolName, p.Description, timeout.Round(time.Second))
+}
+
+// verdictLine is appended to the prompt once it is settled.
+func verdictLine(d agentv1.ApprovalDecision, who string) string {
+	switch d {
+	case agentv1.ApprovalDecision_APPROVAL_ALLOW_ONCE:
+		return "✅ Allowed once by " + who
+	case agentv1.ApprovalDecision_APPROVAL_ALLOW_SESSION:
+		return "✅ Allowed for this session by " + who
+	default:
+		return "🚫 Denied by " + who
+	}
+}
+
+// approversFor returns who may answer a prompt raised by requesterID's turn:
+// the allowed_user_ids list when set, otherwise only the requester. A
+// scheduled run with no allowlist has nobody to ask.
+func (a *DiscordAdapter) approversFor(requesterID string) map[string]bool {
+	if len(a.allowUsers) > 0 {
+		return a.allowUsers
+	}
+	if requesterID == "" || strings.HasPrefix(requesterID, "scheduler:") {
+		return nil
+	}
+	return map[string]bool{requesterID: true}
+}
+
+// RequestApproval implements ApprovalAdapter with a message carrying
+// Allow once / Allow for session / Deny buttons.
+func (a *DiscordAdapter) RequestApproval(ctx context.Context, p ApprovalPrompt) (agentv1.ApprovalDecision, string) {
+	deny := agentv1.ApprovalDecision_APPROVAL_DENY
+	approvers := a.approversFor(p.RequesterID)
+	if len(approvers) == 0 {
+		a.logger.Warn("No Discord user may approve this tool call; denying", "tool", p.ToolName, "requester", p.RequesterID)
+		return deny, ""
+	}
+
+	timeout := 5 * time.Minute
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+	id, answer := a.approvals.add(approvers)
+	defer a.approvals.remove(id)
+
+	content := approvalText(p, timeout)
+	send := &discordgo.MessageSend{Content: content, Components: approvalButtons(id)}
+	if p.ReplyToID != "" {
+		send.Reference = &discordgo.MessageReference{MessageID: p.ReplyToID, ChannelID: p.ChannelID}
+	}
+	posted, err := a.session.ChannelMessageSendComplex(p.ChannelID, send)
+	if err != nil {
+		a.logger.Error("Failed to post approval prompt; denying", "tool", p.ToolName, "error", err)
+		return deny, ""
+	}
+
+	select {
+	case ans := <-answer:
+		// The click handler already rewrote the message.
+		return ans.decision, ans.responder
+	case <-ctx.Done():
+		edit := discordgo.NewMessageEdit(p.ChannelID, posted.ID).
+			SetContent(content + "\n⏱ No answer in time — denied.")
+		edit.Components = &[]discordgo.MessageComponent{}
+		if _, err := a.session.ChannelMessageEditComplex(edit); err != nil {
+			a.logger.Warn("Failed to close expired approval prompt", "error", err)
+		}
+		return deny, ""
+	}
+}
+
+// handleInteraction routes approval button clicks. Unauthorized or stale
+// clicks get an ephemeral note and leave the prompt as it is.
+func (a *DiscordAdapter) handleInteraction(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if i.Type != discordgo.InteractionMessageComponent {
+		return
+	}
+	id, decision, ok := parseApprovalCustomID(i.MessageComponentData().CustomID)
+	if !ok {
+		return
+	}
+	user := i.User
+	if i.Member != nil && i.Member.User != nil {
+		user = i.Member.User
+	}
+	if user == nil {
+		return
+	}
+
+	if err := a.approvals.answer(id, user.ID, user.Username, decision); err != nil {
+		_ = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{Content: err.Error(), Flags: discordgo.MessageFlagsEphemeral},
+		})
+		return
+	}
+
+	var content string
+	if i.Message != nil {
+		content = i.Message.Content
+	}
+	if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content:    strings.TrimSpace(content + "\n" + verdictLine(decision, user.Username)),
+			Components: []discordgo.MessageComponent{},
+		},
+	}); err != nil {
+		a.logger.Warn("Failed to update approval prompt", "error", err)
+	}
+}
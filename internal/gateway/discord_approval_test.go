@@ -0,0 +1,61 @@
+package gateway
+
+import (
+	"testing"
+
+	agentv1 "github.com/fpt/klein-cli/internal/gen/agentv1"
+)
+
+func TestApprovalCustomIDRoundTrip(t *testing.T) {
+	for _, d := range []agentv1.ApprovalDecision{
+		agentv1.ApprovalDecision_APPROVAL_DENY,
+		agentv1.ApprovalDecision_APPROVAL_ALLOW_ONCE,
+		agentv1.ApprovalDecision_APPROVAL_ALLOW_SESSION,
+	} {
+		id, got, ok := parseApprovalCustomID(approvalCustomID("7", d))
+		if !ok || id != "7" || got != d {
+			t.Errorf("%v: got (%q, %v, %v)", d, id, got, ok)
+		}
+	}
+	for _, bad := range []string{"", "other:1:2", "klein-approval::2", "klein-approval:1:x", "klein-approval:1:99"} {
+		if _, _, ok := parseApprovalCustomID(bad); ok {
+			t.Errorf("%q should not parse", bad)
+		}
+	}
+}
+
+func TestApprovalRegistry_OnlyApproversAnswerOnce(t *testing.T) {
+	r := newApprovalRegistry()
+	id, answer := r.add(map[string]bool{"alice": true})
+
+	if err := r.answer(id, "mallory", "mallory", agentv1.ApprovalDecision_APPROVAL_ALLOW_ONCE); err != errApprovalNotAuthorized {
+		t.Errorf("outsider: %v", err)
+	}
+	if err := r.answer(id, "alice", "Alice", agentv1.ApprovalDecision_APPROVAL_ALLOW_SESSION); err != nil {
+		t.Fatalf("approver: %v", err)
+	}
+	if got := <-answer; got.decision != agentv1.ApprovalDecision_APPROVAL_ALLOW_SESSION || got.responder != "Alice" {
+		t.Errorf("answer = %+v", got)
+	}
+	if err := r.answer(id, "alice", "Alice", agentv1.ApprovalDecision_APPROVAL_DENY); err != errApprovalGone {
+		t.Errorf("second click: %v", err)
+	}
+}
+
+func TestDiscordApproversFor(t *testing.T) {
+	open := &DiscordAdapter{allowUsers: toSet(nil)}
+	if got := open.approversFor("u1"); len(got) != 1 || !got["u1"] {
+		t.Errorf("no allowlist: only the requester may answer, got %v", got)
+	}
+	if got := open.approversFor("scheduler:brief"); len(got) != 0 {
+		t.Errorf("no allowlist, scheduled run: nobody to ask, got %v", got)
+	}
+
+	restricted := &DiscordAdapter{allowUsers: toSet([]string{"admin"})}
+	if got := restricted.approversFor("u1"); len(got) != 1 || !got["admin"] {
+		t.Errorf("allowlist: got %v", got)
+	}
+	if got := restricted.approversFor("scheduler:brief"); !got["admin"] {
+		t.Errorf("allowlist applies to scheduled runs too, got %v", got)
+	}
+}
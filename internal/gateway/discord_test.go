@@ -0,0 +1,85 @@
+package gateway
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+
+	pkgLogger "github.com/fpt/klein-cli/pkg/logger"
+)
+
+func TestDiscordFileBatches(t *testing.T) {
+	file := func(name string, size int) OutboundFile { return OutboundFile{Name: name, Data: make([]byte, size)} }
+	var files []OutboundFile
+	for range 12 {
+		files = append(files, file("chart.png", 1024))
+	}
+	files = append(files,
+		file("big.pdf", discordMaxFileBytes-512), // no room left in the second message
+		file("huge.pdf", discordMaxFileBytes+1),
+	)
+	batches, failed := discordFileBatches(files)
+	var sizes []int
+	for _, b := range batches {
+		sizes = append(sizes, len(b))
+	}
+	if len(sizes) != 3 || sizes[0] != 10 || sizes[1] != 2 || sizes[2] != 1 {
+		t.Errorf("batch sizes = %v, want [10 2 1]", sizes)
+	}
+	if len(failed) != 1 || !strings.HasPrefix(failed[0], "huge.pdf") {
+		t.Errorf("failed = %q", failed)
+	}
+}
+
+// discordRecorder answers Discord API calls, failing every upload.
+type discordRecorder struct {
+	posts []string // "text:<content>" or "files"
+}
+
+func (r *discordRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, _ := io.ReadAll(req.Body)
+	status, reply := http.StatusOK, `{"id":"1"}`
+	if strings.HasPrefix(req.Header.Get("Content-Type"), "multipart/") {
+		r.posts = append(r.posts, "files")
+		status, reply = http.StatusRequestEntityTooLarge, `{"message":"Request entity too large","code":40005}`
+	} else {
+		var content string
+		if i := strings.Index(string(body), `"content":"`); i >= 0 {
+			content = string(body)[i+len(`"content":"`):]
+			content = content[:strings.Index(content, `"`)]
+		}
+		r.posts = append(r.posts, "text:"+content)
+	}
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(reply)),
+		Request:    req,
+	}, nil
+}
+
+func TestDiscordSendDeliversTextWhenUploadFails(t *testing.T) {
+	session, err := discordgo.New("Bot test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := &discordRecorder{}
+	session.Client = &http.Client{Transport: rec}
+	a := &DiscordAdapter{session: session, logger: pkgLogger.NewLogger(pkgLogger.LogLevelError)}
+
+	err = a.Send(t.Context(), OutboundMessage{
+		ChannelID: "c1",
+		Text:      "Here is the report.",
+		Files:     []OutboundFile{{Name: "report.pdf", ContentType: "application/pdf", Data: []byte("%PDF")}},
+	})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	want := []string{"text:Here is the report.", "files", "text:Could not attach: report.pdf (upload failed)."}
+	if strings.Join(rec.posts, "|") != strings.Join(want, "|") {
+		t.Errorf("posts = %q, want %q", rec.posts, want)
+	}
+}
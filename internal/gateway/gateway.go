@@ -2,6 +2,7 @@ package gateway
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
@@ -13,6 +14,7 @@ import (
 	"connectrpc.com/connect"
 	agentv1 "github.com/fpt/klein-cli/internal/gen/agentv1"
 	"github.com/fpt/klein-cli/internal/gen/agentv1/agentv1connect"
+	"github.com/fpt/klein-cli/internal/tool/memorydb"
 	pkgLogger "github.com/fpt/klein-cli/pkg/logger"
 )
 
@@ -22,7 +24,9 @@ type Gateway struct {
 	bus       *MessageBus
 	sessions  *SessionManager
 	memory    *MemoryManager
+	memories  *memorydb.Store // nil when the long-term memory store could not be opened
 	scheduler *Scheduler
+	ledger    *RunLedger // nil when the ledger could not be opened
 	adapters  map[string]Adapter
 	client    agentv1connect.AgentServiceClient
 	logger    *pkgLogger.Logger
@@ -39,12 +43,19 @@ func NewGateway(cfg *GatewayConfig, logger *pkgLogger.Logger) (*Gateway, error)
 	if err := memory.EnsureDirectories(); err != nil {
 		logger.Warn("Failed to create memory directories", "error", err)
 	}
+	memories, err := memorydb.Open(memory.StorePath())
+	if err != nil {
+		logger.Warn("Long-term memory store unavailable; !memory disabled", "path", memory.StorePath(), "error", err)
+	} else {
+		importLegacyMemory(memories, cfg.Memory.BaseDir, logger)
+	}
 
 	gw := &Gateway{
 		config:   cfg,
 		bus:      bus,
 		sessions: sessions,
 		memory:   memory,
+		memories: memories,
 		adapters: make(map[string]Adapter),
 		client:   client,
 		logger:   logger.WithComponent("gateway"),
@@ -54,6 +65,7 @@ func NewGateway(cfg *GatewayConfig, logger *pkgLogger.Logger) (*Gateway, error)
 	if cfg.Discord.Token != "" {
 		discord, err := NewDiscordAdapter(bus, cfg.Discord, logger)
 		if err != nil {
+			_ = gw.Close()
 			return nil, fmt.Errorf("failed to create discord adapter: %w", err)
 		}
 		gw.adapters["discord"] = discord
@@ -65,6 +77,14 @@ func NewGateway(cfg *GatewayConfig, logger *pkgLogger.Logger) (*Gateway, error)
 	if cfg.SchedulesFile != "" {
 		gw.scheduler.SetStorePath(cfg.SchedulesFile)
 	}
+	if cfg.ScheduleLedgerFile != "" {
+		if ledger, err := OpenRunLedger(cfg.ScheduleLedgerFile); err != nil {
+			logger.Warn("Schedule run ledger disabled", "path", cfg.ScheduleLedgerFile, "error", err)
+		} else {
+			gw.ledger = ledger
+			gw.scheduler.SetLedger(ledger)
+		}
+	}
 
 	return gw, nil
 }
@@ -98,11 +118,28 @@ func (gw *Gateway) Run(ctx context.Context) error {
 	}
 }
 
+// handleInbound runs one inbound message and, for scheduled runs, reports the
+// outcome on msg.Result.
 func (gw *Gateway) handleInbound(ctx context.Context, msg InboundMessage) {
+	if msg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, msg.Timeout)
+		defer cancel()
+	}
+	res := gw.processInbound(ctx, msg)
+	if res.Status != RunSucceeded && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		res = RunResult{Status: RunTimedOut, Error: fmt.Sprintf("run exceeded its %s timeout", msg.Timeout)}
+	}
+	if msg.Result != nil {
+		msg.Result <- res
+	}
+}
+
+func (gw *Gateway) processInbound(ctx context.Context, msg InboundMessage) RunResult {
 	// Handle commands
 	if strings.HasPrefix(msg.Text, "!") {
 		gw.handleCommand(ctx, msg)
-		return
+		return RunResult{Status: RunSucceeded}
 	}
 
 	// "/<skill> args" runs that skill for this one message (a one-shot slash
@@ -118,7 +155,7 @@ func (gw *Gateway) handleInbound(ctx context.Context, msg InboundMessage) {
 			Text:        gw.skillListText(ctx),
 			ReplyToID:   msg.ReplyToID,
 		}
-		return
+		return RunResult{Status: RunSucceeded}
 	}
 
 	key := SessionKey{
@@ -130,7 +167,7 @@ func (gw *Gateway) handleInbound(ctx context.Context, msg InboundMessage) {
 	session, err := gw.sessions.GetOrCreateSession(ctx, key)
 	if err != nil {
 		gw.logger.Error("Failed to get session", "error", err, "peer", msg.PeerName)
-		return
+		return RunResult{Status: RunFailed, Error: fmt.Sprintf("start agent session: %v", err)}
 	}
 
 	// Serialize invocations for this peer: the agent session/state is not safe
@@ -152,11 +189,6 @@ func (gw *Gateway) handleInbound(ctx context.Context, msg InboundMessage) {
 		}
 	}
 
-	// Inject memory context
-	if memoryPrompt := gw.memory.BuildMemoryPrompt(); memoryPrompt != "" {
-		enrichedText = memoryPrompt + enrichedText
-	}
-
 	// Context injection differs by origin:
 	//   - Scheduler-originated runs get a [SCHEDULED RUN] preamble telling the
 	//     model this is an automated job (no user present) so it executes the
@@ -193,17 +225,24 @@ func (gw *Gateway) handleInbound(ctx context.Context, msg InboundMessage) {
 	if err != nil {
 		gw.logger.Error("Failed to invoke agent", "error", err)
 		gw.sendError(msg, "Sorry, I encountered an error connecting to the agent.")
-		return
+		return RunResult{Status: RunFailed, Error: fmt.Sprintf("invoke agent: %v", err)}
 	}
 	defer stream.Close()
 
 	// Consume stream, extract final response
 	var responseText string
+	var files []OutboundFile
+	result := RunResult{Status: RunSucceeded}
 	for stream.Receive() {
 		event := stream.Msg()
 		switch e := event.Event.(type) {
 		case *agentv1.InvokeEvent_Final:
 			responseText = e.Final.Text
+			for _, a := range e.Final.GetAttachments() {
+				files = append(files, OutboundFile{Name: a.Name, ContentType: a.ContentType, Data: a.Data})
+			}
+			result.InputTokens = int(e.Final.GetUsage().GetInputTokens())
+			result.OutputTokens = int(e.Final.GetUsage().GetOutputTokens())
 		case *agentv1.InvokeEvent_Status:
 			// Refresh typing indicator on tool calls
 			if e.Status.State == agentv1.InvokeState_RUN_TOOL {
@@ -211,6 +250,10 @@ func (gw *Gateway) handleInbound(ctx context.Context, msg InboundMessage) {
 					_ = a.SendTyping(ctx, msg.ChannelID)
 				}
 			}
+		case *agentv1.InvokeEvent_ApprovalRequest:
+			// The agent is paused on this call; answer off the receive loop
+			// so the stream keeps draining while a human decides.
+			go gw.handleApproval(ctx, msg, session.AgentSessionID, e.ApprovalRequest)
 		case *agentv1.InvokeEvent_Error:
 			// A slash command for a non-existent skill fails before any model
 			// call ("skill not found"); fall back to the skill list instead of a
@@ -219,13 +262,18 @@ func (gw *Gateway) handleInbound(ctx context.Context, msg InboundMessage) {
 				responseText = fmt.Sprintf("Unknown command `/%s`.\n\n%s", cmdSkill, gw.skillListText(ctx))
 			} else {
 				responseText = fmt.Sprintf("Error: %s", e.Error)
+				result = RunResult{Status: RunFailed, Error: e.Error}
 			}
 		}
 	}
 	if err := stream.Err(); err != nil {
 		gw.logger.Error("Stream error", "error", err)
 		gw.sendError(msg, "Sorry, I encountered an error processing your request.")
-		return
+		return RunResult{Status: RunFailed, Error: fmt.Sprintf("agent stream: %v", err)}
+	}
+	if result.Status != RunSucceeded && msg.QuietFailure {
+		gw.logger.Warn("Scheduled run failed; a retry follows", "peer", msg.PeerName, "error", result.Error)
+		return result
 	}
 
 	if responseText != "" {
@@ -255,9 +303,54 @@ func (gw *Gateway) handleInbound(ctx context.Context, msg InboundMessage) {
 				ChannelID:   msg.ChannelID,
 				Text:        responseText,
 				ReplyToID:   msg.ReplyToID,
+				Files:       files,
 			}
 		}
 	}
+	return result
+}
+
+// handleApproval asks the originating channel to approve a paused tool call
+// and reports the answer to the agent. Silent runs and channels whose adapter
+// cannot ask are denied outright: there is nobody to put the call in front of.
+// The agent applies the same timeout on its side, so a late answer is moot.
+func (gw *Gateway) handleApproval(ctx context.Context, msg InboundMessage, agentSessionID string, req *agentv1.ApprovalRequest) {
+	decision, responder := agentv1.ApprovalDecision_APPROVAL_DENY, ""
+	approver, ok := gw.adapters[msg.ChannelType].(ApprovalAdapter)
+	if ok && msg.ChannelID != "" && !msg.Silent {
+		askCtx, cancel := context.WithTimeout(ctx, time.Duration(req.TimeoutSeconds)*time.Second)
+		decision, responder = approver.RequestApproval(askCtx, ApprovalPrompt{
+			ChannelType: msg.ChannelType,
+			ChannelID:   msg.ChannelID,
+			ReplyToID:   msg.ReplyToID,
+			RequesterID: msg.PeerID,
+			ToolName:    req.ToolName,
+			Description: req.Description,
+		})
+		cancel()
+	} else {
+		gw.logger.Warn("Tool call needs approval but this run cannot ask anyone; denying",
+			"tool", req.ToolName, "peer", msg.PeerName, "silent", msg.Silent)
+	}
+	gw.logger.Info("Tool approval", "tool", req.ToolName, "decision", decision.String(), "responder", responder)
+
+	resp, err := gw.client.SubmitClientEvent(ctx, connect.NewRequest(&agentv1.ClientEvent{
+		SessionId: agentSessionID,
+		Event: &agentv1.ClientEvent_ApprovalResponse{
+			ApprovalResponse: &agentv1.ApprovalResponse{
+				RequestId: req.RequestId,
+				Decision:  decision,
+				Responder: responder,
+			},
+		},
+	}))
+	if err != nil {
+		gw.logger.Error("Failed to submit approval", "error", err)
+		return
+	}
+	if resp.Msg.Status != "OK" {
+		gw.logger.Debug("Approval not applied", "request_id", req.RequestId, "error", resp.Msg.Error)
+	}
 }
 
 func (gw *Gateway) handleCommand(ctx context.Context, msg InboundMessage) {
@@ -290,20 +383,15 @@ func (gw *Gateway) handleCommand(ctx context.Context, msg InboundMessage) {
 			response = "Usage: !skill <name>\nAvailable: code, respond, claw"
 		}
 	case "memory":
-		mem, _ := gw.memory.GetMemoryContext()
-		if mem == "" {
-			response = "No memory stored yet."
-		} else {
-			if len(mem) > 1800 {
-				mem = mem[:1800] + "\n...(truncated)"
-			}
-			response = fmt.Sprintf("**Current Memory:**\n\n%s", mem)
-		}
+		response = gw.memoryCommand(ctx, strings.Join(parts[1:], " "))
+	case "compact":
+		response = gw.compactCommand(ctx, key, strings.Join(parts[1:], " "))
 	case "help":
 		response = "**Available commands:**\n" +
 			"`!clear` — Clear conversation\n" +
 			"`!skill <name>` — Switch the session's default skill\n" +
-			"`!memory` — Show stored memory\n" +
+			"`!memory [query]` — Show recent memories, or search them\n" +
+			"`!compact [focus]` — Summarise the conversation so far to free context\n" +
 			"`!help` — Show this help\n" +
 			"`/list` — List available skills\n" +
 			"`/<skill> [args]` — Run a skill once for this message (e.g. `/research-stock 7203`)"
@@ -319,6 +407,19 @@ func (gw *Gateway) handleCommand(ctx context.Context, msg InboundMessage) {
 	}
 }
 
+// compactCommand renders the reply to "!compact [focus]".
+func (gw *Gateway) compactCommand(ctx context.Context, key SessionKey, focus string) string {
+	resp, err := gw.sessions.CompactSession(ctx, key, focus)
+	if err != nil {
+		gw.logger.Error("Compaction failed", "error", err)
+		return fmt.Sprintf("Compaction failed: %v", err)
+	}
+	if !resp.Compacted {
+		return "Nothing to compact."
+	}
+	return fmt.Sprintf("Compacted the conversation (%s): %d → %d tokens.", resp.Strategy, resp.BeforeTokens, resp.AfterTokens)
+}
+
 func (gw *Gateway) dispatchOutbound(ctx context.Context) {
 	for {
 		select {
@@ -335,6 +436,9 @@ func (gw *Gateway) dispatchOutbound(ctx context.Context) {
 }
 
 func (gw *Gateway) sendError(orig InboundMessage, text string) {
+	if orig.QuietFailure {
+		return
+	}
 	gw.bus.Outbound <- OutboundMessage{
 		ChannelType: orig.ChannelType,
 		ChannelID:   orig.ChannelID,
@@ -423,5 +527,11 @@ func (gw *Gateway) Close() error {
 	for _, a := range gw.adapters {
 		_ = a.Stop()
 	}
+	if gw.ledger != nil {
+		_ = gw.ledger.Close()
+	}
+	if gw.memories != nil {
+		_ = gw.memories.Close()
+	}
 	return nil
 }
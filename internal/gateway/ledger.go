@@ -0,0 +1,393 @@
+package gateway
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	_ "modernc.org/sqlite" // pure-Go sqlite driver (registers "sqlite")
+)
+
+// ledgerSchemaVersion is tracked via PRAGMA user_version, as in memorydb.
+const ledgerSchemaVersion = 1
+
+// RunStatus is the outcome of one scheduled run attempt.
+type RunStatus string
+
+const (
+	RunRunning   RunStatus = "running"
+	RunSucceeded RunStatus = "succeeded"
+	RunFailed    RunStatus = "failed"
+	RunTimedOut  RunStatus = "timed_out"
+	RunSkipped   RunStatus = "skipped"   // not started: paused or still running
+	RunAbandoned RunStatus = "abandoned" // the gateway stopped before it finished
+)
+
+// Run triggers: why an attempt started.
+const (
+	TriggerCron    = "cron"
+	TriggerStart   = "start" // run_at_start
+	TriggerCatchUp = "catch_up"
+	TriggerManual  = "manual" // klein claw schedules run
+)
+
+// RunResult is what the gateway reports back for a scheduled message.
+type RunResult struct {
+	Status       RunStatus
+	Error        string
+	InputTokens  int
+	OutputTokens int
+}
+
+// RunRecord is one row of the run ledger.
+type RunRecord struct {
+	ScheduledFor time.Time
+	StartedAt    time.Time
+	EndedAt      time.Time // zero while running
+	Schedule     string
+	Trigger      string
+	Status       RunStatus
+	Error        string
+	ID           int64
+	Attempt      int
+	InputTokens  int
+	OutputTokens int
+}
+
+// Duration is how long the run took (zero while it is running).
+func (r RunRecord) Duration() time.Duration {
+	if r.EndedAt.IsZero() {
+		return 0
+	}
+	return r.EndedAt.Sub(r.StartedAt)
+}
+
+// RunLedger is the scheduler's SQLite store under base_dir: one row per run
+// attempt, per-schedule state (paused, last cron fire for catch-up), and the
+// queue `klein claw schedules run` writes for the gateway to pick up. Both
+// the gateway and the CLI open it; SQLite's locking arbitrates.
+type RunLedger struct {
+	db    *sql.DB
+	clock func() time.Time
+}
+
+// OpenRunLedger opens (creating if needed) the ledger at path and migrates it.
+func OpenRunLedger(path string) (*RunLedger, error) {
+	if path != ":memory:" {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, fmt.Errorf("create ledger dir: %w", err)
+		}
+	}
+	db, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, fmt.Errorf("open run ledger: %w", err)
+	}
+	db.SetMaxOpenConns(1)
+	l := &RunLedger{db: db, clock: time.Now}
+	if err := l.migrate(context.Background()); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// Close releases the database.
+func (l *RunLedger) Close() error { return l.db.Close() }
+
+func (l *RunLedger) migrate(ctx context.Context) error {
+	var current int
+	if err := l.db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&current); err != nil {
+		return fmt.Errorf("read ledger version: %w", err)
+	}
+	if current >= ledgerSchemaVersion {
+		return nil
+	}
+	tx, err := l.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("migrate run ledger: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck // no-op after a successful Commit
+	if current < 1 {
+		if _, err := tx.ExecContext(ctx, ledgerMigrationV1); err != nil {
+			return fmt.Errorf("migrate run ledger to v1: %w", err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", ledgerSchemaVersion)); err != nil {
+		return fmt.Errorf("set ledger version: %w", err)
+	}
+	return tx.Commit()
+}
+
+// Times are unix seconds; 0 means unset.
+const ledgerMigrationV1 = `
+CREATE TABLE runs (
+	id            INTEGER PRIMARY KEY,
+	schedule      TEXT NOT NULL,
+	trigger       TEXT NOT NULL,
+	attempt       INTEGER NOT NULL DEFAULT 1,
+	scheduled_for INTEGER NOT NULL,
+	started_at    INTEGER NOT NULL,
+	ended_at      INTEGER NOT NULL DEFAULT 0,
+	status        TEXT NOT NULL,
+	input_tokens  INTEGER NOT NULL DEFAULT 0,
+	output_tokens INTEGER NOT NULL DEFAULT 0,
+	error         TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX runs_schedule ON runs(schedule, id);
+
+CREATE TABLE schedule_state (
+	name      TEXT PRIMARY KEY,
+	paused    INTEGER NOT NULL DEFAULT 0,
+	last_fire INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE run_requests (
+	id           INTEGER PRIMARY KEY,
+	schedule     TEXT NOT NULL,
+	requested_at INTEGER NOT NULL
+);
+
+CREATE TABLE meta (
+	key   TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);
+`
+
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func timeOrZero(sec int64) time.Time {
+	if sec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
+}
+
+// StartRun records an attempt as running and returns its id.
+func (l *RunLedger) StartRun(ctx context.Context, schedule, trigger string, scheduledFor time.Time, attempt int) (int64, error) {
+	res, err := l.db.ExecContext(ctx,
+		`INSERT INTO runs (schedule, trigger, attempt, scheduled_for, started_at, status) VALUES (?, ?, ?, ?, ?, ?)`,
+		schedule, trigger, attempt, unixOrZero(scheduledFor), l.clock().Unix(), RunRunning)
+	if err != nil {
+		return 0, fmt.Errorf("record run start: %w", err)
+	}
+	return res.LastInsertId()
+}
+
+// FinishRun records the outcome of a running attempt.
+func (l *RunLedger) FinishRun(ctx context.Context, id int64, res RunResult) error {
+	_, err := l.db.ExecContext(ctx,
+		`UPDATE runs SET ended_at = ?, status = ?, input_tokens = ?, output_tokens = ?, error = ? WHERE id = ?`,
+		l.clock().Unix(), res.Status, res.InputTokens, res.OutputTokens, res.Error, id)
+	if err != nil {
+		return fmt.Errorf("record run end: %w", err)
+	}
+	return nil
+}
+
+// RecordSkipped records a fire that did not start a run.
+func (l *RunLedger) RecordSkipped(ctx context.Context, schedule, trigger string, scheduledFor time.Time, reason string) error {
+	now := l.clock().Unix()
+	_, err := l.db.ExecContext(ctx,
+		`INSERT INTO runs (schedule, trigger, scheduled_for, started_at, ended_at, status, error) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		schedule, trigger, unixOrZero(scheduledFor), now, now, RunSkipped, reason)
+	if err != nil {
+		return fmt.Errorf("record skipped run: %w", err)
+	}
+	return nil
+}
+
+// AbandonRunning closes out runs left "running" by a gateway that stopped
+// mid-run. Called when the scheduler starts; it returns how many it closed.
+func (l *RunLedger) AbandonRunning(ctx context.Context) (int64, error) {
+	res, err := l.db.ExecContext(ctx,
+		`UPDATE runs SET status = ?, ended_at = ?, error = 'the gateway stopped before the run finished' WHERE status = ?`,
+		RunAbandoned, l.clock().Unix(), RunRunning)
+	if err != nil {
+		return 0, fmt.Errorf("close abandoned runs: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+const runColumns = `id, schedule, trigger, attempt, scheduled_for, started_at, ended_at, status, input_tokens, output_tokens, error`
+
+func scanRuns(rows *sql.Rows) ([]RunRecord, error) {
+	defer rows.Close()
+	var out []RunRecord
+	for rows.Next() {
+		var r RunRecord
+		var scheduled, started, ended int64
+		if err := rows.Scan(&r.ID, &r.Schedule, &r.Trigger, &r.Attempt, &scheduled, &started, &ended,
+			&r.Status, &r.InputTokens, &r.OutputTokens, &r.Error); err != nil {
+			return nil, fmt.Errorf("read run: %w", err)
+		}
+		r.ScheduledFor, r.StartedAt, r.EndedAt = timeOrZero(scheduled), timeOrZero(started), timeOrZero(ended)
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// History returns the most recent runs, newest first, of one schedule (or of
+// all schedules when schedule is empty).
+func (l *RunLedger) History(ctx context.Context, schedule string, limit int) ([]RunRecord, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	query := `SELECT ` + runColumns + ` FROM runs`
+	args := []any{}
+	if schedule != "" {
+		query += ` WHERE schedule = ?`
+		args = append(args, schedule)
+	}
+	query += ` ORDER BY id DESC LIMIT ?`
+	rows, err := l.db.QueryContext(ctx, query, append(args, limit)...)
+	if err != nil {
+		return nil, fmt.Errorf("read run history: %w", err)
+	}
+	return scanRuns(rows)
+}
+
+// LastRuns returns each schedule's most recent run that actually started.
+func (l *RunLedger) LastRuns(ctx context.Context) (map[string]RunRecord, error) {
+	rows, err := l.db.QueryContext(ctx, `SELECT `+runColumns+` FROM runs WHERE id IN (
+		SELECT MAX(id) FROM runs WHERE status != ? GROUP BY schedule)`, RunSkipped)
+	if err != nil {
+		return nil, fmt.Errorf("read last runs: %w", err)
+	}
+	runs, err := scanRuns(rows)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]RunRecord, len(runs))
+	for _, r := range runs {
+		out[r.Schedule] = r
+	}
+	return out, nil
+}
+
+// SetPaused pauses or resumes a schedule. A paused schedule keeps its place
+// but skips its fires until resumed; manual runs still go through.
+func (l *RunLedger) SetPaused(ctx context.Context, name string, paused bool) error {
+	_, err := l.db.ExecContext(ctx,
+		`INSERT INTO schedule_state (name, paused) VALUES (?, ?) ON CONFLICT(name) DO UPDATE SET paused = excluded.paused`,
+		name, paused)
+	if err != nil {
+		return fmt.Errorf("set paused: %w", err)
+	}
+	return nil
+}
+
+// Paused reports whether a schedule is paused.
+func (l *RunLedger) Paused(ctx context.Context, name string) (bool, error) {
+	var paused bool
+	err := l.db.QueryRowContext(ctx, `SELECT paused FROM schedule_state WHERE name = ?`, name).Scan(&paused)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("read paused: %w", err)
+	}
+	return paused, nil
+}
+
+// LastFire returns the last cron fire time the scheduler handled for a
+// schedule (run, skipped or paused alike); zero if it never fired.
+func (l *RunLedger) LastFire(ctx context.Context, name string) (time.Time, error) {
+	var sec int64
+	err := l.db.QueryRowContext(ctx, `SELECT last_fire FROM schedule_state WHERE name = ?`, name).Scan(&sec)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("read last fire: %w", err)
+	}
+	return timeOrZero(sec), nil
+}
+
+// SetLastFire records the cron fire time the scheduler just handled.
+func (l *RunLedger) SetLastFire(ctx context.Context, name string, t time.Time) error {
+	_, err := l.db.ExecContext(ctx,
+		`INSERT INTO schedule_state (name, last_fire) VALUES (?, ?) ON CONFLICT(name) DO UPDATE SET last_fire = excluded.last_fire`,
+		name, unixOrZero(t))
+	if err != nil {
+		return fmt.Errorf("set last fire: %w", err)
+	}
+	return nil
+}
+
+// RequestRun queues a manual run for the gateway's scheduler to pick up.
+func (l *RunLedger) RequestRun(ctx context.Context, name string) error {
+	_, err := l.db.ExecContext(ctx, `INSERT INTO run_requests (schedule, requested_at) VALUES (?, ?)`, name, l.clock().Unix())
+	if err != nil {
+		return fmt.Errorf("queue run: %w", err)
+	}
+	return nil
+}
+
+// TakeRunRequests removes and returns the queued manual runs, oldest first.
+func (l *RunLedger) TakeRunRequests(ctx context.Context) ([]string, error) {
+	tx, err := l.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("take run requests: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck // no-op after a successful Commit
+	rows, err := tx.QueryContext(ctx, `SELECT schedule FROM run_requests ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("take run requests: %w", err)
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("take run requests: %w", err)
+		}
+		names = append(names, name)
+	}
+	rows.Close()
+	if len(names) == 0 {
+		return nil, nil
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM run_requests`); err != nil {
+		return nil, fmt.Errorf("take run requests: %w", err)
+	}
+	return names, tx.Commit()
+}
+
+// Heartbeat records that a gateway's scheduler is alive, so the CLI can tell
+// whether a queued run will be picked up.
+func (l *RunLedger) Heartbeat(ctx context.Context) error {
+	_, err := l.db.ExecContext(ctx,
+		`INSERT INTO meta (key, value) VALUES ('heartbeat', ?) ON CONFLICT(key) DO UPDATE SET value = excluded.value`,
+		strconv.FormatInt(l.clock().Unix(), 10))
+	if err != nil {
+		return fmt.Errorf("write heartbeat: %w", err)
+	}
+	return nil
+}
+
+// LastHeartbeat returns the last scheduler heartbeat; zero if none.
+func (l *RunLedger) LastHeartbeat(ctx context.Context) (time.Time, error) {
+	var v string
+	err := l.db.QueryRowContext(ctx, `SELECT value FROM meta WHERE key = 'heartbeat'`).Scan(&v)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("read heartbeat: %w", err)
+	}
+	sec, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("read heartbeat: %w", err)
+	}
+	return timeOrZero(sec), nil
+}
@@ -0,0 +1,107 @@
+package gateway
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openTestLedger(t *testing.T) *RunLedger {
+	t.Helper()
+	l, err := OpenRunLedger(filepath.Join(t.TempDir(), "schedule_runs.sqlite"))
+	if err != nil {
+		t.Fatalf("OpenRunLedger: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l
+}
+
+func TestRunLedger_Runs(t *testing.T) {
+	l := openTestLedger(t)
+	ctx := context.Background()
+	at := time.Date(2026, 10, 18, 8, 0, 0, 0, time.UTC)
+
+	id, err := l.StartRun(ctx, "brief", TriggerCron, at, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.FinishRun(ctx, id, RunResult{Status: RunFailed, Error: "boom"}); err != nil {
+		t.Fatal(err)
+	}
+	id, _ = l.StartRun(ctx, "brief", TriggerCron, at, 2)
+	_ = l.FinishRun(ctx, id, RunResult{Status: RunSucceeded, InputTokens: 1200, OutputTokens: 300})
+	_ = l.RecordSkipped(ctx, "brief", TriggerCron, at.Add(time.Hour), "previous run still in progress")
+	_, _ = l.StartRun(ctx, "digest", TriggerManual, at, 1)
+
+	hist, err := l.History(ctx, "brief", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hist) != 3 || hist[0].Status != RunSkipped || hist[1].Status != RunSucceeded || hist[2].Error != "boom" {
+		t.Fatalf("history = %+v", hist)
+	}
+	if hist[1].Attempt != 2 || hist[1].InputTokens != 1200 || !hist[1].ScheduledFor.Equal(at) {
+		t.Errorf("run = %+v", hist[1])
+	}
+	if all, _ := l.History(ctx, "", 10); len(all) != 4 {
+		t.Errorf("all history: %d runs", len(all))
+	}
+
+	last, err := l.LastRuns(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last["brief"].Status != RunSucceeded {
+		t.Errorf("a skipped fire is not the last run: %+v", last["brief"])
+	}
+	if last["digest"].Status != RunRunning || last["digest"].Duration() != 0 {
+		t.Errorf("digest = %+v", last["digest"])
+	}
+
+	if n, err := l.AbandonRunning(ctx); err != nil || n != 1 {
+		t.Errorf("AbandonRunning = %d, %v", n, err)
+	}
+	if last, _ := l.LastRuns(ctx); last["digest"].Status != RunAbandoned {
+		t.Errorf("digest after restart = %+v", last["digest"])
+	}
+}
+
+func TestRunLedger_State(t *testing.T) {
+	l := openTestLedger(t)
+	ctx := context.Background()
+
+	if paused, err := l.Paused(ctx, "brief"); err != nil || paused {
+		t.Errorf("unknown schedule: paused=%v err=%v", paused, err)
+	}
+	fire := time.Date(2026, 10, 18, 8, 0, 0, 0, time.UTC)
+	_ = l.SetLastFire(ctx, "brief", fire)
+	_ = l.SetPaused(ctx, "brief", true)
+	if paused, _ := l.Paused(ctx, "brief"); !paused {
+		t.Error("expected paused")
+	}
+	if got, _ := l.LastFire(ctx, "brief"); !got.Equal(fire) {
+		t.Errorf("pausing must keep the last fire, got %v", got)
+	}
+	_ = l.SetPaused(ctx, "brief", false)
+	if paused, _ := l.Paused(ctx, "brief"); paused {
+		t.Error("expected resumed")
+	}
+
+	_ = l.RequestRun(ctx, "brief")
+	_ = l.RequestRun(ctx, "digest")
+	if got, err := l.TakeRunRequests(ctx); err != nil || len(got) != 2 || got[0] != "brief" {
+		t.Errorf("TakeRunRequests = %v, %v", got, err)
+	}
+	if got, _ := l.TakeRunRequests(ctx); len(got) != 0 {
+		t.Errorf("requests are taken once, got %v", got)
+	}
+
+	if hb, _ := l.LastHeartbeat(ctx); !hb.IsZero() {
+		t.Errorf("no heartbeat yet, got %v", hb)
+	}
+	_ = l.Heartbeat(ctx)
+	if hb, _ := l.LastHeartbeat(ctx); time.Since(hb) > time.Minute {
+		t.Errorf("heartbeat = %v", hb)
+	}
+}
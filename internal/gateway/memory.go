@@ -1,12 +1,15 @@
 package gateway
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
-	"sort"
 	"strings"
 	"time"
+
+	"github.com/fpt/klein-cli/internal/tool/memorydb"
+	pkgLogger "github.com/fpt/klein-cli/pkg/logger"
 )
 
 // MemoryConfig holds memory system configuration.
@@ -15,7 +18,10 @@ type MemoryConfig struct {
 	MaxNotes int    `toml:"max_notes"`
 }
 
-// MemoryManager manages MEMORY.md and daily notes.
+// MemoryManager owns the gateway's side of the memory directory: the run logs
+// scheduled jobs append to, and the legacy MEMORY.md/daily notes that are
+// imported once into the long-term memory store (memorydb). Recall into
+// prompts and extraction from conversations happen in the agent.
 type MemoryManager struct {
 	config MemoryConfig
 }
@@ -28,83 +34,10 @@ func NewMemoryManager(cfg MemoryConfig) *MemoryManager {
 	return &MemoryManager{config: cfg}
 }
 
-// MemoryPath returns the path to MEMORY.md.
-func (m *MemoryManager) MemoryPath() string {
-	return filepath.Join(m.config.BaseDir, "MEMORY.md")
-}
-
-// TodayNotePath returns the path to today's daily note.
-func (m *MemoryManager) TodayNotePath() string {
-	date := time.Now().Format("2006-01-02")
-	return filepath.Join(m.config.BaseDir, "daily", date+".md")
-}
-
-// GetMemoryContext returns the current MEMORY.md content for prompt injection.
-func (m *MemoryManager) GetMemoryContext() (string, error) {
-	data, err := os.ReadFile(m.MemoryPath())
-	if err != nil {
-		if os.IsNotExist(err) {
-			return "", nil
-		}
-		return "", err
-	}
-	return string(data), nil
-}
-
-// GetRecentDailyNotes returns the last N daily notes.
-func (m *MemoryManager) GetRecentDailyNotes(n int) ([]string, error) {
-	dir := filepath.Join(m.config.BaseDir, "daily")
-	entries, err := os.ReadDir(dir)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, nil
-		}
-		return nil, err
-	}
-
-	// Sort by name (date-formatted, so lexicographic is chronological)
-	sort.Slice(entries, func(i, j int) bool {
-		return entries[i].Name() < entries[j].Name()
-	})
-
-	start := len(entries) - n
-	if start < 0 {
-		start = 0
-	}
-
-	var notes []string
-	for _, e := range entries[start:] {
-		if !strings.HasSuffix(e.Name(), ".md") {
-			continue
-		}
-		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
-		if err != nil {
-			continue
-		}
-		notes = append(notes, fmt.Sprintf("## %s\n%s", strings.TrimSuffix(e.Name(), ".md"), string(data)))
-	}
-	return notes, nil
-}
-
-// BuildMemoryPrompt constructs a memory context block for injection into the user prompt.
-func (m *MemoryManager) BuildMemoryPrompt() string {
-	memory, err := m.GetMemoryContext()
-	if err != nil || memory == "" {
-		return ""
-	}
-
-	var sb strings.Builder
-	sb.WriteString("[MEMORY CONTEXT]\n")
-	sb.WriteString(memory)
-
-	notes, err := m.GetRecentDailyNotes(3)
-	if err == nil && len(notes) > 0 {
-		sb.WriteString("\n\n## Recent Daily Notes\n")
-		sb.WriteString(strings.Join(notes, "\n\n"))
-	}
-
-	sb.WriteString("\n[END MEMORY CONTEXT]\n\n")
-	return sb.String()
+// StorePath returns the path of the long-term memory database the embedded
+// agent's Remember/Recall tools use.
+func (m *MemoryManager) StorePath() string {
+	return filepath.Join(m.config.BaseDir, "memory.sqlite")
 }
 
 // EnsureDirectories creates the memory base directory and daily subdirectory if needed.
@@ -137,3 +70,65 @@ func (m *MemoryManager) AppendRunLog(now time.Time, schedName, skill, response s
 	_, err = f.WriteString(entry)
 	return err
 }
+
+// importLegacyMemory migrates MEMORY.md and the daily notes under dir into
+// store. The store records that it ran, so this is a no-op after the first
+// gateway start.
+func importLegacyMemory(store *memorydb.Store, dir string, logger *pkgLogger.Logger) {
+	res, err := store.ImportMarkdown(context.Background(), dir)
+	switch {
+	case err != nil:
+		logger.Warn("Importing MEMORY.md and daily notes failed", "dir", dir, "error", err)
+	case !res.Already && res.Files > 0:
+		logger.Info("Imported Markdown memory into the long-term store",
+			"files", res.Files, "imported", res.Imported, "skipped", res.Skipped)
+	}
+}
+
+// memoryListLimit caps the memories one !memory reply shows; Discord messages
+// are limited to 2000 characters.
+const memoryListLimit = 15
+
+// memoryCommand renders the reply to "!memory [query]": the most recently
+// used memories, or the ones matching query.
+func (gw *Gateway) memoryCommand(ctx context.Context, query string) string {
+	if gw.memories == nil {
+		return "Long-term memory is not available."
+	}
+	var lines []string
+	if query = strings.TrimSpace(query); query != "" {
+		hits, err := gw.memories.Recall(ctx, query, nil, memoryListLimit)
+		if err != nil {
+			return fmt.Sprintf("Memory search failed: %v", err)
+		}
+		if len(hits) == 0 {
+			return fmt.Sprintf("No memories match %q.", query)
+		}
+		for _, h := range hits {
+			lines = append(lines, memoryLine(h.ID, h.Kind, h.Content))
+		}
+		return fmt.Sprintf("**Memories matching %q:**\n%s", query, strings.Join(lines, "\n"))
+	}
+
+	items, err := gw.memories.List(ctx, false, memoryListLimit)
+	if err != nil {
+		return fmt.Sprintf("Failed to list memories: %v", err)
+	}
+	if len(items) == 0 {
+		return "No memory stored yet."
+	}
+	active, _, _ := gw.memories.Count(ctx)
+	for _, m := range items {
+		lines = append(lines, memoryLine(m.ID, m.Kind, m.Content))
+	}
+	return fmt.Sprintf("**Recent memories** (%d of %d):\n%s", len(items), active, strings.Join(lines, "\n"))
+}
+
+// memoryLine formats one memory as a list item, shortened to a single line.
+func memoryLine(id int64, kind, content string) string {
+	content = strings.Join(strings.Fields(content), " ")
+	if r := []rune(content); len(r) > 110 {
+		content = string(r[:109]) + "…"
+	}
+	return fmt.Sprintf("- #%d [%s] %s", id, kind, content)
+}
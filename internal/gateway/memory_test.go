@@ -0,0 +1,48 @@
+package gateway
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	pkgLogger "github.com/fpt/klein-cli/pkg/logger"
+)
+
+func TestNewGatewayImportsMarkdownMemory(t *testing.T) {
+	dir := t.TempDir()
+	memo := "# Memory\n\n## User\n- Prefers replies in Japanese\n- Trades Toyota and Sony\n"
+	if err := os.WriteFile(filepath.Join(dir, "MEMORY.md"), []byte(memo), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := DefaultGatewayConfig()
+	cfg.Memory.BaseDir = dir
+	cfg.AgentAddr = "http://127.0.0.1:1"
+
+	gw, err := NewGateway(cfg, pkgLogger.NewLogger(pkgLogger.LogLevelError))
+	if err != nil {
+		t.Fatalf("NewGateway: %v", err)
+	}
+	defer gw.Close()
+	ctx := context.Background()
+
+	list := gw.memoryCommand(ctx, "")
+	if !strings.Contains(list, "(2 of 2)") || !strings.Contains(list, "[fact] Trades Toyota and Sony") {
+		t.Fatalf("!memory listing:\n%s", list)
+	}
+	found := gw.memoryCommand(ctx, "japanese")
+	if !strings.Contains(found, "Prefers replies in Japanese") || strings.Contains(found, "Toyota") {
+		t.Fatalf("!memory japanese:\n%s", found)
+	}
+	if got := gw.memoryCommand(ctx, "kubernetes"); !strings.HasPrefix(got, "No memories match") {
+		t.Fatalf("!memory kubernetes = %q", got)
+	}
+}
+
+func TestMemoryCommandWithoutStore(t *testing.T) {
+	gw := &Gateway{}
+	if got := gw.memoryCommand(context.Background(), ""); got != "Long-term memory is not available." {
+		t.Fatalf("memoryCommand = %q", got)
+	}
+}
@@ -0,0 +1,199 @@
+package gateway
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSchedulePolicy(t *testing.T) {
+	p, err := ScheduleConfig{}.policy()
+	if err != nil || p.timeout != defaultRunTimeout || p.retries != 0 || p.catchUp {
+		t.Errorf("defaults = %+v, %v", p, err)
+	}
+	p, err = ScheduleConfig{Retries: 3, RetryBackoff: "1m", CatchUp: "once", CatchUpWindow: "6h", Timeout: "10m"}.policy()
+	if err != nil || !p.catchUp || p.catchUpWindow != 6*time.Hour || p.timeout != 10*time.Minute {
+		t.Fatalf("policy = %+v, %v", p, err)
+	}
+	if p.backoff(1) != time.Minute || p.backoff(3) != 4*time.Minute || p.backoff(20) != maxRetryBackoff {
+		t.Errorf("backoff = %v %v %v", p.backoff(1), p.backoff(3), p.backoff(20))
+	}
+	for _, bad := range []ScheduleConfig{{Retries: -1}, {Timeout: "soon"}, {RetryBackoff: "0s"}, {CatchUp: "all"}} {
+		if _, err := bad.policy(); err == nil {
+			t.Errorf("%+v should be rejected", bad)
+		}
+	}
+}
+
+// answerRuns plays the gateway: it answers each scheduled message with the
+// next status in order and records the messages.
+func answerRuns(ctx context.Context, bus *MessageBus, statuses ...RunStatus) <-chan InboundMessage {
+	seen := make(chan InboundMessage, 16)
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case <-ctx.Done():
+				return
+			case msg := <-bus.Inbound:
+				seen <- msg
+				st := RunSucceeded
+				if i < len(statuses) {
+					st = statuses[i]
+				}
+				msg.Result <- RunResult{Status: st, Error: string(st)}
+			}
+		}
+	}()
+	return seen
+}
+
+func waitForRuns(t *testing.T, l *RunLedger, name string, n int) []RunRecord {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		runs, _ := l.History(context.Background(), name, 10)
+		done := len(runs) >= n
+		for _, r := range runs {
+			done = done && r.Status != RunRunning
+		}
+		if done {
+			return runs
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("waiting for %d finished runs of %s, have %+v", n, name, runs)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func testSchedule(name string) ScheduleConfig {
+	return ScheduleConfig{Name: name, Enabled: true, Cron: "0 * * * *", Timezone: "UTC", Prompt: "go", ChannelID: "1"}
+}
+
+func TestScheduler_RetriesFailedRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bus := NewMessageBus(8)
+	l := openTestLedger(t)
+	s := NewScheduler(nil, bus, newTestLogger())
+	s.SetLedger(l)
+	seen := answerRuns(ctx, bus, RunTimedOut)
+
+	cfg := testSchedule("brief")
+	cfg.Retries, cfg.RetryBackoff, cfg.Timeout = 1, "10ms", "5m"
+	s.dispatch(ctx, cfg, time.Now(), TriggerCron)
+
+	runs := waitForRuns(t, l, "brief", 2)
+	if len(runs) != 2 || runs[1].Status != RunTimedOut || runs[0].Status != RunSucceeded || runs[0].Attempt != 2 {
+		t.Fatalf("runs = %+v", runs)
+	}
+	first, second := <-seen, <-seen
+	if !first.QuietFailure || second.QuietFailure {
+		t.Errorf("only attempts with a retry left should hold back their error: %v, %v", first.QuietFailure, second.QuietFailure)
+	}
+	if first.Timeout != 5*time.Minute {
+		t.Errorf("timeout = %v", first.Timeout)
+	}
+}
+
+func TestScheduler_PreventsOverlap(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bus := NewMessageBus(8)
+	l := openTestLedger(t)
+	s := NewScheduler(nil, bus, newTestLogger())
+	s.SetLedger(l)
+	cfg := testSchedule("slow")
+
+	s.dispatch(ctx, cfg, time.Now(), TriggerCron)
+	msg, ok := drainOne(bus, time.Second)
+	if !ok {
+		t.Fatal("first fire did not start")
+	}
+	s.dispatch(ctx, cfg, time.Now(), TriggerCron) // first still running
+	if _, ok := drainOne(bus, 50*time.Millisecond); ok {
+		t.Fatal("a fire must not start while the previous run is in flight")
+	}
+	msg.Result <- RunResult{Status: RunSucceeded}
+
+	runs := waitForRuns(t, l, "slow", 2) // newest first
+	if runs[0].Status != RunSkipped || runs[1].Status != RunSucceeded {
+		t.Errorf("runs = %+v", runs)
+	}
+}
+
+func TestScheduler_CatchUp(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bus := NewMessageBus(8)
+	l := openTestLedger(t)
+	s := NewScheduler(nil, bus, newTestLogger())
+	s.SetLedger(l)
+	seen := answerRuns(ctx, bus)
+	down := time.Now().Add(-3 * time.Hour)
+
+	// First start: only a baseline.
+	s.catchUp(ctx, testSchedule("fresh"))
+	if last, _ := l.LastFire(ctx, "fresh"); last.IsZero() {
+		t.Error("first start should record a baseline")
+	}
+
+	skip := testSchedule("skip")
+	_ = l.SetLastFire(ctx, "skip", down)
+	s.catchUp(ctx, skip)
+	if last, _ := l.LastFire(ctx, "skip"); !last.After(down) {
+		t.Error("skipped fires should advance the last fire")
+	}
+
+	stale := testSchedule("stale")
+	stale.CatchUp, stale.CatchUpWindow = "once", "1ms"
+	_ = l.SetLastFire(ctx, "stale", down)
+	s.catchUp(ctx, stale)
+
+	once := testSchedule("once")
+	once.CatchUp = "once"
+	_ = l.SetLastFire(ctx, "once", down)
+	s.catchUp(ctx, once)
+
+	runs := waitForRuns(t, l, "once", 1)
+	if len(runs) != 1 || runs[0].Trigger != TriggerCatchUp || runs[0].ScheduledFor.Minute() != 0 {
+		t.Errorf("catch-up runs = %+v", runs)
+	}
+	if msg := <-seen; msg.PeerID != "scheduler:once" {
+		t.Errorf("only the once schedule should run, got %s", msg.PeerID)
+	}
+	for _, name := range []string{"skip", "stale", "fresh"} {
+		if runs, _ := l.History(ctx, name, 10); len(runs) != 0 {
+			t.Errorf("%s should not run: %+v", name, runs)
+		}
+	}
+}
+
+func TestScheduler_PauseAndManualRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bus := NewMessageBus(8)
+	l := openTestLedger(t)
+	s := NewScheduler([]ScheduleConfig{testSchedule("brief")}, bus, newTestLogger())
+	s.SetLedger(l)
+	seen := answerRuns(ctx, bus)
+
+	_ = l.SetPaused(ctx, "brief", true)
+	s.dispatch(ctx, testSchedule("brief"), time.Now(), TriggerCron)
+	if runs, _ := l.History(ctx, "brief", 10); len(runs) != 0 {
+		t.Fatalf("a paused schedule must not run: %+v", runs)
+	}
+
+	// A queued manual run goes through despite the pause.
+	_ = l.RequestRun(ctx, "brief")
+	_ = l.RequestRun(ctx, "nope")
+	s.runRequested(ctx)
+	runs := waitForRuns(t, l, "brief", 1)
+	if runs[0].Trigger != TriggerManual || runs[0].Status != RunSucceeded {
+		t.Errorf("manual run = %+v", runs[0])
+	}
+	<-seen
+	if all, _ := l.History(ctx, "", 10); len(all) != 1 {
+		t.Errorf("an unknown schedule should not run: %+v", all)
+	}
+}
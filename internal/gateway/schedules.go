@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"slices"
 	"sync"
 	"time"
 
@@ -39,28 +40,101 @@ type ScheduleConfig struct {
 	ChannelType string `json:"channel_type" toml:"channel_type"` // Output channel — required unless Silent
 	ChannelID   string `json:"channel_id" toml:"channel_id"`
 	RunAtStart  bool   `json:"run_at_start" toml:"run_at_start"` // If true, fire once immediately when (re)started
+
+	// Run policy. All optional; see schedulePolicy for the defaults.
+	Timeout       string `json:"timeout,omitempty" toml:"timeout"`                 // Per-attempt limit (Go duration, default "30m")
+	Retries       int    `json:"retries,omitempty" toml:"retries"`                 // Extra attempts after a failed or timed-out run (default 0)
+	RetryBackoff  string `json:"retry_backoff,omitempty" toml:"retry_backoff"`     // Wait before the first retry, doubled for each next one (default "1m")
+	CatchUp       string `json:"catch_up,omitempty" toml:"catch_up"`               // Fires missed while the gateway was down: "skip" (default) or "once"
+	CatchUpWindow string `json:"catch_up_window,omitempty" toml:"catch_up_window"` // Only catch up a fire missed by at most this much (Go duration; empty = any age)
+}
+
+// schedulePolicy is a ScheduleConfig's parsed run policy.
+type schedulePolicy struct {
+	timeout       time.Duration
+	retries       int
+	retryBackoff  time.Duration
+	catchUp       bool
+	catchUpWindow time.Duration // 0 = any age
+}
+
+const (
+	defaultRunTimeout   = 30 * time.Minute
+	defaultRetryBackoff = time.Minute
+	maxRetryBackoff     = time.Hour
+)
+
+// policy parses the run policy fields, applying defaults.
+func (c ScheduleConfig) policy() (schedulePolicy, error) {
+	p := schedulePolicy{timeout: defaultRunTimeout, retries: c.Retries, retryBackoff: defaultRetryBackoff}
+	if p.retries < 0 {
+		return p, fmt.Errorf("retries must not be negative, got %d", c.Retries)
+	}
+	for _, d := range []struct {
+		field, value string
+		into         *time.Duration
+	}{
+		{"timeout", c.Timeout, &p.timeout},
+		{"retry_backoff", c.RetryBackoff, &p.retryBackoff},
+		{"catch_up_window", c.CatchUpWindow, &p.catchUpWindow},
+	} {
+		if d.value == "" {
+			continue
+		}
+		v, err := time.ParseDuration(d.value)
+		if err != nil || v <= 0 {
+			return p, fmt.Errorf("bad %s %q: want a positive Go duration", d.field, d.value)
+		}
+		*d.into = v
+	}
+	switch c.CatchUp {
+	case "", "skip":
+	case "once":
+		p.catchUp = true
+	default:
+		return p, fmt.Errorf("bad catch_up %q: want \"skip\" or \"once\"", c.CatchUp)
+	}
+	return p, nil
+}
+
+// backoff is the wait before retry number n (1-based): retry_backoff doubled
+// per retry, capped at an hour.
+func (p schedulePolicy) backoff(n int) time.Duration {
+	d := p.retryBackoff
+	for i := 1; i < n && d < maxRetryBackoff; i++ {
+		d *= 2
+	}
+	return min(d, maxRetryBackoff)
 }
 
 // scheduleSig is a change signature: reconciliation restarts a job only when
 // one of these fields changes.
 func scheduleSig(c ScheduleConfig) string {
-	return fmt.Sprintf("%s|%s|%s|%s|%s|%t|%s|%s", c.Cron, c.Timezone,
-		c.Prompt, c.Skill, c.ChannelType, c.Silent, c.ChannelID, c.Name)
+	return fmt.Sprintf("%s|%s|%s|%s|%s|%t|%s|%s|%s|%d|%s|%s|%s", c.Cron, c.Timezone,
+		c.Prompt, c.Skill, c.ChannelType, c.Silent, c.ChannelID, c.Name,
+		c.Timeout, c.Retries, c.RetryBackoff, c.CatchUp, c.CatchUpWindow)
 }
 
 // Scheduler owns a set of schedules and runs each on its own goroutine. It
 // supports a static set (from config) plus a dynamic store file (written by the
 // agent's Schedule* tools) that it polls and reconciles without a restart.
+//
+// With a RunLedger it also records every attempt, retries failed runs, catches
+// up fires missed while the gateway was down, honours pause/resume, and runs
+// the manual runs `klein claw schedules run` queues. Without one, runs are
+// still retried and never overlap, but nothing is remembered across restarts.
 type Scheduler struct {
 	bus    *MessageBus
 	logger *pkgLogger.Logger
 
 	static       []ScheduleConfig // from config.json (+ legacy heartbeat)
 	storePath    string           // optional dynamic store file (JSON array)
+	ledger       *RunLedger       // optional run ledger
 	pollInterval time.Duration
 
 	mu      sync.Mutex
 	running map[string]runningJob // by schedule name
+	active  map[string]bool       // schedules with a run in flight (overlap prevention)
 }
 
 type runningJob struct {
@@ -76,6 +150,7 @@ func NewScheduler(static []ScheduleConfig, bus *MessageBus, logger *pkgLogger.Lo
 		static:       static,
 		pollInterval: 20 * time.Second,
 		running:      make(map[string]runningJob),
+		active:       make(map[string]bool),
 	}
 }
 
@@ -83,21 +158,37 @@ func NewScheduler(static []ScheduleConfig, bus *MessageBus, logger *pkgLogger.Lo
 // gateway sets this to <base_dir>/schedules.json.
 func (s *Scheduler) SetStorePath(path string) { s.storePath = path }
 
+// SetLedger enables the run ledger. The gateway opens it at
+// <base_dir>/schedule_runs.sqlite.
+func (s *Scheduler) SetLedger(l *RunLedger) { s.ledger = l }
+
 // Start reconciles the initial schedule set, then polls the store file for
-// changes and reconciles live. Blocks until ctx is cancelled.
+// changes and reconciles live, and the ledger for queued manual runs. Blocks
+// until ctx is cancelled.
 func (s *Scheduler) Start(ctx context.Context) {
+	if s.ledger != nil {
+		if n, err := s.ledger.AbandonRunning(ctx); err != nil {
+			s.logger.Warn("Failed to close out abandoned runs", "error", err)
+		} else if n > 0 {
+			s.logger.Warn("Marked runs interrupted by the last shutdown as abandoned", "count", n)
+		}
+		s.heartbeat(ctx)
+	}
 	s.reconcile(ctx, s.desired())
 
-	// Without a dynamic store there is nothing to watch. Any jobs started above
-	// keep running on their own goroutines (tied to ctx), so just return —
-	// this keeps an empty/static-only scheduler from blocking a goroutine.
-	if s.storePath == "" {
+	// Without a dynamic store or a ledger there is nothing to watch. Any jobs
+	// started above keep running on their own goroutines (tied to ctx), so
+	// just return — this keeps an empty/static-only scheduler from blocking a
+	// goroutine.
+	if s.storePath == "" && s.ledger == nil {
 		return
 	}
 
 	var lastMod time.Time
-	if fi, err := os.Stat(s.storePath); err == nil {
-		lastMod = fi.ModTime()
+	if s.storePath != "" {
+		if fi, err := os.Stat(s.storePath); err == nil {
+			lastMod = fi.ModTime()
+		}
 	}
 	ticker := time.NewTicker(s.pollInterval)
 	defer ticker.Stop()
@@ -107,6 +198,13 @@ func (s *Scheduler) Start(ctx context.Context) {
 			s.stopAll()
 			return
 		case <-ticker.C:
+			if s.ledger != nil {
+				s.heartbeat(ctx)
+				s.runRequested(ctx)
+			}
+			if s.storePath == "" {
+				continue
+			}
 			fi, err := os.Stat(s.storePath)
 			if err != nil {
 				continue // store may not exist yet
@@ -120,19 +218,70 @@ func (s *Scheduler) Start(ctx context.Context) {
 	}
 }
 
-// desired returns the merged, enabled schedule set (static config + store).
-func (s *Scheduler) desired() []ScheduleConfig {
-	out := append([]ScheduleConfig(nil), s.static...)
-	if s.storePath != "" {
-		if loaded, err := loadScheduleStore(s.storePath); err != nil {
-			s.logger.Warn("Failed to read schedule store", "path", s.storePath, "error", err)
-		} else {
-			out = append(out, loaded...)
+func (s *Scheduler) heartbeat(ctx context.Context) {
+	if err := s.ledger.Heartbeat(ctx); err != nil {
+		s.logger.Warn("Failed to write scheduler heartbeat", "error", err)
+	}
+}
+
+// runRequested starts the manual runs queued in the ledger. A manual run
+// ignores pause and enabled; it only needs a schedule with that name.
+func (s *Scheduler) runRequested(ctx context.Context) {
+	names, err := s.ledger.TakeRunRequests(ctx)
+	if err != nil {
+		s.logger.Warn("Failed to read queued runs", "error", err)
+		return
+	}
+	if len(names) == 0 {
+		return
+	}
+	byName := make(map[string]ScheduleConfig)
+	for _, c := range s.desired() {
+		byName[c.Name] = c
+	}
+	for _, name := range names {
+		cfg, ok := byName[name]
+		if !ok || cfg.Prompt == "" {
+			s.logger.Warn("Queued run names no runnable schedule; dropped", "schedule", name)
+			continue
 		}
+		s.logger.Info("Starting queued manual run", "schedule", name)
+		s.dispatch(ctx, cfg, time.Now(), TriggerManual)
+	}
+}
+
+// desired returns the merged schedule set (static config + store).
+func (s *Scheduler) desired() []ScheduleConfig {
+	out, err := MergeSchedules(s.static, s.storePath)
+	if err != nil {
+		s.logger.Warn("Failed to read schedule store", "path", s.storePath, "error", err)
 	}
 	return out
 }
 
+// MergeSchedules returns the static (settings) schedules followed by the ones
+// in the store file at storePath (if any); a store entry overrides a static
+// one of the same name. On a store read error the static set is returned with
+// the error.
+func MergeSchedules(static []ScheduleConfig, storePath string) ([]ScheduleConfig, error) {
+	out := append([]ScheduleConfig(nil), static...)
+	if storePath == "" {
+		return out, nil
+	}
+	loaded, err := loadScheduleStore(storePath)
+	if err != nil {
+		return out, err
+	}
+	for _, c := range loaded {
+		if i := slices.IndexFunc(out, func(o ScheduleConfig) bool { return o.Name == c.Name }); i >= 0 {
+			out[i] = c
+			continue
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
+
 // reconcile starts jobs that are newly-present or changed and stops jobs that
 // were removed or changed. Parent ctx cancellation stops everything.
 func (s *Scheduler) reconcile(parent context.Context, desired []ScheduleConfig) {
@@ -148,7 +297,11 @@ func (s *Scheduler) reconcile(parent context.Context, desired []ScheduleConfig)
 				"schedule", c.Name, "cron", c.Cron, "timezone", c.Timezone)
 			continue
 		}
-		wanted[c.Name] = c // later entries (store) override earlier (static) by name
+		if _, err := c.policy(); err != nil {
+			s.logger.Warn("Schedule skipped: invalid run policy", "schedule", c.Name, "error", err)
+			continue
+		}
+		wanted[c.Name] = c
 	}
 
 	s.mu.Lock()
@@ -187,7 +340,9 @@ func (s *Scheduler) runOne(ctx context.Context, cfg ScheduleConfig) {
 		"cron", cfg.Cron, "timezone", cfg.Timezone, "skill", cfg.Skill, "silent", cfg.Silent)
 
 	if cfg.RunAtStart {
-		s.fire(cfg)
+		s.dispatch(ctx, cfg, time.Now(), TriggerStart)
+	} else {
+		s.catchUp(ctx, cfg)
 	}
 
 	for {
@@ -201,9 +356,173 @@ func (s *Scheduler) runOne(ctx context.Context, cfg ScheduleConfig) {
 			s.logger.Info("Schedule stopped", "schedule", cfg.Name)
 			return
 		case <-time.After(wait):
-			s.fire(cfg)
+			s.dispatch(ctx, cfg, time.Now(), TriggerCron)
+		}
+	}
+}
+
+// catchUp runs the latest fire missed while the gateway was down, when the
+// schedule's catch_up policy asks for it. The first start of a schedule only
+// records a baseline: nothing was missed before the ledger knew about it.
+func (s *Scheduler) catchUp(ctx context.Context, cfg ScheduleConfig) {
+	if s.ledger == nil {
+		return
+	}
+	now := time.Now()
+	last, err := s.ledger.LastFire(ctx, cfg.Name)
+	if err != nil {
+		s.logger.Warn("Failed to read the last fire; not catching up", "schedule", cfg.Name, "error", err)
+		return
+	}
+	if last.IsZero() {
+		s.setLastFire(ctx, cfg.Name, now)
+		return
+	}
+	missed, count := latestMissedFire(last, now, cfg)
+	if count == 0 {
+		return
+	}
+	p, _ := cfg.policy() // validated by reconcile
+	switch {
+	case !p.catchUp:
+		s.logger.Info("Skipping fires missed while the gateway was down", "schedule", cfg.Name, "missed", count)
+		s.setLastFire(ctx, cfg.Name, missed)
+	case p.catchUpWindow > 0 && now.Sub(missed) > p.catchUpWindow:
+		s.logger.Info("Missed fire is older than catch_up_window; skipping", "schedule", cfg.Name,
+			"missed_at", missed, "window", p.catchUpWindow)
+		s.setLastFire(ctx, cfg.Name, missed)
+	default:
+		s.logger.Info("Catching up a missed fire", "schedule", cfg.Name, "missed_at", missed, "missed", count)
+		s.dispatch(ctx, cfg, missed, TriggerCatchUp)
+	}
+}
+
+// latestMissedFire returns the latest cron fire in (last, now] and how many
+// there were (counting stops at 10000).
+func latestMissedFire(last, now time.Time, cfg ScheduleConfig) (time.Time, int) {
+	var latest time.Time
+	count := 0
+	for t := last; count < 10000; count++ {
+		next, err := nextCronFire(t, cfg.Cron, cfg.Timezone)
+		if err != nil || next.After(now) {
+			break
+		}
+		latest, t = next, next
+	}
+	return latest, count
+}
+
+// dispatch starts a run for a fire unless the schedule is paused (manual runs
+// excepted) or its previous run is still going. Cron and catch-up fires
+// advance the schedule's last fire either way, so a paused or overlapping
+// fire is not caught up later.
+func (s *Scheduler) dispatch(ctx context.Context, cfg ScheduleConfig, scheduledFor time.Time, trigger string) {
+	if trigger != TriggerManual {
+		s.setLastFire(ctx, cfg.Name, scheduledFor)
+	}
+	if trigger != TriggerManual && s.ledger != nil {
+		paused, err := s.ledger.Paused(ctx, cfg.Name)
+		if err != nil {
+			s.logger.Warn("Failed to read pause state; running anyway", "schedule", cfg.Name, "error", err)
+		}
+		if paused {
+			s.logger.Info("Schedule is paused; skipping fire", "schedule", cfg.Name)
+			return
+		}
+	}
+
+	s.mu.Lock()
+	busy := s.active[cfg.Name]
+	if !busy {
+		s.active[cfg.Name] = true
+	}
+	s.mu.Unlock()
+	if busy {
+		s.logger.Warn("Previous run still in progress; skipping fire", "schedule", cfg.Name, "trigger", trigger)
+		if s.ledger != nil {
+			if err := s.ledger.RecordSkipped(ctx, cfg.Name, trigger, scheduledFor, "previous run still in progress"); err != nil {
+				s.logger.Warn("Failed to record skipped run", "schedule", cfg.Name, "error", err)
+			}
+		}
+		return
+	}
+	go s.execute(ctx, cfg, scheduledFor, trigger)
+}
+
+// execute runs one fire to completion: the first attempt plus up to retries
+// more after a failure or timeout, backing off between them.
+func (s *Scheduler) execute(ctx context.Context, cfg ScheduleConfig, scheduledFor time.Time, trigger string) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.active, cfg.Name)
+		s.mu.Unlock()
+	}()
+	p, _ := cfg.policy() // validated by reconcile
+	for attempt := 1; ; attempt++ {
+		res := s.attempt(ctx, cfg, p, scheduledFor, trigger, attempt)
+		if res.Status == RunSucceeded || res.Status == RunAbandoned || attempt > p.retries {
+			return
+		}
+		wait := p.backoff(attempt)
+		s.logger.Warn("Scheduled run failed; retrying", "schedule", cfg.Name, "status", res.Status,
+			"error", res.Error, "attempt", attempt, "retry_in", wait)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// attempt fires one message and waits for the gateway to report its outcome.
+func (s *Scheduler) attempt(ctx context.Context, cfg ScheduleConfig, p schedulePolicy, scheduledFor time.Time, trigger string, n int) RunResult {
+	var runID int64
+	if s.ledger != nil {
+		id, err := s.ledger.StartRun(ctx, cfg.Name, trigger, scheduledFor, n)
+		if err != nil {
+			s.logger.Warn("Failed to record run start", "schedule", cfg.Name, "error", err)
+		}
+		runID = id
+	}
+
+	done := make(chan RunResult, 1)
+	msg := s.message(cfg)
+	msg.Result = done
+	msg.Timeout = p.timeout
+	msg.QuietFailure = n <= p.retries // another attempt follows a failure
+
+	var res RunResult
+	select {
+	case s.bus.Inbound <- msg:
+		select {
+		case res = <-done:
+		case <-ctx.Done():
+			res = RunResult{Status: RunAbandoned, Error: "the schedule was stopped or changed during the run"}
+		}
+	case <-ctx.Done():
+		res = RunResult{Status: RunAbandoned, Error: "the schedule was stopped before the run started"}
+	}
+
+	if runID != 0 {
+		if err := s.ledger.FinishRun(context.WithoutCancel(ctx), runID, res); err != nil {
+			s.logger.Warn("Failed to record run end", "schedule", cfg.Name, "error", err)
 		}
 	}
+	return res
+}
+
+func (s *Scheduler) setLastFire(ctx context.Context, name string, t time.Time) {
+	if s.ledger == nil {
+		return
+	}
+	if err := s.ledger.SetLastFire(ctx, name, t); err != nil {
+		s.logger.Warn("Failed to record the last fire", "schedule", name, "error", err)
+	}
+}
+
+// NextFire returns the schedule's next cron fire after now.
+func NextFire(now time.Time, cfg ScheduleConfig) (time.Time, error) {
+	return nextCronFire(now, cfg.Cron, cfg.Timezone)
 }
 
 // nextWait computes the delay until the schedule's next cron fire from now.
@@ -237,9 +556,10 @@ func nextCronFire(now time.Time, expr, tzName string) (time.Time, error) {
 	return next, nil
 }
 
-func (s *Scheduler) fire(cfg ScheduleConfig) {
+// message builds the inbound message a fire of cfg sends.
+func (s *Scheduler) message(cfg ScheduleConfig) InboundMessage {
 	s.logger.Info("Firing schedule", "schedule", cfg.Name, "silent", cfg.Silent)
-	s.bus.Inbound <- InboundMessage{
+	return InboundMessage{
 		ChannelType: cfg.ChannelType,
 		ChannelID:   cfg.ChannelID,
 		PeerID:      "scheduler:" + cfg.Name,
@@ -51,7 +51,10 @@ type SessionManager struct {
 	client   agentv1connect.AgentServiceClient
 	config   *GatewayConfig
 	timeout  time.Duration
-	logger   *pkgLogger.Logger
+	// approvalTimeout is sent as StartSessionRequest.approval_timeout_seconds,
+	// which turns on remote approval for every gateway session.
+	approvalTimeout time.Duration
+	logger          *pkgLogger.Logger
 }
 
 // NewSessionManager creates a session manager.
@@ -60,12 +63,17 @@ func NewSessionManager(client agentv1connect.AgentServiceClient, cfg *GatewayCon
 	if err != nil || timeout <= 0 {
 		timeout = 30 * time.Minute
 	}
+	approvalTimeout, err := time.ParseDuration(cfg.ApprovalTimeout)
+	if err != nil || approvalTimeout < time.Second {
+		approvalTimeout = 5 * time.Minute
+	}
 	return &SessionManager{
-		sessions: make(map[SessionKey]*Session),
-		client:   client,
-		config:   cfg,
-		timeout:  timeout,
-		logger:   logger.WithComponent("sessions"),
+		sessions:        make(map[SessionKey]*Session),
+		client:          client,
+		config:          cfg,
+		timeout:         timeout,
+		approvalTimeout: approvalTimeout,
+		logger:          logger.WithComponent("sessions"),
 	}
 }
 
@@ -102,12 +110,15 @@ func (sm *SessionManager) GetOrCreateSession(ctx context.Context, key SessionKey
 	// Create new agent session via Connect RPC. The gateway only sets the
 	// working directory; the model and max_iterations are owned by the agent
 	// (serve process) via its settings.toml, so they are left unset here and
-	// the server keeps its own configured values.
+	// the server keeps its own configured values. Remote approval is always on:
+	// nobody sits at the server's terminal, so gated tool calls come back to the
+	// channel as ApprovalRequest events (see Gateway.handleApproval).
 	req := connect.NewRequest(&agentv1.StartSessionRequest{
 		Settings: &agentv1.Settings{
 			WorkingDir: sm.config.WorkingDir,
 		},
-		Interactive: true,
+		Interactive:            true,
+		ApprovalTimeoutSeconds: int32(sm.approvalTimeout / time.Second),
 	})
 	req.Header().Set("X-Persistence-Key", key.PersistenceKeyString())
 	resp, err := sm.client.StartSession(ctx, req)
@@ -129,6 +140,28 @@ func (sm *SessionManager) GetOrCreateSession(ctx context.Context, key SessionKey
 	return session, nil
 }
 
+// CompactSession compacts the peer's conversation now, with focus as extra
+// instruction for the summariser. It waits for a running turn to finish.
+func (sm *SessionManager) CompactSession(ctx context.Context, key SessionKey, focus string) (*agentv1.CompactResponse, error) {
+	session, err := sm.GetOrCreateSession(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer session.LockInvoke()()
+	session.mu.Lock()
+	skill := session.Skill
+	session.mu.Unlock()
+	resp, err := sm.client.Compact(ctx, connect.NewRequest(&agentv1.CompactRequest{
+		SessionId: session.AgentSessionID,
+		Scenario:  skill,
+		Focus:     focus,
+	}))
+	if err != nil {
+		return nil, err
+	}
+	return resp.Msg, nil
+}
+
 // ClearSession removes a session from the manager.
 func (sm *SessionManager) ClearSession(ctx context.Context, key SessionKey) error {
 	sm.mu.Lock()
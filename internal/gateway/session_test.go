@@ -15,7 +15,13 @@ import (
 // (nil) so only StartSession needs an implementation for this test.
 type fakeAgentClient struct {
 	agentv1connect.AgentServiceClient
-	lastStart *agentv1.StartSessionRequest
+	lastStart   *agentv1.StartSessionRequest
+	lastCompact *agentv1.CompactRequest
+}
+
+func (f *fakeAgentClient) Compact(ctx context.Context, req *connect.Request[agentv1.CompactRequest]) (*connect.Response[agentv1.CompactResponse], error) {
+	f.lastCompact = req.Msg
+	return connect.NewResponse(&agentv1.CompactResponse{Compacted: true, Strategy: "summary", BeforeTokens: 9000, AfterTokens: 1200}), nil
 }
 
 func (f *fakeAgentClient) StartSession(ctx context.Context, req *connect.Request[agentv1.StartSessionRequest]) (*connect.Response[agentv1.StartSessionResponse], error) {
@@ -51,3 +57,34 @@ func TestGatewayDoesNotDictateModelOrMaxIterations(t *testing.T) {
 		t.Errorf("gateway WorkingDir=%q, want /tmp/work", s.WorkingDir)
 	}
 }
+
+// Gateway sessions always ask the channel before gated tool calls run; nobody
+// sits at the agent's terminal to approve them.
+func TestGatewayEnablesRemoteApproval(t *testing.T) {
+	for _, tc := range []struct {
+		setting string
+		want    int32
+	}{{"", 300}, {"90s", 90}, {"bogus", 300}} {
+		fake := &fakeAgentClient{}
+		cfg := &GatewayConfig{SessionTimeout: "30m", ApprovalTimeout: tc.setting}
+		sm := NewSessionManager(fake, cfg, pkgLogger.NewComponentLogger("test"))
+		if _, err := sm.GetOrCreateSession(context.Background(), SessionKey{ChannelType: "discord", ChannelID: "c1", PeerID: "p1"}); err != nil {
+			t.Fatal(err)
+		}
+		if got := fake.lastStart.ApprovalTimeoutSeconds; got != tc.want {
+			t.Errorf("approval_timeout %q: sent %d seconds, want %d", tc.setting, got, tc.want)
+		}
+	}
+}
+
+func TestCompactSession(t *testing.T) {
+	fake := &fakeAgentClient{}
+	sm := NewSessionManager(fake, &GatewayConfig{SessionTimeout: "30m"}, pkgLogger.NewComponentLogger("test"))
+	resp, err := sm.CompactSession(context.Background(), SessionKey{ChannelType: "discord", ChannelID: "c1", PeerID: "p1"}, "the trip plans")
+	if err != nil || !resp.Compacted {
+		t.Fatalf("CompactSession = %v, %v", resp, err)
+	}
+	if got := fake.lastCompact; got.SessionId != "s1" || got.Scenario != ClawRole || got.Focus != "the trip plans" {
+		t.Errorf("Compact request = %v", got)
+	}
+}
@@ -54,6 +54,8 @@ const (
 	// AgentServiceGetConversationPreviewProcedure is the fully-qualified name of the AgentService's
 	// GetConversationPreview RPC.
 	AgentServiceGetConversationPreviewProcedure = "/klein.agent.v1.AgentService/GetConversationPreview"
+	// AgentServiceCompactProcedure is the fully-qualified name of the AgentService's Compact RPC.
+	AgentServiceCompactProcedure = "/klein.agent.v1.AgentService/Compact"
 	// AgentServiceSetSettingsProcedure is the fully-qualified name of the AgentService's SetSettings
 	// RPC.
 	AgentServiceSetSettingsProcedure = "/klein.agent.v1.AgentService/SetSettings"
@@ -73,6 +75,7 @@ type AgentServiceClient interface {
 	WriteTodos(context.Context, *connect.Request[agentv1.WriteTodosRequest]) (*connect.Response[agentv1.WriteTodosResponse], error)
 	// Conversation
 	GetConversationPreview(context.Context, *connect.Request[agentv1.GetConversationPreviewRequest]) (*connect.Response[agentv1.GetConversationPreviewResponse], error)
+	Compact(context.Context, *connect.Request[agentv1.CompactRequest]) (*connect.Response[agentv1.CompactResponse], error)
 	// Dynamic settings
 	SetSettings(context.Context, *connect.Request[agentv1.SetSettingsRequest]) (*connect.Response[agentv1.SetSettingsResponse], error)
 }
@@ -136,6 +139,12 @@ func NewAgentServiceClient(httpClient connect.HTTPClient, baseURL string, opts .
 			connect.WithSchema(agentServiceMethods.ByName("GetConversationPreview")),
 			connect.WithClientOptions(opts...),
 		),
+		compact: connect.NewClient[agentv1.CompactRequest, agentv1.CompactResponse](
+			httpClient,
+			baseURL+AgentServiceCompactProcedure,
+			connect.WithSchema(agentServiceMethods.ByName("Compact")),
+			connect.WithClientOptions(opts...),
+		),
 		setSettings: connect.NewClient[agentv1.SetSettingsRequest, agentv1.SetSettingsResponse](
 			httpClient,
 			baseURL+AgentServiceSetSettingsProcedure,
@@ -155,6 +164,7 @@ type agentServiceClient struct {
 	getTodos               *connect.Client[agentv1.GetTodosRequest, agentv1.GetTodosResponse]
 	writeTodos             *connect.Client[agentv1.WriteTodosRequest, agentv1.WriteTodosResponse]
 	getConversationPreview *connect.Client[agentv1.GetConversationPreviewRequest, agentv1.GetConversationPreviewResponse]
+	compact                *connect.Client[agentv1.CompactRequest, agentv1.CompactResponse]
 	setSettings            *connect.Client[agentv1.SetSettingsRequest, agentv1.SetSettingsResponse]
 }
 
@@ -198,6 +208,11 @@ func (c *agentServiceClient) GetConversationPreview(ctx context.Context, req *co
 	return c.getConversationPreview.CallUnary(ctx, req)
 }
 
+// Compact calls klein.agent.v1.AgentService.Compact.
+func (c *agentServiceClient) Compact(ctx context.Context, req *connect.Request[agentv1.CompactRequest]) (*connect.Response[agentv1.CompactResponse], error) {
+	return c.compact.CallUnary(ctx, req)
+}
+
 // SetSettings calls klein.agent.v1.AgentService.SetSettings.
 func (c *agentServiceClient) SetSettings(ctx context.Context, req *connect.Request[agentv1.SetSettingsRequest]) (*connect.Response[agentv1.SetSettingsResponse], error) {
 	return c.setSettings.CallUnary(ctx, req)
@@ -217,6 +232,7 @@ type AgentServiceHandler interface {
 	WriteTodos(context.Context, *connect.Request[agentv1.WriteTodosRequest]) (*connect.Response[agentv1.WriteTodosResponse], error)
 	// Conversation
 	GetConversationPreview(context.Context, *connect.Request[agentv1.GetConversationPreviewRequest]) (*connect.Response[agentv1.GetConversationPreviewResponse], error)
+	Compact(context.Context, *connect.Request[agentv1.CompactRequest]) (*connect.Response[agentv1.CompactResponse], error)
 	// Dynamic settings
 	SetSettings(context.Context, *connect.Request[agentv1.SetSettingsRequest]) (*connect.Response[agentv1.SetSettingsResponse], error)
 }
@@ -276,6 +292,12 @@ func NewAgentServiceHandler(svc AgentServiceHandler, opts ...connect.HandlerOpti
 		connect.WithSchema(agentServiceMethods.ByName("GetConversationPreview")),
 		connect.WithHandlerOptions(opts...),
 	)
+	agentServiceCompactHandler := connect.NewUnaryHandler(
+		AgentServiceCompactProcedure,
+		svc.Compact,
+		connect.WithSchema(agentServiceMethods.ByName("Compact")),
+		connect.WithHandlerOptions(opts...),
+	)
 	agentServiceSetSettingsHandler := connect.NewUnaryHandler(
 		AgentServiceSetSettingsProcedure,
 		svc.SetSettings,
@@ -300,6 +322,8 @@ func NewAgentServiceHandler(svc AgentServiceHandler, opts ...connect.HandlerOpti
 			agentServiceWriteTodosHandler.ServeHTTP(w, r)
 		case AgentServiceGetConversationPreviewProcedure:
 			agentServiceGetConversationPreviewHandler.ServeHTTP(w, r)
+		case AgentServiceCompactProcedure:
+			agentServiceCompactHandler.ServeHTTP(w, r)
 		case AgentServiceSetSettingsProcedure:
 			agentServiceSetSettingsHandler.ServeHTTP(w, r)
 		default:
@@ -343,6 +367,10 @@ func (UnimplementedAgentServiceHandler) GetConversationPreview(context.Context,
 	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("klein.agent.v1.AgentService.GetConversationPreview is not implemented"))
 }
 
+func (UnimplementedAgentServiceHandler) Compact(context.Context, *connect.Request[agentv1.CompactRequest]) (*connect.Response[agentv1.CompactResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("klein.agent.v1.AgentService.Compact is not implemented"))
+}
+
 func (UnimplementedAgentServiceHandler) SetSettings(context.Context, *connect.Request[agentv1.SetSettingsRequest]) (*connect.Response[agentv1.SetSettingsResponse], error) {
 	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("klein.agent.v1.AgentService.SetSettings is not implemented"))
 }
@@ -118,6 +118,10 @@ func messageToSerializable(msg message.Message) repository.MessageHistory {
 		Images:    msg.Images(),
 		Timestamp: msg.Timestamp(),
 		Source:    msg.Source(),
+
+		InputTokens:  msg.InputTokens(),
+		OutputTokens: msg.OutputTokens(),
+		TotalTokens:  msg.TotalTokens(),
 	}
 
 	// Handle tool-specific fields if it's a tool call or result message
@@ -172,12 +176,18 @@ func serializableToMessage(s repository.MessageHistory) message.Message {
 	default:
 		// Create regular chat message - we'll lose some metadata like custom ID and timestamp
 		// but this is better than crashing
-		if s.Thinking != "" {
-			return message.NewChatMessageWithThinking(s.Type, s.Content, s.Thinking)
+		var msg message.Message
+		switch {
+		case s.Thinking != "":
+			msg = message.NewChatMessageWithThinking(s.Type, s.Content, s.Thinking)
+		case len(s.Images) > 0:
+			msg = message.NewChatMessageWithImages(s.Type, s.Content, s.Images)
+		default:
+			msg = message.NewChatMessage(s.Type, s.Content)
 		}
-		if len(s.Images) > 0 {
-			return message.NewChatMessageWithImages(s.Type, s.Content, s.Images)
+		if s.TotalTokens > 0 {
+			msg.SetTokenUsage(s.InputTokens, s.OutputTokens, s.TotalTokens)
 		}
-		return message.NewChatMessage(s.Type, s.Content)
+		return msg
 	}
 }
@@ -252,3 +252,23 @@ func TestMessageSerializationEdgeCases(t *testing.T) {
 		t.Fatal("Message type not preserved for empty message")
 	}
 }
+
+func TestMessageHistoryRepositoryPreservesTokenUsage(t *testing.T) {
+	reply := message.NewChatMessage(message.MessageTypeAssistant, "done")
+	reply.SetTokenUsage(120, 30, 150)
+
+	repo := NewMessageHistoryRepository(filepath.Join(t.TempDir(), "session.json"))
+	if err := repo.Save([]message.Message{message.NewChatMessage(message.MessageTypeUser, "go"), reply}); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := repo.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := loaded[1]; got.InputTokens() != 120 || got.OutputTokens() != 30 || got.TotalTokens() != 150 {
+		t.Errorf("token usage = %d/%d/%d, want 120/30/150", got.InputTokens(), got.OutputTokens(), got.TotalTokens())
+	}
+	if loaded[0].TotalTokens() != 0 {
+		t.Errorf("user message gained token usage: %d", loaded[0].TotalTokens())
+	}
+}
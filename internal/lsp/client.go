@@ -0,0 +1,421 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ServerConfig describes one language server.
+type ServerConfig struct {
+	Name       string   // "gopls", "pyright", ...; used in messages
+	Command    string   // executable, looked up on PATH
+	Args       []string // e.g. ["--stdio"]
+	Env        []string // extra "KEY=VAL" entries appended to the environment
+	Extensions []string // file extensions (with dot) routed to this server
+}
+
+// languageIDs maps file extensions to LSP language identifiers. Servers use
+// the identifier to pick a parser, so .tsx must not be sent as "typescript".
+var languageIDs = map[string]string{
+	".go": "go", ".py": "python", ".pyi": "python",
+	".ts": "typescript", ".tsx": "typescriptreact", ".mts": "typescript", ".cts": "typescript",
+	".js": "javascript", ".jsx": "javascriptreact", ".mjs": "javascript", ".cjs": "javascript",
+	".rs": "rust", ".c": "c", ".h": "c", ".cc": "cpp", ".cpp": "cpp", ".hpp": "cpp",
+	".java": "java", ".rb": "ruby",
+}
+
+func languageID(path string) string {
+	ext := strings.ToLower(filepath.Ext(path))
+	if id, ok := languageIDs[ext]; ok {
+		return id
+	}
+	return strings.TrimPrefix(ext, ".")
+}
+
+// shutdownTimeout bounds how long Close waits for a polite exit before
+// killing the server.
+const shutdownTimeout = 2 * time.Second
+
+// Client is a running language server bound to one workspace root. It is safe
+// for concurrent use.
+type Client struct {
+	cfg  ServerConfig
+	root string
+	cmd  *exec.Cmd
+	conn *Conn
+
+	mu    sync.Mutex
+	docs  map[string]*document // by URI
+	diags map[string]*diagState
+	wake  chan struct{} // closed and replaced on every publishDiagnostics
+}
+
+// document is an open text document as the server last saw it.
+type document struct {
+	version int
+	text    string
+}
+
+// diagState is the latest diagnostics for a URI and whether they reflect the
+// most recent sync.
+type diagState struct {
+	diags []Diagnostic
+	fresh bool
+}
+
+// Start launches the server in root and completes the initialize handshake.
+func Start(ctx context.Context, cfg ServerConfig, root string) (*Client, error) {
+	cmd := exec.Command(cfg.Command, cfg.Args...)
+	cmd.Dir = root
+	cmd.Env = append(os.Environ(), cfg.Env...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	// Servers log freely to stderr; nobody reads it, so discard rather than
+	// let a full pipe block the server.
+	cmd.Stderr = io.Discard
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("starting %s: %w", cfg.Name, err)
+	}
+
+	c := &Client{
+		cfg:   cfg,
+		root:  root,
+		cmd:   cmd,
+		docs:  make(map[string]*document),
+		diags: make(map[string]*diagState),
+		wake:  make(chan struct{}),
+	}
+	c.conn = NewConn(stdout, stdin, c)
+	go func() {
+		<-c.conn.Done()
+		_ = cmd.Wait()
+	}()
+
+	if err := c.initialize(ctx); err != nil {
+		c.kill()
+		return nil, fmt.Errorf("initializing %s: %w", cfg.Name, err)
+	}
+	return c, nil
+}
+
+// Name returns the configured server name.
+func (c *Client) Name() string { return c.cfg.Name }
+
+func (c *Client) initialize(ctx context.Context) error {
+	rootURI := PathToURI(c.root)
+	params := map[string]any{
+		"processId": os.Getpid(),
+		"clientInfo": map[string]string{
+			"name": "klein",
+		},
+		"rootUri": rootURI,
+		"workspaceFolders": []map[string]string{
+			{"uri": rootURI, "name": filepath.Base(c.root)},
+		},
+		"capabilities": map[string]any{
+			"textDocument": map[string]any{
+				"synchronization":    map[string]any{"didSave": true},
+				"publishDiagnostics": map[string]any{"versionSupport": true},
+				"hover":              map[string]any{"contentFormat": []string{"markdown", "plaintext"}},
+				"definition":         map[string]any{"linkSupport": true},
+				"references":         map[string]any{},
+				"rename":             map[string]any{"prepareSupport": false},
+			},
+			"workspace": map[string]any{
+				"workspaceFolders": true,
+				"configuration":    true,
+				"workspaceEdit":    map[string]any{"documentChanges": true},
+			},
+		},
+	}
+	if err := c.conn.Call(ctx, "initialize", params, nil); err != nil {
+		return err
+	}
+	return c.conn.Notify("initialized", struct{}{})
+}
+
+// Notify implements Handler.
+func (c *Client) Notify(method string, params json.RawMessage) {
+	if method != "textDocument/publishDiagnostics" {
+		return
+	}
+	var p publishDiagnosticsParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// A versioned publish for an older text is stale: the server is still
+	// catching up with an edit the client has already sent.
+	if doc := c.docs[p.URI]; doc != nil && p.Version != nil && *p.Version < doc.version {
+		return
+	}
+	c.diags[p.URI] = &diagState{diags: p.Diagnostics, fresh: true}
+	close(c.wake)
+	c.wake = make(chan struct{})
+}
+
+// Request implements Handler. Servers ask for configuration, progress tokens
+// and capability registration during startup; each gets the neutral answer.
+func (c *Client) Request(method string, params json.RawMessage) (any, error) {
+	switch method {
+	case "workspace/configuration":
+		var p struct {
+			Items []json.RawMessage `json:"items"`
+		}
+		_ = json.Unmarshal(params, &p)
+		return make([]any, len(p.Items)), nil
+	case "window/workDoneProgress/create", "client/registerCapability", "client/unregisterCapability":
+		return nil, nil
+	case "workspace/workspaceFolders":
+		return []map[string]string{{"uri": PathToURI(c.root), "name": filepath.Base(c.root)}}, nil
+	case "window/showMessageRequest":
+		return nil, nil
+	}
+	return nil, &RPCError{Code: -32601, Message: "method not found: " + method}
+}
+
+// Sync sends the file's current on-disk content to the server: didOpen the
+// first time, a full-text didChange afterwards. An unchanged file is not
+// re-sent, so Sync is cheap to call before every query.
+func (c *Client) Sync(ctx context.Context, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	uri := PathToURI(path)
+	text := string(data)
+
+	c.mu.Lock()
+	doc := c.docs[uri]
+	if doc != nil && doc.text == text {
+		c.mu.Unlock()
+		return nil
+	}
+	if doc == nil {
+		doc = &document{}
+		c.docs[uri] = doc
+	}
+	doc.version++
+	doc.text = text
+	version := doc.version
+	if st := c.diags[uri]; st != nil {
+		st.fresh = false
+	}
+	c.mu.Unlock()
+
+	if version == 1 {
+		return c.conn.Notify("textDocument/didOpen", map[string]any{
+			"textDocument": map[string]any{
+				"uri": uri, "languageId": languageID(path), "version": version, "text": text,
+			},
+		})
+	}
+	if err := c.conn.Notify("textDocument/didChange", map[string]any{
+		"textDocument":   map[string]any{"uri": uri, "version": version},
+		"contentChanges": []map[string]string{{"text": text}},
+	}); err != nil {
+		return err
+	}
+	return c.conn.Notify("textDocument/didSave", map[string]any{
+		"textDocument": map[string]string{"uri": uri},
+	})
+}
+
+// Diagnostics syncs path and returns its diagnostics, waiting up to wait for
+// the server to publish a set that reflects the synced content. If none
+// arrives in time the last known set is returned with fresh=false.
+func (c *Client) Diagnostics(ctx context.Context, path string, wait time.Duration) (diags []Diagnostic, fresh bool, err error) {
+	if err := c.Sync(ctx, path); err != nil {
+		return nil, false, err
+	}
+	uri := PathToURI(path)
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		c.mu.Lock()
+		st, wake := c.diags[uri], c.wake
+		c.mu.Unlock()
+		if st != nil && st.fresh {
+			return st.diags, true, nil
+		}
+		select {
+		case <-wake:
+		case <-timer.C:
+			if st != nil {
+				return st.diags, false, nil
+			}
+			return nil, false, nil
+		case <-c.conn.Done():
+			return nil, false, c.conn.closedErr()
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		}
+	}
+}
+
+// position syncs path and builds the common textDocument/position params.
+func (c *Client) position(ctx context.Context, path string, pos Position) (map[string]any, error) {
+	if err := c.Sync(ctx, path); err != nil {
+		return nil, err
+	}
+	return map[string]any{
+		"textDocument": map[string]string{"uri": PathToURI(path)},
+		"position":     pos,
+	}, nil
+}
+
+// Definition returns where the symbol at pos is defined.
+func (c *Client) Definition(ctx context.Context, path string, pos Position) ([]Location, error) {
+	params, err := c.position(ctx, path, pos)
+	if err != nil {
+		return nil, err
+	}
+	var raw json.RawMessage
+	if err := c.conn.Call(ctx, "textDocument/definition", params, &raw); err != nil {
+		return nil, err
+	}
+	return decodeLocations(raw)
+}
+
+// References returns the uses of the symbol at pos.
+func (c *Client) References(ctx context.Context, path string, pos Position, includeDeclaration bool) ([]Location, error) {
+	params, err := c.position(ctx, path, pos)
+	if err != nil {
+		return nil, err
+	}
+	params["context"] = map[string]bool{"includeDeclaration": includeDeclaration}
+	var locs []Location
+	if err := c.conn.Call(ctx, "textDocument/references", params, &locs); err != nil {
+		return nil, err
+	}
+	return locs, nil
+}
+
+// Hover returns the hover text (type, signature, doc) for the symbol at pos.
+func (c *Client) Hover(ctx context.Context, path string, pos Position) (string, error) {
+	params, err := c.position(ctx, path, pos)
+	if err != nil {
+		return "", err
+	}
+	var h *hoverResult
+	if err := c.conn.Call(ctx, "textDocument/hover", params, &h); err != nil {
+		return "", err
+	}
+	if h == nil {
+		return "", nil
+	}
+	return h.text(), nil
+}
+
+// Rename asks the server for the edits that rename the symbol at pos. The
+// edits are returned, not applied.
+func (c *Client) Rename(ctx context.Context, path string, pos Position, newName string) (WorkspaceEdit, error) {
+	params, err := c.position(ctx, path, pos)
+	if err != nil {
+		return WorkspaceEdit{}, err
+	}
+	params["newName"] = newName
+	var edit WorkspaceEdit
+	if err := c.conn.Call(ctx, "textDocument/rename", params, &edit); err != nil {
+		return WorkspaceEdit{}, err
+	}
+	return edit, nil
+}
+
+// decodeLocations accepts the three definition result shapes: a Location, an
+// array of Locations, or an array of LocationLinks.
+func decodeLocations(raw json.RawMessage) ([]Location, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	if raw[0] == '{' {
+		var l Location
+		if err := json.Unmarshal(raw, &l); err != nil {
+			return nil, err
+		}
+		return []Location{l}, nil
+	}
+	var items []json.RawMessage
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, err
+	}
+	out := make([]Location, 0, len(items))
+	for _, it := range items {
+		var probe struct {
+			URI       string `json:"uri"`
+			TargetURI string `json:"targetUri"`
+		}
+		if err := json.Unmarshal(it, &probe); err != nil {
+			return nil, err
+		}
+		if probe.TargetURI != "" {
+			var link locationLink
+			if err := json.Unmarshal(it, &link); err != nil {
+				return nil, err
+			}
+			out = append(out, Location{URI: link.TargetURI, Range: link.TargetSelectionRange})
+			continue
+		}
+		var l Location
+		if err := json.Unmarshal(it, &l); err != nil {
+			return nil, err
+		}
+		out = append(out, l)
+	}
+	return out, nil
+}
+
+// Alive reports whether the server process is still connected.
+func (c *Client) Alive() bool {
+	select {
+	case <-c.conn.Done():
+		return false
+	default:
+		return true
+	}
+}
+
+// Close shuts the server down politely, killing it if it does not exit in
+// time.
+func (c *Client) Close() error {
+	if !c.Alive() {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	err := c.conn.Call(ctx, "shutdown", nil, nil)
+	if err == nil {
+		_ = c.conn.Notify("exit", nil)
+	}
+	select {
+	case <-c.conn.Done():
+	case <-ctx.Done():
+		c.kill()
+	}
+	if err != nil && !errors.Is(err, ErrClosed) {
+		return err
+	}
+	return nil
+}
+
+func (c *Client) kill() {
+	if c.cmd.Process != nil {
+		_ = c.cmd.Process.Kill()
+	}
+}
@@ -0,0 +1,134 @@
+package lsp_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fpt/klein-cli/internal/lsp"
+	"github.com/fpt/klein-cli/internal/lsp/lsptest"
+)
+
+func TestMain(m *testing.M) {
+	lsptest.MaybeServe()
+	os.Exit(m.Run())
+}
+
+const mainSrc = "package main\n\nfunc helper() int { return 1 }\n\nfunc main() {\n\t_ = helper()\n}\n"
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func startFake(t *testing.T) (*lsp.Client, string) {
+	t.Helper()
+	root := t.TempDir()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	c, err := lsp.Start(ctx, lsptest.Config(".go"), root)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+	return c, root
+}
+
+func TestClientNavigation(t *testing.T) {
+	c, root := startFake(t)
+	ctx := context.Background()
+	path := filepath.Join(root, "main.go")
+	writeFile(t, path, mainSrc)
+
+	// The call site of helper on line 6 (0-based 5), column of "helper".
+	use := lsp.Position{Line: 5, Character: strings.Index("\t_ = helper()", "helper")}
+
+	locs, err := c.Definition(ctx, path, use)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locs) != 1 || locs[0].Range.Start.Line != 2 || locs[0].URI != lsp.PathToURI(path) {
+		t.Errorf("Definition = %+v", locs)
+	}
+
+	refs, err := c.References(ctx, path, use, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refs) != 1 || refs[0].Range.Start.Line != 5 {
+		t.Errorf("References without declaration = %+v", refs)
+	}
+	if refs, _ = c.References(ctx, path, use, true); len(refs) != 2 {
+		t.Errorf("References with declaration = %+v", refs)
+	}
+
+	hover, err := c.Hover(ctx, path, use)
+	if err != nil || !strings.Contains(hover, "func helper()") {
+		t.Errorf("Hover = %q, %v", hover, err)
+	}
+	if hover, err = c.Hover(ctx, path, lsp.Position{Line: 1}); err != nil || hover != "" {
+		t.Errorf("Hover on a blank line = %q, %v", hover, err)
+	}
+
+	edit, err := c.Rename(ctx, path, use, "assist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := edit.Files()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := lsp.ApplyEdits(mainSrc, files[lsp.PathToURI(path)])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := strings.ReplaceAll(mainSrc, "helper", "assist"); got != want {
+		t.Errorf("renamed text:\n%s\nwant:\n%s", got, want)
+	}
+
+	if _, err := c.Rename(ctx, path, lsp.Position{Line: 1}, "x"); err == nil || !strings.Contains(err.Error(), "no identifier") {
+		t.Errorf("rename on a blank line: %v", err)
+	}
+}
+
+func TestClientDiagnosticsFollowEdits(t *testing.T) {
+	c, root := startFake(t)
+	ctx := context.Background()
+	path := filepath.Join(root, "main.go")
+
+	writeFile(t, path, "package main\n\nvar x = BROKEN // TODO\n")
+	diags, fresh, err := c.Diagnostics(ctx, path, 5*time.Second)
+	if err != nil || !fresh {
+		t.Fatalf("Diagnostics: fresh=%v err=%v", fresh, err)
+	}
+	if len(diags) != 2 {
+		t.Fatalf("diagnostics = %+v", diags)
+	}
+	if sig := lsp.Significant(diags); len(sig) != 1 || sig[0].Message != "undefined: BROKEN" {
+		t.Errorf("Significant = %+v", sig)
+	}
+
+	writeFile(t, path, "package main\n\nvar x = 1\n")
+	diags, fresh, err = c.Diagnostics(ctx, path, 5*time.Second)
+	if err != nil || !fresh || len(diags) != 0 {
+		t.Errorf("after fix: %+v fresh=%v err=%v", diags, fresh, err)
+	}
+}
+
+func TestClientCloseStopsServer(t *testing.T) {
+	c, _ := startFake(t)
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if c.Alive() {
+		t.Error("server still connected after Close")
+	}
+	if _, err := c.Hover(context.Background(), "/nonexistent.go", lsp.Position{}); err == nil {
+		t.Error("call after Close should fail")
+	}
+}
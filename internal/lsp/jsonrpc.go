@@ -0,0 +1,247 @@
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrClosed is returned by calls on a connection whose peer has gone away.
+var ErrClosed = errors.New("lsp: connection closed")
+
+// message is the union of JSON-RPC 2.0 requests, notifications and responses.
+// ID is kept raw because servers are free to use strings or numbers for the
+// requests they send us, and the reply must echo it byte for byte.
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+}
+
+// RPCError is a JSON-RPC error object.
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *RPCError) Error() string { return fmt.Sprintf("%s (code %d)", e.Message, e.Code) }
+
+// Handler receives what the peer sends unprompted. Notify is called for
+// notifications; Request for requests, whose return value becomes the reply.
+// Both run on the read loop, so they must not block on the connection.
+type Handler interface {
+	Notify(method string, params json.RawMessage)
+	Request(method string, params json.RawMessage) (any, error)
+}
+
+// Conn is a JSON-RPC 2.0 connection framed with LSP's Content-Length headers.
+type Conn struct {
+	w   io.Writer
+	wmu sync.Mutex
+
+	handler Handler
+
+	mu      sync.Mutex
+	nextID  int64
+	pending map[int64]chan *message
+	err     error // set once the read loop stops
+	done    chan struct{}
+}
+
+// NewConn starts reading from r. Writes go to w. The read loop ends when r
+// returns an error, after which every pending and future call fails.
+func NewConn(r io.Reader, w io.Writer, handler Handler) *Conn {
+	c := &Conn{
+		w:       w,
+		handler: handler,
+		pending: make(map[int64]chan *message),
+		done:    make(chan struct{}),
+	}
+	go c.readLoop(bufio.NewReader(r))
+	return c
+}
+
+// Done is closed when the read loop has stopped.
+func (c *Conn) Done() <-chan struct{} { return c.done }
+
+// Call sends a request and decodes the reply into result (which may be nil).
+func (c *Conn) Call(ctx context.Context, method string, params, result any) error {
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return c.err
+	}
+	c.nextID++
+	id := c.nextID
+	ch := make(chan *message, 1)
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	cleanup := func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}
+
+	raw, err := json.Marshal(params)
+	if err != nil {
+		cleanup()
+		return err
+	}
+	if err := c.write(&message{ID: json.RawMessage(strconv.FormatInt(id, 10)), Method: method, Params: raw}); err != nil {
+		cleanup()
+		return err
+	}
+
+	select {
+	case resp := <-ch:
+		if resp == nil {
+			return c.closedErr()
+		}
+		if resp.Error != nil {
+			return fmt.Errorf("%s: %w", method, resp.Error)
+		}
+		if result == nil || len(resp.Result) == 0 || string(resp.Result) == "null" {
+			return nil
+		}
+		return json.Unmarshal(resp.Result, result)
+	case <-ctx.Done():
+		cleanup()
+		// Tell the server to stop working on it; the reply, if any, is dropped.
+		_ = c.Notify("$/cancelRequest", map[string]int64{"id": id})
+		return ctx.Err()
+	}
+}
+
+// Notify sends a notification.
+func (c *Conn) Notify(method string, params any) error {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return c.write(&message{Method: method, Params: raw})
+}
+
+func (c *Conn) closedErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+func (c *Conn) write(m *message) error {
+	m.JSONRPC = "2.0"
+	body, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if _, err := fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err = c.w.Write(body)
+	return err
+}
+
+func (c *Conn) readLoop(r *bufio.Reader) {
+	var err error
+	for {
+		var m *message
+		if m, err = readMessage(r); err != nil {
+			break
+		}
+		switch {
+		case m.Method != "" && len(m.ID) > 0:
+			c.reply(m)
+		case m.Method != "":
+			if c.handler != nil {
+				c.handler.Notify(m.Method, m.Params)
+			}
+		default:
+			id, convErr := strconv.ParseInt(string(m.ID), 10, 64)
+			if convErr != nil {
+				continue
+			}
+			c.mu.Lock()
+			ch := c.pending[id]
+			delete(c.pending, id)
+			c.mu.Unlock()
+			if ch != nil {
+				ch <- m
+			}
+		}
+	}
+
+	c.mu.Lock()
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		c.err = ErrClosed
+	} else {
+		c.err = fmt.Errorf("%w: %v", ErrClosed, err)
+	}
+	for id, ch := range c.pending {
+		close(ch)
+		delete(c.pending, id)
+	}
+	c.mu.Unlock()
+	close(c.done)
+}
+
+// reply answers a server-to-client request. Without a handler, or for a
+// method it does not know, the reply is a "method not found" error — the
+// protocol requires an answer either way, and a server blocked on one would
+// otherwise stall.
+func (c *Conn) reply(m *message) {
+	var (
+		result any
+		err    error = &RPCError{Code: -32601, Message: "method not found: " + m.Method}
+	)
+	if c.handler != nil {
+		result, err = c.handler.Request(m.Method, m.Params)
+	}
+	resp := &message{ID: m.ID}
+	if err != nil {
+		var rpcErr *RPCError
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &RPCError{Code: -32603, Message: err.Error()}
+		}
+		resp.Error = rpcErr
+	} else {
+		raw, mErr := json.Marshal(result)
+		if mErr != nil {
+			raw = []byte("null")
+		}
+		resp.Result = raw
+	}
+	_ = c.write(resp)
+}
+
+// readMessage reads one Content-Length framed message.
+func readMessage(r *bufio.Reader) (*message, error) {
+	header, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(header.Get("Content-Length")))
+	if err != nil || n <= 0 {
+		return nil, fmt.Errorf("lsp: bad Content-Length %q", header.Get("Content-Length"))
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	var m message
+	if err := json.Unmarshal(body, &m); err != nil {
+		return nil, fmt.Errorf("lsp: malformed message: %w", err)
+	}
+	return &m, nil
+}
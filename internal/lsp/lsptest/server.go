@@ -0,0 +1,287 @@
+// Package lsptest provides a fake language server for tests. It speaks
+// JSON-RPC over stdio like a real one, so tests exercise process launch,
+// framing, document sync and server-initiated requests end to end.
+//
+// The fake understands a tiny Go-like language: "func NAME" declares NAME,
+// every other whole-word occurrence is a use, and a line containing BROKEN
+// produces an error diagnostic (a line containing TODO, an info one).
+//
+// A test binary becomes the server by calling MaybeServe from TestMain; Config
+// returns a ServerConfig that re-executes the test binary in that mode.
+package lsptest
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fpt/klein-cli/internal/lsp"
+)
+
+// EnvVar switches a test binary into fake-server mode.
+const EnvVar = "KLEIN_LSPTEST_SERVE"
+
+// MaybeServe serves on stdin/stdout and exits if the process was started by
+// Config. Call it first thing in TestMain.
+func MaybeServe() {
+	if os.Getenv(EnvVar) != "1" {
+		return
+	}
+	if err := Serve(os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "lsptest:", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// Config returns a ServerConfig launching the current test binary as the fake
+// server for the given extensions.
+func Config(extensions ...string) lsp.ServerConfig {
+	return lsp.ServerConfig{
+		Name:       "fake",
+		Command:    os.Args[0],
+		Env:        []string{EnvVar + "=1"},
+		Extensions: extensions,
+	}
+}
+
+type rpc struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  any             `json:"result,omitempty"`
+	Error   *lsp.RPCError   `json:"error,omitempty"`
+}
+
+type server struct {
+	r    *bufio.Reader
+	w    io.Writer
+	docs map[string]string
+}
+
+// Serve runs the fake server until "exit" or end of input.
+func Serve(in io.Reader, out io.Writer) error {
+	s := &server{r: bufio.NewReader(in), w: out, docs: make(map[string]string)}
+	for {
+		m, err := s.read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if m.Method == "" {
+			continue // a response to one of our own requests
+		}
+		if m.Method == "exit" {
+			return nil
+		}
+		if err := s.handle(m); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *server) read() (*rpc, error) {
+	h, err := textproto.NewReader(s.r).ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(h.Get("Content-Length"))
+	if err != nil {
+		return nil, err
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(s.r, body); err != nil {
+		return nil, err
+	}
+	var m rpc
+	return &m, json.Unmarshal(body, &m)
+}
+
+func (s *server) send(m rpc) error {
+	m.JSONRPC = "2.0"
+	body, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(s.w, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	return err
+}
+
+type posParams struct {
+	TextDocument struct {
+		URI     string `json:"uri"`
+		Text    string `json:"text"`
+		Version int    `json:"version"`
+	} `json:"textDocument"`
+	Position       lsp.Position `json:"position"`
+	ContentChanges []struct {
+		Text string `json:"text"`
+	} `json:"contentChanges"`
+	Context struct {
+		IncludeDeclaration bool `json:"includeDeclaration"`
+	} `json:"context"`
+	NewName string `json:"newName"`
+}
+
+func (s *server) handle(m *rpc) error {
+	var p posParams
+	_ = json.Unmarshal(m.Params, &p)
+	uri := p.TextDocument.URI
+
+	reply := func(result any) error {
+		if result == nil {
+			result = json.RawMessage("null")
+		}
+		return s.send(rpc{ID: m.ID, Result: result})
+	}
+
+	switch m.Method {
+	case "initialize":
+		return reply(map[string]any{"capabilities": map[string]any{
+			"textDocumentSync": 1, "definitionProvider": true, "referencesProvider": true,
+			"hoverProvider": true, "renameProvider": true,
+		}})
+	case "initialized":
+		// Real servers ask for configuration and chat on the log channel
+		// right away; the client must cope with both.
+		if err := s.send(rpc{ID: json.RawMessage(`"cfg-1"`), Method: "workspace/configuration",
+			Params: json.RawMessage(`{"items":[{"section":"fake"}]}`)}); err != nil {
+			return err
+		}
+		return s.send(rpc{Method: "window/logMessage", Params: json.RawMessage(`{"type":3,"message":"ready"}`)})
+	case "textDocument/didOpen":
+		s.docs[uri] = p.TextDocument.Text
+		return s.publish(uri, p.TextDocument.Version)
+	case "textDocument/didChange":
+		if n := len(p.ContentChanges); n > 0 {
+			s.docs[uri] = p.ContentChanges[n-1].Text
+		}
+		return s.publish(uri, p.TextDocument.Version)
+	case "textDocument/didSave", "textDocument/didClose", "$/cancelRequest":
+		return nil
+	case "textDocument/definition":
+		word := s.wordAt(uri, p.Position)
+		var out []lsp.Location
+		for _, l := range s.occurrences(word) {
+			if l.decl {
+				out = append(out, l.Location)
+			}
+		}
+		return reply(out)
+	case "textDocument/references":
+		word := s.wordAt(uri, p.Position)
+		out := []lsp.Location{}
+		for _, l := range s.occurrences(word) {
+			if !l.decl || p.Context.IncludeDeclaration {
+				out = append(out, l.Location)
+			}
+		}
+		return reply(out)
+	case "textDocument/hover":
+		word := s.wordAt(uri, p.Position)
+		if word == "" {
+			return reply(nil)
+		}
+		return reply(map[string]any{"contents": map[string]string{
+			"kind": "markdown", "value": "```go\nfunc " + word + "()\n```",
+		}})
+	case "textDocument/rename":
+		word := s.wordAt(uri, p.Position)
+		if word == "" {
+			return s.send(rpc{ID: m.ID, Error: &lsp.RPCError{Code: -32602, Message: "no identifier at position"}})
+		}
+		changes := map[string][]lsp.TextEdit{}
+		for _, l := range s.occurrences(word) {
+			changes[l.URI] = append(changes[l.URI], lsp.TextEdit{Range: l.Range, NewText: p.NewName})
+		}
+		return reply(map[string]any{"changes": changes})
+	case "shutdown":
+		return reply(nil)
+	}
+	if len(m.ID) > 0 {
+		return s.send(rpc{ID: m.ID, Error: &lsp.RPCError{Code: -32601, Message: "method not found"}})
+	}
+	return nil
+}
+
+func (s *server) publish(uri string, version int) error {
+	diags := []lsp.Diagnostic{}
+	for i, line := range strings.Split(s.docs[uri], "\n") {
+		if c := strings.Index(line, "BROKEN"); c >= 0 {
+			diags = append(diags, lsp.Diagnostic{
+				Range:    lsp.Range{Start: lsp.Position{Line: i, Character: c}, End: lsp.Position{Line: i, Character: c + 6}},
+				Severity: lsp.SeverityError, Source: "fake", Message: "undefined: BROKEN",
+			})
+		}
+		if c := strings.Index(line, "TODO"); c >= 0 {
+			diags = append(diags, lsp.Diagnostic{
+				Range:    lsp.Range{Start: lsp.Position{Line: i, Character: c}, End: lsp.Position{Line: i, Character: c + 4}},
+				Severity: lsp.SeverityInformation, Source: "fake", Message: "todo left in code",
+			})
+		}
+	}
+	params, _ := json.Marshal(map[string]any{"uri": uri, "version": version, "diagnostics": diags})
+	return s.send(rpc{Method: "textDocument/publishDiagnostics", Params: params})
+}
+
+var wordRe = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*`)
+
+// wordAt returns the identifier under pos. The fake only handles ASCII, so
+// UTF-16 offsets and byte offsets coincide.
+func (s *server) wordAt(uri string, pos lsp.Position) string {
+	lines := strings.Split(s.docs[uri], "\n")
+	if pos.Line >= len(lines) {
+		return ""
+	}
+	for _, loc := range wordRe.FindAllStringIndex(lines[pos.Line], -1) {
+		if pos.Character >= loc[0] && pos.Character < loc[1] {
+			return lines[pos.Line][loc[0]:loc[1]]
+		}
+	}
+	return ""
+}
+
+type occurrence struct {
+	lsp.Location
+	decl bool
+}
+
+// occurrences finds word in every open document, in URI then position order.
+func (s *server) occurrences(word string) []occurrence {
+	if word == "" {
+		return nil
+	}
+	uris := make([]string, 0, len(s.docs))
+	for u := range s.docs {
+		uris = append(uris, u)
+	}
+	sort.Strings(uris)
+	var out []occurrence
+	for _, u := range uris {
+		for i, line := range strings.Split(s.docs[u], "\n") {
+			for _, loc := range wordRe.FindAllStringIndex(line, -1) {
+				if line[loc[0]:loc[1]] != word {
+					continue
+				}
+				out = append(out, occurrence{
+					Location: lsp.Location{URI: u, Range: lsp.Range{
+						Start: lsp.Position{Line: i, Character: loc[0]},
+						End:   lsp.Position{Line: i, Character: loc[1]},
+					}},
+					decl: strings.HasSuffix(strings.TrimRight(line[:loc[0]], " "), "func"),
+				})
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,280 @@
+package lsp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultServers are the language servers klein knows how to launch out of the
+// box. Each is used only when its command is on PATH.
+func DefaultServers() []ServerConfig {
+	return []ServerConfig{
+		{Name: "gopls", Command: "gopls", Extensions: []string{".go"}},
+		{Name: "pyright", Command: "pyright-langserver", Args: []string{"--stdio"}, Extensions: []string{".py", ".pyi"}},
+		{
+			Name: "tsserver", Command: "typescript-language-server", Args: []string{"--stdio"},
+			Extensions: []string{".ts", ".tsx", ".mts", ".cts", ".js", ".jsx", ".mjs", ".cjs"},
+		},
+	}
+}
+
+// ErrNoServer is returned for a file no available server handles.
+var ErrNoServer = errors.New("no language server configured for this file type")
+
+// startTimeout bounds the initialize handshake. gopls loading a large module
+// is the slow case; anything beyond this is treated as a failed start.
+const startTimeout = 30 * time.Second
+
+// Manager owns the language servers of one workspace. Servers start lazily on
+// the first request for a file they handle and stay up until Close.
+type Manager struct {
+	root    string
+	servers []ServerConfig
+
+	mu      sync.Mutex
+	clients map[string]*Client // by server name
+	failed  map[string]error   // servers that could not start; not retried
+	lastErr map[string][]Diagnostic
+
+	lookPath func(string) (string, error) // test hook
+}
+
+// NewManager creates a manager for root. servers are tried in order; the
+// first whose extensions match a file handles it.
+func NewManager(root string, servers []ServerConfig) *Manager {
+	if abs, err := filepath.Abs(root); err == nil {
+		root = abs
+	}
+	return &Manager{
+		root:     root,
+		servers:  servers,
+		clients:  make(map[string]*Client),
+		failed:   make(map[string]error),
+		lastErr:  make(map[string][]Diagnostic),
+		lookPath: exec.LookPath,
+	}
+}
+
+// Root returns the workspace root.
+func (m *Manager) Root() string { return m.root }
+
+// serverFor returns the configuration handling path, if any.
+func (m *Manager) serverFor(path string) (ServerConfig, bool) {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, s := range m.servers {
+		for _, e := range s.Extensions {
+			if strings.EqualFold(e, ext) {
+				return s, true
+			}
+		}
+	}
+	return ServerConfig{}, false
+}
+
+// Handles reports whether a server is configured for path and its command
+// can be found. It does not start anything.
+func (m *Manager) Handles(path string) bool {
+	cfg, ok := m.serverFor(path)
+	if !ok {
+		return false
+	}
+	m.mu.Lock()
+	_, failed := m.failed[cfg.Name]
+	m.mu.Unlock()
+	if failed {
+		return false
+	}
+	_, err := m.lookPath(cfg.Command)
+	return err == nil
+}
+
+// Available lists the configured servers whose command is on PATH.
+func (m *Manager) Available() []string {
+	var out []string
+	for _, s := range m.servers {
+		if _, err := m.lookPath(s.Command); err == nil {
+			out = append(out, s.Name)
+		}
+	}
+	return out
+}
+
+// ClientFor returns the running server for path, starting it if needed. A
+// server that failed to start is not retried for the life of the manager;
+// one that died is restarted.
+func (m *Manager) ClientFor(ctx context.Context, path string) (*Client, error) {
+	cfg, ok := m.serverFor(path)
+	if !ok {
+		return nil, fmt.Errorf("%w (%s)", ErrNoServer, filepath.Ext(path))
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if c := m.clients[cfg.Name]; c != nil {
+		if c.Alive() {
+			return c, nil
+		}
+		delete(m.clients, cfg.Name)
+	}
+	if err := m.failed[cfg.Name]; err != nil {
+		return nil, err
+	}
+	command, err := m.lookPath(cfg.Command)
+	if err != nil {
+		err = fmt.Errorf("%s is not installed (%q not found on PATH)", cfg.Name, cfg.Command)
+		m.failed[cfg.Name] = err
+		return nil, err
+	}
+	cfg.Command = command
+
+	startCtx, cancel := context.WithTimeout(ctx, startTimeout)
+	defer cancel()
+	c, err := Start(startCtx, cfg, m.root)
+	if err != nil {
+		// A cancelled caller is not the server's fault; let the next call try.
+		if ctx.Err() == nil {
+			m.failed[cfg.Name] = err
+		}
+		return nil, err
+	}
+	m.clients[cfg.Name] = c
+	return c, nil
+}
+
+// Close shuts down every running server.
+func (m *Manager) Close() {
+	m.mu.Lock()
+	clients := make([]*Client, 0, len(m.clients))
+	for name, c := range m.clients {
+		clients = append(clients, c)
+		delete(m.clients, name)
+	}
+	m.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, c := range clients {
+		wg.Add(1)
+		go func(c *Client) {
+			defer wg.Done()
+			_ = c.Close()
+		}(c)
+	}
+	wg.Wait()
+}
+
+// postEditWait is how long AfterEdit waits for fresh diagnostics. It is short
+// on purpose: the edit already succeeded, and a slow server should cost the
+// turn a little latency at most — the Diagnostics tool can wait longer.
+const postEditWait = 3 * time.Second
+
+// maxReportedDiagnostics caps how many diagnostics one report lists.
+const maxReportedDiagnostics = 20
+
+// AfterEdit syncs a file that was just written and returns a report of its
+// errors and warnings for appending to the edit tool's result, or "" when
+// there is nothing to say (no server, nothing new, or no answer in time).
+// Diagnostics absent from the previous report are marked as new, and a file
+// whose earlier problems are all gone says so.
+func (m *Manager) AfterEdit(ctx context.Context, path string) string {
+	if !m.Handles(path) {
+		return ""
+	}
+	c, err := m.ClientFor(ctx, path)
+	if err != nil {
+		return ""
+	}
+	diags, fresh, err := c.Diagnostics(ctx, path, postEditWait)
+	if err != nil || !fresh {
+		return ""
+	}
+	diags = Significant(diags)
+
+	m.mu.Lock()
+	prev := m.lastErr[path]
+	m.lastErr[path] = diags
+	m.mu.Unlock()
+
+	if len(diags) == 0 {
+		if len(prev) > 0 {
+			return fmt.Sprintf("\n\nLSP (%s): all %d earlier problem(s) in this file are resolved.", c.Name(), len(prev))
+		}
+		return ""
+	}
+	seen := make(map[string]bool, len(prev))
+	for _, d := range prev {
+		seen[diagKey(d)] = true
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n\nLSP diagnostics (%s):\n", c.Name())
+	b.WriteString(FormatDiagnostics(m.root, path, diags, func(d Diagnostic) bool { return !seen[diagKey(d)] }))
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// Significant keeps errors and warnings, most severe first, in position order.
+func Significant(diags []Diagnostic) []Diagnostic {
+	var out []Diagnostic
+	for _, d := range diags {
+		// Severity is optional in the protocol; unset is treated as an error.
+		if d.Severity == 0 || d.Severity <= SeverityWarning {
+			out = append(out, d)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		si, sj := out[i].Severity, out[j].Severity
+		if si == 0 {
+			si = SeverityError
+		}
+		if sj == 0 {
+			sj = SeverityError
+		}
+		if si != sj {
+			return si < sj
+		}
+		if out[i].Range.Start.Line != out[j].Range.Start.Line {
+			return out[i].Range.Start.Line < out[j].Range.Start.Line
+		}
+		return out[i].Range.Start.Character < out[j].Range.Start.Character
+	})
+	return out
+}
+
+func diagKey(d Diagnostic) string {
+	return fmt.Sprintf("%d|%s|%s", d.Severity, d.Source, d.Message)
+}
+
+// FormatDiagnostics renders diagnostics one per line as
+// "path:line:col: severity: message [source]", with 1-based positions and the
+// path relative to root. isNew, when non-nil, marks entries with "(new)".
+func FormatDiagnostics(root, path string, diags []Diagnostic, isNew func(Diagnostic) bool) string {
+	rel := path
+	if r, err := filepath.Rel(root, path); err == nil && !strings.HasPrefix(r, "..") {
+		rel = filepath.ToSlash(r)
+	}
+	var b strings.Builder
+	for i, d := range diags {
+		if i == maxReportedDiagnostics {
+			fmt.Fprintf(&b, "... %d more\n", len(diags)-i)
+			break
+		}
+		sev := d.Severity
+		if sev == 0 {
+			sev = SeverityError
+		}
+		fmt.Fprintf(&b, "%s:%d:%d: %s: %s", rel, d.Range.Start.Line+1, d.Range.Start.Character+1, sev, d.Message)
+		if d.Source != "" {
+			fmt.Fprintf(&b, " [%s]", d.Source)
+		}
+		if isNew != nil && isNew(d) {
+			b.WriteString(" (new)")
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,100 @@
+package lsp_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fpt/klein-cli/internal/lsp"
+	"github.com/fpt/klein-cli/internal/lsp/lsptest"
+)
+
+func TestManagerAfterEdit(t *testing.T) {
+	root := t.TempDir()
+	m := lsp.NewManager(root, []lsp.ServerConfig{lsptest.Config(".go")})
+	t.Cleanup(m.Close)
+	ctx := context.Background()
+	path := filepath.Join(root, "a.go")
+
+	writeFile(t, path, "package a\n")
+	if got := m.AfterEdit(ctx, path); got != "" {
+		t.Errorf("clean file reported: %q", got)
+	}
+
+	writeFile(t, path, "package a\n\nvar x = BROKEN\n")
+	got := m.AfterEdit(ctx, path)
+	if !strings.Contains(got, "LSP diagnostics (fake):\na.go:3:9: error: undefined: BROKEN [fake] (new)") {
+		t.Errorf("first report: %q", got)
+	}
+
+	// Still broken, but no longer new.
+	writeFile(t, path, "package a\n\n// moved\nvar x = BROKEN\n")
+	if got = m.AfterEdit(ctx, path); !strings.HasSuffix(got, "a.go:4:9: error: undefined: BROKEN [fake]") {
+		t.Errorf("second report: %q", got)
+	}
+
+	writeFile(t, path, "package a\n")
+	if got = m.AfterEdit(ctx, path); !strings.Contains(got, "all 1 earlier problem(s) in this file are resolved") {
+		t.Errorf("resolution report: %q", got)
+	}
+
+	if got = m.AfterEdit(ctx, filepath.Join(root, "notes.txt")); got != "" {
+		t.Errorf("unhandled extension reported: %q", got)
+	}
+}
+
+func TestManagerMissingServer(t *testing.T) {
+	m := lsp.NewManager(t.TempDir(), []lsp.ServerConfig{
+		{Name: "ghost", Command: "klein-no-such-language-server", Extensions: []string{".py"}},
+	})
+	if m.Handles("x.py") {
+		t.Error("Handles should be false when the command is missing")
+	}
+	if got := m.Available(); len(got) != 0 {
+		t.Errorf("Available = %v", got)
+	}
+	if _, err := m.ClientFor(context.Background(), "x.py"); err == nil || !strings.Contains(err.Error(), "not installed") {
+		t.Errorf("ClientFor missing server: %v", err)
+	}
+	if _, err := m.ClientFor(context.Background(), "x.rb"); !errors.Is(err, lsp.ErrNoServer) {
+		t.Errorf("ClientFor unconfigured extension: %v", err)
+	}
+}
+
+func TestApplyEdits(t *testing.T) {
+	text := "héllo wörld\nsecond\n"
+	edits := []lsp.TextEdit{
+		// "wörld" starts at UTF-16 offset 6.
+		{Range: lsp.Range{Start: lsp.Position{Line: 0, Character: 6}, End: lsp.Position{Line: 0, Character: 11}}, NewText: "there"},
+		{Range: lsp.Range{Start: lsp.Position{Line: 1, Character: 0}, End: lsp.Position{Line: 1, Character: 0}}, NewText: "a "},
+		{Range: lsp.Range{Start: lsp.Position{Line: 1, Character: 0}, End: lsp.Position{Line: 1, Character: 0}}, NewText: "b "},
+	}
+	got, err := lsp.ApplyEdits(text, edits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "héllo there\na b second\n"; got != want {
+		t.Errorf("ApplyEdits = %q, want %q", got, want)
+	}
+
+	overlap := []lsp.TextEdit{
+		{Range: lsp.Range{Start: lsp.Position{Line: 0, Character: 0}, End: lsp.Position{Line: 0, Character: 5}}},
+		{Range: lsp.Range{Start: lsp.Position{Line: 0, Character: 3}, End: lsp.Position{Line: 0, Character: 7}}},
+	}
+	if _, err := lsp.ApplyEdits(text, overlap); err == nil {
+		t.Error("overlapping edits should fail")
+	}
+}
+
+func TestURIRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dir with space", "ü.go")
+	back, err := lsp.URIToPath(lsp.PathToURI(path))
+	if err != nil || back != path {
+		t.Errorf("round trip %q -> %q (%v)", path, back, err)
+	}
+	if lsp.UTF16Offset("a😀b", len("a😀")) != 3 || lsp.ByteOffset("a😀b", 3) != len("a😀") {
+		t.Error("UTF-16 conversion wrong for a surrogate pair")
+	}
+}
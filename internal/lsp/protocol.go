@@ -0,0 +1,265 @@
+package lsp
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// The subset of the LSP 3.17 protocol the client speaks. Field names follow
+// the specification so the JSON tags are the lower-camel versions of them.
+
+// Position is a zero-based line and UTF-16 code-unit offset.
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// Range is a half-open span.
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// Location is a range inside a document.
+type Location struct {
+	URI   string `json:"uri"`
+	Range Range  `json:"range"`
+}
+
+// locationLink is the richer form some servers return for definitions.
+type locationLink struct {
+	TargetURI            string `json:"targetUri"`
+	TargetSelectionRange Range  `json:"targetSelectionRange"`
+}
+
+// DiagnosticSeverity ranks a diagnostic; lower is more severe.
+type DiagnosticSeverity int
+
+const (
+	SeverityError       DiagnosticSeverity = 1
+	SeverityWarning     DiagnosticSeverity = 2
+	SeverityInformation DiagnosticSeverity = 3
+	SeverityHint        DiagnosticSeverity = 4
+)
+
+func (s DiagnosticSeverity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	case SeverityInformation:
+		return "info"
+	case SeverityHint:
+		return "hint"
+	}
+	return "diagnostic"
+}
+
+// Diagnostic is a compiler error, warning, or lint reported by the server.
+type Diagnostic struct {
+	Range    Range              `json:"range"`
+	Severity DiagnosticSeverity `json:"severity,omitempty"`
+	Code     any                `json:"code,omitempty"`
+	Source   string             `json:"source,omitempty"`
+	Message  string             `json:"message"`
+}
+
+type publishDiagnosticsParams struct {
+	URI         string       `json:"uri"`
+	Version     *int         `json:"version,omitempty"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+// TextEdit replaces Range with NewText.
+type TextEdit struct {
+	Range   Range  `json:"range"`
+	NewText string `json:"newText"`
+}
+
+// WorkspaceEdit is a set of edits across documents. Servers use either the
+// plain Changes map or DocumentChanges; both are accepted.
+type WorkspaceEdit struct {
+	Changes         map[string][]TextEdit `json:"changes,omitempty"`
+	DocumentChanges []documentChange      `json:"documentChanges,omitempty"`
+}
+
+type documentChange struct {
+	TextDocument struct {
+		URI string `json:"uri"`
+	} `json:"textDocument"`
+	Edits []TextEdit `json:"edits"`
+	Kind  string     `json:"kind,omitempty"` // create/rename/delete are not applied
+}
+
+// Files returns the edits grouped by document URI, folding DocumentChanges
+// into the same shape as Changes. Resource operations (file create, rename,
+// delete) are reported as an error: applying half of such an edit would leave
+// the tree broken.
+func (e WorkspaceEdit) Files() (map[string][]TextEdit, error) {
+	out := make(map[string][]TextEdit, len(e.Changes))
+	for uri, edits := range e.Changes {
+		out[uri] = append(out[uri], edits...)
+	}
+	for _, dc := range e.DocumentChanges {
+		if dc.Kind != "" {
+			return nil, fmt.Errorf("rename requires a %s file operation, which is not supported", dc.Kind)
+		}
+		out[dc.TextDocument.URI] = append(out[dc.TextDocument.URI], dc.Edits...)
+	}
+	return out, nil
+}
+
+// hoverResult covers the three content shapes servers send: MarkupContent,
+// a MarkedString, or an array of MarkedStrings.
+type hoverResult struct {
+	Contents any `json:"contents"`
+}
+
+func (h hoverResult) text() string {
+	var parts []string
+	var walk func(v any)
+	walk = func(v any) {
+		switch x := v.(type) {
+		case string:
+			parts = append(parts, x)
+		case map[string]any:
+			if s, ok := x["value"].(string); ok {
+				if lang, ok := x["language"].(string); ok && lang != "" {
+					s = "```" + lang + "\n" + s + "\n```"
+				}
+				parts = append(parts, s)
+			}
+		case []any:
+			for _, e := range x {
+				walk(e)
+			}
+		}
+	}
+	walk(h.Contents)
+	return strings.TrimSpace(strings.Join(parts, "\n\n"))
+}
+
+// PathToURI converts an absolute file path to a file:// URI.
+func PathToURI(path string) string {
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(path)}
+	if !strings.HasPrefix(u.Path, "/") { // Windows drive letter
+		u.Path = "/" + u.Path
+	}
+	return u.String()
+}
+
+// URIToPath converts a file:// URI back to a path.
+func URIToPath(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "file" {
+		return "", fmt.Errorf("not a file URI: %s", uri)
+	}
+	p := u.Path
+	if len(p) >= 3 && p[0] == '/' && p[2] == ':' { // /C:/...
+		p = p[1:]
+	}
+	return filepath.FromSlash(p), nil
+}
+
+// UTF16Offset converts a byte offset within line to the UTF-16 code-unit
+// offset LSP positions use.
+func UTF16Offset(line string, byteOffset int) int {
+	if byteOffset > len(line) {
+		byteOffset = len(line)
+	}
+	n := 0
+	for _, r := range line[:byteOffset] {
+		n += utf16.RuneLen(r)
+	}
+	return n
+}
+
+// ByteOffset converts a UTF-16 code-unit offset within line back to a byte
+// offset, clamping to the end of the line.
+func ByteOffset(line string, utf16Offset int) int {
+	n := 0
+	for i, r := range line {
+		if n >= utf16Offset {
+			return i
+		}
+		n += utf16.RuneLen(r)
+	}
+	return len(line)
+}
+
+// ApplyEdits applies edits to text. Edits must not overlap; they are applied
+// from the end so earlier offsets stay valid.
+func ApplyEdits(text string, edits []TextEdit) (string, error) {
+	lines := strings.SplitAfter(text, "\n")
+	offset := func(p Position) (int, error) {
+		if p.Line > len(lines) || (p.Line == len(lines) && p.Character > 0) {
+			return 0, fmt.Errorf("position %d:%d is past the end of the document", p.Line+1, p.Character)
+		}
+		off := 0
+		for i := 0; i < p.Line; i++ {
+			off += len(lines[i])
+		}
+		if p.Line == len(lines) {
+			return off, nil
+		}
+		return off + ByteOffset(strings.TrimRight(lines[p.Line], "\r\n"), p.Character), nil
+	}
+
+	type span struct {
+		start, end int
+		text       string
+	}
+	spans := make([]span, 0, len(edits))
+	for _, e := range edits {
+		s, err := offset(e.Range.Start)
+		if err != nil {
+			return "", err
+		}
+		en, err := offset(e.Range.End)
+		if err != nil {
+			return "", err
+		}
+		if en < s {
+			return "", fmt.Errorf("inverted edit range at %d:%d", e.Range.Start.Line+1, e.Range.Start.Character)
+		}
+		spans = append(spans, span{s, en, e.NewText})
+	}
+	// Apply back to front. Inserts at the same position must land in array
+	// order, so among equal starts the later edit goes first.
+	order := make([]int, len(spans))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool {
+		if spans[order[a]].start != spans[order[b]].start {
+			return spans[order[a]].start > spans[order[b]].start
+		}
+		return order[a] > order[b]
+	})
+	sorted := make([]span, len(spans))
+	for i, idx := range order {
+		sorted[i] = spans[idx]
+	}
+	spans = sorted
+	for i := 1; i < len(spans); i++ {
+		if spans[i].end > spans[i-1].start {
+			return "", fmt.Errorf("overlapping edits")
+		}
+	}
+	for _, sp := range spans {
+		text = text[:sp.start] + sp.text + text[sp.end:]
+	}
+	if !utf8.ValidString(text) {
+		return "", fmt.Errorf("edit split a multi-byte character")
+	}
+	return text, nil
+}
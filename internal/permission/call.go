@@ -0,0 +1,107 @@
+package permission
+
+import (
+	"fmt"
+	"strings"
+)
+
+// mcpPrefix marks a server-qualified MCP tool name: "mcp:<server>/<tool>".
+const mcpPrefix = "mcp:"
+
+// primaryArgs names the argument each built-in tool is matched on by a rule's
+// Pattern. Tools not listed have no primary argument; their rules match on
+// Args alone.
+var primaryArgs = map[string]string{
+	"Write":     "file_path",
+	"Edit":      "file_path",
+	"MultiEdit": "file_path", // one per edit
+	"Rename":    "file_path",
+	"Bash":      "command",
+}
+
+// Call is a tool invocation as the rules see it.
+type Call struct {
+	Tool    string         // tool name as the model called it
+	Server  string         // MCP server providing the tool; "" for built-ins
+	Args    map[string]any // raw arguments
+	Primary []string       // values Pattern is matched against
+}
+
+// NewCall describes a tool call for rule matching, filling Primary from the
+// tool's primary argument.
+func NewCall(tool, server string, args map[string]any) Call {
+	c := Call{Tool: tool, Server: server, Args: args}
+	if name, ok := primaryArgs[tool]; ok {
+		c.Primary = c.ArgValues(name)
+	}
+	return c
+}
+
+// QualifiedName returns "mcp:<server>/<tool>" for MCP tools and the bare tool
+// name otherwise.
+func (c Call) QualifiedName() string {
+	if c.Server == "" {
+		return c.Tool
+	}
+	return mcpPrefix + c.Server + "/" + c.Tool
+}
+
+// ArgValues returns every value of the named argument, rendered as strings.
+//
+// A top-level argument is used when present. Otherwise arrays of objects are
+// searched one level down, which is how MultiEdit carries a file_path per
+// edit. Arrays of scalars yield one value per element.
+func (c Call) ArgValues(name string) []string {
+	if v, ok := c.Args[name]; ok {
+		return flattenArg(v)
+	}
+	var out []string
+	for _, v := range c.Args {
+		items, ok := v.([]any)
+		if !ok {
+			continue
+		}
+		for _, item := range items {
+			if obj, ok := item.(map[string]any); ok {
+				if v, ok := obj[name]; ok {
+					out = append(out, flattenArg(v)...)
+				}
+			}
+		}
+	}
+	return out
+}
+
+// flattenArg renders an argument value as the strings a matcher sees.
+func flattenArg(v any) []string {
+	switch t := v.(type) {
+	case nil:
+		return nil
+	case string:
+		return []string{t}
+	case []string:
+		return t
+	case []any:
+		var out []string
+		for _, item := range t {
+			out = append(out, flattenArg(item)...)
+		}
+		return out
+	default:
+		return []string{fmt.Sprint(t)}
+	}
+}
+
+// ParseToolName splits a rule-style tool name into tool and server, so
+// "mcp:github/create_issue" describes the github server's create_issue.
+func ParseToolName(name string) (tool, server string) {
+	rest, ok := strings.CutPrefix(name, mcpPrefix)
+	if !ok {
+		return name, ""
+	}
+	server, tool, ok = strings.Cut(rest, "/")
+	if !ok {
+		return name, "" // no tool part; matches nothing
+	}
+	return tool, server
+}
@@ -0,0 +1,183 @@
+package permission
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Scope names one of the three rule files.
+type Scope string
+
+const (
+	ScopeLocal   Scope = "local"   // {workingDir}/.klein/permissions.local.json
+	ScopeProject Scope = "project" // {workingDir}/.klein/permissions.json
+	ScopeUser    Scope = "user"    // ~/.klein/permissions.json
+)
+
+// Scopes lists the scopes in evaluation order, highest priority first.
+var Scopes = []Scope{ScopeLocal, ScopeProject, ScopeUser}
+
+// ParseScope accepts a scope name as typed on the command line.
+func ParseScope(s string) (Scope, error) {
+	for _, sc := range Scopes {
+		if string(sc) == s {
+			return sc, nil
+		}
+	}
+	return "", fmt.Errorf("unknown scope %q (want local, project or user)", s)
+}
+
+// ScopePath returns the rule file for scope. The user scope ignores
+// workingDir; it is "" when the home directory cannot be determined.
+func ScopePath(scope Scope, workingDir string) string {
+	switch scope {
+	case ScopeLocal:
+		return filepath.Join(workingDir, ".klein", "permissions.local.json")
+	case ScopeProject:
+		return filepath.Join(workingDir, ".klein", "permissions.json")
+	case ScopeUser:
+		return userPermissionsPath()
+	default:
+		return ""
+	}
+}
+
+// LoadFile reads the rules in one file, tagging each with its Source. A
+// missing file holds no rules and is not an error; malformed JSON is.
+func LoadFile(path string) ([]PermissionRule, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path) //nolint:gosec // a permissions file klein owns
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var f ruleFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	for i := range f.Rules {
+		f.Rules[i].Source = path
+		f.Rules[i].compile()
+	}
+	return f.Rules, nil
+}
+
+// AddRule appends rule to the file at path, creating it (and its directory)
+// if necessary.
+func AddRule(path string, rule PermissionRule) error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	raw, err := json.Marshal(rule)
+	if err != nil {
+		return err
+	}
+	return editFile(path, func(rules []json.RawMessage) ([]json.RawMessage, error) {
+		return append(rules, raw), nil
+	})
+}
+
+// RemoveRule deletes the rule at index (0-based, in file order) from the file
+// at path and returns it.
+func RemoveRule(path string, index int) (PermissionRule, error) {
+	var removed PermissionRule
+	err := editFile(path, func(rules []json.RawMessage) ([]json.RawMessage, error) {
+		if index < 0 || index >= len(rules) {
+			return nil, fmt.Errorf("no rule #%d in %s (it has %d)", index+1, path, len(rules))
+		}
+		if err := json.Unmarshal(rules[index], &removed); err != nil {
+			return nil, err
+		}
+		return append(rules[:index], rules[index+1:]...), nil
+	})
+	return removed, err
+}
+
+// editFile rewrites the rules array of the file at path.
+//
+// Everything is carried through as raw JSON: other top-level keys, and fields
+// of untouched rules this version does not know about, survive the edit. Only
+// the rules list itself is re-rendered.
+func editFile(path string, edit func([]json.RawMessage) ([]json.RawMessage, error)) error {
+	if path == "" {
+		return errors.New("no permissions file path (home directory unknown?)")
+	}
+	doc := map[string]json.RawMessage{}
+	data, err := os.ReadFile(path) //nolint:gosec // a permissions file klein owns
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("parsing %s: %w", path, err)
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return err
+	}
+
+	var rules []json.RawMessage
+	if raw, ok := doc["rules"]; ok {
+		if err := json.Unmarshal(raw, &rules); err != nil {
+			return fmt.Errorf("parsing %s rules: %w", path, err)
+		}
+	}
+	rules, err = edit(rules)
+	if err != nil {
+		return err
+	}
+	if rules == nil {
+		rules = []json.RawMessage{}
+	}
+	encoded, err := json.Marshal(rules)
+	if err != nil {
+		return err
+	}
+	doc["rules"] = encoded
+
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(out, '\n'), 0600)
+}
+
+// Explanation reports how a hypothetical call would be decided.
+type Explanation struct {
+	Rule    PermissionRule // the deciding rule; zero when Matched is false
+	Index   int            // 0-based position of Rule within its Source file
+	Matched bool
+	Expired []PermissionRule // rules that would have matched but have expired
+}
+
+// Explain evaluates call against the project's rule files the way the agent
+// does, and reports which rule decided it.
+func Explain(workingDir string, call Call, now time.Time) (Explanation, error) {
+	var exp Explanation
+	for _, scope := range Scopes {
+		rules, err := LoadFile(ScopePath(scope, workingDir))
+		if err != nil {
+			return exp, err
+		}
+		for i, r := range rules {
+			if !r.Matches(call) {
+				continue
+			}
+			if r.Expired(now) {
+				exp.Expired = append(exp.Expired, r)
+				continue
+			}
+			exp.Rule, exp.Index, exp.Matched = r, i, true
+			return exp, nil
+		}
+	}
+	return exp, nil
+}
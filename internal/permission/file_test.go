@@ -0,0 +1,133 @@
+package permission
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAddRule_PreservesOtherEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "permissions.json")
+	writeJSON(t, path, `{
+		"comment": "team rules",
+		"rules": [{"tool":"Edit","behavior":"allow","note":"kept verbatim"}]
+	}`)
+
+	if err := AddRule(path, PermissionRule{Tool: "Bash", Pattern: "go test *", Behavior: RuleAllow}); err != nil {
+		t.Fatalf("AddRule: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("rewritten file is not JSON: %v", err)
+	}
+	if string(doc["comment"]) != `"team rules"` {
+		t.Errorf("top-level key lost: %s", data)
+	}
+	if !strings.Contains(string(data), `"note": "kept verbatim"`) {
+		t.Errorf("unknown rule field lost: %s", data)
+	}
+	rules, err := LoadFile(path)
+	if err != nil || len(rules) != 2 || rules[1].Tool != "Bash" {
+		t.Fatalf("LoadFile = %+v, %v", rules, err)
+	}
+	if rules[0].Source != path {
+		t.Errorf("Source = %q, want %q", rules[0].Source, path)
+	}
+}
+
+func TestAddRule_RejectsInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "permissions.json")
+	if err := AddRule(path, PermissionRule{Tool: "Bash", Behavior: "sometimes"}); err == nil {
+		t.Error("expected an error for an unknown behavior")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("an invalid rule must not create the file")
+	}
+}
+
+func TestRemoveRule(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "permissions.json")
+	for _, tool := range []string{"Write", "Edit", "Bash"} {
+		if err := AddRule(path, PermissionRule{Tool: tool, Behavior: RuleAllow}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	removed, err := RemoveRule(path, 1)
+	if err != nil || removed.Tool != "Edit" {
+		t.Fatalf("RemoveRule = %+v, %v", removed, err)
+	}
+	rules, _ := LoadFile(path)
+	if len(rules) != 2 || rules[0].Tool != "Write" || rules[1].Tool != "Bash" {
+		t.Errorf("remaining rules = %+v", rules)
+	}
+	if _, err := RemoveRule(path, 5); err == nil {
+		t.Error("expected an error for an out-of-range index")
+	}
+}
+
+func TestLoadFile_MissingIsEmpty(t *testing.T) {
+	rules, err := LoadFile(filepath.Join(t.TempDir(), "nope.json"))
+	if err != nil || rules != nil {
+		t.Errorf("LoadFile(missing) = %v, %v", rules, err)
+	}
+}
+
+// Loaded regex matchers are compiled up front, and a hand-written matcher
+// with neither glob nor regex matches nothing instead of everything.
+func TestLoadFile_ArgMatchers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "permissions.json")
+	data := `{"rules": [
+  {"tool": "Write", "args": [{"name": "file_path"}], "behavior": "allow"},
+  {"tool": "Bash", "args": [{"name": "command", "regex": "^git push( |$)"}], "behavior": "ask"}
+]}`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rules, err := LoadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs := &RuleSet{Rules: rules}
+	if _, ok := rs.Match(NewCall("Write", "", map[string]any{"file_path": ".env"})); ok {
+		t.Error("an empty matcher matched")
+	}
+	if rule, ok := rs.Match(NewCall("Bash", "", map[string]any{"command": "git push origin"})); !ok || rule.Behavior != RuleAsk {
+		t.Errorf("regex rule: %v %v", rule, ok)
+	}
+}
+
+func TestExplain_ReportsDecidingRuleAndExpired(t *testing.T) {
+	dir := t.TempDir()
+	past := time.Now().Add(-time.Hour)
+	if err := AddRule(ScopePath(ScopeLocal, dir), PermissionRule{Tool: "Bash", Behavior: RuleAllow, Expires: &past}); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddRule(ScopePath(ScopeProject, dir), PermissionRule{Tool: "Write", Behavior: RuleAllow}); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddRule(ScopePath(ScopeProject, dir), PermissionRule{Tool: "Bash", Pattern: "rm *", Behavior: RuleDeny}); err != nil {
+		t.Fatal(err)
+	}
+
+	exp, err := Explain(dir, NewCall("Bash", "", map[string]any{"command": "rm -rf build"}), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exp.Matched || exp.Rule.Behavior != RuleDeny || exp.Index != 1 {
+		t.Errorf("Explain = %+v, want the project deny rule at index 1", exp)
+	}
+	if exp.Rule.Source != ScopePath(ScopeProject, dir) {
+		t.Errorf("Source = %q", exp.Rule.Source)
+	}
+	if len(exp.Expired) != 1 {
+		t.Errorf("expected the expired local rule to be reported, got %+v", exp.Expired)
+	}
+}
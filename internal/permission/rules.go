@@ -12,10 +12,13 @@
 package permission
 
 import (
-	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"time"
 )
 
 // RuleBehavior is the outcome when a rule matches a tool call.
@@ -24,14 +27,26 @@ type RuleBehavior string
 const (
 	RuleAllow RuleBehavior = "allow"
 	RuleDeny  RuleBehavior = "deny"
+	// RuleAsk always puts the call in front of a human, even when a later rule
+	// (or the non-interactive pre-approval) would have let it through.
+	RuleAsk RuleBehavior = "ask"
 )
 
-// PermissionRule describes a single allow or deny entry.
+// Valid reports whether b is one of the known behaviors.
+func (b RuleBehavior) Valid() bool {
+	return b == RuleAllow || b == RuleDeny || b == RuleAsk
+}
+
+// PermissionRule describes a single allow, deny or ask entry.
 //
-// Tool is the tool name (e.g. "Write", "Bash").
+// Tool is the tool name (e.g. "Write", "Bash"). MCP tools are named
+// "mcp:<server>/<tool>", and the Tool field accepts a glob there, so
+// "mcp:github/*" covers every tool of the github server.
 // Pattern is an optional glob matched against the tool's primary argument
-// (file path for Write/Edit, command string for Bash).
-// An empty Pattern matches every invocation of the tool.
+// (file path for Write/Edit, every edited path for MultiEdit, command string
+// for Bash). An empty Pattern matches every invocation of the tool.
+// Args narrows the rule further by named argument; all of them must match.
+// Expires, when set, retires the rule at that instant.
 //
 // Pattern syntax:
 //   - ""         — matches all calls to this tool
@@ -41,7 +56,28 @@ const (
 type PermissionRule struct {
 	Tool     string       `json:"tool"`
 	Pattern  string       `json:"pattern,omitempty"`
+	Args     []ArgMatcher `json:"args,omitempty"`
 	Behavior RuleBehavior `json:"behavior"`
+	Expires  *time.Time   `json:"expires,omitempty"`
+
+	// Source is the file the rule was loaded from ("" for in-memory rules).
+	// It is reported by `klein permissions explain` and never written back.
+	Source string `json:"-"`
+}
+
+// ArgMatcher matches one named tool argument by glob or regular expression.
+// Exactly one of Glob and Regex should be set; Glob uses the Pattern syntax.
+//
+// An argument that appears more than once — each edit's file_path in a
+// MultiEdit call — is matched value by value. An allow rule needs every value
+// to match, so allowing src/** never lets an edit outside src/ ride along; a
+// deny or ask rule fires when any value matches.
+type ArgMatcher struct {
+	Name  string `json:"name"`
+	Glob  string `json:"glob,omitempty"`
+	Regex string `json:"regex,omitempty"`
+
+	re *regexp.Regexp // Regex, compiled when the rule is loaded
 }
 
 // ruleFile is the on-disk JSON format.
@@ -58,18 +94,146 @@ type RuleSet struct {
 // Returns (behavior, true) for the first matching rule, or ("", false) if
 // no rule matches (caller should fall through to the interactive dialog).
 func (rs *RuleSet) Check(toolName, arg string) (RuleBehavior, bool) {
-	if rs == nil {
+	rule, ok := rs.Match(Call{Tool: toolName, Primary: []string{arg}})
+	if !ok {
 		return "", false
 	}
-	for _, r := range rs.Rules {
-		if r.Tool != toolName {
+	return rule.Behavior, true
+}
+
+// Match returns the first unexpired rule that matches call.
+func (rs *RuleSet) Match(call Call) (PermissionRule, bool) {
+	i := rs.index(call, time.Now())
+	if i < 0 {
+		return PermissionRule{}, false
+	}
+	return rs.Rules[i], true
+}
+
+// index returns the position of the first rule matching call at now, or -1.
+func (rs *RuleSet) index(call Call, now time.Time) int {
+	if rs == nil {
+		return -1
+	}
+	for i, r := range rs.Rules {
+		if r.Expired(now) {
 			continue
 		}
-		if matchPattern(r.Pattern, arg) {
-			return r.Behavior, true
+		if r.Matches(call) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Expired reports whether the rule has an expiry at or before now.
+func (r PermissionRule) Expired(now time.Time) bool {
+	return r.Expires != nil && !now.Before(*r.Expires)
+}
+
+// Matches reports whether the rule applies to call, ignoring expiry.
+func (r PermissionRule) Matches(call Call) bool {
+	if !matchTool(r.Tool, call) {
+		return false
+	}
+	all := r.Behavior == RuleAllow
+
+	primary := call.Primary
+	if len(primary) == 0 {
+		primary = []string{""}
+	}
+	if !matchValues(primary, all, func(v string) bool { return matchPattern(r.Pattern, v) }) {
+		return false
+	}
+	for _, m := range r.Args {
+		values := call.ArgValues(m.Name)
+		if len(values) == 0 {
+			return false // the rule is about an argument this call does not have
+		}
+		if !matchValues(values, all, m.match) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchTool compares a rule's Tool against the call. Built-in tools compare by
+// name; "mcp:" rules are globs over the server-qualified name.
+func matchTool(ruleTool string, call Call) bool {
+	if strings.HasPrefix(ruleTool, mcpPrefix) {
+		if call.Server == "" {
+			return false
+		}
+		return matchPattern(ruleTool, call.QualifiedName())
+	}
+	return ruleTool == call.Tool
+}
+
+// matchValues applies match to each value: all of them must match when all is
+// set, otherwise any one will do.
+func matchValues(values []string, all bool, match func(string) bool) bool {
+	for _, v := range values {
+		ok := match(v)
+		if all && !ok {
+			return false
+		}
+		if !all && ok {
+			return true
+		}
+	}
+	return all
+}
+
+// match reports whether value satisfies the matcher. A malformed regex or an
+// empty matcher never matches; Validate reports both when the rule is
+// written.
+func (m ArgMatcher) match(value string) bool {
+	if m.Regex != "" {
+		return m.re != nil && m.re.MatchString(value)
+	}
+	if m.Glob == "" {
+		return false
+	}
+	return matchPattern(m.Glob, value)
+}
+
+// compile prepares the rule's regular expressions so matching does not
+// recompile them per call. It leaves a malformed one uncompiled.
+func (r *PermissionRule) compile() {
+	for i := range r.Args {
+		if r.Args[i].Regex != "" {
+			r.Args[i].re, _ = regexp.Compile(r.Args[i].Regex)
+		}
+	}
+}
+
+// Validate reports a rule that can never do what its author meant: an unknown
+// behavior, a missing tool, or an argument matcher that is empty or will not
+// compile.
+func (r PermissionRule) Validate() error {
+	if r.Tool == "" {
+		return errors.New("rule has no tool")
+	}
+	if !r.Behavior.Valid() {
+		return fmt.Errorf("unknown behavior %q (want allow, deny or ask)", r.Behavior)
+	}
+	for _, m := range r.Args {
+		if m.Name == "" {
+			return errors.New("argument matcher has no name")
+		}
+		if m.Glob != "" && m.Regex != "" {
+			return fmt.Errorf("argument %q sets both glob and regex", m.Name)
+		}
+		if m.Glob == "" && m.Regex == "" {
+			return fmt.Errorf("argument %q has neither glob nor regex", m.Name)
+		}
+		if m.Regex != "" {
+			if _, err := regexp.Compile(m.Regex); err != nil {
+				return fmt.Errorf("argument %q: %w", m.Name, err)
+			}
 		}
 	}
-	return "", false
+	return nil
 }
 
 // matchPattern reports whether value matches pattern.
@@ -114,18 +278,12 @@ func matchPattern(pattern, value string) bool {
 // given working directory.  Missing files are silently ignored.
 // Higher-priority rules are placed first so Check finds them first.
 func LoadForProject(workingDir string) *RuleSet {
-	// Load each source; ignore errors (missing file is fine).
-	userRules := loadFileSilent(userPermissionsPath())
-	projectRules := loadFileSilent(filepath.Join(workingDir, ".klein", "permissions.json"))
-	localRules := loadFileSilent(filepath.Join(workingDir, ".klein", "permissions.local.json"))
-
 	// Merge: local (highest) → project → user (lowest).
 	// first-match-wins evaluation means highest-priority first.
-	merged := make([]PermissionRule, 0, len(localRules)+len(projectRules)+len(userRules))
-	merged = append(merged, localRules...)
-	merged = append(merged, projectRules...)
-	merged = append(merged, userRules...)
-
+	var merged []PermissionRule
+	for _, scope := range Scopes {
+		merged = append(merged, loadFileSilent(ScopePath(scope, workingDir))...)
+	}
 	return &RuleSet{Rules: merged}
 }
 
@@ -141,31 +299,14 @@ func userPermissionsPath() string {
 // AppendToProjectFile appends rule to {workingDir}/.klein/permissions.json,
 // creating the file (and directory) if necessary.
 func AppendToProjectFile(workingDir string, rule PermissionRule) error {
-	path := filepath.Join(workingDir, ".klein", "permissions.json")
-	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
-		return err
-	}
-	existing := loadFileSilent(path)
-	existing = append(existing, rule)
-	data, err := json.MarshalIndent(ruleFile{Rules: existing}, "", "  ")
-	if err != nil {
-		return err
-	}
-	return os.WriteFile(path, append(data, '\n'), 0600)
+	return AddRule(ScopePath(ScopeProject, workingDir), rule)
 }
 
 // loadFileSilent loads rules from a JSON file, returning nil on any error.
 func loadFileSilent(path string) []PermissionRule {
-	if path == "" {
-		return nil
-	}
-	data, err := os.ReadFile(path)
+	rules, err := LoadFile(path)
 	if err != nil {
-		return nil // file absent or unreadable — not an error
-	}
-	var f ruleFile
-	if err := json.Unmarshal(data, &f); err != nil {
 		return nil // malformed JSON — silent skip to avoid breaking the agent
 	}
-	return f.Rules
+	return rules
 }
@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 // ---- matchPattern ----
@@ -297,3 +298,140 @@ func TestAppendToProjectFile_ExistingFilePreserved(t *testing.T) {
 		t.Error("new Write rule not found")
 	}
 }
+
+// ---- Call / Match ----
+
+func multiEditCall(paths ...string) Call {
+	edits := make([]any, len(paths))
+	for i, p := range paths {
+		edits[i] = map[string]any{"file_path": p, "old_string": "a", "new_string": "b"}
+	}
+	return NewCall("MultiEdit", "", map[string]any{"edits": edits})
+}
+
+func TestNewCall_MultiEditPrimaryIsEveryPath(t *testing.T) {
+	c := multiEditCall("src/a.go", "vendor/b.go")
+	if len(c.Primary) != 2 || c.Primary[0] != "src/a.go" || c.Primary[1] != "vendor/b.go" {
+		t.Errorf("Primary = %v, want both edited paths", c.Primary)
+	}
+}
+
+func TestMatch_MultiEditAllowNeedsEveryPath(t *testing.T) {
+	rs := &RuleSet{Rules: []PermissionRule{
+		{Tool: "MultiEdit", Pattern: "src/**", Behavior: RuleAllow},
+	}}
+	if _, ok := rs.Match(multiEditCall("src/a.go", "src/b.go")); !ok {
+		t.Error("allow should match when every path is inside src/")
+	}
+	if _, ok := rs.Match(multiEditCall("src/a.go", "vendor/b.go")); ok {
+		t.Error("allow must not match when one edit is outside src/")
+	}
+}
+
+func TestMatch_MultiEditDenyFiresOnAnyPath(t *testing.T) {
+	rs := &RuleSet{Rules: []PermissionRule{
+		{Tool: "MultiEdit", Args: []ArgMatcher{{Name: "file_path", Glob: "vendor/**"}}, Behavior: RuleDeny},
+	}}
+	rule, ok := rs.Match(multiEditCall("src/a.go", "vendor/b.go"))
+	if !ok || rule.Behavior != RuleDeny {
+		t.Errorf("deny should fire when any edited path is under vendor/, got %v %v", rule, ok)
+	}
+	if _, ok := rs.Match(multiEditCall("src/a.go")); ok {
+		t.Error("deny should not fire for edits outside vendor/")
+	}
+}
+
+func TestMatch_ArgRegex(t *testing.T) {
+	rs := &RuleSet{Rules: []PermissionRule{
+		{Tool: "Bash", Args: []ArgMatcher{{Name: "command", Regex: `^git push( |$)`}}, Behavior: RuleAsk},
+	}}
+	rs.Rules[0].compile()
+	cases := map[string]bool{
+		"git push":             true,
+		"git push origin main": true,
+		"git pushx":            false,
+		"echo git push":        false,
+	}
+	for cmd, want := range cases {
+		_, got := rs.Match(NewCall("Bash", "", map[string]any{"command": cmd}))
+		if got != want {
+			t.Errorf("Match(%q) = %v, want %v", cmd, got, want)
+		}
+	}
+}
+
+func TestMatch_ArgMissingDoesNotMatch(t *testing.T) {
+	rs := &RuleSet{Rules: []PermissionRule{
+		{Tool: "WebFetch", Args: []ArgMatcher{{Name: "url", Glob: "https://internal*"}}, Behavior: RuleDeny},
+	}}
+	if _, ok := rs.Match(NewCall("WebFetch", "", map[string]any{"prompt": "x"})); ok {
+		t.Error("a rule about an absent argument must not match")
+	}
+}
+
+func TestMatch_MCPServerScoping(t *testing.T) {
+	rs := &RuleSet{Rules: []PermissionRule{
+		{Tool: "mcp:github/delete_*", Behavior: RuleDeny},
+		{Tool: "mcp:github/*", Behavior: RuleAsk},
+	}}
+	rule, ok := rs.Match(NewCall("delete_repo", "github", nil))
+	if !ok || rule.Behavior != RuleDeny {
+		t.Errorf("delete_repo: got %v %v, want deny", rule.Behavior, ok)
+	}
+	rule, ok = rs.Match(NewCall("create_issue", "github", nil))
+	if !ok || rule.Behavior != RuleAsk {
+		t.Errorf("create_issue: got %v %v, want ask", rule.Behavior, ok)
+	}
+	if _, ok := rs.Match(NewCall("create_issue", "gitlab", nil)); ok {
+		t.Error("a github rule must not match another server's tool")
+	}
+	if _, ok := rs.Match(NewCall("create_issue", "", nil)); ok {
+		t.Error("an mcp: rule must not match a built-in tool of the same name")
+	}
+}
+
+func TestMatch_ExpiredRuleIsSkipped(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	rs := &RuleSet{Rules: []PermissionRule{
+		{Tool: "Bash", Behavior: RuleAllow, Expires: &past},
+		{Tool: "Bash", Behavior: RuleDeny, Expires: &future},
+	}}
+	rule, ok := rs.Match(NewCall("Bash", "", map[string]any{"command": "ls"}))
+	if !ok || rule.Behavior != RuleDeny {
+		t.Errorf("expired allow should be skipped in favour of the live deny, got %v %v", rule.Behavior, ok)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		rule PermissionRule
+		ok   bool
+	}{
+		{PermissionRule{Tool: "Bash", Behavior: RuleAsk}, true},
+		{PermissionRule{Tool: "Bash", Behavior: "maybe"}, false},
+		{PermissionRule{Behavior: RuleAllow}, false},
+		{PermissionRule{Tool: "Bash", Behavior: RuleDeny, Args: []ArgMatcher{{Name: "command", Regex: "("}}}, false},
+		{PermissionRule{Tool: "Bash", Behavior: RuleDeny, Args: []ArgMatcher{{Name: "command", Glob: "a", Regex: "b"}}}, false},
+		{PermissionRule{Tool: "Write", Behavior: RuleAllow, Args: []ArgMatcher{{Name: "file_path"}}}, false},
+	}
+	for _, c := range cases {
+		if err := c.rule.Validate(); (err == nil) != c.ok {
+			t.Errorf("Validate(%+v) = %v, want ok=%v", c.rule, err, c.ok)
+		}
+	}
+}
+
+func TestParseToolName(t *testing.T) {
+	cases := []struct{ in, tool, server string }{
+		{"Bash", "Bash", ""},
+		{"mcp:github/create_issue", "create_issue", "github"},
+		{"mcp:github", "mcp:github", ""},
+	}
+	for _, c := range cases {
+		tool, server := ParseToolName(c.in)
+		if tool != c.tool || server != c.server {
+			t.Errorf("ParseToolName(%q) = (%q, %q), want (%q, %q)", c.in, tool, server, c.tool, c.server)
+		}
+	}
+}
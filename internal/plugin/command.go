@@ -16,8 +16,11 @@ type commandFrontmatter struct {
 	ArgumentHint string `yaml:"argument-hint"`
 	// allowed-tools accepts either a YAML sequence (["Task","Bash"]) or a
 	// comma-separated string. We decode into any and normalise later.
-	AllowedTools any    `yaml:"allowed-tools"`
-	Model        string `yaml:"model"`
+	AllowedTools    any    `yaml:"allowed-tools"`
+	DisallowedTools any    `yaml:"disallowed-tools"`
+	Model           string `yaml:"model"`
+	Backend         string `yaml:"backend"`
+	Effort          string `yaml:"effort"`
 }
 
 // ParseCommandMD parses a commands/*.md file. The bare command name is the
@@ -41,7 +44,10 @@ func ParseCommandMD(data []byte, sourcePath, pluginName string) (*Command, error
 		cmd.Description = fm.Description
 		cmd.ArgumentHint = fm.ArgumentHint
 		cmd.AllowedTools = parseStringList(fm.AllowedTools)
+		cmd.DisallowedTools = parseStringList(fm.DisallowedTools)
 		cmd.Model = fm.Model
+		cmd.Backend = fm.Backend
+		cmd.Effort = fm.Effort
 	}
 
 	return cmd, nil
@@ -0,0 +1,114 @@
+package plugin
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/fpt/klein-cli/pkg/agent/domain"
+)
+
+// Inventory is what a set of plugins adds to a session. It is what the trust
+// prompt shows before a plugin is installed or updated: the code a plugin
+// brings in is its MCP servers' commands and whatever its commands, agents
+// and skills tell the model to do.
+type Inventory struct {
+	Commands   []string // "/<plugin>:<command>"
+	Agents     []string // "<plugin>:<agent>"
+	Skills     []string
+	MCPServers []string // "<name> (<type>: <command line or URL>)"
+	Hooks      []string // hooks files, which klein does not run
+}
+
+// InventoryOf lists what plugins add, each list sorted.
+func InventoryOf(plugins []*Plugin) Inventory {
+	var inv Inventory
+	for _, p := range plugins {
+		for name := range p.ScopedCommands() {
+			inv.Commands = append(inv.Commands, "/"+name)
+		}
+		for name := range p.ScopedAgents() {
+			inv.Agents = append(inv.Agents, name)
+		}
+		for name := range p.Skills {
+			inv.Skills = append(inv.Skills, name)
+		}
+		for _, srv := range p.MCPServers {
+			inv.MCPServers = append(inv.MCPServers, describeMCPServer(srv))
+		}
+		if hooks := filepath.Join(p.Root, "hooks", "hooks.json"); isFile(hooks) {
+			inv.Hooks = append(inv.Hooks, p.Name+": hooks/hooks.json")
+		}
+	}
+	for _, list := range [][]string{inv.Commands, inv.Agents, inv.Skills, inv.MCPServers, inv.Hooks} {
+		sort.Strings(list)
+	}
+	return inv
+}
+
+func describeMCPServer(srv domain.MCPServerConfig) string {
+	target := strings.TrimSpace(srv.Command + " " + strings.Join(srv.Args, " "))
+	if srv.URL != "" {
+		target = srv.URL
+	}
+	typ := string(srv.Type)
+	if typ == "" {
+		typ = string(domain.MCPServerTypeStdio)
+	}
+	return fmt.Sprintf("%s (%s: %s)", srv.Name, typ, target)
+}
+
+// Empty reports whether the plugins add nothing at all.
+func (inv Inventory) Empty() bool {
+	return len(inv.Commands)+len(inv.Agents)+len(inv.Skills)+len(inv.MCPServers)+len(inv.Hooks) == 0
+}
+
+// Write lists the inventory, one section per kind. With a previous
+// inventory (an update), items it did not have are marked "(new)" and items
+// that are gone are listed as removed.
+func (inv Inventory) Write(w io.Writer, previous *Inventory) {
+	sections := []struct {
+		title     string
+		now, then []string
+	}{
+		{"Commands", inv.Commands, nil},
+		{"Agents", inv.Agents, nil},
+		{"Skills", inv.Skills, nil},
+		{"MCP servers", inv.MCPServers, nil},
+		{"Hooks (not run by klein)", inv.Hooks, nil},
+	}
+	if previous != nil {
+		sections[0].then = previous.Commands
+		sections[1].then = previous.Agents
+		sections[2].then = previous.Skills
+		sections[3].then = previous.MCPServers
+		sections[4].then = previous.Hooks
+	}
+
+	if inv.Empty() {
+		fmt.Fprintln(w, "  (no commands, agents, skills or MCP servers)")
+	}
+	for _, s := range sections {
+		if len(s.now) == 0 && (previous == nil || len(s.then) == 0) {
+			continue
+		}
+		fmt.Fprintf(w, "  %s:\n", s.title)
+		for _, item := range s.now {
+			mark := ""
+			if previous != nil && !slices.Contains(s.then, item) {
+				mark = " (new)"
+			}
+			fmt.Fprintf(w, "    %s%s\n", item, mark)
+		}
+		if previous != nil {
+			for _, item := range s.then {
+				if !slices.Contains(s.now, item) {
+					fmt.Fprintf(w, "    %s (removed)\n", item)
+				}
+			}
+		}
+	}
+}
@@ -113,6 +113,21 @@ func TestCommandRender(t *testing.T) {
 	}
 }
 
+func TestParseCommandMD_ToolPolicyAndModelPin(t *testing.T) {
+	src := "---\ndescription: triage\nallowed-tools: Read, Grep, Bash\n" +
+		"disallowed-tools: [Bash]\nmodel: haiku\neffort: low\n---\nTriage $ARGUMENTS"
+	c, err := ParseCommandMD([]byte(src), "/p/commands/triage.md", "p")
+	if err != nil {
+		t.Fatalf("ParseCommandMD: %v", err)
+	}
+	if len(c.AllowedTools) != 3 || len(c.DisallowedTools) != 1 || c.DisallowedTools[0] != "Bash" {
+		t.Errorf("tools: allowed %v disallowed %v", c.AllowedTools, c.DisallowedTools)
+	}
+	if c.Model != "haiku" || c.Effort != "low" || c.Backend != "" {
+		t.Errorf("model pin: backend=%q model=%q effort=%q", c.Backend, c.Model, c.Effort)
+	}
+}
+
 func keys[V any](m map[string]V) []string {
 	out := make([]string, 0, len(m))
 	for k := range m {
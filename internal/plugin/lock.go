@@ -0,0 +1,113 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// lockVersion is the plugins.lock format written by this build.
+const lockVersion = 1
+
+// Kinds of installed source.
+const (
+	KindPlugin      = "plugin"
+	KindMarketplace = "marketplace"
+)
+
+// Lock is plugins.lock: for every installed entry, the commit it was
+// trusted at. It sits beside the settings file that lists the entries, so a
+// project that commits .agents/settings.toml and .agents/plugins.lock gives
+// every teammate the same plugin code.
+type Lock struct {
+	Version int                  `json:"version"`
+	Plugins map[string]LockEntry `json:"plugins"`
+}
+
+// LockEntry pins one [plugins.<name>] entry.
+type LockEntry struct {
+	Source    string    `json:"source"`
+	Ref       string    `json:"ref,omitempty"`
+	Commit    string    `json:"commit,omitempty"` // empty for a local directory, which loads in place
+	Kind      string    `json:"kind"`             // KindPlugin or KindMarketplace
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+var commitSHA = regexp.MustCompile(`^(?:[0-9a-f]{40}|[0-9a-f]{64})$`)
+
+// ValidCommit reports whether commit is a full SHA-1 or SHA-256 object name.
+// A commit from a lockfile names a directory in the store and is handed to
+// git, so nothing else — a path, an option — may pass for one.
+func ValidCommit(commit string) error {
+	if !commitSHA.MatchString(commit) {
+		return fmt.Errorf("invalid commit %q: want a full lowercase hex SHA", commit)
+	}
+	return nil
+}
+
+// ReadLock reads a lockfile. A missing file is an empty lock. The lockfile is
+// meant to be committed and shared, so every name and commit in it is
+// checked before it can reach the filesystem or git.
+func ReadLock(path string) (*Lock, error) {
+	l := &Lock{Version: lockVersion, Plugins: map[string]LockEntry{}}
+	data, err := os.ReadFile(path) //nolint:gosec // the lockfile beside the user's settings
+	if os.IsNotExist(err) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, l); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if l.Version > lockVersion {
+		return nil, fmt.Errorf("%s is lock format %d; this klein reads up to %d", path, l.Version, lockVersion)
+	}
+	if l.Plugins == nil {
+		l.Plugins = map[string]LockEntry{}
+	}
+	for name, e := range l.Plugins {
+		if err := ValidName(name); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if e.Commit == "" {
+			continue
+		}
+		if err := ValidCommit(e.Commit); err != nil {
+			return nil, fmt.Errorf("%s: plugin %q: %w", path, name, err)
+		}
+	}
+	return l, nil
+}
+
+// Write saves the lock, replacing the file atomically so an interrupted
+// write never leaves a half-written pin behind.
+func (l *Lock) Write(path string) error {
+	l.Version = lockVersion
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("creating %s: %w", filepath.Dir(path), err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".plugins.lock-*")
+	if err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return nil
+}
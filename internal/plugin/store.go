@@ -0,0 +1,312 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Store turns [plugins.<name>] entries into plugin files on disk. A git
+// source is cloned under Dir, one directory per name and commit, so the
+// checkout a lock names is never changed in place; a local directory loads
+// where it is.
+type Store struct {
+	Dir string // <base_dir>/plugins
+	Git string // git binary; "" = "git" on PATH
+}
+
+// Entry is one [plugins.<name>] entry.
+type Entry struct {
+	Name   string
+	Source string // git URL or local directory
+	Ref    string // branch, tag or commit; "" = the remote's default branch
+}
+
+// Fetched is an entry's content at one commit, loaded but not yet trusted.
+// Keep promotes it to the store; Discard throws it away.
+type Fetched struct {
+	Entry
+	Commit  string // "" for a local directory
+	Kind    string // KindPlugin or KindMarketplace
+	Root    string // the directory the plugins were loaded from
+	Plugins []*Plugin
+
+	staging string // temporary clone, "" once kept or for a local directory
+}
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+// ValidName reports whether name can key a [plugins.<name>] table and name a
+// directory: letters, digits, '-' and '_'.
+func ValidName(name string) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("invalid plugin name %q: use letters, digits, '-' and '_'", name)
+	}
+	return nil
+}
+
+// SuggestName turns a plugin or marketplace name into a valid entry name.
+func SuggestName(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('-')
+		}
+	}
+	return strings.Trim(b.String(), "-_")
+}
+
+// IsGitSource reports whether source is fetched with git rather than read
+// in place: a URL, an scp-style address, a path ending in .git, or a bare
+// repository on disk. Nothing starting with '-' is, so a source can never be
+// taken for a git option.
+func IsGitSource(source string) bool {
+	if strings.HasPrefix(source, "-") {
+		return false
+	}
+	if strings.Contains(source, "://") || strings.HasPrefix(source, "git@") || strings.HasSuffix(source, ".git") {
+		return true
+	}
+	return isDir(source) && isFile(filepath.Join(source, "HEAD")) && isDir(filepath.Join(source, "objects"))
+}
+
+// Fetch clones a git entry at its ref into a staging directory, or resolves
+// a local directory, and loads what it finds.
+func (s *Store) Fetch(ctx context.Context, e Entry) (*Fetched, error) {
+	return s.fetch(ctx, e, "")
+}
+
+// FetchCommit is Fetch pinned to an exact commit, for restoring a lock.
+func (s *Store) FetchCommit(ctx context.Context, e Entry, commit string) (*Fetched, error) {
+	return s.fetch(ctx, e, commit)
+}
+
+func (s *Store) fetch(ctx context.Context, e Entry, commit string) (*Fetched, error) {
+	if strings.HasPrefix(e.Source, "-") {
+		return nil, fmt.Errorf("invalid plugin source %q", e.Source)
+	}
+	if strings.HasPrefix(e.Ref, "-") {
+		return nil, fmt.Errorf("invalid ref %q", e.Ref)
+	}
+	if commit != "" {
+		if err := ValidCommit(commit); err != nil {
+			return nil, err
+		}
+	}
+	f := &Fetched{Entry: e}
+	if !IsGitSource(e.Source) {
+		abs, err := filepath.Abs(e.Source)
+		if err != nil {
+			return nil, err
+		}
+		if !isDir(abs) {
+			return nil, fmt.Errorf("%s is neither a directory nor a git repository", e.Source)
+		}
+		f.Root = abs
+		if err := f.load(); err != nil {
+			return nil, err
+		}
+		return f, nil
+	}
+
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating %s: %w", s.Dir, err)
+	}
+	staging, err := os.MkdirTemp(s.Dir, ".fetch-")
+	if err != nil {
+		return nil, err
+	}
+	f.staging, f.Root = staging, staging
+	fail := func(err error) (*Fetched, error) {
+		os.RemoveAll(staging)
+		return nil, err
+	}
+
+	if _, err := s.git(ctx, "", "clone", "--quiet", "--no-checkout", "--", e.Source, staging); err != nil {
+		return fail(fmt.Errorf("cloning %s: %w", e.Source, err))
+	}
+	if commit == "" {
+		if commit, err = s.resolve(ctx, staging, e.Ref); err != nil {
+			return fail(err)
+		}
+	}
+	if _, err := s.git(ctx, staging, "checkout", "--quiet", "--detach", commit); err != nil {
+		return fail(fmt.Errorf("checking out %s: %w", commit, err))
+	}
+	if f.Commit, err = s.git(ctx, staging, "rev-parse", "HEAD"); err != nil {
+		return fail(err)
+	}
+	if err := f.load(); err != nil {
+		return fail(err)
+	}
+	return f, nil
+}
+
+// resolve finds the commit ref names in a fresh clone: the remote's branch
+// of that name first, then a tag or commit.
+func (s *Store) resolve(ctx context.Context, dir, ref string) (string, error) {
+	if ref == "" {
+		return s.git(ctx, dir, "rev-parse", "--verify", "HEAD^{commit}")
+	}
+	for _, candidate := range []string{"origin/" + ref, ref} {
+		if commit, err := s.git(ctx, dir, "rev-parse", "--verify", "--quiet", candidate+"^{commit}"); err == nil {
+			return commit, nil
+		}
+	}
+	return "", fmt.Errorf("ref %q not found in the repository", ref)
+}
+
+// load reads the fetched tree as a marketplace when it has a
+// marketplace.json, otherwise as a single plugin.
+func (f *Fetched) load() error {
+	if isFile(filepath.Join(f.Root, ".claude-plugin", "marketplace.json")) {
+		mp, err := LoadMarketplace(f.Root)
+		if err != nil {
+			return err
+		}
+		f.Kind = KindMarketplace
+		names := make([]string, 0, len(mp.Plugins))
+		for name := range mp.Plugins {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			f.Plugins = append(f.Plugins, mp.Plugins[name])
+		}
+		if f.Name == "" {
+			f.Name = SuggestName(mp.Name)
+		}
+		return nil
+	}
+	p, err := LoadPlugin(f.Root, "")
+	if err != nil {
+		return err
+	}
+	f.Kind = KindPlugin
+	f.Plugins = []*Plugin{p}
+	if f.Name == "" {
+		f.Name = SuggestName(p.Name)
+	}
+	return nil
+}
+
+// Keep moves a fetched clone to its place in the store and returns the lock
+// entry that pins it. A local directory needs no moving.
+func (s *Store) Keep(f *Fetched) (LockEntry, error) {
+	entry := LockEntry{Source: f.Source, Ref: f.Ref, Commit: f.Commit, Kind: f.Kind}
+	if f.staging == "" {
+		return entry, nil
+	}
+	dest := s.checkoutDir(f.Name, f.Commit)
+	if isDir(dest) {
+		// Already there from an earlier install; the staging copy is identical.
+		os.RemoveAll(f.staging)
+	} else {
+		if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+			return LockEntry{}, err
+		}
+		if err := os.Rename(f.staging, dest); err != nil {
+			return LockEntry{}, fmt.Errorf("installing %s: %w", f.Name, err)
+		}
+	}
+	f.staging = ""
+	return entry, nil
+}
+
+// Discard removes a fetched clone that was not trusted.
+func (s *Store) Discard(f *Fetched) {
+	if f != nil && f.staging != "" {
+		os.RemoveAll(f.staging)
+		f.staging = ""
+	}
+}
+
+// ErrNotInstalled means an entry's pinned checkout is missing, as on a
+// teammate's machine after pulling a project's lockfile.
+var ErrNotInstalled = errors.New("not installed at the locked commit")
+
+// Load loads an installed entry: a local directory in place, a git source
+// from the checkout of the commit its lock entry pins.
+func (s *Store) Load(e Entry, locked LockEntry, ok bool) ([]*Plugin, error) {
+	root := e.Source
+	if IsGitSource(e.Source) {
+		if err := ValidName(e.Name); err != nil {
+			return nil, err
+		}
+		if !ok || locked.Commit == "" || locked.Source != e.Source {
+			return nil, ErrNotInstalled
+		}
+		if err := ValidCommit(locked.Commit); err != nil {
+			return nil, err
+		}
+		root = s.checkoutDir(e.Name, locked.Commit)
+		if !isDir(root) {
+			return nil, ErrNotInstalled
+		}
+	}
+	f := &Fetched{Entry: e, Root: root}
+	if err := f.load(); err != nil {
+		return nil, err
+	}
+	return f.Plugins, nil
+}
+
+// Remove deletes an entry's checkout at commit, and its directory in the
+// store once no checkout is left.
+func (s *Store) Remove(name, commit string) error {
+	if commit == "" {
+		return nil
+	}
+	if err := ValidName(name); err != nil {
+		return err
+	}
+	if err := ValidCommit(commit); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(s.checkoutDir(name, commit)); err != nil {
+		return err
+	}
+	os.Remove(filepath.Join(s.Dir, name)) // only succeeds when empty
+	return nil
+}
+
+func (s *Store) checkoutDir(name, commit string) string {
+	return filepath.Join(s.Dir, name, commit)
+}
+
+// git runs a git command in dir and returns its trimmed stdout. Prompts for
+// credentials are turned off: a plugin command must fail, not hang.
+func (s *Store) git(ctx context.Context, dir string, args ...string) (string, error) {
+	bin := s.Git
+	if bin == "" {
+		bin = "git"
+	}
+	args = append([]string{"-c", "advice.detachedHead=false"}, args...)
+	cmd := exec.CommandContext(ctx, bin, args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", args[2], msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[2], err)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
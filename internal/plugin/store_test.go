@@ -0,0 +1,301 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// gitRepo is a work tree whose commits are pushed to a bare repository, the
+// bare one standing in for a plugin's remote.
+type gitRepo struct {
+	t          *testing.T
+	work, bare string
+}
+
+func newGitRepo(t *testing.T, files map[string]string) *gitRepo {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	r := &gitRepo{t: t, work: t.TempDir(), bare: filepath.Join(t.TempDir(), "plugin.git")}
+	r.run("", "init", "--quiet", "--bare", r.bare)
+	r.run(r.work, "init", "--quiet")
+	r.run(r.work, "remote", "add", "origin", r.bare)
+	r.commit(files, "initial")
+	return r
+}
+
+func (r *gitRepo) run(dir string, args ...string) string {
+	r.t.Helper()
+	args = append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com", "-c", "init.defaultBranch=main"}, args...)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_CONFIG_GLOBAL=/dev/null", "GIT_CONFIG_NOSYSTEM=1")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		r.t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// commit writes files (an empty content deletes), commits and pushes, and
+// returns the new commit.
+func (r *gitRepo) commit(files map[string]string, msg string) string {
+	r.t.Helper()
+	for rel, content := range files {
+		p := filepath.Join(r.work, filepath.FromSlash(rel))
+		if content == "" {
+			os.Remove(p)
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			r.t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			r.t.Fatal(err)
+		}
+	}
+	r.run(r.work, "add", "-A")
+	r.run(r.work, "commit", "--quiet", "-m", msg)
+	r.run(r.work, "push", "--quiet", "origin", "HEAD:main")
+	return r.run(r.work, "rev-parse", "HEAD")
+}
+
+var reviewPlugin = map[string]string{
+	".claude-plugin/plugin.json": `{"name": "review", "description": "Code review helpers"}`,
+	"commands/pr.md":             "---\ndescription: Review a PR\n---\nReview $ARGUMENTS\n",
+	".mcp.json":                  `{"mcpServers": {"gh": {"type": "stdio", "command": "npx", "args": ["-y", "gh-mcp"]}}}`,
+}
+
+func TestStoreFetchKeepAndLoad(t *testing.T) {
+	repo := newGitRepo(t, reviewPlugin)
+	v1 := repo.run(repo.work, "rev-parse", "HEAD")
+	repo.run(repo.work, "tag", "v1")
+	repo.run(repo.work, "push", "--quiet", "origin", "v1")
+	v2 := repo.commit(map[string]string{"commands/security.md": "Check for vulnerabilities\n"}, "add security")
+
+	store := &Store{Dir: t.TempDir()}
+	ctx := context.Background()
+
+	if !IsGitSource(repo.bare) || IsGitSource(repo.work) {
+		t.Fatalf("IsGitSource: bare=%v work tree=%v", IsGitSource(repo.bare), IsGitSource(repo.work))
+	}
+
+	f, err := store.Fetch(ctx, Entry{Source: repo.bare})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Name != "review" || f.Kind != KindPlugin || f.Commit != v2 {
+		t.Fatalf("fetched %q kind %q at %s, want review plugin at %s", f.Name, f.Kind, f.Commit, v2)
+	}
+	inv := InventoryOf(f.Plugins)
+	if strings.Join(inv.Commands, ",") != "/review:pr,/review:security" || inv.MCPServers[0] != "gh (stdio: npx -y gh-mcp)" {
+		t.Errorf("inventory = %+v", inv)
+	}
+
+	locked, err := store.Keep(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if locked.Commit != v2 || locked.Source != repo.bare {
+		t.Errorf("lock entry = %+v", locked)
+	}
+	entry := Entry{Name: "review", Source: repo.bare}
+	plugins, err := store.Load(entry, locked, true)
+	if err != nil || len(plugins) != 1 || len(plugins[0].Commands) != 2 {
+		t.Fatalf("Load = %v, %v", plugins, err)
+	}
+
+	// A ref pins an older commit.
+	old, err := store.Fetch(ctx, Entry{Name: "review", Source: repo.bare, Ref: "v1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if old.Commit != v1 || len(old.Plugins[0].Commands) != 1 {
+		t.Errorf("ref v1 fetched %s with %d commands", old.Commit, len(old.Plugins[0].Commands))
+	}
+	store.Discard(old)
+	if entries, _ := filepath.Glob(filepath.Join(store.Dir, ".fetch-*")); len(entries) != 0 {
+		t.Errorf("Discard left staging directories: %v", entries)
+	}
+
+	// The lock, not the remote, decides what loads.
+	repo.commit(map[string]string{"commands/pr.md": ""}, "drop pr")
+	plugins, _ = store.Load(entry, locked, true)
+	if len(plugins[0].Commands) != 2 {
+		t.Error("a new remote commit changed the locked plugin")
+	}
+
+	if _, err := store.Load(entry, LockEntry{}, false); !errors.Is(err, ErrNotInstalled) {
+		t.Errorf("unlocked git entry: %v", err)
+	}
+	if _, err := store.Load(Entry{Name: "review", Source: "https://example.com/other.git"}, locked, true); !errors.Is(err, ErrNotInstalled) {
+		t.Errorf("lock for another source was used: %v", err)
+	}
+
+	// Restoring the pinned commit after the checkout is gone.
+	if err := store.Remove("review", v2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Load(entry, locked, true); !errors.Is(err, ErrNotInstalled) {
+		t.Fatalf("removed checkout still loads: %v", err)
+	}
+	restored, err := store.FetchCommit(ctx, entry, v2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Keep(restored); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Load(entry, locked, true); err != nil {
+		t.Errorf("restored checkout does not load: %v", err)
+	}
+
+	if _, err := store.Fetch(ctx, Entry{Source: repo.bare, Ref: "no-such-branch"}); err == nil {
+		t.Error("an unknown ref was accepted")
+	}
+}
+
+func TestStoreMarketplaceAndLocalDirectory(t *testing.T) {
+	repo := newGitRepo(t, map[string]string{
+		".claude-plugin/marketplace.json": `{"name": "Acme Tools", "plugins": [
+			{"name": "lint", "source": "./lint"}, {"name": "docs", "source": "./docs"}]}`,
+		"lint/commands/lint.md":           "Lint it\n",
+		"docs/skills/write/SKILL.md":      "---\nname: write\ndescription: Write docs\n---\nWrite.\n",
+		"docs/hooks/hooks.json":           `{}`,
+		"docs/.claude-plugin/plugin.json": `{"name": "docs"}`,
+	})
+	store := &Store{Dir: t.TempDir()}
+
+	f, err := store.Fetch(context.Background(), Entry{Source: "file://" + repo.bare})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Discard(f)
+	if f.Name != "Acme-Tools" || f.Kind != KindMarketplace || len(f.Plugins) != 2 {
+		t.Fatalf("marketplace fetched as %q kind %q with %d plugins", f.Name, f.Kind, len(f.Plugins))
+	}
+	inv := InventoryOf(f.Plugins)
+	if len(inv.Skills) != 1 || len(inv.Hooks) != 1 || inv.Commands[0] != "/lint:lint" {
+		t.Errorf("inventory = %+v", inv)
+	}
+
+	// A plain directory loads in place, with nothing to pin.
+	local, err := store.Fetch(context.Background(), Entry{Name: "lint", Source: filepath.Join(repo.work, "lint")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	locked, err := store.Keep(local)
+	if err != nil || locked.Commit != "" || local.Root != filepath.Join(repo.work, "lint") {
+		t.Errorf("local directory: root %s, lock %+v, err %v", local.Root, locked, err)
+	}
+}
+
+func TestLockRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plugins.lock")
+	l, err := ReadLock(path)
+	if err != nil || len(l.Plugins) != 0 {
+		t.Fatalf("missing lock: %+v, %v", l, err)
+	}
+	commit := strings.Repeat("ab", 20)
+	l.Plugins["review"] = LockEntry{Source: "https://example.com/review.git", Commit: commit, Kind: KindPlugin}
+	if err := l.Write(path); err != nil {
+		t.Fatal(err)
+	}
+	again, err := ReadLock(path)
+	if err != nil || again.Plugins["review"].Commit != commit {
+		t.Errorf("round trip: %+v, %v", again, err)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"version": 99}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadLock(path); err == nil {
+		t.Error("a newer lock format was read")
+	}
+}
+
+// A lockfile comes from whoever committed it, so names and commits that
+// could escape the store or reach git as options are refused on read.
+func TestReadLockRejectsHostileEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plugins.lock")
+	for _, lock := range []string{
+		`{"version": 1, "plugins": {"review": {"source": "x.git", "commit": "../../.."}}}`,
+		`{"version": 1, "plugins": {"review": {"source": "x.git", "commit": "--upload-pack=sh"}}}`,
+		`{"version": 1, "plugins": {"review": {"source": "x.git", "commit": "ABCDEF0123456789ABCDEF0123456789ABCDEF01"}}}`,
+		`{"version": 1, "plugins": {"../review": {"source": "x.git", "commit": "` + strings.Repeat("a", 40) + `"}}}`,
+	} {
+		if err := os.WriteFile(path, []byte(lock), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ReadLock(path); err == nil {
+			t.Errorf("accepted %s", lock)
+		}
+	}
+	ok := `{"version": 1, "plugins": {"review": {"source": "x.git", "commit": "` + strings.Repeat("a", 64) + `"}, "lint": {"source": "/src/lint"}}}`
+	if err := os.WriteFile(path, []byte(ok), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadLock(path); err != nil {
+		t.Errorf("a SHA-256 commit and a local entry: %v", err)
+	}
+}
+
+func TestStoreRefusesHostileSourcesAndCommits(t *testing.T) {
+	repo := newGitRepo(t, reviewPlugin)
+	dir := t.TempDir()
+	s := &Store{Dir: filepath.Join(dir, "plugins")}
+	ctx := context.Background()
+
+	marker := filepath.Join(dir, "pwned")
+	source := "--upload-pack=touch " + marker + ";x.git"
+	if IsGitSource(source) {
+		t.Errorf("%q taken for a git source", source)
+	}
+	if _, err := s.Fetch(ctx, Entry{Name: "review", Source: source}); err == nil {
+		t.Error("fetched a source starting with '-'")
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Fatal("the source ran as a git option")
+	}
+	if _, err := s.FetchCommit(ctx, Entry{Name: "review", Source: repo.bare}, "--orphan=x"); err == nil {
+		t.Error("checked out a commit starting with '-'")
+	}
+
+	keep := filepath.Join(dir, "keep")
+	if err := os.MkdirAll(keep, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Remove("review", "../../keep"); err == nil {
+		t.Error("removed a checkout outside the store")
+	}
+	if err := s.Remove("..", strings.Repeat("a", 40)); err == nil {
+		t.Error("removed under an invalid name")
+	}
+	if _, err := os.Stat(keep); err != nil {
+		t.Fatalf("directory outside the store was deleted: %v", err)
+	}
+	if _, err := s.Load(Entry{Name: "review", Source: repo.bare}, LockEntry{Source: repo.bare, Commit: "../.."}, true); err == nil {
+		t.Error("loaded from an invalid commit")
+	}
+}
+
+func TestInventoryWriteMarksChanges(t *testing.T) {
+	before := Inventory{Commands: []string{"/r:a", "/r:b"}, MCPServers: []string{"gh (stdio: gh)"}}
+	after := Inventory{Commands: []string{"/r:a", "/r:c"}, MCPServers: []string{"gh (stdio: gh)"}}
+
+	var buf bytes.Buffer
+	after.Write(&buf, &before)
+	out := buf.String()
+	for _, want := range []string{"/r:a\n", "/r:c (new)", "/r:b (removed)", "gh (stdio: gh)\n"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+}
@@ -66,14 +66,17 @@ func (p *Plugin) ScopedAgents() map[string]*Agent {
 // @filename includes; expansion is identical to the skill renderer (see
 // (*Command).Render).
 type Command struct {
-	Name         string   // bare name (filename without .md)
-	PluginName   string   // owning plugin's name; empty for project-local commands
-	Description  string   // from frontmatter
-	ArgumentHint string   // from frontmatter
-	AllowedTools []string // from frontmatter (may be YAML list OR comma-separated string)
-	Model        string   // optional model override (ignored for now)
-	Body         string   // markdown body, with placeholders intact
-	SourcePath   string
+	Name            string   // bare name (filename without .md)
+	PluginName      string   // owning plugin's name; empty for project-local commands
+	Description     string   // from frontmatter
+	ArgumentHint    string   // from frontmatter
+	AllowedTools    []string // from frontmatter (may be YAML list OR comma-separated string)
+	DisallowedTools []string // from frontmatter; removed from whatever the turn could otherwise reach
+	Backend         string   // optional backend pin for the command's turn
+	Model           string   // optional model pin (id, haiku/sonnet/opus, or "inherit")
+	Effort          string   // optional reasoning-effort pin
+	Body            string   // markdown body, with placeholders intact
+	SourcePath      string
 }
 
 // Agent is a subagent definition. It comes from one of three places, all using
@@ -0,0 +1,240 @@
+package report
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// canvas is what a chart is drawn on: the SVG of the HTML page or a PDF
+// content stream. Coordinates are in points, origin top left, y down.
+type canvas interface {
+	line(x1, y1, x2, y2 float64, c rgb, width float64)
+	polyline(xs, ys []float64, c rgb, width float64)
+	rect(x, y, w, h float64, c rgb)
+	text(x, y float64, s string, size float64, anchor anchor, c rgb)
+}
+
+type anchor int
+
+const (
+	anchorStart anchor = iota
+	anchorMiddle
+	anchorEnd
+)
+
+type rgb struct{ r, g, b uint8 }
+
+func (c rgb) hex() string { return fmt.Sprintf("#%02x%02x%02x", c.r, c.g, c.b) }
+
+var (
+	inkColor  = rgb{0x22, 0x22, 0x22}
+	axisColor = rgb{0x88, 0x88, 0x88}
+	gridColor = rgb{0xe3, 0xe3, 0xe3}
+	palette   = []rgb{
+		{0x1f, 0x77, 0xb4},
+		{0xff, 0x7f, 0x0e},
+		{0x2c, 0xa0, 0x2c},
+		{0xd6, 0x27, 0x28},
+		{0x94, 0x67, 0xbd},
+		{0x8c, 0x56, 0x4b},
+	}
+)
+
+const (
+	chartFont    = 8.0
+	chartTitle   = 10.0
+	maxXLabels   = 8
+	targetYTicks = 5
+)
+
+// drawChart draws c into a w×h box at the canvas origin.
+func drawChart(cv canvas, c Chart, w, h float64) {
+	top := 8.0
+	if c.Title != "" {
+		cv.text(w/2, 12, c.Title, chartTitle, anchorMiddle, inkColor)
+		top = 24
+	}
+	bottom := h - 18
+	if len(c.Series) > 1 {
+		bottom -= 14
+	}
+
+	lo, hi := valueRange(c)
+	ticks := niceTicks(lo, hi, targetYTicks)
+	lo, hi = ticks[0], ticks[len(ticks)-1]
+
+	labelWidth := 0
+	for _, t := range ticks {
+		labelWidth = max(labelWidth, len(formatTick(t, ticks)))
+	}
+	left := float64(labelWidth)*chartFont*0.55 + 10
+	if c.YLabel != "" {
+		cv.text(2, top-4, c.YLabel, chartFont, anchorStart, axisColor)
+		top += 6
+	}
+	right := w - 8
+	plotW, plotH := right-left, bottom-top
+	y := func(v float64) float64 { return bottom - (v-lo)/(hi-lo)*plotH }
+
+	for _, t := range ticks {
+		ty := y(t)
+		cv.line(left, ty, right, ty, gridColor, 0.5)
+		cv.text(left-4, ty+chartFont/3, formatTick(t, ticks), chartFont, anchorEnd, axisColor)
+	}
+	cv.line(left, bottom, right, bottom, axisColor, 0.75)
+
+	n := len(c.Labels)
+	var x func(i int) float64
+	if c.Kind == ChartBar {
+		slot := plotW / float64(n)
+		x = func(i int) float64 { return left + slot*(float64(i)+0.5) }
+		group := slot * 0.8
+		barW := group / float64(len(c.Series))
+		base := y(math.Max(lo, math.Min(0, hi)))
+		for si, s := range c.Series {
+			col := palette[si%len(palette)]
+			for i, v := range s.Values {
+				if math.IsNaN(v) || math.IsInf(v, 0) {
+					continue
+				}
+				bx := x(i) - group/2 + barW*float64(si)
+				vy := y(v)
+				cv.rect(bx, math.Min(vy, base), math.Max(barW-0.5, 0.5), math.Abs(base-vy), col)
+			}
+		}
+	} else {
+		step := 0.0
+		if n > 1 {
+			step = plotW / float64(n-1)
+		}
+		x = func(i int) float64 {
+			if n == 1 {
+				return left + plotW/2
+			}
+			return left + step*float64(i)
+		}
+		for si, s := range c.Series {
+			col := palette[si%len(palette)]
+			var xs, ys []float64
+			flush := func() {
+				if len(xs) == 1 {
+					cv.rect(xs[0]-1, ys[0]-1, 2, 2, col)
+				} else if len(xs) > 1 {
+					cv.polyline(xs, ys, col, 1.25)
+				}
+				xs, ys = nil, nil
+			}
+			for i, v := range s.Values {
+				if math.IsNaN(v) || math.IsInf(v, 0) {
+					flush()
+					continue
+				}
+				xs = append(xs, x(i))
+				ys = append(ys, y(v))
+			}
+			flush()
+		}
+	}
+
+	every := max(1, (n+maxXLabels-1)/maxXLabels)
+	for i := 0; i < n; i += every {
+		cv.line(x(i), bottom, x(i), bottom+3, axisColor, 0.75)
+		cv.text(x(i), bottom+12, c.Labels[i], chartFont, anchorMiddle, axisColor)
+	}
+
+	if len(c.Series) > 1 {
+		lx := left
+		for si, s := range c.Series {
+			col := palette[si%len(palette)]
+			cv.rect(lx, h-10, 8, 6, col)
+			cv.text(lx+11, h-4, s.Name, chartFont, anchorStart, inkColor)
+			lx += 24 + float64(len(s.Name))*chartFont*0.55
+		}
+	}
+}
+
+// valueRange returns the span the y axis must cover; bars always include
+// zero.
+func valueRange(c Chart) (lo, hi float64) {
+	lo, hi = math.Inf(1), math.Inf(-1)
+	for _, s := range c.Series {
+		for _, v := range s.Values {
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				continue
+			}
+			lo, hi = math.Min(lo, v), math.Max(hi, v)
+		}
+	}
+	if c.Kind == ChartBar {
+		lo, hi = math.Min(lo, 0), math.Max(hi, 0)
+	}
+	if lo == hi {
+		pad := math.Max(math.Abs(lo)*0.05, 1)
+		lo, hi = lo-pad, hi+pad
+	}
+	return lo, hi
+}
+
+// niceTicks returns about n evenly spaced round values covering [lo, hi].
+func niceTicks(lo, hi float64, n int) []float64 {
+	raw := (hi - lo) / float64(n)
+	mag := math.Pow(10, math.Floor(math.Log10(raw)))
+	step := mag
+	for _, m := range []float64{1, 2, 2.5, 5, 10} {
+		if m*mag >= raw {
+			step = m * mag
+			break
+		}
+	}
+	start := math.Floor(lo/step) * step
+	var ticks []float64
+	for v := start; v < hi+step*0.5; v += step {
+		ticks = append(ticks, v)
+		if v >= hi {
+			break
+		}
+	}
+	if len(ticks) < 2 {
+		ticks = append(ticks, start+step)
+	}
+	return ticks
+}
+
+// formatTick formats a tick with as many decimals as the step needs, and
+// large values with a k/M/B suffix.
+func formatTick(v float64, ticks []float64) string {
+	step := ticks[1] - ticks[0]
+	top := math.Max(math.Abs(ticks[0]), math.Abs(ticks[len(ticks)-1]))
+	for _, u := range []struct {
+		div    float64
+		suffix string
+	}{{1e9, "B"}, {1e6, "M"}, {1e3, "k"}} {
+		if top >= u.div*10 {
+			return trimZeros(strconv.FormatFloat(v/u.div, 'f', decimals(step/u.div), 64)) + u.suffix
+		}
+	}
+	return trimZeros(strconv.FormatFloat(v, 'f', decimals(step), 64))
+}
+
+// decimals returns how many decimals show every multiple of step.
+func decimals(step float64) int {
+	for d := 0; d < 6; d++ {
+		scaled := step * math.Pow(10, float64(d))
+		if math.Abs(scaled-math.Round(scaled)) < 1e-9*scaled {
+			return d
+		}
+	}
+	return 6
+}
+
+func trimZeros(s string) string {
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
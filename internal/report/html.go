@@ -0,0 +1,177 @@
+package report
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"strings"
+)
+
+const (
+	svgWidth  = 720.0
+	svgHeight = 300.0
+)
+
+var pageTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; color: #222; max-width: 780px; margin: 2em auto; padding: 0 1em; line-height: 1.5; }
+h1, h2, h3 { line-height: 1.25; }
+h1 { border-bottom: 1px solid #ddd; padding-bottom: .3em; }
+code { background: #f4f4f4; padding: .1em .3em; border-radius: 3px; font-size: 90%; }
+pre { background: #f4f4f4; padding: .8em; overflow-x: auto; border-radius: 4px; }
+pre code { background: none; padding: 0; }
+table { border-collapse: collapse; margin: 1em 0; }
+th, td { border: 1px solid #ddd; padding: .3em .6em; }
+th { background: #f4f4f4; }
+blockquote { border-left: 4px solid #ddd; margin: 1em 0; padding: 0 1em; color: #555; }
+figure { margin: 1.5em 0; }
+figure svg { width: 100%; height: auto; }
+.missing { color: #b00; }
+</style>
+</head>
+<body>
+{{.Body}}
+</body>
+</html>
+`))
+
+// RenderHTML renders doc as a self-contained HTML page.
+func RenderHTML(doc Document) string {
+	charts := doc.chartsByID()
+	var b strings.Builder
+	for _, blk := range doc.layout() {
+		switch blk.kind {
+		case blockHeading:
+			fmt.Fprintf(&b, "<h%d>%s</h%d>\n", blk.level, inlineHTML(blk.text), blk.level)
+		case blockParagraph:
+			fmt.Fprintf(&b, "<p>%s</p>\n", inlineHTML(blk.text))
+		case blockQuote:
+			fmt.Fprintf(&b, "<blockquote><p>%s</p></blockquote>\n", inlineHTML(blk.text))
+		case blockCode:
+			fmt.Fprintf(&b, "<pre><code>%s</code></pre>\n", html.EscapeString(blk.text))
+		case blockRule:
+			b.WriteString("<hr>\n")
+		case blockList:
+			writeHTMLList(&b, blk)
+		case blockTable:
+			writeHTMLTable(&b, blk)
+		case blockChart:
+			c, ok := charts[blk.text]
+			if !ok {
+				fmt.Fprintf(&b, "<p class=\"missing\">[no chart %q]</p>\n", html.EscapeString(blk.text))
+				continue
+			}
+			b.WriteString("<figure>\n")
+			b.WriteString(chartSVG(c))
+			b.WriteString("</figure>\n")
+		}
+	}
+
+	var out strings.Builder
+	title := doc.Title
+	if title == "" {
+		title = "Report"
+	}
+	// The body is assembled from escaped pieces above.
+	err := pageTemplate.Execute(&out, struct {
+		Title string
+		Body  template.HTML
+	}{title, template.HTML(b.String())})
+	if err != nil {
+		// Executing a parsed template into a strings.Builder cannot fail.
+		panic(err)
+	}
+	return out.String()
+}
+
+func writeHTMLList(b *strings.Builder, blk block) {
+	tag := "ul"
+	if blk.ordered {
+		tag = "ol"
+	}
+	if blk.ordered && blk.start != 1 {
+		fmt.Fprintf(b, "<%s start=\"%d\">\n", tag, blk.start)
+	} else {
+		fmt.Fprintf(b, "<%s>\n", tag)
+	}
+	depth := 0
+	for _, item := range blk.items {
+		for ; depth < item.depth; depth++ {
+			fmt.Fprintf(b, "<%s>\n", tag)
+		}
+		for ; depth > item.depth; depth-- {
+			fmt.Fprintf(b, "</%s>\n", tag)
+		}
+		fmt.Fprintf(b, "<li>%s</li>\n", inlineHTML(item.text))
+	}
+	for ; depth > 0; depth-- {
+		fmt.Fprintf(b, "</%s>\n", tag)
+	}
+	fmt.Fprintf(b, "</%s>\n", tag)
+}
+
+func writeHTMLTable(b *strings.Builder, blk block) {
+	b.WriteString("<table>\n<thead><tr>")
+	for _, h := range blk.header {
+		fmt.Fprintf(b, "<th>%s</th>", inlineHTML(h))
+	}
+	b.WriteString("</tr></thead>\n<tbody>\n")
+	for _, row := range blk.rows {
+		b.WriteString("<tr>")
+		for i := range blk.header {
+			cell := ""
+			if i < len(row) {
+				cell = row[i]
+			}
+			fmt.Fprintf(b, "<td>%s</td>", inlineHTML(cell))
+		}
+		b.WriteString("</tr>\n")
+	}
+	b.WriteString("</tbody>\n</table>\n")
+}
+
+// chartSVG draws c as an inline SVG element.
+func chartSVG(c Chart) string {
+	var cv svgCanvas
+	fmt.Fprintf(&cv.b, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %g %g" role="img" aria-label="%s" font-family="Helvetica, Arial, sans-serif">`+"\n",
+		svgWidth, svgHeight, html.EscapeString(c.Title))
+	drawChart(&cv, c, svgWidth, svgHeight)
+	cv.b.WriteString("</svg>\n")
+	return cv.b.String()
+}
+
+// svgCanvas draws into SVG markup.
+type svgCanvas struct {
+	b strings.Builder
+}
+
+func (cv *svgCanvas) line(x1, y1, x2, y2 float64, c rgb, width float64) {
+	fmt.Fprintf(&cv.b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s" stroke-width="%g"/>`+"\n",
+		x1, y1, x2, y2, c.hex(), width)
+}
+
+func (cv *svgCanvas) polyline(xs, ys []float64, c rgb, width float64) {
+	cv.b.WriteString(`<polyline fill="none" stroke-linejoin="round" points="`)
+	for i := range xs {
+		if i > 0 {
+			cv.b.WriteByte(' ')
+		}
+		fmt.Fprintf(&cv.b, "%.1f,%.1f", xs[i], ys[i])
+	}
+	fmt.Fprintf(&cv.b, `" stroke="%s" stroke-width="%g"/>`+"\n", c.hex(), width)
+}
+
+func (cv *svgCanvas) rect(x, y, w, h float64, c rgb) {
+	fmt.Fprintf(&cv.b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"/>`+"\n", x, y, w, h, c.hex())
+}
+
+func (cv *svgCanvas) text(x, y float64, s string, size float64, a anchor, c rgb) {
+	anchors := [...]string{"start", "middle", "end"}
+	fmt.Fprintf(&cv.b, `<text x="%.1f" y="%.1f" font-size="%g" text-anchor="%s" fill="%s">%s</text>`+"\n",
+		x, y, size, anchors[a], c.hex(), html.EscapeString(s))
+}
@@ -0,0 +1,230 @@
+package report
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The Markdown a report needs: ATX and setext headings, paragraphs, bullet
+// and numbered lists, fenced code, pipe tables, block quotes, rules and
+// chart placeholders; inline code, links, bold and italics.
+
+type blockKind int
+
+const (
+	blockParagraph blockKind = iota
+	blockHeading
+	blockList
+	blockCode
+	blockTable
+	blockQuote
+	blockRule
+	blockChart
+)
+
+type block struct {
+	kind    blockKind
+	level   int    // heading level
+	text    string // paragraph, heading and quote text; code body; chart ID
+	ordered bool
+	start   int // first number of an ordered list
+	items   []listItem
+	header  []string
+	rows    [][]string
+}
+
+type listItem struct {
+	text  string
+	depth int
+}
+
+var (
+	chartLine    = regexp.MustCompile(`^\{\{\s*chart:\s*([\w.-]+)\s*\}\}$`)
+	atxHeading   = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	listMarker   = regexp.MustCompile(`^(\s*)([-*+]|(\d{1,9})[.)])\s+(.*)$`)
+	ruleLine     = regexp.MustCompile(`^\s{0,3}([-*_])(\s*([-*_]))*\s*$`)
+	tableDivider = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+	setextLine   = regexp.MustCompile(`^\s{0,3}(=+|-+)\s*$`)
+)
+
+// parseMarkdown splits src into blocks.
+func parseMarkdown(src string) []block {
+	lines := strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+	var blocks []block
+	var para []string
+	flush := func() {
+		if len(para) > 0 {
+			blocks = append(blocks, block{kind: blockParagraph, text: strings.Join(para, "\n")})
+			para = nil
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			flush()
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			flush()
+			fence := trimmed[:3]
+			var body []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), fence); i++ {
+				body = append(body, lines[i])
+			}
+			blocks = append(blocks, block{kind: blockCode, text: strings.Join(body, "\n")})
+		case len(para) > 0 && setextLine.MatchString(line):
+			level := 2
+			if strings.HasPrefix(trimmed, "=") {
+				level = 1
+			}
+			text := strings.Join(para, " ")
+			para = nil
+			blocks = append(blocks, block{kind: blockHeading, level: level, text: text})
+		case atxHeading.MatchString(trimmed):
+			flush()
+			m := atxHeading.FindStringSubmatch(trimmed)
+			blocks = append(blocks, block{kind: blockHeading, level: len(m[1]), text: m[2]})
+		case chartLine.MatchString(trimmed):
+			flush()
+			blocks = append(blocks, block{kind: blockChart, text: chartLine.FindStringSubmatch(trimmed)[1]})
+		case ruleLine.MatchString(line) && len(strings.ReplaceAll(trimmed, " ", "")) >= 3:
+			flush()
+			blocks = append(blocks, block{kind: blockRule})
+		case strings.HasPrefix(trimmed, ">"):
+			flush()
+			var quote []string
+			for ; i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), ">"); i++ {
+				quote = append(quote, strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(lines[i]), ">")))
+			}
+			i--
+			blocks = append(blocks, block{kind: blockQuote, text: strings.Join(quote, "\n")})
+		case len(para) == 0 && listMarker.MatchString(line):
+			var b block
+			b, i = parseList(lines, i)
+			blocks = append(blocks, b)
+		case len(para) == 0 && strings.Contains(line, "|") && i+1 < len(lines) && tableDivider.MatchString(lines[i+1]) && strings.Contains(lines[i+1], "-"):
+			b := block{kind: blockTable, header: splitRow(line)}
+			for i += 2; i < len(lines) && strings.Contains(lines[i], "|") && strings.TrimSpace(lines[i]) != ""; i++ {
+				b.rows = append(b.rows, splitRow(lines[i]))
+			}
+			i--
+			blocks = append(blocks, b)
+		default:
+			para = append(para, trimmed)
+		}
+	}
+	flush()
+	return blocks
+}
+
+// parseList reads the list starting at lines[i] and returns it with the
+// index of its last line.
+func parseList(lines []string, i int) (block, int) {
+	first := listMarker.FindStringSubmatch(lines[i])
+	b := block{kind: blockList, ordered: first[3] != ""}
+	if b.ordered {
+		b.start, _ = strconv.Atoi(first[3])
+	}
+	base := len(first[1])
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if strings.TrimSpace(line) == "" {
+			// A blank line ends the list unless an item follows it.
+			if i+1 < len(lines) && listMarker.MatchString(lines[i+1]) {
+				continue
+			}
+			break
+		}
+		if m := listMarker.FindStringSubmatch(line); m != nil {
+			depth := max(0, (len(m[1])-base)/2)
+			b.items = append(b.items, listItem{text: strings.TrimSpace(m[4]), depth: depth})
+			continue
+		}
+		indented := strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")
+		if !indented || len(b.items) == 0 {
+			break
+		}
+		last := &b.items[len(b.items)-1]
+		last.text += " " + strings.TrimSpace(line)
+	}
+	return b, i - 1
+}
+
+// splitRow returns a pipe-table row's cells.
+func splitRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	line = strings.TrimSuffix(line, "|")
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case line[i] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(line[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+var (
+	inlineLink   = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	inlineBold   = regexp.MustCompile(`\*\*([^*]+)\*\*|__([^_]+)__`)
+	inlineItalic = regexp.MustCompile(`\*([^*\s][^*]*)\*`)
+)
+
+// inlineHTML renders a span of Markdown as HTML. Link targets other than
+// http, https and mailto are dropped.
+func inlineHTML(s string) string {
+	var b strings.Builder
+	for i, part := range strings.Split(s, "`") {
+		if i%2 == 1 {
+			b.WriteString("<code>" + html.EscapeString(part) + "</code>")
+			continue
+		}
+		part = html.EscapeString(part)
+		part = inlineLink.ReplaceAllStringFunc(part, func(m string) string {
+			sub := inlineLink.FindStringSubmatch(m)
+			href := html.UnescapeString(sub[2])
+			if !strings.HasPrefix(href, "http://") && !strings.HasPrefix(href, "https://") && !strings.HasPrefix(href, "mailto:") {
+				return sub[1]
+			}
+			return `<a href="` + html.EscapeString(href) + `">` + sub[1] + `</a>`
+		})
+		part = inlineBold.ReplaceAllString(part, "<strong>$1$2</strong>")
+		part = inlineItalic.ReplaceAllString(part, "<em>$1</em>")
+		b.WriteString(strings.ReplaceAll(part, "\n", "<br>\n"))
+	}
+	return b.String()
+}
+
+// inlinePlain renders a span of Markdown as plain text: markers dropped,
+// links as "text (URL)".
+func inlinePlain(s string) string {
+	var b strings.Builder
+	for i, part := range strings.Split(s, "`") {
+		if i%2 == 1 {
+			b.WriteString(part)
+			continue
+		}
+		part = inlineLink.ReplaceAllStringFunc(part, func(m string) string {
+			sub := inlineLink.FindStringSubmatch(m)
+			if sub[1] == sub[2] {
+				return sub[1]
+			}
+			return sub[1] + " (" + sub[2] + ")"
+		})
+		part = inlineBold.ReplaceAllString(part, "$1$2")
+		part = inlineItalic.ReplaceAllString(part, "$1")
+		b.WriteString(part)
+	}
+	return b.String()
+}
@@ -0,0 +1,451 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/font"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// The PDF is A4 set in the standard 14 fonts, which need no embedding but
+// only cover WinAnsi (Latin-1 plus typographic punctuation).
+const (
+	pageWidth   = 595.28
+	pageHeight  = 841.89
+	pageMargin  = 50.0
+	footerSpace = 24.0
+	chartHeight = 230.0
+)
+
+type pdfFont struct {
+	resource string // name in the page's font resources
+	name     string // core font, for metrics
+}
+
+var (
+	fontRegular = pdfFont{"F1", "Helvetica"}
+	fontBold    = pdfFont{"F2", "Helvetica-Bold"}
+	fontMono    = pdfFont{"F3", "Courier"}
+	pdfFonts    = []pdfFont{fontRegular, fontBold, fontMono}
+)
+
+// RenderPDF renders doc as a PDF. It also returns how many characters the
+// fonts could not show.
+func RenderPDF(doc Document) ([]byte, int, error) {
+	p := &pdfLayout{}
+	p.newPage()
+	charts := doc.chartsByID()
+	first := true
+	for _, blk := range doc.layout() {
+		p.block(blk, charts, first)
+		first = false
+	}
+	data, err := p.write()
+	if err != nil {
+		return nil, 0, err
+	}
+	return data, p.replaced, nil
+}
+
+// pdfLayout flows blocks down A4 pages. y is the distance of the cursor
+// from the top of the page.
+type pdfLayout struct {
+	pages    []*bytes.Buffer
+	cur      *bytes.Buffer
+	y        float64
+	replaced int
+}
+
+func (p *pdfLayout) newPage() {
+	p.cur = &bytes.Buffer{}
+	p.pages = append(p.pages, p.cur)
+	p.y = pageMargin
+}
+
+// ensure starts a new page unless h more points fit on this one.
+func (p *pdfLayout) ensure(h float64) {
+	if p.y+h > pageHeight-pageMargin-footerSpace && p.y > pageMargin {
+		p.newPage()
+	}
+}
+
+// space adds vertical space, unless the cursor is at the top of a page.
+func (p *pdfLayout) space(h float64) {
+	if p.y > pageMargin {
+		p.y += h
+	}
+}
+
+func (p *pdfLayout) contentWidth() float64 { return pageWidth - 2*pageMargin }
+
+func (p *pdfLayout) block(blk block, charts map[string]Chart, first bool) {
+	width := p.contentWidth()
+	switch blk.kind {
+	case blockHeading:
+		size := map[int]float64{1: 18, 2: 14, 3: 12}[blk.level]
+		if size == 0 {
+			size = 11
+		}
+		if !first {
+			p.space(size * 0.6)
+		}
+		lines := p.wrap(p.encode(inlinePlain(blk.text)), fontBold, size, width)
+		// Keep a heading with at least a line of what follows.
+		p.ensure(float64(len(lines))*size*1.25 + 28)
+		p.lines(pageMargin, lines, fontBold, size, size*1.25, inkColor)
+		p.y += 4
+	case blockParagraph:
+		p.paragraph(pageMargin, width, inlinePlain(blk.text), inkColor)
+	case blockQuote:
+		top := p.y
+		p.paragraph(pageMargin+12, width-12, inlinePlain(blk.text), axisColor)
+		if p.y > top {
+			p.rectTop(pageMargin+2, top, 2, p.y-top-8, gridColor)
+		}
+	case blockList:
+		for i, item := range blk.items {
+			indent := 14 + 14*float64(item.depth)
+			marker := "\x95"
+			if blk.ordered {
+				marker = fmt.Sprintf("%d.", blk.start+i)
+			}
+			lines := p.wrap(p.encode(inlinePlain(item.text)), fontRegular, 10.5, width-indent-6)
+			p.ensure(14)
+			p.text(pageMargin+indent-4, p.y+10.5, marker, fontRegular, 10.5, anchorEnd, inkColor)
+			p.lines(pageMargin+indent+2, lines, fontRegular, 10.5, 14, inkColor)
+		}
+		p.y += 6
+	case blockCode:
+		var lines []string
+		for _, l := range strings.Split(blk.text, "\n") {
+			lines = append(lines, p.hardWrap(p.encode(strings.ReplaceAll(l, "\t", "    ")), fontMono, 9, width-12)...)
+		}
+		for _, l := range lines {
+			p.ensure(11)
+			p.rectTop(pageMargin, p.y, width, 11, rgb{0xf4, 0xf4, 0xf4})
+			p.text(pageMargin+6, p.y+8.5, l, fontMono, 9, anchorStart, inkColor)
+			p.y += 11
+		}
+		p.y += 8
+	case blockRule:
+		p.ensure(12)
+		p.y += 6
+		p.lineTop(pageMargin, p.y, pageMargin+width, p.y, gridColor, 0.75)
+		p.y += 6
+	case blockTable:
+		p.table(blk, width)
+	case blockChart:
+		c, ok := charts[blk.text]
+		if !ok {
+			p.paragraph(pageMargin, width, fmt.Sprintf("[no chart %q]", blk.text), palette[3])
+			return
+		}
+		p.ensure(chartHeight)
+		fmt.Fprintf(p.cur, "q\n")
+		drawChart(&pdfCanvas{p: p, x0: pageMargin, y0: p.y}, c, width, chartHeight)
+		fmt.Fprintf(p.cur, "Q\n")
+		p.y += chartHeight + 10
+	}
+}
+
+func (p *pdfLayout) paragraph(x, width float64, text string, c rgb) {
+	lines := p.wrap(p.encode(text), fontRegular, 10.5, width)
+	p.lines(x, lines, fontRegular, 10.5, 14, c)
+	p.y += 8
+}
+
+// lines sets already-wrapped lines, breaking pages between them.
+func (p *pdfLayout) lines(x float64, lines []string, f pdfFont, size, leading float64, c rgb) {
+	for _, l := range lines {
+		p.ensure(leading)
+		p.text(x, p.y+size, l, f, size, anchorStart, c)
+		p.y += leading
+	}
+}
+
+func (p *pdfLayout) table(blk block, width float64) {
+	cols := len(blk.header)
+	if cols == 0 {
+		return
+	}
+	cell := func(row []string, i int) string {
+		if i < len(row) {
+			return p.encode(inlinePlain(row[i]))
+		}
+		return ""
+	}
+	// Columns share the width in proportion to their widest cell.
+	natural := make([]float64, cols)
+	for i := range natural {
+		natural[i] = p.width(cell(blk.header, i), fontBold, 9) + 10
+		for _, row := range blk.rows {
+			natural[i] = math.Max(natural[i], p.width(cell(row, i), fontRegular, 9)+10)
+		}
+	}
+	total := 0.0
+	for _, w := range natural {
+		total += w
+	}
+	widths := natural
+	if total > width {
+		widths = make([]float64, cols)
+		for i, w := range natural {
+			widths[i] = w / total * width
+		}
+	}
+
+	row := func(cells []string, f pdfFont) {
+		p.ensure(14)
+		x := pageMargin
+		for i := range widths {
+			text := p.truncate(cell(cells, i), f, 9, widths[i]-10)
+			p.text(x+5, p.y+10, text, f, 9, anchorStart, inkColor)
+			x += widths[i]
+		}
+		p.y += 14
+	}
+	row(blk.header, fontBold)
+	used := 0.0
+	for _, w := range widths {
+		used += w
+	}
+	p.lineTop(pageMargin, p.y-1, pageMargin+used, p.y-1, axisColor, 0.75)
+	for _, r := range blk.rows {
+		row(r, fontRegular)
+		p.lineTop(pageMargin, p.y-1, pageMargin+used, p.y-1, gridColor, 0.5)
+	}
+	p.y += 8
+}
+
+// encode converts s to WinAnsi, replacing what it cannot encode with "?".
+func (p *pdfLayout) encode(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\t':
+			b.WriteByte(' ')
+		case r < 0x20:
+			continue
+		case r < 0x80 || (r >= 0xa0 && r <= 0xff):
+			b.WriteByte(byte(r))
+		default:
+			if c, ok := winAnsi[r]; ok {
+				b.WriteByte(c)
+			} else {
+				b.WriteByte('?')
+				p.replaced++
+			}
+		}
+	}
+	return b.String()
+}
+
+// winAnsi maps the characters WinAnsi places in 0x80–0x9f.
+var winAnsi = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87,
+	'ˆ': 0x88, '‰': 0x89, 'Š': 0x8a, '‹': 0x8b, 'Œ': 0x8c, 'Ž': 0x8e,
+	'‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97,
+	'˜': 0x98, '™': 0x99, 'š': 0x9a, '›': 0x9b, 'œ': 0x9c, 'ž': 0x9e, 'Ÿ': 0x9f,
+}
+
+// width measures WinAnsi text.
+func (p *pdfLayout) width(s string, f pdfFont, size float64) float64 {
+	w, err := font.TextWidthFloat(s, f.name, size)
+	if err != nil {
+		// Core font metrics are built in; estimate rather than fail.
+		return float64(len(s)) * size * 0.5
+	}
+	return w
+}
+
+// wrap breaks s into lines no wider than width, at spaces where it can.
+func (p *pdfLayout) wrap(s string, f pdfFont, size, width float64) []string {
+	var lines []string
+	for _, para := range strings.Split(s, "\n") {
+		line := ""
+		for _, word := range strings.Fields(para) {
+			candidate := word
+			if line != "" {
+				candidate = line + " " + word
+			}
+			if p.width(candidate, f, size) <= width {
+				line = candidate
+				continue
+			}
+			if line != "" {
+				lines = append(lines, line)
+			}
+			parts := p.hardWrap(word, f, size, width)
+			lines = append(lines, parts[:len(parts)-1]...)
+			line = parts[len(parts)-1]
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// hardWrap breaks s into lines no wider than width, anywhere.
+func (p *pdfLayout) hardWrap(s string, f pdfFont, size, width float64) []string {
+	var lines []string
+	for len(s) > 0 && p.width(s, f, size) > width {
+		n := 1
+		for n < len(s) && p.width(s[:n+1], f, size) <= width {
+			n++
+		}
+		lines = append(lines, s[:n])
+		s = s[n:]
+	}
+	return append(lines, s)
+}
+
+// truncate shortens s with an ellipsis to fit width.
+func (p *pdfLayout) truncate(s string, f pdfFont, size, width float64) string {
+	if p.width(s, f, size) <= width {
+		return s
+	}
+	for len(s) > 0 && p.width(s+"\x85", f, size) > width {
+		s = s[:len(s)-1]
+	}
+	return s + "\x85"
+}
+
+// text sets WinAnsi text with its baseline y points from the top.
+func (p *pdfLayout) text(x, y float64, s string, f pdfFont, size float64, a anchor, c rgb) {
+	switch a {
+	case anchorMiddle:
+		x -= p.width(s, f, size) / 2
+	case anchorEnd:
+		x -= p.width(s, f, size)
+	}
+	fmt.Fprintf(p.cur, "BT /%s %g Tf %s rg %.2f %.2f Td (%s) Tj ET\n",
+		f.resource, size, pdfColor(c), x, pageHeight-y, escapePDF(s))
+}
+
+func (p *pdfLayout) rectTop(x, y, w, h float64, c rgb) {
+	fmt.Fprintf(p.cur, "%s rg %.2f %.2f %.2f %.2f re f\n", pdfColor(c), x, pageHeight-y-h, w, h)
+}
+
+func (p *pdfLayout) lineTop(x1, y1, x2, y2 float64, c rgb, width float64) {
+	fmt.Fprintf(p.cur, "%s RG %g w %.2f %.2f m %.2f %.2f l S\n", pdfColor(c), width, x1, pageHeight-y1, x2, pageHeight-y2)
+}
+
+func pdfColor(c rgb) string {
+	return fmt.Sprintf("%.3f %.3f %.3f", float64(c.r)/255, float64(c.g)/255, float64(c.b)/255)
+}
+
+func escapePDF(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `(`, `\(`, `)`, `\)`, "\r", `\r`, "\n", `\n`)
+	return r.Replace(s)
+}
+
+// write numbers the pages and assembles the document.
+func (p *pdfLayout) write() ([]byte, error) {
+	for i, page := range p.pages {
+		p.cur = page
+		p.text(pageWidth/2, pageHeight-pageMargin/2, fmt.Sprintf("%d / %d", i+1, len(p.pages)), fontRegular, 8, anchorMiddle, axisColor)
+	}
+
+	xRefTable, err := pdfcpu.CreateXRefTableWithRootDict()
+	if err != nil {
+		return nil, err
+	}
+	root, err := xRefTable.Catalog()
+	if err != nil {
+		return nil, err
+	}
+	fonts := types.Dict{}
+	for _, f := range pdfFonts {
+		ref, err := xRefTable.IndRefForNewObject(types.Dict{
+			"Type":     types.Name("Font"),
+			"Subtype":  types.Name("Type1"),
+			"BaseFont": types.Name(f.name),
+			"Encoding": types.Name("WinAnsiEncoding"),
+		})
+		if err != nil {
+			return nil, err
+		}
+		fonts[f.resource] = *ref
+	}
+
+	pages := types.Dict{
+		"Type":     types.Name("Pages"),
+		"Count":    types.Integer(len(p.pages)),
+		"MediaBox": types.RectForDim(pageWidth, pageHeight).Array(),
+	}
+	pagesRef, err := xRefTable.IndRefForNewObject(pages)
+	if err != nil {
+		return nil, err
+	}
+	kids := types.Array{}
+	for _, page := range p.pages {
+		sd, err := xRefTable.NewStreamDictForBuf(page.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		if err := sd.Encode(); err != nil {
+			return nil, err
+		}
+		contents, err := xRefTable.IndRefForNewObject(*sd)
+		if err != nil {
+			return nil, err
+		}
+		ref, err := xRefTable.IndRefForNewObject(types.Dict{
+			"Type":      types.Name("Page"),
+			"Parent":    *pagesRef,
+			"Resources": types.Dict{"Font": fonts},
+			"Contents":  *contents,
+		})
+		if err != nil {
+			return nil, err
+		}
+		kids = append(kids, *ref)
+	}
+	pages.Insert("Kids", kids)
+	root.Insert("Pages", *pagesRef)
+	xRefTable.PageCount = len(p.pages)
+
+	var out bytes.Buffer
+	if err := api.WriteContext(pdfcpu.CreateContext(xRefTable, model.NewDefaultConfiguration()), &out); err != nil {
+		return nil, fmt.Errorf("writing PDF: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+// pdfCanvas draws a chart into the current page, its origin at (x0, y0)
+// from the page's top left.
+type pdfCanvas struct {
+	p      *pdfLayout
+	x0, y0 float64
+}
+
+func (cv *pdfCanvas) line(x1, y1, x2, y2 float64, c rgb, width float64) {
+	cv.p.lineTop(cv.x0+x1, cv.y0+y1, cv.x0+x2, cv.y0+y2, c, width)
+}
+
+func (cv *pdfCanvas) polyline(xs, ys []float64, c rgb, width float64) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s RG %g w 1 j", pdfColor(c), width)
+	for i := range xs {
+		op := "l"
+		if i == 0 {
+			op = "m"
+		}
+		fmt.Fprintf(&b, " %.2f %.2f %s", cv.x0+xs[i], pageHeight-cv.y0-ys[i], op)
+	}
+	b.WriteString(" S\n")
+	cv.p.cur.WriteString(b.String())
+}
+
+func (cv *pdfCanvas) rect(x, y, w, h float64, c rgb) {
+	cv.p.rectTop(cv.x0+x, cv.y0+y, w, h, c)
+}
+
+func (cv *pdfCanvas) text(x, y float64, s string, size float64, a anchor, c rgb) {
+	cv.p.text(cv.x0+x, cv.y0+y, cv.p.encode(s), fontRegular, size, a, c)
+}
@@ -0,0 +1,235 @@
+// Package report renders report deliverables: Markdown with charts, written
+// as a self-contained HTML page (charts as inline SVG) and as a PDF (charts
+// drawn as vector paths, through pdfcpu).
+//
+// A chart is placed where the Markdown has a line of its own reading
+// {{chart:ID}}; charts the Markdown does not place follow the text.
+//
+// The files a turn writes are collected on the context (WithOutputs), so a
+// frontend such as the gateway can deliver them with the reply.
+package report
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Chart kinds.
+const (
+	ChartLine = "line"
+	ChartBar  = "bar"
+)
+
+// Output formats.
+const (
+	FormatHTML = "html"
+	FormatPDF  = "pdf"
+)
+
+// Document is one report.
+type Document struct {
+	Title    string
+	Markdown string
+	Charts   []Chart
+}
+
+// Chart is a line or bar chart over labelled points: one value per label in
+// every series.
+type Chart struct {
+	ID     string
+	Kind   string // ChartLine or ChartBar
+	Title  string
+	YLabel string
+	Labels []string
+	Series []Series
+}
+
+// Series is one line, or one bar per label. A NaN value is a gap.
+type Series struct {
+	Name   string
+	Values []float64
+}
+
+// Validate reports what makes c undrawable.
+func (c Chart) Validate() error {
+	if strings.TrimSpace(c.ID) == "" {
+		return fmt.Errorf("chart has no id")
+	}
+	if c.Kind != ChartLine && c.Kind != ChartBar {
+		return fmt.Errorf("chart %s: type %q must be %q or %q", c.ID, c.Kind, ChartLine, ChartBar)
+	}
+	if len(c.Labels) == 0 || len(c.Series) == 0 {
+		return fmt.Errorf("chart %s has no data", c.ID)
+	}
+	finite := false
+	for _, s := range c.Series {
+		if len(s.Values) != len(c.Labels) {
+			return fmt.Errorf("chart %s: series %q has %d values for %d labels", c.ID, s.Name, len(s.Values), len(c.Labels))
+		}
+		for _, v := range s.Values {
+			if !math.IsNaN(v) && !math.IsInf(v, 0) {
+				finite = true
+			}
+		}
+	}
+	if !finite {
+		return fmt.Errorf("chart %s has no numeric values", c.ID)
+	}
+	return nil
+}
+
+// Result is what Write produced.
+type Result struct {
+	Paths []string
+	// Replaced counts characters the PDF's core fonts cannot show, printed
+	// as "?"; the HTML has them all.
+	Replaced int
+}
+
+// Write renders doc into dir in each of formats, as files named after the
+// title and the current time.
+func Write(dir string, doc Document, formats []string) (*Result, error) {
+	for _, c := range doc.Charts {
+		if err := c.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	base := filepath.Join(dir, time.Now().Format("20060102-150405")+"-"+slug(doc.Title))
+	res := &Result{}
+	for _, format := range formats {
+		var data []byte
+		switch format {
+		case FormatHTML:
+			data = []byte(RenderHTML(doc))
+		case FormatPDF:
+			pdf, replaced, err := RenderPDF(doc)
+			if err != nil {
+				return nil, err
+			}
+			data, res.Replaced = pdf, replaced
+		default:
+			return nil, fmt.Errorf("unknown format %q (use %s or %s)", format, FormatHTML, FormatPDF)
+		}
+		path := base + "." + format
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			return nil, err
+		}
+		res.Paths = append(res.Paths, path)
+	}
+	return res, nil
+}
+
+// slug turns a title into a file-name fragment.
+func slug(title string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(title) {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+		if b.Len() >= 48 {
+			break
+		}
+	}
+	s := strings.Trim(b.String(), "-")
+	if s == "" {
+		return "report"
+	}
+	return s
+}
+
+// chartsByID indexes doc's charts by ID.
+func (doc Document) chartsByID() map[string]Chart {
+	m := make(map[string]Chart, len(doc.Charts))
+	for _, c := range doc.Charts {
+		m[c.ID] = c
+	}
+	return m
+}
+
+// layout returns the document's blocks with every chart placed: unplaced
+// charts are appended, and a title heading leads when the Markdown has none.
+func (doc Document) layout() []block {
+	blocks := parseMarkdown(doc.Markdown)
+	placed := map[string]bool{}
+	for _, b := range blocks {
+		if b.kind == blockChart {
+			placed[b.text] = true
+		}
+	}
+	if doc.Title != "" && (len(blocks) == 0 || blocks[0].kind != blockHeading || blocks[0].level != 1) {
+		blocks = append([]block{{kind: blockHeading, level: 1, text: doc.Title}}, blocks...)
+	}
+	for _, c := range doc.Charts {
+		if !placed[c.ID] {
+			blocks = append(blocks, block{kind: blockChart, text: c.ID})
+		}
+	}
+	return blocks
+}
+
+type outputsKey struct{}
+
+// Outputs collects the report files written during one turn.
+type Outputs struct {
+	mu    sync.Mutex
+	paths []string
+}
+
+// WithOutputs returns a context that collects the report files written
+// under it.
+func WithOutputs(ctx context.Context) (context.Context, *Outputs) {
+	o := &Outputs{}
+	return context.WithValue(ctx, outputsKey{}, o), o
+}
+
+// OutputsFrom returns the collector ctx carries, or nil.
+func OutputsFrom(ctx context.Context) *Outputs {
+	o, _ := ctx.Value(outputsKey{}).(*Outputs)
+	return o
+}
+
+// Add records a written file; a nil collector ignores it.
+func (o *Outputs) Add(paths ...string) {
+	if o == nil {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.paths = append(o.paths, paths...)
+}
+
+// Paths returns the files recorded so far.
+func (o *Outputs) Paths() []string {
+	if o == nil {
+		return nil
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]string(nil), o.paths...)
+}
+
+// ContentType returns the MIME type of a file Write produced.
+func ContentType(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".html":
+		return "text/html; charset=utf-8"
+	case ".pdf":
+		return "application/pdf"
+	}
+	return "application/octet-stream"
+}
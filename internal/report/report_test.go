@@ -0,0 +1,168 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+func sampleDoc() Document {
+	return Document{
+		Title: "Weekly <Review>",
+		Markdown: `# Weekly review
+
+Prices **rose**; see [the source](https://example.com/a) and [bad](javascript:alert(1)).
+
+{{chart:px}}
+
+- first
+  - nested
+- second
+
+| Symbol | Close |
+|--------|------:|
+| AAPL   | 190.5 |
+
+` + "```\ncode <here>\n```",
+		Charts: []Chart{
+			{ID: "px", Kind: ChartLine, Title: "Close", Labels: []string{"d1", "d2", "d3", "d4"},
+				Series: []Series{{Name: "AAPL", Values: []float64{1, 2, math.NaN(), 3}}}},
+			{ID: "vol", Kind: ChartBar, Labels: []string{"d1", "d2"},
+				Series: []Series{{Name: "a", Values: []float64{5, -2}}, {Name: "b", Values: []float64{1, 2}}}},
+		},
+	}
+}
+
+func TestParseMarkdown(t *testing.T) {
+	blocks := parseMarkdown(sampleDoc().Markdown)
+	var kinds []blockKind
+	for _, b := range blocks {
+		kinds = append(kinds, b.kind)
+	}
+	want := []blockKind{blockHeading, blockParagraph, blockChart, blockList, blockTable, blockCode}
+	if len(kinds) != len(want) {
+		t.Fatalf("kinds = %v, want %v", kinds, want)
+	}
+	for i := range want {
+		if kinds[i] != want[i] {
+			t.Fatalf("kinds = %v, want %v", kinds, want)
+		}
+	}
+	if list := blocks[3]; len(list.items) != 3 || list.items[1].depth != 1 {
+		t.Errorf("list items = %+v", list.items)
+	}
+	if table := blocks[4]; len(table.header) != 2 || len(table.rows) != 1 || table.rows[0][1] != "190.5" {
+		t.Errorf("table = %+v / %+v", table.header, table.rows)
+	}
+}
+
+func TestRenderHTML(t *testing.T) {
+	out := RenderHTML(sampleDoc())
+	for _, want := range []string{
+		"<title>Weekly &lt;Review&gt;</title>",
+		"<strong>rose</strong>",
+		`<a href="https://example.com/a">the source</a>`,
+		"<polyline",
+		"<rect", // the unplaced bar chart follows the text
+		"code &lt;here&gt;",
+		"<td>190.5</td>",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("HTML lacks %q", want)
+		}
+	}
+	if strings.Contains(out, "javascript:") {
+		t.Error("HTML links to a javascript: URL")
+	}
+	if strings.Index(out, "<polyline") > strings.Index(out, "<ul>") {
+		t.Error("placed chart is not where the Markdown put it")
+	}
+}
+
+func TestRenderPDF(t *testing.T) {
+	doc := sampleDoc()
+	doc.Markdown += "\n\nCafé costs €3 — 日本"
+	for range 80 {
+		doc.Markdown += "\n\nFiller paragraph to push the report onto a second page."
+	}
+	data, replaced, err := RenderPDF(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replaced != 2 {
+		t.Errorf("replaced = %d, want 2", replaced)
+	}
+	conf := model.NewDefaultConfiguration()
+	if err := api.Validate(bytes.NewReader(data), conf); err != nil {
+		t.Fatalf("invalid PDF: %v", err)
+	}
+	pages, err := api.PageCount(bytes.NewReader(data), conf)
+	if err != nil || pages < 2 {
+		t.Fatalf("pages = %d, %v", pages, err)
+	}
+	var content strings.Builder
+	err = api.ExtractContent(bytes.NewReader(data), nil, func(r io.Reader, _ int) error {
+		_, err := io.Copy(&content, r)
+		return err
+	}, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"(Weekly review) Tj", "(1 / ", "Caf\xe9 costs \x803 \x97 ??"} {
+		if !strings.Contains(content.String(), want) {
+			t.Errorf("content lacks %q", want)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "reports")
+	res, err := Write(dir, sampleDoc(), []string{FormatHTML, FormatPDF})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Paths) != 2 || !strings.HasSuffix(res.Paths[0], "-weekly-review.html") || !strings.HasSuffix(res.Paths[1], ".pdf") {
+		t.Fatalf("paths = %v", res.Paths)
+	}
+	for _, p := range res.Paths {
+		if _, err := os.Stat(p); err != nil {
+			t.Error(err)
+		}
+	}
+
+	bad := sampleDoc()
+	bad.Charts[0].Series[0].Values = []float64{1}
+	if _, err := Write(dir, bad, []string{FormatHTML}); err == nil {
+		t.Error("a chart with too few values was rendered")
+	}
+	if _, err := Write(dir, sampleDoc(), []string{"docx"}); err == nil {
+		t.Error("an unknown format was accepted")
+	}
+}
+
+func TestOutputs(t *testing.T) {
+	OutputsFrom(context.Background()).Add("ignored")
+	ctx, outputs := WithOutputs(context.Background())
+	OutputsFrom(ctx).Add("a.html", "a.pdf")
+	if got := outputs.Paths(); len(got) != 2 || got[1] != "a.pdf" {
+		t.Errorf("Paths = %v", got)
+	}
+}
+
+func TestNiceTicks(t *testing.T) {
+	ticks := niceTicks(3, 97, 5)
+	if ticks[0] != 0 || ticks[len(ticks)-1] != 100 {
+		t.Errorf("ticks = %v", ticks)
+	}
+	if got := formatTick(2_500_000, []float64{0, 2_500_000, 5_000_000, 50_000_000}); got != "2.5M" {
+		t.Errorf("formatTick = %q", got)
+	}
+}
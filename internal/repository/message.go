@@ -16,6 +16,12 @@ type MessageHistory struct {
 	Timestamp time.Time             `json:"timestamp"`
 	Source    message.MessageSource `json:"source"`
 
+	// Token usage reported for the message (assistant replies only), kept so
+	// a session's cost can be totalled without replaying it.
+	InputTokens  int `json:"input_tokens,omitempty"`
+	OutputTokens int `json:"output_tokens,omitempty"`
+	TotalTokens  int `json:"total_tokens,omitempty"`
+
 	// For tool messages
 	ToolName string         `json:"tool_name,omitempty"`
 	Args     map[string]any `json:"args,omitempty"`
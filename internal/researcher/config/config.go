@@ -12,7 +12,18 @@ import (
 )
 
 type Config struct {
-	Sources []model.Source `json:"sources"`
+	Sources    []model.Source        `json:"sources"`
+	Symbols    []model.SymbolMapping `json:"symbols"`
+	EventStudy EventStudy            `json:"event_study"`
+}
+
+// EventStudy tunes the narrative event study; zero values take the study's
+// defaults.
+type EventStudy struct {
+	Benchmark      string `json:"benchmark"`       // market index abnormal returns are measured against
+	Windows        []int  `json:"windows"`         // post-event windows, in trading days from the event day
+	EstimationDays int    `json:"estimation_days"` // trading days the return model is fitted on
+	PreDays        int    `json:"pre_days"`        // trading days before the event checked for an early move
 }
 
 func Load(path string) (Config, error) {
@@ -44,75 +55,155 @@ func Load(path string) (Config, error) {
 		}
 		cfg.Sources[i] = model.NormalizeSource(src)
 	}
+	for i, m := range cfg.Symbols {
+		if m.Symbol == "" {
+			return Config{}, fmt.Errorf("symbol mapping %d has no symbol", i)
+		}
+		if (m.Entity == "") == (m.Theme == "") {
+			return Config{}, fmt.Errorf("symbol mapping %q needs exactly one of entity or theme", m.Symbol)
+		}
+	}
+	for _, w := range cfg.EventStudy.Windows {
+		if w <= 0 {
+			return Config{}, fmt.Errorf("event_study window %d must be positive", w)
+		}
+	}
 	return cfg, nil
 }
 
+// parseYAMLConfig reads the subset of YAML the config uses: top-level
+// sections holding either a list of flat mappings (sources, symbols) or
+// flat key: value pairs (event_study).
 func parseYAMLConfig(content string) (Config, error) {
 	var cfg Config
 	var current *model.Source
-	inSources := false
+	var mapping *model.SymbolMapping
+	section := ""
+	flush := func() {
+		if current != nil {
+			cfg.Sources = append(cfg.Sources, *current)
+			current = nil
+		}
+		if mapping != nil {
+			cfg.Symbols = append(cfg.Symbols, *mapping)
+			mapping = nil
+		}
+	}
 
 	scanner := bufio.NewScanner(strings.NewReader(content))
 	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
+		raw := scanner.Text()
+		line := strings.TrimSpace(raw)
 		if line == "" || strings.HasPrefix(line, "#") {
 			continue
 		}
-		if line == "sources:" {
-			inSources = true
-			continue
-		}
-		if !inSources {
+		if !strings.HasPrefix(raw, " ") && !strings.HasPrefix(raw, "-") && strings.HasSuffix(line, ":") {
+			flush()
+			section = strings.TrimSuffix(line, ":")
 			continue
 		}
 		if strings.HasPrefix(line, "- ") {
-			if current != nil {
-				cfg.Sources = append(cfg.Sources, *current)
+			flush()
+			switch section {
+			case "sources":
+				current = &model.Source{}
+			case "symbols":
+				mapping = &model.SymbolMapping{}
 			}
-			current = &model.Source{}
 			line = strings.TrimSpace(strings.TrimPrefix(line, "- "))
 			if line == "" {
 				continue
 			}
 		}
-		if current == nil {
-			continue
-		}
 		key, value, ok := strings.Cut(line, ":")
 		if !ok {
 			continue
 		}
+		key = strings.TrimSpace(key)
 		value = trimYAMLScalar(value)
-		switch strings.TrimSpace(key) {
-		case "name":
-			current.Name = value
-		case "type":
-			current.Type = value
-		case "url":
-			current.URL = value
-		case "intake":
-			current.Intake = value
-		case "role":
-			current.Role = value
-		case "trust_tier", "trust-tier", "tier":
-			current.TrustTier = value
-		case "weight":
-			weight, err := strconv.ParseFloat(value, 64)
-			if err != nil {
-				return Config{}, fmt.Errorf("invalid weight %q", value)
+		switch {
+		case section == "sources" && current != nil:
+			if err := setSourceField(current, key, value); err != nil {
+				return Config{}, err
+			}
+		case section == "symbols" && mapping != nil:
+			switch key {
+			case "entity":
+				mapping.Entity = value
+			case "theme":
+				mapping.Theme = value
+			case "symbol":
+				mapping.Symbol = value
+			case "benchmark":
+				mapping.Benchmark = value
+			}
+		case section == "event_study":
+			if err := setEventStudyField(&cfg.EventStudy, key, value); err != nil {
+				return Config{}, err
 			}
-			current.Weight = weight
 		}
 	}
 	if err := scanner.Err(); err != nil {
 		return Config{}, err
 	}
-	if current != nil {
-		cfg.Sources = append(cfg.Sources, *current)
-	}
+	flush()
 	return cfg, nil
 }
 
+func setSourceField(src *model.Source, key, value string) error {
+	switch key {
+	case "name":
+		src.Name = value
+	case "type":
+		src.Type = value
+	case "url":
+		src.URL = value
+	case "intake":
+		src.Intake = value
+	case "role":
+		src.Role = value
+	case "trust_tier", "trust-tier", "tier":
+		src.TrustTier = value
+	case "weight":
+		weight, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid weight %q", value)
+		}
+		src.Weight = weight
+	}
+	return nil
+}
+
+func setEventStudyField(es *EventStudy, key, value string) error {
+	switch key {
+	case "benchmark":
+		es.Benchmark = value
+	case "windows":
+		es.Windows = nil
+		for _, part := range strings.Split(strings.Trim(value, "[]"), ",") {
+			if part = strings.TrimSpace(part); part == "" {
+				continue
+			}
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				return fmt.Errorf("invalid event_study window %q", part)
+			}
+			es.Windows = append(es.Windows, n)
+		}
+	case "estimation_days", "pre_days":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid event_study %s %q", key, value)
+		}
+		if key == "pre_days" {
+			es.PreDays = n
+		} else {
+			es.EstimationDays = n
+		}
+	}
+	return nil
+}
+
 func trimYAMLScalar(value string) string {
 	value = strings.TrimSpace(value)
 	value = strings.Trim(value, "\"'")
@@ -4,6 +4,8 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/fpt/klein-cli/internal/researcher/embed"
 )
 
 func TestLoadYAML(t *testing.T) {
@@ -40,3 +42,62 @@ func TestLoadYAML(t *testing.T) {
 		t.Fatalf("weights = %f/%f, want 0.45/0.65", cfg.Sources[0].Weight, cfg.Sources[1].Weight)
 	}
 }
+
+func TestLoadYAMLEventStudy(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(path, []byte(`sources:
+  - name: markets
+    url: https://example.com/rss.xml
+
+event_study:
+  benchmark: ^GSPC
+  windows: [1, 5]
+  pre_days: 3
+
+symbols:
+  - theme: energy
+    symbol: CL=F
+  - entity: Toyota
+    symbol: 7203.T
+    benchmark: ^N225
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Sources) != 1 || len(cfg.Symbols) != 2 {
+		t.Fatalf("sources/symbols = %d/%d, want 1/2", len(cfg.Sources), len(cfg.Symbols))
+	}
+	if cfg.Symbols[1].Entity != "Toyota" || cfg.Symbols[1].Benchmark != "^N225" {
+		t.Fatalf("mapping = %+v", cfg.Symbols[1])
+	}
+	es := cfg.EventStudy
+	if es.Benchmark != "^GSPC" || len(es.Windows) != 2 || es.Windows[1] != 5 || es.PreDays != 3 {
+		t.Fatalf("event study = %+v", es)
+	}
+
+	if err := os.WriteFile(path, []byte("sources:\n  - name: a\n    url: https://example.com\nsymbols:\n  - symbol: X\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Error("a mapping with neither entity nor theme loaded")
+	}
+}
+
+func TestDefaultConfigLoads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, embed.DefaultConfigYAML, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Symbols) == 0 || len(cfg.EventStudy.Windows) == 0 {
+		t.Errorf("default config has no event study setup: %+v", cfg.EventStudy)
+	}
+}
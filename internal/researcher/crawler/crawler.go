@@ -22,7 +22,6 @@ import (
 	"crypto/sha1"
 	"encoding/hex"
 	"fmt"
-	"io"
 	"net/http"
 	"net/url"
 	"path"
@@ -33,6 +32,8 @@ import (
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/fpt/klein-cli/internal/researcher/model"
+	"github.com/fpt/klein-cli/internal/webarchive"
+	"github.com/fpt/klein-cli/internal/webfetch"
 )
 
 // userAgent is sent on every request. Sites that block default Go user-agents
@@ -41,7 +42,7 @@ const userAgent = "klein-researcher/0.1 (+https://github.com/fpt/klein-cli)"
 
 // defaultTimeout caps any single HTTP request. Listing pages can be large;
 // PDFs especially. 30s is generous for normal IR pages, conservative for
-// adversarial ones.
+// adversarial ones. The web policy on the context can lower it.
 const defaultTimeout = 30 * time.Second
 
 // Result is the structured output of FetchSingle. Body is the extracted
@@ -52,6 +53,7 @@ type Result struct {
 	Title       string
 	Summary     string // short excerpt of the body (≤ 500 chars), suitable for an Event.Summary
 	PublishedAt time.Time
+	Snapshot    string // web archive snapshot ID of the body; empty when the archive is off
 }
 
 // ListingItem is one candidate event surfaced from an index page.
@@ -65,7 +67,7 @@ type ListingItem struct {
 // suitable for wrapping as a model.Event. For PDFs only metadata + URL are
 // captured — the agent uses PDFRead to read the body.
 func FetchSingle(ctx context.Context, target string) (*Result, error) {
-	body, ct, finalURL, err := fetch(ctx, target)
+	body, ct, finalURL, snapshot, err := fetch(ctx, target)
 	if err != nil {
 		return nil, err
 	}
@@ -74,6 +76,7 @@ func FetchSingle(ctx context.Context, target string) (*Result, error) {
 		URL:         finalURL,
 		ContentType: classifyContentType(ct, finalURL),
 		PublishedAt: time.Now().UTC(), // best default — caller can override
+		Snapshot:    snapshot,
 	}
 
 	switch out.ContentType {
@@ -111,7 +114,7 @@ func FetchSingle(ctx context.Context, target string) (*Result, error) {
 //
 // maxItems <= 0 means no cap.
 func FetchListing(ctx context.Context, target string, maxItems int) ([]ListingItem, error) {
-	body, ct, finalURL, err := fetch(ctx, target)
+	body, ct, finalURL, _, err := fetch(ctx, target)
 	if err != nil {
 		return nil, err
 	}
@@ -211,6 +214,7 @@ func ResultToEvent(r *Result, intake, role, trustTier string) model.Event {
 		PublishedAt: r.PublishedAt,
 		Summary:     r.Summary,
 		FetchedAt:   now,
+		Snapshot:    r.Snapshot,
 	}
 	if ev.PublishedAt.IsZero() {
 		ev.PublishedAt = now
@@ -252,32 +256,35 @@ func ListingItemToEvent(item ListingItem, sourceName, intake, role, trustTier st
 
 // ---------- internals ----------
 
-func fetch(ctx context.Context, target string) (body []byte, contentType, finalURL string, err error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
-	if err != nil {
-		return nil, "", "", err
-	}
-	req.Header.Set("User-Agent", userAgent)
-	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/pdf,*/*;q=0.8")
-	req.Header.Set("Accept-Language", "en;q=0.9,ja;q=0.8")
-
-	client := &http.Client{Timeout: defaultTimeout}
-	resp, err := client.Do(req)
+// fetch goes through webfetch, so the SSRF guard and the domain policy on ctx
+// apply to crawls as they do to WebFetch, and through the web archive on ctx,
+// so an ingested page can be cited as it was. snapshot is the archived
+// body's ID, empty when the archive is off.
+func fetch(ctx context.Context, target string) (body []byte, contentType, finalURL, snapshot string, err error) {
+	resp, snap, err := webarchive.Get(ctx, webfetch.Request{
+		URL: target,
+		Header: http.Header{
+			"User-Agent":      {userAgent},
+			"Accept":          {"text/html,application/xhtml+xml,application/pdf,*/*;q=0.8"},
+			"Accept-Language": {"en;q=0.9,ja;q=0.8"},
+		},
+		// Cap body to 8 MiB. Larger PDFs blow our memory budget for a tool
+		// call; only their head is needed.
+		MaxBytes: 8 << 20,
+		Timeout:  defaultTimeout,
+		Truncate: true,
+	})
 	if err != nil {
-		return nil, "", "", err
+		return nil, "", "", "", err
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, "", "", fmt.Errorf("%s returned HTTP %d", target, resp.StatusCode)
+		return nil, "", "", "", fmt.Errorf("%s returned HTTP %d", target, resp.StatusCode)
 	}
-
-	// Cap body to 8 MiB. Larger PDFs blow our memory budget for a tool call.
-	body, err = io.ReadAll(io.LimitReader(resp.Body, 8<<20))
-	if err != nil {
-		return nil, "", "", err
+	if snap != nil {
+		snapshot = snap.ID
 	}
-	return body, resp.Header.Get("Content-Type"), resp.Request.URL.String(), nil
+	return resp.Body, resp.ContentType(), resp.URL, snapshot, nil
 }
 
 func classifyContentType(ct, target string) string {
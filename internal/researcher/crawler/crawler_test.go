@@ -7,8 +7,28 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/fpt/klein-cli/internal/webfetch"
 )
 
+// localCtx lets the crawler reach httptest servers, which listen on
+// loopback; the default web policy refuses them.
+func localCtx() context.Context {
+	return webfetch.WithPolicy(context.Background(), webfetch.Policy{AllowPrivateNetworks: true})
+}
+
+func TestFetch_RefusesPrivateAddressesByDefault(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		t.Error("the crawler reached a loopback server")
+	}))
+	defer srv.Close()
+
+	_, err := FetchSingle(context.Background(), srv.URL)
+	if fe, ok := webfetch.AsError(err); !ok || fe.Reason != webfetch.ReasonPrivateAddress {
+		t.Errorf("err = %v; want a private_address refusal", err)
+	}
+}
+
 // fakeListing serves an HTML page mimicking a Japanese corporate IR layout —
 // each announcement is an <li> with a date label and a link to a PDF/details
 // page. The crawler should extract these as ListingItems with parsed dates.
@@ -45,7 +65,7 @@ func TestFetchListing_ParsesDatedJapaneseEntries(t *testing.T) {
 	}))
 	defer srv.Close()
 
-	items, err := FetchListing(context.Background(), srv.URL, 10)
+	items, err := FetchListing(localCtx(), srv.URL, 10)
 	if err != nil {
 		t.Fatalf("FetchListing: %v", err)
 	}
@@ -111,7 +131,7 @@ func TestFetchListing_KeepsLongUndatedTitles(t *testing.T) {
 	}))
 	defer srv.Close()
 
-	items, err := FetchListing(context.Background(), srv.URL, 10)
+	items, err := FetchListing(localCtx(), srv.URL, 10)
 	if err != nil {
 		t.Fatalf("FetchListing: %v", err)
 	}
@@ -145,7 +165,7 @@ func TestFetchListing_FiltersJapaneseNavChrome(t *testing.T) {
 	}))
 	defer srv.Close()
 
-	items, err := FetchListing(context.Background(), srv.URL, 20)
+	items, err := FetchListing(localCtx(), srv.URL, 20)
 	if err != nil {
 		t.Fatalf("FetchListing: %v", err)
 	}
@@ -177,7 +197,7 @@ func TestFetchSingle_HTMLExtractsTitleAndDescription(t *testing.T) {
 	}))
 	defer srv.Close()
 
-	r, err := FetchSingle(context.Background(), srv.URL)
+	r, err := FetchSingle(localCtx(), srv.URL)
 	if err != nil {
 		t.Fatalf("FetchSingle: %v", err)
 	}
@@ -203,7 +223,7 @@ func TestFetchSingle_PDFRecordsPointerOnly(t *testing.T) {
 	}))
 	defer srv.Close()
 
-	r, err := FetchSingle(context.Background(), srv.URL+"/files/200A-j.pdf")
+	r, err := FetchSingle(localCtx(), srv.URL+"/files/200A-j.pdf")
 	if err != nil {
 		t.Fatalf("FetchSingle: %v", err)
 	}
@@ -13,6 +13,7 @@ package duckdb
 import (
 	"bytes"
 	"context"
+	"encoding/csv"
 	"errors"
 	"fmt"
 	"os"
@@ -46,6 +47,44 @@ const MaxResultBytes = 16 << 10
 // are phrased in JST business hours). Pass an explicit `SET TimeZone` in the
 // user SQL to override.
 func Query(ctx context.Context, dataDir, sql string) (string, error) {
+	out, err := run(ctx, dataDir, sql, defaultOutputMode)
+	if err != nil {
+		return "", err
+	}
+	if len(out) > MaxResultBytes {
+		out = out[:MaxResultBytes] + fmt.Sprintf("\n\n…(truncated, %d bytes total — narrow the query with LIMIT/WHERE)", len(out))
+	}
+	return out, nil
+}
+
+// MaxTableRows caps the rows QueryTable returns.
+const MaxTableRows = 10000
+
+// QueryTable runs `sql` like Query and returns its result as columns and
+// rows of text, for callers that use the values rather than show them (the
+// report charts). The SQL should end in a single SELECT; rows beyond
+// MaxTableRows are an error.
+func QueryTable(ctx context.Context, dataDir, sql string) ([]string, [][]string, error) {
+	out, err := run(ctx, dataDir, sql, "csv")
+	if err != nil {
+		return nil, nil, err
+	}
+	records, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+	if err != nil {
+		return nil, nil, fmt.Errorf("parsing duckdb output: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, nil, fmt.Errorf("query returned no columns")
+	}
+	if len(records)-1 > MaxTableRows {
+		return nil, nil, fmt.Errorf("query returned %d rows (max %d) — aggregate or LIMIT it", len(records)-1, MaxTableRows)
+	}
+	return records[0], records[1:], nil
+}
+
+// run pipes the prelude and `sql` through `duckdb -<mode>` and returns
+// stdout.
+func run(ctx context.Context, dataDir, sql, mode string) (string, error) {
 	if _, err := exec.LookPath("duckdb"); err != nil {
 		return "", ErrNotInstalled
 	}
@@ -58,7 +97,7 @@ func Query(ctx context.Context, dataDir, sql string) (string, error) {
 		return "", err
 	}
 
-	cmd := exec.CommandContext(ctx, "duckdb", "-"+defaultOutputMode)
+	cmd := exec.CommandContext(ctx, "duckdb", "-"+mode)
 	cmd.Stdin = strings.NewReader(prelude + "\n" + sql + "\n")
 
 	var stdout, stderr bytes.Buffer
@@ -73,12 +112,7 @@ func Query(ctx context.Context, dataDir, sql string) (string, error) {
 		}
 		return "", fmt.Errorf("duckdb: %s", errMsg)
 	}
-
-	out := stdout.String()
-	if len(out) > MaxResultBytes {
-		out = out[:MaxResultBytes] + fmt.Sprintf("\n\n…(truncated, %d bytes total — narrow the query with LIMIT/WHERE)", len(stdout.String()))
-	}
-	return out, nil
+	return stdout.String(), nil
 }
 
 // BuildPrelude returns the SQL that sets up the `events` and `narratives`
@@ -147,6 +147,22 @@ func TestQuery_TimestampsAreUsableWithoutCast(t *testing.T) {
 	}
 }
 
+// TestQueryTable_Live checks the CSV path returns header and typed-as-text
+// rows.
+func TestQueryTable_Live(t *testing.T) {
+	if _, err := exec.LookPath("duckdb"); err != nil {
+		t.Skip("duckdb CLI not installed; skipping live test")
+	}
+	cols, rows, err := QueryTable(context.Background(), t.TempDir(),
+		`SELECT * FROM (VALUES ('2026-06-19', 2), ('2026-06-20', 1)) t(day, n) ORDER BY day;`)
+	if err != nil {
+		t.Fatalf("QueryTable: %v", err)
+	}
+	if len(cols) != 2 || cols[0] != "day" || len(rows) != 2 || rows[0][1] != "2" {
+		t.Errorf("got %v %v", cols, rows)
+	}
+}
+
 // TestQuery_NotInstalled is a smoke test that the not-installed path returns
 // the friendly error message. Skipped when duckdb IS installed (we can't fake
 // the lookup easily).
@@ -0,0 +1,351 @@
+// Package eventstudy links narratives to price action. For each narrative
+// episode and each symbol its themes or entities map to, it fits a market
+// model (the symbol's daily return against a benchmark's) on the trading
+// days before the narrative first appeared, and reports the cumulative
+// abnormal return (CAR) — the move the model does not explain — over a
+// pre-event window and over post-event windows, with t-statistics.
+//
+// A narrative "preceded" a move when a complete post-event window is
+// significant and the pre-event window is not. That is a timing
+// observation, not a causal claim: the study does not know what else
+// happened on those days.
+package eventstudy
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/fpt/klein-cli/internal/researcher/config"
+	"github.com/fpt/klein-cli/internal/researcher/model"
+)
+
+// Defaults for a study whose config leaves a value unset.
+const (
+	DefaultBenchmark      = "^GSPC"
+	DefaultEstimationDays = 60
+	DefaultPreDays        = 5
+
+	// Significance is the |t| from which a window counts as a move (about
+	// the 5% level).
+	Significance = 1.96
+
+	// noBenchmark in a mapping measures returns against their own
+	// pre-event mean instead of a market model.
+	noBenchmark = "none"
+)
+
+// DefaultWindows are the post-event windows, in trading days, when the
+// config sets none.
+var DefaultWindows = []int{1, 5, 20}
+
+// Bar is one trading day's close.
+type Bar struct {
+	Date  time.Time
+	Close float64
+	// End is when the session closed. Zero means unknown: a narrative then
+	// counts toward the session only if it appeared by Date.
+	End time.Time
+}
+
+// PriceSource supplies daily history; the tool layer backs it with the
+// market tools.
+type PriceSource interface {
+	// DailyBars returns symbol's daily bars between from and to, oldest
+	// first.
+	DailyBars(ctx context.Context, symbol string, from, to time.Time) ([]Bar, error)
+}
+
+// Study is one configured event study.
+type Study struct {
+	Prices         PriceSource
+	Mappings       []model.SymbolMapping
+	Benchmark      string
+	Windows        []int
+	EstimationDays int
+	PreDays        int
+	Now            func() time.Time
+}
+
+// New returns the study cfg describes, with defaults for what it leaves
+// unset.
+func New(prices PriceSource, cfg config.Config) Study {
+	s := Study{
+		Prices:         prices,
+		Mappings:       cfg.Symbols,
+		Benchmark:      cfg.EventStudy.Benchmark,
+		Windows:        cfg.EventStudy.Windows,
+		EstimationDays: cfg.EventStudy.EstimationDays,
+		PreDays:        cfg.EventStudy.PreDays,
+	}
+	return s.withDefaults()
+}
+
+func (s Study) withDefaults() Study {
+	if s.Benchmark == "" {
+		s.Benchmark = DefaultBenchmark
+	}
+	if len(s.Windows) == 0 {
+		s.Windows = DefaultWindows
+	}
+	if s.EstimationDays <= 0 {
+		s.EstimationDays = DefaultEstimationDays
+	}
+	if s.PreDays <= 0 {
+		s.PreDays = DefaultPreDays
+	}
+	if s.Now == nil {
+		s.Now = func() time.Time { return time.Now().UTC() }
+	}
+	return s
+}
+
+// Matches returns the mappings that apply to n, one per symbol. A mapping
+// with neither Entity nor Theme applies to every narrative.
+func Matches(n model.Narrative, mappings []model.SymbolMapping) []model.SymbolMapping {
+	seen := map[string]bool{}
+	var out []model.SymbolMapping
+	for _, m := range mappings {
+		if seen[m.Symbol] || !matches(n, m) {
+			continue
+		}
+		seen[m.Symbol] = true
+		out = append(out, m)
+	}
+	return out
+}
+
+func matches(n model.Narrative, m model.SymbolMapping) bool {
+	if m.Theme == "" && m.Entity == "" {
+		return true
+	}
+	if m.Theme != "" {
+		for _, t := range n.Themes {
+			if strings.EqualFold(t, m.Theme) {
+				return true
+			}
+		}
+		return false
+	}
+	want := strings.ToLower(m.Entity)
+	for _, e := range n.Entities {
+		if strings.Contains(strings.ToLower(e), want) {
+			return true
+		}
+	}
+	return false
+}
+
+// Run studies every mapped symbol of every narrative. Results that cannot
+// be computed (no history, too few days) carry a Note; only a cancelled
+// ctx is an error.
+func (s Study) Run(ctx context.Context, narratives []model.Narrative) ([]model.EventStudyResult, error) {
+	s = s.withDefaults()
+	type job struct {
+		n model.Narrative
+		m model.SymbolMapping
+	}
+	var jobs []job
+	var earliest time.Time
+	for _, n := range narratives {
+		if n.FirstSeen.IsZero() {
+			continue
+		}
+		for _, m := range Matches(n, s.Mappings) {
+			jobs = append(jobs, job{n, m})
+			if earliest.IsZero() || n.FirstSeen.Before(earliest) {
+				earliest = n.FirstSeen
+			}
+		}
+	}
+	if len(jobs) == 0 {
+		return nil, nil
+	}
+
+	// One fetch per symbol covers every episode: estimation and pre-event
+	// days before the earliest, in calendar days with room for holidays.
+	lookback := time.Duration((s.EstimationDays+s.PreDays+1)*7/5+14) * 24 * time.Hour
+	from, to := earliest.Add(-lookback), s.Now()
+	type fetched struct {
+		bars []Bar
+		err  error
+	}
+	cache := map[string]fetched{}
+	bars := func(symbol string) ([]Bar, error) {
+		if f, ok := cache[symbol]; ok {
+			return f.bars, f.err
+		}
+		b, err := s.Prices.DailyBars(ctx, symbol, from, to)
+		cache[symbol] = fetched{b, err}
+		return b, err
+	}
+
+	results := make([]model.EventStudyResult, 0, len(jobs))
+	for _, j := range jobs {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+		benchmark := s.Benchmark
+		if j.m.Benchmark != "" {
+			benchmark = j.m.Benchmark
+		}
+		if strings.EqualFold(benchmark, noBenchmark) || benchmark == j.m.Symbol {
+			benchmark = ""
+		}
+		r := model.EventStudyResult{
+			NarrativeID: j.n.ID,
+			Label:       j.n.Label,
+			FirstSeen:   j.n.FirstSeen,
+			Symbol:      j.m.Symbol,
+			Benchmark:   benchmark,
+		}
+		sym, err := bars(j.m.Symbol)
+		if err != nil {
+			r.Note = fmt.Sprintf("no prices for %s: %v", j.m.Symbol, err)
+			results = append(results, r)
+			continue
+		}
+		var bench []Bar
+		if benchmark != "" {
+			if bench, err = bars(benchmark); err != nil {
+				r.Note = fmt.Sprintf("no prices for benchmark %s: %v", benchmark, err)
+				results = append(results, r)
+				continue
+			}
+		}
+		s.compute(&r, sym, bench)
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// compute fills r from the symbol's and, when there is one, the
+// benchmark's bars.
+func (s Study) compute(r *model.EventStudyResult, sym, bench []Bar) {
+	dates, ends, rets, mkt := returns(sym, bench)
+	// Day 0 is the first session that closed after the narrative appeared, so
+	// one first seen after the close is not credited with that day's move.
+	e0 := -1
+	for i, end := range ends {
+		if !end.Before(r.FirstSeen) {
+			e0 = i
+			break
+		}
+	}
+	if e0 < 0 {
+		r.Note = "no session has closed since the narrative appeared yet"
+		return
+	}
+	r.EventDate = dates[e0]
+
+	estEnd := e0 - s.PreDays // exclusive
+	estStart := max(0, estEnd-s.EstimationDays)
+	minDays := max(10, s.EstimationDays/2)
+	if estEnd-estStart < minDays {
+		r.Note = fmt.Sprintf("only %d trading days of history before the event (need %d)", max(0, estEnd-estStart), minDays)
+		return
+	}
+	alpha, beta, sigma := fit(rets[estStart:estEnd], mkt[estStart:estEnd], bench != nil)
+	if sigma == 0 {
+		r.Note = "no price variation before the event"
+		return
+	}
+
+	abnormal := make([]float64, len(rets))
+	for i := range rets {
+		abnormal[i] = rets[i] - alpha - beta*mkt[i]
+	}
+	window := func(from, to int) model.AbnormalReturn {
+		ar := model.AbnormalReturn{From: from, To: to, Complete: e0+to < len(abnormal)}
+		days := 0
+		for i := e0 + from; i <= e0+to && i < len(abnormal); i++ {
+			ar.CAR += abnormal[i]
+			days++
+		}
+		if days > 0 {
+			ar.TStat = ar.CAR / (sigma * math.Sqrt(float64(days)))
+		}
+		return ar
+	}
+
+	r.Pre = window(-s.PreDays, -1)
+	for _, w := range s.Windows {
+		post := window(0, w-1)
+		r.Post = append(r.Post, post)
+		if post.Complete && math.Abs(post.TStat) >= Significance && math.Abs(r.Pre.TStat) < Significance {
+			r.Preceded = true
+		}
+	}
+}
+
+// returns turns bars into daily simple returns on the days both the symbol
+// and the benchmark traded; mkt is all zeros without a benchmark. ends holds
+// each session's close, or its Date where that is unknown.
+func returns(sym, bench []Bar) (dates, ends []time.Time, rets, mkt []float64) {
+	benchClose := map[string]float64{}
+	for _, b := range bench {
+		benchClose[b.Date.UTC().Format(time.DateOnly)] = b.Close
+	}
+	var prevClose, prevBench float64
+	first := true
+	for _, b := range sym {
+		day := b.Date.UTC().Truncate(24 * time.Hour)
+		bc, ok := benchClose[day.Format(time.DateOnly)]
+		if bench != nil && !ok {
+			continue
+		}
+		if b.Close <= 0 || (bench != nil && bc <= 0) {
+			continue
+		}
+		if !first {
+			dates = append(dates, day)
+			end := b.End
+			if end.IsZero() {
+				end = b.Date
+			}
+			ends = append(ends, end)
+			rets = append(rets, b.Close/prevClose-1)
+			m := 0.0
+			if bench != nil {
+				m = bc/prevBench - 1
+			}
+			mkt = append(mkt, m)
+		}
+		prevClose, prevBench, first = b.Close, bc, false
+	}
+	return dates, ends, rets, mkt
+}
+
+// fit estimates r = alpha + beta·m by least squares (beta 0 without a
+// market) and returns the residuals' standard deviation.
+func fit(r, m []float64, market bool) (alpha, beta, sigma float64) {
+	n := float64(len(r))
+	var meanR, meanM float64
+	for i := range r {
+		meanR += r[i]
+		meanM += m[i]
+	}
+	meanR /= n
+	meanM /= n
+	params := 1.0
+	if market {
+		var cov, varM float64
+		for i := range r {
+			cov += (r[i] - meanR) * (m[i] - meanM)
+			varM += (m[i] - meanM) * (m[i] - meanM)
+		}
+		if varM > 0 {
+			beta = cov / varM
+		}
+		params = 2
+	}
+	alpha = meanR - beta*meanM
+	var ss float64
+	for i := range r {
+		e := r[i] - alpha - beta*m[i]
+		ss += e * e
+	}
+	return alpha, beta, math.Sqrt(ss / (n - params))
+}
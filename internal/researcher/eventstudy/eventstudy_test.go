@@ -0,0 +1,189 @@
+package eventstudy
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/fpt/klein-cli/internal/researcher/model"
+)
+
+type fakePrices map[string][]Bar
+
+func (f fakePrices) DailyBars(_ context.Context, symbol string, from, to time.Time) ([]Bar, error) {
+	bars, ok := f[symbol]
+	if !ok {
+		return nil, errors.New("unknown symbol")
+	}
+	var out []Bar
+	for _, b := range bars {
+		if !b.Date.Before(from) && !b.Date.After(to) {
+			out = append(out, b)
+		}
+	}
+	return out, nil
+}
+
+// series builds weekday bars from start whose daily returns are ret(i).
+func series(start time.Time, days int, ret func(i int) float64) []Bar {
+	var bars []Bar
+	price := 100.0
+	for d := start; len(bars) < days; d = d.AddDate(0, 0, 1) {
+		if d.Weekday() == time.Saturday || d.Weekday() == time.Sunday {
+			continue
+		}
+		if len(bars) > 0 {
+			price *= 1 + ret(len(bars))
+		}
+		bars = append(bars, Bar{Date: d, Close: price})
+	}
+	return bars
+}
+
+func TestRun(t *testing.T) {
+	start := time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC)
+	wiggle := func(i int) float64 { return 0.01 * math.Sin(float64(i)*1.7) }
+	bench := series(start, 120, wiggle)
+	event := bench[90].Date
+	// NVDA moves 1.5× the market plus a 6% jump on the event day.
+	nvda := series(start, 120, func(i int) float64 {
+		r := 1.5*wiggle(i) + 0.002*math.Cos(float64(i)*2.3)
+		if i == 90 {
+			r += 0.06
+		}
+		return r
+	})
+	// FLAT only tracks the market.
+	flat := series(start, 120, func(i int) float64 { return wiggle(i) + 0.002*math.Cos(float64(i)*2.3) })
+
+	s := Study{
+		Prices: fakePrices{"^GSPC": bench, "NVDA": nvda, "FLAT": flat},
+		Mappings: []model.SymbolMapping{
+			{Entity: "nvidia", Symbol: "NVDA"},
+			{Theme: "ai", Symbol: "FLAT"},
+			{Theme: "ai", Symbol: "NVDA"}, // already mapped by entity
+			{Theme: "energy", Symbol: "CL=F"},
+		},
+		Windows: []int{1, 5, 40},
+		Now:     func() time.Time { return start.AddDate(1, 0, 0) },
+	}
+	n := model.Narrative{ID: "n1", Label: "AI chips", Themes: []string{"AI"}, Entities: []string{"Nvidia Corp"},
+		FirstSeen: event.Add(-6 * time.Hour)} // a Sunday evening: the event day is Monday
+	results, err := s.Run(context.Background(), []model.Narrative{n})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Symbol != "NVDA" || results[1].Symbol != "FLAT" {
+		t.Fatalf("results = %+v", results)
+	}
+
+	r := results[0]
+	if r.Note != "" || !r.EventDate.Equal(event) || r.Benchmark != "^GSPC" {
+		t.Fatalf("NVDA = %+v", r)
+	}
+	if len(r.Post) != 3 || r.Post[0].From != 0 || r.Post[0].To != 0 || r.Post[1].To != 4 {
+		t.Fatalf("windows = %+v", r.Post)
+	}
+	if car := r.Post[0].CAR; car < 0.05 || car > 0.07 {
+		t.Errorf("CAR[0,0] = %v, want about 0.06", car)
+	}
+	if !r.Post[0].Complete || r.Post[2].Complete {
+		t.Errorf("completeness = %v %v", r.Post[0].Complete, r.Post[2].Complete)
+	}
+	if !r.Preceded || math.Abs(r.Pre.TStat) >= Significance {
+		t.Errorf("Preceded = %v, pre t = %v", r.Preceded, r.Pre.TStat)
+	}
+	if f := results[1]; f.Preceded || math.Abs(f.Post[0].CAR) > 0.01 {
+		t.Errorf("FLAT = %+v", f)
+	}
+}
+
+func TestRunDayZeroIsFirstCloseAfterFirstSeen(t *testing.T) {
+	start := time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC)
+	wiggle := func(i int) float64 { return 0.01 * math.Sin(float64(i)*1.7) }
+	// Sessions open at 14:30 UTC and close at 21:00; the jump is on day 90.
+	timed := func(bars []Bar) []Bar {
+		for i := range bars {
+			d := bars[i].Date
+			bars[i].Date, bars[i].End = d.Add(14*time.Hour+30*time.Minute), d.Add(21*time.Hour)
+		}
+		return bars
+	}
+	bench := timed(series(start, 120, wiggle))
+	nvda := timed(series(start, 120, func(i int) float64 {
+		r := 1.5*wiggle(i) + 0.002*math.Cos(float64(i)*2.3)
+		if i == 90 {
+			r += 0.06
+		}
+		return r
+	}))
+	jumpDay := bench[90].End.Truncate(24 * time.Hour)
+	s := Study{
+		Prices:   fakePrices{"^GSPC": bench, "NVDA": nvda},
+		Mappings: []model.SymbolMapping{{Theme: "ai", Symbol: "NVDA"}},
+		Now:      func() time.Time { return start.AddDate(1, 0, 0) },
+	}
+
+	for _, tc := range []struct {
+		name      string
+		firstSeen time.Time
+		eventDate time.Time
+		preceded  bool
+	}{
+		{"during the session", bench[90].End.Add(-time.Hour), jumpDay, true},
+		{"after the close", bench[90].End.Add(time.Hour), jumpDay.AddDate(0, 0, 1), false},
+	} {
+		results, err := s.Run(context.Background(), []model.Narrative{{ID: "n", Themes: []string{"ai"}, FirstSeen: tc.firstSeen}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := results[0]
+		if !r.EventDate.Equal(tc.eventDate) || r.Preceded != tc.preceded {
+			t.Errorf("%s: event date %s, preceded %v; want %s, %v",
+				tc.name, r.EventDate.Format(time.DateOnly), r.Preceded, tc.eventDate.Format(time.DateOnly), tc.preceded)
+		}
+	}
+}
+
+func TestRunNotes(t *testing.T) {
+	start := time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC)
+	bars := series(start, 30, func(i int) float64 { return 0.01 * math.Sin(float64(i)) })
+	s := Study{
+		Prices:   fakePrices{"X": bars},
+		Mappings: []model.SymbolMapping{{Theme: "t", Symbol: "X", Benchmark: "none"}, {Theme: "t", Symbol: "MISSING"}},
+		Now:      func() time.Time { return start.AddDate(0, 3, 0) },
+	}
+	results, err := s.Run(context.Background(), []model.Narrative{
+		{ID: "early", Themes: []string{"t"}, FirstSeen: bars[10].Date},
+		{ID: "late", Themes: []string{"t"}, FirstSeen: start.AddDate(0, 2, 0)},
+		{ID: "unmapped", Themes: []string{"other"}, FirstSeen: bars[10].Date},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 4 {
+		t.Fatalf("results = %+v", results)
+	}
+	for _, r := range results {
+		if r.Note == "" || r.Preceded {
+			t.Errorf("%s/%s: want a note, got %+v", r.NarrativeID, r.Symbol, r)
+		}
+	}
+	if results[0].Benchmark != "" {
+		t.Errorf("benchmark none kept %q", results[0].Benchmark)
+	}
+}
+
+func TestMatches(t *testing.T) {
+	n := model.Narrative{Themes: []string{"Energy"}, Entities: []string{"日銀総裁"}}
+	got := Matches(n, []model.SymbolMapping{
+		{Theme: "energy", Symbol: "CL=F"},
+		{Theme: "ener", Symbol: "NO"},
+		{Entity: "日銀", Symbol: "^N225"},
+	})
+	if len(got) != 2 || got[0].Symbol != "CL=F" || got[1].Symbol != "^N225" {
+		t.Errorf("Matches = %+v", got)
+	}
+}
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"fmt"
 	"strings"
 	"time"
 )
@@ -27,6 +28,9 @@ type Event struct {
 	PublishedAt time.Time `json:"published_at"`
 	Summary     string    `json:"summary,omitempty"`
 	FetchedAt   time.Time `json:"fetched_at"`
+	// Snapshot is the web archive snapshot ID of the page as ingested, for
+	// citing it with WebArchiveGet; empty when it was not archived.
+	Snapshot string `json:"snapshot,omitempty"`
 }
 
 type Narrative struct {
@@ -54,6 +58,46 @@ type Narrative struct {
 	PreviousEvents        int            `json:"previous_events"`
 }
 
+// SymbolMapping ties narratives to a traded symbol for the event study: a
+// narrative maps when one of its entities contains Entity (case-insensitive)
+// or one of its themes is Theme.
+type SymbolMapping struct {
+	Entity string `json:"entity,omitempty"`
+	Theme  string `json:"theme,omitempty"`
+	Symbol string `json:"symbol"`
+	// Benchmark overrides the study's benchmark for this symbol; "none"
+	// measures returns against their own pre-event mean.
+	Benchmark string `json:"benchmark,omitempty"`
+}
+
+// EventStudyResult is the price reaction of one symbol to one narrative
+// episode.
+type EventStudyResult struct {
+	NarrativeID string    `json:"narrative_id"`
+	Label       string    `json:"label"`
+	FirstSeen   time.Time `json:"first_seen"`
+	Symbol      string    `json:"symbol"`
+	Benchmark   string    `json:"benchmark,omitempty"` // empty: mean-adjusted returns
+	// EventDate is the first trading day whose session closed after FirstSeen.
+	EventDate time.Time        `json:"event_date"`
+	Pre       AbnormalReturn   `json:"pre"`
+	Post      []AbnormalReturn `json:"post"`
+	// Preceded is set when a complete post-event window moved significantly
+	// and the pre-event window did not: the narrative came before the move.
+	Preceded bool   `json:"preceded"`
+	Note     string `json:"note,omitempty"` // why nothing was computed
+}
+
+// AbnormalReturn is the cumulative abnormal return over trading days
+// [From, To] relative to the event day.
+type AbnormalReturn struct {
+	From     int     `json:"from"`
+	To       int     `json:"to"`
+	CAR      float64 `json:"car"`    // fraction: 0.021 is +2.1%
+	TStat    float64 `json:"t_stat"` // CAR over its estimation standard error
+	Complete bool    `json:"complete"`
+}
+
 const (
 	RoleSignal  = "signal"
 	RoleOutcome = "outcome"
@@ -65,6 +109,32 @@ const (
 	TrustOutcome   = "outcome"
 )
 
+// Summary is r on one line, e.g. "NVDA vs ^GSPC from 2026-06-22:
+// CAR[-5,-1] +0.4% (t 0.31); CAR[0,0] +6.0% (t 5.20)". Windows that run
+// past the last close are marked partial.
+func (r EventStudyResult) Summary() string {
+	against := "vs " + r.Benchmark
+	if r.Benchmark == "" {
+		against = "(mean-adjusted)"
+	}
+	if r.Note != "" {
+		return fmt.Sprintf("%s %s: %s", r.Symbol, against, r.Note)
+	}
+	parts := []string{r.Pre.String()}
+	for _, w := range r.Post {
+		parts = append(parts, w.String())
+	}
+	return fmt.Sprintf("%s %s from %s: %s", r.Symbol, against, r.EventDate.Format("2006-01-02"), strings.Join(parts, "; "))
+}
+
+func (a AbnormalReturn) String() string {
+	partial := ""
+	if !a.Complete {
+		partial = ", partial"
+	}
+	return fmt.Sprintf("CAR[%d,%d] %+.1f%% (t %.2f%s)", a.From, a.To, a.CAR*100, a.TStat, partial)
+}
+
 func NormalizeSource(src Source) Source {
 	src.Type = defaultString(src.Type, "rss")
 	src.Intake = defaultString(src.Intake, "general")
@@ -18,6 +18,9 @@ type Extractor struct {
 	Now func() time.Time
 }
 
+// DefaultWindowDays is the analysis window when Options leaves it unset.
+const DefaultWindowDays = 7
+
 type Options struct {
 	WindowDays int
 	Limit      int
@@ -43,7 +46,7 @@ var entityRE = regexp.MustCompile(`\b[A-Z][A-Za-z0-9&.-]*(?:\s+[A-Z][A-Za-z0-9&.
 
 func (e Extractor) Extract(events []model.Event, previous []model.Event, opts Options) []model.Narrative {
 	if opts.WindowDays <= 0 {
-		opts.WindowDays = 7
+		opts.WindowDays = DefaultWindowDays
 	}
 	if opts.Limit <= 0 {
 		opts.Limit = 20
@@ -333,7 +336,12 @@ func evidenceRank(ev model.Event) float64 {
 }
 
 func evidenceLine(ev model.Event) string {
-	return fmt.Sprintf("[%s/%s/%s] %s: %s", defaultValue(ev.Role, model.RoleSignal), defaultValue(ev.TrustTier, model.TrustNews), defaultValue(ev.Intake, "general"), ev.Source, ev.Title)
+	line := fmt.Sprintf("[%s/%s/%s] %s: %s", defaultValue(ev.Role, model.RoleSignal), defaultValue(ev.TrustTier, model.TrustNews), defaultValue(ev.Intake, "general"), ev.Source, ev.Title)
+	if ev.Snapshot != "" {
+		// The archived copy the event was ingested from, for WebArchiveGet.
+		line += fmt.Sprintf(" (snapshot %.12s)", ev.Snapshot)
+	}
+	return line
 }
 
 func defaultValue(value, fallback string) string {
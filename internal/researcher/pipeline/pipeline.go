@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/fpt/klein-cli/internal/researcher/config"
+	"github.com/fpt/klein-cli/internal/researcher/eventstudy"
 	"github.com/fpt/klein-cli/internal/researcher/model"
 	"github.com/fpt/klein-cli/internal/researcher/narrative"
 	"github.com/fpt/klein-cli/internal/researcher/source"
@@ -18,7 +19,8 @@ import (
 // Pipeline runs the researcher workflow:
 //
 //	Fetch  → pull RSS/Atom feeds, normalise events, append-only-store as JSONL.
-//	Analyze → cluster events into narratives, score, write JSON + markdown.
+//	Analyze → cluster events into narratives, score, write JSON + markdown;
+//	          with Prices set, also run the narrative-to-market event study.
 //
 // Agent-based narrative refinement (LLM-driven post-processing of the
 // deterministic cluster) is intentionally not included — the heuristic
@@ -32,7 +34,9 @@ type Pipeline struct {
 	Logger         *slog.Logger
 	NarrativeLimit int
 	WindowDays     int
-	Now            func() time.Time
+	// Prices backs the event study; nil skips it.
+	Prices eventstudy.PriceSource
+	Now    func() time.Time
 }
 
 func (p Pipeline) Run(ctx context.Context) ([]model.Narrative, error) {
@@ -90,13 +94,55 @@ func (p Pipeline) Analyze(ctx context.Context) ([]model.Narrative, error) {
 	if err := store.WriteNarratives(p.narrativesPath(), narratives); err != nil {
 		return nil, err
 	}
-	if err := store.WriteNarrativeReport(p.reportPath(), narratives, p.now()); err != nil {
+	studies := p.studyNarratives(ctx, narratives)
+	if err := store.WriteNarrativeReport(p.reportPath(), narratives, p.now(), studies); err != nil {
 		return nil, err
 	}
 	p.log().Info("narratives written", "count", len(narratives), "json", p.narrativesPath(), "report", p.reportPath())
 	return narratives, nil
 }
 
+// studyNarratives records the narratives in the episode history and, with
+// Prices set, runs the event study over every episode. Failures only cost
+// the report its market section.
+func (p Pipeline) studyNarratives(ctx context.Context, narratives []model.Narrative) []model.EventStudyResult {
+	gap := time.Duration(p.windowDays()) * 24 * time.Hour
+	history, err := store.MergeNarrativeHistory(p.HistoryPath(), narratives, gap)
+	if err != nil {
+		p.log().Warn("narrative history update failed", "error", err)
+		history = narratives
+	}
+	if p.Prices == nil {
+		return nil
+	}
+	studies, err := eventstudy.New(p.Prices, p.Config).Run(ctx, history)
+	if err != nil {
+		p.log().Warn("event study failed", "error", err)
+		return nil
+	}
+	if err := store.WriteEventStudy(p.eventStudyPath(), studies); err != nil {
+		p.log().Warn("event study write failed", "error", err)
+	}
+	p.log().Info("event study written", "results", len(studies), "path", p.eventStudyPath())
+	return studies
+}
+
+// HistoryPath is where narrative episodes accumulate across runs.
+func (p Pipeline) HistoryPath() string {
+	return filepath.Join(p.dataDir(), "narrative_history.json")
+}
+
+func (p Pipeline) eventStudyPath() string {
+	return filepath.Join(p.dataDir(), "event_study.json")
+}
+
+func (p Pipeline) windowDays() int {
+	if p.WindowDays > 0 {
+		return p.WindowDays
+	}
+	return narrative.DefaultWindowDays
+}
+
 func (p Pipeline) eventsPath() string {
 	return filepath.Join(p.dataDir(), "events.jsonl")
 }
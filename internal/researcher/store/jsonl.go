@@ -78,7 +78,79 @@ func WriteNarratives(path string, narratives []model.Narrative) error {
 	return enc.Encode(narratives)
 }
 
-func WriteNarrativeReport(path string, narratives []model.Narrative, generatedAt time.Time) error {
+// ReadNarrativeHistory reads the narrative episodes MergeNarrativeHistory
+// keeps; a missing file is an empty history.
+func ReadNarrativeHistory(path string) ([]model.Narrative, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var history []model.Narrative
+	if err := json.Unmarshal(b, &history); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return history, nil
+}
+
+// MergeNarrativeHistory folds the current narratives into the episode
+// history at path and returns it, oldest first. An episode is one run of a
+// narrative: a narrative extends its latest episode when it starts within
+// gap of that episode's last event, and otherwise begins a new one, so
+// FirstSeen keeps the narrative's emergence after the analysis window has
+// slid past it.
+func MergeNarrativeHistory(path string, narratives []model.Narrative, gap time.Duration) ([]model.Narrative, error) {
+	history, err := ReadNarrativeHistory(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range narratives {
+		latest := -1
+		for i := range history {
+			if history[i].ID == n.ID && (latest < 0 || history[i].FirstSeen.After(history[latest].FirstSeen)) {
+				latest = i
+			}
+		}
+		if latest >= 0 && !n.FirstSeen.After(history[latest].LastSeen.Add(gap)) {
+			ep := n
+			if history[latest].FirstSeen.Before(ep.FirstSeen) {
+				ep.FirstSeen = history[latest].FirstSeen
+			}
+			if history[latest].LastSeen.After(ep.LastSeen) {
+				ep.LastSeen = history[latest].LastSeen
+			}
+			history[latest] = ep
+			continue
+		}
+		history = append(history, n)
+	}
+	sort.SliceStable(history, func(i, j int) bool {
+		return history[i].FirstSeen.Before(history[j].FirstSeen)
+	})
+	if err := WriteNarratives(path, history); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// WriteEventStudy writes event study results as indented JSON.
+func WriteEventStudy(path string, results []model.EventStudyResult) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0o644)
+}
+
+// WriteNarrativeReport writes the daily markdown report. studies, when
+// present, add each narrative's market reaction and a closing list of the
+// episodes that preceded significant moves.
+func WriteNarrativeReport(path string, narratives []model.Narrative, generatedAt time.Time, studies []model.EventStudyResult) error {
 	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
 		return err
 	}
@@ -104,6 +176,13 @@ func WriteNarrativeReport(path string, narratives []model.Narrative, generatedAt
 		fmt.Fprintf(&b, "- Trust mix: %s\n", joinCounts(n.TrustMix))
 		fmt.Fprintf(&b, "- Intake mix: %s\n", joinCounts(n.IntakeMix))
 		fmt.Fprintf(&b, "- Window: %s to %s\n\n", n.FirstSeen.Format("2006-01-02"), n.LastSeen.Format("2006-01-02"))
+		if reaction := currentEpisode(studies, n.ID); len(reaction) > 0 {
+			b.WriteString("Market reaction:\n")
+			for _, r := range reaction {
+				fmt.Fprintf(&b, "- %s\n", r.Summary())
+			}
+			b.WriteString("\n")
+		}
 		b.WriteString("Signals:\n")
 		for _, ev := range n.Evidence {
 			fmt.Fprintf(&b, "- %s\n", ev)
@@ -116,10 +195,54 @@ func WriteNarrativeReport(path string, narratives []model.Narrative, generatedAt
 		}
 		b.WriteString("\n")
 	}
+	writePreceded(&b, studies)
 
 	return os.WriteFile(path, b.Bytes(), 0o644)
 }
 
+// currentEpisode returns the results for the latest episode of narrative id.
+func currentEpisode(studies []model.EventStudyResult, id string) []model.EventStudyResult {
+	var latest time.Time
+	for _, r := range studies {
+		if r.NarrativeID == id && r.FirstSeen.After(latest) {
+			latest = r.FirstSeen
+		}
+	}
+	var out []model.EventStudyResult
+	for _, r := range studies {
+		if r.NarrativeID == id && r.FirstSeen.Equal(latest) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// maxPrecededInReport caps the closing list of the daily report.
+const maxPrecededInReport = 10
+
+func writePreceded(b *bytes.Buffer, studies []model.EventStudyResult) {
+	var preceded []model.EventStudyResult
+	for _, r := range studies {
+		if r.Preceded {
+			preceded = append(preceded, r)
+		}
+	}
+	if len(preceded) == 0 {
+		return
+	}
+	sort.SliceStable(preceded, func(i, j int) bool {
+		return preceded[i].FirstSeen.After(preceded[j].FirstSeen)
+	})
+	if len(preceded) > maxPrecededInReport {
+		preceded = preceded[:maxPrecededInReport]
+	}
+	b.WriteString("## Narratives that preceded moves\n\n")
+	for _, r := range preceded {
+		fmt.Fprintf(b, "- %s (first seen %s): %s\n", r.Label, r.FirstSeen.Format("2006-01-02"), r.Summary())
+	}
+	b.WriteString("\nA significant abnormal return after a narrative appeared is a timing observation, not evidence that the narrative caused the move.\n")
+}
+
 func join(values []string) string {
 	if len(values) == 0 {
 		return "(none)"
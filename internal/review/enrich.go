@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/fpt/klein-cli/internal/repository"
+	"github.com/fpt/klein-cli/pkg/agent/domain"
 )
 
 // Enricher renders parsed file diffs with extra context lines read from the
@@ -16,6 +17,8 @@ type Enricher struct {
 	workingDir string
 	context    int // lines of extra context around each hunk
 	maxBytes   int // budget for the whole rendered diff; 0 = unbounded
+	maxTokens  int // the same budget in the model's tokens; 0 = unbounded
+	counter    domain.TokenCounter
 }
 
 // NewEnricher creates an Enricher reading files relative to workingDir.
@@ -30,6 +33,14 @@ func (e *Enricher) WithMaxBytes(n int) *Enricher {
 	return e
 }
 
+// WithMaxTokens bounds the rendered diff to n tokens as counter counts them,
+// on top of any byte budget. A value <= 0 or a nil counter leaves it
+// unbounded. Returns the receiver for chaining.
+func (e *Enricher) WithMaxTokens(n int, counter domain.TokenCounter) *Enricher {
+	e.maxTokens, e.counter = n, counter
+	return e
+}
+
 // Render produces the annotated review view of all file diffs. Layout per file:
 //
 //	## File: internal/foo.go
@@ -41,8 +52,9 @@ func (e *Enricher) WithMaxBytes(n int) *Enricher {
 //
 // New-side line numbers appear in brackets only for commentable lines, so the
 // model cannot mistake enrichment context for valid comment targets. When a
-// byte budget is set (WithMaxBytes) the result is truncated at a line boundary
-// with a visible marker, so a huge PR can't produce an unbounded prompt.
+// byte or token budget is set (WithMaxBytes, WithMaxTokens) the result is
+// truncated at a line boundary with a visible marker, so a huge PR can't
+// produce an unbounded prompt.
 func (e *Enricher) Render(ctx context.Context, files []FileDiff, ranges Ranges) string {
 	var b strings.Builder
 	for _, f := range files {
@@ -76,7 +88,7 @@ func (e *Enricher) Render(ctx context.Context, files []FileDiff, ranges Ranges)
 		b.WriteString("\n")
 	}
 
-	return e.applyByteBudget(strings.TrimRight(b.String(), "\n") + "\n")
+	return e.applyTokenBudget(e.applyByteBudget(strings.TrimRight(b.String(), "\n") + "\n"))
 }
 
 const truncationMarker = "... [diff truncated to bound prompt size — later changes are not shown]\n"
@@ -95,6 +107,41 @@ func (e *Enricher) applyByteBudget(out string) string {
 	return truncateAtLine(out, e.maxBytes-len(truncationMarker)) + truncationMarker
 }
 
+// applyTokenBudget is applyByteBudget in tokens: out is cut to the longest
+// line-boundary prefix that, with the marker, fits e.maxTokens. Token counts
+// grow with the prefix, so the cut is found by binary search over lines.
+func (e *Enricher) applyTokenBudget(out string) string {
+	if e.maxTokens <= 0 || e.counter == nil || e.counter.CountTokens(out) <= e.maxTokens {
+		return out
+	}
+	out = strings.TrimSuffix(out, truncationMarker) // a byte cut already marked it
+	budget := e.maxTokens - e.counter.CountTokens(truncationMarker)
+	marker := truncationMarker
+	if budget < 0 {
+		budget, marker = e.maxTokens, ""
+	}
+	var ends []int // byte offset just past each line
+	for i := 0; i < len(out); i++ {
+		if out[i] == '\n' {
+			ends = append(ends, i+1)
+		}
+	}
+	lo, hi := 0, len(ends) // the first lo lines fit; more than hi do not
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if e.counter.CountTokens(out[:ends[mid-1]]) <= budget {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	kept := ""
+	if lo > 0 {
+		kept = out[:ends[lo-1]]
+	}
+	return kept + marker
+}
+
 // truncateAtLine returns the longest prefix of s that is at most n bytes and
 // ends on a line boundary. When no newline fits within n, it returns "" rather
 // than a partial line — the contract is that the result never ends mid-line
@@ -256,3 +256,47 @@ func TestBuildPrompt_IncrementalWithPreviousComments(t *testing.T) {
 		"do NOT re-post a duplicate",
 	)
 }
+
+// wordCounter counts whitespace-separated words, a stand-in tokenizer.
+type wordCounter struct{}
+
+func (wordCounter) CountTokens(s string) int { return len(strings.Fields(s)) }
+
+func TestEnricherRender_TokenBudget(t *testing.T) {
+	t.Parallel()
+	var sb strings.Builder
+	sb.WriteString("--- a/a.txt\n+++ b/a.txt\n@@ -0,0 +1,100 @@\n")
+	for i := 1; i <= 100; i++ {
+		fmt.Fprintf(&sb, "+line %d\n", i)
+	}
+	files := mustParse(t, sb.String())
+	ranges := CommentableRanges(files)
+	render := func(e *Enricher) string { return e.Render(context.Background(), files, ranges) }
+	enricher := func() *Enricher { return NewEnricher(infra.NewOSFilesystemRepository(), t.TempDir(), 0) }
+
+	out := render(enricher().WithMaxTokens(150, wordCounter{}))
+	if n := (wordCounter{}).CountTokens(out); n > 150 {
+		t.Errorf("rendered %d tokens, expected within the 150 budget", n)
+	}
+	if !strings.HasSuffix(out, truncationMarker) {
+		t.Errorf("truncated output should end with the marker, got tail %q", out[max(0, len(out)-80):])
+	}
+	mustContain(t, out, "line 10\n")
+	mustNotContain(t, out, "line 100")
+	// The cut is the longest prefix that fits: one more line would not.
+	kept := strings.TrimSuffix(out, truncationMarker)
+	full := render(enricher())
+	next := full[:len(kept)+strings.IndexByte(full[len(kept):], '\n')+1]
+	if (wordCounter{}).CountTokens(next+truncationMarker) <= 150 {
+		t.Errorf("cut too early: %q would still fit", next[len(kept):])
+	}
+
+	// A byte cut and a token cut compose; the marker appears once.
+	both := render(enricher().WithMaxBytes(1200).WithMaxTokens(100, wordCounter{}))
+	if strings.Count(both, "diff truncated") != 1 || len(both) > 1200 {
+		t.Errorf("combined budgets: %d bytes, %d markers", len(both), strings.Count(both, "diff truncated"))
+	}
+	if got := render(enricher().WithMaxTokens(1_000_000, wordCounter{})); got != full {
+		t.Error("a budget the diff fits changed the render")
+	}
+}
@@ -0,0 +1,201 @@
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/fpt/klein-cli/internal/repository"
+	"github.com/fpt/klein-cli/pkg/message"
+)
+
+// Formats lists the export formats accepted by Export.
+var Formats = []string{"md", "jsonl", "html"}
+
+// Export writes a session in the given format:
+//
+//   - md: a readable transcript, tool calls and results in fenced blocks
+//   - jsonl: one serialized message per line, the same shape as the session file
+//   - html: a standalone page of the Markdown transcript's content
+//
+// Exports are complete — nothing is truncated the way previews are.
+func Export(w io.Writer, info Info, st repository.HistoryState, format string) error {
+	switch format {
+	case "md", "markdown":
+		return exportMarkdown(w, info, st)
+	case "jsonl":
+		return exportJSONL(w, st)
+	case "html":
+		return exportHTML(w, info, st)
+	default:
+		return fmt.Errorf("unknown export format %q (want %s)", format, strings.Join(Formats, ", "))
+	}
+}
+
+func exportJSONL(w io.Writer, st repository.HistoryState) error {
+	enc := json.NewEncoder(w)
+	for _, m := range st.Messages {
+		if err := enc.Encode(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// turn is one rendered message, shared by the Markdown and HTML exporters.
+type turn struct {
+	Heading string
+	Time    string
+	Text    string // prose; "" when the turn is only a block
+	Block   string // verbatim content: tool arguments, results, thinking
+	Lang    string // fence language for Block
+	Kind    string // CSS class: user, assistant, system, tool
+}
+
+func turns(st repository.HistoryState) []turn {
+	out := make([]turn, 0, len(st.Messages))
+	for _, m := range st.Messages {
+		t := turn{Time: formatTime(m.Timestamp)}
+		switch m.Type {
+		case message.MessageTypeUser:
+			t.Heading, t.Kind, t.Text = "User", "user", m.Content
+		case message.MessageTypeAssistant:
+			t.Heading, t.Kind, t.Text = "Assistant", "assistant", m.Content
+			if m.Thinking != "" {
+				t.Block, t.Lang = m.Thinking, "text"
+			}
+		case message.MessageTypeReasoning:
+			t.Heading, t.Kind, t.Block, t.Lang = "Reasoning", "assistant", m.Content, "text"
+		case message.MessageTypeSystem:
+			t.Heading, t.Kind, t.Block, t.Lang = "System ("+m.Source.String()+")", "system", m.Content, "text"
+		case message.MessageTypeToolCall:
+			args, _ := json.MarshalIndent(m.Args, "", "  ")
+			t.Heading, t.Kind, t.Block, t.Lang = "Tool call: "+m.ToolName, "tool", string(args), "json"
+		case message.MessageTypeToolResult:
+			t.Heading, t.Kind, t.Lang = "Tool result", "tool", "text"
+			t.Block = m.Result
+			if m.Error != "" {
+				t.Heading, t.Block = "Tool error", m.Error
+			}
+		default:
+			t.Heading, t.Kind, t.Text = m.Type.String(), "system", m.Content
+		}
+		out = append(out, t)
+	}
+	return out
+}
+
+func formatTime(ts time.Time) string {
+	if ts.IsZero() {
+		return ""
+	}
+	return ts.Local().Format("2006-01-02 15:04:05")
+}
+
+// fence returns a backtick fence longer than any run inside s, so a tool
+// result that itself contains ``` cannot close the block early.
+func fence(s string) string {
+	longest, run := 0, 0
+	for _, r := range s {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	return strings.Repeat("`", max(3, longest+1))
+}
+
+func exportMarkdown(w io.Writer, info Info, st repository.HistoryState) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", info.DisplayTitle())
+	for _, line := range info.Details() {
+		fmt.Fprintf(&b, "- %s\n", line)
+	}
+	for _, t := range turns(st) {
+		fmt.Fprintf(&b, "\n## %s", t.Heading)
+		if t.Time != "" {
+			fmt.Fprintf(&b, " · %s", t.Time)
+		}
+		b.WriteString("\n\n")
+		if t.Text != "" {
+			b.WriteString(strings.TrimRight(t.Text, "\n") + "\n")
+		}
+		if t.Block != "" {
+			if t.Text != "" {
+				b.WriteString("\n")
+			}
+			f := fence(t.Block)
+			fmt.Fprintf(&b, "%s%s\n%s\n%s\n", f, t.Lang, strings.TrimRight(t.Block, "\n"), f)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Details returns the session's metadata as "Label: value" lines, as shown
+// above a transcript.
+func (i Info) Details() []string {
+	lines := []string{"Session: " + i.ID}
+	if i.Role != "" {
+		lines = append(lines, "Role: "+i.Role)
+	}
+	if i.ForkedFrom != "" {
+		lines = append(lines, "Forked from: "+i.ForkedFrom)
+	}
+	if s := formatTime(i.Started); s != "" {
+		lines = append(lines, "Started: "+s)
+	}
+	if s := formatTime(i.LastActivity); s != "" {
+		lines = append(lines, "Last activity: "+s)
+	}
+	lines = append(lines, fmt.Sprintf("Messages: %d", i.Messages))
+	if i.TotalTokens > 0 {
+		lines = append(lines, fmt.Sprintf("Tokens: %d (in %d, out %d)", i.TotalTokens, i.InputTokens, i.OutputTokens))
+	}
+	return lines
+}
+
+var htmlTemplate = template.Must(template.New("session").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 56rem; margin: 2rem auto; padding: 0 1rem; color: #1f2328; }
+header ul { color: #59636e; padding-left: 1.2rem; }
+section { border-left: 4px solid #d1d9e0; margin: 1rem 0; padding: .25rem 1rem; }
+section.user { border-color: #0969da; }
+section.assistant { border-color: #1a7f37; }
+section.tool { border-color: #9a6700; }
+h2 { font-size: 1rem; margin: .5rem 0; }
+h2 time { color: #59636e; font-weight: normal; margin-left: .5rem; }
+.text { white-space: pre-wrap; }
+pre { background: #f6f8fa; padding: .75rem; overflow-x: auto; white-space: pre-wrap; }
+</style>
+</head>
+<body>
+<header>
+<h1>{{.Title}}</h1>
+<ul>{{range .Header}}<li>{{.}}</li>{{end}}</ul>
+</header>
+{{range .Turns}}<section class="{{.Kind}}">
+<h2>{{.Heading}}{{if .Time}}<time>{{.Time}}</time>{{end}}</h2>
+{{if .Text}}<div class="text">{{.Text}}</div>
+{{end}}{{if .Block}}<pre><code>{{.Block}}</code></pre>
+{{end}}</section>
+{{end}}</body>
+</html>
+`))
+
+func exportHTML(w io.Writer, info Info, st repository.HistoryState) error {
+	return htmlTemplate.Execute(w, struct {
+		Title  string
+		Header []string
+		Turns  []turn
+	}{info.DisplayTitle(), info.Details(), turns(st)})
+}
@@ -0,0 +1,402 @@
+// Package session browses a project's saved conversations. Each interactive
+// run writes its own MessageHistoryRepository file under the project's
+// sessions directory; this package keeps an index over those files (title,
+// first prompt, last activity, token totals, role) so they can be listed,
+// named, forked, removed and exported without replaying each one.
+//
+// The index is a cache plus the few facts that cannot be derived from a
+// session file (a user-given title, the role it ran under, what it was forked
+// from). Derived fields are recomputed whenever a file's size or mtime
+// changes, so a session written by another klein process is never shown stale.
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/fpt/klein-cli/internal/repository"
+	"github.com/fpt/klein-cli/internal/tool/memorydb"
+	"github.com/fpt/klein-cli/pkg/message"
+)
+
+// fileExt identifies session files, matching config.NewProjectSessionFile.
+// Sidecars such as "<session>.json.codex-thread" do not match it.
+const fileExt = ".json"
+
+// indexFileName lives beside the sessions. Its extension deliberately differs
+// from fileExt so `--continue`, which resumes the newest *.json, never picks
+// the index up as a session.
+const indexFileName = ".index"
+
+// titleRunes caps a title derived from the first prompt.
+const titleRunes = 60
+
+// ErrNotFound is returned when a session reference matches nothing.
+var ErrNotFound = errors.New("no such session")
+
+// Info describes one session.
+type Info struct {
+	ID   string `json:"-"` // file name without extension
+	Path string `json:"-"`
+
+	// Recorded facts, kept across re-derivation.
+	Title      string `json:"title,omitempty"` // set by `sessions name`; "" means use FirstPrompt
+	Role       string `json:"role,omitempty"`  // role of the most recent turn
+	ForkedFrom string `json:"forked_from,omitempty"`
+
+	// Derived from the file.
+	FirstPrompt  string    `json:"first_prompt,omitempty"`
+	Started      time.Time `json:"started,omitzero"`
+	LastActivity time.Time `json:"last_activity"` // file mtime: when the session was last used
+	Messages     int       `json:"messages"`
+	InputTokens  int       `json:"input_tokens,omitempty"`
+	OutputTokens int       `json:"output_tokens,omitempty"`
+	TotalTokens  int       `json:"total_tokens,omitempty"`
+	Size         int64     `json:"size"` // with LastActivity, detects a changed file
+}
+
+// DisplayTitle returns the user-given title, else the first prompt, else a
+// placeholder.
+func (i Info) DisplayTitle() string {
+	switch {
+	case i.Title != "":
+		return i.Title
+	case i.FirstPrompt != "":
+		return i.FirstPrompt
+	default:
+		return "(no prompt yet)"
+	}
+}
+
+// Summary renders the session on one line for listings and pickers:
+// ID, age of last activity, message count, tokens, role and title.
+func (i Info) Summary(now time.Time) string {
+	role := i.Role
+	if role == "" {
+		role = "-"
+	}
+	return fmt.Sprintf("%-26s %8s %5d msgs %7s tok  %-8s %s",
+		i.ID, Age(now, i.LastActivity), i.Messages, compactCount(i.TotalTokens), role, i.DisplayTitle())
+}
+
+// Age renders how long before now t was, coarsely ("just now", "5m ago",
+// "3h ago", "2d ago", then the date).
+func Age(now, t time.Time) string {
+	d := now.Sub(t)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	case d < 30*24*time.Hour:
+		return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+	default:
+		return t.Format("2006-01-02")
+	}
+}
+
+// compactCount renders n as 950, 12.3k or 4.5M.
+func compactCount(n int) string {
+	switch {
+	case n < 1000:
+		return fmt.Sprintf("%d", n)
+	case n < 1_000_000:
+		return fmt.Sprintf("%.1fk", float64(n)/1000)
+	default:
+		return fmt.Sprintf("%.1fM", float64(n)/1_000_000)
+	}
+}
+
+// Store is the sessions directory of one project.
+type Store struct {
+	dir string
+}
+
+// NewStore returns a Store over dir, normally config.GetProjectSessionsDir.
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Dir returns the sessions directory.
+func (s *Store) Dir() string { return s.dir }
+
+// indexMu serialises index read-modify-write cycles within this process.
+// Separate processes can still interleave; the write is an atomic rename, so
+// the worst case is a lost title or role, never a corrupt index.
+var indexMu sync.Mutex
+
+// List returns every session, most recently used first. Ties on mtime are
+// broken by ID descending, the same rule --continue uses.
+func (s *Store) List() ([]Info, error) {
+	indexMu.Lock()
+	defer indexMu.Unlock()
+	return s.refresh()
+}
+
+// refresh brings the index up to date with the directory and returns it
+// sorted. Callers hold indexMu.
+func (s *Store) refresh() ([]Info, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read sessions directory %s: %w", s.dir, err)
+	}
+	index := s.loadIndex()
+	changed := false
+
+	seen := make(map[string]bool)
+	var out []Info
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != fileExt {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil {
+			continue // vanished mid-scan
+		}
+		id := strings.TrimSuffix(e.Name(), fileExt)
+		seen[id] = true
+		info, ok := index[id]
+		if !ok || !info.LastActivity.Equal(fi.ModTime()) || info.Size != fi.Size() {
+			info = derive(info, filepath.Join(s.dir, e.Name()), fi)
+			index[id] = info
+			changed = true
+		}
+		info.ID, info.Path = id, filepath.Join(s.dir, e.Name())
+		out = append(out, info)
+	}
+	for id := range index {
+		if !seen[id] {
+			delete(index, id)
+			changed = true
+		}
+	}
+	if changed {
+		_ = s.saveIndex(index) // a cache; failing to write it only costs a re-scan
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].LastActivity.Equal(out[j].LastActivity) {
+			return out[i].LastActivity.After(out[j].LastActivity)
+		}
+		return out[i].ID > out[j].ID
+	})
+	return out, nil
+}
+
+// derive recomputes the file-derived fields of prev. An unreadable file still
+// gets an entry, so it can be listed and removed.
+func derive(prev Info, path string, fi os.FileInfo) Info {
+	info := Info{Title: prev.Title, Role: prev.Role, ForkedFrom: prev.ForkedFrom,
+		LastActivity: fi.ModTime(), Size: fi.Size()}
+	st, err := Load(path)
+	if err != nil {
+		return info
+	}
+	info.Messages = len(st.Messages)
+	for _, m := range st.Messages {
+		if info.Started.IsZero() && !m.Timestamp.IsZero() {
+			info.Started = m.Timestamp
+		}
+		if info.FirstPrompt == "" && m.Type == message.MessageTypeUser && strings.TrimSpace(m.Content) != "" {
+			info.FirstPrompt = summarize(memorydb.StripContext(m.Content))
+		}
+		info.InputTokens += m.InputTokens
+		info.OutputTokens += m.OutputTokens
+		info.TotalTokens += m.TotalTokens
+	}
+	return info
+}
+
+// summarize reduces a prompt to its first non-blank line, capped at titleRunes.
+func summarize(text string) string {
+	line := ""
+	for l := range strings.SplitSeq(text, "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			line = l
+			break
+		}
+	}
+	if utf8.RuneCountInString(line) <= titleRunes {
+		return line
+	}
+	return string([]rune(line)[:titleRunes-1]) + "…"
+}
+
+// Resolve finds a session by exact ID or unique ID prefix.
+func (s *Store) Resolve(ref string) (Info, error) {
+	ref = strings.TrimSuffix(strings.TrimSpace(ref), fileExt)
+	if ref == "" {
+		return Info{}, errors.New("session id is required")
+	}
+	all, err := s.List()
+	if err != nil {
+		return Info{}, err
+	}
+	var matches []Info
+	for _, info := range all {
+		if info.ID == ref {
+			return info, nil
+		}
+		if strings.HasPrefix(info.ID, ref) {
+			matches = append(matches, info)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return Info{}, fmt.Errorf("%w: %q", ErrNotFound, ref)
+	case 1:
+		return matches[0], nil
+	}
+	ids := make([]string, 0, len(matches))
+	for _, m := range matches {
+		ids = append(ids, m.ID)
+	}
+	return Info{}, fmt.Errorf("%q is ambiguous: matches %s", ref, strings.Join(ids, ", "))
+}
+
+// SetTitle names a session; an empty title reverts to the first prompt.
+func (s *Store) SetTitle(id, title string) error {
+	return s.update(id, func(info *Info) { info.Title = strings.TrimSpace(title) })
+}
+
+// Fork copies session id to dst (a path from config.NewProjectSessionFile)
+// and returns the new session. The copy starts with the original's role and
+// a title marking where it came from; its codex thread, if any, is not
+// copied — two sessions continuing one backend thread would interleave.
+func (s *Store) Fork(id, dst string) (Info, error) {
+	src, err := s.Resolve(id)
+	if err != nil {
+		return Info{}, err
+	}
+	data, err := os.ReadFile(src.Path)
+	if err != nil {
+		return Info{}, fmt.Errorf("failed to read session %s: %w", src.ID, err)
+	}
+	if err := os.WriteFile(dst, data, 0644); err != nil {
+		return Info{}, fmt.Errorf("failed to write forked session: %w", err)
+	}
+	newID := strings.TrimSuffix(filepath.Base(dst), fileExt)
+	err = s.update(newID, func(info *Info) {
+		info.Title = "fork of " + src.DisplayTitle()
+		info.Role = src.Role
+		info.ForkedFrom = src.ID
+	})
+	if err != nil {
+		return Info{}, err
+	}
+	return s.Resolve(newID)
+}
+
+// Remove deletes a session file, its sidecars and its index entry.
+func (s *Store) Remove(id string) error {
+	info, err := s.Resolve(id)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(info.Path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove session %s: %w", info.ID, err)
+	}
+	sidecars, _ := filepath.Glob(filepath.Join(s.dir, info.ID+fileExt+".*"))
+	for _, p := range sidecars {
+		_ = os.Remove(p)
+	}
+	indexMu.Lock()
+	defer indexMu.Unlock()
+	_, err = s.refresh()
+	return err
+}
+
+// RecordRole notes the role that just ran a turn in the session stored at
+// sessionFile. Called after each save; failures are the caller's to ignore.
+func RecordRole(sessionFile, role string) error {
+	if sessionFile == "" || role == "" {
+		return nil
+	}
+	s := NewStore(filepath.Dir(sessionFile))
+	id := strings.TrimSuffix(filepath.Base(sessionFile), fileExt)
+	return s.update(id, func(info *Info) { info.Role = role })
+}
+
+// update applies fn to the recorded facts of session id.
+func (s *Store) update(id string, fn func(*Info)) error {
+	indexMu.Lock()
+	defer indexMu.Unlock()
+	if _, err := os.Stat(filepath.Join(s.dir, id+fileExt)); err != nil {
+		return fmt.Errorf("%w: %q", ErrNotFound, id)
+	}
+	index := s.loadIndex()
+	info := index[id]
+	fn(&info)
+	index[id] = info
+	return s.saveIndex(index)
+}
+
+func (s *Store) indexPath() string { return filepath.Join(s.dir, indexFileName) }
+
+// loadIndex reads the index; a missing or corrupt one is an empty index, since
+// everything but the recorded facts can be rebuilt.
+func (s *Store) loadIndex() map[string]Info {
+	index := make(map[string]Info)
+	data, err := os.ReadFile(s.indexPath())
+	if err != nil {
+		return index
+	}
+	if json.Unmarshal(data, &index) != nil {
+		return make(map[string]Info)
+	}
+	return index
+}
+
+// saveIndex writes the index via a temp file and rename, so a concurrent
+// reader sees the old index or the new one, never half of either.
+func (s *Store) saveIndex(index map[string]Info) error {
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(s.dir, indexFileName+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to write session index: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write session index: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write session index: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.indexPath()); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write session index: %w", err)
+	}
+	return nil
+}
+
+// Load reads a session file in its serialized form, which keeps message IDs
+// and timestamps that converting to message.Message would drop.
+func Load(path string) (repository.HistoryState, error) {
+	var st repository.HistoryState
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return st, fmt.Errorf("failed to read session %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &st); err != nil {
+		return st, fmt.Errorf("failed to parse session %s: %w", path, err)
+	}
+	return st, nil
+}
@@ -0,0 +1,223 @@
+package session
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fpt/klein-cli/internal/infra"
+	"github.com/fpt/klein-cli/pkg/message"
+)
+
+// writeSession saves msgs as session id and stamps its mtime, which is what
+// List orders on.
+func writeSession(t *testing.T, dir, id string, mtime time.Time, msgs ...message.Message) string {
+	t.Helper()
+	path := filepath.Join(dir, id+fileExt)
+	if err := infra.NewMessageHistoryRepository(path).Save(msgs); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func reply(text string, in, out int) message.Message {
+	m := message.NewChatMessage(message.MessageTypeAssistant, text)
+	m.SetTokenUsage(in, out, in+out)
+	return m
+}
+
+func TestListDerivesAndOrders(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now().Truncate(time.Second)
+	writeSession(t, dir, "20260101T000000.000000001", now.Add(-time.Hour),
+		message.NewChatMessage(message.MessageTypeUser, "\n  fix the flaky test\nin ./pkg"),
+		reply("done", 100, 20),
+		message.NewChatMessage(message.MessageTypeUser, "thanks"),
+		reply("np", 50, 5),
+	)
+	writeSession(t, dir, "20260102T000000.000000001", now,
+		message.NewChatMessage(message.MessageTypeUser, strings.Repeat("x", 100)))
+	// Not sessions: a sidecar and the index's own temp names.
+	if err := os.WriteFile(filepath.Join(dir, "20260102T000000.000000001.json.codex-thread"), []byte("t"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewStore(dir)
+	all, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 || all[0].ID != "20260102T000000.000000001" {
+		t.Fatalf("List = %+v", all)
+	}
+	if got := all[0].FirstPrompt; len([]rune(got)) != titleRunes || !strings.HasSuffix(got, "…") {
+		t.Errorf("long prompt not capped: %q", got)
+	}
+	old := all[1]
+	if old.FirstPrompt != "fix the flaky test" || old.Messages != 4 {
+		t.Errorf("derived fields = %+v", old)
+	}
+	if old.InputTokens != 150 || old.OutputTokens != 25 || old.TotalTokens != 175 {
+		t.Errorf("token totals = %d/%d/%d", old.InputTokens, old.OutputTokens, old.TotalTokens)
+	}
+
+	// A changed file is re-derived; recorded facts survive.
+	if err := s.SetTitle(old.ID, "flaky test hunt"); err != nil {
+		t.Fatal(err)
+	}
+	writeSession(t, dir, old.ID, now.Add(time.Minute), message.NewChatMessage(message.MessageTypeUser, "new start"))
+	got, err := s.Resolve(old.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != "flaky test hunt" || got.FirstPrompt != "new start" || got.Messages != 1 {
+		t.Errorf("after rewrite = %+v", got)
+	}
+	if all, _ = s.List(); all[0].ID != old.ID {
+		t.Errorf("most recently used should list first, got %s", all[0].ID)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	writeSession(t, dir, "20260101T010000.000000001", now)
+	writeSession(t, dir, "20260101T020000.000000001", now)
+	s := NewStore(dir)
+
+	if got, err := s.Resolve("20260101T01"); err != nil || got.ID != "20260101T010000.000000001" {
+		t.Errorf("unique prefix: %+v, %v", got, err)
+	}
+	if got, err := s.Resolve("20260101T020000.000000001.json"); err != nil || got.ID != "20260101T020000.000000001" {
+		t.Errorf("file name: %+v, %v", got, err)
+	}
+	if _, err := s.Resolve("20260101"); err == nil || !strings.Contains(err.Error(), "ambiguous") {
+		t.Errorf("ambiguous prefix: %v", err)
+	}
+	if _, err := s.Resolve("1999"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing: %v", err)
+	}
+}
+
+func TestForkRemoveAndRecordRole(t *testing.T) {
+	dir := t.TempDir()
+	src := writeSession(t, dir, "a", time.Now(), message.NewChatMessage(message.MessageTypeUser, "original"))
+	if err := os.WriteFile(src+".codex-thread", []byte("thread"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := RecordRole(src, "code"); err != nil {
+		t.Fatal(err)
+	}
+	s := NewStore(dir)
+
+	fork, err := s.Fork("a", filepath.Join(dir, "b.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fork.ID != "b" || fork.ForkedFrom != "a" || fork.Role != "code" || fork.Title != "fork of original" || fork.Messages != 1 {
+		t.Errorf("fork = %+v", fork)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "b.json.codex-thread")); !os.IsNotExist(err) {
+		t.Error("a fork must not share the codex thread")
+	}
+
+	if err := s.Remove("a"); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{src, src + ".codex-thread"} {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("%s not removed", p)
+		}
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, indexFileName))
+	var index map[string]Info
+	if err := json.Unmarshal(data, &index); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := index["a"]; ok || len(index) != 1 {
+		t.Errorf("index after remove = %v", index)
+	}
+	if err := RecordRole(src, "code"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RecordRole on a removed session: %v", err)
+	}
+}
+
+func TestExport(t *testing.T) {
+	dir := t.TempDir()
+	call := message.NewToolCallMessage("Bash", message.ToolArgumentValues{"command": "ls"})
+	path := writeSession(t, dir, "s", time.Now(),
+		message.NewChatMessage(message.MessageTypeUser, "list <files>"),
+		call,
+		message.NewToolResultMessage(call.ID(), "a.go\n```\nb.go", ""),
+		reply("Two files.", 10, 2),
+	)
+	info, err := NewStore(dir).Resolve("s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var md bytes.Buffer
+	if err := Export(&md, info, st, "md"); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# list <files>\n", "- Tokens: 12 (in 10, out 2)",
+		"## Tool call: Bash", "\"command\": \"ls\"",
+		"````text\na.go\n```\nb.go\n````", // the fence outgrows the result's own
+		"## Assistant", "Two files.",
+	} {
+		if !strings.Contains(md.String(), want) {
+			t.Errorf("markdown lacks %q:\n%s", want, md.String())
+		}
+	}
+
+	var jl bytes.Buffer
+	if err := Export(&jl, info, st, "jsonl"); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(jl.String()), "\n"); len(lines) != 4 || !strings.Contains(lines[1], `"tool_name":"Bash"`) {
+		t.Errorf("jsonl:\n%s", jl.String())
+	}
+
+	var html bytes.Buffer
+	if err := Export(&html, info, st, "html"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(html.String(), "list &lt;files&gt;") || strings.Contains(html.String(), "<files>") {
+		t.Errorf("html does not escape content:\n%s", html.String())
+	}
+
+	if err := Export(&html, info, st, "pdf"); err == nil {
+		t.Error("unknown format should fail")
+	}
+}
+
+func TestSummary(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	info := Info{ID: "20261018T100000.000000000", LastActivity: now.Add(-2 * time.Hour),
+		Messages: 12, TotalTokens: 34_567, Role: "code", FirstPrompt: "fix the build"}
+	want := "20261018T100000.000000000    2h ago    12 msgs   34.6k tok  code     fix the build"
+	if got := info.Summary(now); got != want {
+		t.Errorf("Summary:\n got %q\nwant %q", got, want)
+	}
+	for d, want := range map[time.Duration]string{
+		10 * time.Second: "just now", 5 * time.Minute: "5m ago", 72 * time.Hour: "3d ago",
+		90 * 24 * time.Hour: "2026-07-20",
+	} {
+		if got := Age(now, now.Add(-d)); got != want {
+			t.Errorf("Age(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
@@ -115,6 +115,24 @@ func TestParseDefinition_ParsesAgentOnlyFields(t *testing.T) {
 	}
 }
 
+func TestParseDefinition_ParsesModelPinAndDenylistAlias(t *testing.T) {
+	t.Parallel()
+
+	const src = "---\nname: x\nbackend: anthropic\nmodel: haiku\neffort: low\n" +
+		"disallowed-tools: Bash, Write\n---\nbody"
+
+	d, err := ParseDefinition([]byte(src), "x/SKILL.md", 0, KindSkill)
+	if err != nil {
+		t.Fatalf("ParseDefinition: %v", err)
+	}
+	if d.Backend != "anthropic" || d.Model != "haiku" || d.Effort != "low" {
+		t.Errorf("model pin: got backend=%q model=%q effort=%q", d.Backend, d.Model, d.Effort)
+	}
+	if strings.Join(d.DisallowedTools, ",") != "Bash,Write" {
+		t.Errorf("disallowed-tools: got %v", d.DisallowedTools)
+	}
+}
+
 func TestDefinition_KindPredicates(t *testing.T) {
 	t.Parallel()
 
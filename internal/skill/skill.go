@@ -82,8 +82,9 @@ type Definition struct {
 	// `allowed-tools:` on an agent, where that field has always been a cap.
 	Tools []string
 
-	// DisallowedTools is a denylist applied after Tools. Parsed but not yet
-	// enforced (#78).
+	// DisallowedTools is a denylist applied after Tools, in every mode: a
+	// denied tool is neither exposed, nor loadable through ToolSearch, nor
+	// callable. Comes from `disallowedTools:` (or `disallowed-tools:`).
 	DisallowedTools []string
 
 	// Preload lists the tools exposed up front in the deferred/ToolSearch view.
@@ -106,10 +107,16 @@ type Definition struct {
 	Content      string // markdown body after the frontmatter; the system prompt
 	SourcePath   string // filesystem path or "embedded:<name>"
 	PluginName   string // owning plugin; empty for built-in and project/user definitions
-	Model        string
 	ArgumentHint string
 	Color        string
 
+	// Backend, Model and Effort pin the LLM this definition runs on. Empty
+	// inherits the session's; Model also accepts haiku/sonnet/opus and
+	// "inherit". See config.ModelPin.
+	Backend string
+	Model   string
+	Effort  string
+
 	Priority int  // ladder position; larger wins a name collision
 	Kind     Kind // which file this came from
 
@@ -206,6 +213,7 @@ type frontmatter struct {
 	AllowedTools    any `yaml:"allowed-tools"`
 	Tools           any `yaml:"tools"`
 	DisallowedTools any `yaml:"disallowedTools"`
+	DisallowedAlias any `yaml:"disallowed-tools"`
 	Preload         any `yaml:"preload"`
 	Modes           any `yaml:"modes"`
 
@@ -215,6 +223,8 @@ type frontmatter struct {
 	Description  string `yaml:"description"`
 	ArgumentHint string `yaml:"argument-hint"`
 	Model        string `yaml:"model"`
+	Backend      string `yaml:"backend"`
+	Effort       string `yaml:"effort"`
 	Color        string `yaml:"color"`
 	// Accepted but not enforced today.
 	PermissionMode string `yaml:"permissionMode"`
@@ -303,6 +313,8 @@ func (d *Definition) applyFrontmatter(fm *frontmatter, kind Kind) error {
 	d.ArgumentHint = fm.ArgumentHint
 	d.DisableModelInvocation = fm.DisableModelInvocation
 	d.Model = fm.Model
+	d.Backend = fm.Backend
+	d.Effort = fm.Effort
 	d.Background = fm.Background
 	d.Color = fm.Color
 
@@ -313,6 +325,9 @@ func (d *Definition) applyFrontmatter(fm *frontmatter, kind Kind) error {
 	d.Tools = parseAllowedTools(fm.Tools)
 	d.Preload = parseAllowedTools(fm.Preload)
 	d.DisallowedTools = parseAllowedTools(fm.DisallowedTools)
+	if len(d.DisallowedTools) == 0 {
+		d.DisallowedTools = parseAllowedTools(fm.DisallowedAlias)
+	}
 
 	// Legacy `allowed-tools:` lands in whichever field reproduces the behavior
 	// that file type already had: a hard cap on an agent, a visibility hint on
@@ -554,14 +569,30 @@ func BuildSkillCatalog(skills DefinitionMap) string {
 	return b.String()
 }
 
-// FilterTools returns a ToolManager hard-capped to the definition's tool list.
-// An empty list returns the source manager unchanged.
+// FilterTools returns a ToolManager hard-capped to the definition's tool list,
+// with DisallowedTools removed from whatever is left. With neither list set the
+// source manager is returned unchanged.
 func (d *Definition) FilterTools(source domain.ToolManager) domain.ToolManager {
-	names := d.EffectiveTools()
-	if len(names) == 0 {
-		return source
+	if names := d.EffectiveTools(); len(names) > 0 {
+		return NewFilteredToolManager(source, WithoutTools(names, d.DisallowedTools))
+	}
+	if len(d.DisallowedTools) > 0 {
+		return NewDeniedToolManager(source, d.DisallowedTools)
+	}
+	return source
+}
+
+// WithoutTools returns names minus every entry in denied, preserving order.
+// It always returns a new slice, so an allowlist can be narrowed without
+// touching the definition it came from.
+func WithoutTools(names, denied []string) []string {
+	out := make([]string, 0, len(names))
+	for _, n := range names {
+		if !slices.Contains(denied, n) {
+			out = append(out, n)
+		}
 	}
-	return NewFilteredToolManager(source, names)
+	return out
 }
 
 // FilteredToolManager wraps a ToolManager and only exposes a subset of tools by name.
@@ -608,3 +639,47 @@ func (f *FilteredToolManager) CallTool(
 func (f *FilteredToolManager) RegisterTool(name message.ToolName, description message.ToolDescription, arguments []message.ToolArgument, handler func(ctx context.Context, args message.ToolArgumentValues) (message.ToolResult, error)) {
 	panic("FilteredToolManager does not support RegisterTool; register on the underlying manager")
 }
+
+// DeniedToolManager wraps a ToolManager and hides a set of tools by name. It is
+// the inverse of FilteredToolManager, for a definition that has a denylist but
+// no allowlist.
+type DeniedToolManager struct {
+	source    domain.ToolManager
+	deniedSet map[message.ToolName]bool
+}
+
+// NewDeniedToolManager creates a tool manager exposing everything in source
+// except deniedNames.
+func NewDeniedToolManager(source domain.ToolManager, deniedNames []string) *DeniedToolManager {
+	denied := make(map[message.ToolName]bool, len(deniedNames))
+	for _, name := range deniedNames {
+		denied[message.ToolName(name)] = true
+	}
+	return &DeniedToolManager{source: source, deniedSet: denied}
+}
+
+// GetTools returns every source tool that is not denied.
+func (f *DeniedToolManager) GetTools() map[message.ToolName]message.Tool {
+	out := make(map[message.ToolName]message.Tool)
+	for name, tool := range f.source.GetTools() {
+		if !f.deniedSet[name] {
+			out[name] = tool
+		}
+	}
+	return out
+}
+
+// CallTool invokes a tool by name unless it is denied.
+func (f *DeniedToolManager) CallTool(
+	ctx context.Context, name message.ToolName, args message.ToolArgumentValues,
+) (message.ToolResult, error) {
+	if f.deniedSet[name] {
+		return message.NewToolResultError(fmt.Sprintf("tool '%s' is disallowed by the active skill", name)), nil
+	}
+	return f.source.CallTool(ctx, name, args)
+}
+
+// RegisterTool registers a tool on the underlying manager.
+func (f *DeniedToolManager) RegisterTool(name message.ToolName, description message.ToolDescription, arguments []message.ToolArgument, handler func(ctx context.Context, args message.ToolArgumentValues) (message.ToolResult, error)) {
+	panic("DeniedToolManager does not support RegisterTool; register on the underlying manager")
+}
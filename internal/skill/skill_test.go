@@ -347,3 +347,39 @@ func TestFilteredToolManager_CallToolDenied(t *testing.T) {
 		t.Error("expected error in result for denied tool")
 	}
 }
+
+func TestFilterTools_DenylistAfterAllowlist(t *testing.T) {
+	source := newMockToolManager("Read", "Write", "Grep", "Bash")
+	s := &Definition{Tools: []string{"Read", "Write", "Bash"}, DisallowedTools: []string{"Bash"}}
+	tools := s.FilterTools(source).GetTools()
+	if len(tools) != 2 {
+		t.Fatalf("expected Read and Write, got %v", tools)
+	}
+	if _, ok := tools["Bash"]; ok {
+		t.Error("Bash is allowed but also disallowed; the denylist must win")
+	}
+	if len(s.Tools) != 3 {
+		t.Errorf("FilterTools must not modify the definition's Tools, got %v", s.Tools)
+	}
+}
+
+func TestFilterTools_DenylistOnly(t *testing.T) {
+	source := newMockToolManager("Read", "Write", "Bash")
+	s := &Definition{DisallowedTools: []string{"Write", "Bash"}}
+	fm := s.FilterTools(source)
+	tools := fm.GetTools()
+	if len(tools) != 1 {
+		t.Fatalf("expected only Read, got %v", tools)
+	}
+	result, err := fm.CallTool(context.Background(), "Bash", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Error == "" {
+		t.Error("expected error in result for a disallowed tool")
+	}
+	result, _ = fm.CallTool(context.Background(), "Read", nil)
+	if result.Text != "called:Read" {
+		t.Errorf("unexpected result: %q", result.Text)
+	}
+}
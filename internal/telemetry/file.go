@@ -0,0 +1,183 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TraceFile is the JSONL trace beside a session file, a sidecar like its
+// codex thread, so removing the session removes its trace too.
+func TraceFile(sessionFile string) string {
+	return sessionFile + ".trace"
+}
+
+type traceFileKey struct{}
+
+// WithTraceFile makes the jsonl exporter write the spans started under ctx,
+// and under every context derived from it, to path.
+func WithTraceFile(ctx context.Context, path string) context.Context {
+	return context.WithValue(ctx, traceFileKey{}, path)
+}
+
+func traceFileFrom(ctx context.Context) string {
+	path, _ := ctx.Value(traceFileKey{}).(string)
+	return path
+}
+
+// Span is one line of a JSONL trace file.
+type Span struct {
+	TraceID    string         `json:"trace_id"`
+	SpanID     string         `json:"span_id"`
+	ParentID   string         `json:"parent_id,omitempty"`
+	Name       string         `json:"name"`
+	Start      time.Time      `json:"start"`
+	End        time.Time      `json:"end"`
+	Error      string         `json:"error,omitempty"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+}
+
+// Duration is how long the span ran.
+func (s Span) Duration() time.Duration { return s.End.Sub(s.Start) }
+
+// FileProcessor is a span processor that appends each finished span to the
+// trace file its context named when it started. Spans are written as they
+// end, children before parents, so a run that dies midway still leaves the
+// spans that finished.
+type FileProcessor struct {
+	defaultPath string
+
+	mu    sync.Mutex
+	dests map[trace.SpanID]string
+	files map[string]*os.File
+}
+
+var _ sdktrace.SpanProcessor = (*FileProcessor)(nil)
+
+// NewFileProcessor returns a processor writing spans with no trace file in
+// their context to defaultPath; "" drops them.
+func NewFileProcessor(defaultPath string) *FileProcessor {
+	return &FileProcessor{
+		defaultPath: defaultPath,
+		dests:       map[trace.SpanID]string{},
+		files:       map[string]*os.File{},
+	}
+}
+
+// OnStart implements sdktrace.SpanProcessor.
+func (p *FileProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	path := traceFileFrom(parent)
+	if path == "" {
+		path = p.defaultPath
+	}
+	if path == "" {
+		return
+	}
+	p.mu.Lock()
+	p.dests[s.SpanContext().SpanID()] = path
+	p.mu.Unlock()
+}
+
+// OnEnd implements sdktrace.SpanProcessor.
+func (p *FileProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	line, err := json.Marshal(spanRecord(s))
+	if err != nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	id := s.SpanContext().SpanID()
+	path, ok := p.dests[id]
+	if !ok {
+		return
+	}
+	delete(p.dests, id)
+	f, err := p.open(path)
+	if err != nil {
+		return // tracing must never break the run it observes
+	}
+	_, _ = f.Write(append(line, '\n'))
+}
+
+// open returns the append handle for path; callers hold p.mu.
+func (p *FileProcessor) open(path string) (*os.File, error) {
+	if f, ok := p.files[path]; ok {
+		return f, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644) //nolint:gosec // klein's own trace file
+	if err != nil {
+		return nil, err
+	}
+	p.files[path] = f
+	return f, nil
+}
+
+// ForceFlush implements sdktrace.SpanProcessor; spans are written unbuffered.
+func (p *FileProcessor) ForceFlush(context.Context) error { return nil }
+
+// Shutdown implements sdktrace.SpanProcessor by closing the trace files.
+func (p *FileProcessor) Shutdown(context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var firstErr error
+	for path, f := range p.files {
+		if err := f.Close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("closing %s: %w", path, err)
+		}
+		delete(p.files, path)
+	}
+	return firstErr
+}
+
+func spanRecord(s sdktrace.ReadOnlySpan) Span {
+	rec := Span{
+		TraceID: s.SpanContext().TraceID().String(),
+		SpanID:  s.SpanContext().SpanID().String(),
+		Name:    s.Name(),
+		Start:   s.StartTime(),
+		End:     s.EndTime(),
+	}
+	if parent := s.Parent(); parent.IsValid() {
+		rec.ParentID = parent.SpanID().String()
+	}
+	if status := s.Status(); status.Code == codes.Error {
+		rec.Error = status.Description
+		if rec.Error == "" {
+			rec.Error = "error"
+		}
+	}
+	if attrs := s.Attributes(); len(attrs) > 0 {
+		rec.Attributes = make(map[string]any, len(attrs))
+		for _, kv := range attrs {
+			rec.Attributes[string(kv.Key)] = attributeValue(kv.Value)
+		}
+	}
+	return rec
+}
+
+func attributeValue(v attribute.Value) any {
+	switch v.Type() {
+	case attribute.BOOL:
+		return v.AsBool()
+	case attribute.INT64:
+		return v.AsInt64()
+	case attribute.FLOAT64:
+		return v.AsFloat64()
+	case attribute.STRING:
+		return v.AsString()
+	default:
+		return v.Emit()
+	}
+}
@@ -0,0 +1,75 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+
+	"github.com/fpt/klein-cli/pkg/agent/tracing"
+)
+
+// TestFileProcessor routes each turn's spans to the trace file its context
+// names, and checks the tree `klein trace show` renders from one.
+func TestFileProcessor(t *testing.T) {
+	dir := t.TempDir()
+	fallback := filepath.Join(dir, "traces", "run.jsonl")
+	sessionTrace := TraceFile(filepath.Join(dir, "sessions", "s1.json"))
+
+	processor := NewFileProcessor(fallback)
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(processor))
+	tracer := provider.Tracer("test")
+
+	ctx := WithTraceFile(context.Background(), sessionTrace)
+	ctx, turn := tracer.Start(ctx, tracing.SpanInvoke)
+	turn.SetAttributes(tracing.AttrSkill.String("code"))
+	iterCtx, iter := tracer.Start(ctx, tracing.SpanIteration)
+	iter.SetAttributes(tracing.AttrIteration.Int(1))
+	_, llm := tracer.Start(iterCtx, tracing.SpanLLM)
+	llm.SetAttributes(tracing.AttrModel.String("m1"), tracing.AttrInputTokens.Int(120),
+		tracing.AttrOutputTokens.Int(30), tracing.AttrCachedTokens.Int(100))
+	llm.End()
+	_, call := tracer.Start(iterCtx, tracing.SpanTool)
+	call.SetAttributes(tracing.AttrToolName.String("Read"), tracing.AttrArgsBytes.Int(20),
+		tracing.AttrResultBytes.Int(4096), tracing.AttrOffloaded.Bool(true))
+	tracing.End(call, errors.New("boom\nstack"))
+	iter.End()
+	turn.End()
+
+	_, other := tracer.Start(context.Background(), tracing.SpanInvoke)
+	other.End()
+
+	if err := provider.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	spans, err := ReadTrace(sessionTrace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spans) != 4 {
+		t.Fatalf("session trace holds %d spans, want 4", len(spans))
+	}
+	if rest, err := ReadTrace(fallback); err != nil || len(rest) != 1 {
+		t.Fatalf("fallback trace holds %d spans (err %v), want 1", len(rest), err)
+	}
+
+	var b strings.Builder
+	WriteTree(&b, spans)
+	out := b.String()
+	for _, want := range []string{
+		"1 LLM call(s), 1 tool call(s), 120 in / 30 out tokens (100 cached)",
+		"turn code",
+		"└─ iteration 1",
+		"   ├─ llm m1 in=120 out=30 cached=100",
+		"   └─ tool Read args=20B result=4.0KB offloaded",
+		"ERROR: boom …",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("tree lacks %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package telemetry
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/fpt/klein-cli/pkg/agent/tracing"
+)
+
+// ReadTrace reads a JSONL trace file. A truncated last line, from a run
+// killed mid-write, is skipped.
+func ReadTrace(path string) ([]Span, error) {
+	f, err := os.Open(path) //nolint:gosec // a trace file the user named
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var spans []Span
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		var s Span
+		if err := json.Unmarshal([]byte(line), &s); err != nil {
+			continue
+		}
+		spans = append(spans, s)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return spans, nil
+}
+
+// spanNode is a span with its children in start order.
+type spanNode struct {
+	Span
+	children []*spanNode
+}
+
+// WriteTree renders spans as one tree per trace (a turn), oldest first, each
+// line with the span's duration and its start offset from the turn's start.
+func WriteTree(w io.Writer, spans []Span) {
+	byID := make(map[string]*spanNode, len(spans))
+	for _, s := range spans {
+		byID[s.SpanID] = &spanNode{Span: s}
+	}
+	var roots []*spanNode
+	for _, s := range spans {
+		n := byID[s.SpanID]
+		if parent, ok := byID[s.ParentID]; ok && s.ParentID != "" {
+			parent.children = append(parent.children, n)
+		} else {
+			// A root, or a span whose parent never finished (the run was
+			// killed): shown at the top level rather than lost.
+			roots = append(roots, n)
+		}
+	}
+	for _, n := range byID {
+		sortByStart(n.children)
+	}
+	sortByStart(roots)
+
+	for i, root := range roots {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintln(w, turnSummary(root))
+		var lines []treeLine
+		collectLines(root, "", "", &lines)
+		width := 0
+		for _, l := range lines {
+			width = max(width, len([]rune(l.label)))
+		}
+		for _, l := range lines {
+			pad := strings.Repeat(" ", width-len([]rune(l.label)))
+			fmt.Fprintf(w, "%s%s  %8s  +%s%s\n", l.label, pad, formatDuration(l.span.Duration()),
+				formatDuration(l.span.Start.Sub(root.Start)), l.note)
+		}
+	}
+}
+
+func sortByStart(nodes []*spanNode) {
+	sort.SliceStable(nodes, func(i, j int) bool { return nodes[i].Start.Before(nodes[j].Start) })
+}
+
+type treeLine struct {
+	label string
+	note  string
+	span  Span
+}
+
+func collectLines(n *spanNode, prefix, branch string, out *[]treeLine) {
+	*out = append(*out, treeLine{label: prefix + branch + describeSpan(n.Span), note: spanNote(n.Span), span: n.Span})
+	childPrefix := prefix
+	switch branch {
+	case "├─ ":
+		childPrefix += "│  "
+	case "└─ ":
+		childPrefix += "   "
+	}
+	for i, c := range n.children {
+		b := "├─ "
+		if i == len(n.children)-1 {
+			b = "└─ "
+		}
+		collectLines(c, childPrefix, b, out)
+	}
+}
+
+// turnSummary heads a turn's tree: when it ran, how long, and what it spent.
+func turnSummary(root *spanNode) string {
+	var llmCalls, toolCalls, in, out, cached int64
+	var walk func(*spanNode)
+	walk = func(n *spanNode) {
+		switch n.Name {
+		case tracing.SpanLLM:
+			llmCalls++
+			in += n.IntAttr(tracing.AttrInputTokens)
+			out += n.IntAttr(tracing.AttrOutputTokens)
+			cached += n.IntAttr(tracing.AttrCachedTokens)
+		case tracing.SpanTool:
+			toolCalls++
+		}
+		for _, c := range n.children {
+			walk(c)
+		}
+	}
+	walk(root)
+	summary := fmt.Sprintf("%s  %s  %d LLM call(s), %d tool call(s), %d in / %d out tokens",
+		root.Start.Local().Format("2006-01-02 15:04:05"), formatDuration(root.Duration()), llmCalls, toolCalls, in, out)
+	if cached > 0 {
+		summary += fmt.Sprintf(" (%d cached)", cached)
+	}
+	return summary
+}
+
+// describeSpan is the tree label of a span: its kind and what it was about.
+func describeSpan(s Span) string {
+	switch s.Name {
+	case tracing.SpanInvoke:
+		return "turn " + s.StringAttr(tracing.AttrSkill)
+	case tracing.SpanSubagent:
+		label := "agent " + s.StringAttr(tracing.AttrAgent)
+		if s.BoolAttr(tracing.AttrBackground) {
+			label += " (background)"
+		}
+		return label
+	case tracing.SpanIteration:
+		return fmt.Sprintf("iteration %d", s.IntAttr(tracing.AttrIteration))
+	case tracing.SpanLLM:
+		label := "llm " + s.StringAttr(tracing.AttrModel)
+		if in, out := s.IntAttr(tracing.AttrInputTokens), s.IntAttr(tracing.AttrOutputTokens); in+out > 0 {
+			label += fmt.Sprintf(" in=%d out=%d", in, out)
+		}
+		if cached := s.IntAttr(tracing.AttrCachedTokens); cached > 0 {
+			label += fmt.Sprintf(" cached=%d", cached)
+		}
+		if kind := s.StringAttr(tracing.AttrResponseType); kind != "" {
+			label += " → " + kind
+		}
+		return label
+	case tracing.SpanTool:
+		label := fmt.Sprintf("tool %s args=%s result=%s", s.StringAttr(tracing.AttrToolName),
+			formatBytes(s.IntAttr(tracing.AttrArgsBytes)), formatBytes(s.IntAttr(tracing.AttrResultBytes)))
+		if s.BoolAttr(tracing.AttrOffloaded) {
+			label += " offloaded"
+		}
+		return label
+	default:
+		return s.Name
+	}
+}
+
+// spanNote follows the timing columns: how the span ended, if not cleanly.
+func spanNote(s Span) string {
+	switch {
+	case s.Error != "":
+		return "  ERROR: " + firstLine(s.Error)
+	case s.BoolAttr(tracing.AttrCancelled):
+		return "  (cancelled)"
+	case s.BoolAttr(tracing.AttrWaiting):
+		return "  (waiting for approval)"
+	}
+	return ""
+}
+
+// StringAttr is the string attribute key, or "".
+func (s Span) StringAttr(key attribute.Key) string {
+	v, _ := s.Attributes[string(key)].(string)
+	return v
+}
+
+// IntAttr is the integer attribute key, or 0. Integers come back from JSON
+// as float64.
+func (s Span) IntAttr(key attribute.Key) int64 {
+	switch v := s.Attributes[string(key)].(type) {
+	case float64:
+		return int64(v)
+	case int64:
+		return v
+	}
+	return 0
+}
+
+// BoolAttr is the boolean attribute key, or false.
+func (s Span) BoolAttr(key attribute.Key) bool {
+	v, _ := s.Attributes[string(key)].(bool)
+	return v
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i] + " …"
+	}
+	return s
+}
+
+func formatDuration(d time.Duration) string {
+	switch {
+	case d < time.Millisecond:
+		return fmt.Sprintf("%dµs", d.Microseconds())
+	case d < time.Second:
+		return fmt.Sprintf("%dms", d.Milliseconds())
+	case d < time.Minute:
+		return fmt.Sprintf("%.2fs", d.Seconds())
+	default:
+		return d.Round(time.Second).String()
+	}
+}
+
+func formatBytes(n int64) string {
+	switch {
+	case n < 1024:
+		return fmt.Sprintf("%dB", n)
+	case n < 1024*1024:
+		return fmt.Sprintf("%.1fKB", float64(n)/1024)
+	default:
+		return fmt.Sprintf("%.1fMB", float64(n)/(1024*1024))
+	}
+}
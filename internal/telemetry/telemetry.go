@@ -0,0 +1,55 @@
+// Package telemetry installs the OpenTelemetry tracer provider behind the
+// spans pkg/agent/tracing names, exporting them over OTLP or to JSONL trace
+// files that `klein trace show` renders.
+package telemetry
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+
+	"github.com/fpt/klein-cli/internal/config"
+)
+
+// serviceName identifies klein's spans to a collector.
+const serviceName = "klein"
+
+// Setup installs the tracer provider settings.Exporter asks for and returns
+// the function that flushes and stops it, to be called before exit. With no
+// exporter it installs nothing and the returned shutdown does nothing.
+//
+// defaultTraceFile is where the jsonl exporter writes spans whose context
+// names no trace file (see WithTraceFile): turns of a run with no session.
+func Setup(ctx context.Context, settings config.TraceSettings, defaultTraceFile string) (shutdown func(context.Context) error, err error) {
+	var processor sdktrace.SpanProcessor
+	switch settings.Exporter {
+	case "":
+		return func(context.Context) error { return nil }, nil
+	case config.TraceExporterJSONL:
+		processor = NewFileProcessor(defaultTraceFile)
+	case config.TraceExporterOTLP:
+		var opts []otlptracehttp.Option
+		if settings.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(settings.Endpoint))
+		}
+		exporter, err := otlptracehttp.New(ctx, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("creating OTLP exporter: %w", err)
+		}
+		processor = sdktrace.NewBatchSpanProcessor(exporter)
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", settings.Exporter)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(processor),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
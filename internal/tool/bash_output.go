@@ -0,0 +1,95 @@
+package tool
+
+import (
+	"bytes"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/fpt/klein-cli/pkg/agent/domain"
+)
+
+// maxPartialLine is how much of an unterminated line is held back before it is
+// streamed anyway, so a progress bar or a huge single-line JSON blob still
+// shows up while the command runs.
+const maxPartialLine = 4096
+
+// outputStreamer collects a command's stdout and stderr into one buffer, in
+// the order they arrive, and forwards complete lines to a ToolOutputFunc as
+// they are written.
+type outputStreamer struct {
+	mu       sync.Mutex
+	combined bytes.Buffer
+	emit     domain.ToolOutputFunc
+	streams  []*streamWriter
+}
+
+func newOutputStreamer(emit domain.ToolOutputFunc) *outputStreamer {
+	return &outputStreamer{emit: emit}
+}
+
+// writer returns an io.Writer for one named stream ("stdout" or "stderr").
+func (o *outputStreamer) writer(stream string) *streamWriter {
+	w := &streamWriter{o: o, stream: stream}
+	o.streams = append(o.streams, w)
+	return w
+}
+
+// flush forwards whatever is left of each stream's last, unterminated line.
+func (o *outputStreamer) flush() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, w := range o.streams {
+		w.send(len(w.pending))
+	}
+}
+
+// String returns everything written so far.
+func (o *outputStreamer) String() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.combined.String()
+}
+
+type streamWriter struct {
+	o       *outputStreamer
+	stream  string
+	pending []byte
+}
+
+func (w *streamWriter) Write(p []byte) (int, error) {
+	w.o.mu.Lock()
+	defer w.o.mu.Unlock()
+	w.o.combined.Write(p)
+	if w.o.emit == nil {
+		return len(p), nil
+	}
+	w.pending = append(w.pending, p...)
+	if i := bytes.LastIndexByte(w.pending, '\n'); i >= 0 {
+		w.send(i + 1)
+	}
+	for len(w.pending) >= maxPartialLine {
+		w.send(runeBoundary(w.pending, maxPartialLine))
+	}
+	return len(p), nil
+}
+
+// send forwards the first n pending bytes. The caller holds the lock, which
+// also keeps the sink from being called concurrently by both streams.
+func (w *streamWriter) send(n int) {
+	if n == 0 || w.o.emit == nil {
+		return
+	}
+	w.o.emit(w.stream, string(w.pending[:n]))
+	w.pending = append(w.pending[:0], w.pending[n:]...)
+}
+
+// runeBoundary returns the largest n <= max that does not split a UTF-8
+// sequence in b.
+func runeBoundary(b []byte, max int) int {
+	for n := max; n > max-utf8.UTFMax && n > 0; n-- {
+		if utf8.RuneStart(b[n]) {
+			return n
+		}
+	}
+	return max
+}
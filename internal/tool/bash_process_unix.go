@@ -0,0 +1,27 @@
+//go:build !windows
+
+package tool
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// killProcessGroupOnCancel runs cmd as the leader of a new process group and
+// makes context cancellation kill the whole group.
+//
+// By default exec.CommandContext kills only the bash process. Anything it
+// started — the compiler under `make`, a test binary, a backgrounded server —
+// survives, keeps the output pipes open and leaves Wait blocked until it exits
+// on its own. Killing -pgid takes the whole tree down at once. The new group
+// also keeps a terminal Ctrl+C away from the command: klein turns it into a
+// context cancellation, which lands here.
+func killProcessGroupOnCancel(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
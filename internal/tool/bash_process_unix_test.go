@@ -0,0 +1,63 @@
+//go:build !windows
+
+package tool
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/fpt/klein-cli/pkg/agent/domain"
+)
+
+// Cancelling a command must take down everything it started, not just bash:
+// a surviving child keeps the output pipes open and keeps running after the
+// user pressed Esc.
+func TestBashCancelKillsProcessGroup(t *testing.T) {
+	m := NewBashToolManager(BashConfig{WorkingDir: t.TempDir()})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pids := make(chan int, 1)
+	ctx = domain.WithToolOutput(ctx, func(_, chunk string) {
+		if pid, err := strconv.Atoi(strings.TrimSpace(chunk)); err == nil {
+			pids <- pid
+			cancel()
+		}
+	})
+
+	start := time.Now()
+	_, err := m.executeCommand(ctx, "sleep 30 & echo $!; wait", "")
+	if err == nil || !strings.Contains(err.Error(), "cancelled") {
+		t.Fatalf("err = %v, want a cancellation", err)
+	}
+	if elapsed := time.Since(start); elapsed >= bashWaitDelay {
+		t.Fatalf("cancel took %v: the background sleep kept the pipes open", elapsed)
+	}
+
+	pid := <-pids
+	deadline := time.Now().Add(2 * time.Second)
+	for processAlive(pid) {
+		if time.Now().After(deadline) {
+			t.Fatalf("background child %d survived cancellation", pid)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// processAlive reports whether pid is running; a zombie waiting for a reaper
+// counts as dead.
+func processAlive(pid int) bool {
+	if err := syscall.Kill(pid, 0); err != nil {
+		return false
+	}
+	stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return true // no procfs (macOS): trust kill(0)
+	}
+	fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+	return len(fields) == 0 || fields[0] != "Z"
+}
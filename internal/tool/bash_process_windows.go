@@ -0,0 +1,25 @@
+//go:build windows
+
+package tool
+
+import (
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+// killProcessGroupOnCancel is the Windows counterpart of the unix version:
+// the command gets its own process group, and cancellation kills its whole
+// process tree with taskkill /T instead of just the shell.
+func killProcessGroupOnCancel(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.CreationFlags |= syscall.CREATE_NEW_PROCESS_GROUP
+	cmd.Cancel = func() error {
+		if err := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)).Run(); err != nil {
+			return cmd.Process.Kill()
+		}
+		return nil
+	}
+}
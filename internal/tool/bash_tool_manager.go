@@ -2,24 +2,37 @@ package tool
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/fpt/klein-cli/pkg/agent/domain"
 	pkgLogger "github.com/fpt/klein-cli/pkg/logger"
 	"github.com/fpt/klein-cli/pkg/message"
 )
 
+// bashWaitDelay is how long a finished or killed command may keep its output
+// pipes open (through a process that escaped its group) before Bash stops
+// reading them.
+const bashWaitDelay = 2 * time.Second
+
 // BashToolManager provides shell command execution capabilities
 type BashToolManager struct {
 	tools               map[message.ToolName]message.Tool
 	workingDir          string
 	maxDuration         time.Duration
 	whitelistedCommands []string // Commands that don't require approval
+	terminal            TerminalDispatcher
 }
 
+// TerminalDispatcher runs a command in a terminal the user can see — an
+// attached editor's integrated terminal — instead of capturing its output. It
+// returns the name of the terminal the command went to.
+type TerminalDispatcher func(ctx context.Context, command, description string) (string, error)
+
 // BashConfig holds configuration for the bash tool manager
 type BashConfig struct {
 	WorkingDir          string        `json:"working_dir"`          // Working directory for commands
@@ -76,31 +89,47 @@ func (m *BashToolManager) RegisterTool(name message.ToolName, description messag
 	m.tools[name] = tool
 }
 
+// SetTerminalDispatcher lets Bash hand commands to the user's terminal. The
+// tool gains an in_terminal argument while a dispatcher is set.
+func (m *BashToolManager) SetTerminalDispatcher(d TerminalDispatcher) {
+	m.terminal = d
+	m.registerBashTools()
+}
+
 // registerBashTools registers all bash command tools
 func (m *BashToolManager) registerBashTools() {
+	args := []message.ToolArgument{
+		{
+			Name:        "command",
+			Description: "Shell command to execute (e.g., 'go build ./klein', 'git status', 'ls -la')",
+			Required:    true,
+			Type:        "string",
+		},
+		{
+			Name:        "description",
+			Description: "Clear description of what this command does (5-10 words)",
+			Required:    false,
+			Type:        "string",
+		},
+		{
+			Name:        "timeout",
+			Description: "Optional timeout in milliseconds (max 600000ms / 10 minutes)",
+			Required:    false,
+			Type:        "number",
+		},
+	}
+	if m.terminal != nil {
+		args = append(args, message.ToolArgument{
+			Name:        "in_terminal",
+			Description: "Run in the user's editor terminal instead of capturing output — for dev servers, watchers, or commands the user wants to follow. The output is NOT returned.",
+			Required:    false,
+			Type:        "boolean",
+		})
+	}
+
 	// Primary Bash tool
 	m.RegisterTool("Bash", "Execute shell commands with timeout and error handling. Prefer tools over shell for file reads/search (use Read/Glob/Grep/LS). Provide a short description; quote paths with spaces.",
-		[]message.ToolArgument{
-			{
-				Name:        "command",
-				Description: "Shell command to execute (e.g., 'go build ./klein', 'git status', 'ls -la')",
-				Required:    true,
-				Type:        "string",
-			},
-			{
-				Name:        "description",
-				Description: "Clear description of what this command does (5-10 words)",
-				Required:    false,
-				Type:        "string",
-			},
-			{
-				Name:        "timeout",
-				Description: "Optional timeout in milliseconds (max 600000ms / 10 minutes)",
-				Required:    false,
-				Type:        "number",
-			},
-		},
-		m.handleBash)
+		args, m.handleBash)
 
 	// Note: dedicated Grep tool is provided by SearchToolManager; avoid duplicating here.
 }
@@ -192,6 +221,14 @@ func (m *BashToolManager) handleBash(ctx context.Context, args message.ToolArgum
 		return message.NewToolResultError(err.Error()), nil
 	}
 
+	if inTerminal, _ := args["in_terminal"].(bool); inTerminal && m.terminal != nil {
+		name, err := m.terminal(ctx, command, description)
+		if err != nil {
+			return message.NewToolResultError(fmt.Sprintf("failed to run the command in the editor terminal: %v", err)), nil
+		}
+		return message.NewToolResultText(fmt.Sprintf("Started in the editor terminal %q. Its output is not captured; ask the user or run a follow-up command to check the result.", name)), nil
+	}
+
 	// Create context with timeout
 	cmdCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
@@ -240,20 +277,37 @@ func (m *BashToolManager) executeCommand(ctx context.Context, command, descripti
 
 	// Prepare command
 	cmd := exec.CommandContext(ctx, "bash", "-c", command)
+	killProcessGroupOnCancel(cmd)
+	cmd.WaitDelay = bashWaitDelay
 
 	// Set working directory if specified
 	if m.workingDir != "" {
 		cmd.Dir = m.workingDir
 	}
 
-	// Capture both stdout and stderr
-	output, err := cmd.CombinedOutput()
-	outputStr := string(output)
+	// Capture both stdout and stderr, streaming lines to any listener as they
+	// arrive.
+	output := newOutputStreamer(domain.ToolOutput(ctx))
+	cmd.Stdout = output.writer("stdout")
+	cmd.Stderr = output.writer("stderr")
+	err := cmd.Run()
+	output.flush()
+	outputStr := output.String()
+
+	// A process the command left running in the background can hold the
+	// output pipes open after bash exits; Run gives up on it after
+	// WaitDelay. The command itself succeeded.
+	if errors.Is(err, exec.ErrWaitDelay) {
+		err = nil
+	}
 
 	// Handle different exit scenarios
 	if ctx.Err() == context.DeadlineExceeded {
 		return "", fmt.Errorf("command timed out after %v: %s", m.maxDuration, command)
 	}
+	if ctx.Err() == context.Canceled {
+		return "", fmt.Errorf("command cancelled: %s\nOutput: %s", command, outputStr)
+	}
 
 	if err != nil {
 		// Check if it's a normal exit error with output
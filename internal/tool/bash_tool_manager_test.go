@@ -0,0 +1,117 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/fpt/klein-cli/pkg/agent/domain"
+	"github.com/fpt/klein-cli/pkg/message"
+)
+
+func hasArgument(tool message.Tool, name message.ToolName) bool {
+	for _, a := range tool.Arguments() {
+		if a.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestBashInTerminal(t *testing.T) {
+	m := NewBashToolManager(BashConfig{WorkingDir: t.TempDir()})
+	bash, _ := m.GetTool("Bash")
+	if hasArgument(bash, "in_terminal") {
+		t.Fatal("in_terminal must only be offered with a terminal dispatcher")
+	}
+
+	var dispatched []string
+	m.SetTerminalDispatcher(func(_ context.Context, command, _ string) (string, error) {
+		dispatched = append(dispatched, command)
+		return "klein", nil
+	})
+	bash, _ = m.GetTool("Bash")
+	if !hasArgument(bash, "in_terminal") {
+		t.Fatal("in_terminal should be offered with a terminal dispatcher")
+	}
+
+	res, _ := m.CallTool(context.Background(), "Bash", message.ToolArgumentValues{"command": "npm run dev", "in_terminal": true})
+	if res.Error != "" || !strings.Contains(res.Text, `"klein"`) {
+		t.Errorf("dispatch result = %+v", res)
+	}
+	// Security checks still apply to dispatched commands.
+	res, _ = m.CallTool(context.Background(), "Bash", message.ToolArgumentValues{"command": "echo $(whoami)", "in_terminal": true})
+	if res.Error == "" {
+		t.Error("an injected command must be blocked before dispatch")
+	}
+	if len(dispatched) != 1 || dispatched[0] != "npm run dev" {
+		t.Errorf("dispatched = %v", dispatched)
+	}
+
+	m.SetTerminalDispatcher(func(context.Context, string, string) (string, error) {
+		return "", errors.New("no terminal")
+	})
+	res, _ = m.CallTool(context.Background(), "Bash", message.ToolArgumentValues{"command": "make", "in_terminal": true})
+	if !strings.Contains(res.Error, "no terminal") {
+		t.Errorf("dispatcher error should surface, got %+v", res)
+	}
+}
+
+func TestBashStreamsOutput(t *testing.T) {
+	m := NewBashToolManager(BashConfig{WorkingDir: t.TempDir()})
+	type delta struct{ stream, text string }
+	var deltas []delta
+	ctx := domain.WithToolOutput(context.Background(), func(stream, chunk string) {
+		deltas = append(deltas, delta{stream, chunk})
+	})
+
+	// stdout and stderr are separate pipes; the sleeps pin their relative order.
+	out, err := m.executeCommand(ctx, "echo one; sleep 0.1; echo two >&2; sleep 0.1; echo three; printf tail", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "one\ntwo\nthree\ntail" {
+		t.Errorf("final output = %q", out)
+	}
+	var stdout, stderr strings.Builder
+	for _, d := range deltas {
+		switch d.stream {
+		case "stdout":
+			stdout.WriteString(d.text)
+		case "stderr":
+			stderr.WriteString(d.text)
+		default:
+			t.Errorf("unexpected stream %q", d.stream)
+		}
+	}
+	if stdout.String() != "one\nthree\ntail" || stderr.String() != "two\n" {
+		t.Errorf("streamed stdout = %q, stderr = %q", stdout.String(), stderr.String())
+	}
+	if last := deltas[len(deltas)-1]; last.text != "tail" {
+		t.Errorf("unterminated last line should be flushed at exit, got %+v", last)
+	}
+}
+
+func TestOutputStreamerSplitsLongLines(t *testing.T) {
+	var chunks []string
+	o := newOutputStreamer(func(_, chunk string) { chunks = append(chunks, chunk) })
+	w := o.writer("stdout")
+	long := strings.Repeat("é", maxPartialLine) // two bytes per rune
+	w.Write([]byte(long[:maxPartialLine+1]))
+	w.Write([]byte(long[maxPartialLine+1:] + "\nnext"))
+	o.flush()
+
+	if strings.Join(chunks, "") != long+"\nnext" || o.String() != long+"\nnext" {
+		t.Fatalf("chunks do not add up to the output")
+	}
+	for _, c := range chunks {
+		if !utf8.ValidString(c) {
+			t.Fatalf("chunk splits a rune: %q", c[len(c)-4:])
+		}
+	}
+	if len(chunks[0]) != maxPartialLine {
+		t.Errorf("first chunk = %d bytes, want a partial line of %d", len(chunks[0]), maxPartialLine)
+	}
+}
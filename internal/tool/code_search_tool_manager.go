@@ -0,0 +1,212 @@
+package tool
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/fpt/klein-cli/internal/codeindex"
+	"github.com/fpt/klein-cli/internal/repository"
+	"github.com/fpt/klein-cli/pkg/agent/domain"
+	"github.com/fpt/klein-cli/pkg/message"
+)
+
+const codeSearchDefaultLimit = 50
+
+// CodeSearchToolManager provides the CodeSearch tool: symbol-level queries
+// (definitions, references, callers, outlines) answered from an incremental
+// per-project index rather than by text search.
+type CodeSearchToolManager struct {
+	tools map[message.ToolName]message.Tool
+	index *codeindex.Index
+}
+
+// NewCodeSearchToolManager creates a CodeSearch tool over workingDir. The index
+// is persisted at indexPath so a later session starts warm; an empty indexPath
+// keeps it in memory for the life of the process. Like Grep, the index skips
+// ignored files and never reads one outside fsConfig's allowed directories or
+// matching its blacklist.
+func NewCodeSearchToolManager(workingDir, indexPath string, fsConfig repository.FileSystemConfig) domain.ToolManager {
+	absWorkingDir, err := filepath.Abs(workingDir)
+	if err != nil {
+		absWorkingDir = workingDir
+	}
+	var allowed []string
+	for _, dir := range ensureWorkingDirectoryInAllowedList(fsConfig.AllowedDirectories, absWorkingDir) {
+		if dirAbs, err := filepath.Abs(dir); err == nil {
+			allowed = append(allowed, dirAbs)
+		}
+	}
+	skip := func(abs string) bool {
+		return checkPathAllowed(abs, allowed) != nil || checkBlacklist(abs, fsConfig.BlacklistedFiles) != nil
+	}
+	m := &CodeSearchToolManager{
+		tools: make(map[message.ToolName]message.Tool),
+		index: codeindex.Open(absWorkingDir, indexPath, skip),
+	}
+	m.register()
+	return m
+}
+
+func (m *CodeSearchToolManager) register() {
+	m.RegisterTool("CodeSearch",
+		"Search the project's symbol index. Faster and more precise than Grep for code navigation: "+
+			"mode=definition answers \"where is X defined\" (query may be qualified, e.g. Agent.Invoke); "+
+			"mode=references lists every use of a name; mode=callers lists only call sites, with the enclosing function; "+
+			"mode=outline lists the symbols of a file or directory (a Go package). "+
+			"Go is parsed exactly; Python, JS/TS, Rust, Java, Kotlin, C/C++, Ruby and Swift use pattern-based parsing. "+
+			"The index refreshes changed files before every query.",
+		[]message.ToolArgument{
+			{Name: "mode", Description: "definition|references|callers|outline (default: definition)", Required: false, Type: "string"},
+			{Name: "query", Description: "Symbol name, optionally qualified (Type.Method). Required except for outline", Required: false, Type: "string"},
+			{Name: "path", Description: "File or directory to outline (outline mode; default: project root)", Required: false, Type: "string"},
+			{Name: "kind", Description: "Filter definitions by kind: func|method|type|interface|class|var|const|module", Required: false, Type: "string"},
+			{Name: "limit", Description: "Maximum results (default 50)", Required: false, Type: "number"},
+		},
+		m.handleCodeSearch)
+}
+
+func (m *CodeSearchToolManager) handleCodeSearch(ctx context.Context, args message.ToolArgumentValues) (message.ToolResult, error) {
+	mode, _ := args["mode"].(string)
+	if mode == "" {
+		mode = "definition"
+	}
+	query, _ := args["query"].(string)
+	query = strings.TrimSpace(query)
+	limit := codeSearchDefaultLimit
+	if v, ok := args["limit"].(float64); ok && int(v) > 0 {
+		limit = int(v)
+	}
+
+	if mode != "outline" && query == "" {
+		return message.NewToolResultError(fmt.Sprintf("query parameter is required for mode %q", mode)), nil
+	}
+
+	switch mode {
+	case "definition", "definitions":
+		kind, _ := args["kind"].(string)
+		locs, err := m.index.Definitions(ctx, query, codeindex.Kind(kind))
+		if err != nil {
+			return message.NewToolResultError(fmt.Sprintf("code search failed: %v", err)), nil
+		}
+		if len(locs) == 0 {
+			return message.NewToolResultText(fmt.Sprintf("No definition of %q found.", query)), nil
+		}
+		var b strings.Builder
+		for i, l := range locs {
+			if i == limit {
+				fmt.Fprintf(&b, "... %d more\n", len(locs)-limit)
+				break
+			}
+			fmt.Fprintf(&b, "%s:%d  %s %s\n", l.Path, l.Line, l.Kind, l.QualifiedName())
+			if l.Signature != "" {
+				fmt.Fprintf(&b, "    %s\n", l.Signature)
+			}
+		}
+		return message.NewToolResultText(strings.TrimSuffix(b.String(), "\n")), nil
+
+	case "references", "callers":
+		hits, err := m.index.References(ctx, query, mode == "callers")
+		if err != nil {
+			return message.NewToolResultError(fmt.Sprintf("code search failed: %v", err)), nil
+		}
+		if len(hits) == 0 {
+			noun := "references to"
+			if mode == "callers" {
+				noun = "calls to"
+			}
+			return message.NewToolResultText(fmt.Sprintf("No %s %q found.", noun, query)), nil
+		}
+		var b strings.Builder
+		for i, h := range hits {
+			if i == limit {
+				fmt.Fprintf(&b, "... %d more\n", len(hits)-limit)
+				break
+			}
+			caller := h.Caller
+			if caller == "" {
+				caller = "(top level)"
+			}
+			fmt.Fprintf(&b, "%s:%d  in %s  %s\n", h.Path, h.Line, caller, h.Text)
+		}
+		return message.NewToolResultText(strings.TrimSuffix(b.String(), "\n")), nil
+
+	case "outline":
+		path, _ := args["path"].(string)
+		if path == "" {
+			path = query
+		}
+		files, err := m.index.Outline(ctx, path)
+		if err != nil {
+			return message.NewToolResultError(fmt.Sprintf("code search failed: %v", err)), nil
+		}
+		var b strings.Builder
+		n := 0
+		for _, f := range files {
+			header := f.Path
+			if f.Package != "" {
+				header += " (package " + f.Package + ")"
+			}
+			b.WriteString(header + "\n")
+			for _, s := range f.Symbols {
+				if n == limit {
+					b.WriteString("  ... truncated; outline a single file or raise limit\n")
+					return message.NewToolResultText(strings.TrimSuffix(b.String(), "\n")), nil
+				}
+				n++
+				sig := s.Signature
+				if sig == "" {
+					sig = s.QualifiedName()
+				}
+				fmt.Fprintf(&b, "  %d  %s  %s\n", s.Line, s.Kind, sig)
+			}
+		}
+		return message.NewToolResultText(strings.TrimSuffix(b.String(), "\n")), nil
+	}
+	return message.NewToolResultError(fmt.Sprintf("unknown mode %q (want definition, references, callers or outline)", mode)), nil
+}
+
+// ToolManager interface implementation
+
+func (m *CodeSearchToolManager) GetTool(name message.ToolName) (message.Tool, bool) {
+	t, ok := m.tools[name]
+	return t, ok
+}
+
+func (m *CodeSearchToolManager) GetTools() map[message.ToolName]message.Tool {
+	return m.tools
+}
+
+func (m *CodeSearchToolManager) CallTool(ctx context.Context, name message.ToolName, args message.ToolArgumentValues) (message.ToolResult, error) {
+	t, ok := m.tools[name]
+	if !ok {
+		return message.NewToolResultError(fmt.Sprintf("tool '%s' not found", name)), nil
+	}
+	return t.Handler()(ctx, args)
+}
+
+func (m *CodeSearchToolManager) RegisterTool(name message.ToolName, description message.ToolDescription, arguments []message.ToolArgument, handler func(ctx context.Context, args message.ToolArgumentValues) (message.ToolResult, error)) {
+	m.tools[name] = &codeSearchTool{
+		name:        name,
+		description: description,
+		arguments:   arguments,
+		handler:     handler,
+	}
+}
+
+// codeSearchTool implements message.Tool
+type codeSearchTool struct {
+	name        message.ToolName
+	description message.ToolDescription
+	arguments   []message.ToolArgument
+	handler     func(ctx context.Context, args message.ToolArgumentValues) (message.ToolResult, error)
+}
+
+func (t *codeSearchTool) RawName() message.ToolName            { return t.name }
+func (t *codeSearchTool) Name() message.ToolName               { return t.name }
+func (t *codeSearchTool) Description() message.ToolDescription { return t.description }
+func (t *codeSearchTool) Arguments() []message.ToolArgument    { return t.arguments }
+func (t *codeSearchTool) Handler() func(ctx context.Context, args message.ToolArgumentValues) (message.ToolResult, error) {
+	return t.handler
+}
@@ -0,0 +1,108 @@
+package tool
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fpt/klein-cli/internal/repository"
+	"github.com/fpt/klein-cli/pkg/message"
+)
+
+func newCodeSearchFixture(t *testing.T) *CodeSearchToolManager {
+	t.Helper()
+	root := t.TempDir()
+	files := map[string]string{
+		"svc/svc.go": "package svc\n\ntype Server struct{}\n\nfunc (s *Server) Start() error { return boot() }\n\nfunc boot() error { return nil }\n",
+		"svc/cli.go": "package svc\n\nfunc Main() { _ = boot() }\n",
+	}
+	for rel, content := range files {
+		p := filepath.Join(root, rel)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewCodeSearchToolManager(root, "", repository.FileSystemConfig{}).(*CodeSearchToolManager)
+}
+
+func callCodeSearch(t *testing.T, m *CodeSearchToolManager, args message.ToolArgumentValues) message.ToolResult {
+	t.Helper()
+	res, err := m.CallTool(context.Background(), "CodeSearch", args)
+	if err != nil {
+		t.Fatalf("CodeSearch returned transport error: %v", err)
+	}
+	return res
+}
+
+func TestCodeSearch_Modes(t *testing.T) {
+	m := newCodeSearchFixture(t)
+
+	res := callCodeSearch(t, m, message.ToolArgumentValues{"query": "Server.Start"})
+	if res.Error != "" || !strings.Contains(res.Text, "svc/svc.go:5  method Server.Start") ||
+		!strings.Contains(res.Text, "func (s *Server) Start() error") {
+		t.Errorf("definition: %+v", res)
+	}
+
+	res = callCodeSearch(t, m, message.ToolArgumentValues{"mode": "callers", "query": "boot"})
+	want := "svc/cli.go:3  in Main  func Main() { _ = boot() }\nsvc/svc.go:5  in Server.Start  func (s *Server) Start() error { return boot() }"
+	if res.Text != want {
+		t.Errorf("callers:\n got %q\nwant %q", res.Text, want)
+	}
+
+	res = callCodeSearch(t, m, message.ToolArgumentValues{"mode": "callers", "query": "boot", "limit": float64(1)})
+	if !strings.HasSuffix(res.Text, "... 1 more") {
+		t.Errorf("limit not applied: %q", res.Text)
+	}
+
+	res = callCodeSearch(t, m, message.ToolArgumentValues{"mode": "outline", "path": "svc"})
+	if !strings.HasPrefix(res.Text, "svc/cli.go (package svc)\n  3  func  func Main()") ||
+		!strings.Contains(res.Text, "  3  type  type Server struct") {
+		t.Errorf("outline: %q", res.Text)
+	}
+
+	res = callCodeSearch(t, m, message.ToolArgumentValues{"query": "Missing"})
+	if res.Error != "" || res.Text != `No definition of "Missing" found.` {
+		t.Errorf("miss: %+v", res)
+	}
+}
+
+func TestCodeSearch_SkipsBlacklistedFiles(t *testing.T) {
+	root := t.TempDir()
+	for rel, content := range map[string]string{
+		"svc/svc.go":     "package svc\n\nfunc Start() {}\n",
+		"svc/secrets.go": "package svc\n\nfunc APIKey() string { return \"k\" }\n",
+	} {
+		if err := os.MkdirAll(filepath.Join(root, filepath.Dir(rel)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, rel), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m := NewCodeSearchToolManager(root, "", repository.FileSystemConfig{BlacklistedFiles: []string{"secrets.go"}}).(*CodeSearchToolManager)
+
+	if res := callCodeSearch(t, m, message.ToolArgumentValues{"query": "APIKey"}); !strings.HasPrefix(res.Text, "No definition") {
+		t.Errorf("blacklisted file was indexed: %+v", res)
+	}
+	if res := callCodeSearch(t, m, message.ToolArgumentValues{"query": "Start"}); !strings.Contains(res.Text, "svc/svc.go:3") {
+		t.Errorf("definition: %+v", res)
+	}
+}
+
+func TestCodeSearch_Errors(t *testing.T) {
+	m := newCodeSearchFixture(t)
+	if res := callCodeSearch(t, m, message.ToolArgumentValues{"mode": "callers"}); !strings.Contains(res.Error, "query parameter is required") {
+		t.Errorf("missing query: %+v", res)
+	}
+	if res := callCodeSearch(t, m, message.ToolArgumentValues{"mode": "grep", "query": "x"}); !strings.Contains(res.Error, "unknown mode") {
+		t.Errorf("bad mode: %+v", res)
+	}
+	if res := callCodeSearch(t, m, message.ToolArgumentValues{"mode": "outline", "path": "nowhere"}); res.Error == "" {
+		t.Errorf("outline of a missing dir should fail: %+v", res)
+	}
+}
@@ -0,0 +1,50 @@
+package tool
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/fpt/klein-cli/internal/webfetch"
+	"github.com/fpt/klein-cli/pkg/message"
+)
+
+// fetchRefusal is the error a tool returns when the web policy refuses a
+// fetch: a JSON object naming the rule, so the model can tell a refusal from
+// a flaky site.
+type fetchRefusal struct {
+	Error  string          `json:"error"`
+	Reason webfetch.Reason `json:"reason"`
+	URL    string          `json:"url"`
+	Detail string          `json:"detail"`
+	Hint   string          `json:"hint"`
+}
+
+// fetchError is the tool result for a failed fetch. A policy refusal is
+// reported as a fetchRefusal; anything else keeps its prose message,
+// prefixed with what was being done when doing is set.
+func fetchError(doing string, err error) message.ToolResult {
+	fe, ok := webfetch.AsError(err)
+	if !ok && doing == "" {
+		return message.NewToolResultError(err.Error())
+	}
+	if !ok {
+		return message.NewToolResultError(fmt.Sprintf("%s: %v", doing, err))
+	}
+	hint := "The web policy forbids this URL. Do not retry it or reach it another way; ask the user if you need it."
+	switch fe.Reason {
+	case webfetch.ReasonRobots:
+		hint = "The site's robots.txt disallows this page. Do not retry it; look for the information elsewhere."
+	case webfetch.ReasonTooLarge:
+		hint = "The response is larger than the web policy allows. Look for a smaller resource or a more specific page."
+	case webfetch.ReasonTimeout:
+		hint = "The site did not respond in time. It may work later; do not retry in a loop."
+	}
+	b, _ := json.Marshal(fetchRefusal{
+		Error:  "fetch_refused",
+		Reason: fe.Reason,
+		URL:    fe.URL,
+		Detail: fe.Detail,
+		Hint:   hint,
+	})
+	return message.NewToolResultError(string(b))
+}
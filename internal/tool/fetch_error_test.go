@@ -0,0 +1,56 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fpt/klein-cli/internal/webfetch"
+	"github.com/fpt/klein-cli/pkg/agent/domain"
+	"github.com/fpt/klein-cli/pkg/message"
+)
+
+// TestWebToolsReportRefusals checks that each tool that fetches reports a
+// policy refusal as the structured fetchRefusal, not as prose.
+func TestWebToolsReportRefusals(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		t.Error("a guarded tool reached the loopback server")
+	}))
+	defer srv.Close()
+
+	web := NewWebToolManager()
+	pdf := NewPDFToolManager(t.TempDir())
+	calls := []struct {
+		name    string
+		manager domain.ToolManager
+		args    message.ToolArgumentValues
+	}{
+		{"WebFetch", web, message.ToolArgumentValues{"url": srv.URL + "/page"}},
+		{"WebFetch", web, message.ToolArgumentValues{"url": srv.URL + "/doc.pdf"}},
+		{"WebFetchBlock", web, message.ToolArgumentValues{"url": srv.URL + "/page", "block_indices": "1"}},
+		{"PDFInfo", pdf, message.ToolArgumentValues{"path": srv.URL + "/doc.pdf"}},
+	}
+	for _, c := range calls {
+		res, err := c.manager.CallTool(context.Background(), message.ToolName(c.name), c.args)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		var refusal fetchRefusal
+		if err := json.Unmarshal([]byte(res.Error), &refusal); err != nil {
+			t.Errorf("%s %v: error %q is not a fetch refusal", c.name, c.args, res.Error)
+			continue
+		}
+		if refusal.Error != "fetch_refused" || refusal.Reason != webfetch.ReasonPrivateAddress || refusal.URL == "" || refusal.Hint == "" {
+			t.Errorf("%s: refusal = %+v", c.name, refusal)
+		}
+	}
+}
+
+func TestFetchErrorKeepsOtherFailures(t *testing.T) {
+	res := fetchError("failed to download PDF", context.DeadlineExceeded)
+	if res.Error != "failed to download PDF: context deadline exceeded" {
+		t.Errorf("Error = %q", res.Error)
+	}
+}
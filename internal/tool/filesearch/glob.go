@@ -0,0 +1,118 @@
+package filesearch
+
+import (
+	"path"
+	"strings"
+)
+
+// Pattern is a compiled glob with ripgrep/gitignore semantics: `*`, `?` and
+// `[...]` stay within one path segment, `**` spans any number of segments and
+// `{a,b}` expands to alternatives. A pattern without a slash matches a base
+// name at any depth; one with a slash matches the whole relative path.
+type Pattern struct {
+	alternatives [][]string // brace-expanded patterns, split into segments
+	basename     bool
+}
+
+// CompileGlob compiles a glob. A leading "/" or "./" anchors the pattern to
+// the search root (it is always anchored once it contains a slash).
+func CompileGlob(glob string) (*Pattern, error) {
+	glob = strings.TrimPrefix(glob, "./")
+	anchored := strings.HasPrefix(glob, "/")
+	glob = strings.TrimPrefix(glob, "/")
+
+	p := &Pattern{basename: !anchored && !strings.Contains(glob, "/")}
+	for _, alt := range expandBraces(glob) {
+		alt = strings.ReplaceAll(alt, "[!", "[^")
+		segs := strings.Split(alt, "/")
+		for _, seg := range segs {
+			if seg == "**" {
+				continue
+			}
+			if _, err := path.Match(seg, ""); err != nil {
+				return nil, err
+			}
+		}
+		p.alternatives = append(p.alternatives, segs)
+	}
+	return p, nil
+}
+
+// Match reports whether rel, a slash-separated path relative to the search
+// root, matches the pattern.
+func (p *Pattern) Match(rel string) bool {
+	if p.basename {
+		rel = path.Base(rel)
+	}
+	segs := strings.Split(rel, "/")
+	for _, alt := range p.alternatives {
+		if matchSegments(alt, segs) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasHiddenSegment reports whether the glob names a dot file or directory
+// explicitly (".github/**", "**/.env*"), in which case hidden entries have to
+// be walked for it to match anything.
+func HasHiddenSegment(glob string) bool {
+	for _, seg := range strings.Split(strings.TrimPrefix(glob, "./"), "/") {
+		if strings.HasPrefix(seg, ".") && seg != "." && seg != ".." {
+			return true
+		}
+	}
+	return false
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// Collapse runs of ** and try every split point.
+			for len(pattern) > 0 && pattern[0] == "**" {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := range name {
+				if matchSegments(pattern, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// expandBraces expands `{a,b}` alternatives, innermost-first, so
+// "*.{go,md}" becomes "*.go" and "*.md". Unbalanced braces are literal.
+func expandBraces(glob string) []string {
+	open := -1
+	for i := 0; i < len(glob); i++ {
+		switch glob[i] {
+		case '\\':
+			i++
+		case '{':
+			open = i
+		case '}':
+			if open < 0 {
+				continue
+			}
+			var out []string
+			for _, alt := range strings.Split(glob[open+1:i], ",") {
+				out = append(out, expandBraces(glob[:open]+alt+glob[i+1:])...)
+			}
+			return out
+		}
+	}
+	return []string{glob}
+}
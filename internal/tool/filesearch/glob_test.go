@@ -0,0 +1,89 @@
+package filesearch
+
+import "testing"
+
+func TestPatternMatch(t *testing.T) {
+	tests := []struct {
+		glob, rel string
+		want      bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "internal/tool/grep.go", true}, // no slash: base name at any depth
+		{"*.go", "main.go.orig", false},
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "a/b/c.go", true},
+		{"internal/**/*.go", "internal/tool/x.go", true},
+		{"internal/**/*.go", "internal/x.go", true},
+		{"internal/**/*.go", "cmd/internal/x.go", false},
+		{"internal/*.go", "internal/tool/x.go", false},
+		{"/main.go", "cmd/main.go", false}, // leading slash anchors
+		{"./main.go", "main.go", true},
+		{"*.{go,md}", "README.md", true},
+		{"*.{go,md}", "a.txt", false},
+		{"{cmd,internal}/**/*_test.go", "cmd/klein/main_test.go", true},
+		{"file[0-9].txt", "dir/file7.txt", true},
+		{"file[!0-9].txt", "file7.txt", false},
+		{"docs/**", "docs/a/b.md", true},
+		{"?.go", "ab.go", false},
+	}
+	for _, tt := range tests {
+		p, err := CompileGlob(tt.glob)
+		if err != nil {
+			t.Fatalf("CompileGlob(%q): %v", tt.glob, err)
+		}
+		if got := p.Match(tt.rel); got != tt.want {
+			t.Errorf("%q matching %q = %v, want %v", tt.glob, tt.rel, got, tt.want)
+		}
+	}
+}
+
+func TestCompileGlobRejectsBadClass(t *testing.T) {
+	if _, err := CompileGlob("[a-"); err == nil {
+		t.Fatal("want an error for an unterminated class")
+	}
+}
+
+func TestIgnoreRules(t *testing.T) {
+	var rules ignoreRules
+	for _, line := range []string{
+		"# comment",
+		"*.log",
+		"!keep.log",
+		"build/",
+		"/dist",
+		"docs/generated",
+		`\#hash`,
+	} {
+		if r, ok := parseIgnoreLine(line, ""); ok {
+			rules = append(rules, r)
+		}
+	}
+	sub, _ := parseIgnoreLine("*.tmp", "pkg")
+	rules = append(rules, sub)
+
+	tests := []struct {
+		rel   string
+		isDir bool
+		want  bool
+	}{
+		{"app.log", false, true},
+		{"a/b/app.log", false, true},
+		{"keep.log", false, false},
+		{"build", true, true},
+		{"a/build", true, true},
+		{"build", false, false}, // directories only
+		{"dist", true, true},
+		{"a/dist", true, false}, // anchored to the ignore file's directory
+		{"docs/generated", true, true},
+		{"x/docs/generated", true, false},
+		{"#hash", false, true},
+		{"pkg/x.tmp", false, true},
+		{"x.tmp", false, false}, // outside the rule's directory
+		{"main.go", false, false},
+	}
+	for _, tt := range tests {
+		if got := rules.ignored(tt.rel, tt.isDir); got != tt.want {
+			t.Errorf("ignored(%q, dir=%v) = %v, want %v", tt.rel, tt.isDir, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,355 @@
+package filesearch
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Output modes, as the Grep tool names them.
+const (
+	ModeContent = "content"
+	ModeFiles   = "files_with_matches"
+	ModeCount   = "count"
+)
+
+// binarySniffLen is how much of a file is checked for a NUL byte; a file
+// with one is binary and not searched, as ripgrep does for recursive search.
+const binarySniffLen = 8000
+
+// GrepOptions is one search. The zero value lists the files that match
+// Pattern.
+type GrepOptions struct {
+	Pattern     string
+	IgnoreCase  bool
+	Multiline   bool   // "." matches newlines and a match may span lines
+	Mode        string // ModeContent, ModeFiles (default) or ModeCount
+	Before      int    // context lines before each match (content mode)
+	After       int    // context lines after each match (content mode)
+	LineNumbers bool   // prefix content lines with their number
+	Glob        string // only files matching it; a leading "!" excludes instead
+	Type        string // only files of this ripgrep type ("go", "py", …)
+	Hidden      bool   // also search dot files and directories
+
+	// Skip reports files that may not be read, by absolute path. They are
+	// treated as if they did not exist.
+	Skip func(abs string) bool
+	// Workers caps the files searched at once (default GOMAXPROCS).
+	Workers int
+}
+
+// Grep searches root, a directory or a single file, and returns its output
+// in ripgrep's format: paths are root joined with the file's relative path,
+// and are left out when root is a single file. Files are reported in path
+// order whatever order the workers finish in.
+func Grep(ctx context.Context, root string, opts GrepOptions) ([]string, error) {
+	re, err := compilePattern(opts)
+	if err != nil {
+		return nil, err
+	}
+	include, err := fileFilter(opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Mode == "" {
+		opts.Mode = ModeFiles
+	}
+
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		if opts.Skip != nil && opts.Skip(root) {
+			return nil, nil
+		}
+		return searchFile(root, "", re, opts), nil
+	}
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	type job struct {
+		index int
+		rel   string
+	}
+	var (
+		jobs    = make(chan job)
+		mu      sync.Mutex
+		results = map[int][]string{}
+		wg      sync.WaitGroup
+	)
+	for range workers {
+		wg.Go(func() {
+			for j := range jobs {
+				abs := filepath.Join(root, filepath.FromSlash(j.rel))
+				if out := searchFile(abs, abs, re, opts); len(out) > 0 {
+					mu.Lock()
+					results[j.index] = out
+					mu.Unlock()
+				}
+			}
+		})
+	}
+
+	n := 0
+	walkErr := Walk(ctx, root, WalkOptions{Hidden: opts.Hidden}, func(rel string) error {
+		if !include(rel) {
+			return nil
+		}
+		if opts.Skip != nil && opts.Skip(filepath.Join(root, filepath.FromSlash(rel))) {
+			return nil
+		}
+		select {
+		case jobs <- job{index: n, rel: rel}:
+			n++
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	close(jobs)
+	wg.Wait()
+	if walkErr != nil {
+		return nil, walkErr
+	}
+
+	separate := opts.Mode == ModeContent && (opts.Before > 0 || opts.After > 0)
+	var out []string
+	for i := range n {
+		lines, ok := results[i]
+		if !ok {
+			continue
+		}
+		if separate && len(out) > 0 {
+			out = append(out, "--")
+		}
+		out = append(out, lines...)
+	}
+	return out, nil
+}
+
+// Glob returns the files under root matching glob, as sorted slash paths
+// relative to root. Dot files are walked when the glob names one.
+func Glob(ctx context.Context, root, glob string, opts WalkOptions) ([]string, error) {
+	p, err := CompileGlob(glob)
+	if err != nil {
+		return nil, fmt.Errorf("invalid glob %q: %w", glob, err)
+	}
+	opts.Hidden = opts.Hidden || HasHiddenSegment(glob)
+
+	var files []string
+	err = Walk(ctx, root, opts, func(rel string) error {
+		if p.Match(rel) {
+			files = append(files, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func compilePattern(opts GrepOptions) (*regexp.Regexp, error) {
+	flags := ""
+	if opts.IgnoreCase {
+		flags += "i"
+	}
+	if opts.Multiline {
+		flags += "ms"
+	}
+	expr := opts.Pattern
+	if flags != "" {
+		expr = "(?" + flags + ")" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex %q: %w", opts.Pattern, err)
+	}
+	return re, nil
+}
+
+// fileFilter combines the glob and type options into one test on a file's
+// relative path.
+func fileFilter(opts GrepOptions) (func(rel string) bool, error) {
+	var glob, typ *Pattern
+	exclude := false
+	if g := opts.Glob; g != "" {
+		exclude = strings.HasPrefix(g, "!")
+		p, err := CompileGlob(strings.TrimPrefix(g, "!"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %w", g, err)
+		}
+		glob = p
+	}
+	if opts.Type != "" {
+		p, err := typePattern(opts.Type)
+		if err != nil {
+			return nil, err
+		}
+		typ = p
+	}
+	return func(rel string) bool {
+		if glob != nil && glob.Match(rel) == exclude {
+			return false
+		}
+		return typ == nil || typ.Match(rel)
+	}, nil
+}
+
+// searchFile returns one file's share of the output: nothing when it does
+// not match or cannot be read, otherwise lines in opts.Mode's format with
+// display (if any) as the path.
+func searchFile(abs, display string, re *regexp.Regexp, opts GrepOptions) []string {
+	data, err := os.ReadFile(abs)
+	if err != nil || bytes.IndexByte(data[:min(len(data), binarySniffLen)], 0) >= 0 {
+		return nil
+	}
+
+	if opts.Mode == ModeFiles {
+		if matchesAny(data, re, opts.Multiline) {
+			return []string{display}
+		}
+		return nil
+	}
+
+	lines := splitLines(data)
+	matched := matchedLines(data, lines, re, opts.Multiline)
+	if len(matched) == 0 {
+		return nil
+	}
+
+	prefix := ""
+	if display != "" {
+		prefix = display + ":"
+	}
+	if opts.Mode == ModeCount {
+		return []string{prefix + strconv.Itoa(len(matched))}
+	}
+
+	var out []string
+	last := -1 // last line printed
+	for _, m := range matched {
+		from := max(m-opts.Before, last+1)
+		if last >= 0 && from > last+1 && (opts.Before > 0 || opts.After > 0) {
+			out = append(out, "--")
+		}
+		for i := from; i <= m; i++ {
+			out = append(out, formatLine(display, i, lines[i], i == m || isMatched(matched, i), opts.LineNumbers))
+		}
+		last = m
+		for i := m + 1; i <= min(m+opts.After, len(lines)-1); i++ {
+			if isMatched(matched, i) {
+				break // printed as a match in its own turn
+			}
+			out = append(out, formatLine(display, i, lines[i], false, opts.LineNumbers))
+			last = i
+		}
+	}
+	return out
+}
+
+func matchesAny(data []byte, re *regexp.Regexp, multiline bool) bool {
+	if multiline {
+		return re.Match(data)
+	}
+	for _, line := range splitLines(data) {
+		if re.Match(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitLines splits data into lines without their terminators ("\n" or
+// "\r\n"). A final newline does not start another line.
+func splitLines(data []byte) [][]byte {
+	lines := bytes.Split(data, []byte("\n"))
+	if len(lines) > 0 && len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	for i, l := range lines {
+		lines[i] = bytes.TrimSuffix(l, []byte("\r"))
+	}
+	return lines
+}
+
+// matchedLines returns the sorted indexes of the lines a match touches. In
+// multiline mode a match spanning several lines marks every one of them.
+func matchedLines(data []byte, lines [][]byte, re *regexp.Regexp, multiline bool) []int {
+	var matched []int
+	if !multiline {
+		for i, line := range lines {
+			if re.Match(line) {
+				matched = append(matched, i)
+			}
+		}
+		return matched
+	}
+
+	// Offset of the start of each line, to map match offsets to lines.
+	starts := make([]int, len(lines))
+	off := 0
+	for i := range lines {
+		starts[i] = off
+		if next := bytes.IndexByte(data[off:], '\n'); next >= 0 {
+			off += next + 1
+		}
+	}
+	lineOf := func(offset int) int {
+		return sort.Search(len(starts), func(i int) bool { return starts[i] > offset }) - 1
+	}
+
+	seen := map[int]bool{}
+	for _, loc := range re.FindAllIndex(data, -1) {
+		end := loc[1]
+		if end > loc[0] {
+			end-- // last byte of the match
+		}
+		for i := max(lineOf(loc[0]), 0); i <= min(lineOf(end), len(lines)-1); i++ {
+			if !seen[i] {
+				seen[i] = true
+				matched = append(matched, i)
+			}
+		}
+	}
+	sort.Ints(matched)
+	return matched
+}
+
+func isMatched(matched []int, line int) bool {
+	i := sort.SearchInts(matched, line)
+	return i < len(matched) && matched[i] == line
+}
+
+// formatLine renders a line as ripgrep does: ':' after the path and number
+// of a matching line, '-' after those of a context line.
+func formatLine(display string, index int, line []byte, match, numbers bool) string {
+	sep := "-"
+	if match {
+		sep = ":"
+	}
+	var b strings.Builder
+	if display != "" {
+		b.WriteString(display)
+		b.WriteString(sep)
+	}
+	if numbers {
+		b.WriteString(strconv.Itoa(index + 1))
+		b.WriteString(sep)
+	}
+	b.Write(line)
+	return b.String()
+}
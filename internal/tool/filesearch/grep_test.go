@@ -0,0 +1,196 @@
+package filesearch
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeTree creates files (slash paths → content) under a new temp dir.
+func writeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for rel, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestGlobHonoursIgnoreFiles(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		".git/HEAD":                "ref: refs/heads/main\n",
+		".git/info/exclude":        "scratch.go\n",
+		".gitignore":               "vendor/\nnode_modules/\n*.gen.go\n",
+		"main.go":                  "",
+		"scratch.go":               "",
+		"api.gen.go":               "",
+		"vendor/dep/dep.go":        "",
+		"node_modules/x/index.js":  "",
+		"pkg/.ignore":              "old.go\n",
+		"pkg/old.go":               "",
+		"pkg/new.go":               "",
+		"pkg/sub/.gitignore":       "!keep.gen.go\n",
+		"pkg/sub/keep.gen.go":      "",
+		".github/workflows/ci.yml": "",
+	})
+
+	got, err := Glob(context.Background(), root, "**/*.go", WalkOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"main.go", "pkg/new.go", "pkg/sub/keep.gen.go"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Glob = %v, want %v", got, want)
+	}
+
+	// Searching from a subdirectory still applies the repository's rules.
+	got, err = Glob(context.Background(), filepath.Join(root, "pkg"), "*.go", WalkOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"new.go", "sub/keep.gen.go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Glob from pkg = %v, want %v", got, want)
+	}
+
+	// Hidden directories are walked only when the glob asks for them.
+	got, _ = Glob(context.Background(), root, "**/*.yml", WalkOptions{})
+	if len(got) != 0 {
+		t.Errorf("hidden file listed: %v", got)
+	}
+	got, _ = Glob(context.Background(), root, ".github/**/*.yml", WalkOptions{})
+	if want := []string{".github/workflows/ci.yml"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Glob .github = %v, want %v", got, want)
+	}
+
+	got, _ = Glob(context.Background(), root, "**/*.go", WalkOptions{NoIgnore: true})
+	if len(got) != 7 {
+		t.Errorf("NoIgnore Glob = %v, want all 7 .go files", got)
+	}
+}
+
+func TestGrepModes(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"a.go":    "package a\n\nfunc Alpha() {}\nfunc beta() {}\n",
+		"b/b.go":  "package b\n// TODO: alpha\n",
+		"c.txt":   "ALPHA\n",
+		"bin.dat": "alpha\x00\x01",
+	})
+	ctx := context.Background()
+	path := func(rel string) string { return filepath.Join(root, filepath.FromSlash(rel)) }
+
+	files, err := Grep(ctx, root, GrepOptions{Pattern: "alpha", IgnoreCase: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{path("a.go"), path("b/b.go"), path("c.txt")}; !reflect.DeepEqual(files, want) {
+		t.Errorf("files = %v, want %v", files, want)
+	}
+
+	count, _ := Grep(ctx, root, GrepOptions{Pattern: `func \w+`, Mode: ModeCount})
+	if want := []string{path("a.go") + ":2"}; !reflect.DeepEqual(count, want) {
+		t.Errorf("count = %v, want %v", count, want)
+	}
+
+	content, _ := Grep(ctx, root, GrepOptions{Pattern: "alpha", Mode: ModeContent, LineNumbers: true, Type: "go"})
+	if want := []string{path("b/b.go") + ":2:// TODO: alpha"}; !reflect.DeepEqual(content, want) {
+		t.Errorf("content = %v, want %v", content, want)
+	}
+
+	excluded, _ := Grep(ctx, root, GrepOptions{Pattern: "(?i)alpha", Glob: "!*.go"})
+	if want := []string{path("c.txt")}; !reflect.DeepEqual(excluded, want) {
+		t.Errorf("!glob = %v, want %v", excluded, want)
+	}
+
+	skipped, _ := Grep(ctx, root, GrepOptions{Pattern: "alpha", IgnoreCase: true, Skip: func(abs string) bool {
+		return strings.HasSuffix(abs, ".txt")
+	}})
+	if len(skipped) != 2 {
+		t.Errorf("Skip did not hide c.txt: %v", skipped)
+	}
+
+	// A single file is reported without its path.
+	single, _ := Grep(ctx, path("a.go"), GrepOptions{Pattern: "beta", Mode: ModeContent, LineNumbers: true})
+	if want := []string{"4:func beta() {}"}; !reflect.DeepEqual(single, want) {
+		t.Errorf("single file = %v, want %v", single, want)
+	}
+
+	if _, err := Grep(ctx, root, GrepOptions{Pattern: "("}); err == nil {
+		t.Error("want an error for an invalid regex")
+	}
+	if _, err := Grep(ctx, root, GrepOptions{Pattern: "x", Type: "cobol"}); err == nil {
+		t.Error("want an error for an unknown type")
+	}
+}
+
+func TestGrepContext(t *testing.T) {
+	var b strings.Builder
+	for i := 1; i <= 12; i++ {
+		fmt.Fprintf(&b, "line %d\n", i)
+	}
+	root := writeTree(t, map[string]string{"f.txt": b.String()})
+	file := filepath.Join(root, "f.txt")
+
+	got, _ := Grep(context.Background(), file, GrepOptions{
+		Pattern: `^line (2|3|9)$`, Mode: ModeContent, Before: 1, After: 1, LineNumbers: true,
+	})
+	want := []string{"1-line 1", "2:line 2", "3:line 3", "4-line 4", "--", "8-line 8", "9:line 9", "10-line 10"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("context =\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestGrepMultiline(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"s.go": "type T struct {\n\tA int\n}\n\nfunc f() {}\n",
+	})
+	file := filepath.Join(root, "s.go")
+
+	got, _ := Grep(context.Background(), file, GrepOptions{Pattern: `struct \{.*?\}`, Mode: ModeContent, Multiline: true})
+	if want := []string{"type T struct {", "\tA int", "}"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("multiline = %q, want %q", got, want)
+	}
+
+	got, _ = Grep(context.Background(), file, GrepOptions{Pattern: `struct \{.*?\}`, Mode: ModeContent})
+	if len(got) != 0 {
+		t.Errorf("matched across lines without multiline: %q", got)
+	}
+}
+
+func TestGrepOrderIsStableAcrossWorkers(t *testing.T) {
+	files := map[string]string{}
+	for i := range 200 {
+		files[fmt.Sprintf("d%02d/f%03d.txt", i%17, i)] = "needle\n"
+	}
+	root := writeTree(t, files)
+
+	serial, err := Grep(context.Background(), root, GrepOptions{Pattern: "needle", Workers: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parallel, err := Grep(context.Background(), root, GrepOptions{Pattern: "needle", Workers: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(serial) != 200 || !reflect.DeepEqual(serial, parallel) {
+		t.Errorf("serial and parallel results differ (%d vs %d)", len(serial), len(parallel))
+	}
+}
+
+func TestGrepStopsOnCancel(t *testing.T) {
+	root := writeTree(t, map[string]string{"a.txt": "x\n", "b.txt": "x\n"})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Grep(ctx, root, GrepOptions{Pattern: "x"}); err == nil {
+		t.Fatal("want the context error")
+	}
+}
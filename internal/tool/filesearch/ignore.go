@@ -0,0 +1,147 @@
+package filesearch
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ignoreFiles are read in every directory, lowest precedence first: like
+// ripgrep, a .ignore rule overrides a .gitignore rule in the same directory.
+var ignoreFiles = []string{".gitignore", ".ignore"}
+
+// ignoreRule is one line of an ignore file.
+type ignoreRule struct {
+	base    string // directory of the ignore file, relative to the walk's top
+	pattern *Pattern
+	negate  bool // "!pattern": re-include
+	dirOnly bool // "pattern/": directories only
+}
+
+// ignoreRules is the ordered set of rules in effect for one directory; the
+// last matching rule decides.
+type ignoreRules []ignoreRule
+
+// ignored reports whether rel (relative to the walk's top) is ignored.
+func (rules ignoreRules) ignored(rel string, isDir bool) bool {
+	for i := len(rules) - 1; i >= 0; i-- {
+		r := rules[i]
+		sub := rel
+		if r.base != "" {
+			if !strings.HasPrefix(rel, r.base+"/") {
+				continue
+			}
+			sub = rel[len(r.base)+1:]
+		}
+		if r.dirOnly && !isDir {
+			continue
+		}
+		if r.pattern.Match(sub) {
+			return !r.negate
+		}
+	}
+	return false
+}
+
+// with returns rules extended by the ignore files found in dir, whose path
+// relative to the walk's top is rel. rules itself is never modified, so the
+// sets of sibling directories do not share appends.
+func (rules ignoreRules) with(dir, rel string) ignoreRules {
+	out := slices.Clip(rules)
+	for _, name := range ignoreFiles {
+		out = append(out, readIgnoreFile(filepath.Join(dir, name), rel)...)
+	}
+	return out
+}
+
+// readIgnoreFile parses a gitignore-format file. A missing or unreadable
+// file has no rules.
+func readIgnoreFile(file, base string) []ignoreRule {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var rules []ignoreRule
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if r, ok := parseIgnoreLine(sc.Text(), base); ok {
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
+
+func parseIgnoreLine(line, base string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+	r := ignoreRule{base: base}
+	if strings.HasPrefix(line, "!") {
+		r.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+	// A slash anywhere but the end anchors the pattern to the file's
+	// directory; otherwise it matches a name at any depth below it.
+	if strings.Contains(line, "/") && !strings.HasPrefix(line, "/") {
+		line = "/" + line
+	}
+	p, err := CompileGlob(line)
+	if err != nil {
+		return ignoreRule{}, false
+	}
+	r.pattern = p
+	return r, true
+}
+
+// ignoreTop finds the directory ignore rules are resolved from: the root of
+// the git work tree containing root, or root itself outside a repository.
+// It returns root's slash path relative to the top, and the rules of
+// .git/info/exclude and of every ignore file between the top and root.
+func ignoreTop(root string) (prefix string, rules ignoreRules) {
+	top := root
+	for dir := root; ; {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			top = dir
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	rel, err := filepath.Rel(top, root)
+	if err != nil || rel == "." {
+		rel = ""
+	}
+	prefix = filepath.ToSlash(rel)
+
+	rules = readIgnoreFile(filepath.Join(top, ".git", "info", "exclude"), "")
+	if prefix == "" {
+		return prefix, rules
+	}
+	// Ancestors of root inside the work tree, outermost first.
+	dir, base := top, ""
+	for _, seg := range strings.Split(prefix, "/") {
+		rules = rules.with(dir, base)
+		dir = filepath.Join(dir, seg)
+		base = path.Join(base, seg)
+	}
+	return prefix, rules
+}
@@ -0,0 +1,69 @@
+package filesearch
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// fileTypes maps ripgrep's --type names to their globs, for the types a
+// coding agent asks for. Unknown names are an error, as in rg.
+var fileTypes = map[string][]string{
+	"c":          {"*.c", "*.h"},
+	"cpp":        {"*.cc", "*.cpp", "*.cxx", "*.c++", "*.h", "*.hh", "*.hpp", "*.hxx", "*.inl"},
+	"cs":         {"*.cs"},
+	"css":        {"*.css", "*.scss", "*.sass", "*.less"},
+	"csv":        {"*.csv"},
+	"dart":       {"*.dart"},
+	"docker":     {"Dockerfile", "Dockerfile.*", "*.dockerfile"},
+	"elixir":     {"*.ex", "*.exs"},
+	"erlang":     {"*.erl", "*.hrl"},
+	"go":         {"*.go"},
+	"gomod":      {"go.mod", "go.sum", "go.work"},
+	"graphql":    {"*.graphql", "*.graphqls", "*.gql"},
+	"haskell":    {"*.hs", "*.lhs"},
+	"html":       {"*.htm", "*.html", "*.xhtml"},
+	"java":       {"*.java", "*.jsp"},
+	"js":         {"*.js", "*.jsx", "*.mjs", "*.cjs", "*.vue"},
+	"json":       {"*.json", "*.jsonl", "*.geojson"},
+	"kotlin":     {"*.kt", "*.kts"},
+	"lua":        {"*.lua"},
+	"make":       {"Makefile", "makefile", "GNUmakefile", "*.mk", "*.mak"},
+	"markdown":   {"*.markdown", "*.md", "*.mdown", "*.mdx", "*.mkd", "*.mkdn"},
+	"md":         {"*.markdown", "*.md", "*.mdown", "*.mdx", "*.mkd", "*.mkdn"},
+	"php":        {"*.php", "*.php3", "*.php4", "*.php5", "*.phtml"},
+	"proto":      {"*.proto"},
+	"py":         {"*.py", "*.pyi"},
+	"python":     {"*.py", "*.pyi"},
+	"r":          {"*.R", "*.r", "*.Rmd", "*.Rnw"},
+	"rb":         {"*.rb", "*.gemspec", "Gemfile", "Rakefile", "*.rake"},
+	"ruby":       {"*.rb", "*.gemspec", "Gemfile", "Rakefile", "*.rake"},
+	"rust":       {"*.rs"},
+	"scala":      {"*.scala", "*.sbt"},
+	"sh":         {"*.sh", "*.bash", "*.zsh", "*.ksh", ".bashrc", ".zshrc", ".profile"},
+	"sql":        {"*.sql", "*.psql"},
+	"svelte":     {"*.svelte"},
+	"swift":      {"*.swift"},
+	"tf":         {"*.tf", "*.tfvars"},
+	"toml":       {"*.toml", "Cargo.lock"},
+	"ts":         {"*.ts", "*.tsx", "*.cts", "*.mts"},
+	"typescript": {"*.ts", "*.tsx", "*.cts", "*.mts"},
+	"txt":        {"*.txt"},
+	"xml":        {"*.xml", "*.xsd", "*.xsl", "*.xslt", "*.svg"},
+	"yaml":       {"*.yaml", "*.yml"},
+	"zig":        {"*.zig"},
+}
+
+// typePattern compiles the globs of a --type name into one pattern.
+func typePattern(name string) (*Pattern, error) {
+	globs, ok := fileTypes[strings.ToLower(name)]
+	if !ok {
+		known := make([]string, 0, len(fileTypes))
+		for k := range fileTypes {
+			known = append(known, k)
+		}
+		sort.Strings(known)
+		return nil, fmt.Errorf("unrecognized file type %q (known: %s)", name, strings.Join(known, ", "))
+	}
+	return CompileGlob("{" + strings.Join(globs, ",") + "}")
+}
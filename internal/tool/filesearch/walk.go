@@ -0,0 +1,86 @@
+package filesearch
+
+import (
+	"context"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// WalkOptions controls which files Walk visits.
+type WalkOptions struct {
+	Hidden   bool // also visit dot files and dot directories (.git never)
+	NoIgnore bool // do not apply .gitignore, .ignore or .git/info/exclude
+	// SkipDir, when set, prunes directories it reports true for, given the
+	// directory's slash-separated path relative to root.
+	SkipDir func(rel string) bool
+}
+
+// Walk calls fn, in lexical order, for every regular file under the
+// directory root that is not ignored. rel is the file's slash-separated path
+// relative to root. Unreadable directories are skipped; an error from fn or
+// ctx stops the walk and is returned.
+func Walk(ctx context.Context, root string, opts WalkOptions, fn func(rel string) error) error {
+	prefix, rules := "", ignoreRules(nil)
+	if !opts.NoIgnore {
+		prefix, rules = ignoreTop(root)
+	}
+
+	// Rules in effect for each directory visited so far, keyed by its path
+	// relative to root.
+	dirRules := map[string]ignoreRules{}
+
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		rel, relErr := filepath.Rel(root, p)
+		if relErr != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if err != nil {
+			if d != nil && d.IsDir() && rel != "." {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		if rel == "." {
+			if !opts.NoIgnore {
+				dirRules["."] = rules.with(p, prefix)
+			}
+			return nil
+		}
+
+		name := d.Name()
+		if name == ".git" || (!opts.Hidden && strings.HasPrefix(name, ".")) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		if d.IsDir() && opts.SkipDir != nil && opts.SkipDir(rel) {
+			return fs.SkipDir
+		}
+		if !opts.NoIgnore {
+			parent := dirRules[path.Dir(rel)]
+			if parent.ignored(path.Join(prefix, rel), d.IsDir()) {
+				if d.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() {
+				dirRules[rel] = parent.with(p, path.Join(prefix, rel))
+			}
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		return fn(rel)
+	})
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -39,6 +40,10 @@ type FileSystemToolManager struct {
 	// Edit failure tracking (for ToolStateProvider)
 	editFailCounts map[string]int // Consecutive old_string-not-found failures per abs path
 
+	// postEdit, when set, runs after a successful Write/Edit/MultiEdit with the
+	// absolute path of each changed file; its output is appended to the result.
+	postEdit func(ctx context.Context, path string) string
+
 	// Tool registry
 	tools map[message.ToolName]message.Tool
 }
@@ -239,32 +244,39 @@ func (m *FileSystemToolManager) resolvePath(path string) (string, error) {
 // isPathAllowed checks if a file path is within allowed directories
 func (m *FileSystemToolManager) isPathAllowed(path string) error {
 	// Note: allowedDirectories always contains at least the working directory (ensured in constructor)
-
+	allowed := make([]string, 0, len(m.allowedDirectories))
+	for _, dir := range m.allowedDirectories {
+		if dirAbs, err := m.abs(dir); err == nil {
+			allowed = append(allowed, dirAbs)
+		}
+	}
 	// Expect path to already be absolute (resolved by caller)
-	absPath := path
+	return checkPathAllowed(path, allowed)
+}
 
-	// Check if path is within any allowed directory
-	for _, allowedDir := range m.allowedDirectories {
-		allowedAbs, err := m.abs(allowedDir)
-		if err != nil {
-			continue // Skip invalid allowed directory
-		}
+// isFileBlacklisted checks if a file is in the blacklist
+func (m *FileSystemToolManager) isFileBlacklisted(path string) error {
+	return checkBlacklist(path, m.blacklistedFiles)
+}
 
+// checkPathAllowed checks that absPath is one of the absolute directories in
+// allowed or lies under one. Shared with the search tools.
+func checkPathAllowed(absPath string, allowed []string) error {
+	for _, allowedAbs := range allowed {
 		// Check if the file path is under the allowed directory
 		if strings.HasPrefix(absPath, allowedAbs+string(os.PathSeparator)) || absPath == allowedAbs {
 			return nil
 		}
 	}
-
 	return errNotInAllowedDirectory
 }
 
-// isFileBlacklisted checks if a file is in the blacklist
-func (m *FileSystemToolManager) isFileBlacklisted(path string) error {
-	fileName := filepath.Base(path)
-	absPath := path // Expect path to already be absolute (resolved by caller)
+// checkBlacklist checks an absolute path against blacklisted file patterns,
+// matched on the file name and on the full path. Shared with the search tools.
+func checkBlacklist(absPath string, blacklistedFiles []string) error {
+	fileName := filepath.Base(absPath)
 
-	for _, blacklisted := range m.blacklistedFiles {
+	for _, blacklisted := range blacklistedFiles {
 		// Check both filename and full path patterns
 		if matched, _ := filepath.Match(blacklisted, fileName); matched {
 			return fmt.Errorf("file access denied: %s matches blacklisted pattern %s", fileName, blacklisted)
@@ -274,13 +286,50 @@ func (m *FileSystemToolManager) isFileBlacklisted(path string) error {
 		}
 		// Also check for exact matches
 		if fileName == blacklisted || absPath == blacklisted {
-			return fmt.Errorf("file access denied: %s is blacklisted", path)
+			return fmt.Errorf("file access denied: %s is blacklisted", absPath)
 		}
 	}
 
 	return nil
 }
 
+// CheckWritable applies the filesystem tools' access checks to a file another
+// tool (Rename) is about to rewrite: it must lie in an allowed directory and
+// not be blacklisted, both as given and with symlinks resolved.
+func (m *FileSystemToolManager) CheckWritable(path string) error {
+	abs, err := m.abs(path)
+	if err != nil {
+		return err
+	}
+	if err := m.isPathAllowed(abs); err != nil {
+		return fmt.Errorf("%s: %w", abs, err)
+	}
+	if err := m.isFileBlacklisted(abs); err != nil {
+		return err
+	}
+	real, err := filepath.EvalSymlinks(abs)
+	if err != nil || real == abs {
+		return nil
+	}
+	// The allowed directories may sit behind symlinks themselves (/tmp on
+	// macOS), so compare the target against their resolved form.
+	var allowed []string
+	for _, dir := range m.allowedDirectories {
+		dirAbs, err := m.abs(dir)
+		if err != nil {
+			continue
+		}
+		if r, err := filepath.EvalSymlinks(dirAbs); err == nil {
+			dirAbs = r
+		}
+		allowed = append(allowed, dirAbs)
+	}
+	if err := checkPathAllowed(real, allowed); err != nil {
+		return fmt.Errorf("%s links to %s: %w", abs, real, err)
+	}
+	return m.isFileBlacklisted(real)
+}
+
 // validateReadWriteSemantics checks if a write operation is safe based on read timestamps
 func (m *FileSystemToolManager) validateReadWriteSemantics(ctx context.Context, path string) error {
 	m.mu.RLock()
@@ -664,6 +713,28 @@ func (m *FileSystemToolManager) handleRead(ctx context.Context, args message.Too
 	return message.NewToolResultText(b.String()), nil
 }
 
+// SetPostEditHook installs a callback run after every successful
+// Write/Edit/MultiEdit (once per changed file) whose output is appended to the
+// tool result. The LSP subsystem uses it to keep language servers in sync and
+// report the diagnostics an edit introduced.
+func (m *FileSystemToolManager) SetPostEditHook(hook func(ctx context.Context, path string) string) {
+	m.postEdit = hook
+}
+
+// withPostEdit appends the post-edit hook's report for filePath to a
+// successful result.
+func (m *FileSystemToolManager) withPostEdit(ctx context.Context, res message.ToolResult, filePath string) message.ToolResult {
+	if m.postEdit == nil || res.Error != "" {
+		return res
+	}
+	abs, err := m.resolvePath(filePath)
+	if err != nil {
+		return res
+	}
+	res.Text += m.postEdit(ctx, abs)
+	return res
+}
+
 // handleWrite implements Write
 func (m *FileSystemToolManager) handleWrite(ctx context.Context, args message.ToolArgumentValues) (message.ToolResult, error) {
 	pathParam, ok := args["file_path"].(string)
@@ -673,15 +744,18 @@ func (m *FileSystemToolManager) handleWrite(ctx context.Context, args message.To
 	if _, ok := args["content"].(string); !ok {
 		return message.NewToolResultError("content parameter is required"), nil
 	}
-	return m.handleWriteFile(ctx, message.ToolArgumentValues{
+	res, err := m.handleWriteFile(ctx, message.ToolArgumentValues{
 		"path":    pathParam,
 		"content": args["content"],
 	})
+	return m.withPostEdit(ctx, res, pathParam), err
 }
 
 // handleEdit maps to Edit
 func (m *FileSystemToolManager) handleEdit(ctx context.Context, args message.ToolArgumentValues) (message.ToolResult, error) {
-	return m.handleEnhancedEdit(ctx, args)
+	res, err := m.handleEnhancedEdit(ctx, args)
+	filePath, _ := args["file_path"].(string)
+	return m.withPostEdit(ctx, res, filePath), err
 }
 
 // handleLS provides LS with ignore globs
@@ -790,6 +864,7 @@ func (m *FileSystemToolManager) handleMultiEdit(ctx context.Context, args messag
 	}
 
 	var results []string
+	var changed []string // distinct files edited successfully, in order
 	for idx, e := range edits {
 		// Prepare arguments for the existing enhanced edit handler
 		editArgs := message.ToolArgumentValues{
@@ -810,10 +885,17 @@ func (m *FileSystemToolManager) handleMultiEdit(ctx context.Context, args messag
 				line = line[:nl]
 			}
 			results = append(results, fmt.Sprintf("%d) %s: %s", idx+1, e.FilePath, line))
+			if !slices.Contains(changed, e.FilePath) {
+				changed = append(changed, e.FilePath)
+			}
 		}
 	}
 
-	return message.NewToolResultText("MultiEdit results:\n" + strings.Join(results, "\n")), nil
+	res := message.NewToolResultText("MultiEdit results:\n" + strings.Join(results, "\n"))
+	for _, p := range changed {
+		res = m.withPostEdit(ctx, res, p)
+	}
+	return res, nil
 }
 
 // fileSystemTool is a helper struct for filesystem tool registration
@@ -0,0 +1,407 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fpt/klein-cli/internal/lsp"
+	"github.com/fpt/klein-cli/pkg/agent/domain"
+	"github.com/fpt/klein-cli/pkg/message"
+)
+
+const (
+	lspDiagnosticsWait    = 10 * time.Second // Diagnostics tool: a cold server may still be loading
+	lspMaxLocations       = 50
+	lspMaxRenameFileCount = 200
+)
+
+// LSPToolManager exposes language-server queries as tools: Diagnostics,
+// GoToDefinition, FindReferences, Hover and Rename. Positions are given the
+// way the model sees code — a 1-based line plus the symbol's name on it (or a
+// 1-based column) — and converted to LSP's UTF-16 positions here.
+type LSPToolManager struct {
+	tools   map[message.ToolName]message.Tool
+	manager *lsp.Manager
+
+	// editGuards vet every file a Rename would rewrite; see AddEditGuard.
+	editGuards []func(origin, target string) error
+}
+
+// NewLSPToolManager creates the LSP tools over manager.
+func NewLSPToolManager(manager *lsp.Manager) *LSPToolManager {
+	m := &LSPToolManager{
+		tools:   make(map[message.ToolName]message.Tool),
+		manager: manager,
+	}
+	m.register()
+	return m
+}
+
+func (m *LSPToolManager) register() {
+	positionArgs := func(extra ...message.ToolArgument) []message.ToolArgument {
+		return append([]message.ToolArgument{
+			{Name: "file_path", Description: "File containing the symbol", Required: true, Type: "string"},
+			{Name: "line", Description: "1-based line number", Required: true, Type: "number"},
+			{Name: "symbol", Description: "Identifier on that line to query (preferred over column)", Required: false, Type: "string"},
+			{Name: "column", Description: "1-based column, when symbol is ambiguous or omitted", Required: false, Type: "number"},
+		}, extra...)
+	}
+
+	m.RegisterTool("Diagnostics",
+		"Get compiler/type-checker errors and warnings for a file from its language server (gopls, pyright, tsserver). Faster and more precise than running a build through Bash.",
+		[]message.ToolArgument{
+			{Name: "file_path", Description: "File to check", Required: true, Type: "string"},
+		},
+		m.handleDiagnostics)
+
+	m.RegisterTool("GoToDefinition",
+		"Find where the symbol at a position is defined, using the language server (type-aware, follows imports).",
+		positionArgs(), m.handleDefinition)
+
+	m.RegisterTool("FindReferences",
+		"Find every reference to the symbol at a position, using the language server (type-aware: distinguishes same-named symbols).",
+		positionArgs(message.ToolArgument{
+			Name: "include_declaration", Description: "Include the declaration itself (default false)", Required: false, Type: "boolean",
+		}),
+		m.handleReferences)
+
+	m.RegisterTool("Hover",
+		"Show the type, signature and documentation of the symbol at a position, from the language server.",
+		positionArgs(), m.handleHover)
+
+	m.RegisterTool("Rename",
+		"Rename the symbol at a position everywhere it is used, applying the language server's edits to every affected file in the project. Re-read files before editing them afterwards.",
+		positionArgs(message.ToolArgument{
+			Name: "new_name", Description: "New identifier", Required: true, Type: "string",
+		}),
+		m.handleRename)
+}
+
+// AddEditGuard adds a check for the files a Rename would rewrite. It is called
+// with the file the rename started from and each file the language server
+// wants to change, before anything is written; an error refuses the whole
+// rename.
+func (m *LSPToolManager) AddEditGuard(fn func(origin, target string) error) {
+	m.editGuards = append(m.editGuards, fn)
+}
+
+// resolve maps a file_path argument onto an absolute path inside the
+// workspace. Language servers index the workspace root, so a file outside it
+// would be answered from a server that knows nothing of it.
+func (m *LSPToolManager) resolve(args message.ToolArgumentValues) (string, error) {
+	p, _ := args["file_path"].(string)
+	if p == "" {
+		return "", errors.New("file_path parameter is required")
+	}
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(m.manager.Root(), p)
+	}
+	p = filepath.Clean(p)
+	rel, err := filepath.Rel(m.manager.Root(), p)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside the project", p)
+	}
+	if _, err := os.Stat(p); err != nil {
+		return "", err
+	}
+	return p, nil
+}
+
+// position converts line + symbol/column arguments into an LSP position.
+func (m *LSPToolManager) position(path string, args message.ToolArgumentValues) (lsp.Position, error) {
+	line, ok := args["line"].(float64)
+	if !ok || line < 1 {
+		return lsp.Position{}, errors.New("line parameter is required (1-based)")
+	}
+	lines := readFileLines(path)
+	if int(line) > len(lines) {
+		return lsp.Position{}, fmt.Errorf("line %d is past the end of %s (%d lines)", int(line), filepath.Base(path), len(lines))
+	}
+	text := lines[int(line)-1]
+
+	byteCol := -1
+	if sym, _ := args["symbol"].(string); sym != "" {
+		re := regexp.MustCompile(`(^|[^\w])(` + regexp.QuoteMeta(sym) + `)($|[^\w])`)
+		loc := re.FindStringSubmatchIndex(text)
+		if loc == nil {
+			return lsp.Position{}, fmt.Errorf("symbol %q not found on line %d: %s", sym, int(line), strings.TrimSpace(text))
+		}
+		byteCol = loc[4]
+	} else if col, ok := args["column"].(float64); ok && col >= 1 {
+		byteCol = int(col) - 1
+	}
+	if byteCol < 0 {
+		return lsp.Position{}, errors.New("symbol or column parameter is required")
+	}
+	return lsp.Position{Line: int(line) - 1, Character: lsp.UTF16Offset(text, byteCol)}, nil
+}
+
+// client resolves the file and position shared by the navigation tools.
+func (m *LSPToolManager) client(ctx context.Context, args message.ToolArgumentValues) (*lsp.Client, string, lsp.Position, error) {
+	path, err := m.resolve(args)
+	if err != nil {
+		return nil, "", lsp.Position{}, err
+	}
+	pos, err := m.position(path, args)
+	if err != nil {
+		return nil, "", lsp.Position{}, err
+	}
+	c, err := m.manager.ClientFor(ctx, path)
+	if err != nil {
+		return nil, "", lsp.Position{}, err
+	}
+	return c, path, pos, nil
+}
+
+func (m *LSPToolManager) handleDiagnostics(ctx context.Context, args message.ToolArgumentValues) (message.ToolResult, error) {
+	path, err := m.resolve(args)
+	if err != nil {
+		return message.NewToolResultError(err.Error()), nil
+	}
+	c, err := m.manager.ClientFor(ctx, path)
+	if err != nil {
+		return message.NewToolResultError(err.Error()), nil
+	}
+	diags, fresh, err := c.Diagnostics(ctx, path, lspDiagnosticsWait)
+	if err != nil {
+		return message.NewToolResultError(fmt.Sprintf("%s: %v", c.Name(), err)), nil
+	}
+	if !fresh {
+		return message.NewToolResultError(fmt.Sprintf("%s did not report diagnostics within %s; it may still be loading the workspace — try again shortly",
+			c.Name(), lspDiagnosticsWait)), nil
+	}
+	if len(diags) == 0 {
+		return message.NewToolResultText(fmt.Sprintf("No diagnostics for %s (%s).", m.display(path), c.Name())), nil
+	}
+	sorted := lsp.Significant(diags)
+	for _, d := range diags {
+		if d.Severity > lsp.SeverityWarning {
+			sorted = append(sorted, d)
+		}
+	}
+	return message.NewToolResultText(strings.TrimRight(lsp.FormatDiagnostics(m.manager.Root(), path, sorted, nil), "\n")), nil
+}
+
+func (m *LSPToolManager) handleDefinition(ctx context.Context, args message.ToolArgumentValues) (message.ToolResult, error) {
+	c, path, pos, err := m.client(ctx, args)
+	if err != nil {
+		return message.NewToolResultError(err.Error()), nil
+	}
+	locs, err := c.Definition(ctx, path, pos)
+	if err != nil {
+		return message.NewToolResultError(fmt.Sprintf("%s: %v", c.Name(), err)), nil
+	}
+	if len(locs) == 0 {
+		return message.NewToolResultText("No definition found."), nil
+	}
+	return message.NewToolResultText(m.formatLocations(locs)), nil
+}
+
+func (m *LSPToolManager) handleReferences(ctx context.Context, args message.ToolArgumentValues) (message.ToolResult, error) {
+	c, path, pos, err := m.client(ctx, args)
+	if err != nil {
+		return message.NewToolResultError(err.Error()), nil
+	}
+	includeDecl, _ := args["include_declaration"].(bool)
+	locs, err := c.References(ctx, path, pos, includeDecl)
+	if err != nil {
+		return message.NewToolResultError(fmt.Sprintf("%s: %v", c.Name(), err)), nil
+	}
+	if len(locs) == 0 {
+		return message.NewToolResultText("No references found."), nil
+	}
+	return message.NewToolResultText(fmt.Sprintf("%d reference(s):\n%s", len(locs), m.formatLocations(locs))), nil
+}
+
+func (m *LSPToolManager) handleHover(ctx context.Context, args message.ToolArgumentValues) (message.ToolResult, error) {
+	c, path, pos, err := m.client(ctx, args)
+	if err != nil {
+		return message.NewToolResultError(err.Error()), nil
+	}
+	text, err := c.Hover(ctx, path, pos)
+	if err != nil {
+		return message.NewToolResultError(fmt.Sprintf("%s: %v", c.Name(), err)), nil
+	}
+	if text == "" {
+		return message.NewToolResultText("No hover information at that position."), nil
+	}
+	return message.NewToolResultText(text), nil
+}
+
+func (m *LSPToolManager) handleRename(ctx context.Context, args message.ToolArgumentValues) (message.ToolResult, error) {
+	newName, _ := args["new_name"].(string)
+	if strings.TrimSpace(newName) == "" {
+		return message.NewToolResultError("new_name parameter is required"), nil
+	}
+	c, path, pos, err := m.client(ctx, args)
+	if err != nil {
+		return message.NewToolResultError(err.Error()), nil
+	}
+	edit, err := c.Rename(ctx, path, pos, newName)
+	if err != nil {
+		return message.NewToolResultError(fmt.Sprintf("%s: %v", c.Name(), err)), nil
+	}
+	files, err := edit.Files()
+	if err != nil {
+		return message.NewToolResultError(err.Error()), nil
+	}
+	if len(files) == 0 {
+		return message.NewToolResultError("the language server returned no edits for this rename"), nil
+	}
+	if len(files) > lspMaxRenameFileCount {
+		return message.NewToolResultError(fmt.Sprintf("rename touches %d files (limit %d); narrow it or do it in steps", len(files), lspMaxRenameFileCount)), nil
+	}
+
+	// Compute every new file body before writing any, so a bad edit leaves
+	// the tree untouched rather than half renamed.
+	type change struct {
+		path  string
+		text  []byte
+		mode  os.FileMode
+		edits int
+	}
+	var changes []change
+	for uri, edits := range files {
+		p, err := lsp.URIToPath(uri)
+		if err != nil {
+			return message.NewToolResultError(err.Error()), nil
+		}
+		if _, err := m.resolve(message.ToolArgumentValues{"file_path": p}); err != nil {
+			return message.NewToolResultError(fmt.Sprintf("rename would edit %s, which is outside the project; nothing was changed", p)), nil
+		}
+		for _, guard := range m.editGuards {
+			if err := guard(path, p); err != nil {
+				return message.NewToolResultError(fmt.Sprintf("rename would edit %s: %v; nothing was changed", m.display(p), err)), nil
+			}
+		}
+		info, err := os.Stat(p)
+		if err != nil {
+			return message.NewToolResultError(err.Error()), nil
+		}
+		old, err := os.ReadFile(p)
+		if err != nil {
+			return message.NewToolResultError(err.Error()), nil
+		}
+		updated, err := lsp.ApplyEdits(string(old), edits)
+		if err != nil {
+			return message.NewToolResultError(fmt.Sprintf("applying edits to %s: %v; nothing was changed", m.display(p), err)), nil
+		}
+		changes = append(changes, change{path: p, text: []byte(updated), mode: info.Mode().Perm(), edits: len(edits)})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].path < changes[j].path })
+
+	var b strings.Builder
+	total := 0
+	for _, ch := range changes {
+		if err := atomicWriteFile(ch.path, ch.text, ch.mode); err != nil {
+			return message.NewToolResultError(fmt.Sprintf("writing %s: %v (files before it were already renamed)", m.display(ch.path), err)), nil
+		}
+		total += ch.edits
+		fmt.Fprintf(&b, "  %s (%d edit(s))\n", m.display(ch.path), ch.edits)
+	}
+	header := fmt.Sprintf("Renamed to %s: %d edit(s) in %d file(s):\n", newName, total, len(changes))
+	var diags strings.Builder
+	for _, ch := range changes {
+		diags.WriteString(m.manager.AfterEdit(ctx, ch.path))
+	}
+	return message.NewToolResultText(header + strings.TrimRight(b.String(), "\n") + diags.String()), nil
+}
+
+// display renders path relative to the workspace root when it is inside it.
+func (m *LSPToolManager) display(path string) string {
+	if rel, err := filepath.Rel(m.manager.Root(), path); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
+
+// formatLocations renders "path:line:col  source line" entries.
+func (m *LSPToolManager) formatLocations(locs []lsp.Location) string {
+	cache := map[string][]string{}
+	var b strings.Builder
+	for i, l := range locs {
+		if i == lspMaxLocations {
+			fmt.Fprintf(&b, "... %d more\n", len(locs)-i)
+			break
+		}
+		p, err := lsp.URIToPath(l.URI)
+		if err != nil {
+			fmt.Fprintf(&b, "%s:%d\n", l.URI, l.Range.Start.Line+1)
+			continue
+		}
+		lines, ok := cache[p]
+		if !ok {
+			lines = readFileLines(p)
+			cache[p] = lines
+		}
+		text, col := "", l.Range.Start.Character+1
+		if n := l.Range.Start.Line; n < len(lines) {
+			text = strings.TrimSpace(lines[n])
+			col = lsp.ByteOffset(lines[n], l.Range.Start.Character) + 1
+		}
+		fmt.Fprintf(&b, "%s:%d:%d  %s\n", m.display(p), l.Range.Start.Line+1, col, text)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// readFileLines returns the file's lines, or nil when it cannot be read.
+func readFileLines(path string) []string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	return strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+}
+
+// ToolManager interface implementation
+
+func (m *LSPToolManager) GetTool(name message.ToolName) (message.Tool, bool) {
+	t, ok := m.tools[name]
+	return t, ok
+}
+
+func (m *LSPToolManager) GetTools() map[message.ToolName]message.Tool {
+	return m.tools
+}
+
+func (m *LSPToolManager) CallTool(ctx context.Context, name message.ToolName, args message.ToolArgumentValues) (message.ToolResult, error) {
+	t, ok := m.tools[name]
+	if !ok {
+		return message.NewToolResultError(fmt.Sprintf("tool '%s' not found", name)), nil
+	}
+	return t.Handler()(ctx, args)
+}
+
+func (m *LSPToolManager) RegisterTool(name message.ToolName, description message.ToolDescription, arguments []message.ToolArgument, handler func(ctx context.Context, args message.ToolArgumentValues) (message.ToolResult, error)) {
+	m.tools[name] = &lspTool{
+		name:        name,
+		description: description,
+		arguments:   arguments,
+		handler:     handler,
+	}
+}
+
+// lspTool implements message.Tool
+type lspTool struct {
+	name        message.ToolName
+	description message.ToolDescription
+	arguments   []message.ToolArgument
+	handler     func(ctx context.Context, args message.ToolArgumentValues) (message.ToolResult, error)
+}
+
+func (t *lspTool) RawName() message.ToolName            { return t.name }
+func (t *lspTool) Name() message.ToolName               { return t.name }
+func (t *lspTool) Description() message.ToolDescription { return t.description }
+func (t *lspTool) Arguments() []message.ToolArgument    { return t.arguments }
+func (t *lspTool) Handler() func(ctx context.Context, args message.ToolArgumentValues) (message.ToolResult, error) {
+	return t.handler
+}
+
+var _ domain.ToolManager = (*LSPToolManager)(nil)
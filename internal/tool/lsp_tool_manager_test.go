@@ -0,0 +1,228 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/fpt/klein-cli/internal/infra"
+	"github.com/fpt/klein-cli/internal/lsp"
+	"github.com/fpt/klein-cli/internal/lsp/lsptest"
+	"github.com/fpt/klein-cli/internal/repository"
+	"github.com/fpt/klein-cli/pkg/message"
+)
+
+// TestMain lets the test binary double as the fake language server the LSP
+// tests launch (see lsptest.Config).
+func TestMain(m *testing.M) {
+	lsptest.MaybeServe()
+	os.Exit(m.Run())
+}
+
+// The fake server is registered for ".fk" rather than ".go" so the
+// filesystem tool's go vet auto-validation stays out of these tests.
+const fakeSrc = "package main\n\nfunc helper() int { return 1 }\n\nfunc main() {\n\t_ = helper()\n}\n"
+
+func newLSPFixture(t *testing.T) (*LSPToolManager, *lsp.Manager, string) {
+	t.Helper()
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "main.fk"), []byte(fakeSrc), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mgr := lsp.NewManager(root, []lsp.ServerConfig{lsptest.Config(".fk")})
+	t.Cleanup(mgr.Close)
+	return NewLSPToolManager(mgr), mgr, root
+}
+
+func callLSP(t *testing.T, m *LSPToolManager, name string, args message.ToolArgumentValues) message.ToolResult {
+	t.Helper()
+	res, err := m.CallTool(context.Background(), message.ToolName(name), args)
+	if err != nil {
+		t.Fatalf("%s returned transport error: %v", name, err)
+	}
+	return res
+}
+
+func TestLSPTools_Navigation(t *testing.T) {
+	m, _, _ := newLSPFixture(t)
+	at := message.ToolArgumentValues{"file_path": "main.fk", "line": float64(6), "symbol": "helper"}
+
+	res := callLSP(t, m, "GoToDefinition", at)
+	if res.Text != "main.fk:3:6  func helper() int { return 1 }" {
+		t.Errorf("GoToDefinition = %+v", res)
+	}
+
+	res = callLSP(t, m, "FindReferences", at)
+	if res.Text != "1 reference(s):\nmain.fk:6:6  _ = helper()" {
+		t.Errorf("FindReferences = %+v", res)
+	}
+	withDecl := message.ToolArgumentValues{"file_path": "main.fk", "line": float64(6), "column": float64(6), "include_declaration": true}
+	if res = callLSP(t, m, "FindReferences", withDecl); !strings.HasPrefix(res.Text, "2 reference(s):") {
+		t.Errorf("FindReferences with declaration = %+v", res)
+	}
+
+	res = callLSP(t, m, "Hover", at)
+	if !strings.Contains(res.Text, "func helper()") {
+		t.Errorf("Hover = %+v", res)
+	}
+}
+
+func TestLSPTools_ArgumentErrors(t *testing.T) {
+	m, _, _ := newLSPFixture(t)
+	cases := []struct {
+		args message.ToolArgumentValues
+		want string
+	}{
+		{message.ToolArgumentValues{"line": float64(1), "symbol": "x"}, "file_path parameter is required"},
+		{message.ToolArgumentValues{"file_path": "main.fk", "symbol": "x"}, "line parameter is required"},
+		{message.ToolArgumentValues{"file_path": "main.fk", "line": float64(99), "symbol": "x"}, "past the end"},
+		{message.ToolArgumentValues{"file_path": "main.fk", "line": float64(6), "symbol": "nope"}, `symbol "nope" not found on line 6`},
+		{message.ToolArgumentValues{"file_path": "main.fk", "line": float64(6)}, "symbol or column parameter is required"},
+		{message.ToolArgumentValues{"file_path": "../outside.fk", "line": float64(1), "symbol": "x"}, "outside the project"},
+	}
+	for _, tc := range cases {
+		if res := callLSP(t, m, "GoToDefinition", tc.args); !strings.Contains(res.Error, tc.want) {
+			t.Errorf("args %v: error %q, want it to contain %q", tc.args, res.Error, tc.want)
+		}
+	}
+}
+
+func TestLSPTools_DiagnosticsAndRename(t *testing.T) {
+	m, _, root := newLSPFixture(t)
+	path := filepath.Join(root, "main.fk")
+
+	res := callLSP(t, m, "Diagnostics", message.ToolArgumentValues{"file_path": "main.fk"})
+	if res.Text != "No diagnostics for main.fk (fake)." {
+		t.Errorf("clean Diagnostics = %+v", res)
+	}
+	if err := os.WriteFile(path, []byte(fakeSrc+"// TODO\nvar y = BROKEN\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	res = callLSP(t, m, "Diagnostics", message.ToolArgumentValues{"file_path": path})
+	want := "main.fk:9:9: error: undefined: BROKEN [fake]\nmain.fk:8:4: info: todo left in code [fake]"
+	if res.Text != want {
+		t.Errorf("Diagnostics:\n got %q\nwant %q", res.Text, want)
+	}
+	if err := os.WriteFile(path, []byte(fakeSrc), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	res = callLSP(t, m, "Rename", message.ToolArgumentValues{"file_path": "main.fk", "line": float64(3), "symbol": "helper", "new_name": "assist"})
+	if res.Error != "" || !strings.HasPrefix(res.Text, "Renamed to assist: 2 edit(s) in 1 file(s):\n  main.fk (2 edit(s))") {
+		t.Fatalf("Rename = %+v", res)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != strings.ReplaceAll(fakeSrc, "helper", "assist") {
+		t.Errorf("file after rename:\n%s", data)
+	}
+	if res = callLSP(t, m, "Rename", message.ToolArgumentValues{"file_path": "main.fk", "line": float64(3), "symbol": "assist"}); !strings.Contains(res.Error, "new_name") {
+		t.Errorf("Rename without new_name = %+v", res)
+	}
+}
+
+func TestLSPTools_RenameChecksEveryTarget(t *testing.T) {
+	rename := message.ToolArgumentValues{"file_path": "main.fk", "line": float64(3), "symbol": "helper", "new_name": "assist"}
+
+	t.Run("guard refuses the whole rename", func(t *testing.T) {
+		m, _, root := newLSPFixture(t)
+		var seen []string
+		m.AddEditGuard(func(origin, target string) error {
+			seen = append(seen, origin+" -> "+target)
+			return errors.New("denied by permission rule (Edit main.fk)")
+		})
+		res := callLSP(t, m, "Rename", rename)
+		if !strings.Contains(res.Error, "rename would edit main.fk: denied by permission rule") || !strings.Contains(res.Error, "nothing was changed") {
+			t.Fatalf("Rename = %+v", res)
+		}
+		path := filepath.Join(root, "main.fk")
+		if want := []string{path + " -> " + path}; !slices.Equal(seen, want) {
+			t.Errorf("guard saw %q, want %q", seen, want)
+		}
+		if data, _ := os.ReadFile(path); string(data) != fakeSrc {
+			t.Errorf("file changed despite the refusal:\n%s", data)
+		}
+	})
+
+	t.Run("filesystem policy follows symlinks", func(t *testing.T) {
+		m, _, root := newLSPFixture(t)
+		outside := filepath.Join(t.TempDir(), "real.fk")
+		if err := os.WriteFile(outside, []byte(fakeSrc), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(root, "main.fk")
+		if err := os.Remove(path); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(outside, path); err != nil {
+			t.Skipf("symlinks unavailable: %v", err)
+		}
+		fs := NewFileSystemToolManager(infra.NewOSFilesystemRepository(), repository.FileSystemConfig{AllowedDirectories: []string{root}}, root)
+		m.AddEditGuard(func(_, target string) error { return fs.CheckWritable(target) })
+		res := callLSP(t, m, "Rename", rename)
+		if !strings.Contains(res.Error, "links to "+outside) || !strings.Contains(res.Error, "nothing was changed") {
+			t.Fatalf("Rename = %+v", res)
+		}
+		if data, _ := os.ReadFile(outside); string(data) != fakeSrc {
+			t.Errorf("symlink target changed:\n%s", data)
+		}
+	})
+}
+
+func TestFileSystemToolManager_CheckWritable(t *testing.T) {
+	root := t.TempDir()
+	fs := NewFileSystemToolManager(infra.NewOSFilesystemRepository(), repository.FileSystemConfig{
+		AllowedDirectories: []string{root},
+		BlacklistedFiles:   []string{".env"},
+	}, root)
+	if err := fs.CheckWritable("main.go"); err != nil {
+		t.Errorf("main.go: %v", err)
+	}
+	if err := fs.CheckWritable(".env"); err == nil {
+		t.Error(".env: blacklisted file accepted")
+	}
+	if err := fs.CheckWritable(filepath.Join(t.TempDir(), "x.go")); err == nil {
+		t.Error("file outside the allowed directories accepted")
+	}
+	if err := os.WriteFile(filepath.Join(root, ".env"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(root, ".env"), filepath.Join(root, "settings")); err == nil {
+		if err := fs.CheckWritable("settings"); err == nil {
+			t.Error("link to a blacklisted file accepted")
+		}
+	}
+}
+
+func TestFileSystemTools_PostEditDiagnostics(t *testing.T) {
+	_, mgr, root := newLSPFixture(t)
+	fs := NewFileSystemToolManager(infra.NewOSFilesystemRepository(), repository.FileSystemConfig{}, root)
+	fs.SetPostEditHook(mgr.AfterEdit)
+	ctx := context.Background()
+	path := filepath.Join(root, "main.fk")
+
+	res, _ := fs.CallTool(ctx, "Read", message.ToolArgumentValues{"file_path": path})
+	if res.Error != "" {
+		t.Fatalf("Read: %s", res.Error)
+	}
+	res, _ = fs.CallTool(ctx, "Edit", message.ToolArgumentValues{"file_path": path, "old_string": "return 1", "new_string": "return BROKEN"})
+	if !strings.Contains(res.Text, "LSP diagnostics (fake):\nmain.fk:3:28: error: undefined: BROKEN [fake] (new)") {
+		t.Errorf("Edit result lacks the new diagnostic:\n%s", res.Text)
+	}
+
+	res, _ = fs.CallTool(ctx, "MultiEdit", message.ToolArgumentValues{"edits": []any{
+		map[string]any{"file_path": path, "old_string": "return BROKEN", "new_string": "return 2"},
+		map[string]any{"file_path": path, "old_string": "_ = helper()", "new_string": "_ = helper() + 1"},
+	}})
+	if !strings.Contains(res.Text, "all 1 earlier problem(s) in this file are resolved") {
+		t.Errorf("MultiEdit result lacks the resolution note:\n%s", res.Text)
+	}
+
+	res, _ = fs.CallTool(ctx, "Write", message.ToolArgumentValues{"file_path": filepath.Join(root, "notes.txt"), "content": "BROKEN"})
+	if res.Error != "" || strings.Contains(res.Text, "LSP") {
+		t.Errorf("Write of an unhandled file type = %+v", res)
+	}
+}
@@ -5,13 +5,15 @@ import (
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
-	"io"
+	"math"
 	"net/http"
 	"net/url"
 	"sort"
 	"strings"
 	"time"
 
+	"github.com/fpt/klein-cli/internal/researcher/eventstudy"
+	"github.com/fpt/klein-cli/internal/webfetch"
 	"github.com/fpt/klein-cli/pkg/agent/domain"
 	"github.com/fpt/klein-cli/pkg/message"
 )
@@ -22,7 +24,6 @@ import (
 // finance RSS feeds.
 type MarketToolManager struct {
 	tools     map[message.ToolName]message.Tool
-	client    *http.Client
 	newsFeeds []string
 }
 
@@ -47,6 +48,12 @@ var symbolAliases = map[string]string{
 	"nasdaq": "^IXIC", "ナスダック": "^IXIC",
 }
 
+// Caps on each quote, history and feed request.
+const (
+	marketMaxBytes = 5 << 20
+	marketTimeout  = 25 * time.Second
+)
+
 // validRanges limits the history range argument to values Yahoo accepts.
 var validRanges = map[string]bool{
 	"1d": true, "5d": true, "1mo": true, "3mo": true, "6mo": true, "1y": true, "ytd": true, "max": true,
@@ -56,7 +63,6 @@ var validRanges = map[string]bool{
 func NewMarketToolManager() *MarketToolManager {
 	m := &MarketToolManager{
 		tools:     make(map[message.ToolName]message.Tool),
-		client:    &http.Client{Timeout: 25 * time.Second},
 		newsFeeds: defaultNewsFeeds,
 	}
 	m.registerTools()
@@ -125,6 +131,9 @@ func (m *MarketToolManager) handleQuote(ctx context.Context, args message.ToolAr
 	for _, in := range syms {
 		sym := resolveSymbol(in)
 		chart, err := m.fetchChart(ctx, sym, "1d", "1d")
+		if _, refused := webfetch.AsError(err); refused {
+			return fetchError("", err), nil
+		}
 		if err != nil {
 			fmt.Fprintf(&b, "- %s: error: %v\n", sym, err)
 			continue
@@ -170,50 +179,30 @@ func (m *MarketToolManager) handleHistory(ctx context.Context, args message.Tool
 	}
 
 	sym := resolveSymbol(in)
-	chart, err := m.fetchChart(ctx, sym, rng, "1d")
+	meta, rows, err := m.dailyBars(ctx, sym, rng)
 	if err != nil {
-		return message.NewToolResultError(fmt.Sprintf("market history failed for %s: %v", sym, err)), nil
-	}
-	res := chart.Chart.Result[0]
-	if len(res.Indicators.Quote) == 0 {
-		return message.NewToolResultError(fmt.Sprintf("no price series returned for %s", sym)), nil
-	}
-	closes := res.Indicators.Quote[0].Close
-	ts := res.Timestamp
-
-	// Collect valid (non-null) daily closes.
-	type row struct {
-		t time.Time
-		c float64
-	}
-	var rows []row
-	for i := range closes {
-		if closes[i] == nil || i >= len(ts) {
-			continue
-		}
-		rows = append(rows, row{t: time.Unix(ts[i], 0).UTC(), c: *closes[i]})
+		return fetchError("market history failed for "+sym, err), nil
 	}
 	if len(rows) == 0 {
 		return message.NewToolResultText(fmt.Sprintf("No daily closes returned for %s over %s.", sym, rng)), nil
 	}
 
 	first, last := rows[0], rows[len(rows)-1]
-	change := last.c - first.c
+	change := last.close - first.close
 	pct := 0.0
-	if first.c != 0 {
-		pct = change / first.c * 100
+	if first.close != 0 {
+		pct = change / first.close * 100
 	}
-	hi, lo := rows[0].c, rows[0].c
+	hi, lo := rows[0].close, rows[0].close
 	for _, r := range rows {
-		if r.c > hi {
-			hi = r.c
+		if r.close > hi {
+			hi = r.close
 		}
-		if r.c < lo {
-			lo = r.c
+		if r.close < lo {
+			lo = r.close
 		}
 	}
 
-	meta := res.Meta
 	name := meta.ShortName
 	if name == "" {
 		name = meta.LongName
@@ -222,10 +211,10 @@ func (m *MarketToolManager) handleHistory(ctx context.Context, args message.Tool
 	fmt.Fprintf(&b, "%s (%s) — %s\n", name, meta.Symbol, rng)
 	fmt.Fprintf(&b, "Period: %s → %s  close %.2f → %.2f  %s (%s) %s\n",
 		first.t.Format("2006-01-02"), last.t.Format("2006-01-02"),
-		first.c, last.c, signed(change), signedPct(pct), meta.Currency)
+		first.close, last.close, signed(change), signedPct(pct), meta.Currency)
 	fmt.Fprintf(&b, "Period high/low: %.2f / %.2f\n\nDaily closes:\n", hi, lo)
 	for _, r := range rows {
-		fmt.Fprintf(&b, "  %s  %.2f\n", r.t.Format("2006-01-02"), r.c)
+		fmt.Fprintf(&b, "  %s  %.2f\n", r.t.Format("2006-01-02"), r.close)
 	}
 	return message.NewToolResultText(strings.TrimRight(b.String(), "\n")), nil
 }
@@ -242,8 +231,12 @@ func (m *MarketToolManager) handleNews(ctx context.Context, args message.ToolArg
 
 	var items []feedItem
 	var fetchErrs []string
+	var refusal error
 	for _, feed := range m.newsFeeds {
 		fi, err := m.fetchFeed(ctx, feed)
+		if _, refused := webfetch.AsError(err); refused {
+			refusal = err
+		}
 		if err != nil {
 			fetchErrs = append(fetchErrs, fmt.Sprintf("%s: %v", feed, err))
 			continue
@@ -266,6 +259,9 @@ func (m *MarketToolManager) handleNews(ctx context.Context, args message.ToolArg
 	// Most-recent first (best-effort date parse; undated items sink to the end).
 	sort.SliceStable(items, func(i, j int) bool { return items[i].when().After(items[j].when()) })
 
+	if len(items) == 0 && refusal != nil && len(fetchErrs) == len(m.newsFeeds) {
+		return fetchError("", refusal), nil
+	}
 	if len(items) == 0 {
 		msg := "No matching headlines found."
 		if query != "" {
@@ -298,22 +294,15 @@ func (m *MarketToolManager) handleNews(ctx context.Context, args message.ToolArg
 type yahooChart struct {
 	Chart struct {
 		Result []struct {
-			Meta struct {
-				Currency             string  `json:"currency"`
-				Symbol               string  `json:"symbol"`
-				ShortName            string  `json:"shortName"`
-				LongName             string  `json:"longName"`
-				RegularMarketPrice   float64 `json:"regularMarketPrice"`
-				ChartPreviousClose   float64 `json:"chartPreviousClose"`
-				PreviousClose        float64 `json:"previousClose"`
-				RegularMarketDayHigh float64 `json:"regularMarketDayHigh"`
-				RegularMarketDayLow  float64 `json:"regularMarketDayLow"`
-				RegularMarketTime    int64   `json:"regularMarketTime"`
-			} `json:"meta"`
-			Timestamp  []int64 `json:"timestamp"`
+			Meta       yahooMeta `json:"meta"`
+			Timestamp  []int64   `json:"timestamp"`
 			Indicators struct {
 				Quote []struct {
-					Close []*float64 `json:"close"`
+					Open   []*float64 `json:"open"`
+					High   []*float64 `json:"high"`
+					Low    []*float64 `json:"low"`
+					Close  []*float64 `json:"close"`
+					Volume []*float64 `json:"volume"`
 				} `json:"quote"`
 			} `json:"indicators"`
 		} `json:"result"`
@@ -321,28 +310,128 @@ type yahooChart struct {
 	} `json:"chart"`
 }
 
-func (m *MarketToolManager) fetchChart(ctx context.Context, symbol, rng, interval string) (*yahooChart, error) {
-	endpoint := "https://query1.finance.yahoo.com/v8/finance/chart/" + url.PathEscape(symbol)
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+type yahooMeta struct {
+	Currency             string  `json:"currency"`
+	Symbol               string  `json:"symbol"`
+	ShortName            string  `json:"shortName"`
+	LongName             string  `json:"longName"`
+	RegularMarketPrice   float64 `json:"regularMarketPrice"`
+	ChartPreviousClose   float64 `json:"chartPreviousClose"`
+	PreviousClose        float64 `json:"previousClose"`
+	RegularMarketDayHigh float64 `json:"regularMarketDayHigh"`
+	RegularMarketDayLow  float64 `json:"regularMarketDayLow"`
+	RegularMarketTime    int64   `json:"regularMarketTime"`
+	// CurrentTradingPeriod is today's session; its regular hours give the
+	// length of every session, from the daily bars' open timestamps to their
+	// close.
+	CurrentTradingPeriod struct {
+		Regular struct {
+			Start int64 `json:"start"`
+			End   int64 `json:"end"`
+		} `json:"regular"`
+	} `json:"currentTradingPeriod"`
+}
+
+// dailyBar is one trading day. Open, high, low and volume are NaN where
+// Yahoo has no value.
+type dailyBar struct {
+	t                              time.Time
+	open, high, low, close, volume float64
+}
+
+// dailyBars fetches symbol's daily bars over rng, skipping days without a
+// close.
+func (m *MarketToolManager) dailyBars(ctx context.Context, symbol, rng string) (*yahooMeta, []dailyBar, error) {
+	chart, err := m.fetchChart(ctx, symbol, rng, "1d")
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	res := chart.Chart.Result[0]
+	if len(res.Indicators.Quote) == 0 {
+		return &res.Meta, nil, nil
 	}
-	q := req.URL.Query()
-	q.Set("range", rng)
-	q.Set("interval", interval)
-	req.URL.RawQuery = q.Encode()
-	req.Header.Set("User-Agent", "Mozilla/5.0")
-	req.Header.Set("Accept", "application/json")
+	q := res.Indicators.Quote[0]
+	at := func(vs []*float64, i int) float64 {
+		if i < len(vs) && vs[i] != nil {
+			return *vs[i]
+		}
+		return math.NaN()
+	}
+	var bars []dailyBar
+	for i := range q.Close {
+		if q.Close[i] == nil || i >= len(res.Timestamp) {
+			continue
+		}
+		bars = append(bars, dailyBar{
+			t:      time.Unix(res.Timestamp[i], 0).UTC(),
+			open:   at(q.Open, i),
+			high:   at(q.High, i),
+			low:    at(q.Low, i),
+			close:  *q.Close[i],
+			volume: at(q.Volume, i),
+		})
+	}
+	return &res.Meta, bars, nil
+}
 
-	resp, err := m.client.Do(req)
+// marketPrices serves the researcher event study from the same Yahoo
+// endpoint as MarketHistory.
+type marketPrices struct{ m *MarketToolManager }
+
+// chartRanges are the Yahoo ranges DailyBars picks from, shortest first.
+var chartRanges = []struct {
+	days int
+	rng  string
+}{{28, "1mo"}, {89, "3mo"}, {180, "6mo"}, {365, "1y"}, {730, "2y"}, {1826, "5y"}, {3652, "10y"}}
+
+// DailyBars fetches the shortest range that reaches back to from and keeps
+// the bars between from and to.
+func (p marketPrices) DailyBars(ctx context.Context, symbol string, from, to time.Time) ([]eventstudy.Bar, error) {
+	rng := "max"
+	span := int(time.Since(from).Hours() / 24)
+	for _, r := range chartRanges {
+		if span < r.days {
+			rng = r.rng
+			break
+		}
+	}
+	meta, bars, err := p.m.dailyBars(ctx, resolveSymbol(symbol), rng)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-	body, err := io.ReadAll(io.LimitReader(resp.Body, 5<<20))
+	// Daily bars are stamped with the session's open. Early closes make the
+	// regular length an approximation, off by hours at most.
+	regular := meta.CurrentTradingPeriod.Regular
+	session := time.Duration(regular.End-regular.Start) * time.Second
+	var out []eventstudy.Bar
+	for _, b := range bars {
+		if b.t.Before(from) || b.t.After(to) {
+			continue
+		}
+		bar := eventstudy.Bar{Date: b.t, Close: b.close}
+		if session > 0 && session < 24*time.Hour {
+			bar.End = b.t.Add(session)
+		}
+		out = append(out, bar)
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no daily bars for %s", symbol)
+	}
+	return out, nil
+}
+
+func (m *MarketToolManager) fetchChart(ctx context.Context, symbol, rng, interval string) (*yahooChart, error) {
+	q := url.Values{"range": {rng}, "interval": {interval}}
+	resp, err := webfetch.Get(ctx, webfetch.Request{
+		URL:      "https://query1.finance.yahoo.com/v8/finance/chart/" + url.PathEscape(symbol) + "?" + q.Encode(),
+		Header:   http.Header{"User-Agent": {"Mozilla/5.0"}, "Accept": {"application/json"}},
+		MaxBytes: marketMaxBytes,
+		Timeout:  marketTimeout,
+	})
 	if err != nil {
 		return nil, err
 	}
+	body := resp.Body
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("HTTP %d (unknown symbol %q?)", resp.StatusCode, symbol)
 	}
@@ -389,24 +478,19 @@ type feedDoc struct {
 }
 
 func (m *MarketToolManager) fetchFeed(ctx context.Context, feedURL string) ([]feedItem, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feedURL, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("User-Agent", "Mozilla/5.0")
-	resp, err := m.client.Do(req)
+	resp, err := webfetch.Get(ctx, webfetch.Request{
+		URL:      feedURL,
+		Header:   http.Header{"User-Agent": {"Mozilla/5.0"}},
+		MaxBytes: marketMaxBytes,
+		Timeout:  marketTimeout,
+	})
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
 	}
-	body, err := io.ReadAll(io.LimitReader(resp.Body, 5<<20))
-	if err != nil {
-		return nil, err
-	}
-	return parseFeedBytes(body)
+	return parseFeedBytes(resp.Body)
 }
 
 // parseFeedBytes parses RSS 2.0 (items under <channel>) and RSS 1.0/RDF (items
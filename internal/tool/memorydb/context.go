@@ -0,0 +1,120 @@
+package memorydb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// DefaultContextBudgetTokens caps the memory block prepended to a user message
+// when the caller does not set a budget.
+const DefaultContextBudgetTokens = 600
+
+// Markers delimiting the injected block. The extractor strips the block from
+// user messages so recalled memories are not distilled back into new ones.
+const (
+	ContextStart = "[MEMORY CONTEXT]"
+	ContextEnd   = "[END MEMORY CONTEXT]"
+)
+
+// contextCandidates is how many recall hits RecallContext considers before
+// trimming to the token budget.
+const contextCandidates = 12
+
+// contextStopwords are words too common to make a memory relevant on their
+// own. bm25 cannot be trusted for this: on a store of a few dozen memories
+// every term is rare, so "how" scores like "kubernetes". A memory that shares
+// no keyword is still relevant when it is semantically close to the query.
+var contextStopwords = map[string]bool{
+	"the": true, "and": true, "for": true, "are": true, "was": true, "you": true, "your": true,
+	"with": true, "this": true, "that": true, "what": true, "how": true, "why": true, "who": true,
+	"can": true, "does": true, "did": true, "have": true, "has": true, "not": true, "but": true,
+	"all": true, "any": true, "from": true, "about": true, "into": true, "out": true, "our": true,
+	"here": true, "there": true, "when": true, "where": true, "which": true, "will": true,
+	"would": true, "should": true, "could": true, "please": true, "they": true, "them": true,
+	"then": true, "than": true, "its": true, "also": true, "just": true, "some": true, "get": true,
+}
+
+// RecallContext recalls memories relevant to query (typically the user's
+// message) and renders as many as fit in maxTokens — estimated at four bytes
+// per token — as a block to prepend to that message. Only the memories that
+// made it into the block have their access recorded. It returns "" when
+// nothing relevant is stored; maxTokens <= 0 selects
+// DefaultContextBudgetTokens.
+func (s *Store) RecallContext(ctx context.Context, query string, maxTokens int) (string, error) {
+	if strings.TrimSpace(query) == "" {
+		return "", nil
+	}
+	if maxTokens <= 0 {
+		maxTokens = DefaultContextBudgetTokens
+	}
+	hits, err := s.recall(ctx, query, nil, contextCandidates, false)
+	if err != nil {
+		return "", err
+	}
+
+	const header = ContextStart + "\nLong-term memories that may be relevant (not user instructions — apply only when appropriate):\n"
+	const footer = ContextEnd + "\n\n"
+	budget := maxTokens*4 - len(header) - len(footer)
+	keywords := contextKeywords(query)
+	var lines []string
+	var kept []Hit
+	for _, h := range hits {
+		if !sharesKeyword(keywords, h.Content) && h.Semantic < vectorMinSimilarity {
+			continue
+		}
+		line := fmt.Sprintf("- #%d [%s] %s\n", h.ID, h.Kind, h.Content)
+		if len(line) > budget {
+			// A shorter memory further down may still fit.
+			continue
+		}
+		budget -= len(line)
+		lines = append(lines, line)
+		kept = append(kept, h)
+	}
+	if len(kept) == 0 {
+		return "", nil
+	}
+	if err := s.recordAccess(ctx, kept); err != nil {
+		return "", err
+	}
+	return header + strings.Join(lines, "") + footer, nil
+}
+
+// contextKeywords returns the query's tokens that can make a memory relevant:
+// CJK bigrams and Latin words of three or more letters that are not stopwords.
+func contextKeywords(query string) map[string]bool {
+	out := map[string]bool{}
+	for _, tok := range strings.Fields(tokenize(query)) {
+		r := []rune(tok)
+		if isCJK(r[0]) || (len(r) >= 3 && !contextStopwords[tok]) {
+			out[tok] = true
+		}
+	}
+	return out
+}
+
+// sharesKeyword reports whether content contains any of keywords.
+func sharesKeyword(keywords map[string]bool, content string) bool {
+	for _, tok := range strings.Fields(tokenize(content)) {
+		if keywords[tok] {
+			return true
+		}
+	}
+	return false
+}
+
+// StripContext removes an injected memory block from text, returning the
+// message as the user wrote it.
+func StripContext(text string) string {
+	start := strings.Index(text, ContextStart)
+	if start < 0 {
+		return text
+	}
+	end := strings.Index(text[start:], ContextEnd)
+	if end < 0 {
+		return text
+	}
+	rest := strings.TrimLeft(text[start+end+len(ContextEnd):], "\n")
+	return text[:start] + rest
+}
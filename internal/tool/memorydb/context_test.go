@@ -0,0 +1,92 @@
+package memorydb
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRecallContextRendersRelevantMemories(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	deploy := mustRemember(t, s, "Deploys go out through the staging cluster first", 0.7)
+	mustRemember(t, s, "The user prefers tabs over spaces", 0.5)
+
+	block, err := s.RecallContext(ctx, "how do deploys work here?", 0)
+	if err != nil {
+		t.Fatalf("RecallContext: %v", err)
+	}
+	if !strings.HasPrefix(block, ContextStart+"\n") || !strings.HasSuffix(block, ContextEnd+"\n\n") {
+		t.Fatalf("block not delimited by markers:\n%s", block)
+	}
+	if !strings.Contains(block, deploy.Content) {
+		t.Fatalf("block misses the deploy memory:\n%s", block)
+	}
+	if strings.Contains(block, "tabs") {
+		t.Fatalf("block includes an unrelated memory:\n%s", block)
+	}
+
+	st, _ := s.Stat(ctx, deploy.ID)
+	if st.AccessCount != 1 {
+		t.Fatalf("access count = %d, want 1 for an injected memory", st.AccessCount)
+	}
+}
+
+func TestRecallContextEmptyWhenNothingRelevant(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	s := newTestStore(t)
+	mustRemember(t, s, "The user prefers tabs over spaces", 0.5)
+
+	for _, q := range []string{"", "quarterly revenue forecast"} {
+		block, err := s.RecallContext(ctx, q, 0)
+		if err != nil {
+			t.Fatalf("RecallContext(%q): %v", q, err)
+		}
+		if block != "" {
+			t.Fatalf("RecallContext(%q) = %q, want empty", q, block)
+		}
+	}
+}
+
+func TestRecallContextRespectsBudget(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	long := mustRemember(t, s, "kubernetes "+strings.Repeat("detail ", 200), 0.9)
+	short := mustRemember(t, s, "kubernetes runs on three nodes", 0.5)
+
+	block, err := s.RecallContext(ctx, "kubernetes", 60)
+	if err != nil {
+		t.Fatalf("RecallContext: %v", err)
+	}
+	if len(block) > 60*4 {
+		t.Fatalf("block is %d bytes, over the 240-byte budget", len(block))
+	}
+	if !strings.Contains(block, short.Content) {
+		t.Fatalf("short memory should still fit:\n%s", block)
+	}
+	// The memory that did not fit was not used, so its access is not recorded.
+	if st, _ := s.Stat(ctx, long.ID); st.AccessCount != 0 {
+		t.Fatalf("trimmed memory access count = %d, want 0", st.AccessCount)
+	}
+}
+
+func TestStripContext(t *testing.T) {
+	t.Parallel()
+	block := ContextStart + "\n- #1 [fact] x\n" + ContextEnd + "\n\n"
+	cases := map[string]string{
+		block + "hello":                     "hello",
+		"[SESSION LOG: a]\n" + block + "hi": "[SESSION LOG: a]\nhi",
+		"no block here":                     "no block here",
+		ContextStart + " unterminated":      ContextStart + " unterminated",
+	}
+	for in, want := range cases {
+		if got := StripContext(in); got != want {
+			t.Errorf("StripContext(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
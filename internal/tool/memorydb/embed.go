@@ -0,0 +1,223 @@
+package memorydb
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Embedder turns texts into vectors for semantic recall. Vectors are compared
+// by cosine similarity, so their scale does not matter. Model names the vector
+// space: vectors stored under a different model are ignored and re-embedded.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+	Model() string
+}
+
+// HashingEmbedder is a deterministic, offline Embedder: each token of the
+// tokenize()d text is hashed to a signed dimension (the "hashing trick"). It
+// only captures shared words, not paraphrases, so it is meant for tests and as
+// a stand-in where no embedding endpoint is available.
+type HashingEmbedder struct {
+	dims int
+}
+
+// NewHashingEmbedder returns a HashingEmbedder producing dims-dimensional
+// vectors; dims <= 0 selects 256.
+func NewHashingEmbedder(dims int) *HashingEmbedder {
+	if dims <= 0 {
+		dims = 256
+	}
+	return &HashingEmbedder{dims: dims}
+}
+
+// Model implements Embedder.
+func (e *HashingEmbedder) Model() string { return fmt.Sprintf("hashing-%d", e.dims) }
+
+// Embed implements Embedder.
+func (e *HashingEmbedder) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i, text := range texts {
+		v := make([]float32, e.dims)
+		for _, tok := range strings.Fields(tokenize(text)) {
+			h := fnv.New64a()
+			_, _ = h.Write([]byte(tok))
+			sum := h.Sum64()
+			sign := float32(1)
+			if sum>>63 == 1 {
+				sign = -1
+			}
+			v[sum%uint64(e.dims)] += sign
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+// OpenAIEmbedderConfig configures an OpenAIEmbedder.
+type OpenAIEmbedderConfig struct {
+	// BaseURL is the API root the /embeddings path is appended to; empty
+	// selects https://api.openai.com/v1. Any OpenAI-compatible server works
+	// (Ollama: http://localhost:11434/v1).
+	BaseURL string
+	APIKey  string // sent as a bearer token when set
+	Model   string
+	// Dimensions asks models that support it (text-embedding-3-*) for
+	// shortened vectors; 0 keeps the model's native size.
+	Dimensions int
+	HTTPClient *http.Client // nil uses a client with a 30s timeout
+}
+
+// openAIEmbedBatch caps the inputs sent in one /embeddings request.
+const openAIEmbedBatch = 96
+
+// OpenAIEmbedder calls an OpenAI-compatible POST /embeddings endpoint.
+type OpenAIEmbedder struct {
+	cfg OpenAIEmbedderConfig
+}
+
+// NewOpenAIEmbedder returns an Embedder for cfg. cfg.Model is required.
+func NewOpenAIEmbedder(cfg OpenAIEmbedderConfig) (*OpenAIEmbedder, error) {
+	if strings.TrimSpace(cfg.Model) == "" {
+		return nil, fmt.Errorf("embedding model is required")
+	}
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = "https://api.openai.com/v1"
+	}
+	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &OpenAIEmbedder{cfg: cfg}, nil
+}
+
+// Model implements Embedder. The dimension count is part of the name because
+// shortened vectors of one model are not comparable with its full ones.
+func (e *OpenAIEmbedder) Model() string {
+	if e.cfg.Dimensions > 0 {
+		return fmt.Sprintf("%s@%d", e.cfg.Model, e.cfg.Dimensions)
+	}
+	return e.cfg.Model
+}
+
+type openAIEmbedRequest struct {
+	Model      string   `json:"model"`
+	Input      []string `json:"input"`
+	Dimensions int      `json:"dimensions,omitempty"`
+}
+
+type openAIEmbedResponse struct {
+	Data []struct {
+		Embedding []float32 `json:"embedding"`
+		Index     int       `json:"index"`
+	} `json:"data"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// Embed implements Embedder, splitting texts into batches.
+func (e *OpenAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += openAIEmbedBatch {
+		end := min(start+openAIEmbedBatch, len(texts))
+		vecs, err := e.embedBatch(ctx, texts[start:end])
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, vecs...)
+	}
+	return out, nil
+}
+
+func (e *OpenAIEmbedder) embedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	body, err := json.Marshal(openAIEmbedRequest{Model: e.cfg.Model, Input: texts, Dimensions: e.cfg.Dimensions})
+	if err != nil {
+		return nil, fmt.Errorf("encode embeddings request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.cfg.BaseURL+"/embeddings", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("build embeddings request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.cfg.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.cfg.APIKey)
+	}
+	resp, err := e.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("embeddings request: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 64<<20))
+	if err != nil {
+		return nil, fmt.Errorf("read embeddings response: %w", err)
+	}
+
+	var parsed openAIEmbedResponse
+	if jsonErr := json.Unmarshal(data, &parsed); jsonErr != nil && resp.StatusCode == http.StatusOK {
+		return nil, fmt.Errorf("decode embeddings response: %w", jsonErr)
+	}
+	if resp.StatusCode != http.StatusOK {
+		msg := strings.TrimSpace(string(data))
+		if parsed.Error != nil && parsed.Error.Message != "" {
+			msg = parsed.Error.Message
+		}
+		return nil, fmt.Errorf("embeddings request: %s: %s", resp.Status, oneLine(msg, 200))
+	}
+	if len(parsed.Data) != len(texts) {
+		return nil, fmt.Errorf("embeddings response has %d vectors for %d inputs", len(parsed.Data), len(texts))
+	}
+	out := make([][]float32, len(texts))
+	for _, d := range parsed.Data {
+		if d.Index < 0 || d.Index >= len(texts) || out[d.Index] != nil {
+			return nil, fmt.Errorf("embeddings response has a bad index %d", d.Index)
+		}
+		out[d.Index] = d.Embedding
+	}
+	return out, nil
+}
+
+// cosine returns the cosine similarity of a and b, or 0 when their lengths
+// differ or either is all zeros.
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		x, y := float64(a[i]), float64(b[i])
+		dot += x * y
+		na += x * x
+		nb += y * y
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+// encodeVector packs v as little-endian float32s for the vector BLOB column.
+func encodeVector(v []float32) []byte {
+	buf := make([]byte, 4*len(v))
+	for i, f := range v {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(f))
+	}
+	return buf
+}
+
+// decodeVector is the inverse of encodeVector.
+func decodeVector(b []byte) []float32 {
+	v := make([]float32, len(b)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+	}
+	return v
+}
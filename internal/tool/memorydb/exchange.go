@@ -0,0 +1,332 @@
+package memorydb
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ExportedVersion is one version of a memory in the JSONL exchange format. ID
+// is the memory's id in the exporting store; importing assigns new ids and
+// uses these only to re-link merged_into.
+type ExportedVersion struct {
+	CreatedAt    time.Time  `json:"created_at"`
+	LastUsedAt   *time.Time `json:"last_used_at,omitempty"`
+	Kind         string     `json:"kind"`
+	Content      string     `json:"content"`
+	Source       string     `json:"source,omitempty"`
+	Entities     []string   `json:"entities,omitempty"`
+	ID           int64      `json:"id"`
+	Importance   float64    `json:"importance"`
+	UtilityEMA   float64    `json:"utility_ema,omitempty"`
+	UsefulCount  float64    `json:"useful_count,omitempty"`
+	HarmfulCount float64    `json:"harmful_count,omitempty"`
+	AccessCount  int        `json:"access_count,omitempty"`
+}
+
+// ExportedChain is one line of an export: a memory's whole version chain,
+// oldest first, and the state of its newest version. Embeddings are not
+// exported; the importing store computes its own on recall.
+type ExportedChain struct {
+	ArchivedAt *time.Time        `json:"archived_at,omitempty"`
+	State      string            `json:"state"`                 // active, forgotten, archived or merged
+	MergedInto int64             `json:"merged_into,omitempty"` // exporting-store id of the memory it was merged into
+	Versions   []ExportedVersion `json:"versions"`
+}
+
+// Chain states in the exchange format.
+const (
+	StateActive    = "active"
+	StateForgotten = "forgotten"
+	StateArchived  = "archived"
+	StateMerged    = "merged"
+)
+
+// exchangeRow is a memories row with the columns the exchange format needs.
+type exchangeRow struct {
+	memoryRow
+	lastUsed   sql.NullInt64
+	archivedAt sql.NullInt64
+	mergedInto sql.NullInt64
+	access     int
+}
+
+// ExportJSONL writes every memory to w as JSONL, one version chain per line,
+// in the order the chains were started. It returns the number of chains.
+func (s *Store) ExportJSONL(ctx context.Context, w io.Writer) (int, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, kind, content, importance, created_at, useful_count, harmful_count, utility_ema,
+		       source, version, supersedes, superseded_by, forgotten,
+		       last_used_at, archived_at, merged_into, access_count
+		FROM memories ORDER BY id`)
+	if err != nil {
+		return 0, dbErr("export", err)
+	}
+	byID := map[int64]*exchangeRow{}
+	var roots []int64
+	for rows.Next() {
+		r := &exchangeRow{}
+		if err := rows.Scan(&r.id, &r.kind, &r.content, &r.importance, &r.createdAt, &r.usefulCount,
+			&r.harmfulCount, &r.utilityEMA, &r.source, &r.version, &r.supersedes, &r.supersededBy,
+			&r.forgotten, &r.lastUsed, &r.archivedAt, &r.mergedInto, &r.access); err != nil {
+			rows.Close()
+			return 0, dbErr("scan export", err)
+		}
+		byID[r.id] = r
+		if !r.supersedes.Valid {
+			roots = append(roots, r.id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, dbErr("iterate export", err)
+	}
+	entities, err := s.allEntities(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	for _, root := range roots {
+		var chain ExportedChain
+		var head *exchangeRow
+		for r := byID[root]; r != nil; {
+			chain.Versions = append(chain.Versions, r.exported(entities[r.id]))
+			head = r
+			if !r.supersededBy.Valid {
+				break
+			}
+			r = byID[r.supersededBy.Int64]
+		}
+		chain.State, chain.MergedInto = head.state(), head.mergedInto.Int64
+		if head.archivedAt.Valid {
+			t := time.Unix(head.archivedAt.Int64, 0).UTC()
+			chain.ArchivedAt = &t
+		}
+		if err := enc.Encode(chain); err != nil {
+			return 0, fmt.Errorf("write export: %w", err)
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return 0, fmt.Errorf("write export: %w", err)
+	}
+	return len(roots), nil
+}
+
+func (r *exchangeRow) exported(entities []string) ExportedVersion {
+	v := ExportedVersion{
+		ID: r.id, Kind: r.kind, Content: r.content, Source: r.source, Entities: entities,
+		Importance: r.importance, UtilityEMA: r.utilityEMA, UsefulCount: r.usefulCount,
+		HarmfulCount: r.harmfulCount, AccessCount: r.access, CreatedAt: time.Unix(r.createdAt, 0).UTC(),
+	}
+	if r.lastUsed.Valid {
+		t := time.Unix(r.lastUsed.Int64, 0).UTC()
+		v.LastUsedAt = &t
+	}
+	return v
+}
+
+// state names the row's state in the exchange format; the row must be the
+// newest version of its chain.
+func (r *exchangeRow) state() string {
+	switch {
+	case !r.forgotten:
+		return StateActive
+	case r.mergedInto.Valid:
+		return StateMerged
+	case r.archivedAt.Valid:
+		return StateArchived
+	}
+	return StateForgotten
+}
+
+// allEntities loads every memory's entities, keyed by memory id.
+func (s *Store) allEntities(ctx context.Context) (map[int64][]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT memory_id, entity FROM memory_entities ORDER BY memory_id, entity`)
+	if err != nil {
+		return nil, dbErr("export entities", err)
+	}
+	defer rows.Close()
+	out := map[int64][]string{}
+	for rows.Next() {
+		var id int64
+		var e string
+		if err := rows.Scan(&id, &e); err != nil {
+			return nil, dbErr("scan entity", err)
+		}
+		out[id] = append(out[id], e)
+	}
+	return out, dbErr("iterate entities", rows.Err())
+}
+
+// JSONLImportResult summarizes ImportJSONL.
+type JSONLImportResult struct {
+	Chains   int // chains imported
+	Memories int // versions imported
+	Skipped  int // chains already in the store
+}
+
+// ImportJSONL adds the chains in an ExportJSONL stream to the store, keeping
+// their history, usage stats, entities and state. A chain whose newest
+// version is already stored (same content, same creation time) is skipped,
+// so importing the same export twice is harmless. Each chain is imported in
+// its own transaction; on a malformed line, the chains before it stay.
+func (s *Store) ImportJSONL(ctx context.Context, r io.Reader) (JSONLImportResult, error) {
+	var res JSONLImportResult
+	idMap := map[int64]int64{} // exporting-store id → id here
+	type pendingMerge struct{ id, into int64 }
+	var merges []pendingMerge
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 16<<20)
+	line := 0
+	for sc.Scan() {
+		line++
+		text := strings.TrimSpace(sc.Text())
+		if text == "" {
+			continue
+		}
+		var chain ExportedChain
+		if err := json.Unmarshal([]byte(text), &chain); err != nil {
+			return res, fmt.Errorf("line %d: %w", line, err)
+		}
+		if err := chain.validate(); err != nil {
+			return res, fmt.Errorf("line %d: %w", line, err)
+		}
+		head := chain.Versions[len(chain.Versions)-1]
+		existing, err := s.findVersion(ctx, head.Content, head.CreatedAt)
+		if err != nil {
+			return res, err
+		}
+		if existing != 0 {
+			idMap[head.ID] = existing
+			res.Skipped++
+			continue
+		}
+		ids, err := s.importChain(ctx, chain)
+		if err != nil {
+			return res, fmt.Errorf("line %d: %w", line, err)
+		}
+		for i, v := range chain.Versions {
+			idMap[v.ID] = ids[i]
+		}
+		if chain.State == StateMerged && chain.MergedInto != 0 {
+			merges = append(merges, pendingMerge{id: ids[len(ids)-1], into: chain.MergedInto})
+		}
+		res.Chains++
+		res.Memories += len(ids)
+	}
+	if err := sc.Err(); err != nil {
+		return res, fmt.Errorf("read import: %w", err)
+	}
+	// A merge target may come later in the file than the memory merged into it.
+	for _, m := range merges {
+		if into, ok := idMap[m.into]; ok {
+			if _, err := s.db.ExecContext(ctx, `UPDATE memories SET merged_into = ? WHERE id = ?`, into, m.id); err != nil {
+				return res, fmt.Errorf("link merged memory: %w", err)
+			}
+		}
+	}
+	return res, nil
+}
+
+func (c ExportedChain) validate() error {
+	if len(c.Versions) == 0 {
+		return errors.New("chain has no versions")
+	}
+	for _, v := range c.Versions {
+		if strings.TrimSpace(v.Content) == "" {
+			return errors.New("version has no content")
+		}
+	}
+	switch c.State {
+	case StateActive, StateForgotten, StateArchived, StateMerged:
+		return nil
+	}
+	return fmt.Errorf("unknown state %q", c.State)
+}
+
+// findVersion returns the id of a memory with this content and creation time,
+// or 0.
+func (s *Store) findVersion(ctx context.Context, content string, created time.Time) (int64, error) {
+	var id int64
+	err := s.db.QueryRowContext(ctx, `SELECT id FROM memories WHERE content = ? AND created_at = ? LIMIT 1`,
+		strings.TrimSpace(content), created.Unix()).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return id, dbErr("find version", err)
+}
+
+// importChain inserts one chain's versions, linked oldest to newest, and
+// returns their new ids.
+func (s *Store) importChain(ctx context.Context, chain ExportedChain) ([]int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, dbErr("begin tx", err)
+	}
+	defer tx.Rollback() //nolint:errcheck // no-op after a successful Commit
+
+	ids := make([]int64, 0, len(chain.Versions))
+	for i, v := range chain.Versions {
+		kind := strings.TrimSpace(v.Kind)
+		if kind == "" {
+			kind = "fact"
+		}
+		entities := normalizeEntities(v.Entities)
+		content := strings.TrimSpace(v.Content)
+		row := memoryRow{
+			kind: kind, content: content, searchText: tokenize(content + " " + strings.Join(entities, " ")),
+			importance: clamp(v.Importance, 0, 1), createdAt: v.CreatedAt.Unix(), source: v.Source,
+			version: i + 1, usefulCount: v.UsefulCount, harmfulCount: v.HarmfulCount, utilityEMA: v.UtilityEMA,
+		}
+		if i > 0 {
+			row.supersedes = sql.NullInt64{Int64: ids[i-1], Valid: true}
+		}
+		id, err := insertMemory(ctx, tx, row)
+		if err != nil {
+			return nil, err
+		}
+		if err := insertEntities(ctx, tx, id, entities); err != nil {
+			return nil, err
+		}
+		var lastUsed sql.NullInt64
+		if v.LastUsedAt != nil {
+			lastUsed = sql.NullInt64{Int64: v.LastUsedAt.Unix(), Valid: true}
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE memories SET access_count = ?, last_used_at = ? WHERE id = ?`,
+			v.AccessCount, lastUsed, id); err != nil {
+			return nil, fmt.Errorf("import usage: %w", err)
+		}
+		if i > 0 {
+			if _, err := tx.ExecContext(ctx, `UPDATE memories SET superseded_by = ? WHERE id = ?`, id, ids[i-1]); err != nil {
+				return nil, fmt.Errorf("import history: %w", err)
+			}
+		}
+		ids = append(ids, id)
+	}
+
+	head := ids[len(ids)-1]
+	switch chain.State {
+	case StateForgotten, StateMerged:
+		_, err = tx.ExecContext(ctx, `UPDATE memories SET forgotten = 1 WHERE id = ?`, head)
+	case StateArchived:
+		archived := s.now().UTC()
+		if chain.ArchivedAt != nil {
+			archived = *chain.ArchivedAt
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE memories SET forgotten = 1, archived_at = ? WHERE id = ?`,
+			archived.Unix(), head)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("import state: %w", err)
+	}
+	return ids, dbErr("commit", tx.Commit())
+}
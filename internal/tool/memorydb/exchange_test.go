@@ -0,0 +1,128 @@
+package memorydb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportImportRoundTrip(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	src := newTestStore(t)
+
+	deploy, _ := src.Remember(ctx, "Deploys happen on Fridays", "decision", 0.6, []string{"deploy"}, "chat")
+	revised, err := src.Revise(ctx, deploy.ID, "Deploys happen on Thursdays", "", 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := src.Reinforce(ctx, revised.ID, 0.5); err != nil {
+		t.Fatal(err)
+	}
+	gone, _ := src.Remember(ctx, "The wiki lives on Confluence", "fact", 0.5, nil, "")
+	if err := src.Forget(ctx, gone.ID); err != nil {
+		t.Fatal(err)
+	}
+	mustRemember(t, src, "The user likes short answers", 0.5)
+	mustRemember(t, src, "The user likes short answers!", 0.5)
+	mustRemember(t, src, "Lunch is at noon", 0.3)
+	advance(src, DefaultArchiveAfter+time.Hour)
+	mustRecall(t, src, "deploys")
+	mustRecall(t, src, "short answers")
+	res := mustMaintain(t, src, MaintainOptions{UtilityHalfLife: -1})
+	if res.Merged() != 1 || len(res.Archived) != 1 {
+		t.Fatalf("maintenance = %s", res)
+	}
+
+	var buf bytes.Buffer
+	n, err := src.ExportJSONL(ctx, &buf)
+	if err != nil || n != 5 {
+		t.Fatalf("ExportJSONL = %d, %v; want 5 chains", n, err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	var first ExportedChain
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatal(err)
+	}
+	if first.State != StateActive || len(first.Versions) != 2 || first.Versions[0].Source != "chat" ||
+		first.Versions[1].UsefulCount != 0.5 || first.Versions[1].LastUsedAt == nil ||
+		strings.Join(first.Versions[1].Entities, ",") != "deploy" {
+		t.Fatalf("first chain = %s", lines[0])
+	}
+
+	dst := newTestStore(t)
+	imported, err := dst.ImportJSONL(ctx, strings.NewReader(buf.String()))
+	if err != nil {
+		t.Fatalf("ImportJSONL: %v", err)
+	}
+	if imported.Chains != 5 || imported.Memories != 6 || imported.Skipped != 0 {
+		t.Fatalf("import = %+v", imported)
+	}
+	want, _ := src.Stats(ctx)
+	got, _ := dst.Stats(ctx)
+	want.LastMaintained, got.LastMaintained = time.Time{}, time.Time{}
+	if got.Total != want.Total || got.Active != want.Active || got.Superseded != want.Superseded ||
+		got.Forgotten != want.Forgotten || got.Archived != want.Archived || got.Merged != want.Merged ||
+		got.Entities != want.Entities {
+		t.Fatalf("imported stats = %+v, want %+v", got, want)
+	}
+
+	// History, usage and merge links survive with the new ids.
+	hits, _ := dst.Search(ctx, "thursdays", 1)
+	if len(hits) != 1 {
+		t.Fatalf("search after import = %+v", hits)
+	}
+	hist, err := dst.History(ctx, hits[0].ID)
+	if err != nil || len(hist) != 2 || hist[0].Content != deploy.Content || hist[1].Version != 2 {
+		t.Fatalf("history = %+v, %v", hist, err)
+	}
+	st, _ := dst.Stat(ctx, hits[0].ID)
+	if st.UsefulCount != 0.5 || st.AccessCount != 1 {
+		t.Fatalf("usage after import = %+v", st)
+	}
+	keptHits, _ := dst.Search(ctx, "short answers", 5)
+	if len(keptHits) != 1 {
+		t.Fatalf("merged pair after import = %+v", keptHits)
+	}
+	all, _ := dst.List(ctx, true, 0)
+	merged := 0
+	for _, m := range all {
+		if m.MergedInto != 0 {
+			merged++
+			if m.MergedInto != keptHits[0].ID {
+				t.Fatalf("merged into #%d, want #%d", m.MergedInto, keptHits[0].ID)
+			}
+		}
+	}
+	if merged != 1 {
+		t.Fatalf("%d merged memories after import", merged)
+	}
+
+	// Importing the same export again adds nothing.
+	again, err := dst.ImportJSONL(ctx, strings.NewReader(buf.String()))
+	if err != nil || again.Chains != 0 || again.Skipped != 5 {
+		t.Fatalf("re-import = %+v, %v", again, err)
+	}
+}
+
+func TestImportJSONLRejectsBadLines(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	s := newTestStore(t)
+	good := `{"state":"active","versions":[{"id":1,"kind":"fact","content":"kept","importance":0.5,"created_at":"2026-01-02T00:00:00Z"}]}`
+	for _, tc := range []struct{ input, want string }{
+		{good + "\n{not json", "line 2"},
+		{`{"state":"active","versions":[]}`, "no versions"},
+		{`{"state":"lost","versions":[{"content":"x"}]}`, "unknown state"},
+	} {
+		if _, err := s.ImportJSONL(ctx, strings.NewReader(tc.input)); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("ImportJSONL(%q) error = %v, want %q", tc.input, err, tc.want)
+		}
+	}
+	if hits := mustRecall(t, s, "kept"); len(hits) != 1 {
+		t.Fatalf("chains before a bad line should stay imported, got %+v", hits)
+	}
+}
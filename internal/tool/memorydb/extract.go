@@ -0,0 +1,282 @@
+package memorydb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fpt/klein-cli/pkg/agent/domain"
+	"github.com/fpt/klein-cli/pkg/message"
+)
+
+// Extraction limits. The transcript is cut from the front (older turns go
+// first) so the newest context always reaches the model; maxExtractedFacts
+// bounds what one pass may write however chatty the model is.
+const (
+	maxTranscriptBytes   = 24_000
+	maxToolLineRunes     = 200
+	maxExtractedFacts    = 12
+	relatedMemoriesLimit = 15
+	extractSource        = "auto-extract"
+	extractWatermarkKey  = "extract:"
+)
+
+// ExtractResult counts what one extraction pass wrote.
+type ExtractResult struct {
+	Remembered int
+	Revised    int
+	Skipped    int // duplicates and facts the store rejected
+}
+
+// Extractor distils atomic facts from a conversation transcript into the
+// store, in the spirit of the Remember tool but without relying on the model
+// to call it mid-task. Each pass only reads messages newer than the previous
+// pass for the same source, so running it at every compaction and again at
+// session end does not re-read the same turns.
+type Extractor struct {
+	store *Store
+	llm   domain.LLM
+}
+
+// NewExtractor returns an extractor that asks llm to distil transcripts into
+// store. llm is called without tools.
+func NewExtractor(store *Store, llm domain.LLM) *Extractor {
+	return &Extractor{store: store, llm: llm}
+}
+
+// extractedFact is one entry of the model's JSON reply.
+type extractedFact struct {
+	Content    string   `json:"content"`
+	Kind       string   `json:"kind"`
+	Entities   []string `json:"entities"`
+	Importance float64  `json:"importance"`
+	Revises    int64    `json:"revises"`
+}
+
+// Extract distils the messages newer than source's watermark and advances the
+// watermark once they are stored. source names the conversation (a session
+// file or id); messages without a timestamp are treated as new.
+func (e *Extractor) Extract(ctx context.Context, source string, msgs []message.Message) (ExtractResult, error) {
+	key := extractWatermarkKey + source
+	mark, err := e.store.getMeta(ctx, key)
+	if err != nil {
+		return ExtractResult{}, err
+	}
+	var since time.Time
+	if mark != "" {
+		since, _ = time.Parse(time.RFC3339Nano, mark)
+	}
+
+	transcript, userText, newest := renderTranscript(msgs, since)
+	if transcript == "" {
+		return ExtractResult{}, nil
+	}
+
+	related, err := e.store.recall(ctx, userText, nil, relatedMemoriesLimit, false)
+	if err != nil {
+		return ExtractResult{}, err
+	}
+	resp, err := e.llm.Chat(ctx, []message.Message{
+		message.NewChatMessage(message.MessageTypeUser, extractionPrompt(transcript, related)),
+	}, false, nil)
+	if err != nil {
+		return ExtractResult{}, fmt.Errorf("extract memories: %w", err)
+	}
+	if resp == nil {
+		return ExtractResult{}, errors.New("extract memories: empty response")
+	}
+	facts, err := parseExtractedFacts(resp.Content())
+	if err != nil {
+		return ExtractResult{}, err
+	}
+
+	res, err := e.apply(ctx, facts, related)
+	if err != nil {
+		return res, err
+	}
+	if !newest.IsZero() {
+		if err := e.store.setMeta(ctx, key, newest.UTC().Format(time.RFC3339Nano)); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+// apply writes the model's facts: a revision of a memory it was shown becomes
+// Revise, an exact duplicate of an active memory is skipped, and anything else
+// is remembered as new.
+func (e *Extractor) apply(ctx context.Context, facts []extractedFact, related []Hit) (ExtractResult, error) {
+	shown := make(map[int64]bool, len(related))
+	for _, h := range related {
+		shown[h.ID] = true
+	}
+	var res ExtractResult
+	for i, f := range facts {
+		if i >= maxExtractedFacts {
+			res.Skipped += len(facts) - i
+			break
+		}
+		content := strings.TrimSpace(f.Content)
+		if content == "" {
+			res.Skipped++
+			continue
+		}
+		if f.Revises > 0 && shown[f.Revises] {
+			if _, err := e.store.Revise(ctx, f.Revises, content, f.Kind, f.Importance, nilIfEmpty(f.Entities)); err != nil {
+				if ctx.Err() != nil {
+					return res, ctx.Err()
+				}
+				res.Skipped++ // superseded or forgotten since we looked
+				continue
+			}
+			res.Revised++
+			continue
+		}
+		dup, err := e.store.hasActiveContent(ctx, content)
+		if err != nil {
+			return res, err
+		}
+		if dup {
+			res.Skipped++
+			continue
+		}
+		if _, err := e.store.Remember(ctx, content, f.Kind, f.Importance, f.Entities, extractSource); err != nil {
+			return res, err
+		}
+		res.Remembered++
+	}
+	return res, nil
+}
+
+// nilIfEmpty maps an empty entity list to nil, which Revise reads as "inherit".
+func nilIfEmpty(s []string) []string {
+	if len(s) == 0 {
+		return nil
+	}
+	return s
+}
+
+// hasActiveContent reports whether an active memory already says exactly
+// content (ignoring case and surrounding space).
+func (s *Store) hasActiveContent(ctx context.Context, content string) (bool, error) {
+	var n int
+	err := s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM memories
+		WHERE superseded_by IS NULL AND forgotten = 0 AND lower(trim(content)) = lower(?)`,
+		strings.TrimSpace(content)).Scan(&n)
+	return n > 0, dbErr("find duplicate", err)
+}
+
+// renderTranscript renders the conversational messages newer than since as
+// "role: text" lines, returning the transcript, the user turns alone (the
+// related-memory query), and the newest timestamp seen. Injected scaffolding —
+// system prompts, situation hints, compaction summaries and the memory block
+// itself — is left out.
+func renderTranscript(msgs []message.Message, since time.Time) (transcript, userText string, newest time.Time) {
+	var lines, users []string
+	for _, m := range msgs {
+		ts := m.Timestamp()
+		if !since.IsZero() && !ts.IsZero() && !ts.After(since) {
+			continue
+		}
+		if ts.After(newest) {
+			newest = ts
+		}
+		if m.Source() != message.MessageSourceDefault {
+			continue
+		}
+		var line string
+		switch m.Type() {
+		case message.MessageTypeUser:
+			text := strings.TrimSpace(StripContext(m.Content()))
+			if text == "" {
+				continue
+			}
+			users = append(users, text)
+			line = "user: " + text
+		case message.MessageTypeAssistant:
+			text := strings.TrimSpace(m.Content())
+			if text == "" {
+				continue
+			}
+			line = "assistant: " + text
+		case message.MessageTypeToolCall, message.MessageTypeToolResult:
+			text := strings.Join(strings.Fields(m.TruncatedString()), " ")
+			if r := []rune(text); len(r) > maxToolLineRunes {
+				text = string(r[:maxToolLineRunes]) + "…"
+			}
+			line = "tool: " + text
+		default:
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if len(users) == 0 {
+		// Nothing the user said, nothing worth remembering about them.
+		return "", "", newest
+	}
+	transcript = strings.Join(lines, "\n")
+	if len(transcript) > maxTranscriptBytes {
+		cut := len(transcript) - maxTranscriptBytes
+		if nl := strings.IndexByte(transcript[cut:], '\n'); nl >= 0 {
+			cut += nl + 1
+		}
+		transcript = "…\n" + transcript[cut:]
+	}
+	return transcript, strings.Join(users, "\n"), newest
+}
+
+func extractionPrompt(transcript string, related []Hit) string {
+	var known strings.Builder
+	if len(related) == 0 {
+		known.WriteString("(none)\n")
+	}
+	for _, h := range related {
+		fmt.Fprintf(&known, "#%d [%s] %s\n", h.ID, h.Kind, h.Content)
+	}
+	return fmt.Sprintf(`You maintain an assistant's long-term memory. Read the CONVERSATION and extract durable facts worth remembering in future, unrelated conversations: the user's preferences, decisions, constraints, project details, people and systems they work with, and lessons learned.
+
+Rules:
+- One atomic, self-contained fact per entry, written as a short standalone sentence (name the subject; no "he", "it", "this").
+- Only facts stated or confirmed in the conversation. Skip transient chatter, one-off task details, tool output, and anything the assistant merely proposed.
+- Do not repeat an EXISTING MEMORY. If the conversation corrects or updates one, return the new version with "revises" set to its id.
+- Convert relative dates to absolute ones when the conversation makes the date clear.
+- Returning no facts is normal and expected for most conversations.
+
+EXISTING MEMORIES:
+%s
+CONVERSATION:
+%s
+
+Respond with JSON only, no prose:
+{"facts":[{"content":"...","kind":"fact|preference|decision|constraint|project|lesson","importance":0.5,"entities":["..."],"revises":0}]}`,
+		known.String(), transcript)
+}
+
+// parseExtractedFacts reads the model's JSON reply, tolerating a code fence or
+// prose around the object.
+func parseExtractedFacts(text string) ([]extractedFact, error) {
+	start, end := strings.IndexByte(text, '{'), strings.LastIndexByte(text, '}')
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("extract memories: no JSON object in reply %q", oneLine(text, 120))
+	}
+	var reply struct {
+		Facts []extractedFact `json:"facts"`
+	}
+	if err := json.Unmarshal([]byte(text[start:end+1]), &reply); err != nil {
+		return nil, fmt.Errorf("extract memories: parse reply: %w", err)
+	}
+	return reply.Facts, nil
+}
+
+// oneLine flattens s to a single line of at most n runes.
+func oneLine(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-1]) + "…"
+	}
+	return s
+}
@@ -0,0 +1,186 @@
+package memorydb
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/fpt/klein-cli/pkg/message"
+)
+
+// scriptedLLM replies with a fixed text and records the prompts it was sent.
+type scriptedLLM struct {
+	reply   string
+	err     error
+	prompts []string
+}
+
+func (l *scriptedLLM) Chat(
+	_ context.Context, msgs []message.Message, _ bool, _ chan<- string,
+) (message.Message, error) {
+	l.prompts = append(l.prompts, msgs[len(msgs)-1].Content())
+	if l.err != nil {
+		return nil, l.err
+	}
+	return message.NewChatMessage(message.MessageTypeAssistant, l.reply), nil
+}
+
+func (*scriptedLLM) ModelID() string { return "scripted" }
+
+func itoa(n int64) string { return strconv.FormatInt(n, 10) }
+
+func chat(typ message.MessageType, text string) message.Message {
+	return message.NewChatMessage(typ, text)
+}
+
+func TestExtractRemembersAndRevises(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	s := newTestStore(t)
+	old := mustRemember(t, s, "The team deploys on Fridays", 0.5)
+
+	llm := &scriptedLLM{reply: "```json\n" + `{"facts":[
+		{"content":"The team deploys on Thursdays","revises":` + itoa(old.ID) + `},
+		{"content":"The user's name is Sam","kind":"preference","importance":0.8,"entities":["Sam"]},
+		{"content":"the team deploys on thursdays"},
+		{"content":"  "}
+	]}` + "\n```"}
+	msgs := []message.Message{
+		chat(message.MessageTypeSystem, "[[SKILL_PROMPT:code]] you are helpful"),
+		chat(message.MessageTypeUser, ContextStart+"\n- #1 [fact] stale\n"+ContextEnd+"\n\nI'm Sam; we moved deploys to Thursday"),
+		chat(message.MessageTypeAssistant, "Noted, Sam."),
+	}
+
+	res, err := NewExtractor(s, llm).Extract(ctx, "session-a", msgs)
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	if res != (ExtractResult{Remembered: 1, Revised: 1, Skipped: 2}) {
+		t.Fatalf("result = %+v", res)
+	}
+
+	prompt := llm.prompts[0]
+	if !strings.Contains(prompt, "user: I'm Sam; we moved deploys to Thursday") {
+		t.Fatalf("prompt misses the stripped user turn:\n%s", prompt)
+	}
+	if strings.Contains(prompt, "stale") || strings.Contains(prompt, "SKILL_PROMPT") {
+		t.Fatalf("prompt leaks injected scaffolding:\n%s", prompt)
+	}
+	if !strings.Contains(prompt, "#"+itoa(old.ID)+" [fact] The team deploys on Fridays") {
+		t.Fatalf("prompt should list the related memory:\n%s", prompt)
+	}
+
+	hist, err := s.History(ctx, old.ID)
+	if err != nil || len(hist) != 2 || hist[1].Content != "The team deploys on Thursdays" {
+		t.Fatalf("revision chain = %+v, %v", hist, err)
+	}
+	hits := mustRecall(t, s, "Sam")
+	if len(hits) != 1 || hits[0].Kind != "preference" || hits[0].Importance != 0.8 {
+		t.Fatalf("remembered fact = %+v", hits)
+	}
+	// Looking up related memories is not a use of them.
+	if st, _ := s.Stat(ctx, old.ID); st.AccessCount != 0 {
+		t.Fatalf("extractor recorded access on the related memory: %d", st.AccessCount)
+	}
+}
+
+func TestExtractOnlyReadsNewMessages(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	s := newTestStore(t)
+	llm := &scriptedLLM{reply: `{"facts":[]}`}
+	ex := NewExtractor(s, llm)
+
+	msgs := []message.Message{
+		chat(message.MessageTypeUser, "first question about postgres"),
+		chat(message.MessageTypeAssistant, "first answer"),
+	}
+	if _, err := ex.Extract(ctx, "s", msgs); err != nil {
+		t.Fatalf("first Extract: %v", err)
+	}
+	// Nothing new: the model is not called again.
+	if _, err := ex.Extract(ctx, "s", msgs); err != nil {
+		t.Fatalf("repeat Extract: %v", err)
+	}
+	if len(llm.prompts) != 1 {
+		t.Fatalf("model called %d times, want 1", len(llm.prompts))
+	}
+
+	msgs = append(msgs, chat(message.MessageTypeUser, "second question about redis"))
+	if _, err := ex.Extract(ctx, "s", msgs); err != nil {
+		t.Fatalf("second Extract: %v", err)
+	}
+	if len(llm.prompts) != 2 {
+		t.Fatalf("model called %d times, want 2", len(llm.prompts))
+	}
+	if p := llm.prompts[1]; strings.Contains(p, "postgres") || !strings.Contains(p, "redis") {
+		t.Fatalf("second pass should read only the new turn:\n%s", p)
+	}
+
+	// Watermarks are per source.
+	if _, err := ex.Extract(ctx, "other", msgs); err != nil {
+		t.Fatalf("other-source Extract: %v", err)
+	}
+	if p := llm.prompts[2]; !strings.Contains(p, "postgres") {
+		t.Fatalf("a new source should read the whole transcript:\n%s", p)
+	}
+}
+
+func TestExtractKeepsWatermarkOnFailure(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	s := newTestStore(t)
+	msgs := []message.Message{chat(message.MessageTypeUser, "remember that I use vim")}
+
+	failing := &scriptedLLM{err: errors.New("overloaded")}
+	if _, err := NewExtractor(s, failing).Extract(ctx, "s", msgs); err == nil {
+		t.Fatal("Extract with a failing model should error")
+	}
+	garbled := &scriptedLLM{reply: "I could not find anything."}
+	if _, err := NewExtractor(s, garbled).Extract(ctx, "s", msgs); err == nil {
+		t.Fatal("Extract with a non-JSON reply should error")
+	}
+
+	ok := &scriptedLLM{reply: `{"facts":[{"content":"The user edits with vim"}]}`}
+	res, err := NewExtractor(s, ok).Extract(ctx, "s", msgs)
+	if err != nil || res.Remembered != 1 {
+		t.Fatalf("retry after failures: %+v, %v", res, err)
+	}
+}
+
+func TestExtractSkipsTranscriptWithoutUserTurns(t *testing.T) {
+	t.Parallel()
+	s := newTestStore(t)
+	llm := &scriptedLLM{reply: `{"facts":[]}`}
+	msgs := []message.Message{
+		chat(message.MessageTypeSystem, "system prompt"),
+		message.NewSummarySystemMessage("summary of earlier turns"),
+	}
+	if _, err := NewExtractor(s, llm).Extract(context.Background(), "s", msgs); err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	if len(llm.prompts) != 0 {
+		t.Fatalf("model called without any user turn")
+	}
+}
+
+func TestExtractCapsFactsPerPass(t *testing.T) {
+	t.Parallel()
+	s := newTestStore(t)
+	var facts []string
+	for i := range maxExtractedFacts + 3 {
+		facts = append(facts, `{"content":"fact number `+itoa(int64(i))+`"}`)
+	}
+	llm := &scriptedLLM{reply: `{"facts":[` + strings.Join(facts, ",") + `]}`}
+	msgs := []message.Message{chat(message.MessageTypeUser, "lots of facts")}
+
+	res, err := NewExtractor(s, llm).Extract(context.Background(), "s", msgs)
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	if res.Remembered != maxExtractedFacts || res.Skipped != 3 {
+		t.Fatalf("result = %+v, want %d remembered and 3 skipped", res, maxExtractedFacts)
+	}
+}
@@ -0,0 +1,183 @@
+package memorydb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// markdownImportKey records that the legacy Markdown memory has been imported.
+const markdownImportKey = "import:markdown"
+
+// Importance given to imported entries: MEMORY.md held curated long-term
+// facts, daily notes held observations of the day.
+const (
+	importedMemoryImportance = 0.6
+	importedNoteImportance   = 0.3
+)
+
+// ImportResult summarizes a Markdown import.
+type ImportResult struct {
+	Files    int
+	Imported int
+	Skipped  int  // duplicates of memories already stored
+	Already  bool // the import ran before; nothing was read
+}
+
+// ImportMarkdown migrates the file-based memory the gateway used to inject —
+// dir/MEMORY.md and dir/daily/YYYY-MM-DD.md — into the store, once. Each
+// bullet item or paragraph becomes one memory; the nearest heading is kept as
+// an entity, and a daily note's entries keep the note's date so recency
+// ranking treats them as old. The files are left in place. Later calls return
+// Already without reading anything, so callers can run it on every start.
+func (s *Store) ImportMarkdown(ctx context.Context, dir string) (ImportResult, error) {
+	done, err := s.getMeta(ctx, markdownImportKey)
+	if err != nil {
+		return ImportResult{}, err
+	}
+	if done != "" {
+		return ImportResult{Already: true}, nil
+	}
+
+	var res ImportResult
+	if err := s.importMarkdownFile(ctx, filepath.Join(dir, "MEMORY.md"), "MEMORY.md",
+		importedMemoryImportance, time.Time{}, &res); err != nil {
+		return res, err
+	}
+	notes, err := filepath.Glob(filepath.Join(dir, "daily", "*.md"))
+	if err != nil {
+		return res, fmt.Errorf("list daily notes: %w", err)
+	}
+	sort.Strings(notes)
+	for _, path := range notes {
+		name := filepath.Base(path)
+		day, err := time.ParseInLocation("2006-01-02", strings.TrimSuffix(name, ".md"), time.Local)
+		if err != nil {
+			continue // not a daily note
+		}
+		if err := s.importMarkdownFile(ctx, path, "daily/"+name, importedNoteImportance, day, &res); err != nil {
+			return res, err
+		}
+	}
+	return res, s.setMeta(ctx, markdownImportKey, s.now().UTC().Format(time.RFC3339))
+}
+
+// importMarkdownFile imports one file's entries. A zero day means "now"; a
+// daily note also tags its entries with the date.
+func (s *Store) importMarkdownFile(
+	ctx context.Context, path, rel string, importance float64, day time.Time, res *ImportResult,
+) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read %s: %w", rel, err)
+	}
+	res.Files++
+	at := s.now()
+	if !day.IsZero() {
+		at = day
+	}
+	for _, e := range markdownEntries(string(data)) {
+		entities := e.entities
+		if !day.IsZero() {
+			entities = append(entities, day.Format("2006-01-02"))
+		}
+		dup, err := s.hasActiveContent(ctx, e.text)
+		if err != nil {
+			return err
+		}
+		if dup {
+			res.Skipped++
+			continue
+		}
+		if _, err := s.rememberAt(ctx, at, e.text, "fact", importance, entities, "import:"+rel); err != nil {
+			return err
+		}
+		res.Imported++
+	}
+	return nil
+}
+
+// markdownEntry is one importable unit of a Markdown file.
+type markdownEntry struct {
+	text     string
+	entities []string
+}
+
+// markdownEntries splits free-form Markdown into atomic-ish entries: each
+// list item (with its indented continuation lines) and each paragraph is one
+// entry. Headings are not entries themselves but tag the entries beneath
+// them; front matter, code fences and horizontal rules are skipped.
+func markdownEntries(src string) []markdownEntry {
+	var out []markdownEntry
+	var heading string
+	var cur []string
+	flush := func() {
+		text := strings.TrimSpace(strings.Join(cur, " "))
+		cur = cur[:0]
+		if len([]rune(text)) < 3 {
+			return
+		}
+		var entities []string
+		if heading != "" {
+			entities = []string{heading}
+		}
+		out = append(out, markdownEntry{text: text, entities: entities})
+	}
+
+	lines := strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+	inFence, inFrontMatter := false, len(lines) > 0 && strings.TrimSpace(lines[0]) == "---"
+	for i, raw := range lines {
+		line := strings.TrimSpace(raw)
+		switch {
+		case inFrontMatter:
+			if i > 0 && line == "---" {
+				inFrontMatter = false
+			}
+			continue
+		case strings.HasPrefix(line, "```"):
+			flush()
+			inFence = !inFence
+			continue
+		case inFence:
+			continue
+		case line == "" || line == "---" || line == "***":
+			flush()
+		case strings.HasPrefix(line, "#"):
+			flush()
+			heading = strings.ToLower(strings.TrimSpace(strings.TrimLeft(line, "#")))
+		default:
+			if item, ok := listItem(line); ok {
+				flush()
+				line = item
+			}
+			cur = append(cur, line)
+		}
+	}
+	flush()
+	return out
+}
+
+// listItem strips a bullet or ordered-list marker, reporting whether line
+// started a list item.
+func listItem(line string) (string, bool) {
+	for _, p := range []string{"- [ ] ", "- [x] ", "- ", "* ", "+ "} {
+		if rest, ok := strings.CutPrefix(line, p); ok {
+			return strings.TrimSpace(rest), true
+		}
+	}
+	if dot := strings.IndexAny(line, ".)"); dot > 0 && dot <= 3 {
+		if _, err := strconv.Atoi(line[:dot]); err == nil && len(line) > dot+1 && line[dot+1] == ' ' {
+			return strings.TrimSpace(line[dot+1:]), true
+		}
+	}
+	return line, false
+}
@@ -0,0 +1,141 @@
+package memorydb
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestImportMarkdownOnce(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	s := newTestStore(t)
+	dir := t.TempDir()
+
+	writeFile(t, filepath.Join(dir, "MEMORY.md"), `# Memory
+
+## User
+- Name: Sam, based in Osaka
+- Prefers replies in Japanese
+  for anything work-related
+
+## Projects
+The klein project is written in Go.
+It targets Linux and macOS.
+
+`+"```"+`
+code blocks are not memories
+`+"```"+`
+`)
+	writeFile(t, filepath.Join(dir, "daily", "2026-03-01.md"), "- Discussed the Q1 roadmap\n1. Shipped v0.4\n")
+	writeFile(t, filepath.Join(dir, "daily", "notes.md"), "- not a dated note\n")
+	writeFile(t, filepath.Join(dir, "runs", "2026-03-01.md"), "- run logs are not imported\n")
+
+	res, err := s.ImportMarkdown(ctx, dir)
+	if err != nil {
+		t.Fatalf("ImportMarkdown: %v", err)
+	}
+	if res.Files != 2 || res.Imported != 5 || res.Already {
+		t.Fatalf("result = %+v, want 2 files / 5 imported", res)
+	}
+
+	hits := mustRecall(t, s, "Japanese replies")
+	if len(hits) == 0 || hits[0].Content != "Prefers replies in Japanese for anything work-related" {
+		t.Fatalf("continuation line not joined: %+v", hits)
+	}
+	got, _ := s.Get(ctx, hits[0].ID)
+	if len(got.Entities) != 1 || got.Entities[0] != "user" {
+		t.Fatalf("entities = %v, want the heading", got.Entities)
+	}
+
+	roadmap := mustRecall(t, s, "roadmap")
+	if len(roadmap) != 1 {
+		t.Fatalf("daily note entry missing: %+v", roadmap)
+	}
+	note, _ := s.Get(ctx, roadmap[0].ID)
+	want := time.Date(2026, 3, 1, 0, 0, 0, 0, time.Local).UTC()
+	if !note.CreatedAt.Equal(want) || note.Importance != importedNoteImportance {
+		t.Fatalf("daily entry = %+v, want created %v with note importance", note, want)
+	}
+	if len(note.Entities) != 1 || note.Entities[0] != "2026-03-01" {
+		t.Fatalf("daily entry entities = %v", note.Entities)
+	}
+	for _, q := range []string{"code blocks", "dated note", "run logs"} {
+		if h := mustRecall(t, s, q); len(h) != 0 {
+			t.Fatalf("%q should not have been imported: %+v", q, h)
+		}
+	}
+
+	// Edits after the import are not re-read.
+	writeFile(t, filepath.Join(dir, "MEMORY.md"), "- brand new fact\n")
+	again, err := s.ImportMarkdown(ctx, dir)
+	if err != nil || !again.Already || again.Imported != 0 {
+		t.Fatalf("second import = %+v, %v", again, err)
+	}
+	if active, _, _ := s.Count(ctx); active != 5 {
+		t.Fatalf("active = %d, want 5", active)
+	}
+}
+
+func TestImportMarkdownMissingFilesStillMarksDone(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	s := newTestStore(t)
+	dir := t.TempDir()
+
+	res, err := s.ImportMarkdown(ctx, dir)
+	if err != nil || res.Files != 0 || res.Already {
+		t.Fatalf("first import = %+v, %v", res, err)
+	}
+	writeFile(t, filepath.Join(dir, "MEMORY.md"), "- written later\n")
+	if res, _ := s.ImportMarkdown(ctx, dir); !res.Already {
+		t.Fatalf("import should run once per database, got %+v", res)
+	}
+}
+
+func TestImportMarkdownSkipsDuplicates(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	s := newTestStore(t)
+	mustRemember(t, s, "Prefers dark mode", 0.5)
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "MEMORY.md"), "- prefers dark mode\n- Uses zsh\n")
+
+	res, err := s.ImportMarkdown(ctx, dir)
+	if err != nil {
+		t.Fatalf("ImportMarkdown: %v", err)
+	}
+	if res.Imported != 1 || res.Skipped != 1 {
+		t.Fatalf("result = %+v, want 1 imported / 1 skipped", res)
+	}
+}
+
+func TestMarkdownEntriesFrontMatterAndLists(t *testing.T) {
+	t.Parallel()
+	entries := markdownEntries("---\nname: x\n---\n# Notes\n- [ ] open task\n2) second\n12. twelfth\n---\nplain")
+	var texts []string
+	for _, e := range entries {
+		texts = append(texts, e.text)
+	}
+	want := []string{"open task", "second", "twelfth", "plain"}
+	if len(texts) != len(want) {
+		t.Fatalf("entries = %q, want %q", texts, want)
+	}
+	for i := range want {
+		if texts[i] != want[i] {
+			t.Fatalf("entries = %q, want %q", texts, want)
+		}
+	}
+}
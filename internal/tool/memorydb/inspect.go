@@ -12,10 +12,12 @@ import (
 // (the REPL /memory command), not the model-facing tools.
 type MemoryStat struct {
 	LastUsedAt time.Time
+	ArchivedAt time.Time // set while maintenance has the memory archived
 	Memory
 	AccessCount  int
 	UsefulCount  float64
 	HarmfulCount float64
+	MergedInto   int64 // the memory this near-duplicate was folded into, or 0
 	Active       bool
 }
 
@@ -32,7 +34,7 @@ func (s *Store) List(ctx context.Context, includeInactive bool, limit int) ([]Me
 	}
 	rows, err := s.db.QueryContext(ctx, `
 		SELECT id, kind, content, importance, version, utility_ema, created_at,
-		       access_count, useful_count, harmful_count, last_used_at,
+		       access_count, useful_count, harmful_count, last_used_at, archived_at, merged_into,
 		       (superseded_by IS NULL AND forgotten = 0) AS active
 		FROM memories
 		`+where+`
@@ -58,7 +60,7 @@ func (s *Store) List(ctx context.Context, includeInactive bool, limit int) ([]Me
 func (s *Store) Stat(ctx context.Context, id int64) (MemoryStat, error) {
 	rows, err := s.db.QueryContext(ctx, `
 		SELECT id, kind, content, importance, version, utility_ema, created_at,
-		       access_count, useful_count, harmful_count, last_used_at,
+		       access_count, useful_count, harmful_count, last_used_at, archived_at, merged_into,
 		       (superseded_by IS NULL AND forgotten = 0) AS active
 		FROM memories WHERE id = ?`, id)
 	if err != nil {
@@ -77,18 +79,25 @@ func (s *Store) Stat(ctx context.Context, id int64) (MemoryStat, error) {
 // scanStat scans one MemoryStat row (shared by List and Stat).
 func scanStat(rows *sql.Rows) (MemoryStat, error) {
 	var (
-		st       MemoryStat
-		created  int64
-		lastUsed sql.NullInt64
+		st         MemoryStat
+		created    int64
+		lastUsed   sql.NullInt64
+		archived   sql.NullInt64
+		mergedInto sql.NullInt64
 	)
 	if err := rows.Scan(&st.ID, &st.Kind, &st.Content, &st.Importance, &st.Version, &st.UtilityEMA,
-		&created, &st.AccessCount, &st.UsefulCount, &st.HarmfulCount, &lastUsed, &st.Active); err != nil {
+		&created, &st.AccessCount, &st.UsefulCount, &st.HarmfulCount, &lastUsed, &archived, &mergedInto,
+		&st.Active); err != nil {
 		return MemoryStat{}, dbErr("scan stat", err)
 	}
 	st.CreatedAt = time.Unix(created, 0).UTC()
 	if lastUsed.Valid {
 		st.LastUsedAt = time.Unix(lastUsed.Int64, 0).UTC()
 	}
+	if archived.Valid {
+		st.ArchivedAt = time.Unix(archived.Int64, 0).UTC()
+	}
+	st.MergedInto = mergedInto.Int64
 	return st, nil
 }
 
@@ -107,3 +116,77 @@ func (s *Store) Count(ctx context.Context) (active, total int, err error) {
 	}
 	return active, total, nil
 }
+
+// Search is Recall without recording access, for inspection surfaces where
+// looking a memory up is not a use of it (and must not keep it from being
+// archived).
+func (s *Store) Search(ctx context.Context, query string, limit int) ([]Hit, error) {
+	return s.recall(ctx, query, nil, limit, false)
+}
+
+// StoreStats summarizes the store for `klein memory stats`.
+type StoreStats struct {
+	LastMaintained time.Time
+	ByKind         map[string]int // active memories per kind
+	Vectors        map[string]int // stored embeddings per model
+	Total          int
+	Active         int
+	Superseded     int // older versions of revised memories
+	Forgotten      int
+	Archived       int
+	Merged         int
+	Entities       int // distinct entities on active memories
+}
+
+// Stats counts memories by state and kind, entities, and stored embeddings.
+func (s *Store) Stats(ctx context.Context) (StoreStats, error) {
+	st := StoreStats{ByKind: map[string]int{}, Vectors: map[string]int{}}
+	err := s.db.QueryRowContext(ctx, `
+		SELECT
+			COUNT(*),
+			COUNT(*) FILTER (WHERE superseded_by IS NULL AND forgotten = 0),
+			COUNT(*) FILTER (WHERE superseded_by IS NOT NULL),
+			COUNT(*) FILTER (WHERE superseded_by IS NULL AND forgotten = 1
+			                   AND archived_at IS NULL AND merged_into IS NULL),
+			COUNT(*) FILTER (WHERE superseded_by IS NULL AND archived_at IS NOT NULL),
+			COUNT(*) FILTER (WHERE superseded_by IS NULL AND merged_into IS NOT NULL)
+		FROM memories`).Scan(&st.Total, &st.Active, &st.Superseded, &st.Forgotten, &st.Archived, &st.Merged)
+	if err != nil {
+		return st, dbErr("count states", err)
+	}
+	if err := s.countInto(ctx, st.ByKind, `
+		SELECT kind, COUNT(*) FROM memories
+		WHERE superseded_by IS NULL AND forgotten = 0 GROUP BY kind`); err != nil {
+		return st, err
+	}
+	if err := s.countInto(ctx, st.Vectors, `SELECT model, COUNT(*) FROM memory_vectors GROUP BY model`); err != nil {
+		return st, err
+	}
+	if err := s.db.QueryRowContext(ctx, `
+		SELECT COUNT(DISTINCT e.entity) FROM memory_entities e
+		JOIN memories m ON m.id = e.memory_id
+		WHERE m.superseded_by IS NULL AND m.forgotten = 0`).Scan(&st.Entities); err != nil {
+		return st, dbErr("count entities", err)
+	}
+	last, err := s.LastMaintained(ctx)
+	st.LastMaintained = last
+	return st, err
+}
+
+// countInto runs a (name, count) grouping query into dst.
+func (s *Store) countInto(ctx context.Context, dst map[string]int, query string) error {
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return dbErr("count", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		var n int
+		if err := rows.Scan(&name, &n); err != nil {
+			return dbErr("scan count", err)
+		}
+		dst[name] = n
+	}
+	return dbErr("iterate counts", rows.Err())
+}
@@ -0,0 +1,474 @@
+package memorydb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Maintenance defaults (MaintainOptions zero values).
+const (
+	DefaultUtilityHalfLife     = 90 * 24 * time.Hour
+	DefaultDuplicateSimilarity = 0.8
+	DefaultArchiveAfter        = 180 * 24 * time.Hour
+)
+
+const (
+	// maintainedKey records when Maintain last ran. Decay is applied for the
+	// time since then, so running it hourly or monthly fades utility alike.
+	maintainedKey = "maintain:last_run"
+	// duplicateMinCosine vetoes a lexical near-duplicate whose embeddings
+	// disagree, when both memories have a vector for the current model.
+	duplicateMinCosine = 0.9
+	// archiveKeepImportance exempts memories stored as near-critical from
+	// archiving, however long they go unrecalled.
+	archiveKeepImportance = 0.9
+	// utilityFloor is the |utility| below which decay snaps it to zero.
+	utilityFloor = 0.005
+)
+
+// MaintainOptions tunes a maintenance pass. A zero field selects its default;
+// a negative one turns that step off.
+type MaintainOptions struct {
+	// UtilityHalfLife is how long a memory's learned utility takes to fade
+	// halfway to zero while the memory goes unused.
+	UtilityHalfLife time.Duration
+	// DuplicateSimilarity is the word-set (Jaccard) similarity at which two
+	// memories of the same kind are merged.
+	DuplicateSimilarity float64
+	// ArchiveAfter archives memories not recalled (or created) for this long.
+	ArchiveAfter time.Duration
+	// DryRun reports what would change without writing anything.
+	DryRun bool
+}
+
+// Merge is one group of near-duplicates folded into the memory kept.
+type Merge struct {
+	Kept    int64
+	Dropped []int64
+}
+
+// MaintainResult reports what a maintenance pass changed (or, for a dry run,
+// would change).
+type MaintainResult struct {
+	Decayed  int // memories whose utility faded
+	Merges   []Merge
+	Archived []int64
+	DryRun   bool
+}
+
+// Merged counts the memories folded into others.
+func (r MaintainResult) Merged() int {
+	n := 0
+	for _, m := range r.Merges {
+		n += len(m.Dropped)
+	}
+	return n
+}
+
+// String summarizes the result in one line.
+func (r MaintainResult) String() string {
+	verb := ""
+	if r.DryRun {
+		verb = " (dry run)"
+	}
+	return fmt.Sprintf("decayed %d, merged %d into %d, archived %d%s",
+		r.Decayed, r.Merged(), len(r.Merges), len(r.Archived), verb)
+}
+
+// Maintain runs the housekeeping pass that keeps a long-lived store useful:
+//
+//   - decay: learned utility fades toward zero with a half-life while a memory
+//     goes unused, so an early burst of reinforcement does not rank it forever;
+//   - merge: active memories of the same kind whose words nearly coincide are
+//     folded into the one with the best track record, which takes the union of
+//     their entities and the sum of their usage; the others stay in history,
+//     marked merged;
+//   - archive: memories neither recalled nor created within ArchiveAfter are
+//     excluded from recall (Restore brings one back), except near-critical ones.
+//
+// It is safe to run at any interval, by hand or from a schedule.
+func (s *Store) Maintain(ctx context.Context, opts MaintainOptions) (MaintainResult, error) {
+	opts = opts.withDefaults()
+	res := MaintainResult{DryRun: opts.DryRun}
+	now := s.now().UTC()
+
+	if opts.UtilityHalfLife > 0 {
+		n, err := s.decayUtility(ctx, now, opts.UtilityHalfLife, opts.DryRun)
+		if err != nil {
+			return res, err
+		}
+		res.Decayed = n
+	}
+	if opts.DuplicateSimilarity > 0 {
+		merges, err := s.mergeDuplicates(ctx, opts.DuplicateSimilarity, opts.DryRun)
+		if err != nil {
+			return res, err
+		}
+		res.Merges = merges
+	}
+	if opts.ArchiveAfter > 0 {
+		ids, err := s.archiveStale(ctx, now, opts.ArchiveAfter, opts.DryRun)
+		if err != nil {
+			return res, err
+		}
+		res.Archived = ids
+	}
+	if opts.DryRun {
+		return res, nil
+	}
+	return res, s.setMeta(ctx, maintainedKey, now.Format(time.RFC3339))
+}
+
+func (o MaintainOptions) withDefaults() MaintainOptions {
+	if o.UtilityHalfLife == 0 {
+		o.UtilityHalfLife = DefaultUtilityHalfLife
+	}
+	if o.DuplicateSimilarity == 0 {
+		o.DuplicateSimilarity = DefaultDuplicateSimilarity
+	}
+	if o.ArchiveAfter == 0 {
+		o.ArchiveAfter = DefaultArchiveAfter
+	}
+	return o
+}
+
+// LastMaintained returns when Maintain last ran (not counting dry runs), or
+// the zero time.
+func (s *Store) LastMaintained(ctx context.Context) (time.Time, error) {
+	v, err := s.getMeta(ctx, maintainedKey)
+	if err != nil || v == "" {
+		return time.Time{}, err
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("memorydb: bad %s timestamp %q: %w", maintainedKey, v, err)
+	}
+	return t, nil
+}
+
+// decayUtility fades each active memory's utility for the time it has gone
+// unused since the previous pass: by 0.5^(idle/halfLife), where idle runs from
+// the later of that pass and the memory's last use.
+func (s *Store) decayUtility(ctx context.Context, now time.Time, halfLife time.Duration, dryRun bool) (int, error) {
+	last, err := s.LastMaintained(ctx)
+	if err != nil {
+		return 0, err
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, utility_ema, COALESCE(last_used_at, created_at)
+		FROM memories
+		WHERE superseded_by IS NULL AND forgotten = 0 AND utility_ema != 0`)
+	if err != nil {
+		return 0, dbErr("list utilities", err)
+	}
+	type update struct {
+		id  int64
+		ema float64
+	}
+	var updates []update
+	for rows.Next() {
+		var (
+			id      int64
+			ema     float64
+			touched int64
+		)
+		if err := rows.Scan(&id, &ema, &touched); err != nil {
+			rows.Close()
+			return 0, dbErr("scan utility", err)
+		}
+		from := time.Unix(touched, 0)
+		if last.After(from) {
+			from = last
+		}
+		idle := now.Sub(from)
+		if idle <= 0 {
+			continue
+		}
+		decayed := ema * math.Pow(0.5, float64(idle)/float64(halfLife))
+		if math.Abs(decayed) < utilityFloor {
+			decayed = 0
+		}
+		updates = append(updates, update{id: id, ema: decayed})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, dbErr("iterate utilities", err)
+	}
+	if dryRun || len(updates) == 0 {
+		return len(updates), nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, dbErr("begin tx", err)
+	}
+	defer tx.Rollback() //nolint:errcheck // no-op after a successful Commit
+	for _, u := range updates {
+		if _, err := tx.ExecContext(ctx, `UPDATE memories SET utility_ema = ? WHERE id = ?`, u.ema, u.id); err != nil {
+			return 0, fmt.Errorf("decay utility: %w", err)
+		}
+	}
+	return len(updates), dbErr("commit", tx.Commit())
+}
+
+// dupCandidate is an active memory as duplicate detection sees it.
+type dupCandidate struct {
+	row      memoryRow
+	access   int
+	lastUsed sql.NullInt64
+	words    map[string]bool
+}
+
+// better reports whether a should be kept over b: the stronger track record
+// (utility, then use, then importance) wins, then the newer memory.
+func (a *dupCandidate) better(b *dupCandidate) bool {
+	switch {
+	case a.row.utilityEMA != b.row.utilityEMA:
+		return a.row.utilityEMA > b.row.utilityEMA
+	case a.access != b.access:
+		return a.access > b.access
+	case a.row.importance != b.row.importance:
+		return a.row.importance > b.row.importance
+	}
+	return a.row.id > b.row.id
+}
+
+// mergeDuplicates groups active near-duplicates and folds each group into its
+// best member.
+func (s *Store) mergeDuplicates(ctx context.Context, threshold float64, dryRun bool) ([]Merge, error) {
+	cands, err := s.dupCandidates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var vecs map[int64][]float32
+	if s.embedder != nil {
+		ids := make([]int64, len(cands))
+		for i, c := range cands {
+			ids[i] = c.row.id
+		}
+		if vecs, err = s.vectors(ctx, s.embedder.Model(), ids); err != nil {
+			return nil, err
+		}
+	}
+
+	grouped := make([]bool, len(cands))
+	var merges []Merge
+	for i := range cands {
+		if grouped[i] {
+			continue
+		}
+		group := []*dupCandidate{cands[i]}
+		for j := i + 1; j < len(cands); j++ {
+			if !grouped[j] && nearDuplicate(cands[i], cands[j], threshold, vecs) {
+				grouped[j] = true
+				group = append(group, cands[j])
+			}
+		}
+		if len(group) == 1 {
+			continue
+		}
+		keep := group[0]
+		for _, c := range group[1:] {
+			if c.better(keep) {
+				keep = c
+			}
+		}
+		m := Merge{Kept: keep.row.id}
+		for _, c := range group {
+			if c != keep {
+				m.Dropped = append(m.Dropped, c.row.id)
+			}
+		}
+		sort.Slice(m.Dropped, func(a, b int) bool { return m.Dropped[a] < m.Dropped[b] })
+		if !dryRun {
+			if err := s.applyMerge(ctx, keep, group); err != nil {
+				return merges, err
+			}
+		}
+		merges = append(merges, m)
+	}
+	return merges, nil
+}
+
+func (s *Store) dupCandidates(ctx context.Context) ([]*dupCandidate, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, kind, content, importance, utility_ema, access_count, last_used_at,
+		       useful_count, harmful_count
+		FROM memories
+		WHERE superseded_by IS NULL AND forgotten = 0
+		ORDER BY id`)
+	if err != nil {
+		return nil, dbErr("list duplicates", err)
+	}
+	defer rows.Close()
+	var out []*dupCandidate
+	for rows.Next() {
+		c := &dupCandidate{words: map[string]bool{}}
+		if err := rows.Scan(&c.row.id, &c.row.kind, &c.row.content, &c.row.importance, &c.row.utilityEMA,
+			&c.access, &c.lastUsed, &c.row.usefulCount, &c.row.harmfulCount); err != nil {
+			return nil, dbErr("scan duplicate", err)
+		}
+		for _, w := range strings.Fields(tokenize(c.row.content)) {
+			c.words[w] = true
+		}
+		out = append(out, c)
+	}
+	return out, dbErr("iterate duplicates", rows.Err())
+}
+
+// nearDuplicate reports whether a and b say the same thing: same kind, word
+// sets at least threshold similar, no differing numbers ("v1.24" is not
+// "v1.25"), and — when both are embedded — close in meaning too.
+func nearDuplicate(a, b *dupCandidate, threshold float64, vecs map[int64][]float32) bool {
+	if a.row.kind != b.row.kind || len(a.words) == 0 || len(b.words) == 0 {
+		return false
+	}
+	small, large := len(a.words), len(b.words)
+	if small > large {
+		small, large = large, small
+	}
+	if float64(small)/float64(large) < threshold {
+		return false // Jaccard can be no higher than this
+	}
+	shared := 0
+	for w := range a.words {
+		if b.words[w] {
+			shared++
+		} else if hasDigit(w) {
+			return false
+		}
+	}
+	for w := range b.words {
+		if !a.words[w] && hasDigit(w) {
+			return false
+		}
+	}
+	if float64(shared)/float64(len(a.words)+len(b.words)-shared) < threshold {
+		return false
+	}
+	va, vb := vecs[a.row.id], vecs[b.row.id]
+	return va == nil || vb == nil || cosine(va, vb) >= duplicateMinCosine
+}
+
+func hasDigit(w string) bool {
+	return strings.IndexFunc(w, unicode.IsDigit) >= 0
+}
+
+// applyMerge folds group's other members into keep: keep takes the highest
+// importance and utility, the summed usage and feedback, the latest use and
+// every entity; the others are retired with merged_into pointing at keep.
+func (s *Store) applyMerge(ctx context.Context, keep *dupCandidate, group []*dupCandidate) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return dbErr("begin tx", err)
+	}
+	defer tx.Rollback() //nolint:errcheck // no-op after a successful Commit
+
+	importance, utility := keep.row.importance, keep.row.utilityEMA
+	access, useful, harmful := 0, 0.0, 0.0
+	var lastUsed sql.NullInt64
+	entities, err := loadEntitiesTx(ctx, tx, keep.row.id)
+	if err != nil {
+		return err
+	}
+	for _, c := range group {
+		importance = max(importance, c.row.importance)
+		utility = max(utility, c.row.utilityEMA)
+		access += c.access
+		useful += c.row.usefulCount
+		harmful += c.row.harmfulCount
+		if c.lastUsed.Valid && (!lastUsed.Valid || c.lastUsed.Int64 > lastUsed.Int64) {
+			lastUsed = c.lastUsed
+		}
+		if c == keep {
+			continue
+		}
+		ents, err := loadEntitiesTx(ctx, tx, c.row.id)
+		if err != nil {
+			return err
+		}
+		entities = append(entities, ents...)
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE memories SET forgotten = 1, merged_into = ? WHERE id = ?`, keep.row.id, c.row.id); err != nil {
+			return fmt.Errorf("retire duplicate: %w", err)
+		}
+	}
+	entities = normalizeEntities(entities)
+	if err := insertEntities(ctx, tx, keep.row.id, entities); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE memories
+		SET importance = ?, utility_ema = ?, access_count = ?, useful_count = ?, harmful_count = ?,
+		    last_used_at = ?, search_text = ?
+		WHERE id = ?`,
+		importance, utility, access, useful, harmful, lastUsed,
+		tokenize(keep.row.content+" "+strings.Join(entities, " ")), keep.row.id); err != nil {
+		return fmt.Errorf("merge duplicates: %w", err)
+	}
+	return dbErr("commit", tx.Commit())
+}
+
+// archiveStale archives active memories last recalled (or, never recalled,
+// created) before now-after, sparing near-critical ones.
+func (s *Store) archiveStale(ctx context.Context, now time.Time, after time.Duration, dryRun bool) ([]int64, error) {
+	cutoff := now.Add(-after).Unix()
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id FROM memories
+		WHERE superseded_by IS NULL AND forgotten = 0
+		  AND COALESCE(last_used_at, created_at) < ? AND importance < ?
+		ORDER BY id`, cutoff, archiveKeepImportance)
+	if err != nil {
+		return nil, dbErr("list stale", err)
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, dbErr("scan stale", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, dbErr("iterate stale", err)
+	}
+	if dryRun || len(ids) == 0 {
+		return ids, nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, dbErr("begin tx", err)
+	}
+	defer tx.Rollback() //nolint:errcheck // no-op after a successful Commit
+	for _, id := range ids {
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE memories SET forgotten = 1, archived_at = ? WHERE id = ?`, now.Unix(), id); err != nil {
+			return nil, fmt.Errorf("archive: %w", err)
+		}
+	}
+	return ids, dbErr("commit", tx.Commit())
+}
+
+// Restore returns an archived memory to recall. Its last-used time is reset to
+// now so the next maintenance pass does not archive it straight away.
+func (s *Store) Restore(ctx context.Context, id int64) error {
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE memories SET forgotten = 0, archived_at = NULL, last_used_at = ?
+		WHERE id = ? AND archived_at IS NOT NULL AND superseded_by IS NULL`, s.now().UTC().Unix(), id)
+	if err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
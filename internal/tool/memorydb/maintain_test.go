@@ -0,0 +1,195 @@
+package memorydb
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+// advance moves the store's frozen clock forward by d.
+func advance(s *Store, d time.Duration) {
+	now := s.now().Add(d)
+	s.clock = func() time.Time { return now }
+}
+
+func mustMaintain(t *testing.T, s *Store, opts MaintainOptions) MaintainResult {
+	t.Helper()
+	res, err := s.Maintain(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Maintain: %v", err)
+	}
+	return res
+}
+
+func TestMaintainDecaysUnusedUtility(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	s := newTestStore(t)
+	m := mustRemember(t, s, "Deploys need a change ticket", 0.5)
+	ema, err := s.Reinforce(ctx, m.ID, 1) // 0.3
+	if err != nil {
+		t.Fatal(err)
+	}
+	decayOnly := MaintainOptions{DuplicateSimilarity: -1, ArchiveAfter: -1}
+	utility := func() float64 {
+		st, err := s.Stat(ctx, m.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return st.UtilityEMA
+	}
+
+	advance(s, DefaultUtilityHalfLife)
+	if res := mustMaintain(t, s, decayOnly); res.Decayed != 1 {
+		t.Fatalf("decayed = %d, want 1", res.Decayed)
+	}
+	if got := utility(); math.Abs(got-ema/2) > 1e-9 {
+		t.Fatalf("utility after one half-life = %v, want %v", got, ema/2)
+	}
+	// Running again at once decays nothing more: only idle time since the last
+	// pass counts.
+	if res := mustMaintain(t, s, decayOnly); res.Decayed != 0 {
+		t.Fatalf("second pass decayed %d", res.Decayed)
+	}
+	advance(s, DefaultUtilityHalfLife)
+	mustMaintain(t, s, decayOnly)
+	if got := utility(); math.Abs(got-ema/4) > 1e-9 {
+		t.Fatalf("utility after two half-lives = %v, want %v", got, ema/4)
+	}
+	advance(s, 20*DefaultUtilityHalfLife)
+	mustMaintain(t, s, decayOnly)
+	if got := utility(); got != 0 {
+		t.Fatalf("long-idle utility = %v, want it snapped to 0", got)
+	}
+}
+
+func TestMaintainMergesNearDuplicates(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	s := newTestStore(t)
+	first, _ := s.Remember(ctx, "The user prefers tabs over spaces in Go code", "preference", 0.4, []string{"go"}, "")
+	second, _ := s.Remember(ctx, "The user prefers tabs over spaces in Go code.", "preference", 0.7, []string{"style"}, "")
+	mustRecall(t, s, "tabs spaces") // both used once
+	if _, err := s.Reinforce(ctx, first.ID, 0.5); err != nil {
+		t.Fatal(err)
+	}
+	// Not duplicates: a differing number, and a different kind.
+	v24 := mustRemember(t, s, "Production runs Go 1.24", 0.5)
+	v25 := mustRemember(t, s, "Production runs Go 1.25", 0.5)
+	other, _ := s.Remember(ctx, "The user prefers tabs over spaces in Go code", "decision", 0.5, nil, "")
+	mergeOnly := MaintainOptions{UtilityHalfLife: -1, ArchiveAfter: -1}
+
+	dry := mustMaintain(t, s, MaintainOptions{UtilityHalfLife: -1, ArchiveAfter: -1, DryRun: true})
+	if dry.Merged() != 1 {
+		t.Fatalf("dry run = %+v", dry)
+	}
+	if st, _ := s.Stat(ctx, second.ID); !st.Active {
+		t.Fatal("a dry run changed the store")
+	}
+
+	res := mustMaintain(t, s, mergeOnly)
+	if len(res.Merges) != 1 || res.Merges[0].Kept != first.ID || len(res.Merges[0].Dropped) != 1 ||
+		res.Merges[0].Dropped[0] != second.ID {
+		t.Fatalf("merges = %+v, want #%d folded into the reinforced #%d", res.Merges, second.ID, first.ID)
+	}
+	kept, err := s.Stat(ctx, first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kept.Importance != 0.7 || kept.AccessCount != 2 || kept.UsefulCount != 0.5 {
+		t.Fatalf("kept memory = %+v, want max importance and summed usage", kept)
+	}
+	mem, _ := s.Get(ctx, first.ID)
+	if len(mem.Entities) != 2 {
+		t.Fatalf("entities = %v, want the union", mem.Entities)
+	}
+	dropped, _ := s.Stat(ctx, second.ID)
+	if dropped.Active || dropped.MergedInto != first.ID {
+		t.Fatalf("dropped memory = %+v", dropped)
+	}
+	for _, id := range []int64{v24.ID, v25.ID, other.ID} {
+		if st, _ := s.Stat(ctx, id); !st.Active {
+			t.Errorf("#%d merged though it is not a duplicate", id)
+		}
+	}
+	// The merged entity is searchable on the kept memory.
+	if hits, _ := s.Search(ctx, "style", 5); len(hits) != 1 || hits[0].ID != first.ID {
+		t.Fatalf("search by merged entity = %+v", hits)
+	}
+}
+
+func TestMaintainArchivesUnrecalledMemories(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	s := newTestStore(t)
+	stale := mustRemember(t, s, "The old office was on the fourth floor", 0.5)
+	used := mustRemember(t, s, "Standup is at ten", 0.5)
+	critical := mustRemember(t, s, "The user is allergic to peanuts", 0.95)
+
+	advance(s, DefaultArchiveAfter-24*time.Hour)
+	mustRecall(t, s, "standup")
+	advance(s, 48*time.Hour)
+	res := mustMaintain(t, s, MaintainOptions{UtilityHalfLife: -1, DuplicateSimilarity: -1})
+	if len(res.Archived) != 1 || res.Archived[0] != stale.ID {
+		t.Fatalf("archived = %v, want only #%d", res.Archived, stale.ID)
+	}
+	if hits := mustRecall(t, s, "office floor"); len(hits) != 0 {
+		t.Fatalf("archived memory still recalled: %+v", hits)
+	}
+	st, _ := s.Stat(ctx, stale.ID)
+	if st.Active || st.ArchivedAt.IsZero() {
+		t.Fatalf("archived stat = %+v", st)
+	}
+	for _, id := range []int64{used.ID, critical.ID} {
+		if st, _ := s.Stat(ctx, id); !st.Active {
+			t.Errorf("#%d archived", id)
+		}
+	}
+
+	if err := s.Restore(ctx, stale.ID); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if hits := mustRecall(t, s, "office floor"); len(hits) != 1 {
+		t.Fatalf("restored memory not recalled: %+v", hits)
+	}
+	if res := mustMaintain(t, s, MaintainOptions{UtilityHalfLife: -1, DuplicateSimilarity: -1}); len(res.Archived) != 0 {
+		t.Fatalf("restored memory archived again at once: %v", res.Archived)
+	}
+	if err := s.Restore(ctx, used.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Restore of an active memory = %v, want ErrNotFound", err)
+	}
+}
+
+func TestStats(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	s := newTestStore(t)
+	a, _ := s.Remember(ctx, "alpha", "fact", 0.5, []string{"x", "y"}, "")
+	if _, err := s.Revise(ctx, a.ID, "alpha two", "", 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := s.Remember(ctx, "beta", "lesson", 0.5, nil, "")
+	if err := s.Forget(ctx, b.ID); err != nil {
+		t.Fatal(err)
+	}
+	mustRemember(t, s, "gamma", 0.5)
+	s.SetEmbedder(NewHashingEmbedder(8))
+	mustRecall(t, s, "gamma")
+	res := mustMaintain(t, s, MaintainOptions{})
+
+	st, err := s.Stats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Total != 4 || st.Active != 2 || st.Superseded != 1 || st.Forgotten != 1 || st.Archived != 0 {
+		t.Fatalf("stats = %+v (maintenance: %s)", st, res)
+	}
+	if st.ByKind["fact"] != 2 || st.Entities != 2 || st.Vectors["hashing-8"] != 2 {
+		t.Fatalf("stats = %+v", st)
+	}
+	if !st.LastMaintained.Equal(s.now()) {
+		t.Fatalf("last maintained = %v", st.LastMaintained)
+	}
+}
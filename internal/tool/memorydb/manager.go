@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/fpt/klein-cli/pkg/agent/domain"
 	"github.com/fpt/klein-cli/pkg/message"
@@ -15,6 +16,7 @@ const (
 	jsonTypeString  = "string"
 	jsonTypeNumber  = "number"
 	jsonTypeInteger = "integer"
+	jsonTypeBoolean = "boolean"
 )
 
 // Tool argument names.
@@ -29,6 +31,8 @@ const (
 	pIDs        = "ids"
 	pSignal     = "signal"
 	pSource     = "source"
+	pDryRun     = "dry_run"
+	pArchive    = "archive_after_days"
 )
 
 // Manager exposes the memory Store as a domain.ToolManager. Because it
@@ -134,6 +138,16 @@ func (m *Manager) register() {
 			arg(pID, jsonTypeInteger, true, "Any memory id in the chain"),
 		},
 		m.handleHistory)
+
+	m.RegisterTool("MaintainMemory",
+		"Run memory housekeeping: fade the learned usefulness of memories that go unused, merge near-duplicate "+
+			"memories into one, and archive memories not recalled for a long time (archived ones leave recall but "+
+			"are kept). Meant for a periodic schedule, not ordinary conversation.",
+		[]message.ToolArgument{
+			arg(pDryRun, jsonTypeBoolean, false, "Report what would change without changing anything (default: false)"),
+			arg(pArchive, jsonTypeInteger, false, "Archive memories not recalled for this many days (default: 180)"),
+		},
+		m.handleMaintain)
 }
 
 func (m *Manager) handleRemember(ctx context.Context, args message.ToolArgumentValues) (message.ToolResult, error) {
@@ -252,6 +266,35 @@ func (m *Manager) handleHistory(ctx context.Context, args message.ToolArgumentVa
 	return message.NewToolResultText(strings.TrimRight(b.String(), "\n")), nil
 }
 
+func (m *Manager) handleMaintain(ctx context.Context, args message.ToolArgumentValues) (message.ToolResult, error) {
+	opts := MaintainOptions{DryRun: argBool(args, pDryRun)}
+	if days := argFloat(args, pArchive); days > 0 {
+		opts.ArchiveAfter = time.Duration(days * 24 * float64(time.Hour))
+	}
+	res, err := m.store.Maintain(ctx, opts)
+	if err != nil {
+		return message.NewToolResultError(fmt.Sprintf("memory maintenance failed: %v", err)), nil
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Memory maintenance: %s.", res)
+	for _, mg := range res.Merges {
+		fmt.Fprintf(&b, "\nMerged %s into #%d.", idList(mg.Dropped), mg.Kept)
+	}
+	if len(res.Archived) > 0 {
+		fmt.Fprintf(&b, "\nArchived %s.", idList(res.Archived))
+	}
+	return message.NewToolResultText(b.String()), nil
+}
+
+// idList renders ids as "#1, #2".
+func idList(ids []int64) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = "#" + strconv.FormatInt(id, 10)
+	}
+	return strings.Join(parts, ", ")
+}
+
 // --- argument helpers ---
 
 func argString(args message.ToolArgumentValues, name string) string {
@@ -272,6 +315,18 @@ func argFloat(args message.ToolArgumentValues, name string) float64 {
 	return 0
 }
 
+// argBool reads a boolean flag (accepts a JSON boolean or "true"/"false").
+func argBool(args message.ToolArgumentValues, name string) bool {
+	switch v := args[name].(type) {
+	case bool:
+		return v
+	case string:
+		b, _ := strconv.ParseBool(strings.TrimSpace(v))
+		return b
+	}
+	return false
+}
+
 // argID reads an integer memory id (accepts number or numeric string).
 func argID(args message.ToolArgumentValues, name string) (int64, bool) {
 	switch v := args[name].(type) {
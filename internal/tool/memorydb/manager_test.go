@@ -34,13 +34,13 @@ func call(t *testing.T, m *Manager, name string, args message.ToolArgumentValues
 func TestManagerRegistersTools(t *testing.T) {
 	t.Parallel()
 	m := newTestManager(t)
-	for _, name := range []string{"Remember", "Recall", "Revise", "Reinforce", "Forget", "MemoryHistory"} {
+	for _, name := range []string{"Remember", "Recall", "Revise", "Reinforce", "Forget", "MemoryHistory", "MaintainMemory"} {
 		if _, ok := m.GetTool(message.ToolName(name)); !ok {
 			t.Errorf("tool %q not registered", name)
 		}
 	}
-	if len(m.GetTools()) != 6 {
-		t.Errorf("tool count = %d, want 6", len(m.GetTools()))
+	if len(m.GetTools()) != 7 {
+		t.Errorf("tool count = %d, want 7", len(m.GetTools()))
 	}
 }
 
@@ -11,19 +11,21 @@ import (
 	"time"
 )
 
-// Recall scoring weights. Lexical relevance leads; entity match is a strong
+// Recall scoring weights. Lexical relevance leads; semantic similarity (when an
+// embedder is set) catches paraphrases the words miss; entity match is a strong
 // structured signal; importance/recency are mild priors; utility is the learned
 // signal that feedback moves (kept modest so a useful-but-irrelevant memory
 // doesn't dominate — kb.md's exploit/explore caution).
 const (
 	wLexical    = 1.00
+	wSemantic   = 0.80
 	wEntity     = 0.35
 	wImportance = 0.20
 	wRecency    = 0.15
 	wUtility    = 0.30
 
 	recencyHalfLifeDays = 30.0
-	recallCandidatePool = 4 // fetch limit*this FTS candidates before re-ranking
+	recallCandidatePool = 4 // fetch limit*this FTS (and vector) candidates before re-ranking
 )
 
 // querier is the subset of *sql.DB / *sql.Tx used by the row helpers.
@@ -126,13 +128,21 @@ type candidate struct {
 	bm25      float64
 	haveBM25  bool
 	entityHit float64
+	semantic  float64 // cosine to the query; 0 without an embedder
 }
 
 // Recall retrieves the most relevant active memories for a query, blending
-// lexical (bm25), entity, importance, recency, and learned-utility signals.
+// lexical (bm25), semantic (embedding cosine, with SetEmbedder), entity,
+// importance, recency, and learned-utility signals.
 // Passing entities boosts memories tagged with them (a stable signal even when
 // the lexical query is weak). Matching memories have their access recorded.
 func (s *Store) Recall(ctx context.Context, query string, entities []string, limit int) ([]Hit, error) {
+	return s.recall(ctx, query, entities, limit, true)
+}
+
+// recall is Recall with access recording optional: the extractor looks up
+// related memories to avoid duplicates, which is not a use of them.
+func (s *Store) recall(ctx context.Context, query string, entities []string, limit int, record bool) ([]Hit, error) {
 	if limit <= 0 {
 		limit = 8
 	}
@@ -145,6 +155,11 @@ func (s *Store) Recall(ctx context.Context, query string, entities []string, lim
 	if err := s.gatherEntities(ctx, entities, cands); err != nil {
 		return nil, err
 	}
+	if qvec := s.queryVector(ctx, query); qvec != nil {
+		if err := s.gatherSemantic(ctx, qvec, limit*recallCandidatePool, cands); err != nil {
+			return nil, err
+		}
+	}
 	if len(cands) == 0 {
 		return nil, nil
 	}
@@ -154,6 +169,9 @@ func (s *Store) Recall(ctx context.Context, query string, entities []string, lim
 	if len(hits) > limit {
 		hits = hits[:limit]
 	}
+	if !record {
+		return hits, nil
+	}
 	if err := s.recordAccess(ctx, hits); err != nil {
 		return nil, err
 	}
@@ -251,14 +269,16 @@ func (s *Store) scoreCandidates(cands map[int64]*candidate, _ []string) []Hit {
 				lex = pos / (1 + pos)
 			}
 		}
+		sem := max(c.semantic, 0)
 		recency := recencyScore(now, c.row.createdAt)
 		util := math.Tanh(c.row.utilityEMA) // squashed into (-1,1)
-		score := wLexical*lex + wEntity*c.entityHit + wImportance*c.row.importance +
+		score := wLexical*lex + wSemantic*sem + wEntity*c.entityHit + wImportance*c.row.importance +
 			wRecency*recency + wUtility*util
 		hits = append(hits, Hit{
 			Memory:    c.row.toMemory(nil),
 			Score:     score,
 			Lexical:   lex,
+			Semantic:  sem,
 			EntityHit: c.entityHit,
 			Recency:   recency,
 		})
@@ -7,9 +7,10 @@
 // The design follows kb.md: memory is stored as small atomic facts (not raw
 // conversation logs) with a kind, importance, and associated entities. Updates
 // supersede prior versions rather than deleting them (versioned internally).
-// Retrieval ("recall") blends bm25 lexical relevance with importance, recency,
-// entity match, and a learned per-memory utility that feedback ("reinforce")
-// adjusts — so memories that actually helped surface more readily over time.
+// Retrieval ("recall") blends bm25 lexical relevance — plus embedding similarity
+// when an Embedder is configured — with importance, recency, entity match, and
+// a learned per-memory utility that feedback ("reinforce") adjusts — so
+// memories that actually helped surface more readily over time.
 package memorydb
 
 import (
@@ -27,7 +28,7 @@ import (
 
 // schemaVersion is the current on-disk schema version, tracked via
 // PRAGMA user_version. Bump it and add a migration step when the schema changes.
-const schemaVersion = 1
+const schemaVersion = 4
 
 // utilityAlpha is the EMA weight applied to each feedback credit. Larger =
 // faster adaptation, noisier; smaller = slower, steadier.
@@ -52,6 +53,7 @@ type Hit struct {
 	Memory
 	Score     float64
 	Lexical   float64
+	Semantic  float64
 	EntityHit float64
 	Recency   float64
 }
@@ -64,6 +66,10 @@ type Store struct {
 	// clock supplies the current time; overridable in tests for deterministic
 	// recency/ordering.
 	clock func() time.Time
+	// embedder enables semantic recall when set (SetEmbedder); vec holds the
+	// state it needs across recalls.
+	embedder Embedder
+	vec      vectorState
 }
 
 // Open opens (creating if needed) the memory database at path and migrates it.
@@ -125,6 +131,21 @@ func (s *Store) migrate(ctx context.Context) error {
 			return fmt.Errorf("migrate to v1: %w", err)
 		}
 	}
+	if current < 2 {
+		if _, err := tx.ExecContext(ctx, migrationV2); err != nil {
+			return fmt.Errorf("migrate to v2: %w", err)
+		}
+	}
+	if current < 3 {
+		if _, err := tx.ExecContext(ctx, migrationV3); err != nil {
+			return fmt.Errorf("migrate to v3: %w", err)
+		}
+	}
+	if current < 4 {
+		if _, err := tx.ExecContext(ctx, migrationV4); err != nil {
+			return fmt.Errorf("migrate to v4: %w", err)
+		}
+	}
 
 	if _, err := tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", schemaVersion)); err != nil {
 		return fmt.Errorf("set user_version: %w", err)
@@ -182,6 +203,36 @@ CREATE TRIGGER memories_au AFTER UPDATE ON memories BEGIN
 END;
 `
 
+// migrationV2 adds a small key/value table for pipeline bookkeeping: the
+// one-time Markdown import marker and per-session extraction watermarks.
+const migrationV2 = `
+CREATE TABLE meta (
+	key   TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);
+`
+
+// migrationV3 stores one embedding per memory for semantic recall. model names
+// the embedder that produced it, so switching models re-embeds lazily instead
+// of comparing vectors from different spaces. Memories are immutable per id
+// (Revise creates a new row), so a stored vector never goes stale.
+const migrationV3 = `
+CREATE TABLE memory_vectors (
+	memory_id INTEGER PRIMARY KEY REFERENCES memories(id),
+	model     TEXT NOT NULL,
+	vector    BLOB NOT NULL
+);
+`
+
+// migrationV4 records why maintenance retired a memory. Both states also set
+// forgotten = 1, so every "active" predicate keeps excluding them: archived_at
+// marks a memory shelved for going unrecalled (Restore brings it back), and
+// merged_into points at the near-duplicate it was folded into.
+const migrationV4 = `
+ALTER TABLE memories ADD COLUMN archived_at INTEGER;
+ALTER TABLE memories ADD COLUMN merged_into INTEGER REFERENCES memories(id);
+`
+
 // ErrNotFound is returned when a memory id does not exist.
 var ErrNotFound = errors.New("memorydb: memory not found")
 
@@ -189,6 +240,14 @@ var ErrNotFound = errors.New("memorydb: memory not found")
 // importance is clamped to [0,1] (0 or negative means "use default" 0.5).
 func (s *Store) Remember(
 	ctx context.Context, content, kind string, importance float64, entities []string, source string,
+) (Memory, error) {
+	return s.rememberAt(ctx, s.now(), content, kind, importance, entities, source)
+}
+
+// rememberAt is Remember with an explicit creation time, so imported notes keep
+// the date they were written (recency scoring depends on it).
+func (s *Store) rememberAt(
+	ctx context.Context, at time.Time, content, kind string, importance float64, entities []string, source string,
 ) (Memory, error) {
 	content = strings.TrimSpace(content)
 	if content == "" {
@@ -209,7 +268,7 @@ func (s *Store) Remember(
 	}
 	defer tx.Rollback() //nolint:errcheck // no-op after a successful Commit
 
-	now := s.now().UTC()
+	now := at.UTC()
 	id, err := insertMemory(ctx, tx, memoryRow{
 		kind: kind, content: content, searchText: tokenize(content + " " + strings.Join(entities, " ")),
 		importance: importance, createdAt: now.Unix(), source: strings.TrimSpace(source), version: 1,
@@ -391,6 +450,23 @@ func (s *Store) supersededBy(ctx context.Context, id int64) (int64, error) {
 	return 0, nil
 }
 
+// getMeta returns the value stored under key, or "" when it is unset.
+func (s *Store) getMeta(ctx context.Context, key string) (string, error) {
+	var v string
+	err := s.db.QueryRowContext(ctx, `SELECT value FROM meta WHERE key = ?`, key).Scan(&v)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return v, dbErr("read meta", err)
+}
+
+// setMeta stores value under key, replacing any previous value.
+func (s *Store) setMeta(ctx context.Context, key, value string) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO meta(key, value) VALUES(?, ?) ON CONFLICT(key) DO UPDATE SET value = excluded.value`, key, value)
+	return dbErr("write meta", err)
+}
+
 // clamp constrains v to [lo, hi].
 func clamp(v, lo, hi float64) float64 {
 	if v < lo {
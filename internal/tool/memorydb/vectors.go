@@ -0,0 +1,217 @@
+package memorydb
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// vectorMinSimilarity is the cosine a memory needs to become a recall
+	// candidate on semantic similarity alone. Embedding models put unrelated
+	// sentences around 0.1–0.25 and paraphrases well above this.
+	vectorMinSimilarity = 0.35
+	// embedBackfillBatch caps how many memories without a vector are embedded
+	// alongside one query, so a large store is backfilled over several recalls
+	// rather than stalling the first.
+	embedBackfillBatch = 32
+	// embedFailureBackoff is how long recall stays lexical-only after the
+	// embedder fails, so an unreachable endpoint does not slow every turn.
+	embedFailureBackoff = time.Minute
+	// vectorIDBatch bounds the ids in one "IN (...)" vector lookup.
+	vectorIDBatch = 500
+)
+
+// vectorState is the Store's semantic-recall bookkeeping.
+type vectorState struct {
+	mu sync.Mutex
+	// cache holds decoded vectors for the embedder's model by memory id. A
+	// memory's content never changes, so entries never need invalidating.
+	cache   map[int64][]float32
+	retryAt time.Time
+}
+
+// SetEmbedder enables semantic recall: Recall embeds the query and blends its
+// cosine similarity to each memory into the ranking, embedding memories that
+// have no vector yet a batch at a time. Call it before the Store is shared.
+func (s *Store) SetEmbedder(e Embedder) {
+	s.embedder = e
+	s.vec.mu.Lock()
+	s.vec.cache = nil
+	s.vec.mu.Unlock()
+}
+
+// queryVector embeds query, backfilling a batch of memories that lack a vector
+// for the current model in the same request. It returns nil — recall stays
+// lexical — when no embedder is set or the embedder fails.
+func (s *Store) queryVector(ctx context.Context, query string) []float32 {
+	if s.embedder == nil || strings.TrimSpace(query) == "" {
+		return nil
+	}
+	s.vec.mu.Lock()
+	backingOff := s.now().Before(s.vec.retryAt)
+	s.vec.mu.Unlock()
+	if backingOff {
+		return nil
+	}
+
+	model := s.embedder.Model()
+	ids, texts, err := s.unembedded(ctx, model)
+	if err != nil {
+		return nil
+	}
+	vecs, err := s.embedder.Embed(ctx, append([]string{query}, texts...))
+	if err != nil || len(vecs) != len(texts)+1 {
+		s.vec.mu.Lock()
+		s.vec.retryAt = s.now().Add(embedFailureBackoff)
+		s.vec.mu.Unlock()
+		return nil
+	}
+	for i, id := range ids {
+		if err := s.storeVector(ctx, id, model, vecs[i+1]); err != nil {
+			break
+		}
+	}
+	return vecs[0]
+}
+
+// unembedded returns up to embedBackfillBatch active memories with no vector
+// for model, newest first.
+func (s *Store) unembedded(ctx context.Context, model string) ([]int64, []string, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT m.id, m.content
+		FROM memories m
+		LEFT JOIN memory_vectors v ON v.memory_id = m.id AND v.model = ?
+		WHERE v.memory_id IS NULL AND m.superseded_by IS NULL AND m.forgotten = 0
+		ORDER BY m.id DESC
+		LIMIT ?`, model, embedBackfillBatch)
+	if err != nil {
+		return nil, nil, dbErr("list unembedded", err)
+	}
+	defer rows.Close()
+	var ids []int64
+	var texts []string
+	for rows.Next() {
+		var id int64
+		var content string
+		if err := rows.Scan(&id, &content); err != nil {
+			return nil, nil, dbErr("scan unembedded", err)
+		}
+		ids = append(ids, id)
+		texts = append(texts, content)
+	}
+	return ids, texts, dbErr("iterate unembedded", rows.Err())
+}
+
+// storeVector records id's vector under model, replacing one from another model.
+func (s *Store) storeVector(ctx context.Context, id int64, model string, v []float32) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT OR REPLACE INTO memory_vectors (memory_id, model, vector) VALUES (?, ?, ?)`,
+		id, model, encodeVector(v))
+	return dbErr("store vector", err)
+}
+
+// gatherSemantic scores every active memory that has a vector for the current
+// model against qvec. Existing candidates get their similarity recorded; the
+// poolLimit most similar of the rest join as candidates when they clear
+// vectorMinSimilarity.
+func (s *Store) gatherSemantic(ctx context.Context, qvec []float32, poolLimit int, cands map[int64]*candidate) error {
+	model := s.embedder.Model()
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT m.id, m.kind, m.content, m.importance, m.created_at, m.utility_ema, m.version
+		FROM memory_vectors v
+		JOIN memories m ON m.id = v.memory_id
+		WHERE v.model = ? AND m.superseded_by IS NULL AND m.forgotten = 0`, model)
+	if err != nil {
+		return fmt.Errorf("recall semantic: %w", err)
+	}
+	var memRows []memoryRow
+	for rows.Next() {
+		var r memoryRow
+		if err := rows.Scan(&r.id, &r.kind, &r.content, &r.importance, &r.createdAt,
+			&r.utilityEMA, &r.version); err != nil {
+			rows.Close()
+			return dbErr("scan semantic hit", err)
+		}
+		memRows = append(memRows, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return dbErr("iterate semantic hits", err)
+	}
+
+	ids := make([]int64, len(memRows))
+	for i, r := range memRows {
+		ids[i] = r.id
+	}
+	vecs, err := s.vectors(ctx, model, ids)
+	if err != nil {
+		return err
+	}
+
+	var extra []*candidate
+	for _, r := range memRows {
+		sim := cosine(qvec, vecs[r.id])
+		if c, ok := cands[r.id]; ok {
+			c.semantic = sim
+		} else if sim >= vectorMinSimilarity {
+			extra = append(extra, &candidate{row: r, semantic: sim})
+		}
+	}
+	sort.Slice(extra, func(i, j int) bool { return extra[i].semantic > extra[j].semantic })
+	for _, c := range extra[:min(len(extra), poolLimit)] {
+		cands[c.row.id] = c
+	}
+	return nil
+}
+
+// vectors returns the vectors for ids under model, decoding only those not
+// already cached.
+func (s *Store) vectors(ctx context.Context, model string, ids []int64) (map[int64][]float32, error) {
+	s.vec.mu.Lock()
+	defer s.vec.mu.Unlock()
+	if s.vec.cache == nil {
+		s.vec.cache = map[int64][]float32{}
+	}
+	var missing []int64
+	for _, id := range ids {
+		if _, ok := s.vec.cache[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+	for len(missing) > 0 {
+		batch := missing[:min(len(missing), vectorIDBatch)]
+		missing = missing[len(batch):]
+		args := []any{model}
+		for _, id := range batch {
+			args = append(args, id)
+		}
+		rows, err := s.db.QueryContext(ctx, fmt.Sprintf(
+			`SELECT memory_id, vector FROM memory_vectors WHERE model = ? AND memory_id IN (%s)`,
+			strings.TrimSuffix(strings.Repeat("?,", len(batch)), ",")), args...)
+		if err != nil {
+			return nil, dbErr("load vectors", err)
+		}
+		for rows.Next() {
+			var id int64
+			var blob []byte
+			if err := rows.Scan(&id, &blob); err != nil {
+				rows.Close()
+				return nil, dbErr("scan vector", err)
+			}
+			s.vec.cache[id] = decodeVector(blob)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, dbErr("iterate vectors", err)
+		}
+	}
+	out := make(map[int64][]float32, len(ids))
+	for _, id := range ids {
+		out[id] = s.vec.cache[id]
+	}
+	return out, nil
+}
@@ -0,0 +1,299 @@
+package memorydb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// conceptEmbedder maps texts onto hand-picked concept axes, standing in for a
+// real model that knows "kid" and "daughter" are related. It counts the texts
+// it embeds.
+type conceptEmbedder struct {
+	model string
+	err   error
+
+	mu     sync.Mutex
+	calls  int
+	embeds int
+}
+
+var concepts = [][]string{
+	{"kid", "child", "daughter", "son"},
+	{"school", "elementary", "kindergarten"},
+	{"deploy", "release", "ship"},
+	{"tabs", "spaces", "indent"},
+}
+
+func (e *conceptEmbedder) Model() string {
+	if e.model == "" {
+		return "concepts"
+	}
+	return e.model
+}
+
+func (e *conceptEmbedder) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.calls++
+	if e.err != nil {
+		return nil, e.err
+	}
+	e.embeds += len(texts)
+	out := make([][]float32, len(texts))
+	for i, text := range texts {
+		v := make([]float32, len(concepts)+1)
+		v[len(concepts)] = 0.1 // keeps unrelated texts from being all zeros
+		lower := strings.ToLower(text)
+		for axis, words := range concepts {
+			for _, w := range words {
+				if strings.Contains(lower, w) {
+					v[axis] = 1
+				}
+			}
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+func TestRecallMatchesParaphraseWithEmbedder(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	s := newTestStore(t)
+	school := mustRemember(t, s, "Her daughter attends Midori elementary", 0.5)
+	mustRemember(t, s, "The user prefers tabs over spaces", 0.5)
+
+	if hits := mustRecall(t, s, "my kid's school"); len(hits) != 0 {
+		t.Fatalf("lexical recall should miss the paraphrase, got %+v", hits)
+	}
+
+	s.SetEmbedder(&conceptEmbedder{})
+	hits := mustRecall(t, s, "my kid's school")
+	if len(hits) != 1 || hits[0].ID != school.ID {
+		t.Fatalf("semantic recall = %+v, want only the school memory", hits)
+	}
+	if hits[0].Semantic < vectorMinSimilarity || hits[0].Lexical != 0 {
+		t.Fatalf("component scores = %+v", hits[0])
+	}
+
+	block, err := s.RecallContext(ctx, "which school does my kid go to?", 0)
+	if err != nil || !strings.Contains(block, school.Content) {
+		t.Fatalf("RecallContext dropped a semantic-only match: %q, %v", block, err)
+	}
+}
+
+func TestSemanticSimilarityBlendsIntoLexicalHits(t *testing.T) {
+	t.Parallel()
+	s := newTestStore(t)
+	s.SetEmbedder(&conceptEmbedder{})
+	// Both mention "friday"; only one is about releases.
+	release := mustRemember(t, s, "Releases ship on friday afternoons", 0.5)
+	lunch := mustRemember(t, s, "Team lunch is on friday", 0.5)
+
+	hits := mustRecall(t, s, "when do we deploy on friday")
+	if len(hits) != 2 || hits[0].ID != release.ID || hits[1].ID != lunch.ID {
+		t.Fatalf("ranking = %+v, want the release memory first", hits)
+	}
+	if hits[0].Semantic <= hits[1].Semantic {
+		t.Fatalf("semantic scores = %v, %v", hits[0].Semantic, hits[1].Semantic)
+	}
+}
+
+func TestEmbeddingBackfillIsLazyAndBatched(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	s := newTestStore(t)
+	for i := range embedBackfillBatch + 8 {
+		mustRemember(t, s, "note number "+itoa(int64(i)), 0.5)
+	}
+	emb := &conceptEmbedder{}
+	s.SetEmbedder(emb)
+
+	vectorCount := func() int {
+		var n int
+		if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM memory_vectors`).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	if vectorCount() != 0 {
+		t.Fatal("vectors must not be computed before the first recall")
+	}
+
+	mustRecall(t, s, "note")
+	if got := vectorCount(); got != embedBackfillBatch {
+		t.Fatalf("after one recall: %d vectors, want %d", got, embedBackfillBatch)
+	}
+	mustRecall(t, s, "note")
+	mustRecall(t, s, "note")
+	if got := vectorCount(); got != embedBackfillBatch+8 {
+		t.Fatalf("after backfill: %d vectors, want %d", got, embedBackfillBatch+8)
+	}
+	// query + 32, query + 8, then just the query.
+	if emb.calls != 3 || emb.embeds != embedBackfillBatch+8+3 {
+		t.Fatalf("embedder: %d calls / %d texts", emb.calls, emb.embeds)
+	}
+
+	// Another model does not reuse these vectors.
+	other := &conceptEmbedder{model: "concepts-v2"}
+	s.SetEmbedder(other)
+	mustRecall(t, s, "note")
+	if other.embeds != embedBackfillBatch+1 {
+		t.Fatalf("new model embedded %d texts, want %d", other.embeds, embedBackfillBatch+1)
+	}
+}
+
+func TestEmbedderFailureFallsBackToLexical(t *testing.T) {
+	t.Parallel()
+	s := newTestStore(t)
+	mem := mustRemember(t, s, "The build runs on buildkite", 0.5)
+	emb := &conceptEmbedder{err: errors.New("connection refused")}
+	s.SetEmbedder(emb)
+
+	hits := mustRecall(t, s, "buildkite")
+	if len(hits) != 1 || hits[0].ID != mem.ID {
+		t.Fatalf("lexical recall lost on embedder failure: %+v", hits)
+	}
+	mustRecall(t, s, "buildkite")
+	if emb.calls != 1 {
+		t.Fatalf("embedder called %d times during backoff, want 1", emb.calls)
+	}
+
+	later := s.now().Add(embedFailureBackoff + time.Second)
+	s.clock = func() time.Time { return later }
+	mustRecall(t, s, "buildkite")
+	if emb.calls != 2 {
+		t.Fatalf("embedder not retried after the backoff (calls = %d)", emb.calls)
+	}
+}
+
+func TestMigrationAddsVectorTable(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "mem.sqlite")
+	// Build a v2 database by hand.
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(ctx, migrationV1+migrationV2+"PRAGMA user_version = 2;"); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("open v2 database: %v", err)
+	}
+	defer s.Close()
+	var version int
+	if err := s.db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version); err != nil || version != schemaVersion {
+		t.Fatalf("user_version = %d, %v", version, err)
+	}
+	mem, err := s.Remember(ctx, "after the upgrade", "", 0, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.storeVector(ctx, mem.ID, "m", []float32{1, 2}); err != nil {
+		t.Fatalf("vector table missing after migration: %v", err)
+	}
+}
+
+func TestHashingEmbedder(t *testing.T) {
+	t.Parallel()
+	e := NewHashingEmbedder(64)
+	vecs, err := e.Embed(context.Background(), []string{
+		"deploy staging cluster", "staging cluster deploy", "quarterly revenue forecast",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vecs[0]) != 64 || e.Model() != "hashing-64" {
+		t.Fatalf("dims = %d, model = %q", len(vecs[0]), e.Model())
+	}
+	if same := cosine(vecs[0], vecs[1]); same < 0.99 {
+		t.Fatalf("reordered words cosine = %v, want 1", same)
+	}
+	if diff := cosine(vecs[0], vecs[2]); diff > 0.5 {
+		t.Fatalf("unrelated cosine = %v", diff)
+	}
+	if got := decodeVector(encodeVector(vecs[0])); cosine(got, vecs[0]) < 0.9999 {
+		t.Fatal("vector encoding does not round-trip")
+	}
+}
+
+func TestOpenAIEmbedder(t *testing.T) {
+	t.Parallel()
+	var requests []openAIEmbedRequest
+	var mu sync.Mutex
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/embeddings" || r.Header.Get("Authorization") != "Bearer sk-test" {
+			http.Error(w, `{"error":{"message":"bad request path or key"}}`, http.StatusUnauthorized)
+			return
+		}
+		var req openAIEmbedRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		mu.Lock()
+		requests = append(requests, req)
+		mu.Unlock()
+		// Answer in reverse order; the index field says where each belongs.
+		type item struct {
+			Embedding []float32 `json:"embedding"`
+			Index     int       `json:"index"`
+		}
+		var data []item
+		for i := len(req.Input) - 1; i >= 0; i-- {
+			data = append(data, item{Embedding: []float32{float32(len(req.Input[i])), 1}, Index: i})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": data})
+	}))
+	defer srv.Close()
+
+	e, err := NewOpenAIEmbedder(OpenAIEmbedderConfig{
+		BaseURL: srv.URL + "/v1/", APIKey: "sk-test", Model: "text-embedding-3-small", Dimensions: 256,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Model() != "text-embedding-3-small@256" {
+		t.Fatalf("Model() = %q", e.Model())
+	}
+	texts := make([]string, openAIEmbedBatch+4)
+	for i := range texts {
+		texts[i] = strings.Repeat("x", i+1)
+	}
+	vecs, err := e.Embed(context.Background(), texts)
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	if len(requests) != 2 || len(requests[1].Input) != 4 {
+		t.Fatalf("requests = %d, want the input split into 2 batches", len(requests))
+	}
+	if requests[0].Model != "text-embedding-3-small" || requests[0].Dimensions != 256 {
+		t.Fatalf("request = %+v", requests[0])
+	}
+	for i, v := range vecs {
+		if int(v[0]) != i+1 {
+			t.Fatalf("vector %d = %v, out of order", i, v)
+		}
+	}
+
+	bad, _ := NewOpenAIEmbedder(OpenAIEmbedderConfig{BaseURL: srv.URL + "/v1", APIKey: "wrong", Model: "m"})
+	if _, err := bad.Embed(context.Background(), []string{"x"}); err == nil || !strings.Contains(err.Error(), "bad request path or key") {
+		t.Fatalf("error = %v, want the API message", err)
+	}
+	if _, err := NewOpenAIEmbedder(OpenAIEmbedderConfig{}); err == nil {
+		t.Fatal("a model is required")
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -19,6 +18,8 @@ import (
 	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
 	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
 
+	"github.com/fpt/klein-cli/internal/webarchive"
+	"github.com/fpt/klein-cli/internal/webfetch"
 	"github.com/fpt/klein-cli/pkg/agent/domain"
 	"github.com/fpt/klein-cli/pkg/message"
 )
@@ -82,57 +83,23 @@ func (m *PDFToolManager) resolvePath(ctx context.Context, pathParam string) (str
 	return filepath.Abs(filepath.Join(m.workingDir, pathParam))
 }
 
-// downloadPDF fetches a PDF from a URL and saves it to a temp file.
+// downloadPDF fetches a PDF from a URL, archiving it, and saves it to a
+// temp file.
 func (m *PDFToolManager) downloadPDF(ctx context.Context, urlStr string) (string, error) {
-	client := &http.Client{Timeout: 60 * time.Second}
-	req, err := http.NewRequestWithContext(ctx, "GET", urlStr, nil)
-	if err != nil {
-		return "", fmt.Errorf("failed to create request: %v", err)
-	}
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Compatible Web Fetcher Bot)")
-
-	resp, err := client.Do(req)
+	resp, _, err := webarchive.Get(ctx, webfetch.Request{
+		URL:      urlStr,
+		Header:   http.Header{"User-Agent": {webUserAgent}},
+		MaxBytes: maxPDFBytes,
+		Timeout:  60 * time.Second,
+	})
 	if err != nil {
-		return "", fmt.Errorf("failed to fetch PDF: %v", err)
+		return "", fmt.Errorf("failed to fetch PDF: %w", err)
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		return "", fmt.Errorf("HTTP error %d: %s", resp.StatusCode, resp.Status)
 	}
-
-	const maxPDFBytes = 50 * 1024 * 1024
-	data, err := io.ReadAll(io.LimitReader(resp.Body, maxPDFBytes+1))
-	if err != nil {
-		return "", fmt.Errorf("failed to read PDF: %v", err)
-	}
-	if len(data) > maxPDFBytes {
-		return "", fmt.Errorf("PDF exceeds %dMB size limit", maxPDFBytes/1024/1024)
-	}
-
-	parsedURL, _ := url.Parse(urlStr)
-	baseName := "download.pdf"
-	if parsedURL != nil {
-		if name := filepath.Base(parsedURL.Path); name != "" && name != "." && name != "/" {
-			baseName = name
-		}
-		if !strings.HasSuffix(strings.ToLower(baseName), ".pdf") {
-			baseName += ".pdf"
-		}
-	}
-
-	tmpFile, err := os.CreateTemp("", "klein-pdf-*-"+baseName)
-	if err != nil {
-		return "", fmt.Errorf("failed to create temp file: %v", err)
-	}
-	defer tmpFile.Close()
-
-	if _, err := tmpFile.Write(data); err != nil {
-		os.Remove(tmpFile.Name())
-		return "", fmt.Errorf("failed to write temp file: %v", err)
-	}
-
-	return tmpFile.Name(), nil
+	return savePDFTemp(urlStr, resp.Body)
 }
 
 // parsePDFPages parses a comma-separated page selection string into pdfcpu format.
@@ -161,7 +128,7 @@ func (m *PDFToolManager) handlePDFInfo(ctx context.Context, args message.ToolArg
 
 	absPath, err := m.resolvePath(ctx, pathParam)
 	if err != nil {
-		return message.NewToolResultError(fmt.Sprintf("failed to resolve path: %v", err)), nil
+		return fetchError("failed to resolve path", err), nil
 	}
 
 	f, err := os.Open(absPath)
@@ -280,7 +247,7 @@ func (m *PDFToolManager) handlePDFRead(ctx context.Context, args message.ToolArg
 
 	absPath, err := m.resolvePath(ctx, pathParam)
 	if err != nil {
-		return message.NewToolResultError(fmt.Sprintf("failed to resolve path: %v", err)), nil
+		return fetchError("failed to resolve path", err), nil
 	}
 
 	pagesArg, _ := args["pages"].(string)
@@ -477,7 +444,7 @@ func (m *PDFToolManager) handlePDFExtractImages(ctx context.Context, args messag
 
 	absPath, err := m.resolvePath(ctx, pathParam)
 	if err != nil {
-		return message.NewToolResultError(fmt.Sprintf("failed to resolve path: %v", err)), nil
+		return fetchError("failed to resolve path", err), nil
 	}
 
 	pagesArg, _ := args["pages"].(string)
@@ -15,6 +15,7 @@ var blockedInPlanMode = map[message.ToolName]bool{
 	"Write":     true,
 	"Edit":      true,
 	"MultiEdit": true,
+	"Rename":    true,
 }
 
 // PlanModeGuard wraps a ToolManager and blocks destructive operations
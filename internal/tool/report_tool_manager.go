@@ -0,0 +1,419 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fpt/klein-cli/internal/report"
+	"github.com/fpt/klein-cli/internal/researcher/duckdb"
+	"github.com/fpt/klein-cli/pkg/agent/domain"
+	"github.com/fpt/klein-cli/pkg/message"
+)
+
+// maxReportCharts caps the charts in one report.
+const maxReportCharts = 12
+
+// ReportToolManager provides RenderReport, which writes Markdown and charts
+// as HTML and PDF files under the reports directory.
+type ReportToolManager struct {
+	tools  map[message.ToolName]message.Tool
+	dir    string
+	market *MarketToolManager
+	// queryTable runs a query chart's SQL; duckdb over the Researcher store
+	// by default.
+	queryTable func(ctx context.Context, sql, dataDir string) ([]string, [][]string, error)
+}
+
+// NewReportToolManager creates a report tool manager writing into dir and
+// drawing market charts with market's data.
+func NewReportToolManager(dir string, market *MarketToolManager) *ReportToolManager {
+	m := &ReportToolManager{
+		tools:      make(map[message.ToolName]message.Tool),
+		dir:        dir,
+		market:     market,
+		queryTable: researcherQueryTable,
+	}
+	m.register()
+	return m
+}
+
+func (m *ReportToolManager) register() {
+	m.RegisterTool("RenderReport",
+		"Render a finished report — Markdown plus charts — as a self-contained HTML page (SVG charts) and a PDF, saved under the reports directory. "+
+			"In the gateway the files are attached to the reply. Place a chart with a line of its own reading {{chart:ID}}; unplaced charts follow the text. "+
+			"Each chart is {\"id\", \"type\": \"line\"|\"bar\", \"title\", \"y_label\"} plus exactly one data source: "+
+			"\"market\": {\"symbols\": \"^N225, 7203.T\", \"range\": \"3mo\", \"field\": \"close\"|\"open\"|\"high\"|\"low\"|\"volume\", \"rebase\": true} (daily bars; rebase starts every series at 100); "+
+			"\"query\": {\"sql\": \"SELECT day, n FROM …\", \"x\": \"day\", \"y\": [\"n\"]} (DuckDB over the Researcher events/narratives views, as ResearcherQuery); "+
+			"or \"data\": {\"columns\": [\"month\", \"sales\"], \"rows\": [[\"Jan\", 10], [\"Feb\", 12]], \"x\": \"month\", \"y\": [\"sales\"]}. "+
+			"x defaults to the first column and y to the rest. The PDF uses standard fonts covering Latin text only; other characters print as \"?\" there.",
+		[]message.ToolArgument{
+			{Name: "title", Description: "Report title; also names the files", Required: true, Type: "string"},
+			{Name: "markdown", Description: "Report body in Markdown (headings, paragraphs, lists, tables, code, quotes, {{chart:ID}} lines)", Required: true, Type: "string"},
+			{Name: "charts", Description: "Array of chart specs (see the tool description)", Required: false, Type: "array"},
+			{Name: "formats", Description: "Comma-separated output formats: html, pdf (default both)", Required: false, Type: "string"},
+		},
+		m.handleRenderReport)
+}
+
+// --- domain.ToolManager ---
+
+func (m *ReportToolManager) GetTool(name message.ToolName) (message.Tool, bool) {
+	t, ok := m.tools[name]
+	return t, ok
+}
+
+func (m *ReportToolManager) GetTools() map[message.ToolName]message.Tool { return m.tools }
+
+func (m *ReportToolManager) CallTool(ctx context.Context, name message.ToolName, args message.ToolArgumentValues) (message.ToolResult, error) {
+	t, ok := m.tools[name]
+	if !ok {
+		return message.NewToolResultError(fmt.Sprintf("tool '%s' not found", name)), nil
+	}
+	return t.Handler()(ctx, args)
+}
+
+func (m *ReportToolManager) RegisterTool(name message.ToolName, description message.ToolDescription, arguments []message.ToolArgument, handler func(ctx context.Context, args message.ToolArgumentValues) (message.ToolResult, error)) {
+	m.tools[name] = &reportTool{name: name, description: description, arguments: arguments, handler: handler}
+}
+
+// --- chart specs ---
+
+type chartSpec struct {
+	ID     string       `json:"id"`
+	Type   string       `json:"type"`
+	Title  string       `json:"title"`
+	YLabel string       `json:"y_label"`
+	Market *marketChart `json:"market"`
+	Query  *queryChart  `json:"query"`
+	Data   *dataChart   `json:"data"`
+}
+
+type marketChart struct {
+	Symbols string `json:"symbols"`
+	Range   string `json:"range"`
+	Field   string `json:"field"`
+	Rebase  bool   `json:"rebase"`
+}
+
+type queryChart struct {
+	SQL     string   `json:"sql"`
+	DataDir string   `json:"data_dir"`
+	X       string   `json:"x"`
+	Y       []string `json:"y"`
+}
+
+type dataChart struct {
+	Columns []string `json:"columns"`
+	Rows    [][]any  `json:"rows"`
+	X       string   `json:"x"`
+	Y       []string `json:"y"`
+}
+
+func (m *ReportToolManager) handleRenderReport(ctx context.Context, args message.ToolArgumentValues) (message.ToolResult, error) {
+	title := stringArg(args, "title")
+	markdown, _ := args["markdown"].(string)
+	if title == "" || strings.TrimSpace(markdown) == "" {
+		return message.NewToolResultError("title and markdown are required"), nil
+	}
+	specs, err := parseChartSpecs(args["charts"])
+	if err != nil {
+		return message.NewToolResultError(err.Error()), nil
+	}
+	if len(specs) > maxReportCharts {
+		return message.NewToolResultError(fmt.Sprintf("%d charts (max %d)", len(specs), maxReportCharts)), nil
+	}
+	formats := []string{report.FormatHTML, report.FormatPDF}
+	if f := stringArg(args, "formats"); f != "" {
+		formats = nil
+		for _, part := range strings.Split(f, ",") {
+			if part = strings.ToLower(strings.TrimSpace(part)); part != "" {
+				formats = append(formats, part)
+			}
+		}
+	}
+
+	doc := report.Document{Title: title, Markdown: markdown}
+	seen := map[string]bool{}
+	for _, spec := range specs {
+		if seen[spec.ID] {
+			return message.NewToolResultError(fmt.Sprintf("chart id %q is used twice", spec.ID)), nil
+		}
+		seen[spec.ID] = true
+		chart, err := m.buildChart(ctx, spec)
+		if err != nil {
+			return fetchError("chart "+spec.ID, err), nil
+		}
+		doc.Charts = append(doc.Charts, chart)
+	}
+
+	res, err := report.Write(m.dir, doc, formats)
+	if err != nil {
+		return message.NewToolResultError(fmt.Sprintf("failed to render report: %v", err)), nil
+	}
+	report.OutputsFrom(ctx).Add(res.Paths...)
+
+	var b strings.Builder
+	b.WriteString("Report written:\n")
+	for _, p := range res.Paths {
+		fmt.Fprintf(&b, "  %s\n", p)
+	}
+	if res.Replaced > 0 {
+		fmt.Fprintf(&b, "Note: %d characters outside the PDF's Latin fonts were printed as \"?\" in the PDF; the HTML shows them all.\n", res.Replaced)
+	}
+	return message.NewToolResultText(strings.TrimRight(b.String(), "\n")), nil
+}
+
+// parseChartSpecs accepts the charts argument as a JSON string or as the
+// decoded array.
+func parseChartSpecs(arg any) ([]chartSpec, error) {
+	var raw []byte
+	switch v := arg.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		if strings.TrimSpace(v) == "" {
+			return nil, nil
+		}
+		raw = []byte(v)
+	default:
+		var err error
+		if raw, err = json.Marshal(v); err != nil {
+			return nil, fmt.Errorf("charts: %v", err)
+		}
+	}
+	var specs []chartSpec
+	if err := json.Unmarshal(raw, &specs); err != nil {
+		return nil, fmt.Errorf("charts must be an array of chart specs: %v", err)
+	}
+	return specs, nil
+}
+
+// buildChart resolves spec's data source into a chart.
+func (m *ReportToolManager) buildChart(ctx context.Context, spec chartSpec) (report.Chart, error) {
+	chart := report.Chart{ID: spec.ID, Kind: strings.ToLower(spec.Type), Title: spec.Title, YLabel: spec.YLabel}
+	if chart.Kind == "" {
+		chart.Kind = report.ChartLine
+	}
+	sources := 0
+	for _, set := range []bool{spec.Market != nil, spec.Query != nil, spec.Data != nil} {
+		if set {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return chart, fmt.Errorf("give exactly one of market, query or data")
+	}
+
+	var err error
+	switch {
+	case spec.Market != nil:
+		chart.Labels, chart.Series, err = m.marketSeries(ctx, *spec.Market)
+	case spec.Query != nil:
+		if strings.TrimSpace(spec.Query.SQL) == "" {
+			return chart, fmt.Errorf("query.sql is required")
+		}
+		var cols []string
+		var rows [][]string
+		if cols, rows, err = m.queryTable(ctx, spec.Query.SQL, spec.Query.DataDir); err == nil {
+			chart.Labels, chart.Series, err = tableSeries(cols, rows, spec.Query.X, spec.Query.Y)
+		}
+	case spec.Data != nil:
+		rows := make([][]string, len(spec.Data.Rows))
+		for i, row := range spec.Data.Rows {
+			for _, cell := range row {
+				rows[i] = append(rows[i], cellString(cell))
+			}
+		}
+		chart.Labels, chart.Series, err = tableSeries(spec.Data.Columns, rows, spec.Data.X, spec.Data.Y)
+	}
+	if err != nil {
+		return chart, err
+	}
+	return chart, chart.Validate()
+}
+
+// marketSeries fetches one series per symbol, aligned on the union of their
+// trading days.
+func (m *ReportToolManager) marketSeries(ctx context.Context, src marketChart) ([]string, []report.Series, error) {
+	symbols := splitSymbols(src.Symbols)
+	if len(symbols) == 0 {
+		return nil, nil, fmt.Errorf("market.symbols is required")
+	}
+	rng := strings.ToLower(strings.TrimSpace(src.Range))
+	if rng == "" {
+		rng = "3mo"
+	}
+	if !validRanges[rng] {
+		return nil, nil, fmt.Errorf("invalid range %q (use 1d, 5d, 1mo, 3mo, 6mo, 1y, ytd, max)", rng)
+	}
+	field := strings.ToLower(strings.TrimSpace(src.Field))
+	pick := map[string]func(dailyBar) float64{
+		"":       func(b dailyBar) float64 { return b.close },
+		"close":  func(b dailyBar) float64 { return b.close },
+		"open":   func(b dailyBar) float64 { return b.open },
+		"high":   func(b dailyBar) float64 { return b.high },
+		"low":    func(b dailyBar) float64 { return b.low },
+		"volume": func(b dailyBar) float64 { return b.volume },
+	}[field]
+	if pick == nil {
+		return nil, nil, fmt.Errorf("invalid field %q (use close, open, high, low, volume)", field)
+	}
+
+	byDay := make([]map[string]float64, len(symbols))
+	days := map[string]bool{}
+	names := make([]string, len(symbols))
+	for i, in := range symbols {
+		sym := resolveSymbol(in)
+		meta, bars, err := m.market.dailyBars(ctx, sym, rng)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", sym, err)
+		}
+		if len(bars) == 0 {
+			return nil, nil, fmt.Errorf("no daily bars for %s over %s", sym, rng)
+		}
+		names[i] = sym
+		if meta.ShortName != "" {
+			names[i] = meta.ShortName
+		}
+		byDay[i] = map[string]float64{}
+		for _, b := range bars {
+			day := b.t.Format("2006-01-02")
+			byDay[i][day] = pick(b)
+			days[day] = true
+		}
+	}
+
+	labels := make([]string, 0, len(days))
+	for d := range days {
+		labels = append(labels, d)
+	}
+	sort.Strings(labels)
+	series := make([]report.Series, len(symbols))
+	for i := range symbols {
+		values := make([]float64, len(labels))
+		base := math.NaN()
+		for j, d := range labels {
+			v, ok := byDay[i][d]
+			if !ok {
+				v = math.NaN()
+			}
+			if src.Rebase && !math.IsNaN(v) {
+				if math.IsNaN(base) {
+					base = v
+				}
+				v = v / base * 100
+			}
+			values[j] = v
+		}
+		series[i] = report.Series{Name: names[i], Values: values}
+	}
+	return labels, series, nil
+}
+
+// tableSeries turns a table into chart labels (column x, default the first)
+// and series (columns y, default the rest). Cells that are not numbers are
+// gaps.
+func tableSeries(cols []string, rows [][]string, x string, y []string) ([]string, []report.Series, error) {
+	if len(cols) < 2 {
+		return nil, nil, fmt.Errorf("need an x column and at least one y column, got %v", cols)
+	}
+	index := func(name string) (int, error) {
+		for i, c := range cols {
+			if c == name {
+				return i, nil
+			}
+		}
+		return 0, fmt.Errorf("no column %q in %v", name, cols)
+	}
+	xi := 0
+	if x != "" {
+		var err error
+		if xi, err = index(x); err != nil {
+			return nil, nil, err
+		}
+	}
+	var yi []int
+	for _, name := range y {
+		i, err := index(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		yi = append(yi, i)
+	}
+	if len(yi) == 0 {
+		for i := range cols {
+			if i != xi {
+				yi = append(yi, i)
+			}
+		}
+	}
+
+	cell := func(row []string, i int) string {
+		if i < len(row) {
+			return row[i]
+		}
+		return ""
+	}
+	labels := make([]string, len(rows))
+	series := make([]report.Series, len(yi))
+	for s, i := range yi {
+		series[s] = report.Series{Name: cols[i], Values: make([]float64, len(rows))}
+	}
+	for r, row := range rows {
+		labels[r] = cell(row, xi)
+		for s, i := range yi {
+			v, err := strconv.ParseFloat(strings.TrimSpace(cell(row, i)), 64)
+			if err != nil {
+				v = math.NaN()
+			}
+			series[s].Values[r] = v
+		}
+	}
+	return labels, series, nil
+}
+
+// cellString formats a decoded JSON cell.
+func cellString(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+// researcherQueryTable runs sql with duckdb over the Researcher store in
+// dataDir, or the configured one.
+func researcherQueryTable(ctx context.Context, sql, dataDir string) ([]string, [][]string, error) {
+	d, err := resolveDefaults(message.ToolArgumentValues{"data_dir": dataDir})
+	if err != nil {
+		return nil, nil, err
+	}
+	return duckdb.QueryTable(ctx, d.DataDir, sql)
+}
+
+// reportTool implements message.Tool.
+type reportTool struct {
+	name        message.ToolName
+	description message.ToolDescription
+	arguments   []message.ToolArgument
+	handler     func(ctx context.Context, args message.ToolArgumentValues) (message.ToolResult, error)
+}
+
+func (t *reportTool) RawName() message.ToolName            { return t.name }
+func (t *reportTool) Name() message.ToolName               { return t.name }
+func (t *reportTool) Description() message.ToolDescription { return t.description }
+func (t *reportTool) Arguments() []message.ToolArgument    { return t.arguments }
+func (t *reportTool) Handler() func(ctx context.Context, args message.ToolArgumentValues) (message.ToolResult, error) {
+	return t.handler
+}
+
+var _ domain.ToolManager = (*ReportToolManager)(nil)
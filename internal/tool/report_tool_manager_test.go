@@ -0,0 +1,78 @@
+package tool
+
+import (
+	"context"
+	"math"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/fpt/klein-cli/internal/report"
+	"github.com/fpt/klein-cli/pkg/message"
+)
+
+func TestRenderReport(t *testing.T) {
+	dir := t.TempDir()
+	m := NewReportToolManager(dir, NewMarketToolManager())
+	m.queryTable = func(_ context.Context, sql, _ string) ([]string, [][]string, error) {
+		return []string{"day", "n", "label"}, [][]string{{"2026-06-19", "2", "x"}, {"2026-06-20", "NULL", "y"}}, nil
+	}
+
+	ctx, outputs := report.WithOutputs(context.Background())
+	res, err := m.CallTool(ctx, "RenderReport", message.ToolArgumentValues{
+		"title":    "Events — June",
+		"markdown": "## Summary\n\n{{chart:events}}\n\nTwo days.",
+		"charts": []any{
+			map[string]any{"id": "events", "type": "bar", "query": map[string]any{"sql": "SELECT 1", "y": []any{"n"}}},
+			map[string]any{"id": "sales", "data": map[string]any{
+				"columns": []any{"month", "sales"},
+				"rows":    []any{[]any{"Jan", 10.0}, []any{"Feb", 12.5}},
+			}},
+		},
+	})
+	if err != nil || res.Error != "" {
+		t.Fatalf("RenderReport: %v %q", err, res.Error)
+	}
+	paths := outputs.Paths()
+	if len(paths) != 2 || !strings.HasSuffix(paths[0], ".html") || !strings.HasSuffix(paths[1], ".pdf") {
+		t.Fatalf("outputs = %v", paths)
+	}
+	if !strings.Contains(res.Text, paths[0]) || strings.Contains(res.Text, "Note:") {
+		t.Errorf("result = %q", res.Text)
+	}
+	html, err := os.ReadFile(paths[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(string(html), "<svg") != 2 {
+		t.Error("HTML does not hold both charts")
+	}
+
+	for name, args := range map[string]message.ToolArgumentValues{
+		"no source":    {"title": "t", "markdown": "x", "charts": `[{"id":"a"}]`},
+		"two sources":  {"title": "t", "markdown": "x", "charts": `[{"id":"a","query":{"sql":"s"},"data":{"columns":["a","b"]}}]`},
+		"bad column":   {"title": "t", "markdown": "x", "charts": `[{"id":"a","query":{"sql":"s","x":"nope"}}]`},
+		"bad type":     {"title": "t", "markdown": "x", "charts": `[{"id":"a","type":"pie","query":{"sql":"s"}}]`},
+		"duplicate id": {"title": "t", "markdown": "x", "charts": `[{"id":"a","query":{"sql":"s"}},{"id":"a","query":{"sql":"s"}}]`},
+		"bad format":   {"title": "t", "markdown": "x", "formats": "docx"},
+		"no markdown":  {"title": "t"},
+	} {
+		res, err := m.CallTool(context.Background(), "RenderReport", args)
+		if err != nil || res.Error == "" {
+			t.Errorf("%s: want a tool error, got %v %+v", name, err, res)
+		}
+	}
+}
+
+func TestTableSeries(t *testing.T) {
+	labels, series, err := tableSeries([]string{"n", "day", "m"}, [][]string{{"1", "d1", "x"}, {"2", "d2"}}, "day", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(labels) != 2 || labels[1] != "d2" || len(series) != 2 || series[0].Name != "n" {
+		t.Fatalf("labels %v series %+v", labels, series)
+	}
+	if series[0].Values[1] != 2 || !math.IsNaN(series[1].Values[0]) || !math.IsNaN(series[1].Values[1]) {
+		t.Errorf("values = %+v", series)
+	}
+}
@@ -20,6 +20,7 @@ import (
 	ehconfig "github.com/fpt/klein-cli/internal/researcher/config"
 	"github.com/fpt/klein-cli/internal/researcher/crawler"
 	"github.com/fpt/klein-cli/internal/researcher/duckdb"
+	"github.com/fpt/klein-cli/internal/researcher/eventstudy"
 	"github.com/fpt/klein-cli/internal/researcher/model"
 	"github.com/fpt/klein-cli/internal/researcher/pipeline"
 	"github.com/fpt/klein-cli/internal/researcher/store"
@@ -32,15 +33,21 @@ type ResearcherToolManager struct {
 }
 
 // NewResearcherToolManager constructs the manager and registers all tools.
-func NewResearcherToolManager() *ResearcherToolManager {
+// market supplies the price history of the event study; nil disables it.
+func NewResearcherToolManager(market *MarketToolManager) *ResearcherToolManager {
+	var prices eventstudy.PriceSource
+	if market != nil {
+		prices = marketPrices{market}
+	}
 	m := &ResearcherToolManager{tools: make(map[message.ToolName]message.Tool)}
 	m.tools["ResearcherFetch"] = &ehFetchTool{}
-	m.tools["ResearcherAnalyze"] = &ehAnalyzeTool{}
+	m.tools["ResearcherAnalyze"] = &ehAnalyzeTool{prices: prices}
 	m.tools["ResearcherNarratives"] = &ehNarrativesTool{}
 	m.tools["ResearcherEvents"] = &ehEventsTool{}
 	m.tools["ResearcherIngestURL"] = &ehIngestURLTool{}
 	m.tools["ResearcherCrawlListing"] = &ehCrawlListingTool{}
 	m.tools["ResearcherQuery"] = &ehQueryTool{}
+	m.tools["ResearcherEventStudy"] = &ehEventStudyTool{prices: prices}
 	return m
 }
 
@@ -182,13 +189,17 @@ func (t *ehFetchTool) Handler() func(ctx context.Context, args message.ToolArgum
 
 // ---------- ResearcherAnalyze ----------
 
-type ehAnalyzeTool struct{}
+type ehAnalyzeTool struct {
+	prices eventstudy.PriceSource
+}
 
 func (t *ehAnalyzeTool) RawName() message.ToolName { return "ResearcherAnalyze" }
 func (t *ehAnalyzeTool) Name() message.ToolName    { return "ResearcherAnalyze" }
 func (t *ehAnalyzeTool) Description() message.ToolDescription {
 	return "Cluster stored events into market narratives, score them by source diversity / trust tier / outcome confirmation / recency, " +
 		"and write narratives.json plus a daily markdown report. " +
+		"Each run also records narrative episodes in narrative_history.json and, for narratives mapped to symbols in the config, " +
+		"adds their abnormal market reaction to the report (see ResearcherEventStudy). " +
 		"Run ResearcherFetch first to refresh the dataset. " +
 		"Returns the narrative count and the path to the markdown report."
 }
@@ -223,6 +234,7 @@ func (t *ehAnalyzeTool) Handler() func(ctx context.Context, args message.ToolArg
 			Logger:         silentLogger(),
 			NarrativeLimit: limit,
 			WindowDays:     windowDays,
+			Prices:         t.prices,
 			Now:            now,
 		}
 
@@ -486,7 +498,7 @@ func (t *ehIngestURLTool) Handler() func(ctx context.Context, args message.ToolA
 
 		result, err := crawler.FetchSingle(ctx, urlStr)
 		if err != nil {
-			return message.NewToolResultError(fmt.Sprintf("fetch failed: %v", err)), nil
+			return fetchError("fetch failed", err), nil
 		}
 		if titleOverride := stringArg(args, "title"); titleOverride != "" {
 			result.Title = titleOverride
@@ -512,10 +524,14 @@ func (t *ehIngestURLTool) Handler() func(ctx context.Context, args message.ToolA
 			return message.NewToolResultError(fmt.Sprintf("appending to %s: %v", eventsPath, err)), nil
 		}
 
-		return message.ToolResult{Text: fmt.Sprintf(
+		text := fmt.Sprintf(
 			"Ingested 1 event into %s.\nID: %s\nTitle: %s\nURL: %s\nContent-Type: %s\nIntake: %s  Role: %s  Trust: %s  Published: %s",
 			eventsPath, event.ID, event.Title, event.URL, result.ContentType,
-			event.Intake, event.Role, event.TrustTier, event.PublishedAt.Format(time.RFC3339))}, nil
+			event.Intake, event.Role, event.TrustTier, event.PublishedAt.Format(time.RFC3339))
+		if event.Snapshot != "" {
+			text += fmt.Sprintf("\nSnapshot: %s (reread with WebArchiveGet)", shortSnapshotID(event.Snapshot))
+		}
+		return message.ToolResult{Text: text}, nil
 	}
 }
 
@@ -585,7 +601,7 @@ func (t *ehCrawlListingTool) Handler() func(ctx context.Context, args message.To
 
 		items, err := crawler.FetchListing(ctx, urlStr, maxItems)
 		if err != nil {
-			return message.NewToolResultError(fmt.Sprintf("listing fetch failed: %v", err)), nil
+			return fetchError("listing fetch failed", err), nil
 		}
 		if len(items) == 0 {
 			return message.ToolResult{Text: fmt.Sprintf("No candidate items found at %s. The page may render content via JavaScript or have no anchor links matching the heuristic.", urlStr)}, nil
@@ -683,6 +699,192 @@ func (t *ehQueryTool) Handler() func(ctx context.Context, args message.ToolArgum
 	}
 }
 
+// ---------- ResearcherEventStudy ----------
+
+type ehEventStudyTool struct {
+	prices eventstudy.PriceSource
+}
+
+func (t *ehEventStudyTool) RawName() message.ToolName { return "ResearcherEventStudy" }
+func (t *ehEventStudyTool) Name() message.ToolName    { return "ResearcherEventStudy" }
+func (t *ehEventStudyTool) Description() message.ToolDescription {
+	return "Event study joining narratives with market history: for each narrative episode (first seen → last seen, kept in " +
+		"narrative_history.json by ResearcherAnalyze) and each symbol its themes/entities map to via the config's `symbols` section, " +
+		"fit a market model on the trading days before the narrative appeared and report cumulative abnormal returns (CAR) with " +
+		"t-statistics over a pre-event window and post-event windows. Flags narratives that historically preceded significant moves " +
+		"(|t| ≥ 1.96 after, not before). This shows timing, not causation — corroborate with ResearcherEvents and MarketNews."
+}
+
+func (t *ehEventStudyTool) Arguments() []message.ToolArgument {
+	return []message.ToolArgument{
+		{Name: "narrative", Description: "Narrative ID prefix or label substring; empty studies every episode.", Required: false, Type: "string"},
+		{Name: "symbols", Description: "Comma-separated symbols to study for the selected narratives instead of the configured mappings (e.g. 'NVDA, SMH').", Required: false, Type: "string"},
+		{Name: "benchmark", Description: "Market benchmark symbol, or 'none' for mean-adjusted returns. Defaults to the config's (^GSPC).", Required: false, Type: "string"},
+		{Name: "windows", Description: "Comma-separated post-event windows in trading days (e.g. '1,5,20'). Defaults to the config's.", Required: false, Type: "string"},
+		{Name: "estimation_days", Description: "Trading days before the event used to fit the market model. Default from config (60).", Required: false, Type: "number"},
+		{Name: "pre_days", Description: "Trading days in the pre-event window. Default from config (5).", Required: false, Type: "number"},
+		{Name: "since", Description: "Only episodes first seen on or after this date (YYYY-MM-DD).", Required: false, Type: "string"},
+		{Name: "only_preceded", Description: "If true, list only results where the narrative preceded a significant move.", Required: false, Type: "string"},
+		{Name: "data_dir", Description: "Directory containing narrative_history.json / narratives.json. Defaults to ~/.klein/researcher/data.", Required: false, Type: "string"},
+		{Name: "config_path", Description: "Config holding the symbol mappings and event_study settings. Defaults to ~/.klein/researcher/config.yaml.", Required: false, Type: "string"},
+	}
+}
+
+func (t *ehEventStudyTool) Handler() func(ctx context.Context, args message.ToolArgumentValues) (message.ToolResult, error) {
+	return func(ctx context.Context, args message.ToolArgumentValues) (message.ToolResult, error) {
+		if t.prices == nil {
+			return message.NewToolResultError("ResearcherEventStudy: market data is not available"), nil
+		}
+		d, err := resolveDefaults(args)
+		if err != nil {
+			return message.NewToolResultError(err.Error()), nil
+		}
+		cfg, err := ehconfig.Load(d.ConfigPath)
+		if err != nil {
+			return message.NewToolResultError(fmt.Sprintf("loading config %s: %v", d.ConfigPath, err)), nil
+		}
+
+		study := eventstudy.New(t.prices, cfg)
+		if v := stringArg(args, "benchmark"); v != "" {
+			study.Benchmark = resolveSymbol(v)
+			if strings.EqualFold(v, "none") {
+				study.Benchmark = "none"
+			}
+			// An explicit benchmark wins over per-mapping ones.
+			for i := range study.Mappings {
+				study.Mappings[i].Benchmark = ""
+			}
+		}
+		if v := stringArg(args, "windows"); v != "" {
+			windows, err := parseWindows(v)
+			if err != nil {
+				return message.NewToolResultError(fmt.Sprintf("ResearcherEventStudy: %v", err)), nil
+			}
+			study.Windows = windows
+		}
+		study.EstimationDays = intArg(args, "estimation_days", study.EstimationDays)
+		study.PreDays = intArg(args, "pre_days", study.PreDays)
+		if v := stringArg(args, "symbols"); v != "" {
+			study.Mappings = nil
+			for _, s := range splitSymbols(v) {
+				study.Mappings = append(study.Mappings, model.SymbolMapping{Symbol: resolveSymbol(s)})
+			}
+		}
+		if len(study.Mappings) == 0 {
+			return message.ToolResult{Text: fmt.Sprintf("No symbol mappings in %s. Add a `symbols:` section "+
+				"(entity or theme → symbol; delete the file to reseed the defaults) or pass symbols.", d.ConfigPath)}, nil
+		}
+
+		episodes, source, err := readEpisodes(d.DataDir)
+		if err != nil {
+			return message.NewToolResultError(err.Error()), nil
+		}
+		if len(episodes) == 0 {
+			return message.ToolResult{Text: "No narratives stored. Run ResearcherAnalyze first."}, nil
+		}
+		var since time.Time
+		if v := stringArg(args, "since"); v != "" {
+			if since, err = parseFlexibleDate(v); err != nil {
+				return message.NewToolResultError(fmt.Sprintf("ResearcherEventStudy: %v", err)), nil
+			}
+		}
+		needle := strings.ToLower(stringArg(args, "narrative"))
+		var selected []model.Narrative
+		for _, n := range episodes {
+			if n.FirstSeen.Before(since) {
+				continue
+			}
+			if needle != "" && !strings.HasPrefix(n.ID, needle) && !strings.Contains(strings.ToLower(n.Label), needle) {
+				continue
+			}
+			selected = append(selected, n)
+		}
+		if len(selected) == 0 {
+			return message.ToolResult{Text: fmt.Sprintf("No narrative episodes in %s match.", source)}, nil
+		}
+
+		results, err := study.Run(ctx, selected)
+		if err != nil {
+			return message.NewToolResultError(fmt.Sprintf("event study failed: %v", err)), nil
+		}
+		if len(results) == 0 {
+			return message.ToolResult{Text: fmt.Sprintf("None of the %d selected episode(s) map to a symbol. "+
+				"Add entity/theme mappings to %s or pass symbols.", len(selected), d.ConfigPath)}, nil
+		}
+		return message.ToolResult{Text: formatEventStudy(study, results, len(selected), source, stringArg(args, "only_preceded") == "true")}, nil
+	}
+}
+
+// readEpisodes reads the narrative episode history, falling back to the
+// current narratives when ResearcherAnalyze has not recorded one yet.
+func readEpisodes(dataDir string) ([]model.Narrative, string, error) {
+	path := filepath.Join(dataDir, "narrative_history.json")
+	episodes, err := store.ReadNarrativeHistory(path)
+	if err != nil || len(episodes) > 0 {
+		return episodes, path, err
+	}
+	path = filepath.Join(dataDir, "narratives.json")
+	episodes, err = readNarratives(path)
+	if err != nil {
+		return nil, path, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return episodes, path, nil
+}
+
+func parseWindows(s string) ([]int, error) {
+	var windows []int
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		var n int
+		if _, err := fmt.Sscanf(part, "%d", &n); err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid window %q (want positive trading-day counts like '1,5,20')", part)
+		}
+		windows = append(windows, n)
+	}
+	if len(windows) == 0 {
+		return nil, fmt.Errorf("no windows in %q", s)
+	}
+	return windows, nil
+}
+
+func formatEventStudy(study eventstudy.Study, results []model.EventStudyResult, episodes int, source string, onlyPreceded bool) string {
+	preceded := 0
+	for _, r := range results {
+		if r.Preceded {
+			preceded++
+		}
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Event study of %d episode(s) from %s: %d result(s), %d preceded a significant move.\n",
+		episodes, source, len(results), preceded)
+	fmt.Fprintf(&b, "Benchmark %s; market model fitted on %d trading days before each event; pre-event window %d days; "+
+		"|t| ≥ %.2f is significant at about 5%%.\n\n", study.Benchmark, study.EstimationDays, study.PreDays, eventstudy.Significance)
+
+	last := ""
+	for _, r := range results {
+		if onlyPreceded && !r.Preceded {
+			continue
+		}
+		if key := r.NarrativeID + r.FirstSeen.String(); key != last {
+			last = key
+			fmt.Fprintf(&b, "[%s] %s (first seen %s)\n", r.NarrativeID, r.Label, r.FirstSeen.Format("2006-01-02"))
+		}
+		mark := ""
+		if r.Preceded {
+			mark = "  ← preceded a move"
+		}
+		fmt.Fprintf(&b, "  - %s%s\n", r.Summary(), mark)
+	}
+	if onlyPreceded && preceded == 0 {
+		b.WriteString("No narrative preceded a significant move.\n")
+	}
+	b.WriteString("\nTiming is not causation: check ResearcherEvents and MarketNews for what else happened around each event date.")
+	return b.String()
+}
+
 // parseFlexibleDate accepts ISO-8601 dates with or without time. Returns UTC.
 func parseFlexibleDate(s string) (time.Time, error) {
 	layouts := []string{
@@ -0,0 +1,83 @@
+package tool
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fpt/klein-cli/internal/researcher/eventstudy"
+	"github.com/fpt/klein-cli/internal/researcher/model"
+	"github.com/fpt/klein-cli/internal/researcher/store"
+	"github.com/fpt/klein-cli/pkg/message"
+)
+
+type fakePrices map[string][]eventstudy.Bar
+
+func (f fakePrices) DailyBars(_ context.Context, symbol string, _, _ time.Time) ([]eventstudy.Bar, error) {
+	return f[symbol], nil
+}
+
+func TestResearcherEventStudy(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+	config := "sources:\n  - name: markets\n    url: https://example.com/rss.xml\nsymbols:\n  - entity: Nvidia\n    symbol: NVDA\nevent_study:\n  windows: [1, 5]\n"
+	if err := os.WriteFile(configPath, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC)
+	var bench, nvda []eventstudy.Bar
+	pb, pn := 100.0, 100.0
+	for d := start; len(bench) < 100; d = d.AddDate(0, 0, 1) {
+		if d.Weekday() == time.Saturday || d.Weekday() == time.Sunday {
+			continue
+		}
+		i := float64(len(bench))
+		m := 0.01 * math.Sin(i*1.7)
+		pb *= 1 + m
+		pn *= 1 + 1.2*m + 0.002*math.Cos(i*2.3)
+		if len(bench) == 80 {
+			pn *= 1.08
+		}
+		bench = append(bench, eventstudy.Bar{Date: d, Close: pb})
+		nvda = append(nvda, eventstudy.Bar{Date: d, Close: pn})
+	}
+	episodes := []model.Narrative{
+		{ID: "abc123", Label: "AI chips around Nvidia", Entities: []string{"Nvidia"}, FirstSeen: bench[80].Date, LastSeen: bench[85].Date},
+		{ID: "def456", Label: "Energy", Themes: []string{"energy"}, FirstSeen: bench[80].Date},
+	}
+	if err := store.WriteNarratives(filepath.Join(dir, "narrative_history.json"), episodes); err != nil {
+		t.Fatal(err)
+	}
+
+	tool := &ehEventStudyTool{prices: fakePrices{"^GSPC": bench, "NVDA": nvda}}
+	res, err := tool.Handler()(context.Background(), message.ToolArgumentValues{"data_dir": dir, "config_path": configPath})
+	if err != nil || res.Error != "" {
+		t.Fatalf("ResearcherEventStudy: %v %q", err, res.Error)
+	}
+	for _, want := range []string{"[abc123] AI chips around Nvidia", "NVDA vs ^GSPC", "CAR[0,4]", "preceded a move", "1 preceded"} {
+		if !strings.Contains(res.Text, want) {
+			t.Errorf("result lacks %q:\n%s", want, res.Text)
+		}
+	}
+	if strings.Contains(res.Text, "def456") {
+		t.Errorf("unmapped narrative reported:\n%s", res.Text)
+	}
+
+	res, _ = tool.Handler()(context.Background(), message.ToolArgumentValues{
+		"data_dir": dir, "config_path": configPath, "narrative": "energy", "symbols": "NVDA", "benchmark": "none",
+	})
+	if res.Error != "" || !strings.Contains(res.Text, "[def456]") || !strings.Contains(res.Text, "NVDA (mean-adjusted)") {
+		t.Errorf("symbols override:\n%s %s", res.Text, res.Error)
+	}
+
+	res, _ = tool.Handler()(context.Background(), message.ToolArgumentValues{"data_dir": dir, "config_path": configPath, "windows": "0"})
+	if res.Error == "" {
+		t.Error("a zero window was accepted")
+	}
+}
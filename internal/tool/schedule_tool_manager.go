@@ -29,6 +29,14 @@ type scheduleEntry struct {
 	Silent      bool   `json:"silent"`
 	ChannelType string `json:"channel_type"`
 	ChannelID   string `json:"channel_id"`
+
+	// Run policy, edited by hand or in settings rather than by these tools;
+	// carried so a ScheduleCreate/Delete rewrite keeps it.
+	Timeout       string `json:"timeout,omitempty"`
+	Retries       int    `json:"retries,omitempty"`
+	RetryBackoff  string `json:"retry_backoff,omitempty"`
+	CatchUp       string `json:"catch_up,omitempty"`
+	CatchUpWindow string `json:"catch_up_window,omitempty"`
 }
 
 // ScheduleToolManager provides ScheduleCreate/List/Delete tools that maintain
@@ -140,6 +148,9 @@ func (m *ScheduleToolManager) handleCreate(ctx context.Context, args message.Too
 	replaced := false
 	for i := range entries {
 		if entries[i].Name == name {
+			old := entries[i]
+			entry.Timeout, entry.Retries, entry.RetryBackoff = old.Timeout, old.Retries, old.RetryBackoff
+			entry.CatchUp, entry.CatchUpWindow = old.CatchUp, old.CatchUpWindow
 			entries[i] = entry
 			replaced = true
 			break
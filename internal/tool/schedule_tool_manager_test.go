@@ -2,6 +2,7 @@ package tool
 
 import (
 	"context"
+	"os"
 	"path/filepath"
 	"strings"
 	"testing"
@@ -146,3 +147,23 @@ func TestScheduleCreateRejectsMetaPrompt(t *testing.T) {
 		t.Errorf("actionable prompt rejected: %s", r.Error)
 	}
 }
+
+func TestScheduleCreateKeepsRunPolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schedules.json")
+	if err := os.WriteFile(path, []byte(`[{"name":"brief","enabled":true,"cron":"0 8 * * *","timezone":"Asia/Tokyo",
+		"prompt":"brief","channel_id":"1","retries":2,"retry_backoff":"5m","catch_up":"once"}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m := NewScheduleToolManager(path)
+	r, _ := m.CallTool(context.Background(), "ScheduleCreate", message.ToolArgumentValues{
+		"name": "brief", "prompt": "today's brief", "cron": "0 7 * * *",
+		"timezone": "Asia/Tokyo", "channel_type": "discord", "channel_id": "1",
+	})
+	if r.Error != "" {
+		t.Fatalf("create: %s", r.Error)
+	}
+	entries, _ := m.load()
+	if len(entries) != 1 || entries[0].Retries != 2 || entries[0].RetryBackoff != "5m" || entries[0].CatchUp != "once" {
+		t.Errorf("an update must keep the hand-set run policy: %+v", entries)
+	}
+}
@@ -2,30 +2,66 @@ package tool
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
 	"os/exec"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 
+	"github.com/fpt/klein-cli/internal/repository"
+	"github.com/fpt/klein-cli/internal/tool/filesearch"
 	"github.com/fpt/klein-cli/pkg/agent/domain"
 	"github.com/fpt/klein-cli/pkg/message"
 )
 
-// SearchToolManager provides Glob and Grep tools
+// SearchToolManager provides Glob and Grep tools. Both run in-process
+// (internal/tool/filesearch) and honour .gitignore and .ignore; ripgrep, when
+// it is on PATH, is only an accelerator and any failure of it falls back to
+// the in-process engine.
 type SearchToolManager struct {
 	tools      map[message.ToolName]message.Tool
 	workingDir string
+	allowed    []string // absolute directories a search may start in
+	blacklist  []string // files Grep never reads
+	rgPath     string   // "" = in-process only
 }
 
 type SearchConfig struct {
 	WorkingDir string
+	// FileSystem carries the filesystem tools' limits: a search may only start
+	// inside AllowedDirectories (the working directory always counts), and
+	// Grep never reads a file matching BlacklistedFiles. Glob lists names
+	// only, so it is not subject to the blacklist.
+	FileSystem repository.FileSystemConfig
+	// DisableRipgrep keeps every search in-process even when rg is on PATH.
+	DisableRipgrep bool
 }
 
 func NewSearchToolManager(cfg SearchConfig) domain.ToolManager {
+	absWorkingDir, err := filepath.Abs(cfg.WorkingDir)
+	if err != nil {
+		absWorkingDir = cfg.WorkingDir
+	}
+	var allowed []string
+	for _, dir := range ensureWorkingDirectoryInAllowedList(cfg.FileSystem.AllowedDirectories, absWorkingDir) {
+		if dirAbs, err := filepath.Abs(dir); err == nil {
+			allowed = append(allowed, dirAbs)
+		}
+	}
+
 	m := &SearchToolManager{
 		tools:      make(map[message.ToolName]message.Tool),
-		workingDir: cfg.WorkingDir,
+		workingDir: absWorkingDir,
+		allowed:    allowed,
+		blacklist:  cfg.FileSystem.BlacklistedFiles,
+	}
+	if !cfg.DisableRipgrep {
+		if rg, err := exec.LookPath("rg"); err == nil {
+			m.rgPath = rg
+		}
 	}
 	m.register()
 	return m
@@ -48,18 +84,18 @@ func (m *SearchToolManager) CallTool(ctx context.Context, name message.ToolName,
 
 func (m *SearchToolManager) register() {
 	// Glob tool: fast file listing by pattern
-	m.RegisterTool("Glob", "Find files by glob pattern (e.g., **/*.go)",
+	m.RegisterTool("Glob", "Find files by glob pattern (e.g., **/*.go); skips files ignored by .gitignore/.ignore and dot directories unless the pattern names one",
 		[]message.ToolArgument{
 			{Name: "pattern", Description: "Glob pattern to match", Required: true, Type: "string"},
 			{Name: "path", Description: "Base directory (optional)", Required: false, Type: "string"},
 		}, m.handleGlob)
 
 	// Grep tool: ripgrep-style content search
-	m.RegisterTool("Grep", "Search file contents using ripgrep-compatible flags",
+	m.RegisterTool("Grep", "Search file contents using ripgrep-compatible flags; skips files ignored by .gitignore/.ignore, dot files and binary files",
 		[]message.ToolArgument{
 			{Name: "pattern", Description: "Regex pattern to search", Required: true, Type: "string"},
 			{Name: "path", Description: "File/dir to search (optional)", Required: false, Type: "string"},
-			{Name: "glob", Description: "Glob filter (maps to --glob)", Required: false, Type: "string"},
+			{Name: "glob", Description: "Glob filter (maps to --glob; prefix with ! to exclude)", Required: false, Type: "string"},
 			{Name: "output_mode", Description: "content|files_with_matches|count", Required: false, Type: "string"},
 			{Name: "-B", Description: "Lines before (content mode)", Required: false, Type: "number"},
 			{Name: "-A", Description: "Lines after (content mode)", Required: false, Type: "number"},
@@ -72,193 +108,184 @@ func (m *SearchToolManager) register() {
 		}, m.handleGrep)
 }
 
-// resolve path relative to working dir
+// resolvePath resolves p against the working directory and checks that it
+// lies inside an allowed directory.
 func (m *SearchToolManager) resolvePath(p string) (string, error) {
 	if p == "" {
 		return m.workingDir, nil
 	}
-	if filepath.IsAbs(p) {
-		return p, nil
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(m.workingDir, p)
 	}
-	return filepath.Abs(filepath.Join(m.workingDir, p))
+	p = filepath.Clean(p)
+	if err := checkPathAllowed(p, m.allowed); err != nil {
+		return "", fmt.Errorf("%s: %w", p, err)
+	}
+	return p, nil
 }
 
-// handleGlob tries rg --files with --glob when available; falls back to find
 func (m *SearchToolManager) handleGlob(ctx context.Context, args message.ToolArgumentValues) (message.ToolResult, error) {
 	pattern, ok := args["pattern"].(string)
-	if !ok {
+	if !ok || pattern == "" {
 		return message.NewToolResultError("pattern parameter is required"), nil
 	}
-	base := m.workingDir
-	if p, ok := args["path"].(string); ok && p != "" {
-		rp, err := m.resolvePath(p)
-		if err != nil {
-			return message.NewToolResultError(fmt.Sprintf("failed to resolve path: %v", err)), nil
-		}
-		base = rp
+	p, _ := args["path"].(string)
+	base, err := m.resolvePath(p)
+	if err != nil {
+		return message.NewToolResultError(fmt.Sprintf("failed to resolve path: %v", err)), nil
 	}
 
-	if _, err := exec.LookPath("rg"); err == nil {
-		// rg --files --glob <pattern>
-		cmd := exec.CommandContext(ctx, "rg", "--files", "--glob", pattern)
-		cmd.Dir = base
-		out, err := cmd.CombinedOutput()
-		if err == nil {
-			files := strings.Split(strings.TrimSpace(string(out)), "\n")
-			// Portable: alphabetic sort
-			sort.Strings(files)
-			var b strings.Builder
-			for _, f := range files {
-				if f == "" {
-					continue
-				}
-				b.WriteString(f)
-				b.WriteString("\n")
-			}
-			return message.NewToolResultText(strings.TrimSuffix(b.String(), "\n")), nil
+	if m.rgPath != "" {
+		rgArgs := []string{"--files", "--glob", pattern}
+		if filesearch.HasHiddenSegment(pattern) {
+			rgArgs = append(rgArgs, "--hidden")
+		}
+		if lines, ok := m.runRipgrep(ctx, base, rgArgs); ok {
+			sort.Strings(lines)
+			return message.NewToolResultText(strings.Join(lines, "\n")), nil
 		}
-		// fall through to find on error
 	}
 
-	// Fallback: find by -name when pattern has a basename; otherwise list all
-	// Note: this is a best-effort portable fallback and may not match ** semantics fully.
-	findArgs := []string{"-type", "f"}
-	if strings.Contains(pattern, "/") {
-		// find supports -name on basename; use -name with last segment
-		segs := strings.Split(pattern, "/")
-		findArgs = append(findArgs, "-name", segs[len(segs)-1])
-	} else {
-		findArgs = append(findArgs, "-name", pattern)
-	}
-	findCallArgs := append([]string{base}, findArgs...)
-	cmd := exec.CommandContext(ctx, "find", findCallArgs...)
-	out, err := cmd.CombinedOutput()
+	files, err := filesearch.Glob(ctx, base, pattern, filesearch.WalkOptions{})
 	if err != nil {
-		return message.NewToolResultError(fmt.Sprintf("find failed: %v\nOutput: %s", err, string(out))), nil
+		return message.NewToolResultError(fmt.Sprintf("glob failed: %v", err)), nil
 	}
-	return message.NewToolResultText(strings.TrimSpace(string(out))), nil
+	return message.NewToolResultText(strings.Join(files, "\n")), nil
 }
 
-// handleGrep executes ripgrep when available; falls back to grep
 func (m *SearchToolManager) handleGrep(ctx context.Context, args message.ToolArgumentValues) (message.ToolResult, error) {
 	pattern, ok := args["pattern"].(string)
-	if !ok {
+	if !ok || pattern == "" {
 		return message.NewToolResultError("pattern parameter is required"), nil
 	}
-
-	base := m.workingDir
-	if p, ok := args["path"].(string); ok && p != "" {
-		rp, err := m.resolvePath(p)
-		if err != nil {
-			return message.NewToolResultError(fmt.Sprintf("failed to resolve path: %v", err)), nil
-		}
-		base = rp
+	p, _ := args["path"].(string)
+	base, err := m.resolvePath(p)
+	if err != nil {
+		return message.NewToolResultError(fmt.Sprintf("failed to resolve path: %v", err)), nil
+	}
+	// Patterns only filter files found by walking; a file named directly has
+	// to be checked here.
+	if err := checkBlacklist(base, m.blacklist); err != nil {
+		return message.NewToolResultError(err.Error()), nil
 	}
 
-	outputMode := "files_with_matches"
+	opts := filesearch.GrepOptions{
+		Pattern: pattern,
+		Mode:    filesearch.ModeFiles,
+		Skip: func(abs string) bool {
+			return checkBlacklist(abs, m.blacklist) != nil
+		},
+	}
 	if om, ok := args["output_mode"].(string); ok && om != "" {
-		outputMode = om
+		opts.Mode = om
 	}
-
-	useRG := true
-	if _, err := exec.LookPath("rg"); err != nil {
-		useRG = false
+	switch opts.Mode {
+	case filesearch.ModeContent, filesearch.ModeFiles, filesearch.ModeCount:
+	default:
+		return message.NewToolResultError(fmt.Sprintf("unknown output_mode %q (use content, files_with_matches or count)", opts.Mode)), nil
+	}
+	if v, ok := numberArg(args, "-C"); ok {
+		opts.Before, opts.After = v, v
+	}
+	if v, ok := numberArg(args, "-B"); ok {
+		opts.Before = v
+	}
+	if v, ok := numberArg(args, "-A"); ok {
+		opts.After = v
 	}
+	opts.LineNumbers, _ = args["-n"].(bool)
+	opts.IgnoreCase, _ = args["-i"].(bool)
+	opts.Multiline, _ = args["multiline"].(bool)
+	opts.Type, _ = args["type"].(string)
+	opts.Glob, _ = args["glob"].(string)
+	headLimit, _ := numberArg(args, "head_limit")
 
-	if useRG {
-		rgArgs := []string{}
-		switch outputMode {
-		case "content":
-			// default: show matches
-		case "files_with_matches":
-			rgArgs = append(rgArgs, "-l")
-		case "count":
-			rgArgs = append(rgArgs, "-c")
-		default:
-			// keep default
+	var lines []string
+	searched := false
+	if m.rgPath != "" {
+		lines, searched = m.runRipgrep(ctx, base, m.ripgrepArgs(opts, base))
+		if searched && opts.Mode != filesearch.ModeContent {
+			sort.Strings(lines)
 		}
-		if v, ok := args["-B"].(float64); ok {
-			rgArgs = append(rgArgs, "-B", fmt.Sprintf("%d", int(v)))
-		}
-		if v, ok := args["-A"].(float64); ok {
-			rgArgs = append(rgArgs, "-A", fmt.Sprintf("%d", int(v)))
-		}
-		if v, ok := args["-C"].(float64); ok {
-			rgArgs = append(rgArgs, "-C", fmt.Sprintf("%d", int(v)))
-		}
-		if v, ok := args["-n"].(bool); ok && v {
-			rgArgs = append(rgArgs, "-n")
-		}
-		if v, ok := args["-i"].(bool); ok && v {
-			rgArgs = append(rgArgs, "-i")
-		}
-		if v, ok := args["type"].(string); ok && v != "" {
-			rgArgs = append(rgArgs, "--type", v)
-		}
-		if v, ok := args["glob"].(string); ok && v != "" {
-			rgArgs = append(rgArgs, "--glob", v)
-		}
-		if v, ok := args["multiline"].(bool); ok && v {
-			rgArgs = append(rgArgs, "-U", "--multiline-dotall")
-		}
-
-		rgArgs = append(rgArgs, pattern)
-		rgArgs = append(rgArgs, base)
-
-		cmd := exec.CommandContext(ctx, "rg", rgArgs...)
-		out, err := cmd.CombinedOutput()
+	}
+	if !searched {
+		lines, err = filesearch.Grep(ctx, base, opts)
 		if err != nil {
-			// non-zero with no matches returns exit 1; handle it as empty
-			if ee, ok := err.(*exec.ExitError); ok && ee.ExitCode() == 1 {
-				return message.NewToolResultText(""), nil
-			}
-			return message.NewToolResultError(fmt.Sprintf("rg failed: %v\nOutput: %s", err, string(out))), nil
-		}
-		text := string(out)
-		// head_limit trim
-		if v, ok := args["head_limit"].(float64); ok && int(v) > 0 {
-			lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
-			if len(lines) > int(v) {
-				text = strings.Join(lines[:int(v)], "\n")
-			}
+			return message.NewToolResultError(fmt.Sprintf("grep failed: %v", err)), nil
 		}
-		return message.NewToolResultText(strings.TrimRight(text, "\n")), nil
 	}
 
-	// Fallback to grep
-	grepArgs := []string{"-R"}
-	if v, ok := args["-i"].(bool); ok && v {
-		grepArgs = append(grepArgs, "-i")
+	if headLimit > 0 && len(lines) > headLimit {
+		lines = lines[:headLimit]
 	}
-	if v, ok := args["-n"].(bool); ok && v {
-		grepArgs = append(grepArgs, "-n")
+	return message.NewToolResultText(strings.Join(lines, "\n")), nil
+}
+
+// ripgrepArgs translates opts for rg. The blacklist becomes exclusion
+// globs, matched at any depth so rg never skips less than the in-process
+// engine would.
+func (m *SearchToolManager) ripgrepArgs(opts filesearch.GrepOptions, base string) []string {
+	var rgArgs []string
+	switch opts.Mode {
+	case filesearch.ModeFiles:
+		rgArgs = append(rgArgs, "-l")
+	case filesearch.ModeCount:
+		rgArgs = append(rgArgs, "-c")
 	}
-	if v, ok := args["-B"].(float64); ok {
-		grepArgs = append(grepArgs, "-B", fmt.Sprintf("%d", int(v)))
+	if opts.Before > 0 {
+		rgArgs = append(rgArgs, "-B", strconv.Itoa(opts.Before))
 	}
-	if v, ok := args["-A"].(float64); ok {
-		grepArgs = append(grepArgs, "-A", fmt.Sprintf("%d", int(v)))
+	if opts.After > 0 {
+		rgArgs = append(rgArgs, "-A", strconv.Itoa(opts.After))
 	}
-	if v, ok := args["-C"].(float64); ok {
-		grepArgs = append(grepArgs, "-C", fmt.Sprintf("%d", int(v)))
+	if opts.LineNumbers {
+		rgArgs = append(rgArgs, "-n")
 	}
-	grepArgs = append(grepArgs, "-E", pattern, base)
-	cmd := exec.CommandContext(ctx, "grep", grepArgs...)
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		if ee, ok := err.(*exec.ExitError); ok && ee.ExitCode() == 1 {
-			return message.NewToolResultText(""), nil
+	if opts.IgnoreCase {
+		rgArgs = append(rgArgs, "-i")
+	}
+	if opts.Type != "" {
+		rgArgs = append(rgArgs, "--type", opts.Type)
+	}
+	if opts.Glob != "" {
+		rgArgs = append(rgArgs, "--glob", opts.Glob)
+	}
+	for _, pat := range m.blacklist {
+		if strings.Contains(pat, "/") {
+			pat = "**/" + strings.TrimPrefix(pat, "/")
 		}
-		return message.NewToolResultError(fmt.Sprintf("grep failed: %v\nOutput: %s", err, string(out))), nil
+		rgArgs = append(rgArgs, "--glob", "!"+pat)
+	}
+	if opts.Multiline {
+		rgArgs = append(rgArgs, "-U", "--multiline-dotall")
 	}
-	text := string(out)
-	if v, ok := args["head_limit"].(float64); ok && int(v) > 0 {
-		lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
-		if len(lines) > int(v) {
-			text = strings.Join(lines[:int(v)], "\n")
+	return append(rgArgs, "--no-config", "-e", opts.Pattern, "--", base)
+}
+
+// runRipgrep runs rg in dir and returns its output lines. ok is false when
+// rg failed for any reason other than finding nothing (exit status 1), so
+// the caller can run the search in-process instead.
+func (m *SearchToolManager) runRipgrep(ctx context.Context, dir string, rgArgs []string) (lines []string, ok bool) {
+	cmd := exec.CommandContext(ctx, m.rgPath, rgArgs...)
+	if info, err := os.Stat(dir); err == nil && info.IsDir() {
+		cmd.Dir = dir
+	} else {
+		cmd.Dir = filepath.Dir(dir)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		var ee *exec.ExitError
+		if errors.As(err, &ee) && ee.ExitCode() == 1 {
+			return nil, true
 		}
+		logger.Debug("ripgrep failed, searching in-process", "error", err)
+		return nil, false
+	}
+	text := strings.TrimRight(string(out), "\n")
+	if text == "" {
+		return nil, true
 	}
-	return message.NewToolResultText(strings.TrimRight(text, "\n")), nil
+	return strings.Split(text, "\n"), true
 }
 
 type searchTool struct {
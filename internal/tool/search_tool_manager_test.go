@@ -0,0 +1,125 @@
+package tool
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/fpt/klein-cli/internal/repository"
+	"github.com/fpt/klein-cli/pkg/message"
+)
+
+func newTestSearchManager(t *testing.T, files map[string]string) (*SearchToolManager, string) {
+	t.Helper()
+	dir := t.TempDir()
+	for rel, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m := NewSearchToolManager(SearchConfig{
+		WorkingDir:     dir,
+		FileSystem:     repository.FileSystemConfig{BlacklistedFiles: []string{".env", "*.pem"}},
+		DisableRipgrep: true,
+	}).(*SearchToolManager)
+	return m, dir
+}
+
+func callSearch(t *testing.T, m *SearchToolManager, name string, args message.ToolArgumentValues) message.ToolResult {
+	t.Helper()
+	res, err := m.CallTool(context.Background(), message.ToolName(name), args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestSearchToolsWithoutRipgrep(t *testing.T) {
+	m, dir := newTestSearchManager(t, map[string]string{
+		".gitignore":       "dist/\n",
+		"main.go":          "package main\n// secret handling\n",
+		"util/util.go":     "package util\n",
+		"dist/bundle.js":   "secret\n",
+		"server.pem":       "secret\n",
+		"config/app.toml":  "secret = 1\n",
+		"config/more.toml": "secret = 2\n",
+	})
+
+	res := callSearch(t, m, "Glob", message.ToolArgumentValues{"pattern": "**/*.go"})
+	if res.Error != "" || res.Text != "main.go\nutil/util.go" {
+		t.Errorf("Glob = %q (err %q)", res.Text, res.Error)
+	}
+
+	res = callSearch(t, m, "Grep", message.ToolArgumentValues{"pattern": "secret"})
+	want := strings.Join([]string{
+		filepath.Join(dir, "config", "app.toml"),
+		filepath.Join(dir, "config", "more.toml"),
+		filepath.Join(dir, "main.go"),
+	}, "\n")
+	if res.Text != want {
+		t.Errorf("Grep skipped the wrong files:\n%s\nwant\n%s", res.Text, want)
+	}
+
+	res = callSearch(t, m, "Grep", message.ToolArgumentValues{"pattern": "secret", "output_mode": "content", "-n": true, "head_limit": float64(1)})
+	if res.Text != filepath.Join(dir, "config", "app.toml")+":1:secret = 1" {
+		t.Errorf("head_limit content = %q", res.Text)
+	}
+
+	res = callSearch(t, m, "Grep", message.ToolArgumentValues{"pattern": "secret", "path": "server.pem"})
+	if !strings.Contains(res.Error, "blacklisted") {
+		t.Errorf("a blacklisted file named directly was searched: %+v", res)
+	}
+
+	res = callSearch(t, m, "Grep", message.ToolArgumentValues{"pattern": "secret", "path": filepath.Dir(dir)})
+	if !strings.Contains(res.Error, "not within allowed directories") {
+		t.Errorf("a search outside the allowed directories ran: %+v", res)
+	}
+	res = callSearch(t, m, "Glob", message.ToolArgumentValues{"pattern": "*", "path": "../.."})
+	if res.Error == "" {
+		t.Errorf("Glob escaped the working directory: %q", res.Text)
+	}
+
+	res = callSearch(t, m, "Grep", message.ToolArgumentValues{"pattern": "x", "output_mode": "lines"})
+	if res.Error == "" {
+		t.Error("an unknown output_mode was accepted")
+	}
+}
+
+func TestGrepFallsBackWhenRipgrepFails(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script stand-in for rg")
+	}
+	m, dir := newTestSearchManager(t, map[string]string{"a.txt": "needle\n"})
+
+	// A stand-in rg that records its arguments and fails like a broken install.
+	argsFile := filepath.Join(t.TempDir(), "args")
+	rg := filepath.Join(t.TempDir(), "rg")
+	script := "#!/bin/sh\nprintf '%s\\n' \"$@\" > " + argsFile + "\nexit 2\n"
+	if err := os.WriteFile(rg, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	m.rgPath = rg
+
+	res := callSearch(t, m, "Grep", message.ToolArgumentValues{"pattern": "needle", "-i": true})
+	if res.Text != filepath.Join(dir, "a.txt") {
+		t.Errorf("fallback result = %q (err %q)", res.Text, res.Error)
+	}
+
+	raw, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatalf("rg was not tried: %v", err)
+	}
+	got := string(raw)
+	for _, want := range []string{"-l\n", "-i\n", "--glob\n!.env\n", "--glob\n!*.pem\n", "-e\nneedle\n--\n" + dir + "\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("rg args missing %q:\n%s", want, got)
+		}
+	}
+}
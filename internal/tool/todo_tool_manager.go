@@ -3,6 +3,7 @@ package tool
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"time"
@@ -260,29 +261,82 @@ func (m *TodoToolManager) handleTodoWrite(ctx context.Context, args message.Tool
 		return message.NewToolResultError("todos parameter must be a JSON array or array of objects"), nil
 	}
 
+	todoItems, err := m.replace(todoItems)
+	if err != nil {
+		return message.NewToolResultError(err.Error()), nil
+	}
+
+	// Generate summary response
+	statusCounts := make(map[string]int)
+	priorityCounts := make(map[string]int)
+
+	for _, item := range todoItems {
+		statusCounts[item.Status]++
+		priorityCounts[item.Priority]++
+	}
+
+	summary := fmt.Sprintf("Successfully updated todo list with %d items:\n", len(todoItems))
+	summary += fmt.Sprintf("- Status: %d pending, %d in_progress, %d done\n",
+		statusCounts["pending"], statusCounts["in_progress"], statusCounts["done"])
+	summary += fmt.Sprintf("- Priority: %d high, %d medium, %d low",
+		priorityCounts["high"], priorityCounts["medium"], priorityCounts["low"])
+
+	return message.NewToolResultText(summary), nil
+}
+
+// Items returns a copy of the current todo list.
+func (m *TodoToolManager) Items() []TodoItem {
+	return append([]TodoItem(nil), m.todoRepository.GetItems()...)
+}
+
+// ReplaceItems replaces the whole todo list from outside the model (an editor's
+// todo panel), under the same rules TodoWrite enforces. Missing timestamps are
+// filled in; an item keeps the Created time of the item it replaces.
+func (m *TodoToolManager) ReplaceItems(items []TodoItem) ([]TodoItem, error) {
+	now := time.Now().Format(time.RFC3339)
+	created := make(map[string]string)
+	for _, it := range m.todoRepository.GetItems() {
+		created[it.ID] = it.Created
+	}
+	stamped := make([]TodoItem, len(items))
+	for i, it := range items {
+		if it.Created == "" {
+			it.Created = created[it.ID]
+		}
+		if it.Created == "" {
+			it.Created = now
+		}
+		it.Updated = now
+		stamped[i] = it
+	}
+	return m.replace(stamped)
+}
+
+// replace validates and normalizes items, then stores and saves them.
+func (m *TodoToolManager) replace(todoItems []TodoItem) ([]TodoItem, error) {
 	// Enforce maximum of 5 todos for focus and clarity
 	if len(todoItems) > 5 {
-		return message.NewToolResultError("Too many todo items. Please limit to 5 items or fewer for better focus and management."), nil
+		return nil, errors.New("Too many todo items. Please limit to 5 items or fewer for better focus and management.")
 	}
 
 	// Normalize and validate todo items
 	inProgressCount := 0
 	for i, item := range todoItems {
 		if item.ID == "" || item.Content == "" {
-			return message.NewToolResultError("all todo items must have id and content"), nil
+			return nil, errors.New("all todo items must have id and content")
 		}
 		// Normalize status: map 'done' -> 'completed'
 		if item.Status == "done" {
 			item.Status = "completed"
 		}
 		if item.Status != "pending" && item.Status != "in_progress" && item.Status != "completed" {
-			return message.NewToolResultError(fmt.Sprintf("invalid status '%s', must be pending, in_progress, or completed", item.Status)), nil
+			return nil, fmt.Errorf("invalid status '%s', must be pending, in_progress, or completed", item.Status)
 		}
 		if item.Status == "in_progress" {
 			inProgressCount++
 		}
 		if item.Priority != "high" && item.Priority != "medium" && item.Priority != "low" {
-			return message.NewToolResultError(fmt.Sprintf("invalid priority '%s', must be high, medium, or low", item.Priority)), nil
+			return nil, fmt.Errorf("invalid priority '%s', must be high, medium, or low", item.Priority)
 		}
 		// write-back normalization
 		todoItems[i] = item
@@ -290,7 +344,7 @@ func (m *TodoToolManager) handleTodoWrite(ctx context.Context, args message.Tool
 
 	// Enforce at most one in_progress
 	if inProgressCount > 1 {
-		return message.NewToolResultError("Only one todo may be 'in_progress' at a time. Please adjust statuses and try again."), nil
+		return nil, errors.New("Only one todo may be 'in_progress' at a time. Please adjust statuses and try again.")
 	}
 
 	// Update todo repository
@@ -299,25 +353,9 @@ func (m *TodoToolManager) handleTodoWrite(ctx context.Context, args message.Tool
 
 	// Save to repository
 	if err := m.todoRepository.Save(); err != nil {
-		return message.NewToolResultError(fmt.Sprintf("failed to save todos: %v", err)), nil
+		return nil, fmt.Errorf("failed to save todos: %v", err)
 	}
-
-	// Generate summary response
-	statusCounts := make(map[string]int)
-	priorityCounts := make(map[string]int)
-
-	for _, item := range todoItems {
-		statusCounts[item.Status]++
-		priorityCounts[item.Priority]++
-	}
-
-	summary := fmt.Sprintf("Successfully updated todo list with %d items:\n", len(todoItems))
-	summary += fmt.Sprintf("- Status: %d pending, %d in_progress, %d done\n",
-		statusCounts["pending"], statusCounts["in_progress"], statusCounts["done"])
-	summary += fmt.Sprintf("- Priority: %d high, %d medium, %d low",
-		priorityCounts["high"], priorityCounts["medium"], priorityCounts["low"])
-
-	return message.NewToolResultText(summary), nil
+	return todoItems, nil
 }
 
 // GetTodosForPrompt returns formatted todos for injection into prompt context
@@ -0,0 +1,60 @@
+package tool
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTodoReplaceItems(t *testing.T) {
+	m := NewInMemoryTodoToolManager()
+	first, err := m.ReplaceItems([]TodoItem{
+		{ID: "1", Content: "write parser", Status: "in_progress", Priority: "high"},
+	})
+	if err != nil {
+		t.Fatalf("ReplaceItems: %v", err)
+	}
+	if first[0].Created == "" || first[0].Updated == "" {
+		t.Errorf("timestamps not filled: %+v", first[0])
+	}
+
+	second, err := m.ReplaceItems([]TodoItem{
+		{ID: "1", Content: "write parser", Status: "done", Priority: "high"},
+		{ID: "2", Content: "add tests", Status: "pending", Priority: "medium"},
+	})
+	if err != nil {
+		t.Fatalf("ReplaceItems: %v", err)
+	}
+	if second[0].Created != first[0].Created {
+		t.Errorf("item 1 should keep its Created time, got %q want %q", second[0].Created, first[0].Created)
+	}
+	if second[0].Status != "completed" {
+		t.Errorf("done should normalize to completed, got %q", second[0].Status)
+	}
+	if got := m.Items(); len(got) != 2 || got[1].ID != "2" {
+		t.Errorf("Items() = %+v", got)
+	}
+	if !strings.Contains(m.GetTodosForPrompt(), "add tests") {
+		t.Error("the prompt should show the replaced list")
+	}
+}
+
+func TestTodoReplaceItems_Validates(t *testing.T) {
+	m := NewInMemoryTodoToolManager()
+	cases := map[string][]TodoItem{
+		"status":   {{ID: "1", Content: "x", Priority: "low"}},
+		"priority": {{ID: "1", Content: "x", Status: "pending"}},
+		"id":       {{Content: "x", Status: "pending", Priority: "low"}},
+		"in_progress": {
+			{ID: "1", Content: "a", Status: "in_progress", Priority: "low"},
+			{ID: "2", Content: "b", Status: "in_progress", Priority: "low"},
+		},
+	}
+	for name, items := range cases {
+		if _, err := m.ReplaceItems(items); err == nil {
+			t.Errorf("%s: expected a validation error", name)
+		}
+	}
+	if len(m.Items()) != 0 {
+		t.Errorf("a rejected list must not be stored: %+v", m.Items())
+	}
+}
@@ -31,6 +31,7 @@ var defaultCoreTools = map[message.ToolName]bool{
 type DeferredToolManager struct {
 	source domain.ToolManager
 	core   map[message.ToolName]bool
+	denied map[message.ToolName]bool // hidden everywhere, ToolSearch included
 
 	mu     sync.Mutex
 	active map[message.ToolName]bool // loaded via ToolSearch (persists for the manager's life)
@@ -68,8 +69,25 @@ func (d *DeferredToolManager) SetCore(names []string) {
 	d.core = core
 }
 
+// SetDenied sets the tools this view refuses outright — typically a
+// definition's disallowedTools. Unlike names outside the core, a denied tool is
+// neither exposed nor searchable nor callable. An empty list clears the denial.
+// Same concurrency contract as SetCore.
+func (d *DeferredToolManager) SetDenied(names []string) {
+	denied := make(map[message.ToolName]bool, len(names))
+	for _, n := range names {
+		if n = strings.TrimSpace(n); n != "" {
+			denied[message.ToolName(n)] = true
+		}
+	}
+	d.denied = denied
+}
+
 // isExposed reports whether a tool is currently visible to the model.
 func (d *DeferredToolManager) isExposed(name message.ToolName) bool {
+	if d.denied[name] {
+		return false
+	}
 	if name == ToolSearchName || d.core[name] {
 		return true
 	}
@@ -96,6 +114,9 @@ func (d *DeferredToolManager) GetTool(name message.ToolName) (message.Tool, bool
 	if name == ToolSearchName {
 		return d.searchTool, true
 	}
+	if d.denied[name] {
+		return nil, false
+	}
 	t, ok := d.source.GetTools()[name]
 	return t, ok
 }
@@ -104,6 +125,9 @@ func (d *DeferredToolManager) CallTool(ctx context.Context, name message.ToolNam
 	if name == ToolSearchName {
 		return d.handleSearch(args), nil
 	}
+	if d.denied[name] {
+		return message.NewToolResultError(fmt.Sprintf("tool '%s' is disallowed by the active skill", name)), nil
+	}
 	return d.source.CallTool(ctx, name, args)
 }
 
@@ -122,7 +146,7 @@ type toolInfo struct {
 func (d *DeferredToolManager) deferredCatalog() []toolInfo {
 	var out []toolInfo
 	for name, t := range d.source.GetTools() {
-		if name == ToolSearchName || d.core[name] {
+		if name == ToolSearchName || d.core[name] || d.denied[name] {
 			continue
 		}
 		out = append(out, toolInfo{name: name, desc: t.Description().String()})
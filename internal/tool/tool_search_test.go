@@ -149,6 +149,36 @@ func TestDeferredSetCore(t *testing.T) {
 	}
 }
 
+func TestDeferredSetDenied(t *testing.T) {
+	d := newTestDeferred()
+	d.SetDenied([]string{"Bash", "MarketQuote"})
+
+	tools := d.GetTools()
+	if _, ok := tools["Bash"]; ok {
+		t.Error("denied core tool Bash should not be exposed")
+	}
+	if _, ok := tools["Read"]; !ok {
+		t.Error("Read should stay exposed")
+	}
+	// A denied deferred tool cannot be loaded through ToolSearch either.
+	_, _ = d.CallTool(context.Background(), ToolSearchName, message.ToolArgumentValues{"query": "select:MarketQuote"})
+	if _, ok := d.GetTools()["MarketQuote"]; ok {
+		t.Error("denied MarketQuote should not be loadable via ToolSearch")
+	}
+	if strings.Contains(d.CatalogHint(), "MarketQuote") {
+		t.Error("denied tool should not be advertised in the catalog hint")
+	}
+	res, _ := d.CallTool(context.Background(), "Bash", nil)
+	if res.Error == "" || !strings.Contains(res.Error, "disallowed") {
+		t.Errorf("calling a denied tool should fail, got text %q err %q", res.Text, res.Error)
+	}
+
+	d.SetDenied(nil)
+	if _, ok := d.GetTools()["Bash"]; !ok {
+		t.Error("empty SetDenied should lift the denial")
+	}
+}
+
 func TestCatalogHintGroupsMCP(t *testing.T) {
 	src := newFakeManager(
 		fakeTool{"Read", "Read a file"}, // core
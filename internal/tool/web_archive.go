@@ -0,0 +1,118 @@
+package tool
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/fpt/klein-cli/internal/webarchive"
+	"github.com/fpt/klein-cli/pkg/message"
+)
+
+// snapshotIDLength is how much of a snapshot ID the tools show; WebArchiveGet
+// accepts any unique prefix of webarchive.MinIDPrefix or more.
+const snapshotIDLength = 12
+
+// shortSnapshotID returns the displayed prefix of a snapshot ID.
+func shortSnapshotID(id string) string {
+	if len(id) > snapshotIDLength {
+		return id[:snapshotIDLength]
+	}
+	return id
+}
+
+// archiveNote is the line WebFetch appends to name the snapshot it read, or
+// "" when nothing was archived.
+func archiveNote(snap *webarchive.Snapshot) string {
+	if snap == nil {
+		return ""
+	}
+	return fmt.Sprintf("\n\nArchived as snapshot %s (fetched %s). Cite it, or reread this exact version with WebArchiveGet.",
+		shortSnapshotID(snap.ID), snap.FetchedAt.Format(time.RFC3339))
+}
+
+// handleWebArchiveGet returns an archived snapshot, by ID or as the latest of
+// a URL's.
+func (m *WebToolManager) handleWebArchiveGet(ctx context.Context, args message.ToolArgumentValues) (message.ToolResult, error) {
+	archive := webarchive.FromContext(ctx)
+	if archive == nil {
+		return message.NewToolResultError("the web archive is off (web.disable_archive); fetch the live page with WebFetch"), nil
+	}
+	id, _ := args["id"].(string)
+	urlStr, _ := args["url"].(string)
+
+	var out strings.Builder
+	var snap *webarchive.Snapshot
+	switch {
+	case strings.TrimSpace(id) != "":
+		found, err := archive.Lookup(id)
+		if err != nil {
+			return message.NewToolResultError(err.Error()), nil
+		}
+		snap = found
+	case strings.TrimSpace(urlStr) != "":
+		history, err := archive.History(strings.TrimSpace(urlStr))
+		if err != nil {
+			return message.NewToolResultError(err.Error()), nil
+		}
+		if len(history) == 0 {
+			return message.NewToolResultError(fmt.Sprintf("no archived snapshots of %s; fetch it with WebFetch first", urlStr)), nil
+		}
+		fmt.Fprintf(&out, "Archived snapshots of %s (oldest first):\n", urlStr)
+		for _, h := range history {
+			fmt.Fprintf(&out, "- %s fetched %s, last validated %s, %d bytes\n",
+				shortSnapshotID(h.ID), h.FetchedAt.Format(time.RFC3339), h.ValidatedAt.Format(time.RFC3339), h.Size)
+		}
+		out.WriteString("\nLatest:\n")
+		snap = &history[len(history)-1]
+	default:
+		return message.NewToolResultError("give a snapshot id or a url"), nil
+	}
+
+	body, err := archive.Body(snap.ID)
+	if err != nil {
+		return message.NewToolResultError(fmt.Sprintf("failed to read snapshot %s: %v", shortSnapshotID(snap.ID), err)), nil
+	}
+	fmt.Fprintf(&out, "# Snapshot %s\nURL: %s\n", snap.ID, snap.URL)
+	if snap.FinalURL != "" && snap.FinalURL != snap.URL {
+		fmt.Fprintf(&out, "Final URL: %s\n", snap.FinalURL)
+	}
+	fmt.Fprintf(&out, "Fetched: %s\n", snap.FetchedAt.Format(time.RFC3339))
+	if ct := snap.ContentType(); ct != "" {
+		fmt.Fprintf(&out, "Content-Type: %s\n", ct)
+	}
+	if snap.Truncated {
+		fmt.Fprintf(&out, "Truncated: only the first %d bytes were kept\n", snap.Size)
+	}
+	out.WriteString("\n")
+
+	source := snap.FinalURL
+	if source == "" {
+		source = snap.URL
+	}
+	ct := strings.ToLower(snap.ContentType())
+	switch {
+	case strings.Contains(ct, "pdf") || (ct == "" && isPDFURL(source)):
+		path, err := savePDFTemp(source, body)
+		if err != nil {
+			return message.NewToolResultError(err.Error()), nil
+		}
+		fmt.Fprintf(&out, "PDF (%dKB) saved to: %s\nUse PDFInfo and PDFRead tools with this file path to extract content.", len(body)/1024, path)
+	case ct == "" || strings.Contains(ct, "html"):
+		doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+		if err != nil {
+			return message.NewToolResultError(fmt.Sprintf("failed to parse HTML: %v", err)), nil
+		}
+		baseURL, _ := url.Parse(source)
+		out.WriteString(m.convertToMarkdown(doc, baseURL))
+	case strings.HasPrefix(ct, "text/") || strings.Contains(ct, "xml") || strings.Contains(ct, "json"):
+		out.Write(body)
+	default:
+		fmt.Fprintf(&out, "Binary content (%d bytes) is not shown.", len(body))
+	}
+	return message.NewToolResultText(out.String()), nil
+}
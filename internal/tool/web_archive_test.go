@@ -0,0 +1,52 @@
+package tool
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/fpt/klein-cli/internal/webarchive"
+	"github.com/fpt/klein-cli/internal/webfetch"
+	"github.com/fpt/klein-cli/pkg/message"
+)
+
+func TestWebArchiveGet(t *testing.T) {
+	page := "<html><head><title>Rates</title></head><body><p>The bank held rates at 0.5%.</p></body></html>"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(page))
+	}))
+	defer srv.Close()
+
+	web := NewWebToolManager()
+	ctx := webfetch.WithPolicy(context.Background(), webfetch.Policy{AllowPrivateNetworks: true})
+	if res, _ := web.CallTool(ctx, "WebArchiveGet", message.ToolArgumentValues{"url": srv.URL}); res.Error == "" {
+		t.Error("WebArchiveGet without an archive succeeded")
+	}
+
+	ctx = webarchive.WithArchive(ctx, webarchive.New(t.TempDir()))
+	res, err := web.CallTool(ctx, "WebFetch", message.ToolArgumentValues{"url": srv.URL, "mode": "full"})
+	if err != nil || res.Error != "" {
+		t.Fatalf("WebFetch = %+v, %v", res, err)
+	}
+	m := regexp.MustCompile(`Archived as snapshot ([0-9a-f]{12})`).FindStringSubmatch(res.Text)
+	if m == nil {
+		t.Fatalf("WebFetch did not name its snapshot:\n%s", res.Text)
+	}
+
+	// The page changes; the snapshot still reads as it was.
+	page = strings.Replace(page, "held", "raised", 1)
+	res, _ = web.CallTool(ctx, "WebArchiveGet", message.ToolArgumentValues{"id": m[1]})
+	if res.Error != "" || !strings.Contains(res.Text, "held rates") || !strings.Contains(res.Text, srv.URL) {
+		t.Errorf("WebArchiveGet(id) = %+v", res)
+	}
+
+	web.CallTool(ctx, "WebFetch", message.ToolArgumentValues{"url": srv.URL})
+	res, _ = web.CallTool(ctx, "WebArchiveGet", message.ToolArgumentValues{"url": srv.URL})
+	if res.Error != "" || strings.Count(res.Text, "\n- ") != 2 || !strings.Contains(res.Text, "raised rates") {
+		t.Errorf("WebArchiveGet(url) = %+v", res)
+	}
+}
@@ -9,7 +9,6 @@ import (
 	"image/draw"
 	"image/jpeg"
 	_ "image/png" // register PNG decoder
-	"io"
 	"net/http"
 	"net/url"
 	"os"
@@ -20,6 +19,8 @@ import (
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
+	"github.com/fpt/klein-cli/internal/webarchive"
+	"github.com/fpt/klein-cli/internal/webfetch"
 	"github.com/fpt/klein-cli/pkg/agent/domain"
 	"github.com/fpt/klein-cli/pkg/message"
 )
@@ -62,6 +63,15 @@ func (m *WebToolManager) registerWebTools() {
 		},
 		m.handleFetchWebBlock)
 
+	// WebArchiveGet — reread an archived snapshot instead of the live page.
+	m.RegisterTool("WebArchiveGet",
+		"Read a page or PDF as archived when WebFetch or a research tool fetched it. Give the snapshot ID they reported (8+ leading characters suffice) to cite or reread exactly that version, or a URL to list its archived snapshots and read the latest.",
+		[]message.ToolArgument{
+			{Name: "id", Description: "Snapshot ID, or a unique prefix of at least 8 characters", Required: false, Type: "string"},
+			{Name: "url", Description: "URL whose snapshots to list; the latest is returned", Required: false, Type: "string"},
+		},
+		m.handleWebArchiveGet)
+
 	// WebSearch (stub): declare interface compatibility; return informative message
 	m.RegisterTool("WebSearch", "Search the web (stub). Not implemented in this build. Provide URLs or use WebFetch with a concrete link.",
 		[]message.ToolArgument{
@@ -100,49 +110,53 @@ func (m *WebToolManager) RegisterTool(name message.ToolName, description message
 	}
 }
 
-// fetchAndParse fetches a URL and returns the parsed goquery document and parsed URL.
-func (m *WebToolManager) fetchAndParse(ctx context.Context, urlStr string) (*goquery.Document, *url.URL, error) {
+// fetchAndParse fetches a URL and returns the parsed goquery document, the
+// parsed URL and the archived snapshot (nil when the archive is off).
+func (m *WebToolManager) fetchAndParse(ctx context.Context, urlStr string) (*goquery.Document, *url.URL, *webarchive.Snapshot, error) {
 	parsedURL, err := url.Parse(urlStr)
 	if err != nil {
-		return nil, nil, fmt.Errorf("invalid URL format: %v", err)
+		return nil, nil, nil, fmt.Errorf("invalid URL format: %v", err)
 	}
 	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
-		return nil, nil, fmt.Errorf("invalid URL scheme: must be http or https")
-	}
-
-	client := &http.Client{Timeout: 30 * time.Second}
-	req, err := http.NewRequestWithContext(ctx, "GET", urlStr, nil)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to create request: %v", err)
+		return nil, nil, nil, fmt.Errorf("invalid URL scheme: must be http or https")
 	}
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Compatible Web Fetcher Bot)")
-	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
-	req.Header.Set("Accept-Language", "en-US,en;q=0.5")
 
-	resp, err := client.Do(req)
+	// The cap is the PDF one: a page can turn out to be a PDF, which the
+	// caller then downloads.
+	resp, snap, err := webarchive.Get(ctx, webfetch.Request{
+		URL: urlStr,
+		Header: http.Header{
+			"User-Agent":      {webUserAgent},
+			"Accept":          {"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"},
+			"Accept-Language": {"en-US,en;q=0.5"},
+		},
+		MaxBytes: maxPDFBytes,
+		Timeout:  30 * time.Second,
+	})
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to fetch webpage: %v", err)
+		return nil, nil, nil, fmt.Errorf("failed to fetch webpage: %w", err)
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, nil, fmt.Errorf("HTTP error %d: %s", resp.StatusCode, resp.Status)
+		return nil, nil, nil, fmt.Errorf("HTTP error %d: %s", resp.StatusCode, resp.Status)
 	}
 
 	// Reject non-text content types (images are handled separately in handleFetchWeb)
-	ct := resp.Header.Get("Content-Type")
+	ct := resp.ContentType()
 	if ct != "" && !strings.HasPrefix(ct, "text/") && !strings.Contains(ct, "html") && !strings.Contains(ct, "xml") && !strings.Contains(ct, "json") {
-		return nil, nil, fmt.Errorf("unsupported content type %q — WebFetch only handles HTML/text pages directly; binary content (PDF, images) is handled automatically by URL or content type detection", ct)
+		return nil, nil, nil, fmt.Errorf("unsupported content type %q — WebFetch only handles HTML/text pages directly; binary content (PDF, images) is handled automatically by URL or content type detection", ct)
 	}
 
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(resp.Body))
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to parse HTML: %v", err)
+		return nil, nil, nil, fmt.Errorf("failed to parse HTML: %v", err)
 	}
-	return doc, parsedURL, nil
+	return doc, parsedURL, snap, nil
 }
 
 const (
+	webUserAgent   = "Mozilla/5.0 (Compatible Web Fetcher Bot)"
+	maxPDFBytes    = 50 * 1024 * 1024 // 50MB download limit
 	MaxImageBytes  = 20 * 1024 * 1024 // 20MB download limit
 	MaxImageDim    = 512              // resize to fit within 512x512
 	MaxJPEGQuality = 80
@@ -151,36 +165,26 @@ const (
 // fetchImage downloads an image URL, resizes it to fit within MaxImageDim, and
 // returns it as a base64-encoded JPEG to keep context size small.
 func (m *WebToolManager) fetchImage(ctx context.Context, urlStr string) (base64Data string, contentType string, size int, err error) {
-	client := &http.Client{Timeout: 30 * time.Second}
-	req, err := http.NewRequestWithContext(ctx, "GET", urlStr, nil)
-	if err != nil {
-		return "", "", 0, fmt.Errorf("failed to create request: %v", err)
-	}
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Compatible Web Fetcher Bot)")
-
-	resp, err := client.Do(req)
+	resp, err := webfetch.Get(ctx, webfetch.Request{
+		URL:      urlStr,
+		Header:   http.Header{"User-Agent": {webUserAgent}},
+		MaxBytes: MaxImageBytes,
+		Timeout:  30 * time.Second,
+	})
 	if err != nil {
-		return "", "", 0, fmt.Errorf("failed to fetch image: %v", err)
+		return "", "", 0, fmt.Errorf("failed to fetch image: %w", err)
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		return "", "", 0, fmt.Errorf("HTTP error %d: %s", resp.StatusCode, resp.Status)
 	}
-
-	data, err := io.ReadAll(io.LimitReader(resp.Body, MaxImageBytes+1))
-	if err != nil {
-		return "", "", 0, fmt.Errorf("failed to read image: %v", err)
-	}
-	if len(data) > MaxImageBytes {
-		return "", "", 0, fmt.Errorf("image exceeds %dMB size limit", MaxImageBytes/1024/1024)
-	}
+	data := resp.Body
 
 	// Decode, resize, re-encode as JPEG to save context tokens
 	resized, err := ResizeImageToJPEG(data, MaxImageDim, MaxJPEGQuality)
 	if err != nil {
 		// Fallback: return raw data if decode/resize fails (e.g. SVG, GIF animation)
-		ct := resp.Header.Get("Content-Type")
+		ct := resp.ContentType()
 		if ct == "" {
 			ct = "image/jpeg"
 		}
@@ -263,34 +267,32 @@ func isPDFURL(urlStr string) bool {
 }
 
 // fetchPDF downloads a PDF from a URL and saves it to a temporary file.
-// Returns the local file path where the PDF was saved.
-func (m *WebToolManager) fetchPDF(ctx context.Context, urlStr string) (string, int, error) {
-	client := &http.Client{Timeout: 60 * time.Second}
-	req, err := http.NewRequestWithContext(ctx, "GET", urlStr, nil)
-	if err != nil {
-		return "", 0, fmt.Errorf("failed to create request: %v", err)
-	}
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Compatible Web Fetcher Bot)")
-
-	resp, err := client.Do(req)
+// Returns the local file path where the PDF was saved and the archived
+// snapshot (nil when the archive is off).
+func (m *WebToolManager) fetchPDF(ctx context.Context, urlStr string) (string, int, *webarchive.Snapshot, error) {
+	resp, snap, err := webarchive.Get(ctx, webfetch.Request{
+		URL:      urlStr,
+		Header:   http.Header{"User-Agent": {webUserAgent}},
+		MaxBytes: maxPDFBytes,
+		Timeout:  60 * time.Second,
+	})
 	if err != nil {
-		return "", 0, fmt.Errorf("failed to fetch PDF: %v", err)
+		return "", 0, nil, fmt.Errorf("failed to fetch PDF: %w", err)
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", 0, fmt.Errorf("HTTP error %d: %s", resp.StatusCode, resp.Status)
+		return "", 0, nil, fmt.Errorf("HTTP error %d: %s", resp.StatusCode, resp.Status)
 	}
-
-	const maxPDFBytes = 50 * 1024 * 1024 // 50MB limit
-	data, err := io.ReadAll(io.LimitReader(resp.Body, maxPDFBytes+1))
+	path, err := savePDFTemp(urlStr, resp.Body)
 	if err != nil {
-		return "", 0, fmt.Errorf("failed to read PDF: %v", err)
-	}
-	if len(data) > maxPDFBytes {
-		return "", 0, fmt.Errorf("PDF exceeds %dMB size limit", maxPDFBytes/1024/1024)
+		return "", 0, nil, err
 	}
+	return path, len(resp.Body), snap, nil
+}
 
+// savePDFTemp writes a PDF fetched from urlStr to a temporary file named
+// after it, for PDFInfo and PDFRead.
+func savePDFTemp(urlStr string, data []byte) (string, error) {
 	// Extract filename from URL for a meaningful temp file name
 	parsedURL, _ := url.Parse(urlStr)
 	baseName := "download.pdf"
@@ -305,16 +307,16 @@ func (m *WebToolManager) fetchPDF(ctx context.Context, urlStr string) (string, i
 
 	tmpFile, err := os.CreateTemp("", "klein-pdf-*-"+baseName)
 	if err != nil {
-		return "", 0, fmt.Errorf("failed to create temp file: %v", err)
+		return "", fmt.Errorf("failed to create temp file: %v", err)
 	}
 	defer tmpFile.Close()
 
 	if _, err := tmpFile.Write(data); err != nil {
 		os.Remove(tmpFile.Name())
-		return "", 0, fmt.Errorf("failed to write temp file: %v", err)
+		return "", fmt.Errorf("failed to write temp file: %v", err)
 	}
 
-	return tmpFile.Name(), len(data), nil
+	return tmpFile.Name(), nil
 }
 
 // handleFetchWeb fetches a webpage. Default mode returns dense block summaries.
@@ -332,54 +334,54 @@ func (m *WebToolManager) handleFetchWeb(ctx context.Context, args message.ToolAr
 
 	// If URL looks like a PDF, download to temp file for PDFRead/PDFInfo tools
 	if isPDFURL(urlStr) {
-		filePath, size, err := m.fetchPDF(ctx, urlStr)
+		filePath, size, snap, err := m.fetchPDF(ctx, urlStr)
 		if err != nil {
-			return message.NewToolResultError(fmt.Sprintf("failed to download PDF: %v", err)), nil
+			return fetchError("failed to download PDF", err), nil
 		}
 		desc := fmt.Sprintf("PDF downloaded from %s (%dKB) and saved to: %s\nUse PDFInfo and PDFRead tools with this file path to extract content.", urlStr, size/1024, filePath)
-		return message.NewToolResultText(desc), nil
+		return message.NewToolResultText(desc + archiveNote(snap)), nil
 	}
 
 	// If URL looks like an image, try to download it for vision analysis
 	if isImageURL(urlStr) {
 		b64, ct, size, err := m.fetchImage(ctx, urlStr)
 		if err != nil {
-			return message.NewToolResultError(fmt.Sprintf("failed to download image: %v", err)), nil
+			return fetchError("failed to download image", err), nil
 		}
 		desc := fmt.Sprintf("Image downloaded from %s (type: %s, size: %dKB). Analyze the attached image.", urlStr, ct, size/1024)
 		return message.NewToolResultWithImages(desc, []string{b64}), nil
 	}
 
-	doc, parsedURL, err := m.fetchAndParse(ctx, urlStr)
+	doc, parsedURL, snap, err := m.fetchAndParse(ctx, urlStr)
 	if err != nil {
 		errMsg := err.Error()
 		// If fetchAndParse failed due to content type, check what kind
 		if strings.Contains(errMsg, "unsupported content type") {
 			// PDF content type — download to temp file
 			if strings.Contains(errMsg, "application/pdf") {
-				filePath, size, pdfErr := m.fetchPDF(ctx, urlStr)
+				filePath, size, pdfSnap, pdfErr := m.fetchPDF(ctx, urlStr)
 				if pdfErr != nil {
-					return message.NewToolResultError(fmt.Sprintf("failed to download PDF: %v", pdfErr)), nil
+					return fetchError("failed to download PDF", pdfErr), nil
 				}
 				desc := fmt.Sprintf("PDF downloaded from %s (%dKB) and saved to: %s\nUse PDFInfo and PDFRead tools with this file path to extract content.", urlStr, size/1024, filePath)
-				return message.NewToolResultText(desc), nil
+				return message.NewToolResultText(desc + archiveNote(pdfSnap)), nil
 			}
 			// Image content type — download for vision analysis
 			if strings.Contains(errMsg, "image/") {
 				b64, ct, size, imgErr := m.fetchImage(ctx, urlStr)
 				if imgErr != nil {
-					return message.NewToolResultError(fmt.Sprintf("failed to download image: %v", imgErr)), nil
+					return fetchError("failed to download image", imgErr), nil
 				}
 				desc := fmt.Sprintf("Image downloaded from %s (type: %s, size: %dKB). Analyze the attached image.", urlStr, ct, size/1024)
 				return message.NewToolResultWithImages(desc, []string{b64}), nil
 			}
 		}
-		return message.NewToolResultError(errMsg), nil
+		return fetchError("", err), nil
 	}
 
 	if mode == "full" {
 		markdown := m.convertToMarkdown(doc, parsedURL)
-		return message.NewToolResultText(markdown), nil
+		return message.NewToolResultText(markdown + archiveNote(snap)), nil
 	}
 
 	// Default: block extraction mode.
@@ -408,11 +410,12 @@ func (m *WebToolManager) handleFetchWeb(ctx context.Context, args message.ToolAr
 		if bodyText != "" {
 			fallback.WriteString(fmt.Sprintf("Body preview:\n%s\n", bodyText))
 		}
+		fallback.WriteString(archiveNote(snap))
 		return message.NewToolResultText(fallback.String()), nil
 	}
 
 	summary := formatBlockSummary(title, urlStr, blocks, blockPreviewLength)
-	return message.NewToolResultText(summary), nil
+	return message.NewToolResultText(summary + archiveNote(snap)), nil
 }
 
 // handleFetchWebBlock retrieves full content of specific blocks from cache.
@@ -436,9 +439,9 @@ func (m *WebToolManager) handleFetchWebBlock(ctx context.Context, args message.T
 	cached := m.getCachedBlocks(urlStr)
 	if cached == nil {
 		// Cache miss — re-fetch and extract.
-		doc, _, fetchErr := m.fetchAndParse(ctx, urlStr)
+		doc, _, _, fetchErr := m.fetchAndParse(ctx, urlStr)
 		if fetchErr != nil {
-			return message.NewToolResultError(fetchErr.Error()), nil
+			return fetchError("", fetchErr), nil
 		}
 		title := strings.TrimSpace(doc.Find("title").First().Text())
 		blocks := extractDenseBlocks(doc)
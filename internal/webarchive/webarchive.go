@@ -0,0 +1,321 @@
+// Package webarchive keeps what the web and research tools fetched, so the
+// evidence behind an answer or a narrative outlives the page it came from.
+//
+// Bodies are content-addressed: a snapshot's ID is the SHA-256 of its body,
+// and the body is stored once however many URLs or fetches produced it. Each
+// URL has a history of the snapshots fetched from it, with the response
+// headers and fetch time. A URL fetched again is revalidated with the
+// previous snapshot's ETag and Last-Modified, so an unchanged page costs a
+// 304 and keeps its snapshot.
+//
+// Layout under the archive directory:
+//
+//	objects/ab/abcdef…       body
+//	objects/ab/abcdef….json  the snapshot that first stored it
+//	urls/12/1234…json        history of one URL, oldest first
+//
+// Like the fetch policy, the archive travels on the context (WithArchive);
+// Get fetches without archiving when there is none.
+package webarchive
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fpt/klein-cli/internal/webfetch"
+	pkgLogger "github.com/fpt/klein-cli/pkg/logger"
+)
+
+var logger = pkgLogger.NewComponentLogger("webarchive")
+
+// MinIDPrefix is the shortest snapshot ID prefix Lookup accepts.
+const MinIDPrefix = 8
+
+// Archive is an on-disk store of fetched bodies.
+type Archive struct {
+	dir string
+	mu  sync.Mutex // serialises history read-modify-writes
+}
+
+// New returns the archive rooted at dir; directories are created on first
+// write.
+func New(dir string) *Archive {
+	return &Archive{dir: dir}
+}
+
+// Snapshot is one archived response.
+type Snapshot struct {
+	ID        string      `json:"id"` // SHA-256 of the body, hex
+	URL       string      `json:"url"`
+	FinalURL  string      `json:"final_url"` // after redirects
+	Status    int         `json:"status"`
+	Header    http.Header `json:"header"`
+	Size      int         `json:"size"`
+	Truncated bool        `json:"truncated,omitempty"` // the body is the head of a longer one
+	FetchedAt time.Time   `json:"fetched_at"`
+	// ValidatedAt is when the origin last served or confirmed this body.
+	ValidatedAt time.Time `json:"validated_at"`
+}
+
+// ContentType returns the snapshot's Content-Type header.
+func (s *Snapshot) ContentType() string {
+	return s.Header.Get("Content-Type")
+}
+
+type archiveKey struct{}
+
+// WithArchive returns a context whose fetches through Get are archived in a.
+func WithArchive(ctx context.Context, a *Archive) context.Context {
+	return context.WithValue(ctx, archiveKey{}, a)
+}
+
+// FromContext returns the archive ctx carries, or nil.
+func FromContext(ctx context.Context) *Archive {
+	a, _ := ctx.Value(archiveKey{}).(*Archive)
+	return a
+}
+
+// Get fetches req through webfetch and archives a 200 response in the
+// archive ctx carries. The snapshot is nil when nothing was archived.
+func Get(ctx context.Context, req webfetch.Request) (*webfetch.Response, *Snapshot, error) {
+	if a := FromContext(ctx); a != nil {
+		return a.Fetch(ctx, req)
+	}
+	resp, err := webfetch.Get(ctx, req)
+	return resp, nil, err
+}
+
+// Fetch fetches req, revalidating the URL's latest snapshot when there is
+// one: a 304 is answered from the archive as a 200. Failing to write the
+// archive is logged, not returned — the fetch itself succeeded.
+func (a *Archive) Fetch(ctx context.Context, req webfetch.Request) (*webfetch.Response, *Snapshot, error) {
+	prev := a.latest(req.URL)
+	if prev != nil {
+		req.Header = req.Header.Clone()
+		if req.Header == nil {
+			req.Header = http.Header{}
+		}
+		if etag := prev.Header.Get("ETag"); etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if modified := prev.Header.Get("Last-Modified"); modified != "" {
+			req.Header.Set("If-Modified-Since", modified)
+		}
+	}
+	resp, err := webfetch.Get(ctx, req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && prev != nil:
+		body, err := a.Body(prev.ID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading the archived copy of %s: %w", req.URL, err)
+		}
+		snap, err := a.record(req.URL, *prev, time.Now().UTC())
+		if err != nil {
+			logger.Warn("Failed to record revalidation", "url", req.URL, "error", err)
+			snap = prev
+		}
+		return &webfetch.Response{
+			URL:        prev.FinalURL,
+			StatusCode: http.StatusOK,
+			Status:     "200 OK",
+			Header:     prev.Header,
+			Body:       body,
+			Truncated:  prev.Truncated,
+		}, snap, nil
+	case resp.StatusCode == http.StatusOK:
+		snap, err := a.store(req.URL, resp)
+		if err != nil {
+			logger.Warn("Failed to archive response", "url", req.URL, "error", err)
+			return resp, nil, nil
+		}
+		return resp, snap, nil
+	}
+	return resp, nil, nil
+}
+
+// store writes resp's body, if it is new, and adds it to rawURL's history.
+func (a *Archive) store(rawURL string, resp *webfetch.Response) (*Snapshot, error) {
+	sum := sha256.Sum256(resp.Body)
+	now := time.Now().UTC()
+	header := resp.Header.Clone()
+	header.Del("Set-Cookie")
+	snap := Snapshot{
+		ID:          hex.EncodeToString(sum[:]),
+		URL:         rawURL,
+		FinalURL:    resp.URL,
+		Status:      resp.StatusCode,
+		Header:      header,
+		Size:        len(resp.Body),
+		Truncated:   resp.Truncated,
+		FetchedAt:   now,
+		ValidatedAt: now,
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	object := a.objectPath(snap.ID)
+	if _, err := os.Stat(object); errors.Is(err, os.ErrNotExist) {
+		meta, err := json.MarshalIndent(snap, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		if err := writeFileAtomic(object, resp.Body); err != nil {
+			return nil, err
+		}
+		if err := writeFileAtomic(object+".json", meta); err != nil {
+			return nil, err
+		}
+	}
+	return a.recordLocked(rawURL, snap, now)
+}
+
+// record notes that the origin served or confirmed snap's body at now.
+func (a *Archive) record(rawURL string, snap Snapshot, now time.Time) (*Snapshot, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.recordLocked(rawURL, snap, now)
+}
+
+// recordLocked adds snap to rawURL's history, or, when the body is the one
+// last fetched from it, moves that entry's ValidatedAt to now.
+func (a *Archive) recordLocked(rawURL string, snap Snapshot, now time.Time) (*Snapshot, error) {
+	history, err := a.History(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if n := len(history); n > 0 && history[n-1].ID == snap.ID {
+		history[n-1].ValidatedAt = now
+	} else {
+		snap.ValidatedAt = now
+		history = append(history, snap)
+	}
+	data, err := json.MarshalIndent(history, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(a.historyPath(rawURL), data); err != nil {
+		return nil, err
+	}
+	latest := history[len(history)-1]
+	return &latest, nil
+}
+
+// latest returns rawURL's newest snapshot whose body is still on disk.
+func (a *Archive) latest(rawURL string) *Snapshot {
+	history, err := a.History(rawURL)
+	if err != nil || len(history) == 0 {
+		return nil
+	}
+	snap := history[len(history)-1]
+	if _, err := os.Stat(a.objectPath(snap.ID)); err != nil {
+		return nil
+	}
+	return &snap
+}
+
+// History returns the snapshots fetched from rawURL, oldest first.
+func (a *Archive) History(rawURL string) ([]Snapshot, error) {
+	data, err := os.ReadFile(a.historyPath(rawURL))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var history []Snapshot
+	if err := json.Unmarshal(data, &history); err != nil {
+		return nil, fmt.Errorf("parsing the history of %s: %w", rawURL, err)
+	}
+	return history, nil
+}
+
+// Lookup returns the snapshot with the given ID, or with the only ID that
+// starts with it, as first stored.
+func (a *Archive) Lookup(id string) (*Snapshot, error) {
+	id = strings.ToLower(strings.TrimSpace(id))
+	if len(id) < MinIDPrefix || strings.Trim(id, "0123456789abcdef") != "" {
+		return nil, fmt.Errorf("snapshot ID %q must be at least %d hex characters", id, MinIDPrefix)
+	}
+	entries, err := os.ReadDir(filepath.Join(a.dir, "objects", id[:2]))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	var match string
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasSuffix(name, ".json") || !strings.HasPrefix(name, id) {
+			continue
+		}
+		if match != "" {
+			return nil, fmt.Errorf("snapshot ID %q is ambiguous; give more of it", id)
+		}
+		match = name
+	}
+	if match == "" {
+		return nil, fmt.Errorf("no archived snapshot %q", id)
+	}
+	data, err := os.ReadFile(a.objectPath(match) + ".json")
+	if err != nil {
+		return nil, err
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("parsing snapshot %s: %w", match, err)
+	}
+	return &snap, nil
+}
+
+// Body returns the archived body with the given full ID.
+func (a *Archive) Body(id string) ([]byte, error) {
+	return os.ReadFile(a.objectPath(id))
+}
+
+func (a *Archive) objectPath(id string) string {
+	return filepath.Join(a.dir, "objects", id[:2], id)
+}
+
+func (a *Archive) historyPath(rawURL string) string {
+	sum := sha256.Sum256([]byte(rawURL))
+	key := hex.EncodeToString(sum[:])
+	return filepath.Join(a.dir, "urls", key[:2], key+".json")
+}
+
+// writeFileAtomic writes data beside path and renames it into place, so a
+// reader never sees half a file.
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
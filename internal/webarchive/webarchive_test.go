@@ -0,0 +1,128 @@
+package webarchive
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fpt/klein-cli/internal/webfetch"
+)
+
+// local runs fetches against httptest servers, which listen on loopback.
+func local(a *Archive) context.Context {
+	ctx := webfetch.WithPolicy(context.Background(), webfetch.Policy{AllowPrivateNetworks: true})
+	return WithArchive(ctx, a)
+}
+
+func TestGet_StoresAndRevalidates(t *testing.T) {
+	body := "v1"
+	var hits, notModified int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		etag := `"` + body + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	a := New(t.TempDir())
+	ctx := local(a)
+	_, first, err := Get(ctx, webfetch.Request{URL: srv.URL})
+	if err != nil || first == nil {
+		t.Fatalf("first fetch = %v, %v", first, err)
+	}
+	if first.Header.Get("Set-Cookie") != "" || first.ContentType() != "text/html" || first.Size != 2 {
+		t.Errorf("snapshot = %+v", first)
+	}
+
+	// Unchanged: answered from the archive, same snapshot.
+	resp, again, err := Get(ctx, webfetch.Request{URL: srv.URL})
+	if err != nil || resp.StatusCode != http.StatusOK || string(resp.Body) != "v1" {
+		t.Fatalf("revalidated fetch = %+v, %v", resp, err)
+	}
+	if notModified != 1 || again.ID != first.ID || again.ValidatedAt.Before(first.FetchedAt) {
+		t.Errorf("304s = %d, snapshot = %+v", notModified, again)
+	}
+
+	// Changed: a second snapshot; the first stays readable.
+	body = "v2"
+	_, second, err := Get(ctx, webfetch.Request{URL: srv.URL})
+	if err != nil || second.ID == first.ID {
+		t.Fatalf("changed fetch = %+v, %v", second, err)
+	}
+	history, err := a.History(srv.URL)
+	if err != nil || len(history) != 2 || history[0].ID != first.ID || history[1].ID != second.ID {
+		t.Errorf("history = %+v, %v", history, err)
+	}
+	if old, err := a.Body(first.ID); err != nil || string(old) != "v1" {
+		t.Errorf("first body = %q, %v", old, err)
+	}
+	if hits != 3 {
+		t.Errorf("server hits = %d", hits)
+	}
+}
+
+func TestGet_WithoutArchive(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") != "" {
+			t.Error("conditional request without an archive")
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	ctx := webfetch.WithPolicy(context.Background(), webfetch.Policy{AllowPrivateNetworks: true})
+	resp, snap, err := Get(ctx, webfetch.Request{URL: srv.URL})
+	if err != nil || snap != nil || string(resp.Body) != "ok" {
+		t.Errorf("Get = %+v, %+v, %v", resp, snap, err)
+	}
+}
+
+func TestGet_SkipsErrorStatuses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.NotFound(w, nil)
+	}))
+	defer srv.Close()
+
+	a := New(t.TempDir())
+	resp, snap, err := Get(local(a), webfetch.Request{URL: srv.URL})
+	if err != nil || snap != nil || resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Get = %+v, %+v, %v", resp, snap, err)
+	}
+	if history, _ := a.History(srv.URL); len(history) != 0 {
+		t.Errorf("history = %+v", history)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer srv.Close()
+
+	a := New(t.TempDir())
+	_, snap, err := Get(local(a), webfetch.Request{URL: srv.URL + "/page"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{snap.ID, snap.ID[:MinIDPrefix], strings.ToUpper(snap.ID[:12])} {
+		got, err := a.Lookup(id)
+		if err != nil || got.ID != snap.ID || got.URL != srv.URL+"/page" {
+			t.Errorf("Lookup(%q) = %+v, %v", id, got, err)
+		}
+	}
+	for _, id := range []string{snap.ID[:MinIDPrefix-1], "zzzzzzzzzz", strings.Repeat("0", 64)} {
+		if _, err := a.Lookup(id); err == nil {
+			t.Errorf("Lookup(%q) succeeded", id)
+		}
+	}
+}
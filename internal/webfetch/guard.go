@@ -0,0 +1,75 @@
+package webfetch
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+	"syscall"
+)
+
+// Ranges the standard library's predicates miss: "this network" and the
+// carrier-grade NAT block, which VPN meshes such as Tailscale hand out.
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+}
+
+// blockedAddress reports whether ip belongs to the machine or its networks
+// rather than the public internet.
+func blockedAddress(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsValid() || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return true
+	}
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// guardDial is the dialer's Control hook: it runs on every connection, with
+// the address DNS resolved to, so neither a hostname pointing inward nor a
+// redirect to one gets through.
+func guardDial(_, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("unexpected dial address %q: %w", address, err)
+	}
+	if blockedAddress(ap.Addr()) {
+		return &Error{
+			Reason: ReasonPrivateAddress,
+			Detail: fmt.Sprintf("the host resolves to %s, a private, loopback or link-local address", ap.Addr().Unmap()),
+		}
+	}
+	return nil
+}
+
+// checkDomain applies the deny list, then the allow list, to host.
+func (p Policy) checkDomain(host string) *Error {
+	host = normalizeHost(host)
+	if d, ok := matchDomain(host, p.DenyDomains); ok {
+		return &Error{Reason: ReasonDomainDenied, Detail: fmt.Sprintf("%s is on the deny list (%s)", host, d)}
+	}
+	if len(p.AllowDomains) > 0 {
+		if _, ok := matchDomain(host, p.AllowDomains); !ok {
+			return &Error{Reason: ReasonDomainNotAllowed, Detail: fmt.Sprintf("%s is not on the allow list (%s)", host, strings.Join(p.AllowDomains, ", "))}
+		}
+	}
+	return nil
+}
+
+// matchDomain returns the entry of domains that host is, or is a subdomain
+// of. Entries may be written "example.com", ".example.com" or "*.example.com".
+func matchDomain(host string, domains []string) (string, bool) {
+	for _, d := range domains {
+		d = normalizeHost(strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(d), "*"), "."))
+		if d != "" && (host == d || strings.HasSuffix(host, "."+d)) {
+			return d, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,160 @@
+package webfetch
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// robotsAgent is the product token klein's rules are looked up under; a site
+// without a group for it gets its "*" group applied.
+const robotsAgent = "klein"
+
+const (
+	robotsTTL      = time.Hour
+	robotsMaxBytes = 512 << 10
+	robotsTimeout  = 10 * time.Second
+)
+
+type robotsRule struct {
+	allow   bool
+	pattern string
+}
+
+type robotsRules []robotsRule
+
+// robotsCache holds each origin's rules for robotsTTL, so a crawl of one site
+// reads its robots.txt once.
+var robotsCache = struct {
+	sync.Mutex
+	entries map[string]robotsEntry
+}{entries: map[string]robotsEntry{}}
+
+type robotsEntry struct {
+	rules   robotsRules
+	fetched time.Time
+}
+
+func (p Policy) checkRobots(ctx context.Context, u *url.URL) error {
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	if !p.robotsFor(ctx, u).allowed(path) {
+		return &Error{Reason: ReasonRobots, URL: u.String(), Detail: fmt.Sprintf("%s/robots.txt disallows %s for %s", u.Host, path, robotsAgent)}
+	}
+	return nil
+}
+
+// robotsFor returns the rules for u's origin. A robots.txt that is missing or
+// unreadable allows everything; only one that was read is cached, so a
+// transient failure is retried on the next fetch.
+func (p Policy) robotsFor(ctx context.Context, u *url.URL) robotsRules {
+	origin := u.Scheme + "://" + u.Host
+	robotsCache.Lock()
+	entry, ok := robotsCache.entries[origin]
+	robotsCache.Unlock()
+	if ok && time.Since(entry.fetched) < robotsTTL {
+		return entry.rules
+	}
+
+	inner := p
+	inner.RespectRobots = false
+	resp, err := Get(WithPolicy(ctx, inner), Request{
+		URL:      origin + "/robots.txt",
+		Header:   http.Header{"User-Agent": {robotsAgent}},
+		MaxBytes: robotsMaxBytes,
+		Timeout:  robotsTimeout,
+	})
+	if err != nil {
+		return nil
+	}
+	var rules robotsRules
+	if resp.StatusCode == http.StatusOK {
+		rules = parseRobots(string(resp.Body), robotsAgent)
+	}
+	robotsCache.Lock()
+	robotsCache.entries[origin] = robotsEntry{rules: rules, fetched: time.Now()}
+	robotsCache.Unlock()
+	return rules
+}
+
+// parseRobots returns the rules of the groups naming agent, or of the "*"
+// groups when none does (RFC 9309).
+func parseRobots(body, agent string) robotsRules {
+	var specific, generic robotsRules
+	var agents []string
+	haveSpecific, inRules := false, false
+	for line := range strings.Lines(body) {
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+		switch key {
+		case "user-agent":
+			if inRules {
+				agents, inRules = nil, false
+			}
+			value = strings.ToLower(value)
+			agents = append(agents, value)
+			haveSpecific = haveSpecific || value == agent
+		case "allow", "disallow":
+			inRules = true
+			if value == "" {
+				continue // an empty Disallow allows everything
+			}
+			rule := robotsRule{allow: key == "allow", pattern: value}
+			for _, a := range agents {
+				switch a {
+				case agent:
+					specific = append(specific, rule)
+				case "*":
+					generic = append(generic, rule)
+				}
+			}
+		}
+	}
+	if haveSpecific {
+		return specific
+	}
+	return generic
+}
+
+// allowed applies the longest matching rule to path; on a tie Allow wins.
+func (r robotsRules) allowed(path string) bool {
+	best, allow := -1, true
+	for _, rule := range r {
+		if len(rule.pattern) < best || !robotsMatch(rule.pattern, path) {
+			continue
+		}
+		if len(rule.pattern) > best || rule.allow {
+			best, allow = len(rule.pattern), rule.allow
+		}
+	}
+	return allow
+}
+
+// robotsMatch matches a robots.txt path pattern, where "*" is any run of
+// characters and a trailing "$" anchors the end.
+func robotsMatch(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+	expr := "^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*")
+	if anchored {
+		expr += "$"
+	}
+	re, err := regexp.Compile(expr)
+	return err == nil && re.MatchString(path)
+}
@@ -0,0 +1,290 @@
+// Package webfetch is the HTTP layer behind every tool that reaches the
+// network on the model's behalf: WebFetch, PDF URLs, the market tools and the
+// researcher crawlers. Those tools are exposed to whoever can talk to the
+// agent — in `klein claw`, anyone on the Discord allow-list — so a URL is
+// untrusted input, and Get refuses the ones a Policy rules out:
+//
+//   - addresses on the machine's own networks (private, loopback, link-local,
+//     including cloud metadata endpoints), checked on the address actually
+//     dialled, after DNS resolution and after every redirect;
+//   - domains outside the policy's allow list or on its deny list;
+//   - paths robots.txt disallows, when the policy honours it;
+//   - bodies over the size cap and requests over the time cap.
+//
+// A refusal is an *Error whose Reason says which rule applied, so tools can
+// report it to the model as something to act on rather than retry.
+//
+// The policy travels on the context (WithPolicy); a context without one gets
+// the zero Policy, which is the guarded default.
+package webfetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Defaults for the caps a Policy leaves at zero.
+const (
+	DefaultMaxBytes = 50 << 20
+	DefaultTimeout  = 60 * time.Second
+	maxRedirects    = 10
+)
+
+// Policy is what Get may reach and how much it may read.
+type Policy struct {
+	// AllowDomains, when non-empty, is the only domains (and their subdomains)
+	// that may be fetched.
+	AllowDomains []string
+	// DenyDomains are never fetched, with their subdomains; deny wins over
+	// allow.
+	DenyDomains []string
+	// AllowPrivateNetworks turns the address guard off, for a trusted user
+	// who needs a dev server on localhost or a host on the LAN.
+	AllowPrivateNetworks bool
+	// MaxBytes caps every response body; 0 selects DefaultMaxBytes.
+	MaxBytes int64
+	// Timeout caps every request, redirects included; 0 selects
+	// DefaultTimeout.
+	Timeout time.Duration
+	// RespectRobots refuses paths the site's robots.txt disallows for klein.
+	RespectRobots bool
+}
+
+type policyKey struct{}
+
+// WithPolicy returns a context whose fetches run under p.
+func WithPolicy(ctx context.Context, p Policy) context.Context {
+	return context.WithValue(ctx, policyKey{}, p)
+}
+
+// PolicyFrom returns the policy carried by ctx, or the zero Policy.
+func PolicyFrom(ctx context.Context) Policy {
+	p, _ := ctx.Value(policyKey{}).(Policy)
+	return p
+}
+
+// Reason names the rule that refused a fetch.
+type Reason string
+
+const (
+	ReasonScheme           Reason = "unsupported_scheme"
+	ReasonPrivateAddress   Reason = "private_address"
+	ReasonDomainDenied     Reason = "domain_denied"
+	ReasonDomainNotAllowed Reason = "domain_not_allowed"
+	ReasonRobots           Reason = "robots_disallowed"
+	ReasonTooLarge         Reason = "too_large"
+	ReasonTimeout          Reason = "timeout"
+)
+
+// Error is a fetch the policy refused or cut short.
+type Error struct {
+	Reason Reason
+	URL    string
+	Detail string
+}
+
+func (e *Error) Error() string {
+	if e.URL == "" {
+		return fmt.Sprintf("fetch refused (%s): %s", e.Reason, e.Detail)
+	}
+	return fmt.Sprintf("fetch of %s refused (%s): %s", e.URL, e.Reason, e.Detail)
+}
+
+// AsError returns the policy error in err's chain, if there is one.
+func AsError(err error) (*Error, bool) {
+	var fe *Error
+	ok := errors.As(err, &fe)
+	return fe, ok
+}
+
+// Request is one GET.
+type Request struct {
+	URL    string
+	Header http.Header
+	// MaxBytes and Timeout are the caller's own caps; the policy's apply
+	// when lower.
+	MaxBytes int64
+	Timeout  time.Duration
+	// Truncate keeps the start of a body over the cap instead of refusing it,
+	// for callers that only need its head.
+	Truncate bool
+}
+
+// Response is a fetched body, read in full.
+type Response struct {
+	URL        string // after redirects
+	StatusCode int
+	Status     string
+	Header     http.Header
+	Body       []byte
+	Truncated  bool // Body is the head of a longer one (Request.Truncate)
+}
+
+// ContentType returns the response's Content-Type header.
+func (r *Response) ContentType() string {
+	return r.Header.Get("Content-Type")
+}
+
+// Get fetches req.URL under the policy ctx carries. The response is returned
+// whatever its status; callers decide which statuses they accept.
+func Get(ctx context.Context, req Request) (*Response, error) {
+	p := PolicyFrom(ctx)
+	u, err := url.Parse(req.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+	timeout := capOf(int64(req.Timeout), int64(p.Timeout), int64(DefaultTimeout))
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(timeout))
+	defer cancel()
+	if err := p.check(ctx, u); err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for k, v := range req.Header {
+		httpReq.Header[k] = v
+	}
+	resp, err := p.client().Do(httpReq)
+	if err != nil {
+		return nil, p.describe(ctx, err, u.String(), time.Duration(timeout))
+	}
+	defer resp.Body.Close()
+
+	limit := capOf(req.MaxBytes, p.MaxBytes, DefaultMaxBytes)
+	final := resp.Request.URL.String()
+	if resp.ContentLength > limit && !req.Truncate {
+		return nil, tooLarge(final, limit)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, p.describe(ctx, err, final, time.Duration(timeout))
+	}
+	truncated := false
+	if int64(len(body)) > limit {
+		if !req.Truncate {
+			return nil, tooLarge(final, limit)
+		}
+		body = body[:limit]
+		truncated = true
+	}
+	return &Response{
+		URL:        final,
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Header:     resp.Header,
+		Body:       body,
+		Truncated:  truncated,
+	}, nil
+}
+
+// client returns an http.Client that re-checks the policy on each redirect.
+// The guarded transport dials no proxy: through one, the address dialled
+// would be the proxy's and the guard would see nothing.
+func (p Policy) client() *http.Client {
+	transport := guardedTransport
+	if p.AllowPrivateNetworks {
+		transport = openTransport
+	}
+	return &http.Client{
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			return p.check(req.Context(), req.URL)
+		},
+	}
+}
+
+var (
+	guardedTransport = newTransport(false)
+	openTransport    = newTransport(true)
+)
+
+func newTransport(allowPrivate bool) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if !allowPrivate {
+		t.Proxy = nil
+		dialer.Control = guardDial
+	}
+	t.DialContext = dialer.DialContext
+	return t
+}
+
+// check applies the URL-level rules: scheme, domain lists and robots.txt.
+// The address rule runs at dial time, on the resolved address.
+func (p Policy) check(ctx context.Context, u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return &Error{Reason: ReasonScheme, URL: u.String(), Detail: "only http and https URLs can be fetched"}
+	}
+	if err := p.checkDomain(u.Hostname()); err != nil {
+		err.URL = u.String()
+		return err
+	}
+	if p.RespectRobots {
+		return p.checkRobots(ctx, u)
+	}
+	return nil
+}
+
+// describe turns a transport error into the policy error behind it, when
+// there is one, or a timeout error when the policy's cap ran out.
+func (p Policy) describe(ctx context.Context, err error, target string, timeout time.Duration) error {
+	if fe, ok := AsError(err); ok {
+		var ue *url.Error
+		if fe.URL == "" && errors.As(err, &ue) {
+			fe.URL = ue.URL
+		} else if fe.URL == "" {
+			fe.URL = target
+		}
+		return fe
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return &Error{Reason: ReasonTimeout, URL: target, Detail: fmt.Sprintf("no complete response within %s", timeout)}
+	}
+	return err
+}
+
+func tooLarge(target string, limit int64) error {
+	return &Error{Reason: ReasonTooLarge, URL: target, Detail: fmt.Sprintf("the response exceeds the %s cap", formatBytes(limit))}
+}
+
+// capOf returns the lower of the positive caps, or def when neither is set.
+func capOf(own, policy, def int64) int64 {
+	switch {
+	case own > 0 && policy > 0:
+		return min(own, policy)
+	case own > 0:
+		return own
+	case policy > 0:
+		return policy
+	}
+	return def
+}
+
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<20 && n%(1<<20) == 0:
+		return fmt.Sprintf("%dMB", n>>20)
+	case n >= 1<<10 && n%(1<<10) == 0:
+		return fmt.Sprintf("%dKB", n>>10)
+	}
+	return fmt.Sprintf("%d-byte", n)
+}
+
+// normalizeHost lower-cases a host and drops a trailing root dot, so
+// "Example.COM." matches "example.com".
+func normalizeHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
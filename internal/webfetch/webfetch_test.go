@@ -0,0 +1,216 @@
+package webfetch
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+// local runs fetches against httptest servers, which listen on loopback.
+func local(p Policy) context.Context {
+	p.AllowPrivateNetworks = true
+	return WithPolicy(context.Background(), p)
+}
+
+func reason(t *testing.T, err error) Reason {
+	t.Helper()
+	fe, ok := AsError(err)
+	if !ok {
+		t.Fatalf("err = %v; want a policy error", err)
+	}
+	return fe.Reason
+}
+
+func TestBlockedAddress(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"127.0.0.1":        true,
+		"10.1.2.3":         true,
+		"172.16.0.1":       true,
+		"192.168.1.1":      true,
+		"169.254.169.254":  true, // cloud metadata
+		"100.100.1.1":      true,
+		"0.0.0.0":          true,
+		"::1":              true,
+		"fe80::1":          true,
+		"fd00::1":          true,
+		"::ffff:127.0.0.1": true,
+		"93.184.216.34":    false,
+		"2606:4700::1111":  false,
+	} {
+		if got := blockedAddress(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("blockedAddress(%s) = %v; want %v", addr, got, want)
+		}
+	}
+}
+
+func TestGet_BlocksLoopbackByDefault(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		t.Error("the guarded fetch reached the server")
+	}))
+	defer srv.Close()
+
+	_, err := Get(context.Background(), Request{URL: srv.URL})
+	if got := reason(t, err); got != ReasonPrivateAddress {
+		t.Errorf("reason = %s; want %s", got, ReasonPrivateAddress)
+	}
+	if fe, _ := AsError(err); !strings.HasPrefix(fe.URL, srv.URL) {
+		t.Errorf("error URL = %q", fe.URL)
+	}
+
+	// A hostname is checked on the address it resolves to.
+	_, err = Get(context.Background(), Request{URL: strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)})
+	if got := reason(t, err); got != ReasonPrivateAddress {
+		t.Errorf("localhost: reason = %s", got)
+	}
+}
+
+func TestGet_Scheme(t *testing.T) {
+	_, err := Get(context.Background(), Request{URL: "file:///etc/passwd"})
+	if got := reason(t, err); got != ReasonScheme {
+		t.Errorf("reason = %s", got)
+	}
+}
+
+func TestGet_Domains(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	if _, err := Get(local(Policy{DenyDomains: []string{"127.0.0.1"}}), Request{URL: srv.URL}); reason(t, err) != ReasonDomainDenied {
+		t.Errorf("deny: %v", err)
+	}
+	if _, err := Get(local(Policy{AllowDomains: []string{"example.com"}}), Request{URL: srv.URL}); reason(t, err) != ReasonDomainNotAllowed {
+		t.Errorf("allow: %v", err)
+	}
+	resp, err := Get(local(Policy{AllowDomains: []string{"127.0.0.1"}}), Request{URL: srv.URL})
+	if err != nil || string(resp.Body) != "ok" {
+		t.Errorf("allowed fetch = %v, %v", resp, err)
+	}
+}
+
+func TestMatchDomain(t *testing.T) {
+	domains := []string{"*.Example.com", "docs.go.dev"}
+	for host, want := range map[string]bool{
+		"example.com":      true,
+		"api.example.com":  true,
+		"example.com.":     true,
+		"notexample.com":   false,
+		"go.dev":           false,
+		"pkg.docs.go.dev":  true,
+		"example.com.evil": false,
+	} {
+		if _, got := matchDomain(normalizeHost(host), domains); got != want {
+			t.Errorf("matchDomain(%q) = %v; want %v", host, got, want)
+		}
+	}
+}
+
+func TestGet_RechecksRedirects(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		t.Error("the redirect target was fetched")
+	}))
+	defer target.Close()
+	redirector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, strings.Replace(target.URL, "127.0.0.1", "localhost", 1), http.StatusFound)
+	}))
+	defer redirector.Close()
+
+	_, err := Get(local(Policy{DenyDomains: []string{"localhost"}}), Request{URL: redirector.URL})
+	fe, ok := AsError(err)
+	if !ok || fe.Reason != ReasonDomainDenied || !strings.Contains(fe.URL, "localhost") {
+		t.Errorf("err = %v; want the redirect refused", err)
+	}
+}
+
+func TestGet_SizeCap(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte(strings.Repeat("x", 4096)))
+	}))
+	defer srv.Close()
+
+	// The lower of the caller's and the policy's cap applies.
+	if _, err := Get(local(Policy{MaxBytes: 1024}), Request{URL: srv.URL, MaxBytes: 1 << 20}); reason(t, err) != ReasonTooLarge {
+		t.Errorf("policy cap: %v", err)
+	}
+	if _, err := Get(local(Policy{}), Request{URL: srv.URL, MaxBytes: 1024}); reason(t, err) != ReasonTooLarge {
+		t.Errorf("caller cap: %v", err)
+	}
+	if resp, err := Get(local(Policy{MaxBytes: 4096}), Request{URL: srv.URL}); err != nil || len(resp.Body) != 4096 {
+		t.Errorf("at the cap: %v", err)
+	}
+	if resp, err := Get(local(Policy{MaxBytes: 1024}), Request{URL: srv.URL, Truncate: true}); err != nil || len(resp.Body) != 1024 {
+		t.Errorf("truncated: %v", err)
+	}
+}
+
+func TestGet_Timeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	if _, err := Get(local(Policy{Timeout: 50 * time.Millisecond}), Request{URL: srv.URL}); reason(t, err) != ReasonTimeout {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestGet_Robots(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			w.Write([]byte("User-agent: *\nDisallow: /private\nAllow: /private/ok\n"))
+			return
+		}
+		w.Write([]byte("page"))
+	}))
+	defer srv.Close()
+
+	ctx := local(Policy{RespectRobots: true})
+	if _, err := Get(ctx, Request{URL: srv.URL + "/private/page"}); reason(t, err) != ReasonRobots {
+		t.Errorf("disallowed path: %v", err)
+	}
+	for _, path := range []string{"/private/ok", "/public"} {
+		if _, err := Get(ctx, Request{URL: srv.URL + path}); err != nil {
+			t.Errorf("%s: %v", path, err)
+		}
+	}
+	// Without the option robots.txt is not consulted.
+	if _, err := Get(local(Policy{}), Request{URL: srv.URL + "/private/page"}); err != nil {
+		t.Errorf("robots applied without RespectRobots: %v", err)
+	}
+}
+
+func TestParseRobots(t *testing.T) {
+	body := `# comment
+User-agent: *
+Disallow: /
+
+User-agent: Klein
+User-agent: other
+Disallow: /tmp/
+Disallow: /*.pdf$
+Allow: /tmp/public
+`
+	rules := parseRobots(body, robotsAgent)
+	for path, want := range map[string]bool{
+		"/":              true, // klein's own group replaces "*"
+		"/tmp/x":         false,
+		"/tmp/public/a":  true,
+		"/doc/a.pdf":     false,
+		"/doc/a.pdf?x=1": true,
+	} {
+		if got := rules.allowed(path); got != want {
+			t.Errorf("allowed(%q) = %v; want %v", path, got, want)
+		}
+	}
+	if parseRobots("User-agent: *\nDisallow: /\n", robotsAgent).allowed("/x") {
+		t.Error("the * group was not applied")
+	}
+}
@@ -18,7 +18,6 @@ import (
 	"github.com/fpt/klein-cli/internal/infra"
 	"github.com/fpt/klein-cli/internal/mcp"
 	"github.com/fpt/klein-cli/internal/tool"
-	"github.com/fpt/klein-cli/internal/tool/memorydb"
 	"github.com/fpt/klein-cli/pkg/agent/domain"
 	"github.com/fpt/klein-cli/pkg/agentserver"
 	client "github.com/fpt/klein-cli/pkg/client"
@@ -42,6 +41,8 @@ func runClawCommand(args []string) int {
 		switch args[0] {
 		case "repl", "chat":
 			return runClawREPL(args[1:])
+		case "schedules", "schedule":
+			return runClawSchedules(args[1:])
 		default:
 			if !strings.HasPrefix(args[0], "-") {
 				fmt.Printf("Unknown claw subcommand %q.\n\n%s\n", args[0], clawUsage)
@@ -92,6 +93,10 @@ func runClawCommand(args []string) int {
 	// schedule tools share the gateway's derived paths so ScheduleCreate writes
 	// the same file the scheduler watches.
 	if cfg.AgentAddr == "" {
+		stopTracing := startTracing(ctx, settings, logger)
+		defer stopTracing()
+		configureTokenizer(settings)
+
 		mcpToolManagers, integration := buildClawToolManagers(ctx, settings, cfg, logger)
 		if integration != nil {
 			defer integration.Close()
@@ -114,13 +119,19 @@ func runClawCommand(args []string) int {
 			defer backendRunner.Close()
 		}
 
-		bound, listenErr := connectserver.StartServerListener(
+		bound, serverStopped, listenErr := connectserver.StartServerListener(
 			ctx, *serveAddr, settings, mcpToolManagers, logger, cfg.SessionsDir, agentBackend,
 		)
 		if listenErr != nil {
 			fmt.Fprintf(os.Stderr, "Failed to start embedded agent server: %v\n", listenErr)
 			return 1
 		}
+		// Let the server flush its sessions' memories before the backend and
+		// MCP servers above are closed.
+		defer func() {
+			cancel()
+			<-serverStopped
+		}()
 		cfg.AgentAddr = "http://" + bound
 	}
 
@@ -161,6 +172,7 @@ func runClawCommand(args []string) int {
 const clawUsage = `Usage:
   klein claw [--settings <path>] [--agent-addr <addr>] [--serve-addr <addr>]
   klein claw repl [--settings <path>] [--role <name>]
+  klein claw schedules <list|run|history|pause|resume> [<name>] [--settings <path>]
 
 Runs the messaging gateway. Configuration lives in the "claw" section of
 settings.toml; sessions, memory, and the schedule store are derived from the
@@ -168,7 +180,11 @@ shared "base_dir" (default ~/.klein).
 
   repl     Interactive terminal chat sharing claw's tools (memory, schedules,
            MCP) and backend, with its own session. Runs alongside the gateway —
-           schedules/memory it changes are picked up via the shared base dir.`
+           schedules/memory it changes are picked up via the shared base dir.
+  schedules
+           Operate scheduled jobs: list them with their last run, queue a run
+           now, read the run history, pause or resume. See
+           "klein claw schedules" for details.`
 
 // buildClawToolManagers assembles the MCP + memory + schedule tool managers the
 // claw agent exposes, mirroring `klein --serve`. The returned integration (nil
@@ -195,7 +211,7 @@ func buildClawToolManagers(ctx context.Context, settings *config.Settings, cfg *
 	// The handle lives for the gateway's lifetime (WAL auto-checkpoints); degrade
 	// gracefully if it can't be opened.
 	kbPath := filepath.Join(cfg.Memory.BaseDir, "memory.sqlite")
-	if kb, err := memorydb.NewManager(kbPath); err != nil {
+	if kb, err := openMemoryDB(settings, kbPath, logger); err != nil {
 		logger.Warn("Long-term memory (memorydb) disabled", "error", err)
 	} else {
 		mcpToolManagers["memorydb"] = kb
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/fpt/klein-cli/internal/config"
+	"github.com/fpt/klein-cli/internal/gateway"
+	"github.com/fpt/klein-cli/internal/session"
+)
+
+// runClawSchedules implements `klein claw schedules <list|run|history|pause|resume>`.
+// It works on the same base dir as the gateway: schedules come from settings
+// and schedules.json, state from the run ledger. Runs and pauses take effect in
+// a running gateway on its next poll; nothing here talks to it directly.
+func runClawSchedules(args []string) int {
+	if len(args) == 0 {
+		fmt.Println(schedulesUsage)
+		return 1
+	}
+	sub := args[0]
+	positional, settingsPath, limit, err := parseSchedulesArgs(args[1:])
+	if err != nil {
+		fmt.Printf("%v\n\n%s\n", err, schedulesUsage)
+		return 1
+	}
+	ops, err := openScheduleOps(settingsPath)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	defer ops.ledger.Close()
+
+	ctx := context.Background()
+	switch sub {
+	case "list", "ls":
+		err = ops.list(ctx, os.Stdout, time.Now())
+	case "history":
+		if len(positional) > 1 {
+			err = fmt.Errorf("history takes at most one schedule name")
+			break
+		}
+		name := ""
+		if len(positional) == 1 {
+			name = positional[0]
+		}
+		err = ops.history(ctx, os.Stdout, name, limit, time.Now())
+	case "run", "pause", "resume":
+		if len(positional) != 1 {
+			err = fmt.Errorf("%s needs exactly one schedule name", sub)
+			break
+		}
+		err = ops.control(ctx, os.Stdout, sub, positional[0], time.Now())
+	default:
+		fmt.Printf("Unknown schedules subcommand %q.\n\n%s\n", sub, schedulesUsage)
+		return 1
+	}
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	return 0
+}
+
+const schedulesUsage = `Usage:
+  klein claw schedules list [--settings <path>]
+  klein claw schedules run <name> [--settings <path>]
+  klein claw schedules history [<name>] [-n <count>] [--settings <path>]
+  klein claw schedules pause <name> [--settings <path>]
+  klein claw schedules resume <name> [--settings <path>]
+
+Operates the gateway's schedules (settings.toml [[claw.schedules]] plus
+<base_dir>/schedules.json) through the run ledger in
+<base_dir>/schedule_runs.sqlite. A running gateway picks up queued runs,
+pauses and resumes within about 20 seconds. A paused schedule skips its cron
+fires (and does not catch them up); "run" still starts it.`
+
+// parseSchedulesArgs separates schedule names from --settings and -n, which
+// may come before or after them.
+func parseSchedulesArgs(args []string) (positional []string, settingsPath string, limit int, err error) {
+	limit = 20
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		name, value, hasValue := strings.Cut(arg, "=")
+		switch name {
+		case "--settings", "-settings", "-n", "--n":
+			if !hasValue {
+				if i+1 >= len(args) {
+					return nil, "", 0, fmt.Errorf("%s needs a value", name)
+				}
+				i++
+				value = args[i]
+			}
+			if strings.TrimLeft(name, "-") == "settings" {
+				settingsPath = value
+				continue
+			}
+			n, convErr := strconv.Atoi(value)
+			if convErr != nil || n <= 0 {
+				return nil, "", 0, fmt.Errorf("-n needs a positive count, got %q", value)
+			}
+			limit = n
+		default:
+			if strings.HasPrefix(arg, "-") {
+				return nil, "", 0, fmt.Errorf("unknown flag %s", arg)
+			}
+			positional = append(positional, arg)
+		}
+	}
+	return positional, settingsPath, limit, nil
+}
+
+// scheduleOps is the CLI's view of the gateway's schedules.
+type scheduleOps struct {
+	schedules []gateway.ScheduleConfig
+	ledger    *gateway.RunLedger
+}
+
+func openScheduleOps(settingsPath string) (*scheduleOps, error) {
+	settings, err := config.LoadSettings(settingsPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load settings: %w", err)
+	}
+	cfg, err := gateway.ParseClawConfig(settings.Claw, settings.ResolvedBaseDir())
+	if err != nil {
+		return nil, err
+	}
+	schedules, err := gateway.MergeSchedules(cfg.Schedules, cfg.SchedulesFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", cfg.SchedulesFile, err)
+	}
+	ledger, err := gateway.OpenRunLedger(cfg.ScheduleLedgerFile)
+	if err != nil {
+		return nil, err
+	}
+	return &scheduleOps{schedules: schedules, ledger: ledger}, nil
+}
+
+func (o *scheduleOps) find(name string) (gateway.ScheduleConfig, error) {
+	for _, c := range o.schedules {
+		if c.Name == name {
+			return c, nil
+		}
+	}
+	names := make([]string, len(o.schedules))
+	for i, c := range o.schedules {
+		names[i] = c.Name
+	}
+	if len(names) == 0 {
+		return gateway.ScheduleConfig{}, fmt.Errorf("no schedule named %q (no schedules are configured)", name)
+	}
+	return gateway.ScheduleConfig{}, fmt.Errorf("no schedule named %q (have: %s)", name, strings.Join(names, ", "))
+}
+
+func (o *scheduleOps) list(ctx context.Context, w io.Writer, now time.Time) error {
+	if len(o.schedules) == 0 {
+		fmt.Fprintln(w, "No schedules configured.")
+		return nil
+	}
+	last, err := o.ledger.LastRuns(ctx)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tSTATE\tCRON\tNEXT\tLAST RUN")
+	for _, c := range o.schedules {
+		paused, err := o.ledger.Paused(ctx, c.Name)
+		if err != nil {
+			return err
+		}
+		state, next := "enabled", "-"
+		switch {
+		case !c.Enabled:
+			state = "disabled"
+		case paused:
+			state = "paused"
+		}
+		if state == "enabled" {
+			if t, err := gateway.NextFire(now, c); err != nil {
+				state = "invalid"
+			} else {
+				next = t.Format("2006-01-02 15:04 MST")
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s %s\t%s\t%s\n", c.Name, state, c.Cron, c.Timezone, next, lastRunText(last[c.Name], now))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	return o.warnIfNoGateway(ctx, w, now)
+}
+
+func lastRunText(r gateway.RunRecord, now time.Time) string {
+	if r.ID == 0 {
+		return "never"
+	}
+	text := fmt.Sprintf("%s %s", r.Status, session.Age(now, r.StartedAt))
+	if d := r.Duration(); d > 0 {
+		text += fmt.Sprintf(" (%s)", d.Round(time.Second))
+	}
+	return text
+}
+
+func (o *scheduleOps) history(ctx context.Context, w io.Writer, name string, limit int, now time.Time) error {
+	if name != "" {
+		if _, err := o.find(name); err != nil {
+			// A removed schedule may still have history worth reading.
+			if runs, _ := o.ledger.History(ctx, name, 1); len(runs) == 0 {
+				return err
+			}
+		}
+	}
+	runs, err := o.ledger.History(ctx, name, limit)
+	if err != nil {
+		return err
+	}
+	if len(runs) == 0 {
+		fmt.Fprintln(w, "No runs recorded yet.")
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSCHEDULE\tTRIGGER\tATTEMPT\tSTARTED\tDURATION\tSTATUS\tTOKENS\tERROR")
+	for _, r := range runs {
+		duration, tokens := "-", "-"
+		if d := r.Duration(); d > 0 {
+			duration = d.Round(time.Second).String()
+		}
+		if r.InputTokens+r.OutputTokens > 0 {
+			tokens = fmt.Sprintf("%d/%d", r.InputTokens, r.OutputTokens)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%d\t%s\t%s\t%s\t%s\t%s\n", r.ID, r.Schedule, r.Trigger, r.Attempt,
+			session.Age(now, r.StartedAt), duration, r.Status, tokens, oneLine(r.Error, 60))
+	}
+	return tw.Flush()
+}
+
+// oneLine flattens s to a single line of at most n runes.
+func oneLine(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-1]) + "…"
+	}
+	return s
+}
+
+// control queues a run or pauses/resumes a schedule.
+func (o *scheduleOps) control(ctx context.Context, w io.Writer, action, name string, now time.Time) error {
+	c, err := o.find(name)
+	if err != nil {
+		return err
+	}
+	switch action {
+	case "run":
+		if c.Prompt == "" {
+			return fmt.Errorf("schedule %q has no prompt to run", name)
+		}
+		if err := o.ledger.RequestRun(ctx, name); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "Queued a run of %q; the gateway starts it within about 20s.\n", name)
+	case "pause", "resume":
+		if err := o.ledger.SetPaused(ctx, name, action == "pause"); err != nil {
+			return err
+		}
+		verb := map[string]string{"pause": "Paused", "resume": "Resumed"}[action]
+		fmt.Fprintf(w, "%s %q.\n", verb, name)
+		if !c.Enabled {
+			fmt.Fprintf(w, "Note: %q is disabled in its configuration, so it does not fire either way.\n", name)
+		}
+	}
+	return o.warnIfNoGateway(ctx, w, now)
+}
+
+// warnIfNoGateway notes when no scheduler has polled the ledger recently, so
+// a queued run or pause will wait for the next `klein claw`.
+func (o *scheduleOps) warnIfNoGateway(ctx context.Context, w io.Writer, now time.Time) error {
+	hb, err := o.ledger.LastHeartbeat(ctx)
+	if err != nil {
+		return err
+	}
+	if now.Sub(hb) > time.Minute {
+		seen := "never seen"
+		if !hb.IsZero() {
+			seen = "last seen " + session.Age(now, hb)
+		}
+		fmt.Fprintf(w, "No gateway is running on this base dir (%s); changes apply when `klein claw` starts.\n", seen)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fpt/klein-cli/internal/gateway"
+)
+
+func TestParseSchedulesArgs(t *testing.T) {
+	pos, settings, limit, err := parseSchedulesArgs([]string{"brief", "-n", "5", "--settings=x.toml"})
+	if err != nil || len(pos) != 1 || pos[0] != "brief" || settings != "x.toml" || limit != 5 {
+		t.Errorf("got %v %q %d %v", pos, settings, limit, err)
+	}
+	for _, bad := range [][]string{{"-n"}, {"-n", "0"}, {"--force"}} {
+		if _, _, _, err := parseSchedulesArgs(bad); err == nil {
+			t.Errorf("%v should fail", bad)
+		}
+	}
+}
+
+func TestScheduleOps(t *testing.T) {
+	ledger, err := gateway.OpenRunLedger(filepath.Join(t.TempDir(), "schedule_runs.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ledger.Close()
+	ops := &scheduleOps{ledger: ledger, schedules: []gateway.ScheduleConfig{
+		{Name: "brief", Enabled: true, Cron: "0 8 * * *", Timezone: "UTC", Prompt: "brief"},
+		{Name: "old", Enabled: false, Cron: "0 9 * * *", Timezone: "UTC", Prompt: "x"},
+	}}
+	ctx := context.Background()
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	id, _ := ledger.StartRun(ctx, "brief", gateway.TriggerCron, now, 1)
+	_ = ledger.FinishRun(ctx, id, gateway.RunResult{Status: gateway.RunFailed, Error: "agent stream:\nunavailable", InputTokens: 10, OutputTokens: 2})
+
+	var out bytes.Buffer
+	if err := ops.list(ctx, &out, now); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"2026-10-19 08:00 UTC", "disabled", "failed", "No gateway is running"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("list missing %q:\n%s", want, out.String())
+		}
+	}
+
+	out.Reset()
+	if err := ops.control(ctx, &out, "pause", "brief", now); err != nil {
+		t.Fatal(err)
+	}
+	if paused, _ := ledger.Paused(ctx, "brief"); !paused {
+		t.Error("pause did not stick")
+	}
+	if err := ops.control(ctx, &out, "run", "brief", now); err != nil {
+		t.Fatal(err)
+	}
+	if queued, _ := ledger.TakeRunRequests(ctx); len(queued) != 1 || queued[0] != "brief" {
+		t.Errorf("queued = %v", queued)
+	}
+	if err := ops.control(ctx, &out, "run", "missing", now); err == nil || !strings.Contains(err.Error(), "have: brief, old") {
+		t.Errorf("unknown schedule: %v", err)
+	}
+
+	out.Reset()
+	if err := ops.history(ctx, &out, "brief", 10, now); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "agent stream: unavailable") || !strings.Contains(out.String(), "10/2") {
+		t.Errorf("history:\n%s", out.String())
+	}
+}
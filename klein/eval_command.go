@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fpt/klein-cli/internal/eval"
+)
+
+const evalUsage = `Usage:
+  klein eval [flags] [testcase...]
+
+Runs the testsuite: every testcase (all of them, or those named) against every
+backend in <suite>/backends, each run in a fresh temporary copy of the case
+with its own base_dir. Each run is measured from its JSONL trace (iterations,
+LLM calls, tokens, cost, tool calls) and timed. results.json, junit.xml and
+report.md are written under --out.
+
+With --baseline, the run is diffed against an earlier results.json: a pass
+turning into a fail, or tokens, cost, iterations or tool calls growing past
+--tolerance, is a regression. The command exits 1 on any failure or
+regression.
+
+Examples:
+  klein eval                                        # whole suite, every backend
+  klein eval --backends anthropic fibonacci coding  # two cases on one backend
+  klein eval --backends openai:gpt-5-mini,openai    # one backend, two models
+  klein eval --baseline testsuite/baseline.json     # fail on regressions
+  klein eval --update-baseline                      # record this run as the baseline
+
+Flags:
+`
+
+// evalOptions are the parsed flags of `klein eval`.
+type evalOptions struct {
+	suite          string
+	backends       []string
+	cases          []string
+	concurrency    int
+	timeout        time.Duration
+	out            string
+	baseline       string
+	updateBaseline bool
+	tolerance      float64
+	prices         string
+	binary         string
+	keep           bool
+}
+
+func parseEvalFlags(args []string, stderr io.Writer) (evalOptions, error) {
+	var opts evalOptions
+	fs := flag.NewFlagSet("eval", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&opts.suite, "suite", "testsuite", "Suite directory holding testcases/ and backends/")
+	backends := fs.String("backends", "", "Comma-separated backend[:model] targets (default: every file in <suite>/backends)")
+	fs.IntVar(&opts.concurrency, "j", 4, "Runs to execute at once")
+	fs.DurationVar(&opts.timeout, "timeout", 10*time.Minute, "Per-run timeout, unless the case's config.json sets one")
+	fs.StringVar(&opts.out, "out", "", "Directory for results.json, junit.xml and report.md (default: <suite>/results/<timestamp>)")
+	fs.StringVar(&opts.baseline, "baseline", "", "results.json of an earlier run to diff against (default with --update-baseline: <suite>/baseline.json)")
+	fs.BoolVar(&opts.updateBaseline, "update-baseline", false, "Write this run's results to the baseline file")
+	fs.Float64Var(&opts.tolerance, "tolerance", eval.DefaultTolerance, "Growth in a metric tolerated before it counts as a regression (0.2 = 20%)")
+	fs.StringVar(&opts.prices, "prices", "", "JSON file of model prices (USD per million tokens) laid over the built-in table")
+	fs.StringVar(&opts.binary, "klein", "", "klein binary to evaluate (default: this one)")
+	fs.BoolVar(&opts.keep, "keep", false, "Keep every run's directory, not only those of failed runs")
+	fs.Usage = func() {
+		fmt.Fprint(stderr, evalUsage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return opts, err
+	}
+	for _, b := range strings.Split(*backends, ",") {
+		if b = strings.TrimSpace(b); b != "" {
+			opts.backends = append(opts.backends, b)
+		}
+	}
+	opts.cases = fs.Args()
+	if opts.updateBaseline && opts.baseline == "" {
+		opts.baseline = filepath.Join(opts.suite, "baseline.json")
+	}
+	if opts.tolerance < 0 {
+		return opts, errors.New("--tolerance must not be negative")
+	}
+	return opts, nil
+}
+
+// runEvalCommand implements `klein eval`.
+func runEvalCommand(args []string) int {
+	opts, err := parseEvalFlags(args, os.Stderr)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 2
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	return evalRun(ctx, opts, os.Stdout)
+}
+
+// evalRun runs the suite as opts say and reports to w, returning the exit code.
+func evalRun(ctx context.Context, opts evalOptions, w io.Writer) int {
+	cases, err := eval.LoadCases(filepath.Join(opts.suite, "testcases"), opts.cases)
+	if err != nil {
+		fmt.Fprintln(w, "Error:", err)
+		return 1
+	}
+	targets, err := eval.ResolveTargets(filepath.Join(opts.suite, "backends"), opts.backends)
+	if err != nil {
+		fmt.Fprintln(w, "Error:", err)
+		return 1
+	}
+	prices := eval.DefaultPrices
+	if opts.prices != "" {
+		if prices, err = eval.LoadPrices(opts.prices); err != nil {
+			fmt.Fprintln(w, "Error:", err)
+			return 1
+		}
+	}
+	// A missing baseline is only an error when it is to be compared against.
+	var baseline *eval.Report
+	if opts.baseline != "" {
+		baseline, err = eval.ReadReport(opts.baseline)
+		if err != nil && !(opts.updateBaseline && errors.Is(err, os.ErrNotExist)) {
+			fmt.Fprintln(w, "Error: baseline:", err)
+			return 1
+		}
+	}
+	binary := opts.binary
+	if binary == "" {
+		if binary, err = os.Executable(); err != nil {
+			fmt.Fprintln(w, "Error: cannot locate the klein binary; pass --klein:", err)
+			return 1
+		}
+	}
+	if binary, err = filepath.Abs(binary); err != nil {
+		fmt.Fprintln(w, "Error:", err)
+		return 1
+	}
+	suite, err := filepath.Abs(opts.suite)
+	if err != nil {
+		fmt.Fprintln(w, "Error:", err)
+		return 1
+	}
+
+	report := &eval.Report{Started: time.Now(), Version: gitRevision(suite)}
+	out := opts.out
+	if out == "" {
+		out = filepath.Join(opts.suite, "results", report.Started.Format("20060102_150405"))
+	}
+	fmt.Fprintf(w, "🧪 klein eval: %d testcases × %d targets, %d at a time\n", len(cases), len(targets), max(opts.concurrency, 1))
+	fmt.Fprintf(w, "Binary: %s\n\n", binary)
+
+	var mu sync.Mutex
+	runner := &eval.Runner{
+		Binary:      binary,
+		SuiteDir:    suite,
+		Concurrency: opts.concurrency,
+		Timeout:     opts.timeout,
+		Prices:      prices,
+		KeepAll:     opts.keep,
+		Progress: func(res eval.Result) {
+			mu.Lock()
+			defer mu.Unlock()
+			fmt.Fprintln(w, res.Line())
+			if res.WorkDir != "" && res.Status == eval.StatusFail {
+				fmt.Fprintf(w, "   kept in %s\n", res.WorkDir)
+			}
+		},
+	}
+	report.Results = runner.Run(ctx, cases, targets)
+
+	var diff *eval.Diff
+	if baseline != nil {
+		diff = eval.Compare(baseline, report, opts.tolerance)
+	}
+	if err := writeEvalReports(out, report, diff); err != nil {
+		fmt.Fprintln(w, "Error:", err)
+		return 1
+	}
+
+	sum := report.Summary()
+	fmt.Fprintf(w, "\n%d passed, %d failed, %d skipped · $%.4f · reports in %s\n", sum.Pass, sum.Fail, sum.Skip, sum.CostUSD, out)
+	regressions := 0
+	if diff != nil {
+		fmt.Fprintf(w, "\nAgainst %s:\n", opts.baseline)
+		if len(diff.Changes) == 0 {
+			fmt.Fprintf(w, "  no changes beyond ±%.0f%%\n", opts.tolerance*100)
+		}
+		for _, c := range diff.Changes {
+			mark := "🟢"
+			if c.Regression {
+				mark = "🔴"
+				regressions++
+			}
+			fmt.Fprintf(w, "  %s %s\n", mark, c)
+		}
+	}
+	if opts.updateBaseline {
+		if err := writeFile(opts.baseline, report.WriteJSON); err != nil {
+			fmt.Fprintln(w, "Error:", err)
+			return 1
+		}
+		fmt.Fprintf(w, "\nBaseline updated: %s\n", opts.baseline)
+	}
+	if sum.Fail > 0 || regressions > 0 {
+		return 1
+	}
+	return 0
+}
+
+// writeEvalReports writes the report's three forms into dir.
+func writeEvalReports(dir string, report *eval.Report, diff *eval.Diff) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	if err := writeFile(filepath.Join(dir, "results.json"), report.WriteJSON); err != nil {
+		return err
+	}
+	if err := writeFile(filepath.Join(dir, "junit.xml"), report.WriteJUnit); err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(dir, "report.md"), func(w io.Writer) error {
+		report.WriteMarkdown(w, diff)
+		return nil
+	})
+}
+
+func writeFile(path string, write func(io.Writer) error) error {
+	f, err := os.Create(path) //nolint:gosec // a report path the user chose
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// gitRevision names the checkout the suite lives in, so a report says which
+// code it measured; "" outside git.
+func gitRevision(dir string) string {
+	out, err := exec.Command("git", "-C", dir, "describe", "--always", "--dirty").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
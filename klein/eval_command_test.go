@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseEvalFlags(t *testing.T) {
+	opts, err := parseEvalFlags([]string{"--suite", "s", "--backends", "openai:gpt-5-mini, anthropic", "--update-baseline", "fibonacci", "coding"}, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(opts.backends, "|") != "openai:gpt-5-mini|anthropic" || strings.Join(opts.cases, "|") != "fibonacci|coding" {
+		t.Errorf("backends = %q, cases = %q", opts.backends, opts.cases)
+	}
+	if opts.baseline != filepath.Join("s", "baseline.json") {
+		t.Errorf("baseline = %q, want the suite's baseline.json", opts.baseline)
+	}
+	if _, err := parseEvalFlags([]string{"--tolerance", "-1"}, io.Discard); err == nil {
+		t.Error("a negative tolerance was accepted")
+	}
+}
+
+// TestEvalRun records a baseline and diffs a second run against it, with a
+// stand-in binary whose check passes only while a marker file exists.
+func TestEvalRun(t *testing.T) {
+	suite := t.TempDir()
+	marker := filepath.Join(t.TempDir(), "pass")
+	files := map[string]string{
+		"testcases/hello/prompt.txt": "say hello",
+		"testcases/hello/check.sh":   "#!/bin/sh\ngrep -q hello \"$1\" && test -e " + marker + "\n",
+		"backends/openai.toml":       "[llm]\nbackend = \"openai\"\nmodel = \"gpt-5-mini\"\n",
+		"klein":                      "#!/bin/sh\necho hello\n",
+		"pass":                       "",
+	}
+	for rel, content := range files {
+		path := filepath.Join(suite, rel)
+		if rel == "pass" {
+			path = marker
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("OPENAI_API_KEY", "test")
+	t.Setenv("TMPDIR", t.TempDir()) // where the failed run's directory is kept
+
+	run := func(out string, extra ...string) (int, string) {
+		args := append([]string{"--suite", suite, "--klein", filepath.Join(suite, "klein"), "--out", out}, extra...)
+		opts, err := parseEvalFlags(args, io.Discard)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var w bytes.Buffer
+		return evalRun(context.Background(), opts, &w), w.String()
+	}
+
+	out1 := filepath.Join(t.TempDir(), "r1")
+	if code, log := run(out1, "--update-baseline"); code != 0 || !strings.Contains(log, "1 passed, 0 failed") {
+		t.Fatalf("first run exited %d:\n%s", code, log)
+	}
+	for _, f := range []string{"results.json", "junit.xml", "report.md"} {
+		if _, err := os.Stat(filepath.Join(out1, f)); err != nil {
+			t.Error(err)
+		}
+	}
+	baseline := filepath.Join(suite, "baseline.json")
+	if _, err := os.Stat(baseline); err != nil {
+		t.Fatalf("baseline not written: %v", err)
+	}
+
+	if err := os.Remove(marker); err != nil {
+		t.Fatal(err)
+	}
+	out2 := filepath.Join(t.TempDir(), "r2")
+	code, log := run(out2, "--baseline", baseline)
+	if code != 1 || !strings.Contains(log, "🔴 hello × openai: status pass → fail") {
+		t.Errorf("regressed run exited %d:\n%s", code, log)
+	}
+	report, err := os.ReadFile(filepath.Join(out2, "report.md"))
+	if err != nil || !strings.Contains(string(report), "## Against the baseline") {
+		t.Errorf("report.md lacks the baseline diff (err %v):\n%s", err, report)
+	}
+}
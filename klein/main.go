@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
@@ -18,12 +19,12 @@ import (
 	pluginpkg "github.com/fpt/klein-cli/internal/plugin"
 	"github.com/fpt/klein-cli/internal/skill"
 	"github.com/fpt/klein-cli/internal/tool"
-	"github.com/fpt/klein-cli/internal/tool/memorydb"
 	"github.com/fpt/klein-cli/pkg/agent/domain"
 	"github.com/fpt/klein-cli/pkg/agentserver"
 	client "github.com/fpt/klein-cli/pkg/client"
 	pkgLogger "github.com/fpt/klein-cli/pkg/logger"
 	"github.com/fpt/klein-cli/pkg/message"
+	"github.com/fpt/klein-cli/pkg/tokenizer"
 )
 
 // stringSliceFlag implements flag.Value for repeatable --plugin arguments.
@@ -116,23 +117,64 @@ func printUsage() {
 	fmt.Println("  klein -f prompts.txt                     # Multi-turn from file (no memory)")
 	fmt.Println("  klein -v \"Debug this issue\"              # Enable verbose debug logging")
 	fmt.Println("  klein -l                                 # Show conversation history")
+	fmt.Println("  klein sessions list                      # List this project's saved sessions")
+	fmt.Println("  klein sessions resume <id>               # Resume a specific session (also /resume in the REPL)")
+	fmt.Println("  klein sessions import-claude [<id>]      # Continue a Claude Code session here (also /claude in the REPL)")
+	fmt.Println("  klein memory search <query>              # Search long-term memory (see: klein memory)")
+	fmt.Println("  klein plugin add <path|git-url>          # Install a plugin or marketplace (see: klein plugin)")
+	fmt.Println("  klein trace show <id>                    # Show a session's trace as a timed tree ([trace] in settings)")
+	fmt.Println("  klein eval --backends anthropic          # Run the testsuite and report metrics (see: klein eval -h)")
 	fmt.Println("  klein --json-schema '{\"type\":\"object\",...}' \"...\"  # Structured output (inline schema)")
 	fmt.Println("  klein --json-schema schema.json \"...\"               # Structured output (schema file)")
 	fmt.Println()
 }
 
 func main() {
+	// run returns rather than exiting so its deferred cleanup — flushing trace
+	// spans, closing MCP servers and the agent session — happens on failure too.
+	os.Exit(run())
+}
+
+func run() int {
 	ctx := context.Background()
 
 	// Subcommands are handled before flag parsing (flag treats them as a prompt).
 	if len(os.Args) > 1 && os.Args[1] == "mcp" {
-		os.Exit(runMCPCommand(os.Args[2:]))
+		return runMCPCommand(os.Args[2:])
 	}
 	if len(os.Args) > 1 && os.Args[1] == "claw" {
-		os.Exit(runClawCommand(os.Args[2:]))
+		return runClawCommand(os.Args[2:])
 	}
 	if len(os.Args) > 1 && os.Args[1] == "review" {
-		os.Exit(runReviewCommand(os.Args[2:]))
+		return runReviewCommand(os.Args[2:])
+	}
+	if len(os.Args) > 1 && os.Args[1] == "memory" {
+		return runMemoryCommand(os.Args[2:])
+	}
+	if len(os.Args) > 1 && os.Args[1] == "plugin" {
+		return runPluginCommand(os.Args[2:])
+	}
+	if len(os.Args) > 1 && os.Args[1] == "permissions" {
+		return runPermissionsCommand(os.Args[2:])
+	}
+	if len(os.Args) > 1 && os.Args[1] == "trace" {
+		return runTraceCommand(os.Args[2:])
+	}
+	if len(os.Args) > 1 && os.Args[1] == "eval" {
+		return runEvalCommand(os.Args[2:])
+	}
+	if len(os.Args) > 1 && os.Args[1] == "sessions" {
+		// Resuming is an ordinary run with --resume, so it falls through to the
+		// flag parsing below and keeps every other flag working.
+		if len(os.Args) > 2 && os.Args[2] == "resume" {
+			if len(os.Args) < 4 || strings.HasPrefix(os.Args[3], "-") {
+				fmt.Println(sessionsUsage)
+				return 1
+			}
+			os.Args = append([]string{os.Args[0], "--resume"}, os.Args[3:]...)
+		} else {
+			return runSessionsCommand(os.Args[2:])
+		}
 	}
 
 	// Define command line flags
@@ -155,6 +197,7 @@ func main() {
 	var continueSession = flag.Bool("c", false, "Resume this project's most recent session (default: start fresh)")
 	var continueSessionLong = flag.Bool("continue", false,
 		"Resume this project's most recent session (default: start fresh)")
+	var resumeSession = flag.String("resume", "", "Resume a specific saved session by id or unique id prefix (list them with: klein sessions list)")
 	var promptFile = flag.String("f", "", "File containing multi-turn prompts separated by '----' (no memory between turns)")
 	var verbose = flag.Bool("v", false, "Enable verbose logging (debug level)")
 	var verboseLong = flag.Bool("verbose", false, "Enable verbose logging (debug level)")
@@ -184,7 +227,7 @@ func main() {
 	// Handle help flag
 	if *help || *helpLong {
 		flag.Usage()
-		return
+		return 0
 	}
 
 	// Resolve long/short flag conflicts (prefer the one that was set)
@@ -208,7 +251,7 @@ func main() {
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		fmt.Fprintln(os.Stderr, "Fix the settings file (or pass --settings <path>) and try again.")
-		os.Exit(1)
+		return 1
 	}
 
 	// Initialize structured logger based on settings
@@ -245,14 +288,18 @@ func main() {
 	// Validate settings
 	if err := config.ValidateSettings(settings); err != nil {
 		logger.Error("Settings validation failed", "error", err)
-		os.Exit(1)
+		return 1
 	}
 
+	stopTracing := startTracing(ctx, settings, logger)
+	defer stopTracing()
+	configureTokenizer(settings)
+
 	// Create LLM client based on settings
 	llmClient, err := client.NewLLMClient(settings.LLM)
 	if err != nil {
 		logger.Error("Failed to create LLM client", "error", err)
-		os.Exit(1)
+		return 1
 	}
 
 	// Determine working directory
@@ -261,7 +308,7 @@ func main() {
 		if _, err := os.Stat(workingDirectory); err != nil {
 			logger.Error("Working directory does not exist",
 				"directory", workingDirectory, "error", err)
-			os.Exit(1)
+			return 1
 		}
 		fmt.Printf("Working directory: %s\n", workingDirectory)
 	} else {
@@ -273,7 +320,19 @@ func main() {
 	// a typo costs nothing.
 	if roleErr := validateRole(resolvedRole, workingDirectory); roleErr != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", roleErr)
-		os.Exit(1)
+		return 1
+	}
+
+	// A named session must exist; unlike --continue there is no sensible
+	// fallback to a fresh one.
+	var resumeSessionFile string
+	if *resumeSession != "" {
+		resumeSessionFile, err = resolveResumeSession(workingDirectory, *resumeSession)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			fmt.Fprintln(os.Stderr, "Run `klein sessions list` to see this project's sessions.")
+			return 1
+		}
 	}
 
 	// Load plugins. Plugin MCP servers are merged into settings.MCP.Servers
@@ -281,6 +340,7 @@ func main() {
 	// settings-defined servers. Commands/agents/skills are merged into the
 	// agent after construction via RegisterPlugins.
 	loadedPlugins := loadPluginsFromFlags(*pluginMarketplace, pluginPaths, logger)
+	loadedPlugins = append(loadedPlugins, loadPluginsFromSettings(settings, logger)...)
 	for _, p := range loadedPlugins {
 		settings.MCP.Servers = append(settings.MCP.Servers, p.MCPServers...)
 	}
@@ -337,7 +397,7 @@ func main() {
 		// backed by sqlite under the shared memory dir. Degrade gracefully if it
 		// can't be opened, matching MCP tool behavior.
 		kbPath := filepath.Join(memDir, "memory.sqlite")
-		if kb, kbErr := memorydb.NewManager(kbPath); kbErr != nil {
+		if kb, kbErr := openMemoryDB(settings, kbPath, logger); kbErr != nil {
 			logger.Warn("Long-term memory (memorydb) disabled", "error", kbErr)
 		} else {
 			mcpToolManagers["memorydb"] = kb
@@ -357,7 +417,7 @@ func main() {
 		)
 		if startErr != nil {
 			logger.Error("Failed to start agent backend", "error", startErr)
-			os.Exit(1)
+			return 1
 		}
 		var agentBackend domain.AgentBackend
 		if backendRunner != nil {
@@ -370,9 +430,9 @@ func main() {
 			ctx, *serveAddr, settings, mcpToolManagers, logger, sessDir, agentBackend,
 		); serveErr != nil {
 			logger.Error("Server failed", "error", serveErr)
-			os.Exit(1)
+			return 1
 		}
-		return
+		return 0
 	}
 
 	// Whole-agent backend for interactive/one-shot CLI (plain `klein -b codex` or `-b appserver`).
@@ -398,7 +458,7 @@ func main() {
 	// errors). One-shot/file mode stays ephemeral. Degrade gracefully on failure.
 	if isInteractiveMode {
 		kbPath := settings.MemoryDBFile()
-		if kb, kbErr := memorydb.NewManager(kbPath); kbErr != nil {
+		if kb, kbErr := openMemoryDB(settings, kbPath, logger); kbErr != nil {
 			logger.Warn("Long-term memory (memorydb) disabled", "error", kbErr)
 		} else {
 			mcpToolManagers["memorydb"] = kb
@@ -417,12 +477,13 @@ func main() {
 		SkipSessionRestore: skipSessionRestore,
 		IsInteractiveMode:  isInteractiveMode,
 		ContinueSession:    resolvedContinue,
+		ResumeSession:      resumeSessionFile,
 		LLMClient:          llmClient,
 		AgentBackend:       agentbackend.Select(settings, logger, backendOpts),
 	})
 	if err != nil {
 		logger.Error("Failed to create agent", "error", err)
-		os.Exit(1)
+		return 1
 	}
 	defer cleanup()
 
@@ -453,7 +514,7 @@ func main() {
 		} else {
 			fmt.Println("No conversation history found.")
 		}
-		return
+		return 0
 	}
 
 	// Show which skill is being used
@@ -461,30 +522,28 @@ func main() {
 
 	// Handle multi-turn prompt file if specified
 	if *promptFile != "" {
-		executeMultiTurnFile(ctx, a, *promptFile, resolvedRole)
-		return
+		return executeMultiTurnFile(ctx, a, *promptFile, resolvedRole)
 	}
 
 	// JSON Schema mode: bypass skill/agent system, emit raw JSON to stdout.
 	if *jsonSchema != "" {
 		if len(args) == 0 {
 			fmt.Fprintln(os.Stderr, "Error: --json-schema requires a prompt argument")
-			os.Exit(1)
+			return 1
 		}
-		executeWithSchema(ctx, llmClient, strings.Join(args, " "), *jsonSchema)
-		return
+		return executeWithSchema(ctx, llmClient, strings.Join(args, " "), *jsonSchema)
 	}
 
 	// Determine if we should run in interactive mode or one-shot mode
 	if len(args) > 0 {
 		userInput := strings.Join(args, " ")
-		executeCommand(ctx, a, userInput, resolvedRole)
-	} else {
-		app.StartInteractiveMode(ctx, a, resolvedRole)
+		return executeCommand(ctx, a, userInput, resolvedRole)
 	}
+	app.StartInteractiveMode(ctx, a, resolvedRole)
+	return 0
 }
 
-func executeCommand(ctx context.Context, a *app.Agent, userInput string, skillName string) {
+func executeCommand(ctx context.Context, a *app.Agent, userInput string, skillName string) int {
 	fmt.Print("\n")
 
 	var response message.Message
@@ -500,7 +559,7 @@ func executeCommand(ctx context.Context, a *app.Agent, userInput string, skillNa
 			response, err = a.InvokeCommand(ctx, cmd, cmdArgs, skillName)
 		} else if ambiguous {
 			fmt.Fprintf(os.Stderr, "Command %q is ambiguous; use /<plugin>:%s.\n", name, name)
-			os.Exit(1)
+			return 1
 		} else {
 			response, err = a.Invoke(ctx, userInput, skillName)
 		}
@@ -510,7 +569,7 @@ func executeCommand(ctx context.Context, a *app.Agent, userInput string, skillNa
 
 	if err != nil {
 		fmt.Printf("Command execution failed: %v\n", err)
-		os.Exit(1)
+		return 1
 	}
 
 	w := a.OutWriter()
@@ -518,19 +577,20 @@ func executeCommand(ctx context.Context, a *app.Agent, userInput string, skillNa
 	app.WriteResponseHeader(w, model, false)
 	fmt.Fprintln(w, response.Content())
 	printTokenUsage(a.GetLLMClient())
+	return 0
 }
 
-func executeMultiTurnFile(ctx context.Context, a *app.Agent, filePath string, skillName string) {
+func executeMultiTurnFile(ctx context.Context, a *app.Agent, filePath string, skillName string) int {
 	content, err := os.ReadFile(filePath)
 	if err != nil {
 		fmt.Printf("Failed to read prompt file '%s': %v\n", filePath, err)
-		os.Exit(1)
+		return 1
 	}
 
 	prompts := strings.Split(string(content), "----")
 	if len(prompts) == 0 {
 		fmt.Printf("No prompts found in file '%s'\n", filePath)
-		os.Exit(1)
+		return 1
 	}
 
 	fmt.Printf("Executing %d turns from file: %s\n", len(prompts), filePath)
@@ -561,13 +621,14 @@ func executeMultiTurnFile(ctx context.Context, a *app.Agent, filePath string, sk
 	}
 
 	fmt.Println("All turns completed.")
+	return 0
 }
 
 // executeWithSchema performs a one-shot structured output call using the provided
 // JSON Schema. schemaArg may be an inline JSON string or a file path — inline is
 // tried first; if it is not valid JSON the value is treated as a path.
 // The agent/skill system is bypassed; the raw JSON result is written to stdout.
-func executeWithSchema(ctx context.Context, llm domain.LLM, prompt string, schemaArg string) {
+func executeWithSchema(ctx context.Context, llm domain.LLM, prompt string, schemaArg string) int {
 	var schema map[string]any
 
 	// Try inline JSON first (matches Claude Code's --json-schema behaviour).
@@ -576,26 +637,34 @@ func executeWithSchema(ctx context.Context, llm domain.LLM, prompt string, schem
 		schemaBytes, readErr := os.ReadFile(schemaArg)
 		if readErr != nil {
 			fmt.Fprintf(os.Stderr, "Error: %q is neither valid JSON nor a readable file: %v\n", schemaArg, readErr)
-			os.Exit(1)
+			return 1
 		}
 		if err := json.Unmarshal(schemaBytes, &schema); err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %q is not valid JSON: %v\n", schemaArg, err)
-			os.Exit(1)
+			return 1
 		}
 	}
 
 	result, err := client.InvokeWithSchema(ctx, llm, prompt, schema)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		return 1
 	}
 
 	out, err := json.MarshalIndent(result, "", "  ")
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: failed to format result: %v\n", err)
-		os.Exit(1)
+		return 1
 	}
 	fmt.Println(string(out))
+	return 0
+}
+
+// configureTokenizer points token counting at the vocabulary cache and, when
+// [tokenizer] fetch is set, lets it download a missing vocabulary there.
+func configureTokenizer(settings *config.Settings) {
+	tokenizer.SetCacheDir(settings.TokenizersDir())
+	tokenizer.SetFetch(settings.Tokenizer.Fetch)
 }
 
 // printTokenUsage prints a [usage] line to stderr if the client exposes token usage.
@@ -646,6 +715,43 @@ func loadPluginsFromFlags(marketplace string, pluginDirs []string, logger *pkgLo
 	return out
 }
 
+// loadPluginsFromSettings loads the enabled [plugins.*] entries that
+// `klein plugin add` installed: a git source from the checkout of the commit
+// plugins.lock pins, a local directory in place. Like the flag-loaded
+// plugins, a missing or broken one is logged and skipped.
+func loadPluginsFromSettings(settings *config.Settings, logger *pkgLogger.Logger) []*pluginpkg.Plugin {
+	if len(settings.Plugins) == 0 {
+		return nil
+	}
+	lock, err := pluginpkg.ReadLock(config.PluginsLockFile(settings.FilePath()))
+	if err != nil {
+		logger.Warn("Failed to read the plugin lockfile; git plugins are not loaded", "error", err)
+		lock = &pluginpkg.Lock{}
+	}
+	store := &pluginpkg.Store{Dir: settings.PluginsDir()}
+
+	var out []*pluginpkg.Plugin
+	for _, name := range sortedPluginNames(settings.Plugins) {
+		spec := settings.Plugins[name]
+		if !spec.IsEnabled() {
+			continue
+		}
+		locked, ok := lock.Plugins[name]
+		plugins, err := store.Load(pluginpkg.Entry{Name: name, Source: spec.Source, Ref: spec.Ref}, locked, ok)
+		if errors.Is(err, pluginpkg.ErrNotInstalled) {
+			logger.Warn("Plugin is not installed at its locked commit; run `klein plugin update --locked`", "plugin", name)
+			continue
+		}
+		if err != nil {
+			logger.Warn("Failed to load plugin", "plugin", name, "error", err)
+			continue
+		}
+		out = append(out, plugins...)
+		fmt.Printf("Loaded plugin %q (%d plugin(s)) from settings\n", name, len(plugins))
+	}
+	return out
+}
+
 // terminalApprover prompts the user (y/N) for a backend's on-request approvals.
 // It reads a line from stdin byte-by-byte so it doesn't buffer ahead of the
 // REPL's readline (the two never read concurrently — the approver only runs
@@ -0,0 +1,464 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fpt/klein-cli/internal/config"
+	"github.com/fpt/klein-cli/internal/tool/memorydb"
+	pkgLogger "github.com/fpt/klein-cli/pkg/logger"
+)
+
+// runMemoryCommand implements `klein memory <search|show|forget|restore|stats|
+// export|import|maintain>` over the long-term memory store the REPL, --serve
+// and claw share (<base_dir>/memory/memory.sqlite, or --db).
+func runMemoryCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Println(memoryUsage)
+		return 1
+	}
+	sub := args[0]
+	opts, err := parseMemoryArgs(args[1:])
+	if err != nil {
+		fmt.Printf("%v\n\n%s\n", err, memoryUsage)
+		return 1
+	}
+	dbPath := opts.db
+	settings, err := config.LoadSettings(opts.settings)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	if dbPath == "" {
+		dbPath = settings.MemoryDBFile()
+	}
+	logger := pkgLogger.NewLoggerWithConsoleWriter(pkgLogger.LogLevelWarn, os.Stderr)
+	kb, err := openMemoryDB(settings, dbPath, logger)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	defer kb.Close()
+	store := kb.Store()
+
+	ctx := context.Background()
+	switch sub {
+	case "search", "find":
+		err = memorySearch(ctx, store, os.Stdout, opts)
+	case "show":
+		err = memoryShow(ctx, store, os.Stdout, opts.positional)
+	case "forget", "rm":
+		err = memoryForEach(os.Stdout, opts.positional, "forget", func(id int64) (string, error) {
+			return fmt.Sprintf("Forgot #%d.", id), store.Forget(ctx, id)
+		})
+	case "restore":
+		err = memoryForEach(os.Stdout, opts.positional, "restore", func(id int64) (string, error) {
+			return fmt.Sprintf("Restored #%d.", id), store.Restore(ctx, id)
+		})
+	case "stats":
+		err = memoryStats(ctx, store, os.Stdout, dbPath)
+	case "export":
+		err = memoryExport(ctx, store, os.Stdout, opts)
+	case "import":
+		err = memoryImport(ctx, store, os.Stdout, os.Stdin, opts.positional)
+	case "maintain":
+		err = memoryMaintain(ctx, store, os.Stdout, opts)
+	default:
+		fmt.Printf("Unknown memory subcommand %q.\n\n%s\n", sub, memoryUsage)
+		return 1
+	}
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	return 0
+}
+
+const memoryUsage = `Usage:
+  klein memory search <query> [-n <count>]
+  klein memory show <id>
+  klein memory forget <id>...
+  klein memory restore <id>...
+  klein memory stats
+  klein memory export [-o <file>]
+  klein memory import <file|->
+  klein memory maintain [--dry-run] [--archive-after 180d|off] [--half-life 90d|off]
+                        [--similarity 0.8|off]
+
+Every subcommand also takes --settings <path> and --db <path>; the store
+defaults to <base_dir>/memory/memory.sqlite.
+
+export writes JSONL, one memory per line with its whole version history,
+entities, usage and state; import adds such a file to the store, skipping
+memories it already has. maintain fades the learned utility of unused
+memories, merges near-duplicates and archives memories not recalled for a
+while; restore brings an archived memory back. Searching here does not count
+as a use of a memory.
+
+Examples:
+  klein memory search "deploy schedule"
+  klein memory export -o memories.jsonl
+  klein memory maintain --dry-run --archive-after 90d`
+
+// memoryOptions are the parsed flags of a memory subcommand.
+type memoryOptions struct {
+	positional   []string
+	settings     string
+	db           string
+	output       string
+	limit        int
+	dryRun       bool
+	archiveAfter time.Duration
+	halfLife     time.Duration
+	similarity   float64
+}
+
+// parseMemoryArgs separates positional arguments from flags, which may come
+// before or after them.
+func parseMemoryArgs(args []string) (memoryOptions, error) {
+	opts := memoryOptions{limit: 10}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			opts.positional = append(opts.positional, arg)
+			continue
+		}
+		name, value, hasValue := strings.Cut(arg, "=")
+		if name == "--dry-run" {
+			opts.dryRun = true
+			continue
+		}
+		if !hasValue {
+			if i+1 >= len(args) {
+				return opts, fmt.Errorf("%s needs a value", name)
+			}
+			i++
+			value = args[i]
+		}
+		var err error
+		switch name {
+		case "--settings":
+			opts.settings = value
+		case "--db":
+			opts.db = value
+		case "-o", "--output":
+			opts.output = value
+		case "-n", "--limit":
+			opts.limit, err = strconv.Atoi(value)
+			if err == nil && opts.limit <= 0 {
+				err = errors.New("must be positive")
+			}
+		case "--archive-after":
+			opts.archiveAfter, err = parseMaintainPeriod(value)
+		case "--half-life":
+			opts.halfLife, err = parseMaintainPeriod(value)
+		case "--similarity":
+			if value == "off" {
+				opts.similarity = -1
+			} else if opts.similarity, err = strconv.ParseFloat(value, 64); err == nil &&
+				(opts.similarity <= 0 || opts.similarity > 1) {
+				err = errors.New("want a number in (0, 1]")
+			}
+		default:
+			return opts, fmt.Errorf("unknown flag %s", name)
+		}
+		if err != nil {
+			return opts, fmt.Errorf("%s %q: %w", name, value, err)
+		}
+	}
+	return opts, nil
+}
+
+// parseMaintainPeriod accepts a whole number of days ("180d"), a Go duration,
+// or "off" (returned as -1, which memorydb reads as "skip this step").
+func parseMaintainPeriod(s string) (time.Duration, error) {
+	if s == "off" {
+		return -1, nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n > 0 {
+			return time.Duration(n) * 24 * time.Hour, nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil && d > 0 {
+		return d, nil
+	}
+	return 0, errors.New("want a number of days (90d), a duration (36h) or off")
+}
+
+func memorySearch(ctx context.Context, store *memorydb.Store, w io.Writer, opts memoryOptions) error {
+	query := strings.Join(opts.positional, " ")
+	if strings.TrimSpace(query) == "" {
+		return errors.New("search needs a query")
+	}
+	hits, err := store.Search(ctx, query, opts.limit)
+	if err != nil {
+		return err
+	}
+	if len(hits) == 0 {
+		fmt.Fprintf(w, "No memories match %q.\n", query)
+		return nil
+	}
+	for _, h := range hits {
+		fmt.Fprintf(w, "#%-5d %-10s %.2f  %s\n", h.ID, h.Kind, h.Score, oneLine(h.Content, 100))
+	}
+	return nil
+}
+
+func memoryShow(ctx context.Context, store *memorydb.Store, w io.Writer, args []string) error {
+	if len(args) != 1 {
+		return errors.New("show needs exactly one memory id")
+	}
+	id, err := parseMemoryID(args[0])
+	if err != nil {
+		return err
+	}
+	st, err := store.Stat(ctx, id)
+	if errors.Is(err, memorydb.ErrNotFound) {
+		return fmt.Errorf("no memory #%d", id)
+	}
+	if err != nil {
+		return err
+	}
+	mem, err := store.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "#%d  [%s]  v%d  %s\n", st.ID, st.Kind, st.Version, memoryState(st))
+	fmt.Fprintf(w, "  %s\n", st.Content)
+	if len(mem.Entities) > 0 {
+		fmt.Fprintf(w, "  entities:   %s\n", strings.Join(mem.Entities, ", "))
+	}
+	fmt.Fprintf(w, "  importance: %.2f   utility: %+.3f\n", st.Importance, st.UtilityEMA)
+	fmt.Fprintf(w, "  recalled:   %d×, last %s\n", st.AccessCount, formatMemoryTime(st.LastUsedAt))
+	if st.UsefulCount > 0 || st.HarmfulCount > 0 {
+		fmt.Fprintf(w, "  feedback:   +%.2f / -%.2f\n", st.UsefulCount, st.HarmfulCount)
+	}
+	fmt.Fprintf(w, "  created:    %s\n", formatMemoryTime(st.CreatedAt))
+
+	hist, err := store.History(ctx, id)
+	if err != nil {
+		return err
+	}
+	if len(hist) > 1 {
+		fmt.Fprintf(w, "  history:\n")
+		for _, h := range hist {
+			marker := " "
+			if h.ID == id {
+				marker = "*"
+			}
+			fmt.Fprintf(w, "   %s v%d #%d %s  %s\n", marker, h.Version, h.ID,
+				h.CreatedAt.Local().Format("2006-01-02"), oneLine(h.Content, 80))
+		}
+	}
+	return nil
+}
+
+// memoryState describes whether a memory takes part in recall, and if not why.
+func memoryState(st memorydb.MemoryStat) string {
+	switch {
+	case st.Active:
+		return "active"
+	case st.MergedInto != 0:
+		return fmt.Sprintf("merged into #%d", st.MergedInto)
+	case !st.ArchivedAt.IsZero():
+		return "archived " + st.ArchivedAt.Local().Format("2006-01-02")
+	}
+	return "inactive (superseded or forgotten)"
+}
+
+// memoryForEach applies op to each id argument, reporting every outcome.
+func memoryForEach(w io.Writer, args []string, verb string, op func(id int64) (string, error)) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%s needs at least one memory id", verb)
+	}
+	failed := 0
+	for _, arg := range args {
+		id, err := parseMemoryID(arg)
+		if err == nil {
+			var done string
+			if done, err = op(id); err == nil {
+				fmt.Fprintln(w, done)
+				continue
+			}
+			if errors.Is(err, memorydb.ErrNotFound) {
+				err = fmt.Errorf("no memory #%d to %s", id, verb)
+			}
+		}
+		fmt.Fprintln(w, err)
+		failed++
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d failed", failed, len(args))
+	}
+	return nil
+}
+
+func memoryStats(ctx context.Context, store *memorydb.Store, w io.Writer, dbPath string) error {
+	st, err := store.Stats(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Store:       %s", dbPath)
+	if info, err := os.Stat(dbPath); err == nil {
+		fmt.Fprintf(w, " (%.1f KiB)", float64(info.Size())/1024)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Active:      %d (%d entities)\n", st.Active, st.Entities)
+	if len(st.ByKind) > 0 {
+		fmt.Fprintf(w, "By kind:     %s\n", formatCounts(st.ByKind))
+	}
+	fmt.Fprintf(w, "Inactive:    %d superseded, %d forgotten, %d archived, %d merged\n",
+		st.Superseded, st.Forgotten, st.Archived, st.Merged)
+	if len(st.Vectors) > 0 {
+		fmt.Fprintf(w, "Embeddings:  %s\n", formatCounts(st.Vectors))
+	}
+	fmt.Fprintf(w, "Maintained:  %s\n", formatMemoryTime(st.LastMaintained))
+	return nil
+}
+
+// formatCounts renders counts as "name n" pairs, largest first.
+func formatCounts(counts map[string]int) string {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s %d", name, counts[name])
+	}
+	return strings.Join(parts, ", ")
+}
+
+func memoryExport(ctx context.Context, store *memorydb.Store, stdout io.Writer, opts memoryOptions) error {
+	if len(opts.positional) > 0 {
+		return errors.New("export takes no arguments; use -o <file> to write a file")
+	}
+	if opts.output == "" {
+		_, err := store.ExportJSONL(ctx, stdout)
+		return err
+	}
+	f, err := os.Create(opts.output)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", opts.output, err)
+	}
+	n, err := store.ExportJSONL(ctx, f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(opts.output)
+		return err
+	}
+	fmt.Fprintf(stdout, "Exported %d memories to %s.\n", n, opts.output)
+	return nil
+}
+
+func memoryImport(ctx context.Context, store *memorydb.Store, w io.Writer, stdin io.Reader, args []string) error {
+	if len(args) != 1 {
+		return errors.New("import needs one file (or - for stdin)")
+	}
+	r := stdin
+	if args[0] != "-" {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return fmt.Errorf("open %s: %w", args[0], err)
+		}
+		defer f.Close()
+		r = f
+	}
+	res, err := store.ImportJSONL(ctx, r)
+	fmt.Fprintf(w, "Imported %d memories (%d versions); skipped %d already stored.\n",
+		res.Chains, res.Memories, res.Skipped)
+	return err
+}
+
+func memoryMaintain(ctx context.Context, store *memorydb.Store, w io.Writer, opts memoryOptions) error {
+	if len(opts.positional) > 0 {
+		return errors.New("maintain takes no arguments")
+	}
+	res, err := store.Maintain(ctx, memorydb.MaintainOptions{
+		UtilityHalfLife:     opts.halfLife,
+		DuplicateSimilarity: opts.similarity,
+		ArchiveAfter:        opts.archiveAfter,
+		DryRun:              opts.dryRun,
+	})
+	if err != nil {
+		return err
+	}
+	for _, m := range res.Merges {
+		fmt.Fprintf(w, "merge   #%d ← %s\n", m.Kept, formatIDs(m.Dropped))
+	}
+	for _, id := range res.Archived {
+		fmt.Fprintf(w, "archive #%d\n", id)
+	}
+	fmt.Fprintf(w, "Memory maintenance: %s.\n", res)
+	return nil
+}
+
+func formatIDs(ids []int64) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = "#" + strconv.FormatInt(id, 10)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func parseMemoryID(s string) (int64, error) {
+	id, err := strconv.ParseInt(strings.TrimPrefix(s, "#"), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("bad memory id %q", s)
+	}
+	return id, nil
+}
+
+func formatMemoryTime(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+// openMemoryDB opens the long-term memory store at path and, when
+// [memory.embeddings] names a model, enables semantic recall on it. A bad
+// embeddings config only costs the semantic half: recall stays lexical.
+func openMemoryDB(settings *config.Settings, path string, logger *pkgLogger.Logger) (*memorydb.Manager, error) {
+	kb, err := memorydb.NewManager(path)
+	if err != nil {
+		return nil, err
+	}
+	emb := settings.Memory.Embeddings
+	if emb.Model == "" {
+		return kb, nil
+	}
+	keyEnv := emb.APIKeyEnv
+	if keyEnv == "" {
+		keyEnv = "OPENAI_API_KEY"
+	}
+	embedder, err := memorydb.NewOpenAIEmbedder(memorydb.OpenAIEmbedderConfig{
+		BaseURL:    emb.BaseURL,
+		APIKey:     os.Getenv(keyEnv),
+		Model:      emb.Model,
+		Dimensions: emb.Dimensions,
+	})
+	if err != nil {
+		logger.Warn("Memory embeddings disabled", "error", err)
+		return kb, nil
+	}
+	kb.Store().SetEmbedder(embedder)
+	logger.Info("Memory embeddings enabled", "model", embedder.Model())
+	return kb, nil
+}
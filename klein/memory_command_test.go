@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fpt/klein-cli/internal/tool/memorydb"
+)
+
+func TestParseMemoryArgs(t *testing.T) {
+	opts, err := parseMemoryArgs([]string{"deploy", "--db=/tmp/m.sqlite", "-n", "3", "schedule", "--dry-run",
+		"--archive-after", "90d", "--half-life", "off", "--similarity", "0.9"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(opts.positional, " ") != "deploy schedule" || opts.db != "/tmp/m.sqlite" || opts.limit != 3 ||
+		!opts.dryRun || opts.archiveAfter != 90*24*time.Hour || opts.halfLife != -1 || opts.similarity != 0.9 {
+		t.Fatalf("opts = %+v", opts)
+	}
+	for _, bad := range [][]string{{"-n"}, {"-n", "0"}, {"--archive-after", "soon"}, {"--similarity", "2"}, {"--pdf"}} {
+		if _, err := parseMemoryArgs(bad); err == nil {
+			t.Errorf("parseMemoryArgs(%v) accepted", bad)
+		}
+	}
+	if opts, _ := parseMemoryArgs([]string{"-"}); len(opts.positional) != 1 {
+		t.Errorf("- should be positional (stdin), got %+v", opts)
+	}
+}
+
+func TestMemoryExportImportMaintain(t *testing.T) {
+	ctx := context.Background()
+	src, err := memorydb.Open(filepath.Join(t.TempDir(), "src.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	a, _ := src.Remember(ctx, "The user writes Go with tabs", "preference", 0.6, []string{"go"}, "")
+	if _, err := src.Revise(ctx, a.ID, "The user writes Go and Rust with tabs", "", 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := src.Remember(ctx, "The user writes Go and Rust with tabs.", "preference", 0.5, nil, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	file := filepath.Join(t.TempDir(), "memories.jsonl")
+	var out bytes.Buffer
+	if err := memoryExport(ctx, src, &out, memoryOptions{output: file}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "Exported 2 memories") {
+		t.Fatalf("export output = %q", out.String())
+	}
+
+	dst, err := memorydb.Open(filepath.Join(t.TempDir(), "dst.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+	out.Reset()
+	if err := memoryImport(ctx, dst, &out, nil, []string{file}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "Imported 2 memories (3 versions)") {
+		t.Fatalf("import output = %q", out.String())
+	}
+
+	out.Reset()
+	if err := memoryMaintain(ctx, dst, &out, memoryOptions{dryRun: true}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "merge   #") || !strings.Contains(out.String(), "merged 1 into 1") ||
+		!strings.Contains(out.String(), "(dry run)") {
+		t.Fatalf("maintain output = %q", out.String())
+	}
+
+	out.Reset()
+	if err := memoryShow(ctx, dst, &out, []string{"2"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "v2") || !strings.Contains(out.String(), "history:") ||
+		!strings.Contains(out.String(), "entities:   go") {
+		t.Fatalf("show output = %q", out.String())
+	}
+
+	out.Reset()
+	if err := memorySearch(ctx, dst, &out, memoryOptions{positional: []string{"rust"}, limit: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(out.String(), "\n") != 2 {
+		t.Fatalf("search output = %q", out.String())
+	}
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fpt/klein-cli/internal/permission"
+)
+
+// runPermissionsCommand implements `klein permissions <list|add|remove|explain>`,
+// which reads and edits the three permission rule files for the current
+// directory (see package permission for their precedence).
+func runPermissionsCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Println(permissionsUsage)
+		return 1
+	}
+	workingDir, err := os.Getwd()
+	if err != nil {
+		fmt.Printf("Failed to determine working directory: %v\n", err)
+		return 1
+	}
+
+	switch args[0] {
+	case "list", "ls":
+		return permissionsList(workingDir, args[1:])
+	case "add":
+		return permissionsAdd(workingDir, args[1:])
+	case "remove", "rm", "delete":
+		return permissionsRemove(workingDir, args[1:])
+	case "explain":
+		return permissionsExplain(workingDir, args[1:])
+	default:
+		fmt.Printf("Unknown permissions subcommand %q.\n\n%s\n", args[0], permissionsUsage)
+		return 1
+	}
+}
+
+const permissionsUsage = `Usage:
+  klein permissions list [--scope local|project|user]
+  klein permissions add <allow|deny|ask> <tool> [pattern] [--arg name=glob]... [--arg-regex name=regex]...
+                        [--expires 24h|7d|2026-12-31T00:00:00Z] [--scope local|project|user]
+  klein permissions remove <n> [--scope local|project|user]
+  klein permissions explain <tool> [name=value ...]
+
+Tools are named as the model calls them (Write, Bash, ...); MCP tools are
+"mcp:<server>/<tool>", and "mcp:github/*" covers a whole server.
+
+Examples:
+  klein permissions add allow Bash "go test *"
+  klein permissions add deny MultiEdit --arg file_path=vendor/**
+  klein permissions add ask "mcp:github/*" --expires 7d
+  klein permissions explain Bash command="rm -rf build"
+  klein permissions explain MultiEdit file_path=src/a.go file_path=vendor/b.go
+
+Rules are edited in place (default scope: project, .klein/permissions.json);
+other entries in the file are left as they are.`
+
+// takeScopeFlag pulls an optional `--scope <name>` out of args.
+func takeScopeFlag(args []string, def permission.Scope) (permission.Scope, []string, error) {
+	for i := 0; i < len(args); i++ {
+		if args[i] != "--scope" {
+			continue
+		}
+		if i+1 >= len(args) {
+			return "", nil, errors.New("--scope needs a value")
+		}
+		scope, err := permission.ParseScope(args[i+1])
+		if err != nil {
+			return "", nil, err
+		}
+		rest := append(append([]string{}, args[:i]...), args[i+2:]...)
+		return scope, rest, nil
+	}
+	return def, args, nil
+}
+
+func permissionsList(workingDir string, args []string) int {
+	scope, rest, err := takeScopeFlag(args, "")
+	if err == nil && len(rest) > 0 {
+		err = fmt.Errorf("unexpected argument %q", rest[0])
+	}
+	if err != nil {
+		fmt.Printf("%v\n\n%s\n", err, permissionsUsage)
+		return 1
+	}
+	scopes := permission.Scopes
+	if scope != "" {
+		scopes = []permission.Scope{scope}
+	}
+
+	now := time.Now()
+	for _, scope := range scopes {
+		path := permission.ScopePath(scope, workingDir)
+		rules, err := permission.LoadFile(path)
+		if err != nil {
+			fmt.Printf("%s (%s): %v\n", scope, path, err)
+			continue
+		}
+		fmt.Printf("%s (%s):\n", scope, path)
+		if len(rules) == 0 {
+			fmt.Println("  (no rules)")
+			continue
+		}
+		for i, r := range rules {
+			fmt.Printf("  %d. %s\n", i+1, describeRule(r, now))
+		}
+	}
+	return 0
+}
+
+// describeRule renders a rule on one line, the way list and explain show it.
+func describeRule(r permission.PermissionRule, now time.Time) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-5s %s", r.Behavior, r.Tool)
+	if r.Pattern != "" {
+		fmt.Fprintf(&b, " %q", r.Pattern)
+	}
+	for _, m := range r.Args {
+		if m.Regex != "" {
+			fmt.Fprintf(&b, " %s~/%s/", m.Name, m.Regex)
+		} else {
+			fmt.Fprintf(&b, " %s=%s", m.Name, m.Glob)
+		}
+	}
+	if r.Expires != nil {
+		if r.Expired(now) {
+			fmt.Fprintf(&b, " (expired %s)", r.Expires.Format(time.RFC3339))
+		} else {
+			fmt.Fprintf(&b, " (expires %s)", r.Expires.Format(time.RFC3339))
+		}
+	}
+	return b.String()
+}
+
+// parsePermissionAdd turns `permissions add` arguments into a rule and the
+// scope to write it to (no I/O).
+func parsePermissionAdd(args []string, now time.Time) (permission.Scope, permission.PermissionRule, error) {
+	scope, args, err := takeScopeFlag(args, permission.ScopeProject)
+	if err != nil {
+		return "", permission.PermissionRule{}, err
+	}
+
+	var rule permission.PermissionRule
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		switch a {
+		case "--arg", "--arg-regex":
+			if i+1 >= len(args) {
+				return "", rule, fmt.Errorf("%s needs name=value", a)
+			}
+			i++
+			name, value, ok := strings.Cut(args[i], "=")
+			if !ok || name == "" {
+				return "", rule, fmt.Errorf("%s %q: want name=value", a, args[i])
+			}
+			m := permission.ArgMatcher{Name: name, Glob: value}
+			if a == "--arg-regex" {
+				m = permission.ArgMatcher{Name: name, Regex: value}
+			}
+			rule.Args = append(rule.Args, m)
+		case "--expires":
+			if i+1 >= len(args) {
+				return "", rule, errors.New("--expires needs a duration or timestamp")
+			}
+			i++
+			t, err := parseExpiry(args[i], now)
+			if err != nil {
+				return "", rule, err
+			}
+			rule.Expires = &t
+		default:
+			positional = append(positional, a)
+		}
+	}
+
+	if len(positional) < 2 || len(positional) > 3 {
+		return "", rule, errors.New("want <behavior> <tool> [pattern]")
+	}
+	rule.Behavior = permission.RuleBehavior(positional[0])
+	rule.Tool = positional[1]
+	if len(positional) == 3 {
+		rule.Pattern = positional[2]
+	}
+	if err := rule.Validate(); err != nil {
+		return "", rule, err
+	}
+	return scope, rule, nil
+}
+
+// parseExpiry accepts an RFC 3339 timestamp, a Go duration ("36h"), or a
+// whole number of days ("7d"), the latter two counted from now.
+func parseExpiry(s string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n > 0 {
+			return now.AddDate(0, 0, n).UTC(), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil && d > 0 {
+		return now.Add(d).UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("--expires %q: want a duration (24h, 7d) or an RFC 3339 time", s)
+}
+
+func permissionsAdd(workingDir string, args []string) int {
+	scope, rule, err := parsePermissionAdd(args, time.Now())
+	if err != nil {
+		fmt.Printf("%v\n\n%s\n", err, permissionsUsage)
+		return 1
+	}
+	path := permission.ScopePath(scope, workingDir)
+	if err := permission.AddRule(path, rule); err != nil {
+		fmt.Printf("Failed to update %s: %v\n", path, err)
+		return 1
+	}
+	fmt.Printf("Added to %s: %s\n", path, describeRule(rule, time.Now()))
+	return 0
+}
+
+func permissionsRemove(workingDir string, args []string) int {
+	scope, args, err := takeScopeFlag(args, permission.ScopeProject)
+	if err != nil || len(args) != 1 {
+		fmt.Println("Usage: klein permissions remove <n> [--scope local|project|user]")
+		return 1
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 1 {
+		fmt.Printf("Rule number must be a positive integer (as shown by `klein permissions list`), got %q\n", args[0])
+		return 1
+	}
+	path := permission.ScopePath(scope, workingDir)
+	removed, err := permission.RemoveRule(path, n-1)
+	if err != nil {
+		fmt.Printf("Failed to update %s: %v\n", path, err)
+		return 1
+	}
+	fmt.Printf("Removed from %s: %s\n", path, describeRule(removed, time.Now()))
+	return 0
+}
+
+// parseExplainArgs turns `permissions explain` arguments into the call to
+// evaluate. A name given more than once becomes a list, so several
+// file_path= values stand for a MultiEdit touching several files.
+func parseExplainArgs(args []string) (permission.Call, error) {
+	if len(args) == 0 {
+		return permission.Call{}, errors.New("want <tool> [name=value ...]")
+	}
+	tool, server := permission.ParseToolName(args[0])
+	values := map[string][]string{}
+	var order []string
+	for _, a := range args[1:] {
+		name, value, ok := strings.Cut(a, "=")
+		if !ok || name == "" {
+			return permission.Call{}, fmt.Errorf("argument %q: want name=value", a)
+		}
+		if _, seen := values[name]; !seen {
+			order = append(order, name)
+		}
+		values[name] = append(values[name], value)
+	}
+
+	callArgs := make(map[string]any, len(order))
+	for _, name := range order {
+		vs := values[name]
+		if len(vs) == 1 {
+			callArgs[name] = vs[0]
+			continue
+		}
+		list := make([]any, len(vs))
+		for i, v := range vs {
+			list[i] = v
+		}
+		callArgs[name] = list
+	}
+	return permission.NewCall(tool, server, callArgs), nil
+}
+
+func permissionsExplain(workingDir string, args []string) int {
+	call, err := parseExplainArgs(args)
+	if err != nil {
+		fmt.Printf("%v\n\n%s\n", err, permissionsUsage)
+		return 1
+	}
+	now := time.Now()
+	exp, err := permission.Explain(workingDir, call, now)
+	if err != nil {
+		fmt.Printf("Failed to load permission rules: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("Call: %s", call.QualifiedName())
+	if len(call.Primary) > 0 {
+		fmt.Printf(" %q", strings.Join(call.Primary, ", "))
+	}
+	fmt.Println()
+	for _, r := range exp.Expired {
+		fmt.Printf("  skipped (expired): %s  [%s]\n", describeRule(r, now), r.Source)
+	}
+	if !exp.Matched {
+		fmt.Println("No rule matches: the built-in approval policy decides (Write/Edit/MultiEdit and")
+		fmt.Println("non-whitelisted Bash prompt; other tools run).")
+		return 0
+	}
+	fmt.Printf("Matched rule #%d in %s:\n  %s\n", exp.Index+1, exp.Rule.Source, describeRule(exp.Rule, now))
+	switch exp.Rule.Behavior {
+	case permission.RuleAllow:
+		fmt.Println("Result: allowed without asking.")
+	case permission.RuleDeny:
+		fmt.Println("Result: denied.")
+	case permission.RuleAsk:
+		fmt.Println("Result: always asks (denied when there is no terminal to ask).")
+	}
+	return 0
+}
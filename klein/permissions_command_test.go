@@ -0,0 +1,81 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fpt/klein-cli/internal/permission"
+)
+
+func TestParsePermissionAdd(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	scope, rule, err := parsePermissionAdd([]string{"allow", "Bash", "go test *"}, now)
+	if err != nil || scope != permission.ScopeProject || rule.Tool != "Bash" ||
+		rule.Pattern != "go test *" || rule.Behavior != permission.RuleAllow {
+		t.Errorf("basic add: %v %+v %v", scope, rule, err)
+	}
+
+	scope, rule, err = parsePermissionAdd([]string{
+		"deny", "MultiEdit", "--arg", "file_path=vendor/**",
+		"--arg-regex", "old_string=^package ", "--scope", "local", "--expires", "7d",
+	}, now)
+	if err != nil || scope != permission.ScopeLocal || len(rule.Args) != 2 {
+		t.Fatalf("matchers: %v %+v %v", scope, rule, err)
+	}
+	if rule.Args[0].Glob != "vendor/**" || rule.Args[1].Regex != "^package " {
+		t.Errorf("matchers parsed wrong: %+v", rule.Args)
+	}
+	if rule.Expires == nil || !rule.Expires.Equal(now.AddDate(0, 0, 7)) {
+		t.Errorf("expires = %v, want 7 days after now", rule.Expires)
+	}
+
+	for _, bad := range [][]string{
+		{"allow"},
+		{"sometimes", "Bash"},
+		{"allow", "Bash", "--arg", "novalue"},
+		{"allow", "Bash", "--expires", "soon"},
+		{"allow", "Bash", "--scope", "global"},
+	} {
+		if _, _, err := parsePermissionAdd(bad, now); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
+
+func TestParseExplainArgs(t *testing.T) {
+	call, err := parseExplainArgs([]string{"MultiEdit", "file_path=src/a.go", "file_path=vendor/b.go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(call.Primary) != 2 || call.Primary[1] != "vendor/b.go" {
+		t.Errorf("repeated file_path should become every primary value, got %v", call.Primary)
+	}
+
+	call, err = parseExplainArgs([]string{"mcp:github/create_issue", "repo=klein"})
+	if err != nil || call.Server != "github" || call.Tool != "create_issue" {
+		t.Errorf("mcp call: %+v %v", call, err)
+	}
+	if got := call.QualifiedName(); got != "mcp:github/create_issue" {
+		t.Errorf("QualifiedName = %q", got)
+	}
+
+	if _, err := parseExplainArgs([]string{"Bash", "command"}); err == nil {
+		t.Error("expected an error for an argument without =")
+	}
+}
+
+func TestPermissionsListArgs(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	for _, args := range [][]string{{"--bogus"}, {"project"}, {"--scope"}, {"--scope", "nowhere"}, {"--scope", "project", "extra"}} {
+		if got := permissionsList(dir, args); got != 1 {
+			t.Errorf("list %q = %d, want 1", args, got)
+		}
+	}
+	for _, args := range [][]string{nil, {"--scope", "project"}} {
+		if got := permissionsList(dir, args); got != 0 {
+			t.Errorf("list %q = %d, want 0", args, got)
+		}
+	}
+}
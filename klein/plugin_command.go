@@ -0,0 +1,477 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fpt/klein-cli/internal/config"
+	"github.com/fpt/klein-cli/internal/config/tomledit"
+	pluginpkg "github.com/fpt/klein-cli/internal/plugin"
+)
+
+// pluginPromptInput is where the trust prompt reads its answers; tests swap
+// it. One reader serves every prompt of a run, so an update that asks about
+// several plugins does not lose answers to a buffer it threw away.
+var pluginPromptInput = bufio.NewReader(os.Stdin)
+
+const pluginUsage = `Usage:
+  klein plugin add <path|git-url> [--name <name>] [--ref <branch|tag|commit>] [-y]
+  klein plugin list
+  klein plugin enable <name>
+  klein plugin disable <name>
+  klein plugin update [<name>...] [--locked] [-y]
+  klein plugin remove <name>
+
+A source is a plugin or a marketplace (.claude-plugin/marketplace.json): a local
+directory, which loads in place, or a git repository, which is cloned under
+<base_dir>/plugins and pinned to a commit in plugins.lock beside the settings
+file. add and update list the commands, agents, skills and MCP servers the
+plugin brings and ask before installing it (-y skips the question).
+update --locked installs exactly the commits the lockfile pins, e.g. after
+pulling a project that commits .agents/settings.toml and .agents/plugins.lock.
+
+Edits the [plugins.*] tables in ~/.klein/settings.toml, or in the file named by
+--settings. Comments and formatting elsewhere in the file are left alone.`
+
+// pluginArgs is a parsed `klein plugin` argument list.
+type pluginArgs struct {
+	positional []string
+	name, ref  string
+	yes        bool
+	locked     bool
+}
+
+func parsePluginArgs(args []string) (pluginArgs, error) {
+	var pa pluginArgs
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		switch a {
+		case "--name", "--ref":
+			if i+1 >= len(args) {
+				return pa, fmt.Errorf("%s needs a value", a)
+			}
+			i++
+			if a == "--name" {
+				pa.name = args[i]
+			} else {
+				pa.ref = args[i]
+			}
+		case "-y", "--yes":
+			pa.yes = true
+		case "--locked":
+			pa.locked = true
+		default:
+			if strings.HasPrefix(a, "-") {
+				return pa, fmt.Errorf("unknown flag %s", a)
+			}
+			pa.positional = append(pa.positional, a)
+		}
+	}
+	return pa, nil
+}
+
+// runPluginCommand implements the `klein plugin` subcommands, which manage
+// the [plugins.*] tables of the settings file and the plugins.lock beside it.
+func runPluginCommand(args []string) int {
+	settingsPath, args := takeSettingsFlag(args)
+	if len(args) == 0 {
+		fmt.Println(pluginUsage)
+		return 1
+	}
+	pa, err := parsePluginArgs(args[1:])
+	if err != nil {
+		fmt.Printf("%v\n\n%s\n", err, pluginUsage)
+		return 1
+	}
+
+	settings, err := config.LoadSettings(settingsPath)
+	if err != nil {
+		fmt.Printf("Failed to load settings %s: %v\n", settingsPath, err)
+		return 1
+	}
+	pc := &pluginCommand{
+		settingsPath: settingsPath,
+		lockPath:     config.PluginsLockFile(settingsPath),
+		settings:     settings,
+		store:        &pluginpkg.Store{Dir: settings.PluginsDir()},
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "add", "install":
+		return pc.add(ctx, pa)
+	case "list", "ls":
+		return pc.list()
+	case "enable":
+		return pc.setEnabled(pa, true)
+	case "disable":
+		return pc.setEnabled(pa, false)
+	case "update", "upgrade":
+		return pc.update(ctx, pa)
+	case "remove", "rm", "uninstall":
+		return pc.remove(pa)
+	default:
+		fmt.Printf("Unknown plugin subcommand %q.\n\n%s\n", args[0], pluginUsage)
+		return 1
+	}
+}
+
+type pluginCommand struct {
+	settingsPath string
+	lockPath     string
+	settings     *config.Settings
+	store        *pluginpkg.Store
+}
+
+func (pc *pluginCommand) add(ctx context.Context, pa pluginArgs) int {
+	if len(pa.positional) != 1 {
+		fmt.Printf("add takes one source.\n\n%s\n", pluginUsage)
+		return 1
+	}
+	source := pa.positional[0]
+	if strings.HasPrefix(source, "-") {
+		fmt.Printf("Invalid source %q.\n", source)
+		return 1
+	}
+	if !pluginpkg.IsGitSource(source) {
+		abs, err := filepath.Abs(source)
+		if err != nil {
+			fmt.Printf("Failed to resolve %s: %v\n", source, err)
+			return 1
+		}
+		source = abs
+	}
+	if pa.name != "" {
+		if err := pluginpkg.ValidName(pa.name); err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		if _, exists := pc.settings.Plugins[pa.name]; exists {
+			fmt.Printf("A plugin named %q is already installed; use `klein plugin update %s` or remove it first.\n", pa.name, pa.name)
+			return 1
+		}
+	}
+
+	lock, err := pluginpkg.ReadLock(pc.lockPath)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	fetched, err := pc.store.Fetch(ctx, pluginpkg.Entry{Name: pa.name, Source: source, Ref: pa.ref})
+	if err != nil {
+		fmt.Printf("Failed to fetch %s: %v\n", source, err)
+		return 1
+	}
+	if err := pluginpkg.ValidName(fetched.Name); err != nil {
+		pc.store.Discard(fetched)
+		fmt.Printf("%v; pass --name\n", err)
+		return 1
+	}
+	if _, exists := pc.settings.Plugins[fetched.Name]; exists {
+		pc.store.Discard(fetched)
+		fmt.Printf("A plugin named %q is already installed; pass --name to install this one under another name.\n", fetched.Name)
+		return 1
+	}
+
+	describeFetched(os.Stdout, fetched)
+	pluginpkg.InventoryOf(fetched.Plugins).Write(os.Stdout, nil)
+	if !pa.yes && !confirm("Trust and enable it?") {
+		pc.store.Discard(fetched)
+		fmt.Println("Not installed.")
+		return 1
+	}
+
+	entry, err := pc.store.Keep(fetched)
+	if err != nil {
+		pc.store.Discard(fetched)
+		fmt.Printf("Failed to install %s: %v\n", fetched.Name, err)
+		return 1
+	}
+	entry.UpdatedAt = time.Now().UTC()
+	lock.Plugins[fetched.Name] = entry
+	if err := lock.Write(pc.lockPath); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	if err := pc.writeSpec(fetched.Name, config.PluginSpec{Source: source, Ref: pa.ref}); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	fmt.Printf("Installed %s %q in %s\n", fetched.Kind, fetched.Name, pc.settingsPath)
+	return 0
+}
+
+func (pc *pluginCommand) list() int {
+	if len(pc.settings.Plugins) == 0 {
+		fmt.Printf("No plugins installed in %s\n", pc.settingsPath)
+		return 0
+	}
+	lock, err := pluginpkg.ReadLock(pc.lockPath)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	fmt.Printf("Plugins in %s:\n", pc.settingsPath)
+	for _, name := range sortedPluginNames(pc.settings.Plugins) {
+		spec := pc.settings.Plugins[name]
+		locked, ok := lock.Plugins[name]
+		status := "enabled"
+		if !spec.IsEnabled() {
+			status = "disabled"
+		}
+
+		var pin string
+		switch {
+		case !pluginpkg.IsGitSource(spec.Source):
+			pin = "local directory"
+		case ok && locked.Commit != "":
+			pin = "at " + shortCommit(locked.Commit)
+		default:
+			pin = "not locked"
+		}
+		if spec.Ref != "" {
+			pin += ", follows " + spec.Ref
+		}
+
+		detail := ""
+		plugins, err := pc.store.Load(pluginpkg.Entry{Name: name, Source: spec.Source, Ref: spec.Ref}, locked, ok)
+		switch {
+		case errors.Is(err, pluginpkg.ErrNotInstalled):
+			detail = " — not installed; run `klein plugin update --locked`"
+		case err != nil:
+			detail = " — " + err.Error()
+		default:
+			detail = fmt.Sprintf(" — %d plugin(s)", len(plugins))
+		}
+		fmt.Printf("  %s [%s] %s (%s)%s\n", name, status, spec.Source, pin, detail)
+	}
+	return 0
+}
+
+func (pc *pluginCommand) setEnabled(pa pluginArgs, enabled bool) int {
+	if len(pa.positional) != 1 {
+		fmt.Printf("Name one plugin.\n\n%s\n", pluginUsage)
+		return 1
+	}
+	name := pa.positional[0]
+	spec, ok := pc.settings.Plugins[name]
+	if !ok {
+		fmt.Printf("No plugin named %q in %s\n", name, pc.settingsPath)
+		return 1
+	}
+	spec.Enabled = &enabled
+	if err := pc.writeSpec(name, spec); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	verb := "Disabled"
+	if enabled {
+		verb = "Enabled"
+	}
+	fmt.Printf("%s plugin %q in %s\n", verb, name, pc.settingsPath)
+	return 0
+}
+
+func (pc *pluginCommand) update(ctx context.Context, pa pluginArgs) int {
+	names := pa.positional
+	if len(names) == 0 {
+		names = sortedPluginNames(pc.settings.Plugins)
+	}
+	lock, err := pluginpkg.ReadLock(pc.lockPath)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	failed, changed := false, false
+	for _, name := range names {
+		spec, ok := pc.settings.Plugins[name]
+		if !ok {
+			fmt.Printf("No plugin named %q in %s\n", name, pc.settingsPath)
+			failed = true
+			continue
+		}
+		if err := pluginpkg.ValidName(name); err != nil {
+			fmt.Println(err)
+			failed = true
+			continue
+		}
+		if !pluginpkg.IsGitSource(spec.Source) {
+			fmt.Printf("%s: loads in place from %s; nothing to update\n", name, spec.Source)
+			continue
+		}
+		entry := pluginpkg.Entry{Name: name, Source: spec.Source, Ref: spec.Ref}
+		locked, isLocked := lock.Plugins[name]
+		if isLocked && locked.Source != spec.Source {
+			isLocked = false // the source changed since it was locked; start over
+		}
+		previous, prevErr := pc.store.Load(entry, locked, isLocked)
+
+		var fetched *pluginpkg.Fetched
+		if pa.locked {
+			if !isLocked || locked.Commit == "" {
+				fmt.Printf("%s: not in %s; run `klein plugin update %s` to lock it\n", name, pc.lockPath, name)
+				failed = true
+				continue
+			}
+			if prevErr == nil {
+				fmt.Printf("%s: installed at %s\n", name, shortCommit(locked.Commit))
+				continue
+			}
+			fetched, err = pc.store.FetchCommit(ctx, entry, locked.Commit)
+		} else {
+			fetched, err = pc.store.Fetch(ctx, entry)
+		}
+		if err != nil {
+			fmt.Printf("%s: %v\n", name, err)
+			failed = true
+			continue
+		}
+		if prevErr == nil && fetched.Commit == locked.Commit {
+			pc.store.Discard(fetched)
+			fmt.Printf("%s: up to date at %s\n", name, shortCommit(locked.Commit))
+			continue
+		}
+
+		describeFetched(os.Stdout, fetched)
+		var before *pluginpkg.Inventory
+		if prevErr == nil {
+			inv := pluginpkg.InventoryOf(previous)
+			before = &inv
+			fmt.Printf("Changes since %s:\n", shortCommit(locked.Commit))
+		}
+		pluginpkg.InventoryOf(fetched.Plugins).Write(os.Stdout, before)
+		if !pa.yes && !confirm("Trust this version?") {
+			pc.store.Discard(fetched)
+			fmt.Printf("%s: kept as it was\n", name)
+			failed = true
+			continue
+		}
+		kept, err := pc.store.Keep(fetched)
+		if err != nil {
+			pc.store.Discard(fetched)
+			fmt.Printf("%s: %v\n", name, err)
+			failed = true
+			continue
+		}
+		kept.UpdatedAt = time.Now().UTC()
+		lock.Plugins[name] = kept
+		changed = true
+		fmt.Printf("%s: now at %s\n", name, shortCommit(kept.Commit))
+	}
+
+	if changed {
+		if err := lock.Write(pc.lockPath); err != nil {
+			fmt.Println(err)
+			return 1
+		}
+	}
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+func (pc *pluginCommand) remove(pa pluginArgs) int {
+	if len(pa.positional) != 1 {
+		fmt.Printf("Name one plugin.\n\n%s\n", pluginUsage)
+		return 1
+	}
+	name := pa.positional[0]
+	if _, ok := pc.settings.Plugins[name]; !ok {
+		fmt.Printf("No plugin named %q in %s\n", name, pc.settingsPath)
+		return 1
+	}
+	if err := pluginpkg.ValidName(name); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	lock, err := pluginpkg.ReadLock(pc.lockPath)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	if err := editSettings(pc.settingsPath, func(src []byte) ([]byte, error) {
+		out, _, err := tomledit.DeleteTable(src, "plugins."+name)
+		if err != nil {
+			return nil, fmt.Errorf("removing the plugin's table: %w", err)
+		}
+		return out, nil
+	}); err != nil {
+		fmt.Printf("Failed to update settings %s: %v\n", pc.settingsPath, err)
+		return 1
+	}
+
+	if locked, ok := lock.Plugins[name]; ok {
+		if err := pc.store.Remove(name, locked.Commit); err != nil {
+			fmt.Printf("Failed to delete the checkout of %s: %v\n", name, err)
+		}
+		delete(lock.Plugins, name)
+		if err := lock.Write(pc.lockPath); err != nil {
+			fmt.Println(err)
+			return 1
+		}
+	}
+	fmt.Printf("Removed plugin %q from %s\n", name, pc.settingsPath)
+	return 0
+}
+
+// writeSpec sets the [plugins.<name>] table.
+func (pc *pluginCommand) writeSpec(name string, spec config.PluginSpec) error {
+	body, err := config.PluginTOML(spec)
+	if err != nil {
+		return err
+	}
+	if err := editSettings(pc.settingsPath, func(src []byte) ([]byte, error) {
+		return tomledit.SetTable(src, "plugins."+name, body)
+	}); err != nil {
+		return fmt.Errorf("failed to update settings %s: %w", pc.settingsPath, err)
+	}
+	return nil
+}
+
+// describeFetched is the first line of the trust prompt.
+func describeFetched(w io.Writer, f *pluginpkg.Fetched) {
+	what := fmt.Sprintf("Plugin %q", f.Name)
+	if f.Kind == pluginpkg.KindMarketplace {
+		what = fmt.Sprintf("Marketplace %q (%d plugins)", f.Name, len(f.Plugins))
+	}
+	at := "loaded in place; later edits to it apply on the next run"
+	if f.Commit != "" {
+		at = "at " + shortCommit(f.Commit)
+	}
+	fmt.Fprintf(w, "%s from %s (%s) adds:\n", what, f.Source, at)
+}
+
+// confirm asks a y/N question on pluginPromptInput; anything but yes is no.
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	line, _ := pluginPromptInput.ReadString('\n')
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "y" || answer == "yes"
+}
+
+func sortedPluginNames(plugins map[string]config.PluginSpec) []string {
+	names := make([]string, 0, len(plugins))
+	for name := range plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func shortCommit(commit string) string {
+	if len(commit) > 12 {
+		return commit[:12]
+	}
+	return commit
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fpt/klein-cli/internal/config"
+	pluginpkg "github.com/fpt/klein-cli/internal/plugin"
+)
+
+func TestParsePluginArgs(t *testing.T) {
+	pa, err := parsePluginArgs([]string{"https://example.com/p.git", "--name", "p", "--ref", "v2", "-y"})
+	if err != nil || pa.name != "p" || pa.ref != "v2" || !pa.yes || len(pa.positional) != 1 {
+		t.Errorf("parsed %+v, err %v", pa, err)
+	}
+	if _, err := parsePluginArgs([]string{"--name"}); err == nil {
+		t.Error("--name without a value was accepted")
+	}
+	if _, err := parsePluginArgs([]string{"--force"}); err == nil {
+		t.Error("an unknown flag was accepted")
+	}
+}
+
+// TestPluginCommandLifecycle drives add, list, disable, update and remove
+// against a bare repository, with base_dir and the settings in a temp dir.
+func TestPluginCommandLifecycle(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	tmp := t.TempDir()
+	work, bare := filepath.Join(tmp, "work"), filepath.Join(tmp, "review.git")
+	git := func(dir string, args ...string) string {
+		t.Helper()
+		args = append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com", "-c", "init.defaultBranch=main"}, args...)
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_CONFIG_GLOBAL=/dev/null", "GIT_CONFIG_NOSYSTEM=1")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	commit := func(rel, content string) string {
+		t.Helper()
+		p := filepath.Join(work, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		git(work, "add", "-A")
+		git(work, "commit", "--quiet", "-m", rel)
+		git(work, "push", "--quiet", "origin", "HEAD:main")
+		return git(work, "rev-parse", "HEAD")
+	}
+	answer := func(s string) {
+		pluginPromptInput = bufio.NewReader(strings.NewReader(s))
+	}
+	t.Cleanup(func() { pluginPromptInput = bufio.NewReader(os.Stdin) })
+
+	if err := os.MkdirAll(work, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	git("", "init", "--quiet", "--bare", bare)
+	git(work, "init", "--quiet")
+	git(work, "remote", "add", "origin", bare)
+	commit(".claude-plugin/plugin.json", `{"name": "review"}`)
+	first := commit("commands/pr.md", "Review $ARGUMENTS\n")
+
+	settingsPath := filepath.Join(tmp, "settings.toml")
+	base := filepath.Join(tmp, "base")
+	if err := os.WriteFile(settingsPath, []byte("# my settings\nbase_dir = \""+base+"\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	run := func(args ...string) int {
+		t.Helper()
+		return runPluginCommand(append([]string{"--settings", settingsPath}, args...))
+	}
+	load := func() (*config.Settings, *pluginpkg.Lock) {
+		t.Helper()
+		s, err := config.LoadSettings(settingsPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		l, err := pluginpkg.ReadLock(config.PluginsLockFile(settingsPath))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s, l
+	}
+
+	// Declining the trust prompt installs nothing.
+	answer("n\n")
+	if code := run("add", bare); code == 0 {
+		t.Fatal("a declined add succeeded")
+	}
+	if s, l := load(); len(s.Plugins) != 0 || len(l.Plugins) != 0 {
+		t.Fatalf("declined add wrote settings %v / lock %v", s.Plugins, l.Plugins)
+	}
+	if staged, _ := filepath.Glob(filepath.Join(base, "plugins", ".fetch-*")); len(staged) != 0 {
+		t.Errorf("declined add left %v", staged)
+	}
+
+	answer("y\n")
+	if code := run("add", bare); code != 0 {
+		t.Fatalf("add exited %d", code)
+	}
+	s, l := load()
+	if s.Plugins["review"].Source != bare || l.Plugins["review"].Commit != first {
+		t.Fatalf("after add: settings %+v, lock %+v", s.Plugins, l.Plugins)
+	}
+	if raw, _ := os.ReadFile(settingsPath); !strings.HasPrefix(string(raw), "# my settings\n") {
+		t.Errorf("add rewrote the rest of the file:\n%s", raw)
+	}
+	if code := run("add", bare, "-y"); code == 0 {
+		t.Error("adding the same plugin twice succeeded")
+	}
+	if code := run("list"); code != 0 {
+		t.Errorf("list exited %d", code)
+	}
+
+	if code := run("disable", "review"); code != 0 {
+		t.Fatalf("disable exited %d", code)
+	}
+	if s, _ := load(); s.Plugins["review"].IsEnabled() {
+		t.Error("disable left the plugin enabled")
+	}
+	if code := run("enable", "review"); code != 0 {
+		t.Fatalf("enable exited %d", code)
+	}
+	if s, _ := load(); !s.Plugins["review"].IsEnabled() {
+		t.Error("enable left the plugin disabled")
+	}
+
+	// update moves the pin only once the new version is trusted.
+	second := commit("agents/critic.md", "---\nname: critic\ndescription: Critiques\n---\nBe critical.\n")
+	answer("n\n")
+	run("update", "review")
+	if _, l := load(); l.Plugins["review"].Commit != first {
+		t.Errorf("a declined update moved the pin to %s", l.Plugins["review"].Commit)
+	}
+	if code := run("update", "-y"); code != 0 {
+		t.Fatalf("update exited %d", code)
+	}
+	if _, l := load(); l.Plugins["review"].Commit != second {
+		t.Errorf("update pinned %s, want %s", l.Plugins["review"].Commit, second)
+	}
+
+	// update --locked restores the pinned checkout, not the newest commit,
+	// still asking first: the lockfile may be a teammate's.
+	commit("commands/extra.md", "Extra\n")
+	if err := os.RemoveAll(filepath.Join(base, "plugins")); err != nil {
+		t.Fatal(err)
+	}
+	answer("y\n")
+	if code := run("update", "--locked"); code != 0 {
+		t.Fatalf("update --locked exited %d", code)
+	}
+	if _, err := os.Stat(filepath.Join(base, "plugins", "review", second)); err != nil {
+		t.Errorf("update --locked did not restore the pinned checkout: %v", err)
+	}
+	if _, l := load(); l.Plugins["review"].Commit != second {
+		t.Errorf("update --locked moved the pin to %s", l.Plugins["review"].Commit)
+	}
+
+	if code := run("remove", "review"); code != 0 {
+		t.Fatalf("remove exited %d", code)
+	}
+	if s, l := load(); len(s.Plugins) != 0 || len(l.Plugins) != 0 {
+		t.Errorf("after remove: settings %v, lock %v", s.Plugins, l.Plugins)
+	}
+	if _, err := os.Stat(filepath.Join(base, "plugins", "review")); !os.IsNotExist(err) {
+		t.Errorf("remove left the checkout: %v", err)
+	}
+}
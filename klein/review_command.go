@@ -19,6 +19,7 @@ import (
 	"github.com/fpt/klein-cli/internal/tool"
 	"github.com/fpt/klein-cli/pkg/agent/domain"
 	"github.com/fpt/klein-cli/pkg/agent/react"
+	"github.com/fpt/klein-cli/pkg/agent/state"
 	client "github.com/fpt/klein-cli/pkg/client"
 	pkgLogger "github.com/fpt/klein-cli/pkg/logger"
 	"github.com/fpt/klein-cli/pkg/message"
@@ -70,6 +71,7 @@ type reviewOptions struct {
 	maxBudget        int
 	maxComments      int
 	maxDiffBytes     int
+	maxDiffTokens    int
 	includeGenerated bool
 	verbose          bool
 }
@@ -96,6 +98,8 @@ func parseReviewFlags(args []string) (opts reviewOptions, code int, ok bool) {
 		"Cap on inline comments; excess are trimmed lowest-severity-first (0 = unlimited)")
 	maxDiffBytes := fs.Int("max-diff-bytes", 500_000,
 		"Budget for the enriched diff; it is truncated at a line boundary past this size (0 = unbounded)")
+	maxDiffTokens := fs.Int("max-diff-tokens", 0,
+		"Budget for the enriched diff in the model's tokens (0 = 40% of the model's context window, when known)")
 	verbose := fs.Bool("v", false, "Enable verbose (debug) logging")
 	fs.Usage = func() {
 		fmt.Fprint(os.Stderr, reviewUsage)
@@ -123,6 +127,7 @@ func parseReviewFlags(args []string) (opts reviewOptions, code int, ok bool) {
 		maxBudget:        *maxBudget,
 		maxComments:      *maxComments,
 		maxDiffBytes:     *maxDiffBytes,
+		maxDiffTokens:    *maxDiffTokens,
 		includeGenerated: *includeGenerated,
 		verbose:          *verbose,
 	}, 0, true
@@ -188,6 +193,7 @@ func runReviewCommand(args []string) int {
 	pkgLogger.SetGlobalLoggerWithConsoleWriter(pkgLogger.LogLevel(logLevel), out)
 	logger := pkgLogger.NewLoggerWithConsoleWriter(pkgLogger.LogLevel(logLevel), out)
 
+	configureTokenizer(settings)
 	result, err := executeReview(context.Background(), opts, settings, logger, out)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
@@ -211,12 +217,18 @@ type preparedReview struct {
 	numFiles    int
 }
 
+// reviewDiffShare is the part of the model's context window the diff may take
+// when --max-diff-tokens is not given; the rest is left for the system
+// prompt, tools, file reads and the review itself.
+const reviewDiffShare = 0.4
+
 // prepareReviewPrompt reads the request, parses the diff(s), and builds the
 // annotated review prompt. Commentable ranges come from full_diff when the
 // harness supplies one (incremental round) — GitHub validates comments
-// against the complete PR diff, not the increment being reviewed.
+// against the complete PR diff, not the increment being reviewed. The diff is
+// budgeted in llm's tokens; llm may be nil, leaving only the byte budget.
 func prepareReviewPrompt(
-	ctx context.Context, opts reviewOptions, fsRepo repository.FilesystemRepository,
+	ctx context.Context, opts reviewOptions, fsRepo repository.FilesystemRepository, llm domain.LLM,
 ) (preparedReview, error) {
 	var p preparedReview
 	req, err := readReviewRequest(opts.input)
@@ -228,6 +240,13 @@ func prepareReviewPrompt(
 		return p, fmt.Errorf("parse diff: %w", err)
 	}
 	enricher := review.NewEnricher(fsRepo, opts.workdir, opts.contextLines).WithMaxBytes(opts.maxDiffBytes)
+	if llm != nil {
+		maxTokens := opts.maxDiffTokens
+		if cwp, ok := llm.(domain.ContextWindowProvider); ok && maxTokens == 0 {
+			maxTokens = int(float64(cwp.MaxContextTokens()) * reviewDiffShare)
+		}
+		enricher.WithMaxTokens(maxTokens, state.TokenCounterFor(llm))
+	}
 
 	// Skip machine-generated files (protoc, sqlc, …) unless asked to include
 	// them: they are noise to review and their marker says DO NOT EDIT.
@@ -271,14 +290,9 @@ func noReviewableFilesResult(skipped []string) tool.ReviewResult {
 // agent's cleanup func, which the caller must defer.
 func newReviewAgent(
 	ctx context.Context, opts reviewOptions, settings *config.Settings,
-	reviewMgr domain.ToolManager, fsRepo repository.FilesystemRepository,
+	llmClient domain.LLM, reviewMgr domain.ToolManager, fsRepo repository.FilesystemRepository,
 	logger *pkgLogger.Logger, out io.Writer,
 ) (*app.Agent, func(), error) {
-	llmClient, err := client.NewLLMClient(settings.LLM)
-	if err != nil {
-		return nil, nil, fmt.Errorf("create LLM client: %w", err)
-	}
-
 	a, cleanup, err := app.NewAgentWithOptions(ctx, app.AgentOptions{
 		Settings:   settings,
 		WorkingDir: opts.workdir,
@@ -316,8 +330,13 @@ func executeReview(
 		return zero, fmt.Errorf("working directory %q: %w", opts.workdir, err)
 	}
 
+	// The client comes first: its tokenizer and context window size the diff.
+	llmClient, err := client.NewLLMClient(settings.LLM)
+	if err != nil {
+		return zero, fmt.Errorf("create LLM client: %w", err)
+	}
 	fsRepo := infra.NewOSFilesystemRepository()
-	prepared, err := prepareReviewPrompt(ctx, opts, fsRepo)
+	prepared, err := prepareReviewPrompt(ctx, opts, fsRepo, llmClient)
 	if err != nil {
 		return zero, err
 	}
@@ -332,7 +351,7 @@ func executeReview(
 	reviewMgr := tool.NewReviewToolManager(prepared.ranges.Validate, prepared.previousIDs).
 		WithRangeLister(prepared.ranges.Describe)
 
-	a, cleanup, err := newReviewAgent(ctx, opts, settings, reviewMgr, fsRepo, logger, out)
+	a, cleanup, err := newReviewAgent(ctx, opts, settings, llmClient, reviewMgr, fsRepo, logger, out)
 	if err != nil {
 		return zero, err
 	}
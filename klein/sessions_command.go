@@ -0,0 +1,383 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fpt/klein-cli/internal/claude"
+	"github.com/fpt/klein-cli/internal/config"
+	"github.com/fpt/klein-cli/internal/infra"
+	"github.com/fpt/klein-cli/internal/session"
+)
+
+// runSessionsCommand implements
+// `klein sessions <list|show|name|fork|rm|export|import-claude>` over the
+// current project's saved sessions. `sessions resume` is rewritten
+// into `klein --resume` by main, since resuming is a normal interactive run.
+func runSessionsCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Println(sessionsUsage)
+		return 1
+	}
+	workingDir, err := os.Getwd()
+	if err != nil {
+		fmt.Printf("Failed to determine working directory: %v\n", err)
+		return 1
+	}
+	store, err := projectSessionStore(workingDir)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	switch args[0] {
+	case "list", "ls":
+		return sessionsList(store, os.Stdout)
+	case "show":
+		return sessionsShow(store, args[1:], os.Stdout)
+	case "name", "rename":
+		return sessionsName(store, args[1:])
+	case "fork":
+		return sessionsFork(store, workingDir, args[1:])
+	case "rm", "remove", "delete":
+		return sessionsRemove(store, args[1:])
+	case "export":
+		return sessionsExport(store, workingDir, args[1:], os.Stdout)
+	case "import-claude":
+		return sessionsImportClaude(store, workingDir, args[1:], os.Stdout)
+	default:
+		fmt.Printf("Unknown sessions subcommand %q.\n\n%s\n", args[0], sessionsUsage)
+		return 1
+	}
+}
+
+const sessionsUsage = `Usage:
+  klein sessions list
+  klein sessions show <id>
+  klein sessions resume <id> [klein flags]
+  klein sessions name <id> <title>
+  klein sessions fork <id>
+  klein sessions rm <id>...
+  klein sessions export <id> [--format md|jsonl|html|claude] [-o <file>]
+  klein sessions import-claude [<claude-session-id>|<file.jsonl>]
+
+Sessions belong to the project in the current directory, most recently used
+first. <id> may be any unique prefix of a session id.
+
+--format claude writes a Claude Code session: into this project's Claude Code
+directory (ready for ` + "`claude --resume`" + `) unless -o names a file.
+import-claude copies a Claude Code session of this project (the newest by
+default) into a new klein session, tool calls and results included.
+
+Examples:
+  klein sessions list
+  klein sessions name 20261018T0912 "flaky test hunt"
+  klein sessions resume 20261018T0912 -b anthropic
+  klein sessions export 20261018T0912 --format html -o session.html
+  klein sessions export 20261018T0912 --format claude
+  klein sessions import-claude 3f2a`
+
+// projectSessionStore opens the sessions directory of the project at workingDir.
+func projectSessionStore(workingDir string) (*session.Store, error) {
+	userConfig, err := config.DefaultUserConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to access user config: %w", err)
+	}
+	dir, err := userConfig.GetProjectSessionsDir(workingDir)
+	if err != nil {
+		return nil, err
+	}
+	return session.NewStore(dir), nil
+}
+
+// resolveResumeSession maps a `--resume` reference to its session file.
+func resolveResumeSession(workingDir, ref string) (string, error) {
+	store, err := projectSessionStore(workingDir)
+	if err != nil {
+		return "", err
+	}
+	info, err := store.Resolve(ref)
+	if err != nil {
+		return "", err
+	}
+	return info.Path, nil
+}
+
+func sessionsList(store *session.Store, w io.Writer) int {
+	all, err := store.List()
+	if err != nil {
+		fmt.Fprintln(w, err)
+		return 1
+	}
+	if len(all) == 0 {
+		fmt.Fprintln(w, "No saved sessions for this project.")
+		return 0
+	}
+	now := time.Now()
+	for _, info := range all {
+		fmt.Fprintln(w, info.Summary(now))
+	}
+	return 0
+}
+
+// sessionsShow prints a session's details and a preview of its messages, each
+// cut to one line the way `klein -l` shows them. `export` gives the full text.
+func sessionsShow(store *session.Store, args []string, w io.Writer) int {
+	if len(args) != 1 {
+		fmt.Fprintf(w, "show needs exactly one session id.\n\n%s\n", sessionsUsage)
+		return 1
+	}
+	info, err := store.Resolve(args[0])
+	if err != nil {
+		fmt.Fprintln(w, err)
+		return 1
+	}
+	msgs, err := infra.NewMessageHistoryRepository(info.Path).Load()
+	if err != nil {
+		fmt.Fprintln(w, err)
+		return 1
+	}
+	fmt.Fprintln(w, info.DisplayTitle())
+	for _, line := range info.Details() {
+		fmt.Fprintf(w, "  %s\n", line)
+	}
+	fmt.Fprintln(w, strings.Repeat("-", 60))
+	for _, m := range msgs {
+		if s := m.TruncatedString(); s != "" {
+			fmt.Fprintln(w, s)
+		}
+	}
+	return 0
+}
+
+func sessionsName(store *session.Store, args []string) int {
+	if len(args) < 1 {
+		fmt.Printf("name needs a session id.\n\n%s\n", sessionsUsage)
+		return 1
+	}
+	info, err := store.Resolve(args[0])
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	title := strings.Join(args[1:], " ")
+	if err := store.SetTitle(info.ID, title); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	if strings.TrimSpace(title) == "" {
+		fmt.Printf("Cleared the title of %s.\n", info.ID)
+	} else {
+		fmt.Printf("Named %s %q.\n", info.ID, strings.TrimSpace(title))
+	}
+	return 0
+}
+
+func sessionsFork(store *session.Store, workingDir string, args []string) int {
+	if len(args) != 1 {
+		fmt.Printf("fork needs exactly one session id.\n\n%s\n", sessionsUsage)
+		return 1
+	}
+	userConfig, err := config.DefaultUserConfig()
+	if err != nil {
+		fmt.Printf("Failed to access user config: %v\n", err)
+		return 1
+	}
+	dst, err := userConfig.NewProjectSessionFile(workingDir)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	fork, err := store.Fork(args[0], dst)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	fmt.Printf("Forked %s as %s (%d messages).\n", fork.ForkedFrom, fork.ID, fork.Messages)
+	fmt.Printf("Resume it with: klein sessions resume %s\n", fork.ID)
+	return 0
+}
+
+func sessionsRemove(store *session.Store, args []string) int {
+	if len(args) == 0 {
+		fmt.Printf("rm needs at least one session id.\n\n%s\n", sessionsUsage)
+		return 1
+	}
+	status := 0
+	for _, ref := range args {
+		// Resolve first so a prefix is reported as the session it removed.
+		info, err := store.Resolve(ref)
+		if err == nil {
+			err = store.Remove(info.ID)
+		}
+		if err != nil {
+			fmt.Println(err)
+			status = 1
+			continue
+		}
+		fmt.Printf("Removed %s (%s).\n", info.ID, info.DisplayTitle())
+	}
+	return status
+}
+
+// parseExportArgs splits `export` arguments into the session reference, the
+// format (default md) and the output path ("" for stdout).
+func parseExportArgs(args []string) (ref, format, output string, err error) {
+	format = "md"
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		switch {
+		case a == "--format" || a == "-format" || a == "-o" || a == "--output":
+			if i+1 >= len(args) {
+				return "", "", "", fmt.Errorf("%s needs a value", a)
+			}
+			if strings.HasSuffix(a, "format") {
+				format = args[i+1]
+			} else {
+				output = args[i+1]
+			}
+			i++
+		case strings.HasPrefix(a, "--format="):
+			format = strings.TrimPrefix(a, "--format=")
+		case strings.HasPrefix(a, "-"):
+			return "", "", "", fmt.Errorf("unknown flag %s", a)
+		case ref == "":
+			ref = a
+		default:
+			return "", "", "", errors.New("export takes one session id")
+		}
+	}
+	if ref == "" {
+		return "", "", "", errors.New("export needs a session id")
+	}
+	return ref, format, output, nil
+}
+
+func sessionsExport(store *session.Store, workingDir string, args []string, stdout io.Writer) int {
+	ref, format, output, err := parseExportArgs(args)
+	if err != nil {
+		fmt.Fprintf(stdout, "%v\n\n%s\n", err, sessionsUsage)
+		return 1
+	}
+	info, err := store.Resolve(ref)
+	if err != nil {
+		fmt.Fprintln(stdout, err)
+		return 1
+	}
+	st, err := session.Load(info.Path)
+	if err != nil {
+		fmt.Fprintln(stdout, err)
+		return 1
+	}
+
+	if format == "claude" && output == "" {
+		cs, err := claude.ExportToProject(workingDir, st.Messages, "")
+		if err != nil {
+			fmt.Fprintln(stdout, err)
+			return 1
+		}
+		fmt.Fprintf(stdout, "Exported %s as Claude Code session %s.\n", info.ID, cs.ID)
+		fmt.Fprintf(stdout, "Continue it with: claude --resume %s\n", cs.ID)
+		return 0
+	}
+
+	w := stdout
+	var f *os.File
+	if output != "" {
+		if f, err = os.Create(output); err != nil {
+			fmt.Fprintf(stdout, "Failed to create %s: %v\n", output, err)
+			return 1
+		}
+		w = f
+	}
+	if format == "claude" {
+		abs, _ := filepath.Abs(workingDir)
+		_, err = claude.Export(w, st.Messages, claude.ExportOptions{Cwd: abs})
+	} else {
+		err = session.Export(w, info, st, format)
+	}
+	if f != nil {
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(stdout, err)
+		if output != "" {
+			_ = os.Remove(output)
+		}
+		return 1
+	}
+	if output != "" {
+		fmt.Fprintf(stdout, "Exported %s to %s (%s).\n", info.ID, output, format)
+	}
+	return 0
+}
+
+// sessionsImportClaude copies a Claude Code session — by id, id prefix or
+// file path, the project's newest when none is given — into a new klein
+// session of this project.
+func sessionsImportClaude(store *session.Store, workingDir string, args []string, w io.Writer) int {
+	if len(args) > 1 {
+		fmt.Fprintf(w, "import-claude takes at most one session id.\n\n%s\n", sessionsUsage)
+		return 1
+	}
+	var src claude.Session
+	if len(args) == 1 {
+		s, err := claude.ResolveSession(workingDir, args[0])
+		if err != nil {
+			fmt.Fprintln(w, err)
+			return 1
+		}
+		src = s
+	} else {
+		sessions, err := claude.ListSessions(workingDir)
+		if err != nil {
+			fmt.Fprintln(w, err)
+			return 1
+		}
+		if len(sessions) == 0 {
+			fmt.Fprintln(w, "No Claude Code sessions for this project.")
+			return 1
+		}
+		src = sessions[0]
+	}
+
+	msgs, err := claude.ImportMessages(src.Path)
+	if err != nil {
+		fmt.Fprintln(w, err)
+		return 1
+	}
+	if len(msgs) == 0 {
+		fmt.Fprintf(w, "Claude Code session %s has no messages to import.\n", src.ID)
+		return 1
+	}
+	userConfig, err := config.DefaultUserConfig()
+	if err != nil {
+		fmt.Fprintf(w, "Failed to access user config: %v\n", err)
+		return 1
+	}
+	dst, err := userConfig.NewProjectSessionFile(workingDir)
+	if err != nil {
+		fmt.Fprintln(w, err)
+		return 1
+	}
+	if err := infra.NewMessageHistoryRepository(dst).Save(msgs); err != nil {
+		fmt.Fprintln(w, err)
+		return 1
+	}
+	id := strings.TrimSuffix(filepath.Base(dst), filepath.Ext(dst))
+	if src.Title != "" {
+		if err := store.SetTitle(id, "claude: "+src.Title); err != nil {
+			fmt.Fprintln(w, err)
+		}
+	}
+	fmt.Fprintf(w, "Imported Claude Code session %s as %s (%d messages).\n", src.ID, id, len(msgs))
+	fmt.Fprintf(w, "Resume it with: klein sessions resume %s\n", id)
+	return 0
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fpt/klein-cli/internal/claude"
+	"github.com/fpt/klein-cli/internal/infra"
+	"github.com/fpt/klein-cli/internal/session"
+	"github.com/fpt/klein-cli/pkg/message"
+)
+
+func TestParseExportArgs(t *testing.T) {
+	cases := []struct {
+		args                []string
+		ref, format, output string
+		wantErr             string
+	}{
+		{args: []string{"abc"}, ref: "abc", format: "md"},
+		{args: []string{"abc", "--format", "html", "-o", "out.html"}, ref: "abc", format: "html", output: "out.html"},
+		{args: []string{"--format=jsonl", "abc"}, ref: "abc", format: "jsonl"},
+		{args: []string{"--format"}, wantErr: "--format needs a value"},
+		{args: []string{"abc", "def"}, wantErr: "one session id"},
+		{args: []string{"abc", "--pdf"}, wantErr: "unknown flag --pdf"},
+		{args: nil, wantErr: "needs a session id"},
+	}
+	for _, tc := range cases {
+		ref, format, output, err := parseExportArgs(tc.args)
+		if tc.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("%v: error %v, want %q", tc.args, err, tc.wantErr)
+			}
+			continue
+		}
+		if err != nil || ref != tc.ref || format != tc.format || output != tc.output {
+			t.Errorf("%v = (%q, %q, %q, %v)", tc.args, ref, format, output, err)
+		}
+	}
+}
+
+func TestSessionsListShowExport(t *testing.T) {
+	dir := t.TempDir()
+	store := session.NewStore(dir)
+
+	var out bytes.Buffer
+	if sessionsList(store, &out); !strings.Contains(out.String(), "No saved sessions") {
+		t.Errorf("empty list = %q", out.String())
+	}
+
+	path := filepath.Join(dir, "20261018T090000.000000000.json")
+	long := strings.Repeat("detail ", 40)
+	if err := infra.NewMessageHistoryRepository(path).Save([]message.Message{
+		message.NewChatMessage(message.MessageTypeUser, "summarise the README"),
+		message.NewChatMessage(message.MessageTypeAssistant, long),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	out.Reset()
+	if code := sessionsList(store, &out); code != 0 || !strings.Contains(out.String(), "20261018T090000.000000000") ||
+		!strings.Contains(out.String(), "summarise the README") {
+		t.Errorf("list = %d %q", code, out.String())
+	}
+
+	out.Reset()
+	if code := sessionsShow(store, []string{"20261018"}, &out); code != 0 || !strings.Contains(out.String(), "Messages: 2") {
+		t.Errorf("show = %d %q", code, out.String())
+	}
+	if strings.Contains(out.String(), long) {
+		t.Error("show should preview long messages, not print them whole")
+	}
+
+	export := filepath.Join(t.TempDir(), "s.md")
+	out.Reset()
+	if code := sessionsExport(store, t.TempDir(), []string{"2026", "-o", export}, &out); code != 0 {
+		t.Fatalf("export = %d %q", code, out.String())
+	}
+	data, err := os.ReadFile(export)
+	if err != nil || !strings.Contains(string(data), strings.TrimSpace(long)) {
+		t.Errorf("export should carry the full text: %v\n%s", err, data)
+	}
+
+	bad := filepath.Join(t.TempDir(), "s.pdf")
+	out.Reset()
+	if code := sessionsExport(store, t.TempDir(), []string{"2026", "--format", "pdf", "-o", bad}, &out); code == 0 {
+		t.Error("unknown format should fail")
+	}
+	if _, err := os.Stat(bad); !os.IsNotExist(err) {
+		t.Error("a failed export should not leave a file behind")
+	}
+}
+
+func TestSessionsClaudeRoundTrip(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	work := t.TempDir()
+	store, err := projectSessionStore(work)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if code := sessionsImportClaude(store, work, nil, &out); code == 0 {
+		t.Fatalf("import with no Claude Code sessions = %q", out.String())
+	}
+
+	// A Claude Code session with a tool round trip.
+	home, _ := os.UserHomeDir()
+	abs, _ := filepath.Abs(work)
+	dir := filepath.Join(home, ".claude", "projects", claude.EncodePath(abs))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Join([]string{
+		`{"type":"user","message":{"role":"user","content":"list the files"}}`,
+		`{"type":"assistant","message":{"id":"m1","role":"assistant","content":[{"type":"tool_use","id":"toolu_1","name":"Bash","input":{"command":"ls"}}]}}`,
+		`{"type":"user","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_1","content":"go.mod"}]}}`,
+		`{"type":"assistant","message":{"id":"m2","role":"assistant","content":[{"type":"text","text":"Just go.mod."}]}}`,
+	}, "\n")
+	if err := os.WriteFile(filepath.Join(dir, "c0ffee00-0000-4000-8000-000000000000.jsonl"), []byte(lines+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	out.Reset()
+	if code := sessionsImportClaude(store, work, []string{"c0ffee"}, &out); code != 0 ||
+		!strings.Contains(out.String(), "(4 messages)") {
+		t.Fatalf("import = %d %q", code, out.String())
+	}
+	all, err := store.List()
+	if err != nil || len(all) != 1 || all[0].Title != "claude: list the files" {
+		t.Fatalf("imported session = %+v, %v", all, err)
+	}
+
+	out.Reset()
+	if code := sessionsExport(store, work, []string{all[0].ID, "--format", "claude"}, &out); code != 0 ||
+		!strings.Contains(out.String(), "claude --resume") {
+		t.Fatalf("export = %d %q", code, out.String())
+	}
+	sessions, err := claude.ListSessions(work)
+	if err != nil || len(sessions) != 2 {
+		t.Fatalf("Claude Code sessions after export = %+v, %v", sessions, err)
+	}
+	exported := sessions[0]
+	if strings.HasPrefix(exported.ID, "c0ffee") {
+		exported = sessions[1]
+	}
+	msgs, err := claude.ImportMessages(exported.Path)
+	if err != nil || len(msgs) != 4 {
+		t.Fatalf("exported session re-imports as %v, %v", msgs, err)
+	}
+	if call, ok := msgs[1].(*message.ToolCallMessage); !ok || call.ToolName() != "Bash" || call.ID() != "toolu_1" {
+		t.Errorf("tool call lost in the round trip: %v", msgs[1])
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fpt/klein-cli/internal/config"
+	"github.com/fpt/klein-cli/internal/telemetry"
+	pkgLogger "github.com/fpt/klein-cli/pkg/logger"
+)
+
+// runTraceCommand implements `klein trace show <session|file>`, rendering a
+// session's JSONL trace as a tree of turns, iterations, LLM and tool calls.
+func runTraceCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Println(traceUsage)
+		return 1
+	}
+	switch args[0] {
+	case "show":
+		if len(args) != 2 {
+			fmt.Printf("show needs exactly one session id or trace file.\n\n%s\n", traceUsage)
+			return 1
+		}
+		workingDir, err := os.Getwd()
+		if err != nil {
+			fmt.Printf("Failed to determine working directory: %v\n", err)
+			return 1
+		}
+		return traceShow(workingDir, args[1], os.Stdout)
+	default:
+		fmt.Printf("Unknown trace subcommand %q.\n\n%s\n", args[0], traceUsage)
+		return 1
+	}
+}
+
+const traceUsage = `Usage:
+  klein trace show <session-id|file>
+
+Shows the spans recorded for a session when [trace] exporter = "jsonl": one
+tree per turn, with each iteration, LLM call (model, tokens, cache hits) and
+tool call (argument and result sizes, whether the result was offloaded),
+subagents nested under the Task call that ran them. Every line gives the
+span's duration and its start offset into the turn.
+
+<session-id> is any unique prefix of a session of the project in the current
+directory. A path may name a trace file, or a session file whose trace sits
+beside it (serve-mode sessions live under <base_dir>/sessions).
+
+Examples:
+  klein trace show 20261018T0912
+  klein trace show ~/.klein/traces/20261018T091200.jsonl`
+
+// traceShow resolves ref to a trace file and writes its tree to w.
+func traceShow(workingDir, ref string, w io.Writer) int {
+	path, err := resolveTraceFile(workingDir, ref)
+	if err != nil {
+		fmt.Fprintln(w, err)
+		return 1
+	}
+	spans, err := telemetry.ReadTrace(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			fmt.Fprintf(w, "No trace recorded at %s. Set [trace] exporter = \"jsonl\" in settings to record one.\n", path)
+		} else {
+			fmt.Fprintln(w, err)
+		}
+		return 1
+	}
+	if len(spans) == 0 {
+		fmt.Fprintf(w, "%s holds no spans.\n", path)
+		return 1
+	}
+	telemetry.WriteTree(w, spans)
+	return 0
+}
+
+// resolveTraceFile maps ref to a trace file: an existing file is taken as
+// named (a session file meaning the trace beside it), anything else as a
+// session of the current project.
+func resolveTraceFile(workingDir, ref string) (string, error) {
+	if fi, err := os.Stat(ref); err == nil && !fi.IsDir() {
+		if strings.HasSuffix(ref, ".json") {
+			return telemetry.TraceFile(ref), nil
+		}
+		return ref, nil
+	}
+	store, err := projectSessionStore(workingDir)
+	if err != nil {
+		return "", err
+	}
+	info, err := store.Resolve(ref)
+	if err != nil {
+		return "", err
+	}
+	return telemetry.TraceFile(info.Path), nil
+}
+
+// startTracing installs the exporter settings.Trace names and returns the
+// function that flushes it, for the caller to defer. Tracing failing to start
+// is worth a warning, never a refusal to run.
+func startTracing(ctx context.Context, settings *config.Settings, logger *pkgLogger.Logger) func() {
+	defaultFile := filepath.Join(settings.TracesDir(), time.Now().Format("20060102T150405")+".jsonl")
+	shutdown, err := telemetry.Setup(ctx, settings.Trace, defaultFile)
+	if err != nil {
+		logger.Warn("Tracing disabled", "error", err)
+		return func() {}
+	}
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdown(ctx); err != nil {
+			logger.Warn("Failed to flush traces", "error", err)
+		}
+	}
+}
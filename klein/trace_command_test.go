@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTraceShow(t *testing.T) {
+	dir := t.TempDir()
+	sessionFile := filepath.Join(dir, "20261018T090000.000000000.json")
+	if err := os.WriteFile(sessionFile, []byte("[]"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if code := traceShow(dir, sessionFile, &out); code == 0 || !strings.Contains(out.String(), "No trace recorded") {
+		t.Errorf("untraced session: exit %d, %q", code, out.String())
+	}
+
+	trace := `{"trace_id":"t1","span_id":"a","name":"agent.invoke","start":"2026-10-18T09:00:00Z","end":"2026-10-18T09:00:02Z","attributes":{"klein.skill":"code"}}
+{"trace_id":"t1","span_id":"b","parent_id":"a","name":"tool.call","start":"2026-10-18T09:00:01Z","end":"2026-10-18T09:00:01.5Z","attributes":{"gen_ai.tool.name":"Bash"}}
+{"trace_id":"t1","span_id":"c","parent_`
+	if err := os.WriteFile(sessionFile+".trace", []byte(trace), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// The session file and its trace name the same tree; the truncated last
+	// line of a killed run is skipped.
+	for _, ref := range []string{sessionFile, sessionFile + ".trace"} {
+		out.Reset()
+		if code := traceShow(dir, ref, &out); code != 0 {
+			t.Fatalf("show %s exited %d: %s", ref, code, out.String())
+		}
+		for _, want := range []string{"turn code", "└─ tool Bash", "500ms  +1.00s"} {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("show %s lacks %q:\n%s", ref, want, out.String())
+			}
+		}
+	}
+}
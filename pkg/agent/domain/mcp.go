@@ -124,6 +124,11 @@ func (a *MCPToolAdapter) RawName() message.ToolName {
 	return message.ToolName(a.mcpTool.Name)
 }
 
+// ServerName returns the name of the MCP server that provides the tool.
+func (a *MCPToolAdapter) ServerName() string {
+	return a.serverName
+}
+
 // Name returns the raw tool name for better LLM compatibility
 // Server isolation is handled at the tool manager level
 func (a *MCPToolAdapter) Name() message.ToolName {
@@ -13,10 +13,13 @@ type State interface {
 	Clear()
 	// CleanupMandatory performs mandatory cleanup (remove images, situation messages) without compaction
 	CleanupMandatory() error
-	// CompactIfNeeded performs compaction only if token usage exceeds threshold.
-	// Returns (true, nil) when compaction was performed, (false, nil) when not needed,
-	// and (false, err) on error.
-	CompactIfNeeded(ctx context.Context, llm LLM, maxTokens int, thresholdPercent float64) (bool, error)
+	// CompactIfNeeded compacts with opts only when the context exceeds
+	// thresholdPercent of maxTokens (0 selects the default, 70%). The result's
+	// Compacted is false when nothing was needed or nothing could be done.
+	CompactIfNeeded(ctx context.Context, llm LLM, maxTokens int, thresholdPercent float64, opts CompactionOptions) (CompactionResult, error)
+	// Compact compacts now, whatever the context's size. It is meant for
+	// between turns: only the system prompts survive in full.
+	Compact(ctx context.Context, llm LLM, opts CompactionOptions) (CompactionResult, error)
 	GetValidConversationHistory(maxMessages int) []message.Message
 	RemoveMessagesBySource(source message.MessageSource) int
 	// GetTotalTokenUsage returns the total token usage across all messages
@@ -25,3 +28,36 @@ type State interface {
 	SaveToFile() error
 	LoadFromFile() error
 }
+
+// CompactionStrategy selects how compaction shrinks the conversation.
+type CompactionStrategy string
+
+const (
+	// CompactionSummary replaces older turns with an LLM-written summary.
+	CompactionSummary CompactionStrategy = "summary"
+	// CompactionMicro clears old tool results in place and calls no model.
+	CompactionMicro CompactionStrategy = "micro"
+	// CompactionStructured replaces older turns with a sectioned summary that
+	// keeps the decisions made, the open todos and the files touched.
+	CompactionStructured CompactionStrategy = "structured"
+)
+
+// CompactionStrategies lists the strategies, default first.
+var CompactionStrategies = []CompactionStrategy{CompactionSummary, CompactionMicro, CompactionStructured}
+
+// CompactionOptions steers one compaction.
+type CompactionOptions struct {
+	Strategy CompactionStrategy // "" = CompactionSummary
+	// Focus is extra instruction for the summariser, e.g. "keep the API
+	// design discussion". The micro strategy has no summary to steer.
+	Focus string
+}
+
+// CompactionResult reports what a compaction did, with the context's size in
+// tokens either side of it.
+type CompactionResult struct {
+	Compacted    bool
+	Strategy     CompactionStrategy // the strategy that ran
+	BeforeTokens int
+	AfterTokens  int
+}
@@ -35,3 +35,14 @@ type ContextWindowProvider interface {
 type ModelIdentifier interface {
 	ModelID() string
 }
+
+// TokenCounter is an optional extension that LLM clients can implement to
+// count tokens offline in their model's tokenizer. Counts for models whose
+// tokenizer is not public are calibrated estimates.
+//
+// Unlike TokenUsageProvider it needs no API call, so it can measure text
+// before it is sent: the context a compaction would leave, or a prompt
+// section's share of the window.
+type TokenCounter interface {
+	CountTokens(text string) int
+}
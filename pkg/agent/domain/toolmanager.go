@@ -25,6 +25,26 @@ type ToolManager interface {
 	CallTool(ctx context.Context, name message.ToolName, args message.ToolArgumentValues) (message.ToolResult, error)
 }
 
+// ToolOutputFunc receives output a tool produces while it is still running.
+// Stream names where the chunk came from ("stdout" or "stderr"); chunk is one
+// or more whole lines, or a long partial line.
+type ToolOutputFunc func(stream, chunk string)
+
+type toolOutputKey struct{}
+
+// WithToolOutput returns a context that carries fn to the tool handler, so
+// long-running tools can report partial output before their result is ready.
+func WithToolOutput(ctx context.Context, fn ToolOutputFunc) context.Context {
+	return context.WithValue(ctx, toolOutputKey{}, fn)
+}
+
+// ToolOutput returns the partial-output sink carried by ctx, or nil when no
+// one is listening.
+func ToolOutput(ctx context.Context) ToolOutputFunc {
+	fn, _ := ctx.Value(toolOutputKey{}).(ToolOutputFunc)
+	return fn
+}
+
 // ToolChoiceType represents the type of tool choice
 type ToolChoiceType string
 
@@ -10,14 +10,16 @@ import (
 type EventType string
 
 const (
-	EventTypeThinkingChunk EventType = "thinking_chunk"
-	EventTypeToolCallStart EventType = "tool_call_start"
-	EventTypeToolCallEnd   EventType = "tool_call_end"
-	EventTypeToolResult    EventType = "tool_result"
-	EventTypeResponse      EventType = "response"
-	EventTypeError         EventType = "error"
-	EventTypeSubAgentStart EventType = "sub_agent_start"
-	EventTypeSubAgentEnd   EventType = "sub_agent_end"
+	EventTypeThinkingChunk   EventType = "thinking_chunk"
+	EventTypeToolCallStart   EventType = "tool_call_start"
+	EventTypeToolCallEnd     EventType = "tool_call_end"
+	EventTypeToolResult      EventType = "tool_result"
+	EventTypeToolOutputDelta EventType = "tool_output_delta"
+	EventTypeResponse        EventType = "response"
+	EventTypeError           EventType = "error"
+	EventTypeSubAgentStart   EventType = "sub_agent_start"
+	EventTypeSubAgentEnd     EventType = "sub_agent_end"
+	EventTypeCompaction      EventType = "compaction"
 )
 
 // AgentEvent represents a structured event from the agent
@@ -62,6 +64,15 @@ type ToolResultData struct {
 	IsError  bool   `json:"is_error"`
 }
 
+// ToolOutputDeltaData carries output a running tool has produced so far, for
+// live display. The model still only sees the final ToolResult.
+type ToolOutputDeltaData struct {
+	ToolName string `json:"tool_name"`
+	CallID   string `json:"call_id,omitempty"`
+	Stream   string `json:"stream"` // "stdout" or "stderr"
+	Text     string `json:"text"`
+}
+
 // ResponseData contains the final agent response
 type ResponseData struct {
 	Message message.Message `json:"message"`
@@ -87,6 +98,15 @@ type SubAgentEndData struct {
 	IsError bool   `json:"is_error,omitempty"`
 }
 
+// CompactionData reports a compaction of the conversation, with its size in
+// tokens either side
+type CompactionData struct {
+	Strategy     string `json:"strategy"`
+	BeforeTokens int    `json:"before_tokens"`
+	AfterTokens  int    `json:"after_tokens"`
+	Manual       bool   `json:"manual,omitempty"` // asked for with /compact or the Compact RPC
+}
+
 // EventHandler is a function that processes agent events
 type EventHandler func(event AgentEvent)
 
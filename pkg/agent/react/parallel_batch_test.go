@@ -2,6 +2,8 @@ package react
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -185,6 +187,63 @@ func TestSkipApprovalBypassesWriteApproval(t *testing.T) {
 	}
 }
 
+// TestToolGateBindsBatchAndSkipApproval verifies that the tool gate is
+// consulted for every call of a batch, with approval skipped too: the gated
+// call never runs and its result carries the refusal, the other call runs.
+func TestToolGateBindsBatchAndSkipApproval(t *testing.T) {
+	for _, skip := range []bool{false, true} {
+		var ran []string
+		tools := map[message.ToolName]message.Tool{"Write": &stubTool{name: "Write"}}
+		tm := &mockToolManager{
+			getToolsFunc: func() map[message.ToolName]message.Tool { return tools },
+			callToolFunc: func(_ context.Context, _ message.ToolName, args message.ToolArgumentValues) (message.ToolResult, error) {
+				ran = append(ran, args["file_path"].(string))
+				return message.ToolResult{Text: "wrote"}, nil
+			},
+		}
+		turn := 0
+		llm := &mockLLM{
+			chatWithToolChoiceFunc: func(_ context.Context, _ []message.Message, _ domain.ToolChoice) (message.Message, error) {
+				turn++
+				if turn == 1 {
+					return message.NewToolCallBatch([]*message.ToolCallMessage{
+						message.NewToolCallMessage("Write", message.ToolArgumentValues{"file_path": ".env", "content": "x"}),
+						message.NewToolCallMessage("Write", message.ToolArgumentValues{"file_path": "main.go", "content": "y"}),
+					}), nil
+				}
+				return message.NewChatMessage(message.MessageTypeAssistant, "done"), nil
+			},
+		}
+
+		st := state.NewMessageState()
+		r, _ := NewReAct(llm, tm, st, noopSituation{}, 5)
+		r.SetSkipApproval(skip)
+		r.SetToolGate(func(tc *message.ToolCallMessage) error {
+			if tc.ToolArguments()["file_path"] == ".env" {
+				return errors.New("denied by permission rule")
+			}
+			return nil
+		})
+		if _, err := r.Run(context.Background(), "write files"); err != nil {
+			t.Fatalf("skip=%v: Run: %v", skip, err)
+		}
+		r.Close()
+
+		if len(ran) != 1 || ran[0] != "main.go" {
+			t.Errorf("skip=%v: ran %v, want only main.go", skip, ran)
+		}
+		refused := false
+		for _, m := range st.GetMessages() {
+			if tr, ok := m.(*message.ToolResultMessage); ok && strings.Contains(tr.Error, "denied by permission rule") {
+				refused = true
+			}
+		}
+		if !refused {
+			t.Errorf("skip=%v: no tool result reports the refusal", skip)
+		}
+	}
+}
+
 // stubTool is a minimal message.Tool used by these tests.
 type stubTool struct {
 	name string
@@ -2,16 +2,20 @@ package react
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/fpt/klein-cli/pkg/agent/domain"
 	"github.com/fpt/klein-cli/pkg/agent/events"
+	"github.com/fpt/klein-cli/pkg/agent/tracing"
 	pkgLogger "github.com/fpt/klein-cli/pkg/logger"
 	"github.com/fpt/klein-cli/pkg/message"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/codes"
 )
 
 // allSubAgentDispatch reports whether every call in the batch is a sub-agent
@@ -68,12 +72,37 @@ type ReAct struct {
 	// conservative built-in default is used.
 	bashWhitelist []string
 
+	// approvalCheck is an optional extra gate: when it reports true for a tool
+	// call the built-in checks would let through, the call pauses for approval
+	// anyway. Set via SetApprovalCheck; the agent uses it for persistent
+	// deny/ask permission rules.
+	approvalCheck func(*message.ToolCallMessage) bool
+
+	// toolGate is checked for every call right before it runs, including the
+	// calls of a batch and calls of agents that skip approval; a non-nil
+	// error refuses the call and becomes its result. Set via SetToolGate; the
+	// agent uses it so persistent deny/ask rules bind on every path.
+	// approvedCallID is the call a human just approved through the approval
+	// workflow, which the gate lets through.
+	toolGate       func(*message.ToolCallMessage) error
+	approvedCallID string
+
 	// skipApproval auto-approves tool calls that would otherwise pause for
 	// the parent's approval workflow. Set this for background subagents
 	// (declared `background: true`) where the caller has decided that the
 	// agent's declared tool list constitutes consent. Never set this on the
 	// top-level interactive agent.
 	skipApproval bool
+
+	// onCompact, when set, receives the conversation as it stood just before
+	// a mid-run compaction replaced older turns with a summary. Set via
+	// SetCompactionObserver.
+	onCompact func(before []message.Message)
+
+	// compactionThreshold (percent of the window; 0 = the state's default)
+	// and compaction steer mid-run compaction. Set via SetCompactionPolicy.
+	compactionThreshold float64
+	compaction          domain.CompactionOptions
 }
 
 // SetSkipApproval toggles auto-approval of every tool call. Intended for
@@ -105,6 +134,21 @@ func (r *ReAct) SetToolResultTransform(fn func(toolUseID, toolName, content stri
 	r.toolResultTransform = fn
 }
 
+// SetCompactionObserver registers fn to receive the messages a compaction is
+// about to summarize away (the whole history before it ran). The agent uses it
+// to distil long-term memories from turns that would otherwise be lost. Pass
+// nil to disable.
+func (r *ReAct) SetCompactionObserver(fn func(before []message.Message)) {
+	r.onCompact = fn
+}
+
+// SetCompactionPolicy sets when mid-run compaction triggers, as a percentage
+// of the context window (0 keeps the default), and which strategy it uses.
+func (r *ReAct) SetCompactionPolicy(thresholdPercent float64, opts domain.CompactionOptions) {
+	r.compactionThreshold = thresholdPercent
+	r.compaction = opts
+}
+
 // SetBashWhitelist sets the list of command prefixes that do not require user
 // approval. Pass the user's configured whitelist (settings.Bash.WhitelistedCommands);
 // when empty a conservative built-in default is used.
@@ -112,6 +156,19 @@ func (r *ReAct) SetBashWhitelist(whitelist []string) {
 	r.bashWhitelist = whitelist
 }
 
+// SetApprovalCheck sets an extra predicate that can require approval for a tool
+// call the built-in checks would let through. Pass nil to disable.
+func (r *ReAct) SetApprovalCheck(fn func(*message.ToolCallMessage) bool) {
+	r.approvalCheck = fn
+}
+
+// SetToolGate sets a check every tool call must pass right before it runs,
+// whether it arrives alone, in a batch, or with approval skipped. A call the
+// approval workflow just approved is exempt. Pass nil to disable.
+func (r *ReAct) SetToolGate(fn func(*message.ToolCallMessage) error) {
+	r.toolGate = fn
+}
+
 // GetLastMessage returns the last message in the conversation without exposing state
 func (r *ReAct) GetLastMessage() message.Message {
 	return r.state.GetLastMessage()
@@ -160,6 +217,55 @@ func (r *ReAct) GetConversationSummary() string {
 	return summary.String()
 }
 
+// chat makes the iteration's model call under an llm.chat span recording
+// the model that answered and the tokens it reported.
+func (r *ReAct) chat(ctx context.Context, messages []message.Message) (message.Message, error) {
+	ctx, span := tracing.Start(ctx, tracing.SpanLLM, tracing.AttrMessages.Int(len(messages)))
+
+	// Use tool calling if available, otherwise fall back to thinking/regular chat
+	var resp message.Message
+	var err error
+	if r.toolManager != nil && len(r.toolManager.GetTools()) > 0 {
+		// Use tool choice auto to let the LLM decide when to use tools
+		resp, err = r.chatWithToolChoice(ctx, messages, domain.ToolChoice{Type: domain.ToolChoiceAuto}, r.thinkingChan)
+	} else {
+		// Fall back to thinking if supported, otherwise regular chat
+		resp, err = r.chatWithThinkingIfSupported(ctx, messages, r.thinkingChan)
+	}
+
+	// After the call: a fallback chain reports the backend that served it.
+	span.SetAttributes(tracing.AttrModel.String(r.llmClient.ModelID()))
+	if err == nil {
+		span.SetAttributes(tracing.AttrResponseType.String(responseKind(resp)))
+		if usageProvider, ok := r.llmClient.(domain.TokenUsageProvider); ok {
+			if usage, ok := usageProvider.LastTokenUsage(); ok {
+				span.SetAttributes(
+					tracing.AttrInputTokens.Int(usage.InputTokens),
+					tracing.AttrOutputTokens.Int(usage.OutputTokens),
+					tracing.AttrCachedTokens.Int(usage.CachedTokens),
+					tracing.AttrCacheCreationTokens.Int(usage.CacheCreationTokens),
+				)
+			}
+		}
+	}
+	tracing.End(span, err)
+	return resp, err
+}
+
+// responseKind names a model response for its llm.chat span.
+func responseKind(resp message.Message) string {
+	switch resp.Type() {
+	case message.MessageTypeToolCall:
+		return "tool_call"
+	case message.MessageTypeToolCallBatch:
+		return "tool_calls"
+	case message.MessageTypeReasoning:
+		return "reasoning"
+	default:
+		return "answer"
+	}
+}
+
 // chatWithThinkingIfSupported uses thinking if the LLM client supports it
 func (r *ReAct) chatWithThinkingIfSupported(ctx context.Context, messages []message.Message, thinkingChan chan<- string) (message.Message, error) {
 	return r.llmClient.Chat(ctx, messages, true, thinkingChan)
@@ -255,6 +361,10 @@ func (r *ReAct) Resume(ctx context.Context) (message.Message, error) {
 	if r.pendingToolCall != nil {
 		resp := r.pendingToolCall
 		r.pendingToolCall = nil
+		if tc, ok := resp.(*message.ToolCallMessage); ok {
+			r.approvedCallID = tc.ID()
+			defer func() { r.approvedCallID = "" }()
+		}
 
 		done, err := r.processResponse(ctx, r.currentIteration, resp)
 		if err != nil {
@@ -332,90 +442,115 @@ func (r *ReAct) runInternal(ctx context.Context) (message.Message, error) {
 			return nil, fmt.Errorf("used %d of %d budgeted tokens: %w", r.usedTokens, r.tokenBudget, ErrTokenBudgetExceeded)
 		}
 
-		// Remove any previous situation messages to avoid context contamination
-		if removedCount := r.state.RemoveMessagesBySource(message.MessageSourceSituation); removedCount > 0 {
-			reactLogger.DebugWithIntention(pkgLogger.IntentionDebug, "Removed previous situation messages", "count", removedCount)
+		iterCtx, span := tracing.Start(ctx, tracing.SpanIteration, tracing.AttrIteration.Int(r.currentIteration+1))
+		resp, done, err := r.runIteration(iterCtx)
+		if errors.Is(err, ErrWaitingForApproval) {
+			// A pause, not a failure: the iteration resumes once approved.
+			span.SetAttributes(tracing.AttrWaiting.Bool(true))
+			tracing.End(span, nil)
+		} else {
+			tracing.End(span, err)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if done {
+			r.status = domain.AgentStatusCompleted
+			return resp, nil
 		}
+	}
 
-		r.situation.InjectMessage(r.state, r.currentIteration, r.maxIterations)
+	// TBD: If it exhausted with tool calls, we might want to drop it to prevent Anthropic's error.
+	return nil, fmt.Errorf("exceeded maximum loop limit (%d) without a valid response", r.maxIterations)
+}
 
-		// Apply mandatory cleanup (remove images, situation messages) every iteration
-		if err := r.state.CleanupMandatory(); err != nil {
-			return nil, fmt.Errorf("failed to perform mandatory cleanup: %w", err)
-		}
+// runIteration is one turn of the loop: compact if needed, call the model,
+// and act on its response. done reports a final answer.
+func (r *ReAct) runIteration(ctx context.Context) (message.Message, bool, error) {
+	// Remove any previous situation messages to avoid context contamination
+	if removedCount := r.state.RemoveMessagesBySource(message.MessageSourceSituation); removedCount > 0 {
+		reactLogger.DebugWithIntention(pkgLogger.IntentionDebug, "Removed previous situation messages", "count", removedCount)
+	}
 
-		// Apply compaction only if the backend doesn't handle it server-side.
-		// Backends like OpenAI Responses API (truncation: "auto") manage overflow
-		// on the server, so client-side compaction is unnecessary.
-		if ssc, ok := r.llmClient.(domain.ServerSideCompactionLLM); !ok || !ssc.SupportsServerSideCompaction() {
-			maxTokensEstimate := r.estimateContextWindow()
-			const compactionThreshold = 70.0 // 70% threshold
-			if _, err := r.state.CompactIfNeeded(ctx, r.llmClient, maxTokensEstimate, compactionThreshold); err != nil {
-				return nil, fmt.Errorf("failed to compact messages when needed: %w", err)
-			}
-		}
-		messages := r.state.GetMessages()
+	r.situation.InjectMessage(r.state, r.currentIteration, r.maxIterations)
 
-		// Use tool calling if available, otherwise fall back to thinking/regular chat
-		var resp message.Message
-		var err error
+	// Apply mandatory cleanup (remove images, situation messages) every iteration
+	if err := r.state.CleanupMandatory(); err != nil {
+		return nil, false, fmt.Errorf("failed to perform mandatory cleanup: %w", err)
+	}
 
-		// Check if we have tools available and should use tool calling
-		if r.toolManager != nil && len(r.toolManager.GetTools()) > 0 {
-			// Use tool choice auto to let the LLM decide when to use tools
-			resp, err = r.chatWithToolChoice(ctx, messages, domain.ToolChoice{Type: domain.ToolChoiceAuto}, r.thinkingChan)
-		} else {
-			// Fall back to thinking if supported, otherwise regular chat
-			resp, err = r.chatWithThinkingIfSupported(ctx, messages, r.thinkingChan)
+	// Apply compaction only if the backend doesn't handle it server-side.
+	// Backends like OpenAI Responses API (truncation: "auto") manage overflow
+	// on the server, so client-side compaction is unnecessary.
+	if ssc, ok := r.llmClient.(domain.ServerSideCompactionLLM); !ok || !ssc.SupportsServerSideCompaction() {
+		maxTokensEstimate := r.estimateContextWindow()
+		var before []message.Message
+		if r.onCompact != nil {
+			before = slices.Clone(r.state.GetMessages())
 		}
-
+		res, err := r.state.CompactIfNeeded(ctx, r.llmClient, maxTokensEstimate, r.compactionThreshold, r.compaction)
 		if err != nil {
-			// Check if the error is due to context cancellation
-			if ctx.Err() == context.Canceled {
-				reactLogger.InfoWithIntention(pkgLogger.IntentionCancel, "Operation cancelled by user during LLM call. History preserved.")
-				return nil, ctx.Err()
+			return nil, false, fmt.Errorf("failed to compact messages when needed: %w", err)
+		}
+		if res.Compacted {
+			r.eventEmitter.EmitEvent(events.EventTypeCompaction, events.CompactionData{
+				Strategy:     string(res.Strategy),
+				BeforeTokens: res.BeforeTokens,
+				AfterTokens:  res.AfterTokens,
+			})
+			if r.onCompact != nil {
+				r.onCompact(before)
 			}
-			return nil, fmt.Errorf("failed to get response from LLM client: %w", err)
 		}
+	}
+	messages := r.state.GetMessages()
 
-		// Clear waiting indicator and show minified response
-		fmt.Print("\r                    \r") // Clear the "Thinking..." line
-		// Annotate and log token usage when available
-		r.annotateAndLogUsage(resp)
-		// Accumulate usage for the run-level token budget (every LLM call).
-		r.accumulateUsage()
+	resp, err := r.chat(ctx, messages)
+	if err != nil {
+		// Check if the error is due to context cancellation
+		if ctx.Err() == context.Canceled {
+			reactLogger.InfoWithIntention(pkgLogger.IntentionCancel, "Operation cancelled by user during LLM call. History preserved.")
+			return nil, false, ctx.Err()
+		}
+		return nil, false, fmt.Errorf("failed to get response from LLM client: %w", err)
+	}
 
-		// Check tool call if it requires user's approval (file writing operations and bash commands)
-		if toolCall, ok := resp.(*message.ToolCallMessage); ok && !r.skipApproval {
-			toolName := string(toolCall.ToolName())
+	// Clear waiting indicator and show minified response
+	fmt.Print("\r                    \r") // Clear the "Thinking..." line
+	// Annotate and log token usage when available
+	r.annotateAndLogUsage(resp)
+	// Accumulate usage for the run-level token budget (every LLM call).
+	r.accumulateUsage()
 
-			// Check for file operations that require approval
-			requiresApproval := toolName == "Write" || toolName == "Edit" || toolName == "MultiEdit"
+	// Check tool call if it requires user's approval (file writing operations and bash commands)
+	if toolCall, ok := resp.(*message.ToolCallMessage); ok && !r.skipApproval {
+		toolName := string(toolCall.ToolName())
 
-			// Check for bash commands that may require approval
-			if !requiresApproval && (toolName == "Bash") {
-				requiresApproval = r.bashCommandRequiresApproval(toolCall)
-			}
+		// Check for file operations that require approval (Rename rewrites
+		// every file the language server says uses the symbol)
+		requiresApproval := toolName == "Write" || toolName == "Edit" || toolName == "MultiEdit" || toolName == "Rename"
 
-			if requiresApproval {
-				r.pendingToolCall = toolCall
-				r.status = domain.AgentStatusWaitingForApproval
-				return nil, ErrWaitingForApproval
-			}
+		// Check for bash commands that may require approval
+		if !requiresApproval && (toolName == "Bash") {
+			requiresApproval = r.bashCommandRequiresApproval(toolCall)
 		}
 
-		done, err := r.processResponse(ctx, r.currentIteration, resp)
-		if err != nil {
-			return nil, err
+		if !requiresApproval && r.approvalCheck != nil {
+			requiresApproval = r.approvalCheck(toolCall)
 		}
-		if done {
-			r.status = domain.AgentStatusCompleted
-			return resp, nil
+
+		if requiresApproval {
+			r.pendingToolCall = toolCall
+			r.status = domain.AgentStatusWaitingForApproval
+			return nil, false, ErrWaitingForApproval
 		}
 	}
 
-	// TBD: If it exhausted with tool calls, we might want to drop it to prevent Anthropic's error.
-	return nil, fmt.Errorf("exceeded maximum loop limit (%d) without a valid response", r.maxIterations)
+	done, err := r.processResponse(ctx, r.currentIteration, resp)
+	if err != nil {
+		return nil, false, err
+	}
+	return resp, done, nil
 }
 
 // processResponse processes input using the configured maxIterations
@@ -456,7 +591,7 @@ func (r *ReAct) processResponse(ctx context.Context, currentIter int, resp messa
 		r.eventEmitter.EmitEvent(events.EventTypeToolCallStart, events.ToolCallStartData{
 			ToolName:  string(toolCall.ToolName()),
 			Arguments: message.SummarizeToolArgs(toolCall.ToolArguments()),
-			CallID:    "", // Could add call ID if needed
+			CallID:    toolCall.ID(),
 		})
 		msg, err := r.handleToolCall(ctx, toolCall)
 		if err != nil {
@@ -507,6 +642,7 @@ func (r *ReAct) processResponse(ctx context.Context, currentIter int, resp messa
 				r.eventEmitter.EmitEvent(events.EventTypeToolCallStart, events.ToolCallStartData{
 					ToolName:  string(call.ToolName()),
 					Arguments: message.SummarizeToolArgs(call.ToolArguments()),
+					CallID:    call.ID(),
 				})
 				wg.Add(1)
 				go func(idx int, c *message.ToolCallMessage) {
@@ -535,6 +671,7 @@ func (r *ReAct) processResponse(ctx context.Context, currentIter int, resp messa
 				r.eventEmitter.EmitEvent(events.EventTypeToolCallStart, events.ToolCallStartData{
 					ToolName:  string(call.ToolName()),
 					Arguments: message.SummarizeToolArgs(call.ToolArguments()),
+					CallID:    call.ID(),
 				})
 				msg, err := r.handleToolCall(ctx, call)
 				if err != nil {
@@ -557,9 +694,32 @@ func (r *ReAct) handleToolCall(ctx context.Context, toolCall *message.ToolCallMe
 	toolName := toolCall.ToolName()
 	toolArgs := toolCall.ToolArguments()
 
+	if r.toolGate != nil && id != r.approvedCallID {
+		if err := r.toolGate(toolCall); err != nil {
+			return message.NewToolResultMessage(id, "", err.Error()), nil
+		}
+	}
+
+	// Let long-running tools (Bash) stream partial output to the event stream
+	// while the model waits for the final result.
+	ctx = domain.WithToolOutput(ctx, func(stream, chunk string) {
+		r.eventEmitter.EmitEvent(events.EventTypeToolOutputDelta, events.ToolOutputDeltaData{
+			ToolName: string(toolName),
+			CallID:   id,
+			Stream:   stream,
+			Text:     chunk,
+		})
+	})
+
+	ctx, span := tracing.Start(ctx, tracing.SpanTool,
+		tracing.AttrToolName.String(string(toolName)),
+		tracing.AttrToolCallID.String(id),
+		tracing.AttrArgsBytes.Int(argsSize(toolArgs)))
+
 	// Execute tool and get structured result
 	toolResult, err := r.toolManager.CallTool(ctx, toolName, toolArgs)
 	if err != nil {
+		tracing.End(span, err)
 		// Don't return an error - create a tool result message with the error instead
 		// This allows the agent to continue and let the LLM see the error message
 		return message.NewToolResultMessage(id, "", fmt.Sprintf("Tool execution failed: %v", err)), nil
@@ -572,6 +732,15 @@ func (r *ReAct) handleToolCall(ctx context.Context, toolCall *message.ToolCallMe
 	if toolResult.Error == "" && len(toolResult.Images) == 0 && r.toolResultTransform != nil {
 		resultText = r.toolResultTransform(id, string(toolName), resultText)
 	}
+	span.SetAttributes(
+		tracing.AttrResultBytes.Int(len(toolResult.Text)),
+		tracing.AttrOffloaded.Bool(resultText != toolResult.Text),
+	)
+	if toolResult.Error != "" {
+		// The model sees the error and carries on; the span still shows it.
+		span.SetStatus(codes.Error, toolResult.Error)
+	}
+	span.End()
 
 	// Handle structured tool result
 	var resp message.Message
@@ -586,6 +755,15 @@ func (r *ReAct) handleToolCall(ctx context.Context, toolCall *message.ToolCallMe
 	return resp, nil
 }
 
+// argsSize is the size of a tool call's arguments as JSON, for its span.
+func argsSize(args message.ToolArgumentValues) int {
+	data, err := json.Marshal(args)
+	if err != nil {
+		return 0
+	}
+	return len(data)
+}
+
 // printTruncatedToolResult emits tool result events
 func (r *ReAct) printTruncatedToolResult(msg message.Message) {
 	content := strings.TrimRight(msg.Content(), "\n")
@@ -594,7 +772,7 @@ func (r *ReAct) printTruncatedToolResult(msg message.Message) {
 	// Emit tool result event
 	r.eventEmitter.EmitEvent(events.EventTypeToolResult, events.ToolResultData{
 		ToolName: "", // Tool name would need to be tracked separately
-		CallID:   "", // Call ID would need to be tracked separately
+		CallID:   msg.ID(),
 		Content:  content,
 		IsError:  isError,
 	})
@@ -617,24 +795,15 @@ func (r *ReAct) emitEventWithIteration(eventType events.EventType, data interfac
 	}
 }
 
-// estimateContextWindow estimates the context window size based on common model patterns
+// estimateContextWindow returns the model's context window as the client
+// reports it, or a conservative 100k for clients that do not say.
 func (r *ReAct) estimateContextWindow() int {
-	// This is a conservative estimation based on common model types
-	// In the future, this should be replaced with dynamic model capability detection
-
-	// Try to get client type information if possible
-	clientType := fmt.Sprintf("%T", r.llmClient)
-
-	switch {
-	case strings.Contains(clientType, "anthropic"):
-		return 200000 // Claude models typically have 200k+ context windows
-	case strings.Contains(clientType, "openai"):
-		return 128000 // GPT-5.6 models have 128k context windows
-	case strings.Contains(clientType, "gemini"):
-		return 1000000 // Gemini models have very large context windows (1M+)
-	default:
-		return 100000 // Conservative fallback for unknown models
+	if cwp, ok := r.llmClient.(domain.ContextWindowProvider); ok {
+		if n := cwp.MaxContextTokens(); n > 0 {
+			return n
+		}
 	}
+	return 100000
 }
 
 // bashCommandRequiresApproval checks if a bash command requires user approval.
@@ -548,6 +548,12 @@ func TestReAct_StateManagement(t *testing.T) {
 	}
 }
 
+// withTokens pads content with n words, about n tokens, so a message takes
+// that much of the context window when compaction counts it.
+func withTokens(content string, n int) string {
+	return content + strings.Repeat(" word", n)
+}
+
 func TestReAct_compaction(t *testing.T) {
 	tests := []struct {
 		name              string
@@ -614,9 +620,9 @@ func TestReAct_compaction(t *testing.T) {
 			for i := 0; i < tt.initialMessages; i++ {
 				var msg message.Message
 				if i%2 == 0 {
-					msg = message.NewChatMessage(message.MessageTypeUser, fmt.Sprintf("User message %d", i+1))
+					msg = message.NewChatMessage(message.MessageTypeUser, withTokens(fmt.Sprintf("User message %d", i+1), 1500))
 				} else {
-					msg = message.NewChatMessage(message.MessageTypeAssistant, fmt.Sprintf("Assistant message %d", i+1))
+					msg = message.NewChatMessage(message.MessageTypeAssistant, withTokens(fmt.Sprintf("Assistant message %d", i+1), 1500))
 				}
 				react.state.AddMessage(msg)
 			}
@@ -627,17 +633,12 @@ func TestReAct_compaction(t *testing.T) {
 				t.Fatalf("Initial setup failed: expected %d messages, got %d", tt.initialMessages, initialCount)
 			}
 
-			// Set up high token usage to trigger compaction
-			// Add token usage to messages to exceed threshold
 			messages := react.state.GetMessages()
-			for _, msg := range messages {
-				msg.SetTokenUsage(1000, 500, 1500) // Each message uses 1500 tokens
-			}
 
 			// Perform compaction with low max tokens to trigger it
 			ctx := context.Background()
 			maxTokens := len(messages) * 500 // Much lower than actual usage to force compaction
-			_, err := react.state.CompactIfNeeded(ctx, mockLLM, maxTokens, 70.0)
+			_, err := react.state.CompactIfNeeded(ctx, mockLLM, maxTokens, 70.0, domain.CompactionOptions{})
 			if err != nil {
 				t.Fatalf("Compaction failed: %v", err)
 			}
@@ -693,6 +694,62 @@ func TestReAct_compaction(t *testing.T) {
 	}
 }
 
+// TestReAct_compactionObserver verifies a mid-run compaction hands the
+// pre-compaction history to the observer, and that no compaction means no call.
+func TestReAct_compactionObserver(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		tokensEach  int
+		wantObserve bool
+	}{
+		{name: "compacts", tokensEach: 4000, wantObserve: true},
+		{name: "below threshold", tokensEach: 10, wantObserve: false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mockLLM := &mockLLM{
+				chatFunc: func(ctx context.Context, messages []message.Message) (message.Message, error) {
+					return message.NewChatMessage(message.MessageTypeAssistant, "done"), nil
+				},
+			}
+			react, _ := NewReAct(mockLLM, &mockToolManager{}, state.NewMessageState(), &mockSituation{}, 3)
+			for i := 0; i < 30; i++ {
+				typ := message.MessageTypeUser
+				if i%2 == 1 {
+					typ = message.MessageTypeAssistant
+				}
+				react.state.AddMessage(message.NewChatMessage(typ, withTokens(fmt.Sprintf("message %d", i+1), tc.tokensEach)))
+			}
+
+			var observed []message.Message
+			calls := 0
+			react.SetCompactionObserver(func(before []message.Message) {
+				calls++
+				observed = before
+			})
+			if _, err := react.Run(context.Background(), "next question"); err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+
+			if !tc.wantObserve {
+				if calls != 0 {
+					t.Fatalf("observer called %d times without a compaction", calls)
+				}
+				return
+			}
+			if calls != 1 {
+				t.Fatalf("observer called %d times, want 1", calls)
+			}
+			// The history as it stood before compaction: 30 seeded turns plus the new input.
+			if len(observed) != 31 || !strings.HasPrefix(observed[0].Content(), "message 1 ") {
+				t.Fatalf("observed %d messages starting %.20q", len(observed), observed[0].Content())
+			}
+			if len(react.state.GetMessages()) >= len(observed) {
+				t.Fatalf("state was not compacted: %d messages", len(react.state.GetMessages()))
+			}
+		})
+	}
+}
+
 func TestReAct_compactionEdgeCases(t *testing.T) {
 	t.Run("EmptyMessageState", func(t *testing.T) {
 		mockLLM := &mockLLM{
@@ -709,7 +766,7 @@ func TestReAct_compactionEdgeCases(t *testing.T) {
 
 		// Test compaction with empty state (should not do anything)
 		ctx := context.Background()
-		_, err := react.state.CompactIfNeeded(ctx, mockLLM, 100000, 70.0)
+		_, err := react.state.CompactIfNeeded(ctx, mockLLM, 100000, 70.0, domain.CompactionOptions{})
 		if err != nil {
 			t.Fatalf("Compaction of empty state failed: %v", err)
 		}
@@ -735,18 +792,14 @@ func TestReAct_compactionEdgeCases(t *testing.T) {
 
 		// Add exactly 60 messages (> 50 to trigger compaction attempt)
 		for i := 0; i < 60; i++ {
-			react.state.AddMessage(message.NewChatMessage(message.MessageTypeUser, fmt.Sprintf("Message %d", i+1)))
+			react.state.AddMessage(message.NewChatMessage(message.MessageTypeUser, withTokens(fmt.Sprintf("Message %d", i+1), 1500)))
 		}
 
-		// Set up high token usage to trigger compaction
 		messages := react.state.GetMessages()
-		for _, msg := range messages {
-			msg.SetTokenUsage(1000, 500, 1500) // Each message uses 1500 tokens
-		}
 
 		ctx := context.Background()
 		maxTokens := len(messages) * 500 // Lower than actual usage to force compaction
-		_, err := react.state.CompactIfNeeded(ctx, mockLLM, maxTokens, 70.0)
+		_, err := react.state.CompactIfNeeded(ctx, mockLLM, maxTokens, 70.0, domain.CompactionOptions{})
 		if err != nil {
 			t.Fatalf("Compaction failed: %v", err)
 		}
@@ -785,9 +838,9 @@ func TestReAct_compactionWithToolCalls(t *testing.T) {
 		// Add 46 regular messages (positions 0-45)
 		for i := 0; i < 46; i++ {
 			if i%2 == 0 {
-				react.state.AddMessage(message.NewChatMessage(message.MessageTypeUser, fmt.Sprintf("User message %d", i+1)))
+				react.state.AddMessage(message.NewChatMessage(message.MessageTypeUser, withTokens(fmt.Sprintf("User message %d", i+1), 1500)))
 			} else {
-				react.state.AddMessage(message.NewChatMessage(message.MessageTypeAssistant, fmt.Sprintf("Assistant message %d", i+1)))
+				react.state.AddMessage(message.NewChatMessage(message.MessageTypeAssistant, withTokens(fmt.Sprintf("Assistant message %d", i+1), 1500)))
 			}
 		}
 
@@ -807,9 +860,9 @@ func TestReAct_compactionWithToolCalls(t *testing.T) {
 		// Add 8 more regular messages (positions 48-55) - these will be the "recent" ones
 		for i := 0; i < 8; i++ {
 			if i%2 == 0 {
-				react.state.AddMessage(message.NewChatMessage(message.MessageTypeUser, fmt.Sprintf("Recent user message %d", i+1)))
+				react.state.AddMessage(message.NewChatMessage(message.MessageTypeUser, withTokens(fmt.Sprintf("Recent user message %d", i+1), 1500)))
 			} else {
-				react.state.AddMessage(message.NewChatMessage(message.MessageTypeAssistant, fmt.Sprintf("Recent assistant message %d", i+1)))
+				react.state.AddMessage(message.NewChatMessage(message.MessageTypeAssistant, withTokens(fmt.Sprintf("Recent assistant message %d", i+1), 1500)))
 			}
 		}
 
@@ -827,16 +880,12 @@ func TestReAct_compactionWithToolCalls(t *testing.T) {
 		t.Logf("Tool call at position 46, tool result at position 47")
 		t.Logf("Split point will be at position %d (total - 10)", initialCount-10)
 
-		// Set up high token usage to trigger compaction
 		messages := react.state.GetMessages()
-		for _, msg := range messages {
-			msg.SetTokenUsage(1000, 500, 1500) // Each message uses 1500 tokens
-		}
 
 		// Perform compaction
 		ctx := context.Background()
 		maxTokens := len(messages) * 500 // Lower than actual usage to force compaction
-		_, err := react.state.CompactIfNeeded(ctx, mockLLM, maxTokens, 70.0)
+		_, err := react.state.CompactIfNeeded(ctx, mockLLM, maxTokens, 70.0, domain.CompactionOptions{})
 		if err != nil {
 			t.Fatalf("Compaction failed: %v", err)
 		}
@@ -897,9 +946,9 @@ func TestReAct_compactionWithToolCalls(t *testing.T) {
 		// Add 60 regular messages (no tool calls) - compaction should work normally
 		for i := 0; i < 60; i++ {
 			if i%2 == 0 {
-				react.state.AddMessage(message.NewChatMessage(message.MessageTypeUser, fmt.Sprintf("User message %d", i+1)))
+				react.state.AddMessage(message.NewChatMessage(message.MessageTypeUser, withTokens(fmt.Sprintf("User message %d", i+1), 1500)))
 			} else {
-				react.state.AddMessage(message.NewChatMessage(message.MessageTypeAssistant, fmt.Sprintf("Assistant message %d", i+1)))
+				react.state.AddMessage(message.NewChatMessage(message.MessageTypeAssistant, withTokens(fmt.Sprintf("Assistant message %d", i+1), 1500)))
 			}
 		}
 
@@ -908,16 +957,12 @@ func TestReAct_compactionWithToolCalls(t *testing.T) {
 			t.Fatalf("Setup failed: expected 60 messages, got %d", initialCount)
 		}
 
-		// Set up high token usage to trigger compaction
 		messages := react.state.GetMessages()
-		for _, msg := range messages {
-			msg.SetTokenUsage(1000, 500, 1500) // Each message uses 1500 tokens
-		}
 
 		// Perform compaction
 		ctx := context.Background()
 		maxTokens := len(messages) * 500 // Lower than actual usage to force compaction
-		_, err := react.state.CompactIfNeeded(ctx, mockLLM, maxTokens, 70.0)
+		_, err := react.state.CompactIfNeeded(ctx, mockLLM, maxTokens, 70.0, domain.CompactionOptions{})
 		if err != nil {
 			t.Fatalf("Compaction failed: %v", err)
 		}
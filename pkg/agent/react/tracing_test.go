@@ -0,0 +1,74 @@
+package react
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/fpt/klein-cli/pkg/agent/state"
+	"github.com/fpt/klein-cli/pkg/agent/tracing"
+	"github.com/fpt/klein-cli/pkg/message"
+)
+
+// TestReAct_Spans checks that a run with one tool call records an iteration
+// per LLM round, each holding its llm.chat span, with the tool call under the
+// iteration that requested it.
+func TestReAct_Spans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	calls := 0
+	llm := &mockLLM{chatFunc: func(ctx context.Context, messages []message.Message) (message.Message, error) {
+		calls++
+		if calls == 1 {
+			return message.NewToolCallMessage("test_tool", message.ToolArgumentValues{"arg1": "value1"}), nil
+		}
+		return message.NewChatMessage(message.MessageTypeAssistant, "done"), nil
+	}}
+	tools := &mockToolManager{callToolFunc: func(ctx context.Context, name message.ToolName, args message.ToolArgumentValues) (message.ToolResult, error) {
+		return message.NewToolResultText("tool result"), nil
+	}}
+	r, _ := NewReAct(llm, tools, state.NewMessageState(), &mockSituation{}, 10)
+	if _, err := r.Run(context.Background(), "Use test tool"); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	byName := map[string][]sdktrace.ReadOnlySpan{}
+	for _, s := range recorder.Ended() {
+		byName[s.Name()] = append(byName[s.Name()], s)
+	}
+	iterations, chats, toolCalls := byName[tracing.SpanIteration], byName[tracing.SpanLLM], byName[tracing.SpanTool]
+	if len(iterations) != 2 || len(chats) != 2 || len(toolCalls) != 1 {
+		t.Fatalf("got %d iterations, %d llm calls, %d tool calls; want 2, 2, 1",
+			len(iterations), len(chats), len(toolCalls))
+	}
+
+	first := iterations[0].SpanContext().SpanID()
+	if toolCalls[0].Parent().SpanID() != first {
+		t.Error("the tool call is not a child of the iteration that requested it")
+	}
+	attrs := map[string]any{}
+	for _, kv := range toolCalls[0].Attributes() {
+		attrs[string(kv.Key)] = kv.Value.AsInterface()
+	}
+	if attrs[string(tracing.AttrToolName)] != "test_tool" || attrs[string(tracing.AttrResultBytes)] != int64(len("tool result")) {
+		t.Errorf("tool span attributes = %v", attrs)
+	}
+	for _, c := range chats {
+		var model any
+		for _, kv := range c.Attributes() {
+			if kv.Key == tracing.AttrModel {
+				model = kv.Value.AsInterface()
+			}
+		}
+		if model != "mock-llm" {
+			t.Errorf("llm span model = %v, want mock-llm", model)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package state
 import (
 	"context"
 	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/fpt/klein-cli/pkg/agent/domain"
@@ -71,7 +72,7 @@ func (c *MessageState) CleanupMandatory() error {
 	// 3. Single pass: strip vision content and micro-compact large tool results.
 	reduced := 0
 	for i := 0; i < boundary; i++ {
-		if cleaned, ok := applyLightweightCleanup(c.Messages[i]); ok {
+		if cleaned, ok := applyLightweightCleanup(c.Messages[i], microCompactMinChars); ok {
 			c.Messages[i] = cleaned
 			reduced++
 		}
@@ -106,16 +107,17 @@ func findTurnBoundary(messages []message.Message, n int) int {
 //
 //   - ChatMessage with images: image data is removed (vision truncation).
 //   - ToolResultMessage with images: image data is removed.
-//   - ToolResultMessage with a large result text: content is replaced with a
-//     stub that records the original size. Already-offloaded stubs are skipped.
+//   - ToolResultMessage with a result text over minChars: content is replaced
+//     with a stub that records the original size. Already-offloaded stubs are
+//     skipped.
 //
 // Returns (cleaned, true) when a reduction was made, (original, false) otherwise.
-func applyLightweightCleanup(msg message.Message) (message.Message, bool) {
+func applyLightweightCleanup(msg message.Message, minChars int) (message.Message, bool) {
 	switch m := msg.(type) {
 	case *message.ToolResultMessage:
 		stripImages := len(m.Images()) > 0
 		stubResult := m.Error == "" &&
-			len(m.Result) > microCompactMinChars &&
+			len(m.Result) > minChars &&
 			!strings.HasPrefix(m.Result, offloadStubPrefix) &&
 			!strings.HasPrefix(m.Result, "[Content cleared")
 
@@ -142,69 +144,40 @@ func applyLightweightCleanup(msg message.Message) (message.Message, bool) {
 	return msg, false
 }
 
-// getAccurateTokenCount returns the most accurate token count available
+// getAccurateTokenCount returns the context's size in tokens: the messages
+// counted in the model's tokenizer, or the input the last API call reported
+// when that is larger (it also covers tool schemas and provider framing).
+// Usage reported before the last compaction describes the history that
+// compaction replaced, so it is not used again.
 func (c *MessageState) getAccurateTokenCount(llm domain.LLM) int {
-	// Try to get actual token usage from the LLM client first
+	counted := CountMessageTokens(TokenCounterFor(llm), c.Messages)
 	if usageProvider, ok := llm.(domain.TokenUsageProvider); ok {
-		if usage, ok2 := usageProvider.LastTokenUsage(); ok2 && usage.InputTokens > 0 {
-			// Use actual input token count from last request as baseline
-			// This is the most accurate measure of current context size
-			return usage.InputTokens
+		if usage, ok2 := usageProvider.LastTokenUsage(); ok2 && usage.InputTokens != c.usageAtCompaction {
+			return max(counted, usage.InputTokens)
 		}
 	}
-
-	// Fallback: estimate from message content (improved heuristic)
-	return c.estimateTokensFromMessages()
-}
-
-// estimateTokensFromMessages provides improved token estimation from message content
-func (c *MessageState) estimateTokensFromMessages() int {
-	var totalTokens int
-
-	for _, msg := range c.Messages {
-		// Use stored token usage if available (most accurate)
-		if stored := msg.TotalTokens(); stored > 0 {
-			totalTokens += stored
-			continue
-		}
-
-		// Improved estimation heuristic
-		content := msg.Content()
-		thinking := msg.Thinking()
-
-		// Combine content and thinking for total character count
-		totalChars := len(content) + len(thinking)
-
-		// Conservative token estimation (to avoid overestimating)
-		// Use the original simple approach to maintain compatibility
-		var estimatedTokens int
-		if totalChars > 0 {
-			// Simple heuristic: ~4 chars/token + small overhead
-			estimatedTokens = int(float64(totalChars)/4.0) + 8
-		} else {
-			estimatedTokens = 8 // Minimum overhead for empty messages
-		}
-
-		totalTokens += estimatedTokens
-	}
-
-	return totalTokens
+	return counted
 }
 
-// CompactIfNeeded performs efficient token-based compaction.
-// Returns (true, nil) when compaction was performed, (false, nil) when not needed,
-// and (false, err) on error.
-func (c *MessageState) CompactIfNeeded(ctx context.Context, llm domain.LLM, maxTokens int, thresholdPercent float64) (bool, error) {
+// CompactIfNeeded compacts with opts when the context reaches
+// thresholdPercent of maxTokens (0 selects CompactAtPercent) and compaction
+// would save enough to be worth a pass.
+func (c *MessageState) CompactIfNeeded(ctx context.Context, llm domain.LLM, maxTokens int, thresholdPercent float64, opts domain.CompactionOptions) (domain.CompactionResult, error) {
 	if maxTokens <= 0 {
-		return false, nil // No token limit specified
+		return domain.CompactionResult{}, nil // No token limit specified
 	}
 
 	// Get accurate current token count
 	currentTokens := c.getAccurateTokenCount(llm)
 
-	// Calculate thresholds using standard compaction strategy
-	compactThreshold := int(float64(maxTokens) * CompactAtPercent)
-	targetAfterCompaction := int(float64(maxTokens) * TargetAfterPercent)
+	// Compact down to half the trigger point, or TargetAfterPercent if that
+	// is lower, so a low threshold still leaves room before the next pass.
+	compactAt := CompactAtPercent
+	if thresholdPercent > 0 {
+		compactAt = thresholdPercent / 100
+	}
+	compactThreshold := int(float64(maxTokens) * compactAt)
+	targetAfterCompaction := int(float64(maxTokens) * min(TargetAfterPercent, compactAt/2))
 
 	usagePercent := (float64(currentTokens) / float64(maxTokens)) * 100
 
@@ -219,8 +192,8 @@ func (c *MessageState) CompactIfNeeded(ctx context.Context, llm domain.LLM, maxT
 	if currentTokens < compactThreshold {
 		logger.DebugWithIntention(pkgLogger.IntentionStatistics, "Usage below compaction threshold, skipping",
 			"usage_percent", fmt.Sprintf("%.1f%%", usagePercent),
-			"threshold", fmt.Sprintf("%.1f%%", CompactAtPercent*100))
-		return false, nil
+			"threshold", fmt.Sprintf("%.1f%%", compactAt*100))
+		return domain.CompactionResult{}, nil
 	}
 
 	// Check if compaction will save meaningful tokens
@@ -228,19 +201,65 @@ func (c *MessageState) CompactIfNeeded(ctx context.Context, llm domain.LLM, maxT
 	if tokensToSave < MinReductionTokens {
 		logger.InfoWithIntention(pkgLogger.IntentionStatus, "Compaction would save too few tokens, skipping",
 			"tokens_to_save", tokensToSave, "min_reduction", MinReductionTokens)
-		return false, nil
+		return domain.CompactionResult{}, nil
 	}
 
 	logger.InfoWithIntention(pkgLogger.IntentionStatus, "Performing token-based compaction",
 		"current_tokens", currentTokens,
 		"usage_percent", fmt.Sprintf("%.1f%%", usagePercent),
 		"target_tokens", targetAfterCompaction,
-		"tokens_to_save", tokensToSave)
+		"tokens_to_save", tokensToSave,
+		"strategy", opts.Strategy)
 
-	if err := c.performCompaction(ctx, llm); err != nil {
-		return false, err
+	return c.compact(ctx, llm, opts, true, compactThreshold)
+}
+
+// Compact compacts the whole conversation now with opts' strategy. Unlike
+// CompactIfNeeded it keeps no recent turns verbatim, so it must run between
+// turns, never inside one.
+func (c *MessageState) Compact(ctx context.Context, llm domain.LLM, opts domain.CompactionOptions) (domain.CompactionResult, error) {
+	return c.compact(ctx, llm, opts, false, 0)
+}
+
+// compact runs opts' strategy and measures the context either side of it.
+// keepRecent leaves the latest turns intact, as a pass inside a turn must. A
+// micro pass that leaves the context at or above fallbackAt tokens is followed
+// by a summary; 0 never falls back.
+func (c *MessageState) compact(ctx context.Context, llm domain.LLM, opts domain.CompactionOptions, keepRecent bool, fallbackAt int) (domain.CompactionResult, error) {
+	strategy := opts.Strategy
+	if strategy == "" {
+		strategy = domain.CompactionSummary
 	}
-	return true, nil
+	if !slices.Contains(domain.CompactionStrategies, strategy) {
+		return domain.CompactionResult{}, fmt.Errorf("unknown compaction strategy %q", opts.Strategy)
+	}
+
+	counter := TokenCounterFor(llm)
+	res := domain.CompactionResult{Strategy: strategy, BeforeTokens: CountMessageTokens(counter, c.Messages)}
+
+	// Usage the last API call reported describes the history about to change,
+	// so it must not count toward the next check.
+	if usageProvider, ok := llm.(domain.TokenUsageProvider); ok {
+		if usage, ok2 := usageProvider.LastTokenUsage(); ok2 {
+			c.usageAtCompaction = usage.InputTokens
+		}
+	}
+
+	var err error
+	if strategy == domain.CompactionMicro {
+		res.Compacted = c.microCompact(keepRecent) > 0
+		if fallbackAt > 0 && CountMessageTokens(counter, c.Messages) >= fallbackAt {
+			logger.InfoWithIntention(pkgLogger.IntentionStatus, "Clearing old tool results was not enough, summarising")
+			var summarised bool
+			summarised, err = c.performCompaction(ctx, llm, domain.CompactionSummary, opts.Focus, keepRecent)
+			res.Compacted = res.Compacted || summarised
+			res.Strategy = domain.CompactionSummary
+		}
+	} else {
+		res.Compacted, err = c.performCompaction(ctx, llm, strategy, opts.Focus, keepRecent)
+	}
+	res.AfterTokens = CountMessageTokens(counter, c.Messages)
+	return res, err
 }
 
 // GetTotalTokenUsage returns the total token usage across all messages
@@ -255,13 +274,12 @@ func (c *MessageState) GetTotalTokenUsage() (inputTokens, outputTokens, totalTok
 
 // performCompaction contains the compaction logic with boundary-aware summarisation.
 // It finds the last CompactBoundary message (if any), builds on its summary, and
-// replaces all older messages with a single new boundary message.
-func (c *MessageState) performCompaction(ctx context.Context, llm domain.LLM) error {
+// replaces all older messages with a single new boundary message. System
+// prompts in the replaced range are kept ahead of it. It reports false when
+// there was nothing it could safely replace.
+func (c *MessageState) performCompaction(ctx context.Context, llm domain.LLM, strategy domain.CompactionStrategy, focus string, keepRecent bool) (bool, error) {
 	messages := c.Messages
 
-	// Reset counters before compaction to avoid double counting across histories
-	c.ResetTokenCounters()
-
 	// Find the last compact boundary message (search backwards).
 	boundaryIdx := -1
 	var prevSummary string
@@ -295,7 +313,12 @@ func (c *MessageState) performCompaction(ctx context.Context, llm domain.LLM) er
 	// Try to use block-based compaction, but ensure we preserve at least 10 messages for compatibility
 	const minMessagesToPreserve = 10
 
-	if len(blocksToPreserve) > 0 && len(blocksToPreserve) >= minMessagesToPreserve && len(blocksToPreserve) < len(eligibleMessages)-5 {
+	if !keepRecent {
+		if len(eligibleMessages) == 0 {
+			return false, nil
+		}
+		olderMessages = eligibleMessages
+	} else if len(blocksToPreserve) > 0 && len(blocksToPreserve) >= minMessagesToPreserve && len(blocksToPreserve) < len(eligibleMessages)-5 {
 		// Block-based compaction with good number of messages
 		splitIndex := len(eligibleMessages) - len(blocksToPreserve)
 		olderMessages = eligibleMessages[:splitIndex]
@@ -308,7 +331,7 @@ func (c *MessageState) performCompaction(ctx context.Context, llm domain.LLM) er
 		splitPoint := findSafeSplitPoint(eligibleMessages, preserveRecent)
 		if splitPoint <= 0 {
 			logger.DebugWithIntention(pkgLogger.IntentionDebug, "No safe split point found, skipping compaction")
-			return nil
+			return false, nil
 		}
 		olderMessages = eligibleMessages[:splitPoint]
 		recentMessages = eligibleMessages[splitPoint:]
@@ -316,25 +339,45 @@ func (c *MessageState) performCompaction(ctx context.Context, llm domain.LLM) er
 			"total_messages", len(messages), "messages_preserved", len(recentMessages))
 	}
 
+	// System prompts are instructions, not conversation: they are carried over
+	// rather than summarised away, including those an earlier pass kept ahead
+	// of its boundary.
+	keptSystem, olderMessages := splitKeptSystemMessages(
+		append(slices.Clone(messages[:max(boundaryIdx, 0)]), olderMessages...), recentMessages)
+	if len(olderMessages) == 0 {
+		return false, nil
+	}
+
 	// Create an LLM-generated summary, building on the previous boundary summary if available.
 	var summary string
 	var err error
-	if prevSummary != "" {
-		summary, err = c.createLLMSummaryWithContext(ctx, llm, prevSummary, olderMessages)
+	if strategy == domain.CompactionStructured {
+		summary, err = c.createStructuredSummary(ctx, llm, prevSummary, olderMessages, focus)
 	} else {
-		summary, err = c.createLLMSummary(ctx, llm, olderMessages)
+		summary, err = c.createLLMSummary(ctx, llm, prevSummary, olderMessages, focus)
 	}
 	if err != nil {
+		if ctx.Err() != nil {
+			return false, ctx.Err() // abandoned, not failed: leave the history alone
+		}
 		logger.Warn("Failed to create LLM summary, using fallback",
 			"error", err, "message_count", len(olderMessages))
 		summary = createBasicMessageSummary(olderMessages)
+		if strategy == domain.CompactionStructured {
+			summary += "\n\n" + newStructuredFacts(prevSummary, olderMessages).sections()
+		}
 	}
 
 	// Build new state: boundary message + recent messages. Clear only the
 	// in-memory slice — the persisted file must survive until the next
 	// SaveToFile, otherwise a crash between here and that save loses history
 	// (mid-run compaction performs no save of its own).
+	// Reset counters before compaction to avoid double counting across histories
+	c.ResetTokenCounters()
 	c.clearInMemory()
+	for _, msg := range keptSystem {
+		c.AddMessage(msg)
+	}
 
 	// Prepend a compact boundary message (survives CleanupMandatory for future passes)
 	c.AddMessage(message.NewCompactBoundaryMessage(summary))
@@ -365,66 +408,7 @@ func (c *MessageState) performCompaction(ctx context.Context, llm domain.LLM) er
 	logger.InfoWithIntention(pkgLogger.IntentionStatistics, "Token counters updated after compaction",
 		"input_tokens", in, "output_tokens", out, "total_tokens", total)
 
-	return nil
-}
-
-// createLLMSummaryWithContext creates a summary that incorporates a previous summary
-// with newly observed messages, producing a single combined summary.
-func (c *MessageState) createLLMSummaryWithContext(ctx context.Context, llm domain.LLM, prevSummary string, newMessages []message.Message) (string, error) {
-	if len(newMessages) == 0 {
-		// Nothing new to incorporate; return the previous summary unchanged.
-		return prevSummary, nil
-	}
-
-	// Build conversation text for new messages only (same logic as createLLMSummary)
-	var conversationBuilder strings.Builder
-	conversationBuilder.WriteString("Previous summary:\n")
-	conversationBuilder.WriteString(prevSummary)
-	conversationBuilder.WriteString("\n\nNew messages to incorporate:\n\n")
-
-	for _, msg := range newMessages {
-		switch msg.Type() {
-		case message.MessageTypeUser:
-			conversationBuilder.WriteString(fmt.Sprintf("User: %s\n", msg.Content()))
-		case message.MessageTypeAssistant:
-			if len(msg.Content()) > 0 && !strings.HasPrefix(msg.Content(), "Tool call:") {
-				conversationBuilder.WriteString(fmt.Sprintf("Assistant: %s\n", msg.Content()))
-			}
-		case message.MessageTypeToolCall:
-			if toolMsg, ok := msg.(*message.ToolCallMessage); ok {
-				conversationBuilder.WriteString(fmt.Sprintf("Tool used: %s\n", toolMsg.ToolName()))
-			}
-		case message.MessageTypeToolResult:
-			if toolResult, ok := msg.(*message.ToolResultMessage); ok {
-				result := toolResult.Result
-				if len(result) > 200 {
-					result = result[:200] + "..."
-				}
-				if len(msg.Images()) > 0 {
-					conversationBuilder.WriteString(fmt.Sprintf("Tool result: %s [Image data truncated for token efficiency]\n", result))
-				} else {
-					conversationBuilder.WriteString(fmt.Sprintf("Tool result: %s\n", result))
-				}
-			}
-		}
-	}
-
-	summaryPrompt := fmt.Sprintf(`You are updating a running conversation summary. You have a previous summary and new messages. Produce a single combined summary that:
-1. Merges the previous summary with the new messages
-2. Focuses on main topics, key findings, important context, and ongoing tasks
-3. Stays under 200 words
-
-%s
-
-Combined Summary:`, conversationBuilder.String())
-
-	summaryMessage := message.NewChatMessage(message.MessageTypeUser, summaryPrompt)
-	response, err := llm.Chat(ctx, []message.Message{summaryMessage}, false, nil)
-	if err != nil {
-		return "", fmt.Errorf("failed to generate LLM summary with context: %w", err)
-	}
-
-	return response.Content(), nil
+	return true, nil
 }
 
 // findSafeSplitPoint finds a split point that doesn't break tool call chains
@@ -493,69 +477,6 @@ func isSafeSplitPoint(messages []message.Message, splitPoint int) bool {
 	return true
 }
 
-// createLLMSummary creates an intelligent summary using LLM
-func (c *MessageState) createLLMSummary(ctx context.Context, llm domain.LLM, messages []message.Message) (string, error) {
-	if len(messages) == 0 {
-		return "No previous conversation.", nil
-	}
-
-	// Build conversation text for summarization
-	var conversationBuilder strings.Builder
-	conversationBuilder.WriteString("Previous conversation to summarize:\n\n")
-
-	for _, msg := range messages {
-		switch msg.Type() {
-		case message.MessageTypeUser:
-			conversationBuilder.WriteString(fmt.Sprintf("User: %s\n", msg.Content()))
-		case message.MessageTypeAssistant:
-			// Only include actual responses, not tool calls
-			if len(msg.Content()) > 0 && !strings.HasPrefix(msg.Content(), "Tool call:") {
-				conversationBuilder.WriteString(fmt.Sprintf("Assistant: %s\n", msg.Content()))
-			}
-		case message.MessageTypeToolCall:
-			if toolMsg, ok := msg.(*message.ToolCallMessage); ok {
-				conversationBuilder.WriteString(fmt.Sprintf("Tool used: %s\n", toolMsg.ToolName()))
-			}
-		case message.MessageTypeToolResult:
-			if toolResult, ok := msg.(*message.ToolResultMessage); ok {
-				result := toolResult.Result
-				if len(result) > 200 {
-					result = result[:200] + "..."
-				}
-
-				// Drop all images from older messages to save tokens - recent messages keep the latest images
-				if len(msg.Images()) > 0 {
-					conversationBuilder.WriteString(fmt.Sprintf("Tool result: %s [Image data truncated for token efficiency]\n", result))
-				} else {
-					conversationBuilder.WriteString(fmt.Sprintf("Tool result: %s\n", result))
-				}
-			}
-		}
-	}
-
-	// Create summarization prompt
-	summaryPrompt := fmt.Sprintf(`Please create a concise summary of the following conversation. Focus on:
-1. Main topics discussed
-2. Key findings or results
-3. Important context that should be preserved
-4. Any ongoing tasks or decisions
-
-Keep the summary under 200 words and preserve essential context for continuing the conversation.
-
-%s
-
-Summary:`, conversationBuilder.String())
-
-	// Use LLM to create summary
-	summaryMessage := message.NewChatMessage(message.MessageTypeUser, summaryPrompt)
-	response, err := llm.Chat(ctx, []message.Message{summaryMessage}, false, nil) // Summary doesn't need thinking
-	if err != nil {
-		return "", fmt.Errorf("failed to generate LLM summary: %w", err)
-	}
-
-	return response.Content(), nil
-}
-
 // createBasicMessageSummary creates a simple fallback summary of messages
 func createBasicMessageSummary(messages []message.Message) string {
 	if len(messages) == 0 {
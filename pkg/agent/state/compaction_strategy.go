@@ -0,0 +1,318 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/fpt/klein-cli/pkg/agent/domain"
+	pkgLogger "github.com/fpt/klein-cli/pkg/logger"
+	"github.com/fpt/klein-cli/pkg/message"
+)
+
+// The micro strategy clears every tool result longer than the stub replacing
+// it, leaving the turn in progress and the one before it alone when it runs
+// inside a turn.
+const (
+	microStrategyMinChars = 200
+	microKeepTurns        = 2
+)
+
+// microCompact clears old tool results in place and returns how many it
+// cleared. Without keepRecent every turn is old.
+func (c *MessageState) microCompact(keepRecent bool) int {
+	end := len(c.Messages)
+	if keepRecent {
+		end = findTurnBoundary(c.Messages, microKeepTurns)
+	}
+	cleared := 0
+	for i := range end {
+		if cleaned, ok := applyLightweightCleanup(c.Messages[i], microStrategyMinChars); ok {
+			c.Messages[i] = cleaned
+			cleared++
+		}
+	}
+	logger.InfoWithIntention(pkgLogger.IntentionStatus, "Micro-compaction cleared old tool results",
+		"cleared", cleared, "boundary", end)
+	return cleared
+}
+
+// splitKeptSystemMessages separates the system prompts a compaction carries
+// over from the messages it summarises. Situation and summary messages are not
+// carried (they are regenerated each turn), nor is a prompt that a later one
+// supersedes: Invoke re-injects a changed prompt under the same first-line
+// marker, so only the latest of each survives.
+func splitKeptSystemMessages(older, recent []message.Message) (kept, rest []message.Message) {
+	seen := map[string]bool{}
+	for _, msg := range recent {
+		if msg.Type() == message.MessageTypeSystem {
+			seen[firstLine(msg.Content())] = true
+		}
+	}
+	keep := make([]bool, len(older))
+	for i := len(older) - 1; i >= 0; i-- {
+		msg := older[i]
+		if msg.Type() != message.MessageTypeSystem || msg.Source() != message.MessageSourceDefault {
+			continue
+		}
+		key := firstLine(msg.Content())
+		keep[i] = !seen[key]
+		seen[key] = true
+	}
+	for i, msg := range older {
+		if keep[i] {
+			kept = append(kept, msg)
+		} else if msg.Type() != message.MessageTypeSystem || msg.Source() != message.MessageSourceDefault {
+			rest = append(rest, msg)
+		}
+	}
+	return kept, rest
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
+
+// conversationTranscript renders messages as the plain transcript the
+// summariser reads: what was said, which tools ran, and the start of what
+// they returned.
+func conversationTranscript(messages []message.Message) string {
+	var b strings.Builder
+	for _, msg := range messages {
+		switch msg.Type() {
+		case message.MessageTypeUser:
+			fmt.Fprintf(&b, "User: %s\n", msg.Content())
+		case message.MessageTypeAssistant:
+			// Only include actual responses, not tool calls
+			if len(msg.Content()) > 0 && !strings.HasPrefix(msg.Content(), "Tool call:") {
+				fmt.Fprintf(&b, "Assistant: %s\n", msg.Content())
+			}
+		case message.MessageTypeToolCall:
+			if toolMsg, ok := msg.(*message.ToolCallMessage); ok {
+				fmt.Fprintf(&b, "Tool used: %s\n", toolMsg.ToolName())
+			}
+		case message.MessageTypeToolResult:
+			if toolResult, ok := msg.(*message.ToolResultMessage); ok {
+				result := toolResult.Result
+				if len(result) > 200 {
+					result = result[:200] + "..."
+				}
+				// Images are dropped from the summary; recent messages keep theirs.
+				if len(msg.Images()) > 0 {
+					fmt.Fprintf(&b, "Tool result: %s [Image data truncated for token efficiency]\n", result)
+				} else {
+					fmt.Fprintf(&b, "Tool result: %s\n", result)
+				}
+			}
+		}
+	}
+	return b.String()
+}
+
+// focusInstruction is the prompt line for a user's /compact focus.
+func focusInstruction(focus string) string {
+	if focus = strings.TrimSpace(focus); focus == "" {
+		return ""
+	}
+	return fmt.Sprintf("\nThe user asked that the summary focus on: %s\nGive that the most detail and keep everything it depends on.\n", focus)
+}
+
+// createLLMSummary summarises messages in prose, folding in the previous
+// boundary's summary when there is one.
+func (c *MessageState) createLLMSummary(ctx context.Context, llm domain.LLM, prevSummary string, messages []message.Message, focus string) (string, error) {
+	if len(messages) == 0 {
+		if prevSummary != "" {
+			// Nothing new to incorporate; return the previous summary unchanged.
+			return prevSummary, nil
+		}
+		return "No previous conversation.", nil
+	}
+
+	var prompt string
+	if prevSummary != "" {
+		prompt = fmt.Sprintf(`You are updating a running conversation summary. You have a previous summary and new messages. Produce a single combined summary that:
+1. Merges the previous summary with the new messages
+2. Focuses on main topics, key findings, important context, and ongoing tasks
+3. Stays under 200 words
+%s
+Previous summary:
+%s
+
+New messages to incorporate:
+
+%s
+Combined Summary:`, focusInstruction(focus), prevSummary, conversationTranscript(messages))
+	} else {
+		prompt = fmt.Sprintf(`Please create a concise summary of the following conversation. Focus on:
+1. Main topics discussed
+2. Key findings or results
+3. Important context that should be preserved
+4. Any ongoing tasks or decisions
+
+Keep the summary under 200 words and preserve essential context for continuing the conversation.
+%s
+Previous conversation to summarize:
+
+%s
+Summary:`, focusInstruction(focus), conversationTranscript(messages))
+	}
+
+	// Summary doesn't need thinking
+	response, err := llm.Chat(ctx, []message.Message{message.NewChatMessage(message.MessageTypeUser, prompt)}, false, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate LLM summary: %w", err)
+	}
+	return response.Content(), nil
+}
+
+// Headings of the structured summary's mechanically kept sections.
+const (
+	todosHeading = "## Open todos"
+	filesHeading = "## Files"
+)
+
+// createStructuredSummary summarises messages under fixed headings. The open
+// todos and the files touched are also gathered from the tool calls, and a
+// section the model leaves out is appended from them, so neither is lost to
+// a summariser that skims.
+func (c *MessageState) createStructuredSummary(ctx context.Context, llm domain.LLM, prevSummary string, messages []message.Message, focus string) (string, error) {
+	facts := newStructuredFacts(prevSummary, messages)
+	previous := ""
+	if prevSummary != "" {
+		previous = "Summary of the conversation before these messages, to merge in:\n" + prevSummary + "\n\n"
+	}
+	prompt := fmt.Sprintf(`Summarise the conversation below for an agent that will carry on the work without seeing it. Use exactly these Markdown headings, in this order:
+
+## Goal
+What the user is trying to achieve.
+## Decisions
+Each decision made and the reason for it, one bullet each.
+%s
+Work agreed but not finished, one bullet each.
+%s
+Every file read, created or changed, one bullet each with what happened to it.
+## Context
+Findings, errors and constraints needed to continue.
+
+Keep it under 400 words. Drop nothing listed under "Known so far".
+%s
+Known so far:
+%s
+
+%sConversation:
+
+%s
+Summary:`, todosHeading, filesHeading, focusInstruction(focus), facts.sections(), previous, conversationTranscript(messages))
+
+	response, err := llm.Chat(ctx, []message.Message{message.NewChatMessage(message.MessageTypeUser, prompt)}, false, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate structured summary: %w", err)
+	}
+	summary := strings.TrimSpace(response.Content())
+	if !strings.Contains(summary, todosHeading) {
+		summary += "\n\n" + facts.section(todosHeading, facts.todos)
+	}
+	if !strings.Contains(summary, filesHeading) {
+		summary += "\n\n" + facts.section(filesHeading, facts.files)
+	}
+	return summary, nil
+}
+
+// structuredFacts is what the structured strategy keeps without trusting the
+// summariser: the open items of the latest todo list and every file a tool
+// was pointed at, carried forward from the previous summary's sections.
+type structuredFacts struct {
+	todos []string
+	files []string
+}
+
+// pathArguments are the tool arguments that name a file.
+var pathArguments = []string{"file_path", "path", "notebook_path"}
+
+func newStructuredFacts(prevSummary string, messages []message.Message) structuredFacts {
+	f := structuredFacts{
+		todos: sectionBullets(prevSummary, todosHeading),
+		files: sectionBullets(prevSummary, filesHeading),
+	}
+	for _, msg := range messages {
+		call, ok := msg.(*message.ToolCallMessage)
+		if !ok {
+			continue
+		}
+		args := call.ToolArguments()
+		if call.ToolName() == "TodoWrite" {
+			if todos, ok := openTodos(args["todos"]); ok {
+				f.todos = todos
+			}
+			continue
+		}
+		for _, key := range pathArguments {
+			path, _ := args[key].(string)
+			if path == "" || slices.ContainsFunc(f.files, func(b string) bool { return strings.Contains(b, path) }) {
+				continue
+			}
+			f.files = append(f.files, "- "+path)
+		}
+	}
+	return f
+}
+
+// openTodos renders the unfinished items of a TodoWrite "todos" argument,
+// which arrives as a JSON string or as decoded objects.
+func openTodos(arg any) ([]string, bool) {
+	var items []map[string]any
+	switch v := arg.(type) {
+	case string:
+		if err := json.Unmarshal([]byte(v), &items); err != nil {
+			return nil, false
+		}
+	case []any:
+		for _, it := range v {
+			if m, ok := it.(map[string]any); ok {
+				items = append(items, m)
+			}
+		}
+	default:
+		return nil, false
+	}
+	var open []string
+	for _, it := range items {
+		status, _ := it["status"].(string)
+		content, _ := it["content"].(string)
+		if content == "" || status == "completed" || status == "done" {
+			continue
+		}
+		open = append(open, fmt.Sprintf("- [%s] %s", status, content))
+	}
+	return open, true
+}
+
+func (f structuredFacts) sections() string {
+	return f.section(todosHeading, f.todos) + "\n" + f.section(filesHeading, f.files)
+}
+
+func (f structuredFacts) section(heading string, bullets []string) string {
+	if len(bullets) == 0 {
+		return heading + "\n- (none)\n"
+	}
+	return heading + "\n" + strings.Join(bullets, "\n") + "\n"
+}
+
+// sectionBullets returns the bullet lines under heading in summary.
+func sectionBullets(summary, heading string) []string {
+	var bullets []string
+	in := false
+	for _, line := range strings.Split(summary, "\n") {
+		line = strings.TrimRight(line, " \t")
+		switch {
+		case strings.HasPrefix(line, "## "):
+			in = line == heading
+		case in && strings.HasPrefix(line, "- ") && line != "- (none)":
+			bullets = append(bullets, line)
+		}
+	}
+	return bullets
+}
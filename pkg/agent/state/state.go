@@ -17,6 +17,10 @@ type MessageState struct {
 	tokenInput  int
 	tokenOutput int
 	tokenTotal  int
+
+	// usageAtCompaction is the input usage last reported before the latest
+	// compaction; it measured the history compaction replaced.
+	usageAtCompaction int
 }
 
 // NewMessageState creates a new message state (in-memory only)
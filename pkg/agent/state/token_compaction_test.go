@@ -6,6 +6,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/fpt/klein-cli/pkg/agent/domain"
 	"github.com/fpt/klein-cli/pkg/message"
 )
 
@@ -112,7 +113,7 @@ func TestCompactIfNeeded_BelowThreshold(t *testing.T) {
 
 	// Test with 10000 max tokens and 70% threshold (7000 tokens)
 	// 1500 tokens is below threshold, should not compact
-	_, err := state.CompactIfNeeded(context.Background(), mockLLM, 10000, 70.0)
+	_, err := state.CompactIfNeeded(context.Background(), mockLLM, 10000, 70.0, domain.CompactionOptions{})
 	if err != nil {
 		t.Errorf("CompactIfNeeded failed: %v", err)
 	}
@@ -134,15 +135,14 @@ func TestCompactIfNeeded_AboveThreshold(t *testing.T) {
 
 	// Add many messages to trigger compaction by message count
 	for i := 0; i < 60; i++ {
-		msg := message.NewChatMessage(message.MessageTypeUser, "Test message")
-		msg.SetTokenUsage(200, 100, 300) // Each message uses 300 tokens
-		state.AddMessage(msg)
+		msg := message.NewChatMessage(message.MessageTypeUser, strings.Repeat("word ", 300))
+		state.AddMessage(msg) // Each message counts about 300 tokens
 	}
 
-	// Total: 60 * 300 = 18000 tokens
+	// Total: about 60 * 300 = 18000 tokens
 	// Test with 20000 max tokens and 70% threshold (14000 tokens)
 	// 18000 tokens is above threshold, should compact
-	_, err := state.CompactIfNeeded(context.Background(), mockLLM, 20000, 70.0)
+	_, err := state.CompactIfNeeded(context.Background(), mockLLM, 20000, 70.0, domain.CompactionOptions{})
 	if err != nil {
 		t.Errorf("CompactIfNeeded failed: %v", err)
 	}
@@ -173,7 +173,7 @@ func TestCompactIfNeeded_NoMaxTokens(t *testing.T) {
 	initialCount := len(state.GetMessages())
 
 	// Test with 0 max tokens (no limit specified)
-	_, err := state.CompactIfNeeded(context.Background(), mockLLM, 0, 70.0)
+	_, err := state.CompactIfNeeded(context.Background(), mockLLM, 0, 70.0, domain.CompactionOptions{})
 	if err != nil {
 		t.Errorf("CompactIfNeeded failed: %v", err)
 	}
@@ -308,3 +308,181 @@ func TestMicroCompaction_SmallResultsUntouched(t *testing.T) {
 		}
 	}
 }
+
+// usageLLM reports a fixed input usage for its last call.
+type usageLLM struct {
+	mockLLM
+	input int
+}
+
+func (u *usageLLM) LastTokenUsage() (message.TokenUsage, bool) {
+	return message.TokenUsage{InputTokens: u.input}, u.input > 0
+}
+
+func TestGetAccurateTokenCount(t *testing.T) {
+	state := NewMessageState()
+	state.AddMessage(message.NewChatMessage(message.MessageTypeUser, strings.Repeat("word ", 1000)))
+	state.AddMessage(message.NewToolCallMessage("Read", message.ToolArgumentValues{"path": "main.go"}))
+
+	counted := state.getAccurateTokenCount(&mockLLM{})
+	if counted < 900 || counted > 1200 {
+		t.Fatalf("counted %d tokens for ~1000 words", counted)
+	}
+	// Reported usage wins when larger: it also covers tool schemas.
+	llm := &usageLLM{input: 5000}
+	if got := state.getAccurateTokenCount(llm); got != 5000 {
+		t.Errorf("with usage 5000: got %d", got)
+	}
+	// After a compaction the same report describes the old history.
+	if _, err := state.Compact(context.Background(), llm, domain.CompactionOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := state.getAccurateTokenCount(llm); got == 5000 {
+		t.Error("usage from before the compaction was used again")
+	}
+}
+
+// longConversation adds turns user turns of about 300 tokens each, every one
+// with a Read call and a large result.
+func longConversation(st *MessageState, turns int) {
+	for i := range turns {
+		st.AddMessage(message.NewChatMessage(message.MessageTypeUser, "request "+strconv.Itoa(i)+" "+strings.Repeat("word ", 300)))
+		st.AddMessage(message.NewToolCallMessage("Read", message.ToolArgumentValues{"file_path": "pkg/f" + strconv.Itoa(i) + ".go"}))
+		st.AddMessage(message.NewToolResultMessage("", strings.Repeat("line of code\n", 100), ""))
+		st.AddMessage(message.NewChatMessage(message.MessageTypeAssistant, "done "+strconv.Itoa(i)))
+	}
+}
+
+func TestCompactIfNeeded_HonorsThreshold(t *testing.T) {
+	st := NewMessageState()
+	longConversation(st, 20) // roughly 10k tokens
+	llm := &mockLLM{}
+
+	// 40k at the default 70% triggers at 28k: nothing to do.
+	res, err := st.CompactIfNeeded(context.Background(), llm, 40000, 0, domain.CompactionOptions{})
+	if err != nil || res.Compacted {
+		t.Fatalf("default threshold: %+v, %v", res, err)
+	}
+	// At 20% it triggers at 8k.
+	res, err = st.CompactIfNeeded(context.Background(), llm, 40000, 20, domain.CompactionOptions{})
+	if err != nil || !res.Compacted {
+		t.Fatalf("20%% threshold: %+v, %v", res, err)
+	}
+	if res.Strategy != domain.CompactionSummary || res.AfterTokens >= res.BeforeTokens {
+		t.Errorf("result = %+v", res)
+	}
+}
+
+func TestCompact_KeepsSystemPrompts(t *testing.T) {
+	st := NewMessageState()
+	st.AddMessage(message.NewSystemMessage("[[SKILL_PROMPT:code]]\nold prompt"))
+	st.AddMessage(message.NewSystemMessage("[[SKILL_PROMPT:code]]\nnew prompt"))
+	st.AddMessage(message.NewSystemMessage("[[MEMORY_SYSTEM]]\nmemory"))
+	st.AddMessage(message.NewSituationSystemMessage("iteration 3 of 30"))
+	longConversation(st, 3)
+
+	var prompt string
+	llm := &mockLLM{chatFunc: func(_ context.Context, msgs []message.Message) (message.Message, error) {
+		prompt = msgs[0].Content()
+		return message.NewChatMessage(message.MessageTypeAssistant, "the summary"), nil
+	}}
+	res, err := st.Compact(context.Background(), llm, domain.CompactionOptions{Focus: "the parser rewrite"})
+	if err != nil || !res.Compacted {
+		t.Fatalf("Compact: %+v, %v", res, err)
+	}
+	if !strings.Contains(prompt, "focus on: the parser rewrite") {
+		t.Errorf("focus missing from the prompt:\n%s", prompt)
+	}
+	var got []string
+	for _, m := range st.GetMessages() {
+		got = append(got, firstLine(m.Content()))
+	}
+	want := []string{"[[SKILL_PROMPT:code]]", "[[MEMORY_SYSTEM]]", "# Conversation Summary"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("after compaction: %q, want %q", got, want)
+	}
+	if !strings.Contains(st.GetMessages()[0].Content(), "new prompt") {
+		t.Error("the superseded prompt was kept instead of the latest")
+	}
+
+	// A second pass keeps them too, and with only prompts left there is
+	// nothing to do.
+	longConversation(st, 1)
+	if res, err := st.Compact(context.Background(), llm, domain.CompactionOptions{}); err != nil || !res.Compacted {
+		t.Fatalf("second Compact: %+v, %v", res, err)
+	}
+	if n := len(st.GetMessages()); n != 3 || firstLine(st.GetMessages()[1].Content()) != "[[MEMORY_SYSTEM]]" {
+		t.Errorf("after the second pass: %d messages, %q", n, st.GetMessages()[1].Content())
+	}
+	if res, _ := st.Compact(context.Background(), llm, domain.CompactionOptions{}); res.Compacted {
+		t.Error("compacted a conversation with nothing but its prompts")
+	}
+}
+
+func TestCompact_Micro(t *testing.T) {
+	st := NewMessageState()
+	longConversation(st, 4)
+	llm := &mockLLM{chatFunc: func(context.Context, []message.Message) (message.Message, error) {
+		t.Error("micro compaction called the model")
+		return nil, nil
+	}}
+	res, err := st.Compact(context.Background(), llm, domain.CompactionOptions{Strategy: domain.CompactionMicro})
+	if err != nil || !res.Compacted || res.AfterTokens >= res.BeforeTokens {
+		t.Fatalf("Compact: %+v, %v", res, err)
+	}
+	msgs := st.GetMessages()
+	if len(msgs) != 16 {
+		t.Fatalf("micro compaction removed messages: %d left", len(msgs))
+	}
+	for _, m := range msgs {
+		if tr, ok := m.(*message.ToolResultMessage); ok && !strings.HasPrefix(tr.Result, "[Content cleared") {
+			t.Errorf("tool result kept: %.40s", tr.Result)
+		}
+	}
+
+	// Inside a turn the latest two turns are left alone.
+	st = NewMessageState()
+	longConversation(st, 4)
+	st.microCompact(true)
+	for i, m := range st.GetMessages() {
+		tr, ok := m.(*message.ToolResultMessage)
+		if !ok {
+			continue
+		}
+		if cleared := strings.HasPrefix(tr.Result, "[Content cleared"); cleared != (i < 8) {
+			t.Errorf("message %d cleared = %v", i, cleared)
+		}
+	}
+}
+
+func TestCompact_Structured(t *testing.T) {
+	st := NewMessageState()
+	st.AddMessage(message.NewCompactBoundaryMessage("## Goal\nship it\n## Files\n- README.md: rewritten\n"))
+	longConversation(st, 2)
+	st.AddMessage(message.NewToolCallMessage("TodoWrite", message.ToolArgumentValues{"todos": []any{
+		map[string]any{"content": "write tests", "status": "pending"},
+		map[string]any{"content": "fix build", "status": "completed"},
+	}}))
+	st.AddMessage(message.NewToolResultMessage("", "Successfully updated todo list", ""))
+
+	// A summariser that ignores the requested sections.
+	llm := &mockLLM{chatFunc: func(context.Context, []message.Message) (message.Message, error) {
+		return message.NewChatMessage(message.MessageTypeAssistant, "## Goal\nship it"), nil
+	}}
+	if _, err := st.Compact(context.Background(), llm, domain.CompactionOptions{Strategy: domain.CompactionStructured}); err != nil {
+		t.Fatal(err)
+	}
+	summary := st.GetMessages()[0].Content()
+	for _, want := range []string{"- [pending] write tests", "- README.md: rewritten", "- pkg/f0.go", "- pkg/f1.go"} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("summary lacks %q:\n%s", want, summary)
+		}
+	}
+	if strings.Contains(summary, "fix build") {
+		t.Errorf("a completed todo was kept:\n%s", summary)
+	}
+
+	if _, err := st.Compact(context.Background(), llm, domain.CompactionOptions{Strategy: "nonsense"}); err == nil {
+		t.Error("an unknown strategy was accepted")
+	}
+}
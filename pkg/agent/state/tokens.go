@@ -0,0 +1,50 @@
+package state
+
+import (
+	"encoding/json"
+
+	"github.com/fpt/klein-cli/pkg/agent/domain"
+	"github.com/fpt/klein-cli/pkg/message"
+	"github.com/fpt/klein-cli/pkg/tokenizer"
+)
+
+// Per-message costs the text alone does not show: the role and framing tokens
+// every backend wraps a message in, and an image at the size most backends
+// bill a typical screenshot.
+const (
+	messageOverheadTokens = 4
+	imageTokens           = 1600
+)
+
+// TokenCounterFor returns the counter for llm's model: the client's own when
+// it counts, else the tokenizer chosen by its model id.
+func TokenCounterFor(llm domain.LLM) domain.TokenCounter {
+	if tc, ok := llm.(domain.TokenCounter); ok {
+		return tc
+	}
+	model := ""
+	if llm != nil {
+		model = llm.ModelID()
+	}
+	return tokenizer.ForModel(model)
+}
+
+// MessageTokens counts the tokens msg occupies in the context window: its
+// text, thinking and images, or a tool call's name and arguments.
+func MessageTokens(counter domain.TokenCounter, msg message.Message) int {
+	n := messageOverheadTokens + counter.CountTokens(msg.Thinking()) + len(msg.Images())*imageTokens
+	if call, ok := msg.(*message.ToolCallMessage); ok {
+		args, _ := json.Marshal(call.ToolArguments())
+		return n + counter.CountTokens(string(call.ToolName())) + counter.CountTokens(string(args))
+	}
+	return n + counter.CountTokens(msg.Content())
+}
+
+// CountMessageTokens counts the tokens of every message.
+func CountMessageTokens(counter domain.TokenCounter, msgs []message.Message) int {
+	total := 0
+	for _, msg := range msgs {
+		total += MessageTokens(counter, msg)
+	}
+	return total
+}
@@ -0,0 +1,80 @@
+// Package tracing names the OpenTelemetry spans the agent emits. Spans are
+// created through the global tracer provider, which is a no-op until the
+// application installs one (see internal/telemetry), so instrumented code
+// pays nothing when tracing is off.
+//
+// A turn nests as:
+//
+//	agent.invoke
+//	└─ react.iteration
+//	   ├─ llm.chat
+//	   └─ tool.call
+//	      └─ agent.subagent   (Task)
+//	         └─ react.iteration ...
+package tracing
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/fpt/klein-cli/pkg/agent"
+
+// Span names.
+const (
+	SpanInvoke    = "agent.invoke"
+	SpanSubagent  = "agent.subagent"
+	SpanIteration = "react.iteration"
+	SpanLLM       = "llm.chat"
+	SpanTool      = "tool.call"
+)
+
+// Attribute keys. LLM and tool attributes use the OpenTelemetry GenAI
+// semantic convention names where one exists.
+const (
+	AttrSession    = attribute.Key("klein.session.id")
+	AttrSkill      = attribute.Key("klein.skill")
+	AttrAgent      = attribute.Key("klein.agent")
+	AttrIteration  = attribute.Key("klein.iteration")
+	AttrBackground = attribute.Key("klein.background")
+	AttrCancelled  = attribute.Key("klein.cancelled")
+	AttrWaiting    = attribute.Key("klein.waiting_for_approval")
+
+	AttrModel               = attribute.Key("gen_ai.request.model")
+	AttrInputTokens         = attribute.Key("gen_ai.usage.input_tokens")
+	AttrOutputTokens        = attribute.Key("gen_ai.usage.output_tokens")
+	AttrCachedTokens        = attribute.Key("gen_ai.usage.cache_read.input_tokens")
+	AttrCacheCreationTokens = attribute.Key("gen_ai.usage.cache_creation.input_tokens")
+	AttrMessages            = attribute.Key("klein.llm.messages")
+	AttrResponseType        = attribute.Key("klein.llm.response")
+
+	AttrToolName    = attribute.Key("gen_ai.tool.name")
+	AttrToolCallID  = attribute.Key("gen_ai.tool.call.id")
+	AttrArgsBytes   = attribute.Key("klein.tool.args_bytes")
+	AttrResultBytes = attribute.Key("klein.tool.result_bytes")
+	AttrOffloaded   = attribute.Key("klein.tool.offloaded")
+)
+
+// Start starts a span under whatever span ctx carries.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End ends span, marking it failed when err is set. A cancelled context is
+// recorded as such rather than as a failure.
+func End(span trace.Span, err error) {
+	switch {
+	case err == nil:
+	case errors.Is(err, context.Canceled):
+		span.SetAttributes(AttrCancelled.Bool(true))
+	default:
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
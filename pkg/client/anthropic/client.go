@@ -11,6 +11,7 @@ import (
 	"github.com/anthropics/anthropic-sdk-go/option"
 	"github.com/fpt/klein-cli/pkg/agent/domain"
 	"github.com/fpt/klein-cli/pkg/message"
+	"github.com/fpt/klein-cli/pkg/tokenizer"
 )
 
 const (
@@ -35,15 +36,17 @@ func NewAnthropicCore(model string) (*AnthropicCore, error) {
 	return NewAnthropicCoreWithTokens(model, 0) // 0 = use default
 }
 
-// NewAnthropicCoreWithTokens creates a new Anthropic core with configurable maxTokens
-func NewAnthropicCoreWithTokens(model string, maxTokens int) (*AnthropicCore, error) {
+// NewAnthropicCoreWithTokens creates a new Anthropic core with configurable
+// maxTokens. opts are applied after the API key, e.g. a base URL or a retry
+// count.
+func NewAnthropicCoreWithTokens(model string, maxTokens int, opts ...option.RequestOption) (*AnthropicCore, error) {
 	apiKey := os.Getenv("ANTHROPIC_API_KEY")
 	if apiKey == "" {
 		return nil, fmt.Errorf("ANTHROPIC_API_KEY environment variable not set")
 	}
 
 	client := anthropic.NewClient(
-		option.WithAPIKey(apiKey),
+		append([]option.RequestOption{option.WithAPIKey(apiKey)}, opts...)...,
 	)
 
 	// Use default if maxTokens is 0 or negative
@@ -75,9 +78,10 @@ func NewAnthropicClient(model string) (domain.ToolCallingLLM, error) {
 	return NewAnthropicClientWithTokens(model, 0) // 0 = use default
 }
 
-// NewAnthropicClientWithTokens creates a new Anthropic client with configurable maxTokens
-func NewAnthropicClientWithTokens(model string, maxTokens int) (domain.ToolCallingLLM, error) {
-	core, err := NewAnthropicCoreWithTokens(model, maxTokens)
+// NewAnthropicClientWithTokens creates a new Anthropic client with configurable
+// maxTokens and optional request options (see NewAnthropicCoreWithTokens).
+func NewAnthropicClientWithTokens(model string, maxTokens int, opts ...option.RequestOption) (domain.ToolCallingLLM, error) {
+	core, err := NewAnthropicCoreWithTokens(model, maxTokens, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -103,6 +107,11 @@ func (c *AnthropicClient) MaxContextTokens() int {
 	return getModelContextWindow(c.model)
 }
 
+// TokenCounter implementation
+func (c *AnthropicClient) CountTokens(text string) int {
+	return tokenizer.ForModel(c.model).CountTokens(text)
+}
+
 // TokenUsageProvider implementation (populated from Message.Usage when available)
 func (c *AnthropicClient) LastTokenUsage() (message.TokenUsage, bool) {
 	if c.lastUsage.InputTokens != 0 || c.lastUsage.OutputTokens != 0 || c.lastUsage.TotalTokens != 0 {
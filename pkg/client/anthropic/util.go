@@ -281,6 +281,21 @@ func convertArgumentToAnthropicProperty(arg message.ToolArgument) map[string]any
 	return property
 }
 
+// signedThinkingBlock replays msg's thinking as a thinking block. Only
+// thinking Anthropic produced and signed can be sent back: the API rejects a
+// block without its signature, so thinking from another backend (after a
+// fallback, or an imported session) is left out.
+func signedThinkingBlock(msg message.Message) (anthropic.ContentBlockParamUnion, bool) {
+	thinking := msg.Thinking()
+	signature, _ := msg.Metadata()["anthropic_thinking_signature"].(string)
+	if thinking == "" || signature == "" {
+		return anthropic.ContentBlockParamUnion{}, false
+	}
+	return anthropic.ContentBlockParamUnion{
+		OfThinking: &anthropic.ThinkingBlockParam{Thinking: thinking, Signature: signature},
+	}, true
+}
+
 // toAnthropicMessages converts neutral messages to Anthropic format
 func toAnthropicMessages(messages []message.Message) []anthropic.MessageParam {
 	var anthropicMessages []anthropic.MessageParam
@@ -322,16 +337,7 @@ func toAnthropicMessages(messages []message.Message) []anthropic.MessageParam {
 			var contentBlocks []anthropic.ContentBlockParamUnion
 
 			// Add thinking block first if present (required by Anthropic when thinking is enabled)
-			if thinking := msg.Thinking(); thinking != "" {
-				thinkingBlockParam := &anthropic.ThinkingBlockParam{
-					Thinking: thinking,
-				}
-				if signature, ok := msg.Metadata()["anthropic_thinking_signature"].(string); ok && signature != "" {
-					thinkingBlockParam.Signature = signature
-				}
-				thinkingBlock := anthropic.ContentBlockParamUnion{
-					OfThinking: thinkingBlockParam,
-				}
+			if thinkingBlock, ok := signedThinkingBlock(msg); ok {
 				contentBlocks = append(contentBlocks, thinkingBlock)
 			}
 
@@ -358,19 +364,7 @@ func toAnthropicMessages(messages []message.Message) []anthropic.MessageParam {
 				var contentBlocks []anthropic.ContentBlockParamUnion
 
 				// Add thinking block only if we have actual thinking content
-				if thinkingContent := msg.Thinking(); thinkingContent != "" {
-					thinkingBlockParam := &anthropic.ThinkingBlockParam{
-						Thinking: thinkingContent,
-					}
-
-					// Check if we have a preserved signature from streaming
-					if signature, hasSignature := msg.Metadata()["anthropic_thinking_signature"].(string); hasSignature && signature != "" {
-						thinkingBlockParam.Signature = signature
-					}
-
-					thinkingBlock := anthropic.ContentBlockParamUnion{
-						OfThinking: thinkingBlockParam,
-					}
+				if thinkingBlock, ok := signedThinkingBlock(msg); ok {
 					contentBlocks = append(contentBlocks, thinkingBlock)
 				}
 
@@ -3,6 +3,10 @@ package client
 import (
 	"fmt"
 
+	anthropicoption "github.com/anthropics/anthropic-sdk-go/option"
+	openaioption "github.com/openai/openai-go/v3/option"
+	"google.golang.org/genai"
+
 	"github.com/fpt/klein-cli/internal/config"
 	"github.com/fpt/klein-cli/pkg/agent/domain"
 	"github.com/fpt/klein-cli/pkg/client/anthropic"
@@ -12,19 +16,63 @@ import (
 
 // NewLLMClient creates an LLM client based on settings. openai is the default:
 // unknown backends are rejected by config.ValidateSettings before reaching here.
+//
+// With [llm.retry] or [[llm.fallback]] configured the result is a
+// FallbackClient over the primary and its fallbacks, which then owns retries:
+// the SDKs' own are turned off so one policy decides how long a call may take
+// before the next backend gets it.
 func NewLLMClient(settings config.LLMSettings) (domain.LLM, error) {
+	if !settings.UsesFallbackChain() || config.IsAgentServerBackend(settings.Backend) {
+		return newBackendClient(settings, false)
+	}
+	attempts, backoff, maxBackoff, err := settings.Retry.Policy()
+	if err != nil {
+		return nil, err
+	}
+	chain := append([]config.LLMSettings{settings}, settings.Fallback...)
+	backends := make([]domain.LLM, 0, len(chain))
+	for _, s := range chain {
+		c, err := newBackendClient(s, true)
+		if err != nil {
+			return nil, fmt.Errorf("%s fallback chain: %s %s: %w", settings.Backend, s.Backend, s.Model, err)
+		}
+		backends = append(backends, c)
+	}
+	policy := RetryPolicy{MaxAttempts: attempts, Backoff: backoff, MaxBackoff: maxBackoff}
+	return NewFallbackClient(policy, backends...), nil
+}
+
+// newBackendClient creates the client for one backend. base_url applies to
+// every chat backend; noSDKRetries leaves retrying to a FallbackClient.
+func newBackendClient(settings config.LLMSettings, noSDKRetries bool) (domain.LLM, error) {
 	switch settings.Backend {
 	case "anthropic", "claude":
-		return anthropic.NewAnthropicClientWithTokens(settings.Model, settings.MaxTokens)
+		var opts []anthropicoption.RequestOption
+		if settings.BaseURL != "" {
+			opts = append(opts, anthropicoption.WithBaseURL(settings.BaseURL))
+		}
+		if noSDKRetries {
+			opts = append(opts, anthropicoption.WithMaxRetries(0))
+		}
+		return anthropic.NewAnthropicClientWithTokens(settings.Model, settings.MaxTokens, opts...)
 	case "gemini":
-		return gemini.NewGeminiClientWithTokens(settings.Model, settings.MaxTokens)
+		// The Gemini SDK does not retry unless asked to.
+		return gemini.NewGeminiClientWithHTTPOptions(settings.Model, settings.MaxTokens, settings.Effort,
+			genai.HTTPOptions{BaseURL: settings.BaseURL})
 	case "codex", "appserver":
 		// These are whole-agent backends routed via internal/agentbackend; this
 		// stub only satisfies domain.LLM for agent construction (Chat is never
 		// called).
 		return &agentStubLLM{backend: settings.Backend, model: settings.Model}, nil
 	default:
-		c, err := openai.NewOpenAIClient(settings.Model, settings.MaxTokens, settings.Effort)
+		var opts []openaioption.RequestOption
+		if settings.BaseURL != "" {
+			opts = append(opts, openaioption.WithBaseURL(settings.BaseURL))
+		}
+		if noSDKRetries {
+			opts = append(opts, openaioption.WithMaxRetries(0))
+		}
+		c, err := openai.NewOpenAIClient(settings.Model, settings.MaxTokens, settings.Effort, opts...)
 		if err != nil {
 			return nil, fmt.Errorf("create openai client: %w", err)
 		}
@@ -53,6 +101,17 @@ func NewClientWithToolManager(client domain.LLM, toolManager domain.ToolManager)
 		toolClient := gemini.NewGeminiClientFromCore(c.GeminiCore)
 		toolClient.SetToolManager(toolManager)
 		return toolClient, nil
+	case *FallbackClient:
+		// Wrap each backend the same way, so every one of them sees the tools.
+		backends := make([]domain.LLM, len(c.backends))
+		for i, b := range c.backends {
+			toolClient, err := NewClientWithToolManager(b, toolManager)
+			if err != nil {
+				return nil, err
+			}
+			backends[i] = toolClient
+		}
+		return c.withBackends(backends), nil
 	}
 
 	// Fallback: an unknown client that already supports tool calling. We cannot
@@ -84,6 +143,10 @@ func NewStructuredClient[T any](client domain.LLM) (domain.StructuredLLM[T], err
 	case *gemini.GeminiClient:
 		// For Gemini, use native structured output with ResponseMIMEType and ResponseSchema
 		return gemini.NewGeminiStructuredClient[T](c.GeminiCore), nil
+	case *FallbackClient:
+		// The "respond" tool works on every backend, so a fallback keeps the
+		// structured contract.
+		return NewToolCallingStructuredClient[T](c), nil
 	default:
 		// For unknown clients, we cannot create a structured client
 		return nil, fmt.Errorf("unsupported client type for structured output: %T", client)
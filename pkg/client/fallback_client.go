@@ -0,0 +1,287 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/fpt/klein-cli/pkg/agent/domain"
+	pkgLogger "github.com/fpt/klein-cli/pkg/logger"
+	"github.com/fpt/klein-cli/pkg/message"
+	"github.com/fpt/klein-cli/pkg/tokenizer"
+)
+
+var fallbackLogger = pkgLogger.NewComponentLogger("llm-fallback")
+
+// RetryPolicy controls how often FallbackClient calls one backend before it
+// moves on to the next.
+type RetryPolicy struct {
+	MaxAttempts int           // calls per backend, the first included (<= 1: no retries)
+	Backoff     time.Duration // wait before the first retry, doubled for each next one
+	MaxBackoff  time.Duration // longest single wait; 0 = no cap
+}
+
+// delay is the wait before retry number n (1-based). A Retry-After from the
+// server replaces the computed backoff; ok is false when that is longer than
+// MaxBackoff, meaning this backend is not worth waiting for.
+func (p RetryPolicy) delay(n int, retryAfter time.Duration) (d time.Duration, ok bool) {
+	if retryAfter > 0 {
+		return retryAfter, p.MaxBackoff <= 0 || retryAfter <= p.MaxBackoff
+	}
+	d = p.Backoff
+	for i := 1; i < n && (p.MaxBackoff <= 0 || d < p.MaxBackoff); i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 {
+		d = min(d, p.MaxBackoff)
+	}
+	return d, true
+}
+
+// fallbackCore is the state shared by a FallbackClient and every wrapper
+// NewClientWithToolManager builds from it, so the original client reports
+// which backend served the latest call even when a wrapper made it.
+type fallbackCore struct {
+	policy RetryPolicy
+	sleep  func(ctx context.Context, d time.Duration) error // replaced in tests
+
+	mu        sync.Mutex
+	served    int // index of the backend that answered last
+	lastUsage message.TokenUsage
+}
+
+// FallbackClient is a domain.ToolCallingLLM over an ordered chain of backends.
+// Each call goes to the first backend; a transient failure (429, 5xx,
+// overload, a dropped connection) is retried under the policy, honouring
+// Retry-After, and once that backend's attempts are used up the call moves to
+// the next one with the same messages and tools. Any other error is returned
+// as is: a bad request would fail the same way everywhere.
+//
+// ModelID and LastTokenUsage describe the backend that served the latest
+// call, so telemetry and cost accounting follow the fallback.
+type FallbackClient struct {
+	*fallbackCore
+	backends []domain.LLM
+}
+
+// NewFallbackClient chains backends, tried in the order given.
+func NewFallbackClient(policy RetryPolicy, backends ...domain.LLM) *FallbackClient {
+	return &FallbackClient{
+		fallbackCore: &fallbackCore{policy: policy, sleep: sleepContext},
+		backends:     backends,
+	}
+}
+
+// Backends returns the chain, primary first.
+func (c *FallbackClient) Backends() []domain.LLM { return c.backends }
+
+// withBackends returns a client over a different set of (same-ordered)
+// backends sharing this one's core.
+func (c *FallbackClient) withBackends(backends []domain.LLM) *FallbackClient {
+	return &FallbackClient{fallbackCore: c.fallbackCore, backends: backends}
+}
+
+// Chat implements domain.LLM.
+func (c *FallbackClient) Chat(ctx context.Context, messages []message.Message, enableThinking bool, thinkingChan chan<- string) (message.Message, error) {
+	return c.call(ctx, func(llm domain.LLM) (message.Message, error) {
+		return llm.Chat(ctx, messages, enableThinking, thinkingChan)
+	})
+}
+
+// ChatWithToolChoice implements domain.ToolCallingLLM. Each backend converts
+// the tool schemas to its own format, so a fallback call carries the same
+// tools as the one it replaces.
+func (c *FallbackClient) ChatWithToolChoice(ctx context.Context, messages []message.Message, toolChoice domain.ToolChoice, enableThinking bool, thinkingChan chan<- string) (message.Message, error) {
+	return c.call(ctx, func(llm domain.LLM) (message.Message, error) {
+		if tc, ok := llm.(domain.ToolCallingLLM); ok {
+			return tc.ChatWithToolChoice(ctx, messages, toolChoice, enableThinking, thinkingChan)
+		}
+		return llm.Chat(ctx, messages, enableThinking, thinkingChan)
+	})
+}
+
+// SetToolManager implements domain.ToolCallingLLM by setting the tool manager
+// on every backend in place. NewClientWithToolManager is preferred: it gives
+// each caller its own copies.
+func (c *FallbackClient) SetToolManager(toolManager domain.ToolManager) {
+	for _, b := range c.backends {
+		if tc, ok := b.(domain.ToolCallingLLM); ok {
+			tc.SetToolManager(toolManager)
+		}
+	}
+}
+
+// call runs fn against each backend in turn until one succeeds.
+func (c *FallbackClient) call(ctx context.Context, fn func(domain.LLM) (message.Message, error)) (message.Message, error) {
+	var errs []error
+	for i, b := range c.backends {
+		msg, err := c.callBackend(ctx, b, fn)
+		if err == nil {
+			c.record(i, b)
+			return msg, nil
+		}
+		if ctx.Err() != nil || !isTransient(err) {
+			return nil, err
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", b.ModelID(), err))
+		if i+1 < len(c.backends) {
+			fallbackLogger.Warn("LLM backend unavailable, falling back",
+				"backend", b.ModelID(), "next", c.backends[i+1].ModelID(), "error", err)
+		}
+	}
+	if len(errs) == 1 {
+		return nil, errs[0]
+	}
+	return nil, fmt.Errorf("all %d LLM backends failed: %w", len(c.backends), errors.Join(errs...))
+}
+
+// callBackend calls one backend, retrying transient failures under the policy.
+func (c *FallbackClient) callBackend(ctx context.Context, b domain.LLM, fn func(domain.LLM) (message.Message, error)) (message.Message, error) {
+	for attempt := 1; ; attempt++ {
+		msg, err := fn(b)
+		if err == nil {
+			return msg, nil
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		f := classifyError(err)
+		if !f.transient || attempt >= c.policy.MaxAttempts {
+			return nil, err
+		}
+		wait, ok := c.policy.delay(attempt, f.retryAfter)
+		if !ok {
+			fallbackLogger.Warn("LLM backend asks for a longer wait than max_backoff allows, giving up on it",
+				"backend", b.ModelID(), "retry_after", wait, "error", err)
+			return nil, err
+		}
+		fallbackLogger.Info("Retrying LLM call after transient error",
+			"backend", b.ModelID(), "attempt", attempt+1, "wait", wait, "error", err)
+		if err := c.sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// record notes which backend served the latest call and its token usage.
+func (c *FallbackClient) record(i int, b domain.LLM) {
+	var usage message.TokenUsage
+	if p, ok := b.(domain.TokenUsageProvider); ok {
+		usage, _ = p.LastTokenUsage()
+	}
+	c.mu.Lock()
+	c.served, c.lastUsage = i, usage
+	c.mu.Unlock()
+}
+
+// ModelID implements domain.ModelIdentifier: the model of the backend that
+// served the latest call, the primary's before any call.
+func (c *FallbackClient) ModelID() string {
+	c.mu.Lock()
+	i := c.served
+	c.mu.Unlock()
+	return c.backends[i].ModelID()
+}
+
+// LastTokenUsage implements domain.TokenUsageProvider for the latest call,
+// whichever backend served it.
+func (c *FallbackClient) LastTokenUsage() (message.TokenUsage, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	u := c.lastUsage
+	return u, u.InputTokens != 0 || u.OutputTokens != 0 || u.TotalTokens != 0
+}
+
+// MaxContextTokens implements domain.ContextWindowProvider with the smallest
+// window in the chain: the history has to fit whichever backend takes over.
+func (c *FallbackClient) MaxContextTokens() int {
+	smallest := 0
+	for _, b := range c.backends {
+		if p, ok := b.(domain.ContextWindowProvider); ok {
+			if n := p.MaxContextTokens(); n > 0 && (smallest == 0 || n < smallest) {
+				smallest = n
+			}
+		}
+	}
+	return smallest
+}
+
+// CountTokens implements domain.TokenCounter with the largest count in the
+// chain, for the same reason MaxContextTokens takes the smallest window.
+func (c *FallbackClient) CountTokens(text string) int {
+	largest, counted := 0, false
+	for _, b := range c.backends {
+		if tc, ok := b.(domain.TokenCounter); ok {
+			largest, counted = max(largest, tc.CountTokens(text)), true
+		}
+	}
+	if !counted {
+		return tokenizer.ForModel(c.backends[0].ModelID()).CountTokens(text)
+	}
+	return largest
+}
+
+// SupportsVision implements domain.VisionLLM when every backend does.
+func (c *FallbackClient) SupportsVision() bool {
+	for _, b := range c.backends {
+		if v, ok := b.(domain.VisionLLM); !ok || !v.SupportsVision() {
+			return false
+		}
+	}
+	return true
+}
+
+// SupportsServerSideCompaction implements domain.ServerSideCompactionLLM
+// only when every backend compacts on its side; otherwise the agent keeps
+// compacting so a fallback never receives an oversized history.
+func (c *FallbackClient) SupportsServerSideCompaction() bool {
+	for _, b := range c.backends {
+		if s, ok := b.(domain.ServerSideCompactionLLM); !ok || !s.SupportsServerSideCompaction() {
+			return false
+		}
+	}
+	return true
+}
+
+// SetSessionID implements domain.SessionAware for every backend.
+func (c *FallbackClient) SetSessionID(id string) {
+	for _, b := range c.backends {
+		if s, ok := b.(domain.SessionAware); ok {
+			s.SetSessionID(id)
+		}
+	}
+}
+
+// SessionID implements domain.SessionAware.
+func (c *FallbackClient) SessionID() string {
+	for _, b := range c.backends {
+		if s, ok := b.(domain.SessionAware); ok {
+			return s.SessionID()
+		}
+	}
+	return ""
+}
+
+// ConfigureModelSideCache implements domain.ModelSideCacheConfigurator for
+// every backend.
+func (c *FallbackClient) ConfigureModelSideCache(opts domain.ModelSideCacheOptions) {
+	for _, b := range c.backends {
+		if m, ok := b.(domain.ModelSideCacheConfigurator); ok {
+			m.ConfigureModelSideCache(opts)
+		}
+	}
+}
+
+// sleepContext waits for d or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
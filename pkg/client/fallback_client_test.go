@@ -0,0 +1,341 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fpt/klein-cli/internal/config"
+	"github.com/fpt/klein-cli/pkg/agent/domain"
+	"github.com/fpt/klein-cli/pkg/client/openai"
+	"github.com/fpt/klein-cli/pkg/message"
+)
+
+// fakeProvider is an httptest server that answers each request with the next
+// scripted response, repeating the last one, and keeps the request bodies.
+type fakeProvider struct {
+	*httptest.Server
+	mu     sync.Mutex
+	bodies []string
+}
+
+type fakeResponse struct {
+	status int
+	header map[string]string
+	body   string
+	gemini bool // body is a GenerateContentResponse, sent as SSE to streaming calls
+}
+
+func newFakeProvider(t *testing.T, script ...fakeResponse) *fakeProvider {
+	t.Helper()
+	f := &fakeProvider{}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		f.mu.Lock()
+		f.bodies = append(f.bodies, string(body))
+		resp := script[min(len(f.bodies), len(script))-1]
+		f.mu.Unlock()
+		for k, v := range resp.header {
+			w.Header().Set(k, v)
+		}
+		if resp.gemini && strings.Contains(r.URL.Path, ":streamGenerateContent") {
+			w.Header().Set("Content-Type", "text/event-stream")
+			resp.body = "data: " + resp.body + "\r\n\r\n"
+		}
+		if resp.status == 0 {
+			resp.status = http.StatusOK
+		}
+		w.WriteHeader(resp.status)
+		_, _ = io.WriteString(w, resp.body)
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeProvider) requests() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.bodies...)
+}
+
+// geminiReply is a Gemini answer with its token usage.
+func geminiReply(text string, in, out int) fakeResponse {
+	body := fmt.Sprintf(`{"candidates":[{"content":{"role":"model","parts":[{"text":%q}]},"finishReason":"STOP"}],`+
+		`"usageMetadata":{"promptTokenCount":%d,"candidatesTokenCount":%d,"totalTokenCount":%d}}`, text, in, out, in+out)
+	return fakeResponse{header: map[string]string{"Content-Type": "application/json"}, body: body, gemini: true}
+}
+
+// errorReply is an API error in the shape OpenAI and Anthropic use.
+func errorReply(status int, header map[string]string) fakeResponse {
+	h := map[string]string{"Content-Type": "application/json"}
+	for k, v := range header {
+		h[k] = v
+	}
+	return fakeResponse{status: status, header: h,
+		body: fmt.Sprintf(`{"error":{"message":"fake %d","type":"error"}}`, status)}
+}
+
+// newTestChain builds a client from settings the way klein does and records
+// the waits instead of sleeping.
+func newTestChain(t *testing.T, settings config.LLMSettings) (*FallbackClient, *[]time.Duration) {
+	t.Helper()
+	t.Setenv("OPENAI_API_KEY", "test")
+	t.Setenv("ANTHROPIC_API_KEY", "test")
+	t.Setenv("GEMINI_API_KEY", "test")
+	t.Setenv("OPENAI_BASE_URL", "")
+	llm, err := NewLLMClient(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc, ok := llm.(*FallbackClient)
+	if !ok {
+		t.Fatalf("NewLLMClient = %T, want *FallbackClient", llm)
+	}
+	var waits []time.Duration
+	fc.sleep = func(_ context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+	return fc, &waits
+}
+
+func userPrompt(text string) []message.Message {
+	return []message.Message{message.NewChatMessage(message.MessageTypeUser, text)}
+}
+
+func TestNewLLMClientWithoutChainKeepsPlainClient(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "test")
+	llm, err := NewLLMClient(config.LLMSettings{Backend: "openai", Model: "gpt-5.6-luna"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := llm.(*openai.OpenAIClient); !ok {
+		t.Fatalf("NewLLMClient = %T, want the plain OpenAI client", llm)
+	}
+}
+
+func TestFallbackHonoursRetryAfterThenFallsBack(t *testing.T) {
+	primary := newFakeProvider(t, errorReply(http.StatusTooManyRequests, map[string]string{"Retry-After": "3"}))
+	backup := newFakeProvider(t, geminiReply("served by gemini", 11, 4))
+	fc, waits := newTestChain(t, config.LLMSettings{
+		Backend: "openai", Model: "gpt-5.6-luna", BaseURL: primary.URL,
+		Retry:    config.RetrySettings{MaxAttempts: 2},
+		Fallback: []config.LLMSettings{{Backend: "gemini", Model: "gemini-2.5-flash", BaseURL: backup.URL}},
+	})
+
+	if got := fc.ModelID(); got != "gpt-5.6-luna" {
+		t.Errorf("ModelID before any call = %q, want the primary", got)
+	}
+	resp, err := fc.Chat(context.Background(), userPrompt("hi"), false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content() != "served by gemini" {
+		t.Errorf("reply = %q", resp.Content())
+	}
+	// Two attempts on the primary — its SDK must not retry on its own.
+	if n := len(primary.requests()); n != 2 {
+		t.Errorf("primary saw %d requests, want 2", n)
+	}
+	if len(*waits) != 1 || (*waits)[0] != 3*time.Second {
+		t.Errorf("waits = %v, want the server's Retry-After of 3s", *waits)
+	}
+	if got := fc.ModelID(); got != "gemini-2.5-flash" {
+		t.Errorf("ModelID = %q, want the backend that served the call", got)
+	}
+	if u, ok := fc.LastTokenUsage(); !ok || u.InputTokens != 11 || u.OutputTokens != 4 {
+		t.Errorf("usage = %+v, %v", u, ok)
+	}
+}
+
+func TestFallbackRetriesSameBackendOnRetryInfo(t *testing.T) {
+	busy := fakeResponse{status: http.StatusServiceUnavailable, header: map[string]string{"Content-Type": "application/json"},
+		body: `{"error":{"code":503,"message":"overloaded","status":"UNAVAILABLE","details":[` +
+			`{"@type":"type.googleapis.com/google.rpc.RetryInfo","retryDelay":"7s"}]}}`}
+	gemini := newFakeProvider(t, busy, geminiReply("second time lucky", 5, 2))
+	fc, waits := newTestChain(t, config.LLMSettings{
+		Backend: "gemini", Model: "gemini-2.5-flash", BaseURL: gemini.URL,
+		Retry: config.RetrySettings{MaxAttempts: 3},
+	})
+
+	resp, err := fc.Chat(context.Background(), userPrompt("hi"), false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content() != "second time lucky" || len(gemini.requests()) != 2 {
+		t.Errorf("reply %q after %d requests", resp.Content(), len(gemini.requests()))
+	}
+	if len(*waits) != 1 || (*waits)[0] != 7*time.Second {
+		t.Errorf("waits = %v, want Gemini's retryDelay of 7s", *waits)
+	}
+}
+
+func TestFallbackSkipsBackendAskingTooLongAWait(t *testing.T) {
+	primary := newFakeProvider(t, errorReply(http.StatusTooManyRequests, map[string]string{"retry-after": "120"}))
+	backup := newFakeProvider(t, geminiReply("ok", 1, 1))
+	fc, waits := newTestChain(t, config.LLMSettings{
+		Backend: "anthropic", Model: "claude-sonnet-4-6", BaseURL: primary.URL,
+		Retry:    config.RetrySettings{MaxAttempts: 5, MaxBackoff: "30s"},
+		Fallback: []config.LLMSettings{{Backend: "gemini", Model: "gemini-2.5-flash", BaseURL: backup.URL}},
+	})
+
+	if _, err := fc.Chat(context.Background(), userPrompt("hi"), false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(primary.requests()); n != 1 || len(*waits) != 0 {
+		t.Errorf("primary saw %d requests and we waited %v; a 120s Retry-After should move on at once", n, *waits)
+	}
+}
+
+func TestFallbackReturnsPermanentErrors(t *testing.T) {
+	primary := newFakeProvider(t, errorReply(http.StatusBadRequest, nil))
+	backup := newFakeProvider(t, geminiReply("unused", 1, 1))
+	fc, _ := newTestChain(t, config.LLMSettings{
+		Backend: "openai", Model: "gpt-5.6-luna", BaseURL: primary.URL,
+		Fallback: []config.LLMSettings{{Backend: "gemini", Model: "gemini-2.5-flash", BaseURL: backup.URL}},
+	})
+
+	if _, err := fc.Chat(context.Background(), userPrompt("hi"), false, nil); err == nil {
+		t.Fatal("a 400 should be returned, not retried elsewhere")
+	}
+	if len(primary.requests()) != 1 || len(backup.requests()) != 0 {
+		t.Errorf("requests: primary %d, backup %d", len(primary.requests()), len(backup.requests()))
+	}
+}
+
+func TestFallbackReportsEveryBackendWhenAllFail(t *testing.T) {
+	a := newFakeProvider(t, errorReply(http.StatusInternalServerError, nil))
+	b := newFakeProvider(t, errorReply(529, nil))
+	fc, waits := newTestChain(t, config.LLMSettings{
+		Backend: "openai", Model: "gpt-5.6-luna", BaseURL: a.URL,
+		Retry:    config.RetrySettings{MaxAttempts: 3, Backoff: "1s", MaxBackoff: "1500ms"},
+		Fallback: []config.LLMSettings{{Backend: "anthropic", Model: "claude-sonnet-4-6", BaseURL: b.URL}},
+	})
+
+	_, err := fc.Chat(context.Background(), userPrompt("hi"), false, nil)
+	if err == nil || !strings.Contains(err.Error(), "all 2 LLM backends failed") ||
+		!strings.Contains(err.Error(), "gpt-5.6-luna") || !strings.Contains(err.Error(), "claude-sonnet-4-6") {
+		t.Fatalf("err = %v", err)
+	}
+	want := []time.Duration{time.Second, 1500 * time.Millisecond, time.Second, 1500 * time.Millisecond}
+	if fmt.Sprint(*waits) != fmt.Sprint(want) {
+		t.Errorf("waits = %v, want doubling capped at max_backoff: %v", *waits, want)
+	}
+	if len(a.requests()) != 3 || len(b.requests()) != 3 {
+		t.Errorf("requests: %d and %d, want 3 each", len(a.requests()), len(b.requests()))
+	}
+}
+
+type fakeTool struct{ name message.ToolName }
+
+func (f fakeTool) RawName() message.ToolName { return f.name }
+func (f fakeTool) Name() message.ToolName    { return f.name }
+func (f fakeTool) Description() message.ToolDescription {
+	return "Look up the weather"
+}
+func (f fakeTool) Arguments() []message.ToolArgument {
+	return []message.ToolArgument{{Name: "city", Description: "City name", Required: true, Type: "string"}}
+}
+func (f fakeTool) Handler() func(ctx context.Context, args message.ToolArgumentValues) (message.ToolResult, error) {
+	return nil
+}
+
+func TestFallbackResendsToolsToTheNextBackend(t *testing.T) {
+	primary := newFakeProvider(t, errorReply(http.StatusBadGateway, nil))
+	backup := newFakeProvider(t, geminiReply("sunny", 20, 1))
+	fc, _ := newTestChain(t, config.LLMSettings{
+		Backend: "openai", Model: "gpt-5.6-luna", BaseURL: primary.URL,
+		Retry:    config.RetrySettings{MaxAttempts: 1},
+		Fallback: []config.LLMSettings{{Backend: "gemini", Model: "gemini-2.5-flash", BaseURL: backup.URL}},
+	})
+	tools := &mockToolManager{getToolsFunc: func() map[message.ToolName]message.Tool {
+		return map[message.ToolName]message.Tool{"weather": fakeTool{name: "weather"}}
+	}}
+
+	toolClient, err := NewClientWithToolManager(fc, tools)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := toolClient.ChatWithToolChoice(context.Background(), userPrompt("weather in Kyoto?"),
+		domain.ToolChoice{Type: domain.ToolChoiceAuto}, false, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if reqs := primary.requests(); len(reqs) != 1 || !strings.Contains(reqs[0], `"weather"`) {
+		t.Errorf("primary request should carry the tool: %v", reqs)
+	}
+	reqs := backup.requests()
+	if len(reqs) != 1 {
+		t.Fatalf("backup saw %d requests", len(reqs))
+	}
+	var body struct {
+		Tools []struct {
+			FunctionDeclarations []struct{ Name string } `json:"functionDeclarations"`
+		} `json:"tools"`
+	}
+	if err := json.Unmarshal([]byte(reqs[0]), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Tools) == 0 || len(body.Tools[0].FunctionDeclarations) == 0 ||
+		body.Tools[0].FunctionDeclarations[0].Name != "weather" {
+		t.Errorf("fallback request lost the tools: %s", reqs[0])
+	}
+	// The wrapper shares the original client's record of who served the call.
+	if fc.ModelID() != "gemini-2.5-flash" {
+		t.Errorf("original client ModelID = %q", fc.ModelID())
+	}
+}
+
+func TestFallbackStopsWhenContextIsCancelled(t *testing.T) {
+	primary := newFakeProvider(t, errorReply(http.StatusServiceUnavailable, nil))
+	backup := newFakeProvider(t, geminiReply("unused", 1, 1))
+	fc, _ := newTestChain(t, config.LLMSettings{
+		Backend: "openai", Model: "gpt-5.6-luna", BaseURL: primary.URL,
+		Fallback: []config.LLMSettings{{Backend: "gemini", Model: "gemini-2.5-flash", BaseURL: backup.URL}},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	fc.sleep = func(context.Context, time.Duration) error {
+		cancel()
+		return ctx.Err()
+	}
+
+	if _, err := fc.Chat(ctx, userPrompt("hi"), false, nil); err == nil {
+		t.Fatal("want the cancellation")
+	}
+	if len(backup.requests()) != 0 {
+		t.Error("a cancelled call must not fall back")
+	}
+}
+
+func TestRetryAfterHeader(t *testing.T) {
+	for _, tc := range []struct {
+		header map[string]string
+		want   time.Duration
+	}{
+		{map[string]string{"Retry-After": "2"}, 2 * time.Second},
+		{map[string]string{"Retry-After": "2", "Retry-After-Ms": "250"}, 250 * time.Millisecond},
+		{map[string]string{"Retry-After": "soon"}, 0},
+		{map[string]string{"Retry-After": time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)}, 0},
+		{nil, 0},
+	} {
+		resp := &http.Response{Header: http.Header{}}
+		for k, v := range tc.header {
+			resp.Header.Set(k, v)
+		}
+		if got := retryAfter(resp); got != tc.want {
+			t.Errorf("retryAfter(%v) = %v, want %v", tc.header, got, tc.want)
+		}
+	}
+	future := time.Now().Add(90 * time.Second).UTC().Format(http.TimeFormat)
+	resp := &http.Response{Header: http.Header{"Retry-After": {future}}}
+	if got := retryAfter(resp); got < 80*time.Second || got > 90*time.Second {
+		t.Errorf("HTTP-date Retry-After = %v, want about 90s", got)
+	}
+}
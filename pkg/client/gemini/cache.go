@@ -0,0 +1,166 @@
+package gemini
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+// contextCacheTTL is how long an explicit context cache outlives its last
+// use. It is refreshed once half of it has passed, so a conversation keeps
+// one cache while it is active and the cache goes away soon after.
+const contextCacheTTL = 10 * time.Minute
+
+// contextCache keeps Gemini explicit context caches for the part of a request
+// that repeats on every call of a conversation: the system instruction, the
+// tool declarations and the tool config. Gemini bills cached tokens at a
+// fraction of the input price, and implicit caching is not guaranteed to hit.
+//
+// It lives on the core so every client built from it shares the caches; each
+// distinct prefix (a sub-agent's tool set, a forced tool choice) gets its own.
+type contextCache struct {
+	mu       sync.Mutex
+	disabled bool
+	entries  map[string]cacheEntry // prefix hash → cache
+	refused  map[string]bool       // prefixes the API would not cache
+	now      func() time.Time
+}
+
+type cacheEntry struct {
+	name    string // cachedContents/…
+	expires time.Time
+}
+
+func newContextCache() *contextCache {
+	return &contextCache{
+		entries: make(map[string]cacheEntry),
+		refused: make(map[string]bool),
+		now:     time.Now,
+	}
+}
+
+// setEnabled turns explicit caching on or off (domain.ModelSideCacheOptions).
+func (cc *contextCache) setEnabled(enabled bool) {
+	cc.mu.Lock()
+	cc.disabled = !enabled
+	cc.mu.Unlock()
+}
+
+// apply moves config's system instruction, tools and tool config into a
+// context cache and points config at it. It returns the cache name, or "" when
+// the request goes out as it is: caching is off, the prefix is under the
+// model's minimum, or the API would not create a cache. A failure to cache is
+// never a failure of the call.
+func (cc *contextCache) apply(ctx context.Context, client *genai.Client, model string, caps ModelCapabilities, config *genai.GenerateContentConfig) string {
+	if config.SystemInstruction == nil && len(config.Tools) == 0 {
+		return ""
+	}
+	prefix, err := json.Marshal(struct {
+		System     *genai.Content    `json:"system,omitempty"`
+		Tools      []*genai.Tool     `json:"tools,omitempty"`
+		ToolConfig *genai.ToolConfig `json:"toolConfig,omitempty"`
+	}{config.SystemInstruction, config.Tools, config.ToolConfig})
+	if err != nil {
+		return ""
+	}
+	// About four bytes a token; the API has the final word on the minimum.
+	if caps.CacheMinTokens == 0 || len(prefix)/4 < caps.CacheMinTokens {
+		return ""
+	}
+	sum := sha256.Sum256(append([]byte(model+"\x00"), prefix...))
+	key := hex.EncodeToString(sum[:])
+
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if cc.disabled || cc.refused[key] {
+		return ""
+	}
+
+	now := cc.now()
+	entry, ok := cc.entries[key]
+	switch {
+	case ok && entry.expires.Sub(now) > contextCacheTTL/2:
+		// Fresh enough to use as is.
+	case ok && entry.expires.After(now):
+		if _, err := client.Caches.Update(ctx, entry.name, &genai.UpdateCachedContentConfig{TTL: contextCacheTTL}); err != nil {
+			geminiLogger.Debug("Context cache refresh failed, creating a new one", "cache", entry.name, "error", err)
+			ok = false
+		} else {
+			entry.expires = now.Add(contextCacheTTL)
+			cc.entries[key] = entry
+		}
+	default:
+		ok = false
+	}
+
+	if !ok {
+		delete(cc.entries, key)
+		cached, err := client.Caches.Create(ctx, model, &genai.CreateCachedContentConfig{
+			DisplayName:       "klein",
+			TTL:               contextCacheTTL,
+			SystemInstruction: config.SystemInstruction,
+			Tools:             config.Tools,
+			ToolConfig:        config.ToolConfig,
+		})
+		if err != nil {
+			if refusedForGood(err) {
+				cc.refused[key] = true
+			}
+			geminiLogger.Debug("Context cache not created, sending the prefix inline", "model", model, "error", err)
+			return ""
+		}
+		entry = cacheEntry{name: cached.Name, expires: now.Add(contextCacheTTL)}
+		cc.entries[key] = entry
+		geminiLogger.Debug("Created context cache", "cache", entry.name, "model", model, "prefix_bytes", len(prefix))
+	}
+
+	config.CachedContent = entry.name
+	config.SystemInstruction, config.Tools, config.ToolConfig = nil, nil, nil
+	return entry.name
+}
+
+// forget drops a cache the API no longer knows (expired or deleted early).
+func (cc *contextCache) forget(name string) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	for key, e := range cc.entries {
+		if e.name == name {
+			delete(cc.entries, key)
+		}
+	}
+}
+
+// refusedForGood reports whether a failed cache creation will fail the same
+// way next time (too few tokens, a model without caching) rather than being
+// a passing rate limit or outage.
+func refusedForGood(err error) bool {
+	var apiErr genai.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.Code == http.StatusBadRequest || apiErr.Code == http.StatusNotFound
+}
+
+// isStaleCacheError reports whether a generate call failed because the
+// context cache it named is gone, in which case it is worth repeating inline.
+func isStaleCacheError(err error) bool {
+	var apiErr genai.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.Code {
+	case http.StatusForbidden, http.StatusNotFound:
+		return true
+	case http.StatusBadRequest:
+		return strings.Contains(strings.ToLower(apiErr.Message), "cache")
+	}
+	return false
+}
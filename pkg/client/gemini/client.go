@@ -2,6 +2,7 @@ package gemini
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
 	"os"
 	"strings"
@@ -11,6 +12,7 @@ import (
 	"github.com/fpt/klein-cli/pkg/agent/domain"
 	pkgLogger "github.com/fpt/klein-cli/pkg/logger"
 	"github.com/fpt/klein-cli/pkg/message"
+	"github.com/fpt/klein-cli/pkg/tokenizer"
 )
 
 var geminiLogger = pkgLogger.NewComponentLogger("gemini-client")
@@ -20,6 +22,11 @@ type GeminiCore struct {
 	client    *genai.Client
 	model     string
 	maxTokens int
+	effort    string // llm.effort, mapped onto thinking budgets or levels
+
+	// cache holds the explicit context caches shared by every client built
+	// from this core; nil turns caching off.
+	cache *contextCache
 
 	// lastUsage is shared by all wrappers built from this core so token usage
 	// reported via the original client stays accurate even when per-invocation
@@ -32,9 +39,8 @@ type GeminiClient struct {
 	*GeminiCore
 	toolManager domain.ToolManager
 
-	// Caching/session hints
+	// Session hint
 	sessionID string
-	cacheOpts domain.ModelSideCacheOptions
 }
 
 // NewGeminiClient creates a new Gemini client with the specified model
@@ -44,6 +50,14 @@ func NewGeminiClient(model string) (*GeminiClient, error) {
 
 // NewGeminiClientWithTokens creates a new Gemini client with configurable maxTokens
 func NewGeminiClientWithTokens(model string, maxTokens int) (*GeminiClient, error) {
+	return NewGeminiClientWithHTTPOptions(model, maxTokens, "", genai.HTTPOptions{})
+}
+
+// NewGeminiClientWithHTTPOptions creates a Gemini client with a reasoning
+// effort (see thinkingConfig; "" = the model's default) whose requests use
+// httpOptions, e.g. a base URL. The SDK does not retry unless
+// httpOptions.RetryOptions asks it to.
+func NewGeminiClientWithHTTPOptions(model string, maxTokens int, effort string, httpOptions genai.HTTPOptions) (*GeminiClient, error) {
 	apiKey := os.Getenv("GEMINI_API_KEY")
 	if apiKey == "" {
 		return nil, fmt.Errorf("GEMINI_API_KEY environment variable not set")
@@ -51,8 +65,9 @@ func NewGeminiClientWithTokens(model string, maxTokens int) (*GeminiClient, erro
 
 	ctx := context.Background()
 	client, err := genai.NewClient(ctx, &genai.ClientConfig{
-		APIKey:  apiKey,
-		Backend: genai.BackendGeminiAPI,
+		APIKey:      apiKey,
+		Backend:     genai.BackendGeminiAPI,
+		HTTPOptions: httpOptions,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Gemini client: %w", err)
@@ -70,6 +85,8 @@ func NewGeminiClientWithTokens(model string, maxTokens int) (*GeminiClient, erro
 		client:    client,
 		model:     geminiModel,
 		maxTokens: maxTokens,
+		effort:    effort,
+		cache:     newContextCache(),
 	}
 
 	return &GeminiClient{
@@ -89,15 +106,16 @@ func (c *GeminiClient) ModelID() string { return c.model }
 
 // ContextWindowProvider implementation
 func (c *GeminiClient) MaxContextTokens() int {
-	caps := getModelCapabilities(c.model)
-	if caps.MaxContextWindow > 0 {
-		return caps.MaxContextWindow
-	}
-	// Conservative default for Gemini 2.5 family
-	return 1048576
+	return getModelCapabilities(c.model).MaxContextWindow
+}
+
+// TokenCounter implementation
+func (c *GeminiClient) CountTokens(text string) int {
+	return tokenizer.ForModel(c.model).CountTokens(text)
 }
 
-// TokenUsageProvider implementation
+// TokenUsageProvider implementation. CachedTokens counts prompt tokens
+// served from a context cache, explicit or implicit.
 func (c *GeminiClient) LastTokenUsage() (message.TokenUsage, bool) {
 	if c.lastUsage.InputTokens != 0 || c.lastUsage.OutputTokens != 0 || c.lastUsage.TotalTokens != 0 {
 		return c.lastUsage, true
@@ -109,191 +127,204 @@ func (c *GeminiClient) LastTokenUsage() (message.TokenUsage, bool) {
 func (c *GeminiClient) SetSessionID(id string) { c.sessionID = id }
 func (c *GeminiClient) SessionID() string      { return c.sessionID }
 
-// ModelSideCacheConfigurator implementation (store hints for later use)
-func (c *GeminiClient) ConfigureModelSideCache(opts domain.ModelSideCacheOptions) { c.cacheOpts = opts }
+// ModelSideCacheConfigurator implementation: PromptCachingEnabled turns
+// explicit context caching on or off. It is on until configured otherwise.
+func (c *GeminiClient) ConfigureModelSideCache(opts domain.ModelSideCacheOptions) {
+	if c.cache != nil {
+		c.cache.setEnabled(opts.PromptCachingEnabled)
+	}
+}
 
 // Chat implements the basic LLM interface with thinking control
 func (c *GeminiClient) Chat(ctx context.Context, messages []message.Message, enableThinking bool, thinkingChan chan<- string) (message.Message, error) {
-	// Convert internal messages to Gemini format
-	geminiContents := make([]*genai.Content, 0)
-	var systemInstruction *genai.Content
-
-	for _, msg := range messages {
-		switch msg.Type() {
-		case message.MessageTypeUser:
-			// Handle images if present
-			if images := msg.Images(); len(images) > 0 {
-				// Create parts with both text and images
-				parts := []*genai.Part{}
-				if content := msg.Content(); content != "" {
-					parts = append(parts, &genai.Part{Text: content})
-				}
-				// TODO: Implement image handling with proper base64 decoding
-				// For now, just add text content
-				geminiContents = append(geminiContents, genai.NewContentFromParts(parts, genai.RoleUser))
-			} else {
-				geminiContents = append(geminiContents, genai.NewContentFromText(msg.Content(), genai.RoleUser))
-			}
-
-		case message.MessageTypeAssistant:
-			// Add assistant messages as context
-			geminiContents = append(geminiContents, genai.NewContentFromText(msg.Content(), genai.RoleModel))
+	caps := getModelCapabilities(c.model)
+	system, contents := toGeminiContents(messages, caps, false)
 
-		case message.MessageTypeSystem:
-			// Use the last system message as system instruction
-			systemInstruction = genai.NewContentFromText(msg.Content(), genai.RoleUser)
+	config := c.newConfig(caps, enableThinking)
+	config.SystemInstruction = system
 
-			// Skip tool call and result messages for basic chat
-		}
-	}
+	// Stream for progressive thinking display (no tool handling in basic chat)
+	stream := enableThinking && caps.IsReasoningModel
+	return c.generate(ctx, contents, config, stream, false, enableThinking, thinkingChan)
+}
 
-	// Prepare configuration with thinking support
-	config := &genai.GenerateContentConfig{
+// newConfig returns the per-call generation settings: the output limit and
+// the thinking controls llm.effort maps to.
+func (c *GeminiCore) newConfig(caps ModelCapabilities, includeThoughts bool) *genai.GenerateContentConfig {
+	return &genai.GenerateContentConfig{
 		MaxOutputTokens: int32(c.maxTokens),
+		ThinkingConfig:  thinkingConfig(caps, c.effort, includeThoughts),
 	}
-	if systemInstruction != nil {
-		config.SystemInstruction = systemInstruction
-	}
+}
 
-	// Enable thinking if requested and model supports it
-	if enableThinking && c.isThinkingCapable() {
-		config.ThinkingConfig = &genai.ThinkingConfig{
-			IncludeThoughts: true,
-		}
+// generate sends one request, through a context cache for its stable prefix
+// when one applies. A cache that has gone away server-side is forgotten and
+// the request repeated inline.
+func (c *GeminiCore) generate(ctx context.Context, contents []*genai.Content, config *genai.GenerateContentConfig, stream, handleTools, enableThinking bool, thinkingChan chan<- string) (message.Message, error) {
+	inline := *config
+	var cacheName string
+	if c.cache != nil {
+		cacheName = c.cache.apply(ctx, c.client, c.model, getModelCapabilities(c.model), config)
+	}
 
-		// Use streaming for progressive thinking display (no tool handling in basic chat)
-		return c.chatWithStreaming(ctx, geminiContents, config, true, false, enableThinking, thinkingChan)
+	msg, err := c.send(ctx, contents, config, stream, handleTools, enableThinking, thinkingChan)
+	if err != nil && cacheName != "" && isStaleCacheError(err) {
+		geminiLogger.Debug("Context cache rejected, retrying inline", "cache", cacheName, "error", err)
+		c.cache.forget(cacheName)
+		return c.send(ctx, contents, &inline, stream, handleTools, enableThinking, thinkingChan)
 	}
+	return msg, err
+}
 
-	// Generate content using the Models interface (non-streaming)
-	resp, err := c.client.Models.GenerateContent(ctx, c.model, geminiContents, config)
-	if err != nil {
-		return nil, fmt.Errorf("Gemini API call failed: %w", err)
+// send makes the API call, streaming or not, and turns the response into a
+// message. The handleTools parameter controls whether function calls are
+// returned as tool calls or ignored.
+func (c *GeminiCore) send(ctx context.Context, contents []*genai.Content, config *genai.GenerateContentConfig, stream, handleTools, enableThinking bool, thinkingChan chan<- string) (message.Message, error) {
+	r := responseCollector{handleTools: handleTools}
+	if enableThinking {
+		r.thinkingChan = thinkingChan
 	}
 
-	// Debug: Log token usage from Gemini API response
-	if resp.UsageMetadata != nil {
-		maxTokens := c.maxTokens
-		inputTokens := resp.UsageMetadata.PromptTokenCount
-		outputTokens := resp.UsageMetadata.CandidatesTokenCount
-		totalTokens := resp.UsageMetadata.TotalTokenCount
-		utilizationPct := float64(outputTokens) / float64(maxTokens) * 100
-
-		// Store token usage for telemetry consumers
-		c.lastUsage = message.TokenUsage{
-			InputTokens:  int(inputTokens),
-			OutputTokens: int(outputTokens),
-			TotalTokens:  int(totalTokens),
+	if stream {
+		for resp, err := range c.client.Models.GenerateContentStream(ctx, c.model, contents, config) {
+			if err != nil {
+				return nil, fmt.Errorf("Gemini streaming error: %w", err)
+			}
+			r.add(resp)
+		}
+	} else {
+		resp, err := c.client.Models.GenerateContent(ctx, c.model, contents, config)
+		if err != nil {
+			return nil, fmt.Errorf("Gemini API call failed: %w", err)
 		}
+		r.add(resp)
+	}
 
-		geminiLogger.DebugWithIntention(pkgLogger.IntentionStatistics, "Gemini API Usage", "input_tokens", inputTokens, "output_tokens", outputTokens, "total_tokens", totalTokens, "model", c.model)
-		geminiLogger.DebugWithIntention(pkgLogger.IntentionStatistics, "Token utilization", "percent", fmt.Sprintf("%.1f", utilizationPct), "output", outputTokens, "max_output", maxTokens)
+	c.recordUsage(r.usage)
+	return r.message()
+}
 
-		// Warn if we're approaching the limit
+// recordUsage stores the usage of the latest response for telemetry
+// consumers. Thought tokens are billed as output, as OpenAI reasoning tokens
+// are.
+func (c *GeminiCore) recordUsage(u *genai.GenerateContentResponseUsageMetadata) {
+	if u == nil {
+		return
+	}
+	c.lastUsage = message.TokenUsage{
+		InputTokens:  int(u.PromptTokenCount),
+		OutputTokens: int(u.CandidatesTokenCount + u.ThoughtsTokenCount),
+		TotalTokens:  int(u.TotalTokenCount),
+		CachedTokens: int(u.CachedContentTokenCount),
+	}
+
+	geminiLogger.DebugWithIntention(pkgLogger.IntentionStatistics, "Gemini API Usage",
+		"input_tokens", c.lastUsage.InputTokens, "cached_tokens", c.lastUsage.CachedTokens,
+		"output_tokens", c.lastUsage.OutputTokens, "total_tokens", c.lastUsage.TotalTokens, "model", c.model)
+
+	if c.maxTokens > 0 {
+		utilizationPct := float64(c.lastUsage.OutputTokens) / float64(c.maxTokens) * 100
 		if utilizationPct > 90 {
 			geminiLogger.Warn("Very high token usage - potential truncation risk!", "percent", fmt.Sprintf("%.1f", utilizationPct))
 		} else if utilizationPct > 80 {
 			geminiLogger.Warn("High token usage - approaching limit", "percent", fmt.Sprintf("%.1f", utilizationPct))
 		}
 	}
-
-	if len(resp.Candidates) == 0 {
-		return nil, fmt.Errorf("no response from Gemini")
-	}
-
-	// Extract text content from response
-	responseText := resp.Text()
-	if responseText == "" {
-		return nil, fmt.Errorf("empty response from Gemini")
-	}
-
-	return message.NewChatMessage(message.MessageTypeAssistant, responseText), nil
 }
 
-// isThinkingCapable checks if the current model supports thinking
-func (c *GeminiClient) isThinkingCapable() bool {
-	capabilities := getModelCapabilities(c.model)
-	return capabilities.IsReasoningModel
+// responseCollector accumulates a response, whole or streamed in chunks.
+type responseCollector struct {
+	handleTools  bool
+	thinkingChan chan<- string // nil when thoughts are not shown
+
+	text     strings.Builder
+	thinking strings.Builder
+	calls    []*genai.Part // functionCall parts, in the order emitted
+	usage    *genai.GenerateContentResponseUsageMetadata
+	finish   genai.FinishReason
+	blocked  genai.BlockedReason
 }
 
-// chatWithStreaming handles streaming generation with progressive thinking display
-// The handleTools parameter controls whether to process function calls or treat them as regular text
-func (c *GeminiClient) chatWithStreaming(ctx context.Context, contents []*genai.Content, config *genai.GenerateContentConfig, showThinking bool, handleTools bool, enableThinking bool, thinkingChan chan<- string) (message.Message, error) {
-	// Create streaming generator
-	stream := c.client.Models.GenerateContentStream(ctx, c.model, contents, config)
-
-	var responseText strings.Builder
-	var thinkingText strings.Builder
-	var toolCalls []*genai.FunctionCall // Collect multiple tool calls
-
-	// Process streaming responses using the iter.Seq2 pattern
-	for resp, err := range stream {
-		if err != nil {
-			return nil, fmt.Errorf("Gemini streaming error: %w", err)
-		}
+func (r *responseCollector) add(resp *genai.GenerateContentResponse) {
+	// The final chunk carries the usage for the whole response
+	if resp.UsageMetadata != nil {
+		r.usage = resp.UsageMetadata
+	}
+	if resp.PromptFeedback != nil && resp.PromptFeedback.BlockReason != "" {
+		r.blocked = resp.PromptFeedback.BlockReason
+	}
+	if len(resp.Candidates) == 0 {
+		return
+	}
+	candidate := resp.Candidates[0]
+	if candidate.FinishReason != "" {
+		r.finish = candidate.FinishReason
+	}
+	if candidate.Content == nil {
+		return
+	}
 
-		// Handle content candidates
-		if len(resp.Candidates) > 0 && resp.Candidates[0].Content != nil {
-			for _, part := range resp.Candidates[0].Content.Parts {
-				// Handle function calls if tool handling is enabled
-				if handleTools && part.FunctionCall != nil {
-					// Collect tool calls for later processing
-					toolCalls = append(toolCalls, part.FunctionCall)
-					continue
-				}
-
-				// Handle regular text content
-				if part.Text != "" {
-					if showThinking {
-						// Send thinking content to channel if enabled
-						if enableThinking && thinkingChan != nil {
-							message.SendThinkingContent(thinkingChan, part.Text)
-						}
-					}
-
-					// Accumulate all text
-					responseText.WriteString(part.Text)
-				}
+	for _, part := range candidate.Content.Parts {
+		switch {
+		case part.FunctionCall != nil:
+			if r.handleTools {
+				r.calls = append(r.calls, part)
+			}
+		case part.Thought:
+			r.thinking.WriteString(part.Text)
+			if part.Text != "" && r.thinkingChan != nil {
+				message.SendThinkingContent(r.thinkingChan, part.Text)
 			}
+		default:
+			r.text.WriteString(part.Text)
 		}
 	}
+}
 
-	// Signal end of thinking if we accumulated thinking content
-	if thinkingText.Len() > 0 && enableThinking && thinkingChan != nil {
-		message.EndThinking(thinkingChan)
+// message builds the reply: the function calls when there are any (a batch
+// for parallel calls), the text otherwise. Each call keeps its thought
+// signature for the next request.
+func (r *responseCollector) message() (message.Message, error) {
+	thinking := r.thinking.String()
+	if thinking != "" && r.thinkingChan != nil {
+		message.EndThinking(r.thinkingChan)
 	}
 
-	// Check if we have tool calls to return (only when tool handling is enabled)
-	if handleTools && len(toolCalls) > 0 {
-		// Build messages for all collected function calls
-		if len(toolCalls) == 1 {
-			fc := toolCalls[0]
-			args := convertGeminiArgsToToolArgs(convertToolArgsToJSON(fc.Args))
-			return message.NewToolCallMessage(message.ToolName(fc.Name), args), nil
+	if len(r.calls) > 0 {
+		calls := make([]*message.ToolCallMessage, 0, len(r.calls))
+		for i, part := range r.calls {
+			name := message.ToolName(part.FunctionCall.Name)
+			args := toToolArgs(part.FunctionCall.Args)
+			call := message.NewToolCallMessage(name, args)
+			if i == 0 && thinking != "" {
+				call = message.NewToolCallMessageWithThinking(name, args, thinking)
+			}
+			if len(part.ThoughtSignature) > 0 {
+				call.SetMetadata(thoughtSignatureKey, base64.StdEncoding.EncodeToString(part.ThoughtSignature))
+			}
+			calls = append(calls, call)
 		}
-		calls := make([]*message.ToolCallMessage, 0, len(toolCalls))
-		for _, fc := range toolCalls {
-			args := convertGeminiArgsToToolArgs(convertToolArgsToJSON(fc.Args))
-			calls = append(calls, message.NewToolCallMessage(message.ToolName(fc.Name), args))
+		if len(calls) == 1 {
+			return calls[0], nil
 		}
 		return message.NewToolCallBatch(calls), nil
 	}
 
-	finalText := responseText.String()
-	if finalText == "" {
-		return nil, fmt.Errorf("empty response from Gemini streaming")
+	text := r.text.String()
+	if text == "" {
+		switch {
+		case r.blocked != "":
+			return nil, fmt.Errorf("Gemini blocked the prompt: %s", r.blocked)
+		case r.finish != "" && r.finish != genai.FinishReasonStop:
+			return nil, fmt.Errorf("empty response from Gemini (finish reason %s)", r.finish)
+		}
+		return nil, fmt.Errorf("empty response from Gemini")
 	}
 
-	// If we have thinking content, create message with thinking
-	if thinkingText.Len() > 0 {
-		return message.NewChatMessageWithThinking(
-			message.MessageTypeAssistant,
-			finalText,
-			thinkingText.String(),
-		), nil
+	if thinking != "" {
+		return message.NewChatMessageWithThinking(message.MessageTypeAssistant, text, thinking), nil
 	}
-
-	return message.NewChatMessage(message.MessageTypeAssistant, finalText), nil
+	return message.NewChatMessage(message.MessageTypeAssistant, text), nil
 }
 
 // SetToolManager implements ToolCallingLLM interface
@@ -308,168 +339,36 @@ func (c *GeminiClient) GetToolManager() domain.ToolManager {
 
 // IsToolCapable checks if the Gemini client supports native tool calling
 func (c *GeminiClient) IsToolCapable() bool {
-	// All Gemini 1.5+ and 2.0+ models support function calling
-	return strings.Contains(c.model, "gemini-1.5") ||
-		strings.Contains(c.model, "gemini-2.0") ||
-		strings.Contains(c.model, "gemini-2.5")
+	return getModelCapabilities(c.model).SupportsToolCalling
 }
 
-// ChatWithToolChoice implements ToolCallingLLM interface with tool manager integration
+// ChatWithToolChoice implements ToolCallingLLM interface with tool manager
+// integration. Gemini may answer with several function calls at once; they
+// come back as a batch.
 func (c *GeminiClient) ChatWithToolChoice(ctx context.Context, messages []message.Message, toolChoice domain.ToolChoice, enableThinking bool, thinkingChan chan<- string) (message.Message, error) {
-	// Convert internal messages to Gemini format
-	geminiContents := make([]*genai.Content, 0)
-	var systemInstruction *genai.Content
-
-	for _, msg := range messages {
-		switch msg.Type() {
-		case message.MessageTypeUser:
-			// Handle images if present
-			if images := msg.Images(); len(images) > 0 {
-				// Create parts with both text and images
-				parts := []*genai.Part{}
-				if content := msg.Content(); content != "" {
-					parts = append(parts, &genai.Part{Text: content})
-				}
-				// TODO: Implement image handling with proper base64 decoding
-				// For now, just add text content
-				geminiContents = append(geminiContents, genai.NewContentFromParts(parts, genai.RoleUser))
-			} else {
-				geminiContents = append(geminiContents, genai.NewContentFromText(msg.Content(), genai.RoleUser))
-			}
-
-		case message.MessageTypeAssistant:
-			// Add assistant messages as context
-			geminiContents = append(geminiContents, genai.NewContentFromText(msg.Content(), genai.RoleModel))
-
-		case message.MessageTypeSystem:
-			// Use the last system message as system instruction
-			systemInstruction = genai.NewContentFromText(msg.Content(), genai.RoleUser)
-
-		case message.MessageTypeToolCall:
-			// For tool calls, represent as assistant function calls
-			if toolCallMsg, ok := msg.(interface {
-				ToolName() message.ToolName
-				ToolArguments() message.ToolArgumentValues
-			}); ok {
-				argsJSON := convertToolArgsToJSON(toolCallMsg.ToolArguments())
-				toolCallText := "[Function call: " + string(toolCallMsg.ToolName()) + "(" + argsJSON + ")]"
-				geminiContents = append(geminiContents, genai.NewContentFromText(toolCallText, genai.RoleModel))
-			}
-
-		case message.MessageTypeToolResult:
-			// Represent tool results as user messages
-			if toolResultMsg, ok := msg.(interface{ Content() string }); ok {
-				resultText := "[Function result: " + toolResultMsg.Content() + "]"
-				geminiContents = append(geminiContents, genai.NewContentFromText(resultText, genai.RoleUser))
-			}
-
-		case message.MessageTypeToolCallBatch:
-			// Skip batch containers; individual calls/results are already in the transcript
-			continue
-		}
-	}
-
-	// Prepare configuration
-	config := &genai.GenerateContentConfig{
-		MaxOutputTokens: int32(c.maxTokens),
-	}
-	if systemInstruction != nil {
-		config.SystemInstruction = systemInstruction
-	}
+	caps := getModelCapabilities(c.model)
 
 	// Add tools from tool manager if available
+	var tools []*genai.Tool
 	if c.toolManager != nil {
-		tools := convertToolsToGemini(c.toolManager.GetTools())
-		if len(tools) > 0 {
-			// Add tools to config
-			config.Tools = tools
-
-			// Set native tool choice using ToolConfig and FunctionCallingConfig
-			toolConfig := convertToolChoiceToGemini(toolChoice, tools)
-			if toolConfig != nil {
-				config.ToolConfig = toolConfig
-			}
-		}
-	}
-
-	// Enable thinking for tool calling as well
-	if c.isThinkingCapable() {
-		config.ThinkingConfig = &genai.ThinkingConfig{
-			IncludeThoughts: true,
-		}
-
-		// Use streaming for progressive thinking display with tool handling enabled
-		return c.chatWithStreaming(ctx, geminiContents, config, true, true, enableThinking, thinkingChan)
+		tools = convertToolsToGemini(c.toolManager.GetTools())
 	}
 
-	// Generate content using the Models interface (non-streaming)
-	resp, err := c.client.Models.GenerateContent(ctx, c.model, geminiContents, config)
-	if err != nil {
-		return nil, fmt.Errorf("Gemini API call failed: %w", err)
-	}
+	system, contents := toGeminiContents(messages, caps, len(tools) > 0)
 
-	// Debug: Log token usage from Gemini API response
-	if resp.UsageMetadata != nil {
-		maxTokens := c.maxTokens
-		inputTokens := resp.UsageMetadata.PromptTokenCount
-		outputTokens := resp.UsageMetadata.CandidatesTokenCount
-		totalTokens := resp.UsageMetadata.TotalTokenCount
-		utilizationPct := float64(outputTokens) / float64(maxTokens) * 100
-
-		fmt.Printf("DEBUG: Gemini API Usage - Input: %d tokens, Output: %d tokens, Total: %d tokens, Model: %s\n",
-			inputTokens, outputTokens, totalTokens, c.model)
-		fmt.Printf("DEBUG: Token Utilization - %.1f%% of max output tokens (%d/%d)\n",
-			utilizationPct, outputTokens, maxTokens)
-
-		// Warn if we're approaching the limit
-		if utilizationPct > 90 {
-			fmt.Printf("⚠️  WARNING: Very high token usage (%.1f%%) - potential truncation risk!\n", utilizationPct)
-		} else if utilizationPct > 80 {
-			fmt.Printf("⚠️  WARNING: High token usage (%.1f%%) - approaching limit\n", utilizationPct)
-		}
-	}
-
-	// Capture token usage if available
-	if resp.UsageMetadata != nil {
-		c.lastUsage = message.TokenUsage{
-			InputTokens:  int(resp.UsageMetadata.PromptTokenCount),
-			OutputTokens: int(resp.UsageMetadata.CandidatesTokenCount),
-			TotalTokens:  int(resp.UsageMetadata.TotalTokenCount),
-		}
-	}
-
-	if len(resp.Candidates) == 0 {
-		return nil, fmt.Errorf("no response from Gemini")
-	}
-
-	// Check if response contains function calls (collect all)
-	if len(resp.Candidates) > 0 && resp.Candidates[0].Content != nil && len(resp.Candidates[0].Content.Parts) > 0 {
-		var calls []*message.ToolCallMessage
-		for _, part := range resp.Candidates[0].Content.Parts {
-			if functionCall := part.FunctionCall; functionCall != nil {
-				args := convertGeminiArgsToToolArgs(convertToolArgsToJSON(functionCall.Args))
-				calls = append(calls, message.NewToolCallMessage(message.ToolName(functionCall.Name), args))
-			}
-		}
-		if len(calls) == 1 {
-			return calls[0], nil
-		} else if len(calls) > 1 {
-			return message.NewToolCallBatch(calls), nil
-		}
-	}
-
-	// Extract text content from response
-	responseText := resp.Text()
-	if responseText == "" {
-		return nil, fmt.Errorf("empty response from Gemini")
+	config := c.newConfig(caps, enableThinking)
+	config.SystemInstruction = system
+	if len(tools) > 0 {
+		config.Tools = tools
+		// Set native tool choice using ToolConfig and FunctionCallingConfig
+		config.ToolConfig = convertToolChoiceToGemini(toolChoice, tools)
 	}
 
-	return message.NewChatMessage(message.MessageTypeAssistant, responseText), nil
+	// Stream on thinking models so thoughts show progressively
+	return c.generate(ctx, contents, config, caps.IsReasoningModel, true, enableThinking, thinkingChan)
 }
 
 // SupportsVision implements VisionLLM interface
 func (c *GeminiClient) SupportsVision() bool {
-	// Gemini Pro Vision models support vision
-	return strings.Contains(c.model, "vision") || strings.Contains(c.model, "gemini-pro-vision") ||
-		strings.Contains(c.model, "gemini-2.0") || strings.Contains(c.model, "gemini-1.5")
+	return getModelCapabilities(c.model).SupportsVision
 }
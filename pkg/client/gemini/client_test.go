@@ -0,0 +1,389 @@
+package gemini
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/genai"
+
+	"github.com/fpt/klein-cli/pkg/agent/domain"
+	"github.com/fpt/klein-cli/pkg/message"
+)
+
+// fakeGemini is a scripted Gemini API. Generate calls, streaming or not, take
+// the next scripted reply; cachedContents calls are answered on their own.
+type fakeGemini struct {
+	*httptest.Server
+	mu       sync.Mutex
+	replies  []fakeReply
+	requests []fakeRequest
+	caches   int
+}
+
+// fakeReply is one scripted generate answer: chunks of a streamed response
+// (merged into one for a non-streaming call), or an error status.
+type fakeReply struct {
+	status int
+	chunks []string
+}
+
+type fakeRequest struct {
+	method, path string
+	body         map[string]any
+}
+
+func newFakeGemini(t *testing.T, replies ...fakeReply) *fakeGemini {
+	t.Helper()
+	t.Setenv("GEMINI_API_KEY", "test-key")
+	f := &fakeGemini{replies: replies}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeGemini) serve(w http.ResponseWriter, r *http.Request) {
+	raw, _ := io.ReadAll(r.Body)
+	var body map[string]any
+	_ = json.Unmarshal(raw, &body)
+
+	f.mu.Lock()
+	f.requests = append(f.requests, fakeRequest{method: r.Method, path: r.URL.Path, body: body})
+	w.Header().Set("Content-Type", "application/json")
+
+	if strings.Contains(r.URL.Path, "cachedContents") {
+		if r.Method == http.MethodPost {
+			f.caches++
+		}
+		name := fmt.Sprintf("cachedContents/c%d", f.caches)
+		f.mu.Unlock()
+		fmt.Fprintf(w, `{"name":%q,"expireTime":%q}`, name, time.Now().Add(contextCacheTTL).Format(time.RFC3339))
+		return
+	}
+
+	if len(f.replies) == 0 {
+		f.mu.Unlock()
+		http.Error(w, `{"error":{"code":500,"message":"script exhausted"}}`, http.StatusInternalServerError)
+		return
+	}
+	reply := f.replies[0]
+	f.replies = f.replies[1:]
+	f.mu.Unlock()
+
+	switch {
+	case reply.status != 0:
+		w.WriteHeader(reply.status)
+		fmt.Fprintf(w, `{"error":{"code":%d,"message":"scripted failure for cached content","status":"NOT_FOUND"}}`, reply.status)
+	case strings.Contains(r.URL.Path, ":streamGenerateContent"):
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, c := range reply.chunks {
+			fmt.Fprintf(w, "data: %s\n\n", c)
+		}
+	default:
+		// Only single-chunk replies are scripted for non-streaming calls.
+		fmt.Fprint(w, reply.chunks[0])
+	}
+}
+
+// generateRequests returns the bodies of the generate calls made so far.
+func (f *fakeGemini) generateRequests() []map[string]any {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []map[string]any
+	for _, r := range f.requests {
+		if strings.Contains(r.path, "GenerateContent") || strings.Contains(r.path, "generateContent") {
+			out = append(out, r.body)
+		}
+	}
+	return out
+}
+
+// cacheRequests returns "METHOD path" for each cachedContents call.
+func (f *fakeGemini) cacheRequests() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []string
+	for _, r := range f.requests {
+		if strings.Contains(r.path, "cachedContents") {
+			out = append(out, r.method+" "+r.path)
+		}
+	}
+	return out
+}
+
+func reply(chunks ...string) fakeReply { return fakeReply{chunks: chunks} }
+
+func textReply(text string) fakeReply {
+	return reply(fmt.Sprintf(`{"candidates":[{"content":{"role":"model","parts":[{"text":%q}]},"finishReason":"STOP"}],`+
+		`"usageMetadata":{"promptTokenCount":10,"candidatesTokenCount":2,"totalTokenCount":12}}`, text))
+}
+
+func newFakeClient(t *testing.T, f *fakeGemini, model, effort string) *GeminiClient {
+	t.Helper()
+	c, err := NewGeminiClientWithHTTPOptions(model, 0, effort, genai.HTTPOptions{BaseURL: f.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+type stubTool struct{ name string }
+
+func (s stubTool) RawName() message.ToolName { return message.ToolName(s.name) }
+func (s stubTool) Name() message.ToolName    { return message.ToolName(s.name) }
+func (s stubTool) Description() message.ToolDescription {
+	return message.ToolDescription("Test tool " + s.name)
+}
+func (s stubTool) Arguments() []message.ToolArgument {
+	return []message.ToolArgument{{
+		Name: "paths", Type: "array", Required: true, Description: "Files to read",
+		Properties: map[string]any{"items": map[string]any{"type": "string"}, "maxItems": 5},
+	}}
+}
+func (s stubTool) Handler() func(context.Context, message.ToolArgumentValues) (message.ToolResult, error) {
+	return nil
+}
+
+type stubTools map[message.ToolName]message.Tool
+
+func (m stubTools) GetTools() map[message.ToolName]message.Tool { return m }
+func (m stubTools) CallTool(context.Context, message.ToolName, message.ToolArgumentValues) (message.ToolResult, error) {
+	return message.ToolResult{}, nil
+}
+func (m stubTools) RegisterTool(message.ToolName, message.ToolDescription, []message.ToolArgument, func(context.Context, message.ToolArgumentValues) (message.ToolResult, error)) {
+}
+
+// path digs into decoded JSON: keys for objects, ints for arrays.
+func path(v any, steps ...any) any {
+	for _, s := range steps {
+		switch k := s.(type) {
+		case string:
+			m, _ := v.(map[string]any)
+			v = m[k]
+		case int:
+			a, _ := v.([]any)
+			if k >= len(a) {
+				return nil
+			}
+			v = a[k]
+		}
+	}
+	return v
+}
+
+func TestParallelFunctionCallsRoundTrip(t *testing.T) {
+	f := newFakeGemini(t,
+		reply(
+			`{"candidates":[{"content":{"role":"model","parts":[{"text":"Read both.","thought":true}]}}]}`,
+			`{"candidates":[{"content":{"role":"model","parts":[`+
+				`{"functionCall":{"name":"Read","args":{"paths":["a.go"]}},"thoughtSignature":"c2lnMQ=="},`+
+				`{"functionCall":{"name":"Read","args":{"paths":["b.go"]}}}]},"finishReason":"STOP"}],`+
+				`"usageMetadata":{"promptTokenCount":40,"candidatesTokenCount":8,"thoughtsTokenCount":30,"totalTokenCount":78}}`,
+		),
+		reply(`{"candidates":[{"content":{"role":"model","parts":[{"text":"Both read."}]},"finishReason":"STOP"}],`+
+			`"usageMetadata":{"promptTokenCount":120,"cachedContentTokenCount":100,"candidatesTokenCount":3,"totalTokenCount":123}}`),
+	)
+	c := newFakeClient(t, f, "gemini-3-flash-preview", "low")
+	c.SetToolManager(stubTools{"Read": stubTool{"Read"}, "Bash": stubTool{"Bash"}})
+
+	history := []message.Message{
+		message.NewSystemMessage("You are a code assistant."),
+		message.NewChatMessage(message.MessageTypeUser, "Compare a.go and b.go"),
+	}
+	thoughts := make(chan string, 8)
+	resp, err := c.ChatWithToolChoice(context.Background(), history, domain.ToolChoice{Type: domain.ToolChoiceAuto}, true, thoughts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	batch, ok := resp.(*message.ToolCallBatchMessage)
+	if !ok || len(batch.Calls()) != 2 {
+		t.Fatalf("want a batch of two calls, got %T %v", resp, resp)
+	}
+	first, second := batch.Calls()[0], batch.Calls()[1]
+	if first.Thinking() != "Read both." || first.Metadata()[thoughtSignatureKey] != "c2lnMQ==" {
+		t.Errorf("first call: thinking %q, signature %v", first.Thinking(), first.Metadata()[thoughtSignatureKey])
+	}
+	if _, ok := second.Metadata()[thoughtSignatureKey]; ok {
+		t.Error("second call should have no signature of its own")
+	}
+	if got := <-thoughts; got != "Read both." {
+		t.Errorf("thinking channel got %q", got)
+	}
+	if usage, _ := c.LastTokenUsage(); usage.OutputTokens != 38 {
+		t.Errorf("output tokens = %d, want candidates+thoughts 38", usage.OutputTokens)
+	}
+
+	thinkingCfg := path(f.generateRequests()[0], "generationConfig", "thinkingConfig")
+	if path(thinkingCfg, "thinkingLevel") != "LOW" || path(thinkingCfg, "includeThoughts") != true {
+		t.Errorf("thinking config = %v, want LOW with thoughts", thinkingCfg)
+	}
+	decls := path(f.generateRequests()[0], "tools", 0, "functionDeclarations").([]any)
+	if path(decls, 0, "name") != "Bash" || path(decls, 1, "name") != "Read" {
+		t.Errorf("declarations not sorted by name: %v", decls)
+	}
+	if path(decls, 1, "parametersJsonSchema", "properties", "paths", "items", "type") != "string" {
+		t.Errorf("explicit argument schema lost: %v", path(decls, 1, "parametersJsonSchema"))
+	}
+
+	// Answer both calls and send the history back.
+	history = append(history, first, second,
+		message.NewToolResultMessage(first.ID(), "package a", ""),
+		message.NewToolResultMessage(second.ID(), "", "no such file"))
+	resp, err = c.ChatWithToolChoice(context.Background(), history, domain.ToolChoice{Type: domain.ToolChoiceAuto}, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content() != "Both read." {
+		t.Errorf("reply = %q", resp.Content())
+	}
+
+	req := f.generateRequests()[1]
+	if path(req, "systemInstruction", "parts", 0, "text") != "You are a code assistant." {
+		t.Errorf("system instruction = %v", path(req, "systemInstruction"))
+	}
+	contents := path(req, "contents").([]any)
+	if len(contents) != 3 {
+		t.Fatalf("want user, model, user turns; got %d: %v", len(contents), contents)
+	}
+	calls := path(contents, 1, "parts").([]any)
+	if len(calls) != 2 || path(calls, 0, "thoughtSignature") != "c2lnMQ==" || path(calls, 1, "thoughtSignature") != nil {
+		t.Errorf("model turn = %v", calls)
+	}
+	results := path(contents, 2, "parts").([]any)
+	if path(results, 0, "functionResponse", "response", "output") != "package a" ||
+		path(results, 1, "functionResponse", "response", "error") != "no such file" ||
+		path(results, 1, "functionResponse", "name") != "Read" {
+		t.Errorf("function responses = %v", results)
+	}
+
+	usage, ok := c.LastTokenUsage()
+	if !ok || usage.CachedTokens != 100 || usage.InputTokens != 120 {
+		t.Errorf("usage = %+v, want 100 of 120 input tokens cached", usage)
+	}
+	if got := f.cacheRequests(); len(got) != 0 {
+		t.Errorf("a short prefix should not be cached: %v", got)
+	}
+}
+
+func TestForeignFunctionCallsGetPlaceholderSignature(t *testing.T) {
+	// Calls made by another backend carry no Gemini signature; Gemini 3
+	// rejects them unless the first of a turn has the documented placeholder.
+	a := message.NewToolCallMessage("Read", message.ToolArgumentValues{"path": "a.go"})
+	b := message.NewToolCallMessage("Read", message.ToolArgumentValues{"path": "b.go"})
+	history := []message.Message{
+		message.NewChatMessage(message.MessageTypeUser, "read"),
+		a, b,
+		message.NewToolResultMessage(a.ID(), "A", ""),
+		message.NewToolResultMessage(b.ID(), "B", ""),
+	}
+
+	_, contents := toGeminiContents(history, getModelCapabilities(modelGemini3Pro), true)
+	parts := contents[1].Parts
+	if string(parts[0].ThoughtSignature) != string(skipThoughtSignature) || parts[1].ThoughtSignature != nil {
+		t.Errorf("signatures = %q, %q", parts[0].ThoughtSignature, parts[1].ThoughtSignature)
+	}
+	wire, _ := json.Marshal(parts[0])
+	if !strings.Contains(string(wire), `"thoughtSignature":"skip/thought/signature/validator"`) {
+		t.Errorf("placeholder on the wire: %s", wire)
+	}
+
+	_, contents = toGeminiContents(history, getModelCapabilities(modelGemini25Flash), true)
+	if contents[1].Parts[0].ThoughtSignature != nil {
+		t.Error("Gemini 2.5 does not need a placeholder")
+	}
+}
+
+func TestContextCacheForStablePrefix(t *testing.T) {
+	f := newFakeGemini(t,
+		textReply("one"), textReply("two"), textReply("three"),
+		fakeReply{status: http.StatusNotFound}, textReply("four"),
+	)
+	c := newFakeClient(t, f, "gemini-2.5-flash", "")
+	now := time.Now()
+	c.cache.now = func() time.Time { return now }
+
+	history := []message.Message{
+		message.NewSystemMessage(strings.Repeat("Project rules. ", 400)),
+		message.NewChatMessage(message.MessageTypeUser, "hi"),
+	}
+	chat := func(want string) map[string]any {
+		t.Helper()
+		resp, err := c.Chat(context.Background(), history, false, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Content() != want {
+			t.Fatalf("reply = %q, want %q", resp.Content(), want)
+		}
+		reqs := f.generateRequests()
+		return reqs[len(reqs)-1]
+	}
+
+	req := chat("one")
+	if req["cachedContent"] != "cachedContents/c1" || req["systemInstruction"] != nil {
+		t.Errorf("first call should use the new cache instead of the inline prefix: %v", req)
+	}
+	chat("two")
+	if got := f.cacheRequests(); len(got) != 1 {
+		t.Errorf("the cache should be reused, got %v", got)
+	}
+
+	// Past half its TTL the cache is extended rather than recreated.
+	now = now.Add(contextCacheTTL * 3 / 4)
+	chat("three")
+	if got := f.cacheRequests(); len(got) != 2 || !strings.HasPrefix(got[1], "PATCH ") {
+		t.Errorf("want a refresh, got %v", got)
+	}
+
+	// A cache that vanished server-side is dropped and the call made inline.
+	req = chat("four")
+	if req["cachedContent"] != nil || path(req, "systemInstruction", "parts", 0, "text") == nil {
+		t.Errorf("retry should carry the prefix inline: %v", req)
+	}
+	if len(c.cache.entries) != 0 {
+		t.Errorf("stale cache kept: %v", c.cache.entries)
+	}
+}
+
+func TestContextCacheCanBeTurnedOff(t *testing.T) {
+	f := newFakeGemini(t, textReply("ok"))
+	c := newFakeClient(t, f, "gemini-2.5-flash", "")
+	c.ConfigureModelSideCache(domain.ModelSideCacheOptions{PromptCachingEnabled: false})
+
+	history := []message.Message{
+		message.NewSystemMessage(strings.Repeat("Project rules. ", 400)),
+		message.NewChatMessage(message.MessageTypeUser, "hi"),
+	}
+	if _, err := c.Chat(context.Background(), history, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.cacheRequests(); len(got) != 0 {
+		t.Errorf("caching is off, got %v", got)
+	}
+}
+
+func TestLaterSystemMessagesStayInPlace(t *testing.T) {
+	history := []message.Message{
+		message.NewSystemMessage("role prompt"),
+		message.NewSystemMessage("skill catalog"),
+		message.NewChatMessage(message.MessageTypeUser, "hi"),
+		message.NewChatMessage(message.MessageTypeAssistant, "hello"),
+		message.NewSituationSystemMessage("iteration 5 of 20"),
+		message.NewChatMessage(message.MessageTypeUser, "go on"),
+	}
+	system, contents := toGeminiContents(history, getModelCapabilities(modelGemini25Flash), false)
+	if len(system.Parts) != 2 || system.Parts[1].Text != "skill catalog" {
+		t.Errorf("system instruction = %v", system.Parts)
+	}
+	if len(contents) != 3 || contents[2].Parts[0].Text != "System: iteration 5 of 20" || contents[2].Parts[1].Text != "go on" {
+		t.Errorf("contents = %+v", contents)
+	}
+}
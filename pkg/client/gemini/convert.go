@@ -0,0 +1,177 @@
+package gemini
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"google.golang.org/genai"
+
+	"github.com/fpt/klein-cli/pkg/message"
+)
+
+// thoughtSignatureKey is the metadata key under which a tool call keeps the
+// thought signature Gemini attached to its functionCall part. The signature
+// has to go back with the call for the model to resume its reasoning.
+const thoughtSignatureKey = "gemini_thought_signature"
+
+// skipThoughtSignature is the placeholder Google documents for function calls
+// that did not come from Gemini (another backend, an imported session, a
+// session restored from disk). Gemini 3 rejects a replayed call without any
+// signature; this one tells it to skip the check. The documented value is the
+// JSON (base64) form, so these are its decoded bytes.
+var skipThoughtSignature, _ = base64.URLEncoding.DecodeString("skip_thought_signature_validator")
+
+// toGeminiContents converts a conversation to Gemini's format.
+//
+// The system messages before the first turn become the system instruction:
+// that is the stable role prompt, skill catalog and project context, and the
+// prefix worth caching. A system message further down (a situation note, a
+// compaction summary) stays where it is as a user turn, as it does for
+// Anthropic.
+//
+// With functionParts, tool calls and results become functionCall and
+// functionResponse parts. Consecutive calls share one model turn and their
+// results one user turn, which is how Gemini expects parallel calls to be
+// answered. Without it (a request that declares no tools) they are kept as
+// text.
+func toGeminiContents(messages []message.Message, caps ModelCapabilities, functionParts bool) (*genai.Content, []*genai.Content) {
+	var (
+		system   []*genai.Part
+		contents []*genai.Content
+		calls    = map[string]string{} // tool-call message ID → function name
+		leading  = true
+	)
+
+	// add appends parts to the last turn when it has the same role.
+	add := func(role string, parts ...*genai.Part) {
+		if len(parts) == 0 {
+			return
+		}
+		if n := len(contents); n > 0 && contents[n-1].Role == role {
+			contents[n-1].Parts = append(contents[n-1].Parts, parts...)
+			return
+		}
+		contents = append(contents, &genai.Content{Role: role, Parts: parts})
+	}
+
+	for _, msg := range messages {
+		if msg.Type() == message.MessageTypeSystem {
+			if leading {
+				system = append(system, &genai.Part{Text: msg.Content()})
+			} else {
+				add(genai.RoleUser, &genai.Part{Text: "System: " + msg.Content()})
+			}
+			continue
+		}
+		leading = false
+
+		switch msg.Type() {
+		case message.MessageTypeUser:
+			parts := imageParts(msg.Images())
+			if msg.Content() != "" || len(parts) == 0 {
+				parts = append(parts, &genai.Part{Text: msg.Content()})
+			}
+			add(genai.RoleUser, parts...)
+
+		case message.MessageTypeAssistant:
+			if msg.Content() != "" {
+				add(genai.RoleModel, &genai.Part{Text: msg.Content()})
+			}
+
+		case message.MessageTypeToolCall:
+			call, ok := msg.(*message.ToolCallMessage)
+			if !ok {
+				continue
+			}
+			if !functionParts {
+				add(genai.RoleModel, &genai.Part{Text: "[Function call: " + string(call.ToolName()) + "(" + argsJSON(call.ToolArguments()) + ")]"})
+				continue
+			}
+			calls[call.ID()] = string(call.ToolName())
+			part := &genai.Part{FunctionCall: &genai.FunctionCall{
+				Name: string(call.ToolName()),
+				Args: call.ToolArguments(),
+			}}
+			if sig, _ := call.Metadata()[thoughtSignatureKey].(string); sig != "" {
+				part.ThoughtSignature, _ = base64.StdEncoding.DecodeString(sig)
+			}
+			// Only the first call of a turn carries a signature.
+			if part.ThoughtSignature == nil && caps.RequiresThoughtSignatures && !lastTurnHasCalls(contents) {
+				part.ThoughtSignature = skipThoughtSignature
+			}
+			add(genai.RoleModel, part)
+
+		case message.MessageTypeToolResult:
+			result, ok := msg.(*message.ToolResultMessage)
+			if !ok {
+				continue
+			}
+			name, ok := calls[result.ID()]
+			if !ok {
+				// Without function parts, or when the call is gone
+				// (compacted away), the result is kept as text.
+				add(genai.RoleUser, &genai.Part{Text: "[Function result: " + result.Content() + "]"})
+				continue
+			}
+			response := map[string]any{"output": result.Result}
+			if result.Error != "" {
+				response = map[string]any{"error": result.Error}
+			}
+			parts := []*genai.Part{{FunctionResponse: &genai.FunctionResponse{Name: name, Response: response}}}
+			add(genai.RoleUser, append(parts, imageParts(result.Images())...)...)
+
+		case message.MessageTypeToolCallBatch:
+			// The calls of a batch are in the transcript one by one.
+			continue
+		}
+	}
+
+	if len(system) == 0 {
+		return nil, contents
+	}
+	return &genai.Content{Role: genai.RoleUser, Parts: system}, contents
+}
+
+// lastTurnHasCalls reports whether the latest turn is a model turn that
+// already holds a function call.
+func lastTurnHasCalls(contents []*genai.Content) bool {
+	if len(contents) == 0 || contents[len(contents)-1].Role != genai.RoleModel {
+		return false
+	}
+	for _, p := range contents[len(contents)-1].Parts {
+		if p.FunctionCall != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// imageParts decodes base64 images into inline data parts, skipping any that
+// do not decode.
+func imageParts(images []string) []*genai.Part {
+	var parts []*genai.Part
+	for _, img := range images {
+		data, err := base64.StdEncoding.DecodeString(img)
+		if err != nil {
+			geminiLogger.Warn("Skipping image that is not valid base64", "error", err)
+			continue
+		}
+		mime := http.DetectContentType(data)
+		if !strings.HasPrefix(mime, "image/") {
+			mime = "image/jpeg"
+		}
+		parts = append(parts, &genai.Part{InlineData: &genai.Blob{MIMEType: mime, Data: data}})
+	}
+	return parts
+}
+
+// argsJSON renders tool arguments for a text transcript.
+func argsJSON(args message.ToolArgumentValues) string {
+	b, err := json.Marshal(args)
+	if err != nil || len(args) == 0 {
+		return "{}"
+	}
+	return string(b)
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/fpt/klein-cli/pkg/agent/domain"
 	"github.com/fpt/klein-cli/pkg/message"
@@ -42,7 +43,8 @@ func (c *GeminiStructuredClient[T]) Chat(ctx context.Context, messages []message
 	return message.NewChatMessage(message.MessageTypeAssistant, string(jsonBytes)), nil
 }
 
-// ChatWithStructure implements StructuredLLM interface using Gemini's native structured output
+// ChatWithStructure implements StructuredLLM interface using Gemini's native
+// structured output: the reply is constrained to the schema of T.
 func (c *GeminiStructuredClient[T]) ChatWithStructure(ctx context.Context, messages []message.Message, enableThinking bool, thinkingChan chan<- string) (T, error) {
 	var zero T
 
@@ -52,47 +54,44 @@ func (c *GeminiStructuredClient[T]) ChatWithStructure(ctx context.Context, messa
 		return zero, fmt.Errorf("failed to generate Gemini schema: %w", err)
 	}
 
-	// Convert messages to Gemini format
-	geminiContents, systemInstruction := c.convertMessagesToGemini(messages)
+	caps := getModelCapabilities(c.core.model)
+	systemInstruction, geminiContents := toGeminiContents(messages, caps, false)
 
-	// Create structured output configuration
-	config := &genai.GenerateContentConfig{
-		ResponseMIMEType: "application/json",
-		ResponseSchema:   geminiSchema,
-	}
-
-	// Add system instruction if present
-	if systemInstruction != nil {
-		config.SystemInstruction = systemInstruction
-	}
-
-	// Add thinking parameter if supported and enabled
-	if enableThinking && c.isThinkingCapable() {
-		config.ThinkingConfig = &genai.ThinkingConfig{
-			IncludeThoughts: true,
-		}
-	}
+	config := c.core.newConfig(caps, enableThinking)
+	config.SystemInstruction = systemInstruction
+	config.ResponseMIMEType = "application/json"
+	config.ResponseSchema = geminiSchema
 
-	// Generate content using Gemini's structured output
-	result, err := c.core.client.Models.GenerateContent(
-		ctx,
-		c.core.model,
-		geminiContents,
-		config,
-	)
+	msg, err := c.core.generate(ctx, geminiContents, config, false, false, enableThinking, thinkingChan)
 	if err != nil {
 		return zero, fmt.Errorf("gemini generate content failed: %w", err)
 	}
 
 	// Parse the JSON response into the target type
 	var parsedResult T
-	if err := json.Unmarshal([]byte(result.Text()), &parsedResult); err != nil {
+	if err := json.Unmarshal([]byte(msg.Content()), &parsedResult); err != nil {
 		return zero, fmt.Errorf("failed to unmarshal structured response: %w", err)
 	}
 
 	return parsedResult, nil
 }
 
+// ChatWithJSONSchema asks for a reply that is JSON conforming to schema, a
+// JSON Schema object, through Gemini's native responseJsonSchema rather than
+// a forced tool call. The reply is the JSON text; validating it is the
+// caller's job.
+func (c *GeminiClient) ChatWithJSONSchema(ctx context.Context, messages []message.Message, schema map[string]any) (message.Message, error) {
+	caps := getModelCapabilities(c.model)
+	systemInstruction, contents := toGeminiContents(messages, caps, false)
+
+	config := c.newConfig(caps, false)
+	config.SystemInstruction = systemInstruction
+	config.ResponseMIMEType = "application/json"
+	config.ResponseJsonSchema = schema
+
+	return c.generate(ctx, contents, config, false, false, false, nil)
+}
+
 // IsToolCapable returns false since this client uses native structured output, not tools
 func (c *GeminiStructuredClient[T]) IsToolCapable() bool {
 	return false
@@ -122,7 +121,7 @@ func (c *GeminiStructuredClient[T]) generateGeminiSchema(structType reflect.Type
 		Properties: make(map[string]*genai.Schema),
 	}
 
-	var propertyOrdering []string
+	var propertyOrdering, required []string
 
 	// Process struct fields
 	for i := 0; i < structType.NumField(); i++ {
@@ -152,6 +151,9 @@ func (c *GeminiStructuredClient[T]) generateGeminiSchema(structType reflect.Type
 		if err != nil {
 			return nil, fmt.Errorf("failed to generate schema for field %s: %w", fieldName, err)
 		}
+		if applyJSONSchemaTag(fieldSchema, field) {
+			required = append(required, fieldName)
+		}
 
 		schema.Properties[fieldName] = fieldSchema
 		propertyOrdering = append(propertyOrdering, fieldName)
@@ -159,15 +161,25 @@ func (c *GeminiStructuredClient[T]) generateGeminiSchema(structType reflect.Type
 
 	// Set property ordering for consistent JSON output
 	schema.PropertyOrdering = propertyOrdering
+	schema.Required = required
 
 	return schema, nil
 }
 
 // generateFieldSchema generates a Gemini schema for a struct field
 func (c *GeminiStructuredClient[T]) generateFieldSchema(fieldType reflect.Type) (*genai.Schema, error) {
-	// Handle pointers
+	// Handle pointers: the field may be null
 	if fieldType.Kind() == reflect.Ptr {
-		fieldType = fieldType.Elem()
+		schema, err := c.generateFieldSchema(fieldType.Elem())
+		if err != nil {
+			return nil, err
+		}
+		schema.Nullable = genai.Ptr(true)
+		return schema, nil
+	}
+
+	if fieldType == reflect.TypeFor[time.Time]() {
+		return &genai.Schema{Type: genai.TypeString, Format: "date-time"}, nil
 	}
 
 	switch fieldType.Kind() {
@@ -207,39 +219,32 @@ func (c *GeminiStructuredClient[T]) generateFieldSchema(fieldType reflect.Type)
 
 // convertMessagesToGemini converts internal messages to Gemini format
 func (c *GeminiStructuredClient[T]) convertMessagesToGemini(messages []message.Message) ([]*genai.Content, *genai.Content) {
-	geminiContents := make([]*genai.Content, 0)
-	var systemInstruction *genai.Content
-
-	for _, msg := range messages {
-		switch msg.Type() {
-		case message.MessageTypeUser:
-			// Handle images if present
-			if images := msg.Images(); len(images) > 0 {
-				// Create parts with both text and images
-				parts := []*genai.Part{}
-				if content := msg.Content(); content != "" {
-					parts = append(parts, &genai.Part{Text: content})
-				}
-				// TODO: Implement image handling with proper base64 decoding
-				// For now, just add text content
-				geminiContents = append(geminiContents, genai.NewContentFromParts(parts, genai.RoleUser))
-			} else {
-				geminiContents = append(geminiContents, genai.NewContentFromText(msg.Content(), genai.RoleUser))
-			}
-
-		case message.MessageTypeAssistant:
-			// Add assistant messages as context
-			geminiContents = append(geminiContents, genai.NewContentFromText(msg.Content(), genai.RoleModel))
-
-		case message.MessageTypeSystem:
-			// Use the last system message as system instruction
-			systemInstruction = genai.NewContentFromText(msg.Content(), genai.RoleUser)
+	systemInstruction, contents := toGeminiContents(messages, getModelCapabilities(c.core.model), false)
+	return contents, systemInstruction
+}
 
-			// Skip tool call and result messages for structured output
+// applyJSONSchemaTag copies what a field's `jsonschema` tag says (the same
+// tags the tool-calling structured client reads) onto its schema: a
+// description and enum values. It reports whether the field is required.
+func applyJSONSchemaTag(schema *genai.Schema, field reflect.StructField) (required bool) {
+	if d := field.Tag.Get("jsonschema_description"); d != "" {
+		schema.Description = d
+	}
+	for _, opt := range strings.Split(field.Tag.Get("jsonschema"), ",") {
+		key, value, _ := strings.Cut(opt, "=")
+		switch key {
+		case "required":
+			required = true
+		case "description":
+			schema.Description = value
+		case "enum":
+			schema.Enum = append(schema.Enum, value)
 		}
 	}
-
-	return geminiContents, systemInstruction
+	if len(schema.Enum) > 0 && schema.Type == genai.TypeString {
+		schema.Format = "enum"
+	}
+	return required
 }
 
 // isThinkingCapable checks if the current model supports thinking
@@ -226,3 +226,30 @@ func TestGeminiStructuredClient_InterfaceCompliance(t *testing.T) {
 		ChatWithStructure(context.Context, []message.Message, bool, chan<- string) (TestResponse, error)
 	} = client
 }
+
+func TestGeminiStructuredClient_schemaFollowsJSONSchemaTags(t *testing.T) {
+	type Verdict struct {
+		Decision string  `json:"decision" jsonschema:"required,enum=approve,enum=reject"`
+		Reason   string  `json:"reason" jsonschema_description:"Why, in one sentence"`
+		Score    *int    `json:"score,omitempty"`
+		Notes    []*bool `json:"notes"`
+	}
+	client := NewGeminiStructuredClient[Verdict](&GeminiCore{model: "gemini-2.5-flash"})
+
+	schema, err := client.generateGeminiSchema(reflect.TypeOf(Verdict{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(schema.Required, []string{"decision"}) {
+		t.Errorf("required = %v", schema.Required)
+	}
+	if d := schema.Properties["decision"]; !reflect.DeepEqual(d.Enum, []string{"approve", "reject"}) || d.Format != "enum" {
+		t.Errorf("decision = %+v", d)
+	}
+	if schema.Properties["reason"].Description != "Why, in one sentence" {
+		t.Errorf("reason = %+v", schema.Properties["reason"])
+	}
+	if s := schema.Properties["score"]; s.Type != genai.TypeInteger || s.Nullable == nil || !*s.Nullable {
+		t.Errorf("score = %+v", s)
+	}
+}
@@ -1,7 +1,7 @@
 package gemini
 
 import (
-	"encoding/json"
+	"sort"
 	"strings"
 
 	"google.golang.org/genai"
@@ -10,153 +10,102 @@ import (
 	"github.com/fpt/klein-cli/pkg/message"
 )
 
-// convertGeminiArgsToToolArgs converts Gemini function arguments JSON to tool argument values
-func convertGeminiArgsToToolArgs(argsJSON string) message.ToolArgumentValues {
-	result := make(message.ToolArgumentValues)
-
-	if argsJSON == "" {
-		return result
-	}
-
-	// Parse JSON arguments
-	var args map[string]interface{}
-	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
-		// If parsing fails, return empty map
-		return result
-	}
-
-	// Convert interface{} values to proper types
+// toToolArgs converts Gemini function arguments to tool argument values
+func toToolArgs(args map[string]any) message.ToolArgumentValues {
+	result := make(message.ToolArgumentValues, len(args))
 	for key, value := range args {
 		result[key] = value
 	}
-
 	return result
 }
 
-// convertToolArgsToJSON converts tool argument values to JSON string
-func convertToolArgsToJSON(args message.ToolArgumentValues) string {
-	if len(args) == 0 {
-		return "{}"
-	}
-
-	jsonBytes, err := json.Marshal(args)
-	if err != nil {
-		// If marshaling fails, return empty object
-		return "{}"
-	}
-
-	return string(jsonBytes)
-}
-
-// convertToolsToGemini converts domain tools to Gemini function format using tool manager's schema
+// convertToolsToGemini converts domain tools to Gemini function declarations.
+// Parameters go as JSON Schema, so an argument's explicit Properties (nested
+// objects, array items, enums) reach the model verbatim.
+//
+// Declarations are sorted by name: map order would change the request prefix
+// on every call and defeat context caching.
 func convertToolsToGemini(tools map[message.ToolName]message.Tool) []*genai.Tool {
 	if len(tools) == 0 {
 		return nil
 	}
 
-	var geminiTools []*genai.Tool
+	names := make([]string, 0, len(tools))
+	for name := range tools {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
 
 	// Group all functions under a single Tool
-	var functionDeclarations []*genai.FunctionDeclaration
-
-	for _, tool := range tools {
-		// Create properties from tool arguments using the same schema logic as OpenAI
-		properties := make(map[string]*genai.Schema)
-		var required []string
+	functionDeclarations := make([]*genai.FunctionDeclaration, 0, len(tools))
+	for _, name := range names {
+		tool := tools[message.ToolName(name)]
 
+		properties := make(map[string]any)
+		required := []string{}
 		for _, arg := range tool.Arguments() {
-			// Convert tool argument to Gemini schema using JSON schema conversion
-			schema := convertArgumentToGeminiSchema(arg)
-			properties[string(arg.Name)] = schema
-
+			properties[string(arg.Name)] = convertArgumentToProperty(arg)
 			if arg.Required {
 				required = append(required, string(arg.Name))
 			}
 		}
 
-		// Create Gemini function declaration
-		functionDecl := &genai.FunctionDeclaration{
+		functionDeclarations = append(functionDeclarations, &genai.FunctionDeclaration{
 			Name:        string(tool.Name()),
 			Description: tool.Description().String(),
-			Parameters: &genai.Schema{
-				Type:       genai.TypeObject,
-				Properties: properties,
-				Required:   required,
+			ParametersJsonSchema: map[string]any{
+				"type":       "object",
+				"properties": properties,
+				"required":   required,
 			},
-		}
-
-		functionDeclarations = append(functionDeclarations, functionDecl)
-	}
-
-	// Create a single tool with all function declarations
-	if len(functionDeclarations) > 0 {
-		geminiTool := &genai.Tool{
-			FunctionDeclarations: functionDeclarations,
-		}
-		geminiTools = append(geminiTools, geminiTool)
+		})
 	}
 
-	return geminiTools
+	return []*genai.Tool{{FunctionDeclarations: functionDeclarations}}
 }
 
-// convertArgumentToGeminiSchema converts a ToolArgument to Gemini schema format
-func convertArgumentToGeminiSchema(arg message.ToolArgument) *genai.Schema {
-	schema := &genai.Schema{
-		Description: arg.Description.String(),
-	}
-
-	// Map types to Gemini schema types
-	switch arg.Type {
-	case "string":
-		schema.Type = genai.TypeString
-	case "number":
-		schema.Type = genai.TypeNumber
-	case "integer":
-		schema.Type = genai.TypeInteger
-	case "boolean":
-		schema.Type = genai.TypeBoolean
-	case "array":
-		schema.Type = genai.TypeArray
-		// Try to infer array item schema from description
-		if itemSchema := inferGeminiArrayItemSchema(arg.Description.String()); itemSchema != nil {
-			schema.Items = itemSchema
-		}
-	case "object":
-		schema.Type = genai.TypeObject
+// convertArgumentToProperty converts a ToolArgument to a JSON Schema property
+func convertArgumentToProperty(arg message.ToolArgument) map[string]any {
+	argType := strings.TrimSpace(arg.Type)
+	switch argType {
+	case "string", "number", "integer", "boolean", "array", "object":
 	default:
 		// Default to string for unknown types
-		schema.Type = genai.TypeString
+		argType = "string"
 	}
 
-	return schema
-}
-
-// inferGeminiArrayItemSchema attempts to infer array item schema for Gemini format
-func inferGeminiArrayItemSchema(desc string) *genai.Schema {
-	lowerDesc := strings.ToLower(desc)
-
-	// String array
-	if strings.Contains(lowerDesc, "string") || strings.Contains(lowerDesc, "text") {
-		return &genai.Schema{Type: genai.TypeString}
+	property := map[string]any{
+		"type":        argType,
+		"description": arg.Description.String(),
 	}
-
-	// Number array
-	if strings.Contains(lowerDesc, "number") || strings.Contains(lowerDesc, "numeric") {
-		return &genai.Schema{Type: genai.TypeNumber}
+	if argType == "array" {
+		property["items"] = inferArrayItemSchema(arg.Description.String())
 	}
 
-	// Integer array
-	if strings.Contains(lowerDesc, "integer") {
-		return &genai.Schema{Type: genai.TypeInteger}
+	// Explicit properties win over anything inferred
+	for k, v := range arg.Properties {
+		property[k] = v
 	}
+	return property
+}
+
+// inferArrayItemSchema attempts to infer array item schema from the description
+func inferArrayItemSchema(desc string) map[string]any {
+	lowerDesc := strings.ToLower(desc)
 
-	// Boolean array
-	if strings.Contains(lowerDesc, "boolean") || strings.Contains(lowerDesc, "bool") {
-		return &genai.Schema{Type: genai.TypeBoolean}
+	switch {
+	case strings.Contains(lowerDesc, "string") || strings.Contains(lowerDesc, "text"):
+		return map[string]any{"type": "string"}
+	case strings.Contains(lowerDesc, "number") || strings.Contains(lowerDesc, "numeric"):
+		return map[string]any{"type": "number"}
+	case strings.Contains(lowerDesc, "integer"):
+		return map[string]any{"type": "integer"}
+	case strings.Contains(lowerDesc, "boolean") || strings.Contains(lowerDesc, "bool"):
+		return map[string]any{"type": "boolean"}
 	}
 
 	// Default to object for complex arrays
-	return &genai.Schema{Type: genai.TypeObject}
+	return map[string]any{"type": "object"}
 }
 
 // convertToolChoiceToGemini converts domain ToolChoice to Gemini ToolConfig with native FunctionCallingConfig
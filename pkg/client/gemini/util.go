@@ -1,43 +1,47 @@
 package gemini
 
-// Google Gemini 2.5 Models
+import (
+	"sort"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+// Google Gemini models
 // https://ai.google.dev/gemini-api/docs/models
 
 const (
+	modelGemini3Pro        = "gemini-3-pro-preview"
+	modelGemini3Flash      = "gemini-3-flash-preview"
 	modelGemini25Pro       = "gemini-2.5-pro"
 	modelGemini25Flash     = "gemini-2.5-flash"
 	modelGemini25FlashLite = "gemini-2.5-flash-lite"
+	modelGemini20Flash     = "gemini-2.0-flash"
+	modelGemini20FlashLite = "gemini-2.0-flash-lite"
 )
 
-// getGeminiModel maps user-friendly model names to actual Gemini 2.5 model identifiers
+// getGeminiModel maps user-friendly model names to Gemini model identifiers.
+// Any other gemini-* name (a dated preview, a -latest alias) is passed
+// through; its capabilities come from the family it belongs to.
 func getGeminiModel(model string) string {
-	// Normalize the model name to 2.5 series only
+	model = strings.TrimPrefix(model, "models/")
 	switch model {
+	case "gemini-3-pro", "gemini-3-pro-preview":
+		return modelGemini3Pro
+	case "gemini-3-flash", "gemini-3-flash-preview":
+		return modelGemini3Flash
 	case "gemini-2.5-pro", "gemini-pro", "pro":
 		return modelGemini25Pro
 	case "gemini-2.5-flash", "gemini-flash", "flash":
 		return modelGemini25Flash
 	case "gemini-2.5-flash-lite", "gemini-2.5-lite", "gemini-lite", "lite":
 		return modelGemini25FlashLite
-	default:
-		// If it's already a valid Gemini 2.5 model name, return as-is
-		if isValidGeminiModel(model) {
-			return model
-		}
-		// Default to Gemini 2.5 Flash for unknown models (most balanced)
-		return modelGemini25Flash
 	}
-}
-
-// isValidGeminiModel checks if a model name is a valid Gemini 2.5 model
-func isValidGeminiModel(model string) bool {
-	validModels := map[string]bool{
-		// Gemini 2.5 models only
-		modelGemini25Pro:       true,
-		modelGemini25Flash:     true,
-		modelGemini25FlashLite: true,
+	if strings.HasPrefix(model, "gemini-") {
+		return model
 	}
-	return validModels[model]
+	// Default to Gemini 2.5 Flash for unknown models (most balanced)
+	return modelGemini25Flash
 }
 
 // ModelCapabilities represents the capabilities of a Gemini model
@@ -47,60 +51,178 @@ type ModelCapabilities struct {
 	SupportsStructured  bool
 	MaxTokens           int
 	// MaxContextWindow is the approximate input context window size
-	// Gemini 2.5 models support ~1,048,576 token input contexts.
+	// Gemini 2.x and 3 models support ~1,048,576 token input contexts.
 	MaxContextWindow     int
 	SupportsSystemPrompt bool
 	SupportsMultimodal   bool
 	IsReasoningModel     bool
+
+	// Thinking budget range in tokens (Gemini 2.5). CanDisableThinking means
+	// a budget of 0 turns thinking off.
+	ThinkingBudgetMin  int32
+	ThinkingBudgetMax  int32
+	CanDisableThinking bool
+	// ThinkingLevels, when set, replace the budget (Gemini 3), lowest first.
+	ThinkingLevels []genai.ThinkingLevel
+	// RequiresThoughtSignatures: replayed function calls must carry one.
+	RequiresThoughtSignatures bool
+
+	// CacheMinTokens is the smallest prefix explicit context caching accepts.
+	CacheMinTokens int
+}
+
+// modelFamilies holds the capabilities of each model family, keyed by the
+// prefix its model names share.
+var modelFamilies = map[string]ModelCapabilities{
+	"gemini-3-pro": {
+		MaxTokens:                 65536,
+		ThinkingLevels:            []genai.ThinkingLevel{genai.ThinkingLevelLow, genai.ThinkingLevelHigh},
+		RequiresThoughtSignatures: true,
+		CacheMinTokens:            4096,
+	},
+	"gemini-3-flash": {
+		MaxTokens: 65536,
+		ThinkingLevels: []genai.ThinkingLevel{
+			genai.ThinkingLevelMinimal, genai.ThinkingLevelLow, genai.ThinkingLevelMedium, genai.ThinkingLevelHigh,
+		},
+		RequiresThoughtSignatures: true,
+		CacheMinTokens:            1024,
+	},
+	"gemini-2.5-pro": {
+		MaxTokens:         65536,
+		ThinkingBudgetMin: 128,
+		ThinkingBudgetMax: 32768,
+		CacheMinTokens:    4096,
+	},
+	"gemini-2.5-flash": {
+		MaxTokens:          65536,
+		ThinkingBudgetMin:  1,
+		ThinkingBudgetMax:  24576,
+		CanDisableThinking: true,
+		CacheMinTokens:     1024,
+	},
+	"gemini-2.5-flash-lite": {
+		MaxTokens:          65536,
+		ThinkingBudgetMin:  512,
+		ThinkingBudgetMax:  24576,
+		CanDisableThinking: true,
+		CacheMinTokens:     1024,
+	},
+	"gemini-2.0-flash": {
+		MaxTokens:      8192,
+		CacheMinTokens: 4096,
+	},
+	"gemini-2.0-flash-lite": {
+		MaxTokens: 8192,
+	},
 }
 
-// getModelCapabilities returns the capabilities of a specific Gemini 2.5 model
+// familyPrefixes lists the keys of modelFamilies longest first, so that
+// gemini-2.5-flash-lite-… is not taken for gemini-2.5-flash.
+var familyPrefixes = func() []string {
+	keys := make([]string, 0, len(modelFamilies))
+	for k := range modelFamilies {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return len(keys[i]) > len(keys[j]) })
+	return keys
+}()
+
+// getModelCapabilities returns the capabilities of a Gemini model by family.
+// Unknown models are assumed to be like Gemini 2.5 Flash.
 func getModelCapabilities(model string) ModelCapabilities {
-	switch model {
-	case modelGemini25Pro:
-		return ModelCapabilities{
-			SupportsVision:       true,
-			SupportsToolCalling:  true,  // Function calling supported
-			SupportsStructured:   true,  // Structured output supported
-			MaxTokens:            65536, // Output token limit: 65,536 (Input limit: 1,048,576)
-			MaxContextWindow:     1048576,
-			SupportsSystemPrompt: true,
-			SupportsMultimodal:   true, // Audio, images, video, text, PDF
-			IsReasoningModel:     true, // Thinking supported, caching, code execution, grounding
-		}
-	case modelGemini25Flash:
-		return ModelCapabilities{
-			SupportsVision:       true,
-			SupportsToolCalling:  true,  // Function calling supported
-			SupportsStructured:   true,  // Structured output supported
-			MaxTokens:            65536, // Output token limit: 65,536 (Input limit: 1,048,576)
-			MaxContextWindow:     1048576,
-			SupportsSystemPrompt: true,
-			SupportsMultimodal:   true, // Text, images, video, audio (no PDF)
-			IsReasoningModel:     true, // Thinking supported, caching, code execution, grounding
+	caps, ok := ModelCapabilities{}, false
+	for _, prefix := range familyPrefixes {
+		if strings.HasPrefix(model, prefix) {
+			caps, ok = modelFamilies[prefix], true
+			break
 		}
-	case modelGemini25FlashLite:
-		return ModelCapabilities{
-			SupportsVision:       true,
-			SupportsToolCalling:  true,  // Function calling supported
-			SupportsStructured:   true,  // Structured output supported
-			MaxTokens:            65536, // Output token limit: 65,536 (Input limit: 1,048,576)
-			MaxContextWindow:     1048576,
-			SupportsSystemPrompt: true,
-			SupportsMultimodal:   true, // Text, images, video, audio, PDF
-			IsReasoningModel:     true, // Thinking supported, caching, code execution, grounding, URL context
+	}
+	if !ok {
+		caps = modelFamilies["gemini-2.5-flash"]
+	}
+
+	// Common to every current Gemini model
+	caps.SupportsVision = true
+	caps.SupportsToolCalling = true
+	caps.SupportsStructured = true
+	caps.MaxContextWindow = 1048576
+	caps.SupportsSystemPrompt = true
+	caps.SupportsMultimodal = true
+	caps.IsReasoningModel = caps.ThinkingBudgetMax > 0 || len(caps.ThinkingLevels) > 0
+	return caps
+}
+
+// effortBudgets are the thinking budgets llm.effort stands for on budget
+// models, clamped to each model's range.
+var effortBudgets = map[string]int32{
+	"minimal": 512,
+	"low":     1024,
+	"medium":  8192,
+	"high":    24576,
+}
+
+// effortLevels are the thinking levels llm.effort stands for on level
+// models; a model without that level gets the next one up.
+var effortLevels = map[string]genai.ThinkingLevel{
+	"none":    genai.ThinkingLevelMinimal,
+	"minimal": genai.ThinkingLevelMinimal,
+	"low":     genai.ThinkingLevelLow,
+	"medium":  genai.ThinkingLevelMedium,
+	"high":    genai.ThinkingLevelHigh,
+	"xhigh":   genai.ThinkingLevelHigh,
+}
+
+// levelOrder ranks thinking levels for effortLevels' "next one up".
+var levelOrder = map[genai.ThinkingLevel]int{
+	genai.ThinkingLevelMinimal: 0,
+	genai.ThinkingLevelLow:     1,
+	genai.ThinkingLevelMedium:  2,
+	genai.ThinkingLevelHigh:    3,
+}
+
+// thinkingConfig maps llm.effort (the OpenAI scale: none … xhigh) onto the
+// model's thinking controls. It returns nil when there is nothing to set:
+// thoughts are not wanted and effort is unset, leaving the model's own
+// dynamic thinking in charge.
+func thinkingConfig(caps ModelCapabilities, effort string, includeThoughts bool) *genai.ThinkingConfig {
+	if !caps.IsReasoningModel {
+		return nil
+	}
+	cfg := &genai.ThinkingConfig{IncludeThoughts: includeThoughts}
+
+	switch {
+	case effort == "":
+	case len(caps.ThinkingLevels) > 0:
+		want := levelOrder[effortLevels[effort]]
+		cfg.ThinkingLevel = caps.ThinkingLevels[len(caps.ThinkingLevels)-1]
+		for _, l := range caps.ThinkingLevels {
+			if levelOrder[l] >= want {
+				cfg.ThinkingLevel = l
+				break
+			}
 		}
 	default:
-		// Default to Flash Lite capabilities for unknown models
-		return ModelCapabilities{
-			SupportsVision:       true,
-			SupportsToolCalling:  true,  // Function calling supported
-			SupportsStructured:   true,  // Structured output supported
-			MaxTokens:            65536, // Output token limit: 65,536 (Input limit: 1,048,576)
-			MaxContextWindow:     1048576,
-			SupportsSystemPrompt: true,
-			SupportsMultimodal:   true, // Text, images, video, audio, PDF
-			IsReasoningModel:     true, // Thinking supported
+		var budget int32
+		switch effort {
+		case "none":
+			if !caps.CanDisableThinking {
+				budget = caps.ThinkingBudgetMin
+			}
+		case "xhigh":
+			budget = caps.ThinkingBudgetMax
+		default:
+			budget = min(max(effortBudgets[effort], caps.ThinkingBudgetMin), caps.ThinkingBudgetMax)
+		}
+		cfg.ThinkingBudget = &budget
+		if budget == 0 {
+			// Nothing to include when the model does not think.
+			cfg.IncludeThoughts = false
 		}
 	}
+
+	if !cfg.IncludeThoughts && cfg.ThinkingBudget == nil && cfg.ThinkingLevel == "" {
+		return nil
+	}
+	return cfg
 }
@@ -0,0 +1,82 @@
+package gemini
+
+import (
+	"testing"
+
+	"google.golang.org/genai"
+)
+
+func TestGetGeminiModel(t *testing.T) {
+	tests := map[string]string{
+		"flash":                            modelGemini25Flash,
+		"lite":                             modelGemini25FlashLite,
+		"gemini-3-pro":                     modelGemini3Pro,
+		"models/gemini-2.5-pro":            modelGemini25Pro,
+		"gemini-2.5-flash-preview-09-2025": "gemini-2.5-flash-preview-09-2025",
+		"gemini-flash-latest":              "gemini-flash-latest",
+		"gpt-5":                            modelGemini25Flash,
+	}
+	for in, want := range tests {
+		if got := getGeminiModel(in); got != want {
+			t.Errorf("getGeminiModel(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestModelCapabilitiesByFamily(t *testing.T) {
+	if caps := getModelCapabilities("gemini-2.5-flash-lite-preview-06-17"); caps.ThinkingBudgetMin != 512 {
+		t.Errorf("a flash-lite preview was taken for flash: %+v", caps)
+	}
+	if caps := getModelCapabilities("gemini-2.0-flash"); caps.IsReasoningModel || caps.MaxTokens != 8192 {
+		t.Errorf("gemini-2.0-flash: %+v", caps)
+	}
+	if caps := getModelCapabilities(modelGemini3Pro); !caps.RequiresThoughtSignatures || !caps.IsReasoningModel {
+		t.Errorf("gemini-3-pro: %+v", caps)
+	}
+}
+
+func TestThinkingConfigFromEffort(t *testing.T) {
+	budget := func(n int32) *int32 { return &n }
+	tests := []struct {
+		model, effort string
+		thoughts      bool
+		wantBudget    *int32
+		wantLevel     genai.ThinkingLevel
+		wantNil       bool
+	}{
+		{model: modelGemini25Flash, effort: "", thoughts: false, wantNil: true},
+		{model: modelGemini25Flash, effort: "", thoughts: true},
+		{model: modelGemini25Flash, effort: "none", wantBudget: budget(0)},
+		{model: modelGemini25Pro, effort: "none", wantBudget: budget(128)}, // cannot be turned off
+		{model: modelGemini25FlashLite, effort: "minimal", wantBudget: budget(512)},
+		{model: modelGemini25Flash, effort: "medium", wantBudget: budget(8192)},
+		{model: modelGemini25Pro, effort: "high", wantBudget: budget(24576)},
+		{model: modelGemini25Pro, effort: "xhigh", wantBudget: budget(32768)},
+		{model: modelGemini3Pro, effort: "minimal", wantLevel: genai.ThinkingLevelLow}, // next level up
+		{model: modelGemini3Pro, effort: "medium", wantLevel: genai.ThinkingLevelHigh},
+		{model: modelGemini3Flash, effort: "medium", wantLevel: genai.ThinkingLevelMedium},
+		{model: modelGemini3Flash, effort: "none", wantLevel: genai.ThinkingLevelMinimal},
+		{model: modelGemini20Flash, effort: "high", wantNil: true},
+	}
+	for _, tt := range tests {
+		cfg := thinkingConfig(getModelCapabilities(tt.model), tt.effort, tt.thoughts)
+		name := tt.model + "/" + tt.effort
+		if tt.wantNil {
+			if cfg != nil {
+				t.Errorf("%s: want no thinking config, got %+v", name, cfg)
+			}
+			continue
+		}
+		if cfg == nil {
+			t.Errorf("%s: no thinking config", name)
+			continue
+		}
+		if (cfg.ThinkingBudget == nil) != (tt.wantBudget == nil) ||
+			(cfg.ThinkingBudget != nil && *cfg.ThinkingBudget != *tt.wantBudget) {
+			t.Errorf("%s: budget = %v, want %v", name, cfg.ThinkingBudget, tt.wantBudget)
+		}
+		if cfg.ThinkingLevel != tt.wantLevel {
+			t.Errorf("%s: level = %q, want %q", name, cfg.ThinkingLevel, tt.wantLevel)
+		}
+	}
+}
@@ -15,6 +15,7 @@ import (
 
 	"github.com/fpt/klein-cli/pkg/agent/domain"
 	"github.com/fpt/klein-cli/pkg/message"
+	"github.com/fpt/klein-cli/pkg/tokenizer"
 )
 
 const (
@@ -57,21 +58,22 @@ type OpenAIClient struct {
 // NewOpenAIClient creates a new OpenAI client with configurable maxTokens and
 // reasoning effort. maxTokens = 0 means default; effort = "" means
 // defaultReasoningEffort. effort is one of: none, minimal, low, medium, high, xhigh.
-func NewOpenAIClient(model string, maxTokens int, effort string) (*OpenAIClient, error) {
+// opts are applied last, so a base URL given here wins over OPENAI_BASE_URL.
+func NewOpenAIClient(model string, maxTokens int, effort string, opts ...option.RequestOption) (*OpenAIClient, error) {
 	apiKey := os.Getenv("OPENAI_API_KEY")
 	if apiKey == "" {
 		return nil, fmt.Errorf("OPENAI_API_KEY environment variable not set")
 	}
 
 	// Setup client options
-	opts := []option.RequestOption{option.WithAPIKey(apiKey)}
+	clientOpts := []option.RequestOption{option.WithAPIKey(apiKey)}
 
 	// Support custom base URL (for Azure OpenAI, etc.)
 	if baseURL := os.Getenv("OPENAI_BASE_URL"); baseURL != "" {
-		opts = append(opts, option.WithBaseURL(baseURL))
+		clientOpts = append(clientOpts, option.WithBaseURL(baseURL))
 	}
 
-	client := openai.NewClient(opts...)
+	client := openai.NewClient(append(clientOpts, opts...)...)
 
 	// Validate and map model name
 	openaiModel := getOpenAIModel(model)
@@ -135,6 +137,11 @@ func (c *OpenAIClient) MaxContextTokens() int {
 	return 128000
 }
 
+// TokenCounter implementation
+func (c *OpenAIClient) CountTokens(text string) int {
+	return tokenizer.ForModel(c.model).CountTokens(text)
+}
+
 // TokenUsageProvider implementation (best-effort; populated when available)
 func (c *OpenAIClient) LastTokenUsage() (message.TokenUsage, bool) {
 	if c.lastUsage.InputTokens != 0 || c.lastUsage.OutputTokens != 0 || c.lastUsage.TotalTokens != 0 {
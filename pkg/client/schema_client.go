@@ -17,15 +17,12 @@ const schemaMaxRetries = 3
 // the provided JSON Schema. The schema must be a JSON Schema object with a top-level
 // "properties" key (i.e. {"type":"object","properties":{...}}).
 //
-// Requires a tool-capable backend. Retries up to schemaMaxRetries times when the
+// Backends with native structured output (jsonSchemaLLM) are given the schema
+// directly; any other needs tool calling and is made to call a "respond" tool
+// shaped like the schema. Retries up to schemaMaxRetries times when the
 // model's response does not pass JSON Schema validation, feeding the validation
 // error back so the model can self-correct. Returns the validated map on success.
 func InvokeWithSchema(ctx context.Context, llm domain.LLM, prompt string, schema map[string]any) (map[string]any, error) {
-	toolCallingLLM, ok := llm.(domain.ToolCallingLLM)
-	if !ok {
-		return nil, fmt.Errorf("backend %T does not support tool calling (required for --json-schema)", llm)
-	}
-
 	toolArgs, err := schemaPropsToToolArgs(schema)
 	if err != nil {
 		return nil, err
@@ -37,6 +34,15 @@ func InvokeWithSchema(ctx context.Context, llm domain.LLM, prompt string, schema
 		return nil, fmt.Errorf("--json-schema: failed to compile schema for validation: %w", err)
 	}
 
+	if native, ok := llm.(jsonSchemaLLM); ok {
+		return invokeWithNativeSchema(ctx, native, prompt, schema, validator)
+	}
+
+	toolCallingLLM, ok := llm.(domain.ToolCallingLLM)
+	if !ok {
+		return nil, fmt.Errorf("backend %T does not support tool calling (required for --json-schema)", llm)
+	}
+
 	respond := &respondTool{
 		name:        "respond",
 		description: "Provide your answer in the exact JSON structure specified by the schema.",
@@ -102,6 +108,45 @@ func InvokeWithSchema(ctx context.Context, llm domain.LLM, prompt string, schema
 	return nil, fmt.Errorf("response did not match schema after %d attempts: %w", schemaMaxRetries, lastValidationErr)
 }
 
+// jsonSchemaLLM is implemented by backends that constrain a reply to a JSON
+// Schema themselves (Gemini's responseJsonSchema), where the schema reaches
+// the model whole instead of flattened into tool arguments.
+type jsonSchemaLLM interface {
+	ChatWithJSONSchema(ctx context.Context, messages []message.Message, schema map[string]any) (message.Message, error)
+}
+
+// invokeWithNativeSchema is InvokeWithSchema for a jsonSchemaLLM: the same
+// validate-and-retry loop, with the failed reply and the validation error
+// fed back as conversation turns.
+func invokeWithNativeSchema(ctx context.Context, llm jsonSchemaLLM, prompt string, schema map[string]any, validator *jschema.Schema) (map[string]any, error) {
+	msgs := []message.Message{message.NewChatMessage(message.MessageTypeUser, prompt)}
+
+	var lastValidationErr error
+	for attempt := 1; attempt <= schemaMaxRetries; attempt++ {
+		resp, err := llm.ChatWithJSONSchema(ctx, msgs, schema)
+		if err != nil {
+			return nil, fmt.Errorf("LLM call failed (attempt %d): %w", attempt, err)
+		}
+
+		var result map[string]any
+		if err := json.Unmarshal([]byte(resp.Content()), &result); err != nil {
+			lastValidationErr = fmt.Errorf("response is not a JSON object: %w", err)
+		} else if valErr := validator.Validate(result); valErr == nil {
+			return result, nil
+		} else {
+			lastValidationErr = valErr
+		}
+
+		if attempt == schemaMaxRetries {
+			break
+		}
+		msgs = append(msgs, resp, message.NewChatMessage(message.MessageTypeUser,
+			fmt.Sprintf("VALIDATION ERROR: Your response does not match the required JSON Schema.\n\n%s\n\nPlease answer again with a corrected response.", lastValidationErr)))
+	}
+
+	return nil, fmt.Errorf("response did not match schema after %d attempts: %w", schemaMaxRetries, lastValidationErr)
+}
+
 // compileSchema compiles a map[string]any JSON Schema into a validator using
 // santhosh-tekuri/jsonschema/v6. The schema is registered under an in-memory URL.
 func compileSchema(schema map[string]any) (*jschema.Schema, error) {
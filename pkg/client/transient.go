@@ -0,0 +1,115 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/openai/openai-go/v3"
+	"google.golang.org/genai"
+)
+
+// failure is what the fallback client needs to know about a failed call.
+type failure struct {
+	transient  bool          // worth retrying, here or on another backend
+	retryAfter time.Duration // the server's requested wait; 0 = none given
+}
+
+// classifyError reads a provider error. Each SDK reports HTTP failures with
+// its own type, all wrapped (%w) by the clients in this module.
+func classifyError(err error) failure {
+	var (
+		anthropicErr *anthropic.Error
+		openaiErr    *openai.Error
+		geminiErr    genai.APIError
+		geminiErrPtr *genai.APIError
+	)
+	switch {
+	case errors.Is(err, context.Canceled):
+		return failure{}
+	case errors.As(err, &anthropicErr):
+		return failure{transient: transientStatus(anthropicErr.StatusCode), retryAfter: retryAfter(anthropicErr.Response)}
+	case errors.As(err, &openaiErr):
+		return failure{transient: transientStatus(openaiErr.StatusCode), retryAfter: retryAfter(openaiErr.Response)}
+	case errors.As(err, &geminiErr):
+		return failure{transient: transientStatus(geminiErr.Code), retryAfter: geminiRetryDelay(geminiErr)}
+	case errors.As(err, &geminiErrPtr):
+		return failure{transient: transientStatus(geminiErrPtr.Code), retryAfter: geminiRetryDelay(*geminiErrPtr)}
+	}
+	return failure{transient: transientTransport(err)}
+}
+
+// isTransient reports whether err is worth retrying or handing to a fallback.
+func isTransient(err error) bool { return classifyError(err).transient }
+
+// transientStatus: request timeout, conflict (Anthropic and OpenAI use it for
+// lock contention), rate limits and server-side failures, Anthropic's 529
+// "overloaded" included.
+func transientStatus(code int) bool {
+	return code == http.StatusRequestTimeout || code == http.StatusConflict ||
+		code == http.StatusTooManyRequests || code >= 500
+}
+
+// transientTransport recognises failures below HTTP: a reset or refused
+// connection, a stream cut short, a timeout — and the error events Anthropic
+// sends mid-stream, which arrive as plain errors.
+func transientTransport(err error) bool {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.ECONNREFUSED),
+		errors.Is(err, syscall.EPIPE), errors.As(err, &netErr):
+		return true
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "overloaded_error") || strings.Contains(msg, "rate_limit_error") ||
+		strings.Contains(msg, `"api_error"`)
+}
+
+// retryAfter reads the wait a response asks for: retry-after-ms (sent by
+// OpenAI and Anthropic), then Retry-After in seconds or as an HTTP date.
+func retryAfter(resp *http.Response) time.Duration {
+	if resp == nil {
+		return 0
+	}
+	if ms, err := strconv.ParseFloat(resp.Header.Get("Retry-After-Ms"), 64); err == nil && ms > 0 {
+		return time.Duration(ms * float64(time.Millisecond))
+	}
+	v := strings.TrimSpace(resp.Header.Get("Retry-After"))
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.ParseFloat(v, 64); err == nil {
+		if secs <= 0 {
+			return 0
+		}
+		return time.Duration(secs * float64(time.Second))
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0)
+	}
+	return 0
+}
+
+// geminiRetryDelay reads the google.rpc.RetryInfo detail Gemini attaches to a
+// 429 ("retryDelay": "17s"); the SDK drops the response headers.
+func geminiRetryDelay(e genai.APIError) time.Duration {
+	for _, d := range e.Details {
+		if t, _ := d["@type"].(string); !strings.HasSuffix(t, "google.rpc.RetryInfo") {
+			continue
+		}
+		if s, _ := d["retryDelay"].(string); s != "" {
+			if delay, err := time.ParseDuration(s); err == nil && delay > 0 {
+				return delay
+			}
+		}
+	}
+	return 0
+}
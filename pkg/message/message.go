@@ -191,6 +191,12 @@ func (c *ChatMessage) SetMetadata(key string, value any) {
 	c.metadata[key] = value
 }
 
+// SetThinking attaches thinking content to a message built without it, such
+// as a tool call restored with its original ID.
+func (c *ChatMessage) SetThinking(thinking string) {
+	c.thinking = thinking
+}
+
 // TruncatedString returns a truncated, user-friendly representation for conversation previews
 func (c *ChatMessage) TruncatedString() string {
 	content := c.content
@@ -0,0 +1,138 @@
+package tokenizer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// Ranks maps each token's bytes to its rank, which is both its id and its
+// merge priority: lower ranks merge first.
+type Ranks map[string]int
+
+// ParseRanks reads a vocabulary in tiktoken's format: one token per line, its
+// bytes base64-encoded, a space, and its rank.
+func ParseRanks(r io.Reader) (Ranks, error) {
+	ranks := make(Ranks)
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		text := bytes.TrimSpace(sc.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		token, rank, ok := bytes.Cut(text, []byte{' '})
+		if !ok {
+			return nil, fmt.Errorf("line %d: want \"<base64> <rank>\"", line)
+		}
+		decoded, err := base64.StdEncoding.DecodeString(string(token))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		n, err := strconv.Atoi(string(rank))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		ranks[string(decoded)] = n
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(ranks) == 0 {
+		return nil, fmt.Errorf("empty vocabulary")
+	}
+	return ranks, nil
+}
+
+// Encoding is a byte-pair encoding: a pre-tokenizer and a vocabulary.
+type Encoding struct {
+	name  string
+	split splitFunc
+	ranks Ranks
+}
+
+// Name is the encoding's name, such as "o200k_base".
+func (e *Encoding) Name() string { return e.name }
+
+// Encode returns the token ids of text.
+func (e *Encoding) Encode(text string) []int {
+	var ids []int
+	e.split(text, func(piece string) {
+		if id, ok := e.ranks[piece]; ok {
+			ids = append(ids, id)
+			return
+		}
+		for _, part := range e.merge(piece) {
+			ids = append(ids, e.ranks[part])
+		}
+	})
+	return ids
+}
+
+// CountTokens returns how many tokens text encodes to, without building the
+// ids.
+func (e *Encoding) CountTokens(text string) int {
+	n := 0
+	e.split(text, func(piece string) {
+		if _, ok := e.ranks[piece]; ok {
+			n++
+			return
+		}
+		n += len(e.merge(piece))
+	})
+	return n
+}
+
+// merge splits piece into single bytes and repeatedly joins the adjacent pair
+// whose union has the lowest rank, until no pair is in the vocabulary. Every
+// byte is a token in the OpenAI vocabularies; a byte that is not still counts
+// as one part.
+func (e *Encoding) merge(piece string) []string {
+	// bounds[i] is where part i starts; rank[i] is the rank of parts i and
+	// i+1 joined, or MaxInt when they do not form a token.
+	bounds := make([]int, len(piece)+1)
+	for i := range bounds {
+		bounds[i] = i
+	}
+	pairRank := func(i int) int {
+		if i+2 >= len(bounds) {
+			return math.MaxInt
+		}
+		if r, ok := e.ranks[piece[bounds[i]:bounds[i+2]]]; ok {
+			return r
+		}
+		return math.MaxInt
+	}
+	rank := make([]int, len(bounds)-1)
+	for i := range rank {
+		rank[i] = pairRank(i)
+	}
+	for len(bounds) > 2 {
+		best := 0
+		for i := range rank[:len(rank)-1] {
+			if rank[i] < rank[best] {
+				best = i
+			}
+		}
+		if rank[best] == math.MaxInt {
+			break
+		}
+		// Drop the boundary between parts best and best+1; only the pairs
+		// touching the new part change.
+		bounds = append(bounds[:best+1], bounds[best+2:]...)
+		rank = append(rank[:best+1], rank[best+2:]...)
+		rank[best] = pairRank(best)
+		if best > 0 {
+			rank[best-1] = pairRank(best - 1)
+		}
+	}
+	parts := make([]string, len(bounds)-1)
+	for i := range parts {
+		parts[i] = piece[bounds[i]:bounds[i+1]]
+	}
+	return parts
+}
@@ -0,0 +1,50 @@
+package tokenizer
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// estimate counts tokens without a vocabulary: text is pre-tokenized as the
+// encoding would, and each piece is priced by its shape. Common English words
+// are a single token in every modern vocabulary and long identifiers split
+// every few characters; whitespace runs (indentation) merge well, and CJK
+// text runs close to a token per character. It is an approximation, but one
+// that tracks code and non-Latin text far better than four characters per
+// token.
+func estimate(split splitFunc, text string) int {
+	n := 0
+	split(text, func(piece string) { n += estimatePiece(piece) })
+	return n
+}
+
+func estimatePiece(piece string) int {
+	r, _ := utf8.DecodeRuneInString(piece)
+	switch {
+	case unicode.IsSpace(r) && run(piece, 0, isSpace) == len(piece):
+		return 1 + len(piece)/32
+	case unicode.IsNumber(r):
+		return 1
+	}
+	letters, ascii, cjk, other := 0, 0, 0, 0
+	for _, r := range piece {
+		switch {
+		case r < utf8.RuneSelf && unicode.IsLetter(r):
+			letters++
+		case r < utf8.RuneSelf:
+			ascii++
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			cjk++
+		default:
+			other++
+		}
+	}
+	n := cjk + (other+1)/2
+	switch {
+	case letters > 0:
+		n += 1 + (letters-1)/6
+	case ascii > 0:
+		n += (ascii + 1) / 2
+	}
+	return max(n, 1)
+}
@@ -0,0 +1,244 @@
+package tokenizer
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// The OpenAI encodings split text into pieces with a regular expression
+// before running BPE over each piece, and never merge across pieces. The
+// patterns need lookahead, which Go's regexp lacks, so they are written out
+// here as scanners. Each function below mirrors one alternative of the
+// pattern and returns where its match ends, or -1; the tests check the
+// scanners against the published patterns.
+//
+// cl100k_base:
+//
+//	(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+(?!\S)|\s+
+//
+// o200k_base:
+//
+//	[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+(?i:'s|'t|'re|'ve|'m|'ll|'d)?
+//	|[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*(?i:'s|'t|'re|'ve|'m|'ll|'d)?
+//	|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n/]*|\s*[\r\n]+|\s+(?!\S)|\s+
+
+// splitFunc calls yield with each piece of s, in order.
+type splitFunc func(s string, yield func(piece string))
+
+func splitCl100k(s string, yield func(string)) {
+	for i := 0; i < len(s); {
+		end := contraction(s, i)
+		if end < 0 {
+			end = prefixed(s, i, letterRun)
+		}
+		if end < 0 {
+			end = numbers(s, i)
+		}
+		if end < 0 {
+			end = punctuation(s, i, false)
+		}
+		if end < 0 {
+			end = whitespace(s, i)
+		}
+		yield(s[i:end])
+		i = end
+	}
+}
+
+func splitO200k(s string, yield func(string)) {
+	for i := 0; i < len(s); {
+		end := prefixed(s, i, casedWordLowerTail)
+		if end < 0 {
+			end = prefixed(s, i, casedWordUpperHead)
+		}
+		if end < 0 {
+			end = numbers(s, i)
+		}
+		if end < 0 {
+			end = punctuation(s, i, true)
+		}
+		if end < 0 {
+			end = whitespace(s, i)
+		}
+		yield(s[i:end])
+		i = end
+	}
+}
+
+func runeAt(s string, i int) (rune, int) {
+	if i >= len(s) {
+		return utf8.RuneError, 0
+	}
+	return utf8.DecodeRuneInString(s[i:])
+}
+
+func isNewline(r rune) bool { return r == '\r' || r == '\n' }
+
+// isSpace is the patterns' \s.
+func isSpace(r rune) bool { return unicode.IsSpace(r) }
+
+// isOther is [^\s\p{L}\p{N}].
+func isOther(r rune) bool { return !isSpace(r) && !unicode.IsLetter(r) && !unicode.IsNumber(r) }
+
+// isUpperish is o200k's [\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}].
+func isUpperish(r rune) bool {
+	return unicode.In(r, unicode.Lu, unicode.Lt, unicode.Lm, unicode.Lo, unicode.M)
+}
+
+// isLowerish is o200k's [\p{Ll}\p{Lm}\p{Lo}\p{M}].
+func isLowerish(r rune) bool {
+	return unicode.In(r, unicode.Ll, unicode.Lm, unicode.Lo, unicode.M)
+}
+
+// run is the end of the longest run of runes from i satisfying ok.
+func run(s string, i int, ok func(rune) bool) int {
+	for i < len(s) {
+		r, w := runeAt(s, i)
+		if !ok(r) {
+			break
+		}
+		i += w
+	}
+	return i
+}
+
+// contraction matches (?i:'s|'t|'re|'ve|'m|'ll|'d) at i.
+func contraction(s string, i int) int {
+	if i >= len(s) || s[i] != '\'' {
+		return -1
+	}
+	for _, suffix := range [...]string{"s", "t", "re", "ve", "m", "ll", "d"} {
+		j := i + 1
+		for k := 0; k < len(suffix) && j < len(s) && s[j]|0x20 == suffix[k]; k++ {
+			j++
+		}
+		if j-i-1 == len(suffix) {
+			return j
+		}
+	}
+	return -1
+}
+
+// prefixed matches [^\r\n\p{L}\p{N}]? followed by body: with the optional
+// character if that matches, else without it.
+func prefixed(s string, i int, body func(s string, i int) int) int {
+	if r, w := runeAt(s, i); w > 0 && !isNewline(r) && !unicode.IsLetter(r) && !unicode.IsNumber(r) {
+		if end := body(s, i+w); end >= 0 {
+			return end
+		}
+	}
+	return body(s, i)
+}
+
+// letterRun is cl100k's \p{L}+.
+func letterRun(s string, i int) int {
+	if end := run(s, i, unicode.IsLetter); end > i {
+		return end
+	}
+	return -1
+}
+
+// casedWordLowerTail is o200k's first word alternative without its prefix:
+// uppercase-ish runes, then at least one lowercase-ish rune, then an optional
+// contraction. The two classes overlap (Lm, Lo, M), so the head gives back
+// runes until the tail can start.
+func casedWordLowerTail(s string, i int) int {
+	var starts []int
+	j := i
+	for j < len(s) {
+		r, w := runeAt(s, j)
+		if !isUpperish(r) {
+			break
+		}
+		starts = append(starts, j)
+		j += w
+	}
+	starts = append(starts, j)
+	for k := len(starts) - 1; k >= 0; k-- {
+		if r, w := runeAt(s, starts[k]); w > 0 && isLowerish(r) {
+			return optionalContraction(s, run(s, starts[k], isLowerish))
+		}
+	}
+	return -1
+}
+
+// casedWordUpperHead is o200k's second word alternative without its prefix:
+// at least one uppercase-ish rune, any lowercase-ish runes, and an optional
+// contraction.
+func casedWordUpperHead(s string, i int) int {
+	head := run(s, i, isUpperish)
+	if head == i {
+		return -1
+	}
+	return optionalContraction(s, run(s, head, isLowerish))
+}
+
+func optionalContraction(s string, i int) int {
+	if end := contraction(s, i); end >= 0 {
+		return end
+	}
+	return i
+}
+
+// numbers matches \p{N}{1,3}.
+func numbers(s string, i int) int {
+	j := i
+	for n := 0; n < 3 && j < len(s); n++ {
+		r, w := runeAt(s, j)
+		if !unicode.IsNumber(r) {
+			break
+		}
+		j += w
+	}
+	if j == i {
+		return -1
+	}
+	return j
+}
+
+// punctuation matches ` ?[^\s\p{L}\p{N}]+[\r\n]*`, with '/' among the
+// trailing characters for o200k.
+func punctuation(s string, i int, slash bool) int {
+	trailing := isNewline
+	if slash {
+		trailing = func(r rune) bool { return isNewline(r) || r == '/' }
+	}
+	for _, start := range [...]int{i + 1, i} {
+		if start == i+1 && (i >= len(s) || s[i] != ' ') {
+			continue
+		}
+		if end := run(s, start, isOther); end > start {
+			return run(s, end, trailing)
+		}
+	}
+	return -1
+}
+
+// whitespace matches the last three alternatives, which every remaining
+// position satisfies: \s*[\r\n]+ up to the last newline of the run, else
+// \s+(?!\S), which leaves the run's last space to the word after it, else \s+.
+// A rune outside every class (invalid UTF-8) is a piece of its own.
+func whitespace(s string, i int) int {
+	end := run(s, i, isSpace)
+	if end == i {
+		_, w := runeAt(s, i)
+		return i + max(w, 1)
+	}
+	lastNewline, lastStart := -1, i
+	for j := i; j < end; {
+		r, w := runeAt(s, j)
+		if isNewline(r) {
+			lastNewline = j + w
+		}
+		lastStart = j
+		j += w
+	}
+	switch {
+	case lastNewline >= 0:
+		return lastNewline
+	case end == len(s) || lastStart == i:
+		return end
+	default:
+		return lastStart
+	}
+}
@@ -0,0 +1,83 @@
+package tokenizer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dlclark/regexp2"
+)
+
+// The patterns as tiktoken publishes them; the scanners must split exactly as
+// they do.
+const (
+	cl100kPattern = `(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+(?!\S)|\s+`
+	o200kPattern  = `[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+(?i:'s|'t|'re|'ve|'m|'ll|'d)?` +
+		`|[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*(?i:'s|'t|'re|'ve|'m|'ll|'d)?` +
+		`|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n/]*|\s*[\r\n]+|\s+(?!\S)|\s+`
+)
+
+var splitSamples = []string{
+	"Hello, world! It's a test.",
+	"I'LL be there; they've gone, we'd go.",
+	"func add(a, b int) int {\n\treturn a + b\n}\n",
+	"    indented   spaces   \n\n\n  trailing  ",
+	"x  = 12345678 + 3.14159e10",
+	"path/to/file.go:42 // comment\r\n",
+	"HTTPServer getHTTPResponse XMLHttpRequest camelCaseWord",
+	"日本語のテキストと English mixed 한국어",
+	"Ünïcödé naïve café — “quotes” ‘single’",
+	"emoji 🎉🎉 and symbols ©®™ ±∞",
+	"tabs\tand\u00a0nbsp\u2003em space",
+	"'s 't alone 'S",
+	"!!!\n\n???\n",
+	"",
+	" ",
+	"\n",
+	"a\n b",
+	"ǅungla ǈj ʰmodifier",
+	"e\u0301clair combining",
+	"١٢٣ Arabic-Indic digits ٤٥",
+	"https://example.com/a/b?c=d&e=f",
+	"{\"key\": [1, 2, 3], \"nested\": {\"x\": null}}",
+}
+
+func regexSplit(t *testing.T, pattern, s string) []string {
+	t.Helper()
+	re := regexp2.MustCompile(pattern, regexp2.RE2|regexp2.Unicode)
+	var pieces []string
+	m, err := re.FindStringMatch(s)
+	for ; m != nil && err == nil; m, err = re.FindNextMatch(m) {
+		pieces = append(pieces, m.String())
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pieces
+}
+
+func collect(split splitFunc, s string) []string {
+	var pieces []string
+	split(s, func(p string) { pieces = append(pieces, p) })
+	return pieces
+}
+
+func TestSplitMatchesPatterns(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		split   splitFunc
+		pattern string
+	}{
+		{"cl100k", splitCl100k, cl100kPattern},
+		{"o200k", splitO200k, o200kPattern},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, s := range splitSamples {
+				want := regexSplit(t, tc.pattern, s)
+				got := collect(tc.split, s)
+				if strings.Join(got, "|") != strings.Join(want, "|") {
+					t.Errorf("%q:\n got  %q\n want %q", s, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,241 @@
+// Package tokenizer counts tokens the way the model backends do.
+//
+// OpenAI models use the o200k_base or cl100k_base byte-pair encodings. Their
+// vocabularies are not bundled: an encoding is used once its vocabulary is in
+// the cache directory, verified against its published SHA-256. It gets there
+// by hand or, only after SetFetch(true), by a background download from
+// openaipublic.blob.core.windows.net the first time a count needs it. Until
+// then counts come from a per-piece estimate over the same pre-tokenization,
+// with no network access. Anthropic and Google do not publish their
+// tokenizers, so Claude and Gemini counts scale an OpenAI encoding by a fixed
+// ratio; those counts are approximations and say so through Exact.
+package tokenizer
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	pkgLogger "github.com/fpt/klein-cli/pkg/logger"
+)
+
+var logger = pkgLogger.NewComponentLogger("tokenizer")
+
+// vocab describes a published vocabulary.
+type vocab struct {
+	name   string
+	url    string
+	sha256 string
+	split  splitFunc
+}
+
+var (
+	o200k = &vocab{
+		name:   "o200k_base",
+		url:    "https://openaipublic.blob.core.windows.net/encodings/o200k_base.tiktoken",
+		sha256: "446a9538cb6c348e3516120d7c08b09f57c36495e2acfffe59a5bf8b0cfb1a2d",
+		split:  splitO200k,
+	}
+	cl100k = &vocab{
+		name:   "cl100k_base",
+		url:    "https://openaipublic.blob.core.windows.net/encodings/cl100k_base.tiktoken",
+		sha256: "223921b76ee99bde995b7ff738513eef100fb51d18c93597a113bcffe865b2a7",
+		split:  splitCl100k,
+	}
+)
+
+// fetchTimeout bounds a vocabulary download.
+const fetchTimeout = 2 * time.Minute
+
+var (
+	mu       sync.Mutex
+	cacheDir string
+	fetchOn  bool
+	loaded   = map[string]*Encoding{}
+	fetching = map[string]bool{}
+)
+
+// SetCacheDir sets where vocabularies are looked for, and downloaded to when
+// SetFetch allows it. An empty dir (the default) leaves every count an
+// estimate.
+func SetCacheDir(dir string) {
+	mu.Lock()
+	defer mu.Unlock()
+	cacheDir = dir
+	loaded = map[string]*Encoding{}
+}
+
+// SetFetch turns the background download of missing vocabularies on or off
+// (the default). With it off, vocabularies already in the cache directory are
+// still used; the rest stay estimates.
+func SetFetch(enabled bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	fetchOn = enabled
+	loaded = map[string]*Encoding{}
+}
+
+// encoding returns v's encoding if its vocabulary is cached, starting a fetch
+// if it is not and fetching is on; nil until then.
+func encoding(v *vocab) *Encoding {
+	mu.Lock()
+	defer mu.Unlock()
+	if enc, ok := loaded[v.name]; ok {
+		return enc
+	}
+	if cacheDir == "" {
+		return nil
+	}
+	path := filepath.Join(cacheDir, v.name+".tiktoken")
+	data, err := os.ReadFile(path) //nolint:gosec // a path under the configured cache dir
+	if err == nil {
+		enc, err := parseVerified(v, data)
+		if err == nil {
+			loaded[v.name] = enc
+			return enc
+		}
+		logger.Warn("Discarding cached vocabulary", "path", path, "error", err)
+		_ = os.Remove(path)
+	}
+	if fetchOn && !fetching[v.name] {
+		fetching[v.name] = true
+		go fetch(v, path)
+	}
+	// Remember the miss so the file is read once, not on every count; a
+	// finished fetch replaces it.
+	loaded[v.name] = nil
+	return nil
+}
+
+func parseVerified(v *vocab, data []byte) (*Encoding, error) {
+	if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != v.sha256 {
+		return nil, fmt.Errorf("sha256 mismatch")
+	}
+	ranks, err := ParseRanks(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return &Encoding{name: v.name, split: v.split, ranks: ranks}, nil
+}
+
+// fetch downloads v into path and, once verified, makes it the encoding in
+// use.
+func fetch(v *vocab, path string) {
+	enc, err := download(v, path)
+	mu.Lock()
+	defer mu.Unlock()
+	delete(fetching, v.name)
+	if err != nil {
+		logger.Warn("Could not fetch tokenizer vocabulary; token counts stay estimates", "vocabulary", v.name, "error", err)
+		return
+	}
+	if filepath.Dir(path) == cacheDir {
+		loaded[v.name] = enc
+	}
+}
+
+func download(v *vocab, path string) (*Encoding, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", v.url, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return nil, err
+	}
+	enc, err := parseVerified(v, data)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return nil, err
+	}
+	return enc, nil
+}
+
+// Counter counts the tokens of one model's text.
+type Counter struct {
+	vocab *vocab
+	// scale converts the encoding's count to the model's, for models whose
+	// own tokenizer is not public.
+	scale float64
+}
+
+// ForModel returns the counter for a model id, such as "gpt-5-mini" or
+// "claude-sonnet-4-6". Unknown models count as o200k_base.
+func ForModel(model string) *Counter {
+	m := strings.ToLower(model)
+	if i := strings.LastIndex(m, "/"); i >= 0 {
+		m = m[i+1:] // "openai/gpt-oss-20b", "models/gemini-2.5-pro"
+	}
+	switch {
+	case strings.HasPrefix(m, "claude"):
+		// Claude's tokenizer yields roughly 15% more tokens than cl100k on
+		// mixed English and code; a measured ratio, not an exact count.
+		return &Counter{vocab: cl100k, scale: 1.15}
+	case strings.HasPrefix(m, "gemini"), strings.HasPrefix(m, "gemma"):
+		// Gemini's SentencePiece vocabulary lands close to o200k; the ratio
+		// is likewise approximate.
+		return &Counter{vocab: o200k, scale: 1.05}
+	case strings.HasPrefix(m, "gpt-4o"), strings.HasPrefix(m, "gpt-4.1"), strings.HasPrefix(m, "gpt-4.5"):
+		return &Counter{vocab: o200k, scale: 1}
+	case strings.HasPrefix(m, "gpt-4"), strings.HasPrefix(m, "gpt-3.5"), strings.HasPrefix(m, "text-embedding"):
+		return &Counter{vocab: cl100k, scale: 1}
+	default: // gpt-5, o1/o3/o4, codex, gpt-oss and anything newer
+		return &Counter{vocab: o200k, scale: 1}
+	}
+}
+
+// CountTokens returns the number of tokens text takes.
+func (c *Counter) CountTokens(text string) int {
+	if text == "" {
+		return 0
+	}
+	var n int
+	if enc := encoding(c.vocab); enc != nil {
+		n = enc.CountTokens(text)
+	} else {
+		n = estimate(c.vocab.split, text)
+	}
+	if c.scale == 1 {
+		return n
+	}
+	return int(math.Ceil(float64(n) * c.scale))
+}
+
+// Name names the encoding counts are based on, such as "o200k_base".
+func (c *Counter) Name() string { return c.vocab.name }
+
+// Exact reports whether counts are the model's real token counts: its own
+// vocabulary is loaded and no ratio is applied.
+func (c *Counter) Exact() bool {
+	return c.scale == 1 && encoding(c.vocab) != nil
+}
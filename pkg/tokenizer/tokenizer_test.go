@@ -0,0 +1,136 @@
+package tokenizer
+
+import (
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// tinyRanks is a vocabulary of every byte plus a few merges, in tiktoken's
+// file format.
+func tinyRanks(t *testing.T) Ranks {
+	t.Helper()
+	var b strings.Builder
+	rank := 0
+	add := func(tok string) {
+		fmt.Fprintf(&b, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(tok)), rank)
+		rank++
+	}
+	for i := range 256 {
+		add(string([]byte{byte(i)}))
+	}
+	for _, merge := range []string{"in", "ing", "th", "the", " the", "er", "re", "ad", "read"} {
+		add(merge)
+	}
+	ranks, err := ParseRanks(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ranks
+}
+
+func TestEncoding(t *testing.T) {
+	ranks := tinyRanks(t)
+	enc := &Encoding{name: "tiny", split: splitCl100k, ranks: ranks}
+	decode := func(ids []int) []string {
+		byID := map[int]string{}
+		for tok, id := range ranks {
+			byID[id] = tok
+		}
+		var toks []string
+		for _, id := range ids {
+			toks = append(toks, byID[id])
+		}
+		return toks
+	}
+
+	for text, want := range map[string][]string{
+		"the":        {"the"},                    // a whole piece in the vocabulary
+		" thing":     {" ", "th", "ing"},         // " th" is not a token, so the space stays alone
+		"reading":    {"read", "ing"},            // "read" is built from "re" and "ad"
+		"the reader": {"the", " ", "read", "er"}, // pieces never merge across
+		"":           nil,
+		"xé":         {"x", "\xc3", "\xa9"}, // bytes of a rune with no merge
+	} {
+		ids := enc.Encode(text)
+		if got := decode(ids); !slices.Equal(got, want) {
+			t.Errorf("Encode(%q) = %q, want %q", text, got, want)
+		}
+		if n := enc.CountTokens(text); n != len(want) {
+			t.Errorf("CountTokens(%q) = %d, want %d", text, n, len(want))
+		}
+	}
+
+	if _, err := ParseRanks(strings.NewReader("not-base64! 1\n")); err == nil {
+		t.Error("a malformed vocabulary parsed")
+	}
+}
+
+func TestForModel(t *testing.T) {
+	SetCacheDir("") // estimates only; never touch the network
+	for model, want := range map[string]string{
+		"gpt-5-mini":            "o200k_base",
+		"gpt-4o-2024-08-06":     "o200k_base",
+		"o3":                    "o200k_base",
+		"openai/gpt-oss-20b":    "o200k_base",
+		"gpt-4-turbo":           "cl100k_base",
+		"gpt-3.5-turbo":         "cl100k_base",
+		"claude-sonnet-4-6":     "cl100k_base",
+		"models/gemini-2.5-pro": "o200k_base",
+		"qwen3:30b":             "o200k_base",
+	} {
+		if got := ForModel(model).Name(); got != want {
+			t.Errorf("ForModel(%q) = %s, want %s", model, got, want)
+		}
+	}
+
+	text := strings.Repeat("The quick brown fox jumps over the lazy dog. ", 20)
+	gpt := ForModel("gpt-5").CountTokens(text)
+	claude := ForModel("claude-opus-4-1").CountTokens(text)
+	if gpt < 150 || gpt > 260 {
+		t.Errorf("gpt-5 estimate for 180 words = %d", gpt)
+	}
+	if claude <= gpt {
+		t.Errorf("claude count %d not scaled above %d", claude, gpt)
+	}
+	if ForModel("claude-opus-4-1").Exact() || ForModel("gpt-5").Exact() {
+		t.Error("an estimate claimed to be exact")
+	}
+	if n := ForModel("gpt-5").CountTokens(""); n != 0 {
+		t.Errorf("empty text = %d tokens", n)
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	for text, want := range map[string]int{
+		"hello":                 1,
+		" internationalization": 4,
+		"123456":                2,
+		"日本語":                   3,
+		"    ":                  1,
+		"{}();":                 3,
+	} {
+		if got := estimate(splitO200k, text); got != want {
+			t.Errorf("estimate(%q) = %d, want %d", text, got, want)
+		}
+	}
+}
+
+// TestFetchIsOptIn counts with a cache directory but without SetFetch: the
+// count is an estimate and nothing is downloaded.
+func TestFetchIsOptIn(t *testing.T) {
+	SetCacheDir(t.TempDir())
+	t.Cleanup(func() { SetCacheDir("") })
+
+	if n := ForModel("gpt-5").CountTokens("hello world"); n == 0 {
+		t.Error("no estimate without a vocabulary")
+	}
+	mu.Lock()
+	started := len(fetching)
+	mu.Unlock()
+	if started != 0 {
+		t.Errorf("%d vocabulary download(s) started without SetFetch", started)
+	}
+}